@@ -21,11 +21,13 @@ import (
 type Config struct {
 	Port             int
 	PublicUIURL      string
+	PublicAPIURL     string
 	ConversionURL    string
 	LanguageURL      string
 	MosaicURL        string
 	Postgres         config.PostgresConfig
 	Search           config.SearchConfig
+	Embedding        config.EmbeddingConfig
 	Redis            config.RedisConfig
 	S3               config.S3Config
 	Security         config.SecurityConfig
@@ -38,8 +40,11 @@ type Config struct {
 }
 
 type LimitsConfig struct {
-	FileUploadMB     int64
-	FileProcessingMB map[string]int64
+	FileUploadMB             int64
+	FileProcessingMB         map[string]int64
+	UploadChunkMB            int64
+	UploadSessionExpiryHours int64
+	TrashRetentionDays       int64
 }
 
 type DefaultsConfig struct {
@@ -75,6 +80,7 @@ func GetConfig() *Config {
 	config.ReadPostgres(&cfg.Postgres)
 	config.ReadS3(&cfg.S3)
 	config.ReadSearch(&cfg.Search)
+	config.ReadEmbedding(&cfg.Embedding)
 	config.ReadRedis(&cfg.Redis)
 	config.ReadSMTP(&cfg.SMTP)
 	config.ReadEnvironment(&cfg.Environment)
@@ -100,6 +106,7 @@ func readPort(config *Config) {
 
 func readURLs(config *Config) {
 	config.PublicUIURL = os.Getenv("PUBLIC_UI_URL")
+	config.PublicAPIURL = os.Getenv("PUBLIC_API_URL")
 	config.ConversionURL = os.Getenv("CONVERSION_URL")
 	config.LanguageURL = os.Getenv("LANGUAGE_URL")
 	config.MosaicURL = os.Getenv("MOSAIC_URL")
@@ -129,6 +136,30 @@ func readLimits(config *Config) {
 			config.Limits.FileProcessingMB[limit[0]] = v
 		}
 	}
+	config.Limits.UploadChunkMB = 100
+	if len(os.Getenv("LIMITS_UPLOAD_CHUNK_MB")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_UPLOAD_CHUNK_MB"), 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.UploadChunkMB = v
+	}
+	config.Limits.UploadSessionExpiryHours = 24
+	if len(os.Getenv("LIMITS_UPLOAD_SESSION_EXPIRY_HOURS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_UPLOAD_SESSION_EXPIRY_HOURS"), 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.UploadSessionExpiryHours = v
+	}
+	config.Limits.TrashRetentionDays = 30
+	if len(os.Getenv("LIMITS_TRASH_RETENTION_DAYS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_TRASH_RETENTION_DAYS"), 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.TrashRetentionDays = v
+	}
 }
 
 func readDefaults(config *Config) {
@@ -113,3 +113,224 @@ func (s *BleveSuite) TestFilter() {
 		s.Equal("file_b", hits[0].GetID())
 	}
 }
+
+func (s *BleveSuite) TestFilter_Metadata() {
+	values := []struct {
+		opts     repo.FileNewModelOptions
+		metadata *model.SnapshotMetadata
+	}{
+		{
+			opts: repo.FileNewModelOptions{ID: "file_d", WorkspaceID: "workspace_d", Name: "holiday clip.mp4", Type: model.FileTypeFile},
+			metadata: &model.SnapshotMetadata{
+				Media: &model.MediaMetadata{Duration: 120, VideoCodec: helper.ToPtr("h264")},
+			},
+		},
+		{
+			opts: repo.FileNewModelOptions{ID: "file_e", WorkspaceID: "workspace_d", Name: "holiday clip.mkv", Type: model.FileTypeFile},
+			metadata: &model.SnapshotMetadata{
+				Media: &model.MediaMetadata{Duration: 30, VideoCodec: helper.ToPtr("h264")},
+			},
+		},
+		{
+			opts: repo.FileNewModelOptions{ID: "file_f", WorkspaceID: "workspace_d", Name: "holiday photo.jpg", Type: model.FileTypeFile},
+			metadata: &model.SnapshotMetadata{
+				Image: &model.ImageMetadata{CameraMake: helper.ToPtr("Canon"), Latitude: helper.ToPtr(48.85), Longitude: helper.ToPtr(2.35)},
+			},
+		},
+	}
+	for _, v := range values {
+		file := repo.NewFileModelWithOptions(v.opts)
+		file.SetMetadata(v.metadata)
+		err := search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		).Index([]model.File{file})
+		s.Require().NoError(err)
+	}
+
+	hits, err := search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Query("holiday", infra.SearchQueryOptions{
+		Limit:  10,
+		Filter: "workspaceId=\"workspace_d\" AND videoCodec=\"h264\" AND duration>=60",
+	})
+	s.Require().NoError(err)
+	if s.Len(hits, 1) {
+		s.Equal("file_d", hits[0].GetID())
+	}
+
+	hits, err = search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Query("holiday", infra.SearchQueryOptions{
+		Limit:  10,
+		Filter: "workspaceId=\"workspace_d\" AND hasLocation=true",
+	})
+	s.Require().NoError(err)
+	if s.Len(hits, 1) {
+		s.Equal("file_f", hits[0].GetID())
+	}
+}
+
+func (s *BleveSuite) TestSearch() {
+	values := []repo.FileNewModelOptions{
+		{
+			ID:          "file_g",
+			WorkspaceID: "workspace_g",
+			Name:        "a_report.txt",
+			Type:        model.FileTypeFile,
+			Text:        helper.ToPtr("quarterly report of the green orchard"),
+			Intent:      helper.ToPtr(model.SnapshotIntentDocument),
+			Language:    helper.ToPtr("eng"),
+			CreateTime:  "2025-01-10T10:00:00Z",
+		},
+		{
+			ID:          "file_h",
+			WorkspaceID: "workspace_h",
+			Name:        "b_report.txt",
+			Type:        model.FileTypeFile,
+			Text:        helper.ToPtr("yearly report of the green orchard"),
+			Intent:      helper.ToPtr(model.SnapshotIntentDocument),
+			Language:    helper.ToPtr("fra"),
+			CreateTime:  "2025-02-10T10:00:00Z",
+		},
+		{
+			ID:          "file_i",
+			WorkspaceID: "workspace_i",
+			Name:        "c_report.txt",
+			Type:        model.FileTypeFile,
+			Text:        helper.ToPtr("monthly report of the green orchard"),
+			Intent:      helper.ToPtr(model.SnapshotIntentDocument),
+			Language:    helper.ToPtr("eng"),
+			CreateTime:  "2025-02-20T10:00:00Z",
+		},
+	}
+	for _, v := range values {
+		err := search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		).Index([]model.File{repo.NewFileModelWithOptions(v)})
+		s.Require().NoError(err)
+	}
+
+	res, err := search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Search("orchard", infra.SearchOptions{
+		Filter: "workspaceId IN [\"workspace_g\",\"workspace_h\"]",
+		Page:   1,
+		Size:   1,
+		Sort:   []infra.SearchSort{{Field: "createTime", Descending: true}},
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(2), res.TotalHits)
+	if s.Len(res.Hits, 1) {
+		s.Equal("file_h", res.Hits[0].ID)
+		if s.NotEmpty(res.Hits[0].Highlights) {
+			s.Contains(res.Hits[0].Highlights[0], infra.SearchHighlightPreTag+"orchard"+infra.SearchHighlightPostTag)
+		}
+	}
+	s.Equal(map[string]int64{"eng": 1, "fra": 1}, res.Facets[search.FileFacetLanguage])
+	s.Equal(map[string]int64{"2025-01": 1, "2025-02": 1}, res.Facets[search.FileFacetDate])
+	s.Equal(map[string]int64{"workspace_g": 1, "workspace_h": 1}, res.Facets[search.FileFacetWorkspace])
+
+	res, err = search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Search("orchard", infra.SearchOptions{
+		Filter: "workspaceId IN [\"workspace_g\",\"workspace_h\"]",
+		Page:   2,
+		Size:   1,
+		Sort:   []infra.SearchSort{{Field: "createTime", Descending: true}},
+	})
+	s.Require().NoError(err)
+	if s.Len(res.Hits, 1) {
+		s.Equal("file_g", res.Hits[0].ID)
+	}
+}
+
+func (s *BleveSuite) TestFilter_Principals() {
+	values := []struct {
+		opts   repo.FileNewModelOptions
+		users  []string
+		groups []string
+	}{
+		{
+			opts:  repo.FileNewModelOptions{ID: "file_j", WorkspaceID: "workspace_j", Name: "annual budget.xlsx", Type: model.FileTypeFile},
+			users: []string{"user_a"},
+		},
+		{
+			opts:   repo.FileNewModelOptions{ID: "file_k", WorkspaceID: "workspace_j", Name: "annual budget.pdf", Type: model.FileTypeFile},
+			users:  []string{"user_b"},
+			groups: []string{"group_a"},
+		},
+		{
+			opts:  repo.FileNewModelOptions{ID: "file_l", WorkspaceID: "workspace_j", Name: "annual budget.docx", Type: model.FileTypeFile},
+			users: []string{"user_b"},
+		},
+	}
+	for _, v := range values {
+		file := repo.NewFileModelWithOptions(v.opts)
+		var userPermissions []model.CoreUserPermission
+		for _, u := range v.users {
+			userPermissions = append(userPermissions, &repo.UserPermissionValue{UserID: u, Value: model.PermissionViewer})
+		}
+		file.SetUserPermissions(userPermissions)
+		var groupPermissions []model.CoreGroupPermission
+		for _, g := range v.groups {
+			groupPermissions = append(groupPermissions, &repo.GroupPermissionValue{GroupID: g, Value: model.PermissionEditor})
+		}
+		file.SetGroupPermissions(groupPermissions)
+		err := search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		).Index([]model.File{file})
+		s.Require().NoError(err)
+	}
+
+	res, err := search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Search("annual", infra.SearchOptions{
+		Filter: "workspaceId=\"workspace_j\" AND " + search.FilePrincipalFilter("user_a", []string{"group_a"}),
+		Page:   1,
+		Size:   10,
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(2), res.TotalHits)
+	var ids []string
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	s.ElementsMatch([]string{"file_j", "file_k"}, ids)
+
+	res, err = search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	).Search("annual", infra.SearchOptions{
+		Filter: "workspaceId=\"workspace_j\" AND " + search.FilePrincipalFilter("user_c", nil),
+		Page:   1,
+		Size:   10,
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(0), res.TotalHits)
+}
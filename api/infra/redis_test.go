@@ -11,14 +11,19 @@
 package infra_test
 
 import (
+	"errors"
+	"slices"
 	"testing"
+	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 
@@ -56,3 +61,246 @@ func (s *RedisSuite) TestSetAndGet() {
 	s.Equal(opts.Name, file.GetName())
 	s.Equal(opts.Type, file.GetType())
 }
+
+func (s *RedisSuite) TestHashSetAndDelete() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	key := "hash:" + helper.NewID()
+	s.Require().NoError(mgr.HSet(key, "a", "1"))
+	s.Require().NoError(mgr.HSet(key, "b", "2"))
+
+	values, err := mgr.HGetAll(key)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"a": "1", "b": "2"}, values)
+
+	s.Require().NoError(mgr.HDel(key, "a"))
+	values, err = mgr.HGetAll(key)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"b": "2"}, values)
+}
+
+func (s *RedisSuite) TestSetNX() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	key := "mutex:" + helper.NewID()
+	ok, err := mgr.SetNX(key, "1", 0)
+	s.Require().NoError(err)
+	s.True(ok)
+
+	ok, err = mgr.SetNX(key, "1", 0)
+	s.Require().NoError(err)
+	s.False(ok)
+}
+
+func (s *RedisSuite) TestSetManyNX() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	existing := "key:" + helper.NewID()
+	missing := "key:" + helper.NewID()
+	s.Require().NoError(mgr.Set(existing, "newer"))
+
+	written, err := mgr.SetManyNX(map[string]interface{}{existing: "older", missing: "value"}, time.Minute)
+	s.Require().NoError(err)
+	s.Equal([]string{missing}, written)
+
+	value, err := mgr.Get(existing)
+	s.Require().NoError(err)
+	s.Equal("newer", value)
+	value, err = mgr.Get(missing)
+	s.Require().NoError(err)
+	s.Equal("value", value)
+}
+
+func (s *RedisSuite) TestStream() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	stream := "stream:" + helper.NewID()
+	s.Require().NoError(mgr.XGroupCreate(stream, "group"))
+	/* Creating the group twice must succeed */
+	s.Require().NoError(mgr.XGroupCreate(stream, "group"))
+
+	_, err := mgr.XAdd(stream, map[string]interface{}{"a": "1"})
+	s.Require().NoError(err)
+
+	messages, err := mgr.XReadGroup(stream, "group", "consumer", 1, 0)
+	s.Require().NoError(err)
+	s.Require().Len(messages, 1)
+	s.Equal("1", messages[0].Values["a"])
+
+	claimed, err := mgr.XAutoClaim(stream, "group", "another-consumer", 0, 1)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1)
+	s.Equal(messages[0].ID, claimed[0].ID)
+
+	s.Require().NoError(mgr.XAckDel(stream, "group", messages[0].ID))
+	length, err := mgr.XLen(stream)
+	s.Require().NoError(err)
+	s.Equal(int64(0), length)
+}
+
+func (s *RedisSuite) TestSortedSet() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	key := "zset:" + helper.NewID()
+	s.Require().NoError(mgr.ZAdd(key, 1, "a"))
+	s.Require().NoError(mgr.ZAdd(key, 3, "b"))
+
+	members, err := mgr.ZRangeByMaxScore(key, 2, 10)
+	s.Require().NoError(err)
+	s.Equal([]string{"a"}, members)
+
+	removed, err := mgr.ZRem(key, "a")
+	s.Require().NoError(err)
+	s.Equal(int64(1), removed)
+	removed, err = mgr.ZRem(key, "a")
+	s.Require().NoError(err)
+	s.Equal(int64(0), removed)
+
+	count, err := mgr.ZCard(key)
+	s.Require().NoError(err)
+	s.Equal(int64(1), count)
+}
+
+func (s *RedisSuite) TestPublishSubscribe() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	channel := "channel:" + helper.NewID()
+	sub, err := mgr.Subscribe(channel)
+	s.Require().NoError(err)
+	defer func() { _ = sub.Close() }()
+
+	s.Require().NoError(mgr.Publish(channel, "hello"))
+	select {
+	case msg := <-sub.Channel():
+		s.Equal("hello", msg.Payload)
+	case <-time.After(5 * time.Second):
+		s.Fail("message not received")
+	}
+}
+
+func (s *RedisSuite) TestCacheKeyIsVersioned() {
+	file := repo.NewFileModelWithOptions(repo.FileNewModelOptions{
+		ID:   helper.NewID(),
+		Name: "file",
+		Type: model.FileTypeFile,
+	})
+	err := cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	).Set(file)
+	s.Require().NoError(err)
+
+	value, err := infra.NewRedisManager(config.GetConfig().Redis).Get("file:" + cache.KeyVersion + ":" + file.GetID())
+	s.Require().NoError(err)
+	s.Contains(value, file.GetID())
+}
+
+func (s *RedisSuite) TestCacheBroadcastsInvalidations() {
+	sub, err := infra.NewRedisManager(config.GetConfig().Redis).Subscribe("cache:invalidate")
+	s.Require().NoError(err)
+	defer func() { _ = sub.Close() }()
+
+	id := helper.NewID()
+	s.Require().NoError(cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	).Delete(id))
+	select {
+	case msg := <-sub.Channel():
+		s.Contains(msg.Payload, id)
+	case <-time.After(5 * time.Second):
+		s.Fail("invalidation not received")
+	}
+}
+
+func (s *RedisSuite) TestCacheMetrics() {
+	fileCache := cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	)
+	file := repo.NewFileModelWithOptions(repo.FileNewModelOptions{
+		ID:   helper.NewID(),
+		Name: "file",
+		Type: model.FileTypeFile,
+	})
+	s.Require().NoError(fileCache.Set(file))
+	_, err := fileCache.Get(file.GetID())
+	s.Require().NoError(err)
+
+	var metrics *cache.Metrics
+	for _, m := range cache.GetMetrics() {
+		if m.Kind == "file" {
+			metrics = &m
+		}
+	}
+	s.Require().NotNil(metrics)
+	s.Positive(metrics.LocalHits + metrics.Hits)
+}
+
+func (s *RedisSuite) TestCacheGetMany() {
+	fileCache := cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	)
+	var ids []string
+	for range 3 {
+		file := repo.NewFileModelWithOptions(repo.FileNewModelOptions{
+			ID:   helper.NewID(),
+			Name: "file",
+			Type: model.FileTypeFile,
+		})
+		s.Require().NoError(fileCache.Set(file))
+		ids = append(ids, file.GetID())
+	}
+	slices.Reverse(ids)
+
+	files, err := fileCache.GetMany(ids)
+	s.Require().NoError(err)
+	s.Require().Len(files, len(ids))
+	for i, f := range files {
+		s.Equal(ids[i], f.GetID())
+	}
+}
+
+func (s *RedisSuite) TestCacheWriteDuringOutage() {
+	mgr := infra.NewRedisManager(config.GetConfig().Redis)
+	fileCache := cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	)
+	unreachable := config.GetConfig().Redis
+	unreachable.Address = "127.0.0.1:1"
+	unreachableCache := cache.NewFileCache(
+		config.GetConfig().Postgres,
+		unreachable,
+		config.GetConfig().Environment,
+	)
+	newFile := func() model.File {
+		return repo.NewFileModelWithOptions(repo.FileNewModelOptions{
+			ID:   helper.NewID(),
+			Name: "file",
+			Type: model.FileTypeFile,
+		})
+	}
+	updated := newFile()
+	deleted := newFile()
+	s.Require().NoError(fileCache.Set(updated))
+	s.Require().NoError(fileCache.Set(deleted))
+
+	/* A single failure makes every cache bypass Redis for a while */
+	_, _ = unreachableCache.Get(helper.NewID())
+
+	/* Writes still evict the previous entry from Redis when it can be reached... */
+	s.Require().NoError(fileCache.Set(updated))
+	_, err := mgr.Get("file:" + cache.KeyVersion + ":" + updated.GetID())
+	s.ErrorIs(err, redis.Nil)
+
+	/* ...and queue the eviction for when it's back otherwise */
+	s.Require().NoError(unreachableCache.Delete(deleted.GetID()))
+	_, err = mgr.Get("file:" + cache.KeyVersion + ":" + deleted.GetID())
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		_, _ = fileCache.Get(helper.NewID())
+		_, err := mgr.Get("file:" + cache.KeyVersion + ":" + deleted.GetID())
+		return errors.Is(err, redis.Nil)
+	}, 15*time.Second, 500*time.Millisecond)
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/kouprlabs/voltaserve/api/config"
 	"github.com/kouprlabs/voltaserve/api/router"
+	"github.com/kouprlabs/voltaserve/api/service"
 )
 
 //	@title		Voltaserve API
@@ -71,6 +72,8 @@ func main() {
 			{Path: "/" + v + "/groups/:id/image.:extension", Method: "GET"},
 			{Path: "/" + v + "/files/:id/original.:extension", Method: "GET"},
 			{Path: "/" + v + "/files/:id/preview.:extension", Method: "GET"},
+			{Path: "/" + v + "/files/:id/hls/:name", Method: "GET"},
+			{Path: "/" + v + "/files/:id/hls/:rendition/:name", Method: "GET"},
 			{Path: "/" + v + "/files/:id/text.:extension", Method: "GET"},
 			{Path: "/" + v + "/files/:id/ocr.:extension", Method: "GET"},
 			{Path: "/" + v + "/files/:id/thumbnail.:extension", Method: "GET"},
@@ -78,12 +81,30 @@ func main() {
 			{Path: "/" + v + "/files/:id/patch_from_s3", Method: "PATCH"},
 			{Path: "/" + v + "/snapshots/:id", Method: "GET"},
 			{Path: "/" + v + "/snapshots/:id", Method: "PATCH"},
+			{Path: "/" + v + "/snapshot_diffs/:id", Method: "PATCH"},
+			{Path: "/" + v + "/snapshot_diffs/:id/image.:extension", Method: "GET"},
 			{Path: "/" + v + "/mosaics/:file_id/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/pages/:page/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/iiif/info.json", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/iiif/:region/:size/:rotation/:quality.:format", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/deep_zoom.dzi", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/deep_zoom_files/:level/:tile", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/pages/:page/iiif/info.json", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/pages/:page/iiif/:region/:size/:rotation/:quality.:format", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/pages/:page/deep_zoom.dzi", Method: "GET"},
+			{Path: "/" + v + "/mosaics/:file_id/pages/:page/deep_zoom_files/:level/:tile", Method: "GET"},
 			{Path: "/" + v + "/tasks", Method: "POST"},
 			{Path: "/" + v + "/tasks/:id", Method: "DELETE"},
 			{Path: "/" + v + "/tasks/:id", Method: "PATCH"},
 			{Path: "/" + v + "/users/:id/picture.:extension", Method: "GET"},
 			{Path: "/" + v + "/webhooks/users", Method: "POST"},
+			{Path: "/" + v + "/shares/:token", Method: "GET"},
+			{Path: "/" + v + "/shares/:token/unlock", Method: "POST"},
+			{Path: "/" + v + "/shares/:token/upload", Method: "POST"},
+			{Path: "/" + v + "/shares/:token/files/:id/list", Method: "GET"},
+			{Path: "/" + v + "/shares/:token/files/:id/original.:extension", Method: "GET"},
+			{Path: "/" + v + "/shares/:token/files/:id/preview.:extension", Method: "GET"},
+			{Path: "/" + v + "/shares/:token/files/:id/thumbnail.:extension", Method: "GET"},
 		} {
 			if helper.MatchPath(route.Path, c.Path()) && c.Method() == route.Method {
 				return c.Next()
@@ -111,6 +132,21 @@ func main() {
 	router.NewGroupRouter().AppendRoutes(group.Group("groups"))
 	router.NewEntityRouter().AppendRoutes(group.Group("entities"))
 	router.NewWebhookRouter().AppendRoutes(group.Group("webhooks"))
+	router.NewUploadSessionRouter().AppendRoutes(group.Group("upload_sessions"))
+	router.NewShareLinkRouter().AppendRoutes(group.Group("share_links"))
+	router.NewShareRouter().AppendRoutes(group.Group("shares"))
+	router.NewWebhookSubscriptionRouter().AppendRoutes(group.Group("webhook_subscriptions"))
+	router.NewTrashRouter().AppendRoutes(group.Group("trash"))
+	router.NewSnapshotRetentionRouter().AppendRoutes(group.Group("snapshot_retention"))
+	router.NewSnapshotDiffRouter().AppendRoutes(group.Group("snapshot_diffs"))
+	router.NewSearchIndexRouter().AppendRoutes(group.Group("search_index"))
+	router.NewCacheRouter().AppendRoutes(group.Group("cache"))
+
+	service.NewUploadSessionService().StartGarbageCollector()
+	service.NewWebhookSubscriptionService().StartDispatcher()
+	service.NewTrashService().StartGarbageCollector()
+	service.NewSnapshotRetentionService().StartPruner()
+	service.NewSearchIndexService().StartBackfill()
 
 	if err := app.Listen(fmt.Sprintf(":%d", cfg.Port)); err != nil {
 		panic(err)
@@ -0,0 +1,55 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type CacheRouter struct {
+	cacheSvc *service.CacheService
+}
+
+func NewCacheRouter() *CacheRouter {
+	return &CacheRouter{
+		cacheSvc: service.NewCacheService(),
+	}
+}
+
+func (r *CacheRouter) AppendRoutes(g fiber.Router) {
+	g.Get("/metrics", r.GetMetrics)
+}
+
+// GetMetrics godoc
+//
+//	@Summary		Get Metrics
+//	@Description	Get the hits and misses of the caches of the replica which answers, restricted to admins
+//	@Tags			Cache
+//	@Id				cache_get_metrics
+//	@Produce		application/json
+//	@Success		200	{object}	dto.CacheMetricsResult
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/cache/metrics [get]
+func (r *CacheRouter) GetMetrics(c *fiber.Ctx) error {
+	if _, err := helper.GetUserID(c); err != nil {
+		return err
+	}
+	res, err := r.cacheSvc.GetMetrics(helper.IsAdmin(c))
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
@@ -59,14 +59,20 @@ func NewFileRouter() *FileRouter {
 }
 
 const (
-	FileDefaultPageSize = 100
+	FileDefaultPageSize        = 100
+	FileSemanticSearchSize     = 10
+	FileSnapshotLabelMaxLength = 255
+	FileSnapshotNoteMaxLength  = 4096
 )
 
 func (r *FileRouter) AppendRoutes(g fiber.Router) {
 	g.Post("/", r.Create)
 	g.Get("/list", r.ListByPath)
+	g.Get("/search", r.Search)
+	g.Get("/semantic_search", r.SemanticSearch)
 	g.Post("/move", r.MoveMany)
 	g.Post("/copy", r.CopyMany)
+	g.Post("/properties", r.ListPropertiesMany)
 	g.Get("/", r.FindByPath)
 	g.Delete("/", r.DeleteMany)
 	g.Get("/:id", r.Find)
@@ -79,6 +85,8 @@ func (r *FileRouter) AppendRoutes(g fiber.Router) {
 	g.Post("/:id/move/:target_id", r.Move)
 	g.Post("/:id/copy/:target_id", r.Copy)
 	g.Patch("/:id/name", r.PatchName)
+	g.Get("/:id/properties", r.ListProperties)
+	g.Patch("/:id/properties", r.PatchProperties)
 	g.Post("/:id/reprocess", r.Reprocess)
 	g.Get("/:id/size", r.GetSize)
 	g.Post("/grant_user_permission", r.GrantUserPermission)
@@ -89,6 +97,8 @@ func (r *FileRouter) AppendRoutes(g fiber.Router) {
 	g.Get("/:id/group_permissions", r.FindGroupPermissions)
 	g.Get("/:id/original.:extension", r.DownloadOriginal)
 	g.Get("/:id/preview.:extension", r.DownloadPreview)
+	g.Get("/:id/hls/:name", r.DownloadHLS)
+	g.Get("/:id/hls/:rendition/:name", r.DownloadHLS)
 	g.Get("/:id/text.:extension", r.DownloadText)
 	g.Get("/:id/ocr.:extension", r.DownloadOCR)
 	g.Get("/:id/thumbnail.:extension", r.DownloadThumbnail)
@@ -108,6 +118,8 @@ func (r *FileRouter) AppendRoutes(g fiber.Router) {
 //	@Param			workspace_id	query		string	true	"Workspace ID"
 //	@Param			parent_id		query		string	false	"Parent ID"
 //	@Param			name			query		string	false	"Name"
+//	@Param			label			query		string	false	"Label of the snapshot"
+//	@Param			note			query		string	false	"Note of the snapshot"
 //	@Success		201				{object}	dto.File
 //	@Failure		400				{object}	errorpkg.ErrorResponse
 //	@Failure		404				{object}	errorpkg.ErrorResponse
@@ -136,6 +148,10 @@ func (r *FileRouter) Create(c *fiber.Ctx) error {
 	}
 	name := c.Query("name")
 	if fileType == model.FileTypeFile {
+		storeOpts, err := r.parseStoreQueryParams(c)
+		if err != nil {
+			return err
+		}
 		fh, err := c.FormFile("file")
 		if err != nil {
 			return errorpkg.NewInvalidFormFileError("file")
@@ -170,7 +186,8 @@ func (r *FileRouter) Create(c *fiber.Ctx) error {
 				logger.GetLogger().Error(err)
 			}
 		}(path)
-		file, err = r.fileSvc.Store(file.ID, service.FileStoreOptions{Path: &path}, userID)
+		storeOpts.Path = &path
+		file, err = r.fileSvc.Store(file.ID, *storeOpts, userID)
 		if err != nil {
 			return err
 		}
@@ -202,12 +219,14 @@ func (r *FileRouter) Create(c *fiber.Ctx) error {
 //	@Id				files_patch
 //	@Accept			x-www-form-urlencoded
 //	@Produce		application/json
-//	@Param			id	path		string	true	"ID"
-//	@Success		200	{object}	dto.File
-//	@Failure		400	{object}	errorpkg.ErrorResponse
-//	@Failure		403	{object}	errorpkg.ErrorResponse
-//	@Failure		404	{object}	errorpkg.ErrorResponse
-//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Param			id		path		string	true	"ID"
+//	@Param			label	query		string	false	"Label of the snapshot"
+//	@Param			note	query		string	false	"Note of the snapshot"
+//	@Success		200		{object}	dto.File
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
 //	@Router			/files/{id} [patch]
 func (r *FileRouter) Patch(c *fiber.Ctx) error {
 	userID, err := helper.GetUserID(c)
@@ -222,6 +241,10 @@ func (r *FileRouter) Patch(c *fiber.Ctx) error {
 		return errorpkg.NewFileNotFoundError(nil)
 	}
 	file := files[0]
+	storeOpts, err := r.parseStoreQueryParams(c)
+	if err != nil {
+		return err
+	}
 	fh, err := c.FormFile("file")
 	if err != nil {
 		return err
@@ -244,7 +267,8 @@ func (r *FileRouter) Patch(c *fiber.Ctx) error {
 			logger.GetLogger().Error(err)
 		}
 	}(path)
-	file, err = r.fileSvc.Store(file.ID, service.FileStoreOptions{Path: &path}, userID)
+	storeOpts.Path = &path
+	file, err = r.fileSvc.Store(file.ID, *storeOpts, userID)
 	if err != nil {
 		return err
 	}
@@ -336,6 +360,68 @@ func (r *FileRouter) ListByPath(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
+// Search godoc
+//
+//	@Summary		Search
+//	@Description	Search the files of all the workspaces the user can access
+//	@Tags			Files
+//	@Id				files_search
+//	@Produce		application/json
+//	@Param			page		query		string	false	"Page"
+//	@Param			size		query		string	false	"Size"
+//	@Param			sort_by		query		string	false	"Sort By, relevance if empty"
+//	@Param			sort_order	query		string	false	"Sort Order"
+//	@Param			query		query		string	true	"Query"
+//	@Success		200			{object}	dto.FileSearchResult
+//	@Failure		400			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/files/search [get]
+func (r *FileRouter) Search(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts, err := r.parseSearchQueryParams(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.fileSvc.Search(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// SemanticSearch godoc
+//
+//	@Summary		Semantic Search
+//	@Description	Search the text of the files the user can access by meaning rather than by keywords
+//	@Tags			Files
+//	@Id				files_semantic_search
+//	@Produce		application/json
+//	@Param			size	query		string	false	"Size"
+//	@Param			query	query		string	true	"Query"
+//	@Success		200		{object}	dto.FileSemanticSearchResult
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Failure		503		{object}	errorpkg.ErrorResponse
+//	@Router			/files/semantic_search [get]
+func (r *FileRouter) SemanticSearch(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts, err := r.parseSemanticSearchQueryParams(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.fileSvc.SemanticSearch(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
 // List godoc
 //
 //	@Summary		List
@@ -349,6 +435,7 @@ func (r *FileRouter) ListByPath(c *fiber.Ctx) error {
 //	@Param			sort_by		query		string	false	"Sort By"
 //	@Param			sort_order	query		string	false	"Sort Order"
 //	@Param			query		query		string	false	"Query"
+//	@Param			cursor		query		string	false	"Cursor"
 //	@Success		200			{object}	dto.FileList
 //	@Failure		400			{object}	errorpkg.ErrorResponse
 //	@Failure		404			{object}	errorpkg.ErrorResponse
@@ -576,6 +663,96 @@ func (r *FileRouter) PatchName(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
+// ListProperties godoc
+//
+//	@Summary		List Properties
+//	@Description	List Properties
+//	@Tags			Files
+//	@Id				files_list_properties
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{array}		dto.FileProperty
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/files/{id}/properties [get]
+func (r *FileRouter) ListProperties(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.fileSvc.ListProperties(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// ListPropertiesMany godoc
+//
+//	@Summary		List Properties Many
+//	@Description	List the properties of many files, keyed by file ID, the files the user cannot view are left out
+//	@Tags			Files
+//	@Id				files_list_properties_many
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.FileListPropertiesManyOptions	true	"Body"
+//	@Success		200		{object}	map[string][]dto.FileProperty
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/files/properties [post]
+func (r *FileRouter) ListPropertiesMany(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.FileListPropertiesManyOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.fileSvc.ListPropertiesMany(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// PatchProperties godoc
+//
+//	@Summary		Patch Properties
+//	@Description	Patch Properties
+//	@Tags			Files
+//	@Id				files_patch_properties
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			id		path		string							true	"ID"
+//	@Param			body	body		dto.FilePatchPropertiesOptions	true	"Body"
+//	@Success		200		{object}	dto.FilePatchPropertiesResult
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/files/{id}/properties [patch]
+func (r *FileRouter) PatchProperties(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.FilePatchPropertiesOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.fileSvc.PatchProperties(c.Params("id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
 // Reprocess godoc
 //
 //	@Summary		Reprocess
@@ -990,6 +1167,81 @@ func (r *FileRouter) DownloadPreview(c *fiber.Ctx) error {
 	return c.Send(buf.Bytes())
 }
 
+// DownloadHLS godoc
+//
+//	@Summary		Download HLS
+//	@Description	Download the master playlist, or a playlist or segment of a rendition
+//	@Tags			Files
+//	@Id				files_download_hls
+//	@Produce		application/octet-stream
+//	@Param			id				path		string	true	"ID"
+//	@Param			rendition		path		string	false	"Rendition"
+//	@Param			name			path		string	true	"Name"
+//	@Param			access_token	query		string	true	"Access Token"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/files/{id}/hls/{rendition}/{name} [get]
+func (r *FileRouter) DownloadHLS(c *fiber.Ctx) error {
+	accessToken := c.Query("access_token", c.Query("access_key"))
+	if accessToken == "" {
+		return errorpkg.NewFileNotFoundError(nil)
+	}
+	userID, err := r.getUserIDFromAccessToken(accessToken)
+	if err != nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+	id := c.Params("id")
+	if id == "" {
+		return errorpkg.NewMissingQueryParamError("id")
+	}
+	name := c.Params("name")
+	if name == "" {
+		return errorpkg.NewMissingQueryParamError("name")
+	}
+	isPlaylist := filepath.Ext(name) == ".m3u8"
+	rangeHeader := c.Get("Range")
+	if isPlaylist {
+		// Playlists are rewritten, so a range of the stored object is meaningless
+		rangeHeader = ""
+	}
+	buf := r.bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer r.bufferPool.Put(buf)
+	res, err := r.fileSvc.DownloadHLSBuffer(id, c.Params("rendition"), name, rangeHeader, buf, userID)
+	if err != nil {
+		return err
+	}
+	if isPlaylist {
+		// Players resolve the URIs of the playlist relative to its own URL,
+		// which drops the query string, hence the access token is appended
+		c.Set("Content-Type", "application/vnd.apple.mpegurl")
+		c.Set("Cache-Control", "no-cache")
+		return c.Status(http.StatusOK).Send(appendAccessTokenToPlaylist(buf.Bytes(), accessToken))
+	}
+	c.Set("Content-Type", "video/mp2t")
+	if res.RangeInterval != nil {
+		res.RangeInterval.ApplyToFiberContext(c)
+		c.Status(http.StatusPartialContent)
+	} else {
+		c.Set("Content-Length", fmt.Sprintf("%d", len(buf.Bytes())))
+		c.Status(http.StatusOK)
+	}
+	return c.Send(buf.Bytes())
+}
+
+func appendAccessTokenToPlaylist(playlist []byte, accessToken string) []byte {
+	lines := strings.Split(string(playlist), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines[i] = line + "?access_token=" + url.QueryEscape(accessToken)
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
 // DownloadText godoc
 //
 //	@Summary		Download Text
@@ -1149,6 +1401,8 @@ func (r *FileRouter) DownloadThumbnail(c *fiber.Ctx) error {
 //	@Param			key				query		string	true	"Key"
 //	@Param			bucket			query		string	true	"Bucket"
 //	@Param			size			query		string	true	"Size"
+//	@Param			label			query		string	false	"Label of the snapshot"
+//	@Param			note			query		string	false	"Note of the snapshot"
 //	@Success		201				{object}	dto.File
 //	@Failure		404				{object}	errorpkg.ErrorResponse
 //	@Failure		400				{object}	errorpkg.ErrorResponse
@@ -1202,6 +1456,10 @@ func (r *FileRouter) CreateFromS3(c *fiber.Ctx) error {
 	if contentType == "" {
 		return errorpkg.NewMissingQueryParamError("content_type")
 	}
+	storeOpts, err := r.parseStoreQueryParams(c)
+	if err != nil {
+		return err
+	}
 	var size int64
 	if c.Query("size") == "" {
 		return errorpkg.NewMissingQueryParamError("size")
@@ -1226,15 +1484,14 @@ func (r *FileRouter) CreateFromS3(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	file, err = r.fileSvc.Store(file.ID, service.FileStoreOptions{
-		S3Reference: &model.S3Reference{
-			Key:         key,
-			Bucket:      bucket,
-			SnapshotID:  snapshotID,
-			Size:        size,
-			ContentType: contentType,
-		},
-	}, userID)
+	storeOpts.S3Reference = &model.S3Reference{
+		Key:         key,
+		Bucket:      bucket,
+		SnapshotID:  snapshotID,
+		Size:        size,
+		ContentType: contentType,
+	}
+	file, err = r.fileSvc.Store(file.ID, *storeOpts, userID)
 	if err != nil {
 		return err
 	}
@@ -1255,6 +1512,8 @@ func (r *FileRouter) CreateFromS3(c *fiber.Ctx) error {
 //	@Param			bucket			query		string	true	"Bucket"
 //	@Param			size			query		string	true	"Size"
 //	@Param			id				path		string	true	"ID"
+//	@Param			label			query		string	false	"Label of the snapshot"
+//	@Param			note			query		string	false	"Note of the snapshot"
 //	@Success		200				{object}	dto.File
 //	@Failure		404				{object}	errorpkg.ErrorResponse
 //	@Failure		400				{object}	errorpkg.ErrorResponse
@@ -1308,6 +1567,10 @@ func (r *FileRouter) PatchFromS3(c *fiber.Ctx) error {
 	if contentType == "" {
 		return errorpkg.NewMissingQueryParamError("content_type")
 	}
+	storeOpts, err := r.parseStoreQueryParams(c)
+	if err != nil {
+		return err
+	}
 	hasEnoughSpace, err := r.workspaceSvc.HasEnoughSpaceForByteSize(file.Workspace.ID, size, userID)
 	if err != nil {
 		return err
@@ -1315,15 +1578,14 @@ func (r *FileRouter) PatchFromS3(c *fiber.Ctx) error {
 	if !hasEnoughSpace {
 		return errorpkg.NewStorageLimitExceededError()
 	}
-	file, err = r.fileSvc.Store(file.ID, service.FileStoreOptions{
-		S3Reference: &model.S3Reference{
-			Key:         key,
-			Bucket:      bucket,
-			SnapshotID:  snapshotID,
-			Size:        size,
-			ContentType: contentType,
-		},
-	}, userID)
+	storeOpts.S3Reference = &model.S3Reference{
+		Key:         key,
+		Bucket:      bucket,
+		SnapshotID:  snapshotID,
+		Size:        size,
+		ContentType: contentType,
+	}
+	file, err = r.fileSvc.Store(file.ID, *storeOpts, userID)
 	if err != nil {
 		return err
 	}
@@ -1350,6 +1612,25 @@ func (r *FileRouter) getUserIDFromAccessToken(accessToken string) (string, error
 	}
 }
 
+// parseStoreQueryParams reads the label and note to set on the snapshot
+// created by an upload.
+func (r *FileRouter) parseStoreQueryParams(c *fiber.Ctx) (*service.FileStoreOptions, error) {
+	res := &service.FileStoreOptions{}
+	if label := c.Query("label"); label != "" {
+		if len(label) > FileSnapshotLabelMaxLength {
+			return nil, errorpkg.NewInvalidQueryParamError("label")
+		}
+		res.Label = &label
+	}
+	if note := c.Query("note"); note != "" {
+		if len(note) > FileSnapshotNoteMaxLength {
+			return nil, errorpkg.NewInvalidQueryParamError("note")
+		}
+		res.Note = &note
+	}
+	return res, nil
+}
+
 func (r *FileRouter) parseListQueryParams(c *fiber.Ctx) (*service.FileListOptions, error) {
 	var err error
 	var page uint64
@@ -1390,6 +1671,7 @@ func (r *FileRouter) parseListQueryParams(c *fiber.Ctx) (*service.FileListOption
 		Size:      size,
 		SortBy:    sortBy,
 		SortOrder: sortOrder,
+		Cursor:    c.Query("cursor"),
 	}
 	if query != "" {
 		b, err := base64.StdEncoding.DecodeString(query + strings.Repeat("=", (4-len(query)%4)%4))
@@ -1402,3 +1684,68 @@ func (r *FileRouter) parseListQueryParams(c *fiber.Ctx) (*service.FileListOption
 	}
 	return &opts, nil
 }
+
+func (r *FileRouter) parseSearchQueryParams(c *fiber.Ctx) (*service.FileSearchOptions, error) {
+	if c.Query("query") == "" {
+		return nil, errorpkg.NewMissingQueryParamError("query")
+	}
+	if !r.fileSvc.IsValidSearchSortBy(c.Query("sort_by")) {
+		return nil, errorpkg.NewInvalidQueryParamError("sort_by")
+	}
+	listOpts, err := r.parseListQueryParams(c)
+	if err != nil {
+		return nil, err
+	}
+	opts := service.FileSearchOptions{
+		Page:      listOpts.Page,
+		Size:      listOpts.Size,
+		SortBy:    listOpts.SortBy,
+		SortOrder: listOpts.SortOrder,
+	}
+	query, err := url.QueryUnescape(c.Query("query"))
+	if err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	b, err := base64.StdEncoding.DecodeString(query + strings.Repeat("=", (4-len(query)%4)%4))
+	if err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	if err := json.Unmarshal(b, &opts.Query); err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	if err := validator.New().Struct(opts.Query); err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	return &opts, nil
+}
+
+func (r *FileRouter) parseSemanticSearchQueryParams(c *fiber.Ctx) (*service.FileSemanticSearchOptions, error) {
+	if c.Query("query") == "" {
+		return nil, errorpkg.NewMissingQueryParamError("query")
+	}
+	opts := service.FileSemanticSearchOptions{
+		Size: FileSemanticSearchSize,
+	}
+	if c.Query("size") != "" {
+		size, err := strconv.ParseUint(c.Query("size"), 10, 64)
+		if err != nil || size == 0 {
+			return nil, errorpkg.NewInvalidQueryParamError("size")
+		}
+		opts.Size = size
+	}
+	query, err := url.QueryUnescape(c.Query("query"))
+	if err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	b, err := base64.StdEncoding.DecodeString(query + strings.Repeat("=", (4-len(query)%4)%4))
+	if err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	if err := json.Unmarshal(b, &opts.Query); err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	if err := validator.New().Struct(opts.Query); err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("query")
+	}
+	return &opts, nil
+}
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -30,19 +31,41 @@ import (
 
 type MosaicRouter struct {
 	mosaicSvc *service.MosaicService
+	config    *config.Config
 }
 
 func NewMosaicRouter() *MosaicRouter {
 	return &MosaicRouter{
 		mosaicSvc: service.NewMosaicService(),
+		config:    config.GetConfig(),
 	}
 }
 
+// MosaicCacheMaxAge is how long clients can reuse the IIIF and Deep Zoom
+// responses without asking again. It is short because the routes address the
+// file rather than its snapshot, after that clients revalidate with the ETag.
+const MosaicCacheMaxAge = 300
+
+const (
+	IIIFProfileLink  = "<http://iiif.io/api/image/3/level2.json>;rel=\"profile\""
+	IIIFJSONLDType   = "application/ld+json;profile=\"http://iiif.io/api/image/3/context.json\""
+	DeepZoomMIMEType = "application/xml"
+)
+
 func (r *MosaicRouter) AppendRoutes(g fiber.Router) {
 	g.Post("/:file_id", r.Create)
 	g.Delete("/:file_id", r.Delete)
 	g.Get("/:file_id/metadata", r.GetMetadata)
 	g.Get("/:file_id/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", r.DownloadTile)
+	g.Get("/:file_id/pages/:page/metadata", r.GetMetadata)
+	g.Get("/:file_id/pages/:page/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", r.DownloadTile)
+	for _, prefix := range []string{"/:file_id", "/:file_id/pages/:page"} {
+		g.Get(prefix+"/iiif", r.RedirectIIIF)
+		g.Get(prefix+"/iiif/info.json", r.GetIIIFInfo)
+		g.Get(prefix+"/iiif/:region/:size/:rotation/:quality.:format", r.DownloadIIIFImage)
+		g.Get(prefix+"/deep_zoom.dzi", r.GetDeepZoomDescriptor)
+		g.Get(prefix+"/deep_zoom_files/:level/:tile", r.DownloadDeepZoomTile)
+	}
 }
 
 // Create godoc
@@ -101,16 +124,23 @@ func (r *MosaicRouter) Delete(c *fiber.Ctx) error {
 //	@Id				mosaic_get_metadata
 //	@Produce		application/json
 //	@Param			file_id	path		string	true	"File ID"
+//	@Param			page	path		int		false	"Page"
 //	@Success		200		{object}	dto.MosaicMetadata
+//	@Failure		400		{object}	errorpkg.ErrorResponse
 //	@Failure		404		{object}	errorpkg.ErrorResponse
 //	@Failure		500		{object}	errorpkg.ErrorResponse
 //	@Router			/mosaics/{file_id}/metadata [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/metadata [get]
 func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 	userID, err := helper.GetUserID(c)
 	if err != nil {
 		return err
 	}
-	res, err := r.mosaicSvc.GetMetadata(c.Params("file_id"), userID)
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.mosaicSvc.GetMetadata(c.Params("file_id"), page, userID)
 	if err != nil {
 		return err
 	}
@@ -125,6 +155,7 @@ func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 //	@Id				mosaic_download_tile
 //	@Produce		application/octet-stream
 //	@Param			file_id		path		string	true	"File ID"
+//	@Param			page		path		int		false	"Page"
 //	@Param			zoom_level	path		string	true	"Zoom Level"
 //	@Param			row			path		string	true	"Row"
 //	@Param			column		path		string	true	"Column"
@@ -133,6 +164,7 @@ func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 //	@Failure		404			{object}	errorpkg.ErrorResponse
 //	@Failure		500			{object}	errorpkg.ErrorResponse
 //	@Router			/mosaics/{file_id}/zoom_level/{zoom_level}/row/{row}/column/{column}/extension/{extension} [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/zoom_level/{zoom_level}/row/{row}/column/{column}/extension/{extension} [get]
 func (r *MosaicRouter) DownloadTile(c *fiber.Ctx) error {
 	accessToken := c.Query("access_token", c.Query("access_key"))
 	if accessToken == "" {
@@ -173,35 +205,316 @@ func (r *MosaicRouter) DownloadTile(c *fiber.Ctx) error {
 			return err
 		}
 	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
 	b, snapshot, err := r.mosaicSvc.DownloadTileBuffer(id, service.MosaicDownloadTileOptions{
 		ZoomLevel: int(zoomLevel),
 		Row:       int(row),
 		Column:    int(column),
 		Extension: c.Params("extension"),
+		Page:      page,
 	}, userID)
 	if err != nil {
 		return err
 	}
-	var extension string
-	if snapshot.GetPreview() != nil {
-		extension = filepath.Ext(snapshot.GetPreview().Key)
-	} else {
-		extension = filepath.Ext(snapshot.GetOriginal().Key)
-	}
-	if strings.TrimPrefix(extension, ".") != c.Params("extension") {
-		return errorpkg.NewS3ObjectNotFoundError(nil)
+	// The pages of a document are rendered to images, so their tiles don't share
+	// the extension of the document.
+	if page == nil {
+		var extension string
+		if snapshot.GetPreview() != nil {
+			extension = filepath.Ext(snapshot.GetPreview().Key)
+		} else {
+			extension = filepath.Ext(snapshot.GetOriginal().Key)
+		}
+		if strings.TrimPrefix(extension, ".") != c.Params("extension") {
+			return errorpkg.NewS3ObjectNotFoundError(nil)
+		}
 	}
 	c.Set("Content-Type", helper.DetectMIMEFromBytes(b))
 	c.Set("Content-Disposition", fmt.Sprintf("filename=\"tile%s\"", c.Params("extension")))
 	return c.Send(b)
 }
 
+// RedirectIIIF godoc
+//
+//	@Summary		Redirect IIIF
+//	@Description	Redirect the base URI of the IIIF image service to its info.json
+//	@Tags			Mosaic
+//	@Id				mosaic_redirect_iiif
+//	@Param			file_id	path	string	true	"File ID"
+//	@Param			page	path	int		false	"Page"
+//	@Success		303
+//	@Router			/mosaics/{file_id}/iiif [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/iiif [get]
+func (r *MosaicRouter) RedirectIIIF(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(r.iiifID(c.Params("file_id"), page)+"/info.json", fiber.StatusSeeOther)
+}
+
+// GetIIIFInfo godoc
+//
+//	@Summary		Get IIIF Info
+//	@Description	Get the IIIF Image API 3.0 info.json of the mosaic
+//	@Tags			Mosaic
+//	@Id				mosaic_get_iiif_info
+//	@Produce		application/json
+//	@Param			file_id			path		string	true	"File ID"
+//	@Param			page			path		int		false	"Page"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{object}	dto.MosaicIIIFInfo
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{file_id}/iiif/info.json [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/iiif/info.json [get]
+func (r *MosaicRouter) GetIIIFInfo(c *fiber.Ctx) error {
+	userID, err := r.authenticate(c)
+	if err != nil {
+		return err
+	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	if fresh, err := r.setCacheHeaders(c, userID); err != nil {
+		return err
+	} else if fresh {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	res, err := r.mosaicSvc.GetIIIFInfo(c.Params("file_id"), page, r.iiifID(c.Params("file_id"), page), userID)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(c.Get(fiber.HeaderAccept), "application/ld+json") {
+		return c.JSON(res, IIIFJSONLDType)
+	}
+	return c.JSON(res)
+}
+
+// DownloadIIIFImage godoc
+//
+//	@Summary		Download IIIF Image
+//	@Description	Download a region of the mosaic, scaled, rotated and encoded as described by the IIIF Image API 3.0
+//	@Tags			Mosaic
+//	@Id				mosaic_download_iiif_image
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			file_id			path		string	true	"File ID"
+//	@Param			page			path		int		false	"Page"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Param			region			path		string	true	"Region"
+//	@Param			size			path		string	true	"Size"
+//	@Param			rotation		path		string	true	"Rotation"
+//	@Param			quality			path		string	true	"Quality"
+//	@Param			format			path		string	true	"Format"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		413				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{file_id}/iiif/{region}/{size}/{rotation}/{quality}.{format} [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/iiif/{region}/{size}/{rotation}/{quality}.{format} [get]
+func (r *MosaicRouter) DownloadIIIFImage(c *fiber.Ctx) error {
+	userID, err := r.authenticate(c)
+	if err != nil {
+		return err
+	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	if fresh, err := r.setCacheHeaders(c, userID); err != nil {
+		return err
+	} else if fresh {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	opts := service.MosaicDownloadIIIFImageOptions{Page: page}
+	for param, value := range map[string]*string{
+		"region":   &opts.Region,
+		"size":     &opts.Size,
+		"rotation": &opts.Rotation,
+		"quality":  &opts.Quality,
+		"format":   &opts.Format,
+	} {
+		unescaped, err := url.PathUnescape(c.Params(param))
+		if err != nil {
+			return errorpkg.NewInvalidPathParamError(param)
+		}
+		*value = unescaped
+	}
+	b, err := r.mosaicSvc.DownloadIIIFImageBuffer(c.Params("file_id"), opts, userID)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, helper.DetectMIMEFromBytes(b))
+	c.Set(fiber.HeaderLink, IIIFProfileLink)
+	return c.Send(b)
+}
+
+// GetDeepZoomDescriptor godoc
+//
+//	@Summary		Get Deep Zoom Descriptor
+//	@Description	Get the Deep Zoom (DZI) descriptor of the mosaic, the tiles are served next to it under deep_zoom_files
+//	@Tags			Mosaic
+//	@Id				mosaic_get_deep_zoom_descriptor
+//	@Produce		application/xml
+//	@Param			file_id			path		string	true	"File ID"
+//	@Param			page			path		int		false	"Page"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{file_id}/deep_zoom.dzi [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/deep_zoom.dzi [get]
+func (r *MosaicRouter) GetDeepZoomDescriptor(c *fiber.Ctx) error {
+	userID, err := r.authenticate(c)
+	if err != nil {
+		return err
+	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	if fresh, err := r.setCacheHeaders(c, userID); err != nil {
+		return err
+	} else if fresh {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	b, err := r.mosaicSvc.GetDeepZoomDescriptorBuffer(c.Params("file_id"), page, userID)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, DeepZoomMIMEType)
+	return c.Send(b)
+}
+
+// DownloadDeepZoomTile godoc
+//
+//	@Summary		Download Deep Zoom Tile
+//	@Description	Download Deep Zoom Tile
+//	@Tags			Mosaic
+//	@Id				mosaic_download_deep_zoom_tile
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			file_id			path		string	true	"File ID"
+//	@Param			page			path		int		false	"Page"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Param			level			path		int		true	"Level"
+//	@Param			tile			path		string	true	"Tile, formatted as {column}_{row}.{format}"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{file_id}/deep_zoom_files/{level}/{tile} [get]
+//	@Router			/mosaics/{file_id}/pages/{page}/deep_zoom_files/{level}/{tile} [get]
+func (r *MosaicRouter) DownloadDeepZoomTile(c *fiber.Ctx) error {
+	userID, err := r.authenticate(c)
+	if err != nil {
+		return err
+	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	if fresh, err := r.setCacheHeaders(c, userID); err != nil {
+		return err
+	} else if fresh {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	level, err := strconv.Atoi(c.Params("level"))
+	if err != nil {
+		return errorpkg.NewInvalidPathParamError("level")
+	}
+	var column, row int
+	name, format, found := strings.Cut(c.Params("tile"), ".")
+	if !found {
+		return errorpkg.NewInvalidPathParamError("tile")
+	}
+	if _, err := fmt.Sscanf(name, "%d_%d", &column, &row); err != nil {
+		return errorpkg.NewInvalidPathParamError("tile")
+	}
+	b, err := r.mosaicSvc.DownloadDeepZoomTileBuffer(c.Params("file_id"), service.MosaicDownloadDeepZoomTileOptions{
+		Level:  level,
+		Column: column,
+		Row:    row,
+		Format: format,
+		Page:   page,
+	}, userID)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, helper.DetectMIMEFromBytes(b))
+	return c.Send(b)
+}
+
+// authenticate returns the user of a IIIF or Deep Zoom request. Viewers load
+// these without the Authorization header, unless configured to, so the token
+// can also come as the access_token query param. The Deep Zoom tiles inherit
+// the query of the descriptor in OpenSeadragon, whereas IIIF viewers build the
+// image URLs from the id of info.json, so for IIIF the header is required, e.g.
+// with OpenSeadragon's loadTilesWithAjax and ajaxHeaders options.
+func (r *MosaicRouter) authenticate(c *fiber.Ctx) (string, error) {
+	accessToken := c.Query("access_token", c.Query("access_key"))
+	if accessToken == "" {
+		accessToken, _ = strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	}
+	if accessToken == "" {
+		return "", errorpkg.NewFileNotFoundError(nil)
+	}
+	userID, err := r.getUserIDFromAccessToken(accessToken)
+	if err != nil {
+		return "", errorpkg.NewFileNotFoundError(nil)
+	}
+	return userID, nil
+}
+
+// setCacheHeaders lets clients cache the response privately, since it is only
+// for the user, and tag it with the snapshot the mosaic was built from. It
+// returns true if the client already has this version of the response.
+func (r *MosaicRouter) setCacheHeaders(c *fiber.Ctx, userID string) (bool, error) {
+	snapshot, err := r.mosaicSvc.GetSnapshot(c.Params("file_id"), userID)
+	if err != nil {
+		return false, err
+	}
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", MosaicCacheMaxAge))
+	c.Set(fiber.HeaderETag, fmt.Sprintf("\"%s\"", snapshot.GetID()))
+	return c.Fresh(), nil
+}
+
+// iiifID returns the base URI of the IIIF image service of the mosaic, which is
+// public, so that viewers send the image requests to the API.
+func (r *MosaicRouter) iiifID(fileID string, page *int) string {
+	if page == nil {
+		return fmt.Sprintf("%s/v3/mosaics/%s/iiif", r.config.PublicAPIURL, fileID)
+	}
+	return fmt.Sprintf("%s/v3/mosaics/%s/pages/%d/iiif", r.config.PublicAPIURL, fileID, *page)
+}
+
+// parsePage returns the page of the document the route is prefixed with, or nil
+// when the route addresses the mosaic of an image.
+func (r *MosaicRouter) parsePage(c *fiber.Ctx) (*int, error) {
+	if c.Params("page") == "" {
+		return nil, nil
+	}
+	page, err := strconv.Atoi(c.Params("page"))
+	if err != nil || page < 0 {
+		return nil, errorpkg.NewInvalidPathParamError("page")
+	}
+	return &page, nil
+}
+
 func (r *MosaicRouter) getUserIDFromAccessToken(accessToken string) (string, error) {
 	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(config.GetConfig().Security.JWTSigningKey), nil
+		return []byte(r.config.Security.JWTSigningKey), nil
 	})
 	if err != nil {
 		return "", err
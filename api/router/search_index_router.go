@@ -0,0 +1,102 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type SearchIndexRouter struct {
+	searchIndexSvc *service.SearchIndexService
+}
+
+func NewSearchIndexRouter() *SearchIndexRouter {
+	return &SearchIndexRouter{
+		searchIndexSvc: service.NewSearchIndexService(),
+	}
+}
+
+func (r *SearchIndexRouter) AppendRoutes(g fiber.Router) {
+	g.Post("/reindex", r.Reindex)
+	g.Post("/check", r.Check)
+}
+
+// Reindex godoc
+//
+//	@Summary		Reindex
+//	@Description	Rebuild the search indexes from the database, restricted to admins
+//	@Tags			Search Index
+//	@Id				search_index_reindex
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.SearchIndexReindexOptions	true	"Body"
+//	@Success		202		{object}	dto.Task
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/search_index/reindex [post]
+func (r *SearchIndexRouter) Reindex(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.SearchIndexReindexOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.searchIndexSvc.Reindex(*opts, userID, helper.IsAdmin(c))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(res)
+}
+
+// Check godoc
+//
+//	@Summary		Check
+//	@Description	Report the drift between the database and the search indexes, and optionally fix it, restricted to admins
+//	@Tags			Search Index
+//	@Id				search_index_check
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.SearchIndexCheckOptions	true	"Body"
+//	@Success		200		{object}	dto.SearchIndexCheckResult
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/search_index/check [post]
+func (r *SearchIndexRouter) Check(c *fiber.Ctx) error {
+	if _, err := helper.GetUserID(c); err != nil {
+		return err
+	}
+	opts := new(dto.SearchIndexCheckOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.searchIndexSvc.Check(*opts, helper.IsAdmin(c))
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
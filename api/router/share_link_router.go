@@ -0,0 +1,208 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type ShareLinkRouter struct {
+	shareLinkSvc *service.ShareLinkService
+	config       *config.Config
+}
+
+func NewShareLinkRouter() *ShareLinkRouter {
+	return &ShareLinkRouter{
+		shareLinkSvc: service.NewShareLinkService(),
+		config:       config.GetConfig(),
+	}
+}
+
+const (
+	ShareLinkAccessDefaultPageSize = 100
+)
+
+func (r *ShareLinkRouter) AppendRoutes(g fiber.Router) {
+	g.Post("/", r.Create)
+	g.Get("/", r.List)
+	g.Get("/:id", r.Find)
+	g.Post("/:id/revoke", r.Revoke)
+	g.Get("/:id/accesses", r.ListAccesses)
+}
+
+// Create godoc
+//
+//	@Summary		Create
+//	@Description	Create
+//	@Tags			ShareLinks
+//	@Id				share_links_create
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.ShareLinkCreateOptions	true	"Body"
+//	@Success		201		{object}	dto.ShareLink
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/share_links [post]
+func (r *ShareLinkRouter) Create(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.ShareLinkCreateOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.shareLinkSvc.Create(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(res)
+}
+
+// List godoc
+//
+//	@Summary		List
+//	@Description	List
+//	@Tags			ShareLinks
+//	@Id				share_links_list
+//	@Produce		application/json
+//	@Param			file_id	query		string	true	"File ID"
+//	@Success		200		{array}		dto.ShareLink
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/share_links [get]
+func (r *ShareLinkRouter) List(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	fileID := c.Query("file_id")
+	if fileID == "" {
+		return errorpkg.NewMissingQueryParamError("file_id")
+	}
+	res, err := r.shareLinkSvc.List(fileID, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find
+//	@Tags			ShareLinks
+//	@Id				share_links_find
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.ShareLink
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/share_links/{id} [get]
+func (r *ShareLinkRouter) Find(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.shareLinkSvc.Find(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Revoke godoc
+//
+//	@Summary		Revoke
+//	@Description	Revoke, the link stops working but its access log is kept
+//	@Tags			ShareLinks
+//	@Id				share_links_revoke
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.ShareLink
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/share_links/{id}/revoke [post]
+func (r *ShareLinkRouter) Revoke(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.shareLinkSvc.Revoke(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// ListAccesses godoc
+//
+//	@Summary		List Accesses
+//	@Description	List Accesses, most recent first
+//	@Tags			ShareLinks
+//	@Id				share_links_list_accesses
+//	@Produce		application/json
+//	@Param			id		path		string	true	"ID"
+//	@Param			page	query		string	false	"Page"
+//	@Param			size	query		string	false	"Size"
+//	@Success		200		{object}	dto.ShareLinkAccessList
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/share_links/{id}/accesses [get]
+func (r *ShareLinkRouter) ListAccesses(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	var page uint64
+	if c.Query("page") == "" {
+		page = 1
+	} else {
+		page, err = strconv.ParseUint(c.Query("page"), 10, 64)
+		if err != nil || page == 0 {
+			return errorpkg.NewInvalidQueryParamError("page")
+		}
+	}
+	var size uint64
+	if c.Query("size") == "" {
+		size = ShareLinkAccessDefaultPageSize
+	} else {
+		size, err = strconv.ParseUint(c.Query("size"), 10, 64)
+		if err != nil || size == 0 {
+			return errorpkg.NewInvalidQueryParamError("size")
+		}
+	}
+	res, err := r.shareLinkSvc.ListAccesses(c.Params("id"), page, size, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
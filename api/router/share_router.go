@@ -0,0 +1,363 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+// ShareRouter serves the anonymous side of share links, its routes bypass the
+// JWT middleware, the token of the link in the path is what grants access.
+type ShareRouter struct {
+	shareLinkSvc *service.ShareLinkService
+	fileSvc      *service.FileService
+	config       *config.Config
+	bufferPool   sync.Pool
+}
+
+func NewShareRouter() *ShareRouter {
+	return &ShareRouter{
+		shareLinkSvc: service.NewShareLinkService(),
+		fileSvc:      service.NewFileService(),
+		config:       config.GetConfig(),
+		bufferPool: sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+}
+
+func (r *ShareRouter) AppendRoutes(g fiber.Router) {
+	g.Get("/:token", r.Find)
+	g.Post("/:token/unlock", r.Unlock)
+	g.Post("/:token/upload", r.Upload)
+	g.Get("/:token/files/:id/list", r.ListFiles)
+	g.Get("/:token/files/:id/original.:extension", r.DownloadOriginal)
+	g.Get("/:token/files/:id/preview.:extension", r.DownloadPreview)
+	g.Get("/:token/files/:id/thumbnail.:extension", r.DownloadThumbnail)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find, the file is omitted until the share is unlocked if it is protected by a password
+//	@Tags			Shares
+//	@Id				shares_find
+//	@Produce		application/json
+//	@Param			token			path		string	true	"Token"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{object}	dto.Share
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token} [get]
+func (r *ShareRouter) Find(c *fiber.Ctx) error {
+	res, err := r.shareLinkSvc.GetShare(r.newRequest(c))
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Unlock godoc
+//
+//	@Summary		Unlock
+//	@Description	Unlock, the returned access token must be sent with the other requests of this share
+//	@Tags			Shares
+//	@Id				shares_unlock
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			token	path		string					true	"Token"
+//	@Param			body	body		dto.ShareUnlockOptions	true	"Body"
+//	@Success		200		{object}	dto.ShareUnlockResult
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		401		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		410		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/unlock [post]
+func (r *ShareRouter) Unlock(c *fiber.Ctx) error {
+	opts := new(dto.ShareUnlockOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.shareLinkSvc.Unlock(r.newRequest(c), opts.Password)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Upload godoc
+//
+//	@Summary		Upload
+//	@Description	Upload a file into the folder of an upload share
+//	@Tags			Shares
+//	@Id				shares_upload
+//	@Accept			x-www-form-urlencoded
+//	@Produce		application/json
+//	@Param			token			path		string	true	"Token"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		201				{object}	dto.SharedFile
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		401				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/upload [post]
+func (r *ShareRouter) Upload(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return errorpkg.NewInvalidFormFileError("file")
+	}
+	path := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(fh.Filename))
+	if err := c.SaveFile(fh, path); err != nil {
+		return err
+	}
+	defer func(path string) {
+		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(path)
+	res, err := r.shareLinkSvc.Upload(r.newRequest(c), service.ShareUploadOptions{
+		Name: fh.Filename,
+		Path: path,
+		Size: fh.Size,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(res)
+}
+
+// ListFiles godoc
+//
+//	@Summary		List Files
+//	@Description	List the files of a folder of a viewer share
+//	@Tags			Shares
+//	@Id				shares_list_files
+//	@Produce		application/json
+//	@Param			token			path		string	true	"Token"
+//	@Param			id				path		string	true	"ID"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Param			page			query		string	false	"Page"
+//	@Param			size			query		string	false	"Size"
+//	@Param			sort_by			query		string	false	"Sort By"
+//	@Param			sort_order		query		string	false	"Sort Order"
+//	@Success		200				{object}	dto.SharedFileList
+//	@Failure		401				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/files/{id}/list [get]
+func (r *ShareRouter) ListFiles(c *fiber.Ctx) error {
+	var err error
+	var page uint64
+	if c.Query("page") == "" {
+		page = 1
+	} else {
+		page, err = strconv.ParseUint(c.Query("page"), 10, 64)
+		if err != nil {
+			return errorpkg.NewInvalidQueryParamError("page")
+		}
+	}
+	var size uint64
+	if c.Query("size") == "" {
+		size = FileDefaultPageSize
+	} else {
+		size, err = strconv.ParseUint(c.Query("size"), 10, 64)
+		if err != nil {
+			return errorpkg.NewInvalidQueryParamError("size")
+		}
+	}
+	if size == 0 {
+		return errorpkg.NewInvalidQueryParamError("size")
+	}
+	sortBy := c.Query("sort_by")
+	if !r.fileSvc.IsValidSortBy(sortBy) {
+		return errorpkg.NewInvalidQueryParamError("sort_by")
+	}
+	sortOrder := c.Query("sort_order")
+	if !r.fileSvc.IsValidSortOrder(sortOrder) {
+		return errorpkg.NewInvalidQueryParamError("sort_order")
+	}
+	res, err := r.shareLinkSvc.ListFiles(r.newRequest(c), c.Params("id"), service.FileListOptions{
+		Page:      page,
+		Size:      size,
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// DownloadOriginal godoc
+//
+//	@Summary		Download Original
+//	@Description	Download Original, downloads from the first byte count towards the download limit of the share
+//	@Tags			Shares
+//	@Id				shares_download_original
+//	@Produce		application/octet-stream
+//	@Param			token			path		string	true	"Token"
+//	@Param			id				path		string	true	"ID"
+//	@Param			ext				path		string	true	"Extension"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{file}		file
+//	@Failure		401				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/files/{id}/original.{ext} [get]
+func (r *ShareRouter) DownloadOriginal(c *fiber.Ctx) error {
+	extension := c.Params("extension")
+	if extension == "" {
+		return errorpkg.NewMissingQueryParamError("ext")
+	}
+	buf := r.bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer r.bufferPool.Put(buf)
+	res, err := r.shareLinkSvc.DownloadOriginalBuffer(r.newRequest(c), c.Params("id"), c.Get("Range"), buf)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(filepath.Ext(res.Snapshot.GetOriginal().Key), "."), extension) {
+		return errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	c.Set("Content-Type", helper.DetectMIMEFromBytes(buf.Bytes()))
+	c.Set("Content-Disposition", fmt.Sprintf("filename=\"%s\"", filepath.Base(res.File.GetName())))
+	return r.send(c, res, buf)
+}
+
+// DownloadPreview godoc
+//
+//	@Summary		Download Preview
+//	@Description	Download Preview
+//	@Tags			Shares
+//	@Id				shares_download_preview
+//	@Produce		application/octet-stream
+//	@Param			token			path		string	true	"Token"
+//	@Param			id				path		string	true	"ID"
+//	@Param			ext				path		string	true	"Extension"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{file}		file
+//	@Failure		401				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/files/{id}/preview.{ext} [get]
+func (r *ShareRouter) DownloadPreview(c *fiber.Ctx) error {
+	extension := c.Params("extension")
+	if extension == "" {
+		return errorpkg.NewMissingQueryParamError("ext")
+	}
+	buf := r.bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer r.bufferPool.Put(buf)
+	res, err := r.shareLinkSvc.DownloadPreviewBuffer(r.newRequest(c), c.Params("id"), c.Get("Range"), buf)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(filepath.Ext(res.Snapshot.GetPreview().Key), "."), extension) {
+		return errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	c.Set("Content-Type", helper.DetectMIMEFromBytes(buf.Bytes()))
+	c.Set("Content-Disposition", fmt.Sprintf("filename=\"%s\"", helper.FilenameWithNewExtension(res.File.GetName(), extension)))
+	return r.send(c, res, buf)
+}
+
+// DownloadThumbnail godoc
+//
+//	@Summary		Download Thumbnail
+//	@Description	Download Thumbnail
+//	@Tags			Shares
+//	@Id				shares_download_thumbnail
+//	@Produce		application/octet-stream
+//	@Param			token			path		string	true	"Token"
+//	@Param			id				path		string	true	"ID"
+//	@Param			ext				path		string	true	"Extension"
+//	@Param			access_token	query		string	false	"Access Token"
+//	@Success		200				{file}		file
+//	@Failure		401				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		410				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/shares/{token}/files/{id}/thumbnail.{ext} [get]
+func (r *ShareRouter) DownloadThumbnail(c *fiber.Ctx) error {
+	extension := c.Params("extension")
+	if extension == "" {
+		return errorpkg.NewMissingQueryParamError("ext")
+	}
+	buf := r.bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer r.bufferPool.Put(buf)
+	snapshot, err := r.shareLinkSvc.DownloadThumbnailBuffer(r.newRequest(c), c.Params("id"), buf)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(filepath.Ext(snapshot.GetThumbnail().Key), "."), extension) {
+		return errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	c.Set("Content-Type", helper.DetectMIMEFromBytes(buf.Bytes()))
+	c.Set("Content-Disposition", fmt.Sprintf("filename=\"thumbnail%s\"", extension))
+	return c.Send(buf.Bytes())
+}
+
+func (r *ShareRouter) newRequest(c *fiber.Ctx) service.ShareRequest {
+	return service.ShareRequest{
+		Token:       c.Params("token"),
+		AccessToken: c.Query("access_token"),
+		IP:          c.IP(),
+		UserAgent:   c.Get("User-Agent"),
+	}
+}
+
+func (r *ShareRouter) send(c *fiber.Ctx, res *service.DownloadResult, buf *bytes.Buffer) error {
+	if res.RangeInterval != nil {
+		res.RangeInterval.ApplyToFiberContext(c)
+		c.Status(http.StatusPartialContent)
+	} else {
+		c.Set("Content-Length", fmt.Sprintf("%d", len(buf.Bytes())))
+		c.Status(http.StatusOK)
+	}
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type SnapshotDiffRouter struct {
+	snapshotDiffSvc *service.SnapshotDiffService
+	config          *config.Config
+	bufferPool      sync.Pool
+}
+
+func NewSnapshotDiffRouter() *SnapshotDiffRouter {
+	return &SnapshotDiffRouter{
+		snapshotDiffSvc: service.NewSnapshotDiffService(),
+		config:          config.GetConfig(),
+		bufferPool: sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+}
+
+func (r *SnapshotDiffRouter) AppendRoutes(g fiber.Router) {
+	g.Post("/", r.Create)
+	g.Get("/:id", r.Find)
+	g.Patch("/:id", r.Patch)
+	g.Get("/:id/image.:extension", r.DownloadImage)
+}
+
+// Create godoc
+//
+//	@Summary		Create
+//	@Description	Compare two snapshots of the same file
+//	@Tags			SnapshotDiffs
+//	@Id				snapshot_diffs_create
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.SnapshotDiffCreateOptions	true	"Body"
+//	@Success		200		{object}	dto.SnapshotDiff
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		413		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_diffs [post]
+func (r *SnapshotDiffRouter) Create(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.SnapshotDiffCreateOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.snapshotDiffSvc.Create(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find
+//	@Tags			SnapshotDiffs
+//	@Id				snapshot_diffs_find
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.SnapshotDiff
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_diffs/{id} [get]
+func (r *SnapshotDiffRouter) Find(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.snapshotDiffSvc.Find(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Patch godoc
+//
+//	@Summary		Patch
+//	@Description	Patch
+//	@Tags			SnapshotDiffs
+//	@Id				snapshot_diffs_patch
+//	@Accept			application/json
+//	@Param			api_key	query	string							true	"API Key"
+//	@Param			id		path	string							true	"ID"
+//	@Param			body	body	dto.SnapshotDiffPatchOptions	true	"Body"
+//	@Success		204
+//	@Failure		400	{object}	errorpkg.ErrorResponse
+//	@Failure		401	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_diffs/{id} [patch]
+func (r *SnapshotDiffRouter) Patch(c *fiber.Ctx) error {
+	apiKey := c.Query("api_key")
+	if apiKey == "" {
+		return errorpkg.NewMissingQueryParamError("api_key")
+	}
+	if apiKey != r.config.Security.APIKey {
+		return errorpkg.NewInvalidAPIKeyError()
+	}
+	opts := new(dto.SnapshotDiffPatchOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	if err := r.snapshotDiffSvc.Patch(c.Params("id"), *opts); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// DownloadImage godoc
+//
+//	@Summary		Download Image
+//	@Description	Download the image that highlights the differences
+//	@Tags			SnapshotDiffs
+//	@Id				snapshot_diffs_download_image
+//	@Produce		image/png
+//	@Param			id				path		string	true	"ID"
+//	@Param			access_token	query		string	true	"Access Token"
+//	@Param			ext				path		string	true	"Extension"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_diffs/{id}/image{ext} [get]
+func (r *SnapshotDiffRouter) DownloadImage(c *fiber.Ctx) error {
+	accessToken := c.Query("access_token", c.Query("access_key"))
+	if accessToken == "" {
+		return errorpkg.NewSnapshotDiffNotFoundError(nil)
+	}
+	userID, err := r.getUserIDFromAccessToken(accessToken)
+	if err != nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+	extension := c.Params("extension")
+	if extension == "" {
+		return errorpkg.NewMissingQueryParamError("ext")
+	}
+	buf := r.bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer r.bufferPool.Put(buf)
+	diff, err := r.snapshotDiffSvc.DownloadImageBuffer(c.Params("id"), buf, userID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(filepath.Ext(diff.GetArtifact().Key), "."), extension) {
+		return errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	b := buf.Bytes()
+	c.Set("Content-Type", helper.DetectMIMEFromBytes(b))
+	c.Set("Content-Disposition", fmt.Sprintf("filename=\"diff.%s\"", extension))
+	return c.Send(b)
+}
+
+func (r *SnapshotDiffRouter) getUserIDFromAccessToken(accessToken string) (string, error) {
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(config.GetConfig().Security.JWTSigningKey), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid {
+		return "", errors.New("invalid token")
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		return claims["sub"].(string), nil
+	} else {
+		return "", errors.New("cannot find sub claim")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type SnapshotRetentionRouter struct {
+	snapshotRetentionSvc *service.SnapshotRetentionService
+}
+
+func NewSnapshotRetentionRouter() *SnapshotRetentionRouter {
+	return &SnapshotRetentionRouter{
+		snapshotRetentionSvc: service.NewSnapshotRetentionService(),
+	}
+}
+
+func (r *SnapshotRetentionRouter) AppendRoutes(g fiber.Router) {
+	g.Get("/:workspace_id", r.Find)
+	g.Put("/:workspace_id", r.Put)
+	g.Delete("/:workspace_id", r.Delete)
+	g.Post("/:workspace_id/prune", r.Prune)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find the retention policy of a workspace
+//	@Tags			SnapshotRetention
+//	@Id				snapshot_retention_find
+//	@Produce		application/json
+//	@Param			workspace_id	path		string	true	"Workspace ID"
+//	@Success		200				{object}	dto.SnapshotRetentionPolicy
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_retention/{workspace_id} [get]
+func (r *SnapshotRetentionRouter) Find(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.snapshotRetentionSvc.Find(c.Params("workspace_id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Put godoc
+//
+//	@Summary		Put
+//	@Description	Create or replace the retention policy of a workspace
+//	@Tags			SnapshotRetention
+//	@Id				snapshot_retention_put
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			workspace_id	path		string								true	"Workspace ID"
+//	@Param			body			body		dto.SnapshotRetentionPolicyOptions	true	"Body"
+//	@Success		200				{object}	dto.SnapshotRetentionPolicy
+//	@Failure		400				{object}	errorpkg.ErrorResponse
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_retention/{workspace_id} [put]
+func (r *SnapshotRetentionRouter) Put(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.SnapshotRetentionPolicyOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.snapshotRetentionSvc.Put(c.Params("workspace_id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete
+//	@Description	Delete the retention policy of a workspace, snapshots are kept from then on
+//	@Tags			SnapshotRetention
+//	@Id				snapshot_retention_delete
+//	@Produce		application/json
+//	@Param			workspace_id	path	string	true	"Workspace ID"
+//	@Success		204
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_retention/{workspace_id} [delete]
+func (r *SnapshotRetentionRouter) Delete(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := r.snapshotRetentionSvc.Delete(c.Params("workspace_id"), userID); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Prune godoc
+//
+//	@Summary		Prune
+//	@Description	Prune, enforces the retention policy of a workspace right away
+//	@Tags			SnapshotRetention
+//	@Id				snapshot_retention_prune
+//	@Produce		application/json
+//	@Param			workspace_id	path		string	true	"Workspace ID"
+//	@Success		200				{object}	dto.SnapshotPruneResult
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		409				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/snapshot_retention/{workspace_id}/prune [post]
+func (r *SnapshotRetentionRouter) Prune(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.snapshotRetentionSvc.Prune(c.Params("workspace_id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
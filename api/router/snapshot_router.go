@@ -11,6 +11,7 @@
 package router
 
 import (
+	"net/http"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -42,8 +43,13 @@ func (r *SnapshotRouter) AppendRoutes(g fiber.Router) {
 	g.Get("/probe", r.Probe)
 	g.Post("/:id/activate", r.Activate)
 	g.Post("/:id/detach", r.Detach)
+	g.Post("/:id/pin", r.Pin)
+	g.Post("/:id/unpin", r.Unpin)
+	g.Patch("/:id/label", r.PatchLabel)
+	g.Patch("/:id/note", r.PatchNote)
 	g.Get("/:id", r.Find)
 	g.Patch("/:id", r.Patch)
+	g.Post("/:id/embed", r.Embed)
 }
 
 // List godoc
@@ -58,6 +64,10 @@ func (r *SnapshotRouter) AppendRoutes(g fiber.Router) {
 //	@Param			size		query		string	false	"Size"
 //	@Param			sort_by		query		string	false	"Sort By"
 //	@Param			sort_order	query		string	false	"Sort Order"
+//	@Param			label		query		string	false	"Label contains"
+//	@Param			note		query		string	false	"Note contains"
+//	@Param			user_id		query		string	false	"Uploader user ID"
+//	@Param			is_pinned	query		bool	false	"Is pinned"
 //	@Success		200			{object}	dto.SnapshotList
 //	@Failure		400			{object}	errorpkg.ErrorResponse
 //	@Failure		404			{object}	errorpkg.ErrorResponse
@@ -157,6 +167,122 @@ func (r *SnapshotRouter) Detach(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
+// Pin godoc
+//
+//	@Summary		Pin
+//	@Description	Pin, protects from being pruned by the retention policy
+//	@Tags			Snapshots
+//	@Id				snapshots_pin
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.Snapshot
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshots/{id}/pin [post]
+func (r *SnapshotRouter) Pin(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.snapshotSvc.Pin(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Unpin godoc
+//
+//	@Summary		Unpin
+//	@Description	Unpin
+//	@Tags			Snapshots
+//	@Id				snapshots_unpin
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.Snapshot
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshots/{id}/unpin [post]
+func (r *SnapshotRouter) Unpin(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.snapshotSvc.Unpin(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// PatchLabel godoc
+//
+//	@Summary		Patch Label
+//	@Description	Patch Label
+//	@Tags			Snapshots
+//	@Id				snapshots_patch_label
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			id		path		string							true	"ID"
+//	@Param			body	body		dto.SnapshotPatchLabelOptions	true	"Body"
+//	@Success		200		{object}	dto.Snapshot
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/snapshots/{id}/label [patch]
+func (r *SnapshotRouter) PatchLabel(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.SnapshotPatchLabelOptions)
+	if err = c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err = validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.snapshotSvc.PatchLabel(c.Params("id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// PatchNote godoc
+//
+//	@Summary		Patch Note
+//	@Description	Patch Note
+//	@Tags			Snapshots
+//	@Id				snapshots_patch_note
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			id		path		string						true	"ID"
+//	@Param			body	body		dto.SnapshotPatchNoteOptions	true	"Body"
+//	@Success		200		{object}	dto.Snapshot
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/snapshots/{id}/note [patch]
+func (r *SnapshotRouter) PatchNote(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.SnapshotPatchNoteOptions)
+	if err = c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err = validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.snapshotSvc.PatchNote(c.Params("id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
 // Find godoc
 //
 //	@Summary		Find
@@ -220,6 +346,31 @@ func (r *SnapshotRouter) Patch(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
+// Embed godoc
+//
+//	@Summary		Embed
+//	@Description	Chunk and embed the text of the snapshot for the semantic search
+//	@Tags			Snapshots
+//	@Id				snapshots_embed
+//	@Param			id	path	string	true	"ID"
+//	@Success		204
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/snapshots/{id}/embed [post]
+func (r *SnapshotRouter) Embed(c *fiber.Ctx) error {
+	apiKey := c.Query("api_key")
+	if apiKey == "" {
+		return errorpkg.NewMissingQueryParamError("api_key")
+	}
+	if apiKey != r.config.Security.APIKey {
+		return errorpkg.NewInvalidAPIKeyError()
+	}
+	if err := r.snapshotSvc.Embed(c.Params("id")); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
 // GetLanguages godoc
 //
 //	@Summary		Get Languages
@@ -273,10 +424,27 @@ func (r *SnapshotRouter) parseListQueryParams(c *fiber.Ctx) (*service.SnapshotLi
 	if !r.snapshotSvc.IsValidSortOrder(sortOrder) {
 		return nil, errorpkg.NewInvalidQueryParamError("sort_order")
 	}
-	return &service.SnapshotListOptions{
+	res := &service.SnapshotListOptions{
 		Page:      page,
 		Size:      size,
 		SortBy:    sortBy,
 		SortOrder: sortOrder,
-	}, err
+	}
+	if c.Query("label") != "" {
+		res.Label = helper.ToPtr(c.Query("label"))
+	}
+	if c.Query("note") != "" {
+		res.Note = helper.ToPtr(c.Query("note"))
+	}
+	if c.Query("user_id") != "" {
+		res.UserID = helper.ToPtr(c.Query("user_id"))
+	}
+	if c.Query("is_pinned") != "" {
+		isPinned, err := strconv.ParseBool(c.Query("is_pinned"))
+		if err != nil {
+			return nil, errorpkg.NewInvalidQueryParamError("is_pinned")
+		}
+		res.IsPinned = &isPinned
+	}
+	return res, nil
 }
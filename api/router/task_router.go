@@ -45,6 +45,7 @@ func (r *TaskRouter) AppendRoutes(g fiber.Router) {
 	g.Post("/dismiss", r.DismissAll)
 	g.Get("/:id", r.Find)
 	g.Post("/:id/dismiss", r.Dismiss)
+	g.Post("/:id/cancel", r.Cancel)
 	g.Post("/", r.Create)
 	g.Delete("/:id", r.Delete)
 	g.Patch("/:id", r.Patch)
@@ -181,6 +182,31 @@ func (r *TaskRouter) Dismiss(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusNoContent)
 }
 
+// Cancel godoc
+//
+//	@Summary		Cancel
+//	@Description	Cancel
+//	@Tags			Tasks
+//	@Id				tasks_cancel
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.Task
+//	@Failure		400	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/tasks/{id}/cancel [post]
+func (r *TaskRouter) Cancel(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.taskSvc.Cancel(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
 // DismissAll godoc
 //
 //	@Summary		Dismiss All
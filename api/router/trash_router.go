@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type TrashRouter struct {
+	trashSvc *service.TrashService
+}
+
+func NewTrashRouter() *TrashRouter {
+	return &TrashRouter{
+		trashSvc: service.NewTrashService(),
+	}
+}
+
+const (
+	TrashDefaultPageSize = 100
+)
+
+func (r *TrashRouter) AppendRoutes(g fiber.Router) {
+	g.Get("/", r.List)
+	g.Delete("/", r.Empty)
+	g.Post("/:id/restore", r.Restore)
+	g.Delete("/:id", r.Purge)
+}
+
+// List godoc
+//
+//	@Summary		List
+//	@Description	List, most recently trashed first
+//	@Tags			Trash
+//	@Id				trash_list
+//	@Produce		application/json
+//	@Param			workspace_id	query		string	true	"Workspace ID"
+//	@Param			page			query		string	false	"Page"
+//	@Param			size			query		string	false	"Size"
+//	@Success		200				{object}	dto.TrashItemList
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/trash [get]
+func (r *TrashRouter) List(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	workspaceID := c.Query("workspace_id")
+	if workspaceID == "" {
+		return errorpkg.NewMissingQueryParamError("workspace_id")
+	}
+	var page uint64
+	if c.Query("page") == "" {
+		page = 1
+	} else {
+		page, err = strconv.ParseUint(c.Query("page"), 10, 64)
+		if err != nil || page == 0 {
+			return errorpkg.NewInvalidQueryParamError("page")
+		}
+	}
+	var size uint64
+	if c.Query("size") == "" {
+		size = TrashDefaultPageSize
+	} else {
+		size, err = strconv.ParseUint(c.Query("size"), 10, 64)
+		if err != nil || size == 0 {
+			return errorpkg.NewInvalidQueryParamError("size")
+		}
+	}
+	res, err := r.trashSvc.List(workspaceID, service.TrashListOptions{
+		Page: page,
+		Size: size,
+	}, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Restore godoc
+//
+//	@Summary		Restore
+//	@Description	Restore, into the original parent unless another one is given
+//	@Tags			Trash
+//	@Id				trash_restore
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			id		path		string					true	"ID"
+//	@Param			body	body		dto.TrashRestoreOptions	true	"Body"
+//	@Success		200		{object}	dto.File
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/trash/{id}/restore [post]
+func (r *TrashRouter) Restore(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.TrashRestoreOptions)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(opts); err != nil {
+			return err
+		}
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.trashSvc.Restore(c.Params("id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Purge godoc
+//
+//	@Summary		Purge
+//	@Description	Purge, deletes the item permanently
+//	@Tags			Trash
+//	@Id				trash_purge
+//	@Produce		application/json
+//	@Param			id	path	string	true	"ID"
+//	@Success		204
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/trash/{id} [delete]
+func (r *TrashRouter) Purge(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := r.trashSvc.Purge(c.Params("id"), userID); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Empty godoc
+//
+//	@Summary		Empty
+//	@Description	Empty, purges all the items in the trash of the workspace
+//	@Tags			Trash
+//	@Id				trash_empty
+//	@Produce		application/json
+//	@Param			workspace_id	query		string	true	"Workspace ID"
+//	@Success		200				{object}	dto.TrashEmptyResult
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/trash [delete]
+func (r *TrashRouter) Empty(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	workspaceID := c.Query("workspace_id")
+	if workspaceID == "" {
+		return errorpkg.NewMissingQueryParamError("workspace_id")
+	}
+	res, err := r.trashSvc.Empty(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type UploadSessionRouter struct {
+	uploadSessionSvc *service.UploadSessionService
+}
+
+func NewUploadSessionRouter() *UploadSessionRouter {
+	return &UploadSessionRouter{
+		uploadSessionSvc: service.NewUploadSessionService(),
+	}
+}
+
+func (r *UploadSessionRouter) AppendRoutes(g fiber.Router) {
+	g.Post("/", r.Create)
+	g.Get("/:id", r.Find)
+	g.Delete("/:id", r.Delete)
+	g.Put("/:id/chunks/:number", r.PutChunk)
+	g.Post("/:id/finalize", r.Finalize)
+}
+
+// Create godoc
+//
+//	@Summary		Create
+//	@Description	Create
+//	@Tags			UploadSessions
+//	@Id				upload_sessions_create
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.UploadSessionCreateOptions	true	"Body"
+//	@Success		201		{object}	dto.UploadSession
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/upload_sessions [post]
+func (r *UploadSessionRouter) Create(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.UploadSessionCreateOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.uploadSessionSvc.Create(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(res)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find, the offset tells where to resume the upload from
+//	@Tags			UploadSessions
+//	@Id				upload_sessions_find
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.UploadSession
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/upload_sessions/{id} [get]
+func (r *UploadSessionRouter) Find(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.uploadSessionSvc.Find(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// PutChunk godoc
+//
+//	@Summary		Put Chunk
+//	@Description	Put Chunk, every chunk except the last one must be at least 5 MiB, and no chunk can exceed LIMITS_UPLOAD_CHUNK_MB
+//	@Tags			UploadSessions
+//	@Id				upload_sessions_put_chunk
+//	@Accept			application/octet-stream
+//	@Produce		application/json
+//	@Param			id		path		string	true	"ID"
+//	@Param			number	path		int		true	"Number, starting at 1"
+//	@Success		200		{object}	dto.UploadSession
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		409		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/upload_sessions/{id}/chunks/{number} [put]
+func (r *UploadSessionRouter) PutChunk(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(c.Params("number"))
+	if err != nil {
+		return errorpkg.NewInvalidPathError(err)
+	}
+	body := c.Body()
+	res, err := r.uploadSessionSvc.PutChunk(c.Params("id"), number, bytes.NewReader(body), int64(len(body)), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Finalize godoc
+//
+//	@Summary		Finalize
+//	@Description	Finalize
+//	@Tags			UploadSessions
+//	@Id				upload_sessions_finalize
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.File
+//	@Failure		400	{object}	errorpkg.ErrorResponse
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		409	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/upload_sessions/{id}/finalize [post]
+func (r *UploadSessionRouter) Finalize(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.uploadSessionSvc.Finalize(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete
+//	@Description	Delete
+//	@Tags			UploadSessions
+//	@Id				upload_sessions_delete
+//	@Produce		application/json
+//	@Param			id	path	string	true	"ID"
+//	@Success		204
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		409	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/upload_sessions/{id} [delete]
+func (r *UploadSessionRouter) Delete(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := r.uploadSessionSvc.Delete(c.Params("id"), userID); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+)
+
+type WebhookSubscriptionRouter struct {
+	webhookSubscriptionSvc *service.WebhookSubscriptionService
+}
+
+func NewWebhookSubscriptionRouter() *WebhookSubscriptionRouter {
+	return &WebhookSubscriptionRouter{
+		webhookSubscriptionSvc: service.NewWebhookSubscriptionService(),
+	}
+}
+
+const (
+	WebhookDeliveryDefaultPageSize = 100
+)
+
+func (r *WebhookSubscriptionRouter) AppendRoutes(g fiber.Router) {
+	g.Post("/", r.Create)
+	g.Get("/", r.List)
+	g.Get("/:id", r.Find)
+	g.Patch("/:id", r.Patch)
+	g.Delete("/:id", r.Delete)
+	g.Get("/:id/deliveries", r.ListDeliveries)
+	g.Post("/:id/deliveries/:delivery_id/redeliver", r.Redeliver)
+}
+
+// Create godoc
+//
+//	@Summary		Create
+//	@Description	Create, the returned secret is used to verify the signature of deliveries and can't be retrieved afterwards
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_create
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body		dto.WebhookSubscriptionCreateOptions	true	"Body"
+//	@Success		201		{object}	dto.WebhookSubscription
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions [post]
+func (r *WebhookSubscriptionRouter) Create(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.WebhookSubscriptionCreateOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.webhookSubscriptionSvc.Create(*opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(res)
+}
+
+// List godoc
+//
+//	@Summary		List
+//	@Description	List
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_list
+//	@Produce		application/json
+//	@Param			workspace_id	query		string	true	"Workspace ID"
+//	@Success		200				{array}		dto.WebhookSubscription
+//	@Failure		403				{object}	errorpkg.ErrorResponse
+//	@Failure		404				{object}	errorpkg.ErrorResponse
+//	@Failure		500				{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions [get]
+func (r *WebhookSubscriptionRouter) List(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	workspaceID := c.Query("workspace_id")
+	if workspaceID == "" {
+		return errorpkg.NewMissingQueryParamError("workspace_id")
+	}
+	res, err := r.webhookSubscriptionSvc.List(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Find godoc
+//
+//	@Summary		Find
+//	@Description	Find
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_find
+//	@Produce		application/json
+//	@Param			id	path		string	true	"ID"
+//	@Success		200	{object}	dto.WebhookSubscription
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions/{id} [get]
+func (r *WebhookSubscriptionRouter) Find(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.webhookSubscriptionSvc.Find(c.Params("id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Patch godoc
+//
+//	@Summary		Patch
+//	@Description	Patch
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_patch
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			id		path		string									true	"ID"
+//	@Param			body	body		dto.WebhookSubscriptionPatchOptions	true	"Body"
+//	@Success		200		{object}	dto.WebhookSubscription
+//	@Failure		400		{object}	errorpkg.ErrorResponse
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions/{id} [patch]
+func (r *WebhookSubscriptionRouter) Patch(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	opts := new(dto.WebhookSubscriptionPatchOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	res, err := r.webhookSubscriptionSvc.Patch(c.Params("id"), *opts, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete
+//	@Description	Delete, along with its delivery log
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_delete
+//	@Produce		application/json
+//	@Param			id	path	string	true	"ID"
+//	@Success		204
+//	@Failure		403	{object}	errorpkg.ErrorResponse
+//	@Failure		404	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions/{id} [delete]
+func (r *WebhookSubscriptionRouter) Delete(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := r.webhookSubscriptionSvc.Delete(c.Params("id"), userID); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+//
+//	@Summary		List Deliveries
+//	@Description	List Deliveries, most recent first
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_list_deliveries
+//	@Produce		application/json
+//	@Param			id		path		string	true	"ID"
+//	@Param			page	query		string	false	"Page"
+//	@Param			size	query		string	false	"Size"
+//	@Success		200		{object}	dto.WebhookDeliveryList
+//	@Failure		403		{object}	errorpkg.ErrorResponse
+//	@Failure		404		{object}	errorpkg.ErrorResponse
+//	@Failure		500		{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions/{id}/deliveries [get]
+func (r *WebhookSubscriptionRouter) ListDeliveries(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	var page uint64
+	if c.Query("page") == "" {
+		page = 1
+	} else {
+		page, err = strconv.ParseUint(c.Query("page"), 10, 64)
+		if err != nil || page == 0 {
+			return errorpkg.NewInvalidQueryParamError("page")
+		}
+	}
+	var size uint64
+	if c.Query("size") == "" {
+		size = WebhookDeliveryDefaultPageSize
+	} else {
+		size, err = strconv.ParseUint(c.Query("size"), 10, 64)
+		if err != nil || size == 0 {
+			return errorpkg.NewInvalidQueryParamError("size")
+		}
+	}
+	res, err := r.webhookSubscriptionSvc.ListDeliveries(c.Params("id"), page, size, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+// Redeliver godoc
+//
+//	@Summary		Redeliver
+//	@Description	Redeliver, queues a new delivery with the same payload
+//	@Tags			WebhookSubscriptions
+//	@Id				webhook_subscriptions_redeliver
+//	@Produce		application/json
+//	@Param			id			path		string	true	"ID"
+//	@Param			delivery_id	path		string	true	"Delivery ID"
+//	@Success		201			{object}	dto.WebhookDelivery
+//	@Failure		403			{object}	errorpkg.ErrorResponse
+//	@Failure		404			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/webhook_subscriptions/{id}/deliveries/{delivery_id}/redeliver [post]
+func (r *WebhookSubscriptionRouter) Redeliver(c *fiber.Ctx) error {
+	userID, err := helper.GetUserID(c)
+	if err != nil {
+		return err
+	}
+	res, err := r.webhookSubscriptionSvc.Redeliver(c.Params("id"), c.Params("delivery_id"), userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(res)
+}
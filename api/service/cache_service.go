@@ -0,0 +1,46 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+)
+
+type CacheService struct{}
+
+func NewCacheService() *CacheService {
+	return &CacheService{}
+}
+
+func (svc *CacheService) GetMetrics(isAdmin bool) (*dto.CacheMetricsResult, error) {
+	if !isAdmin {
+		return nil, errorpkg.NewUserIsNotAdminError()
+	}
+	res := &dto.CacheMetricsResult{Data: make([]*dto.CacheMetrics, 0)}
+	for _, m := range cache.GetMetrics() {
+		item := &dto.CacheMetrics{
+			Kind:      m.Kind,
+			LocalHits: m.LocalHits,
+			Hits:      m.Hits,
+			Misses:    m.Misses,
+			Bypasses:  m.Bypasses,
+			Errors:    m.Errors,
+			Evictions: m.Evictions,
+		}
+		if lookups := m.LocalHits + m.Hits + m.Misses + m.Bypasses; lookups > 0 {
+			item.HitRatio = float64(m.LocalHits+m.Hits) / float64(lookups)
+		}
+		res.Data = append(res.Data, item)
+	}
+	return res, nil
+}
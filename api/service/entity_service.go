@@ -108,7 +108,7 @@ func (svc *EntityService) Create(fileID string, opts dto.EntityCreateOptions, us
 	if err := svc.snapshotSvc.saveAndSync(snapshot); err != nil {
 		return nil, err
 	}
-	if err := svc.runPipeline(snapshot, task); err != nil {
+	if err := svc.runPipeline(file, snapshot, task); err != nil {
 		return nil, err
 	}
 	res, err := svc.taskMapper.Map(task)
@@ -199,19 +199,21 @@ func (svc *EntityService) IsValidSortOrder(value string) bool {
 	return value == "" || value == dto.EntitySortOrderAsc || value == dto.EntitySortOrderDesc
 }
 
-func (svc *EntityService) runPipeline(snapshot model.Snapshot, task model.Task) error {
+func (svc *EntityService) runPipeline(file model.File, snapshot model.Snapshot, task model.Task) error {
 	key := snapshot.GetOriginal().Key
 	if svc.fileIdent.IsOffice(key) || svc.fileIdent.IsPlainText(key) {
 		key = snapshot.GetPreview().Key
 	}
 	if err := svc.pipelineClient.Run(&dto.PipelineRunOptions{
-		PipelineID: helper.ToPtr(dto.PipelineEntity),
-		TaskID:     helper.ToPtr(task.GetID()),
-		SnapshotID: snapshot.GetID(),
-		Bucket:     snapshot.GetPreview().Bucket,
-		Key:        key,
-		Intent:     snapshot.GetIntent(),
-		Language:   snapshot.GetLanguage(),
+		PipelineID:  helper.ToPtr(dto.PipelineEntity),
+		TaskID:      helper.ToPtr(task.GetID()),
+		SnapshotID:  snapshot.GetID(),
+		WorkspaceID: helper.ToPtr(file.GetWorkspaceID()),
+		Priority:    helper.ToPtr(dto.PipelinePriorityInteractive),
+		Bucket:      snapshot.GetPreview().Bucket,
+		Key:         key,
+		Intent:      snapshot.GetIntent(),
+		Language:    snapshot.GetLanguage(),
 	}); err != nil {
 		return err
 	}
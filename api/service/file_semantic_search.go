@@ -0,0 +1,329 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"errors"
+	"slices"
+	"unicode"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/mapper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+	"github.com/kouprlabs/voltaserve/shared/search"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+)
+
+const (
+	// FileSemanticSearchChunkSize is the maximum number of characters of a chunk,
+	// consecutive chunks overlap so that a passage cut in two still matches.
+	FileSemanticSearchChunkSize    = 1000
+	FileSemanticSearchChunkOverlap = 200
+	FileSemanticSearchEmbedBatch   = 64
+	// FileSemanticSearchCandidates is the number of chunks fetched from the
+	// search engine per requested hit, since some of them belong to files the
+	// user cannot access, or that are in the trash.
+	FileSemanticSearchCandidates    = 4
+	FileSemanticSearchMaxCandidates = 1000
+)
+
+type FileSemanticSearchOptions struct {
+	Size  uint64
+	Query dto.FileSemanticSearchQuery
+}
+
+type fileSemanticSearch struct {
+	snapshotChunkRepo   *repo.SnapshotChunkRepo
+	snapshotChunkSearch *search.SnapshotChunkSearch
+	snapshotRepo        *repo.SnapshotRepo
+	workspaceRepo       *repo.WorkspaceRepo
+	fileCache           *cache.FileCache
+	fileGuard           *guard.FileGuard
+	fileMapper          *mapper.FileMapper
+	s3                  infra.S3Manager
+	embedder            infra.Embedder
+	config              *config.Config
+}
+
+func newFileSemanticSearch() *fileSemanticSearch {
+	return &fileSemanticSearch{
+		snapshotChunkRepo: repo.NewSnapshotChunkRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		snapshotChunkSearch: search.NewSnapshotChunkSearch(
+			config.GetConfig().Search,
+			config.GetConfig().Embedding,
+			config.GetConfig().Environment,
+		),
+		snapshotRepo: repo.NewSnapshotRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		workspaceRepo: repo.NewWorkspaceRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileMapper: mapper.NewFileMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		s3:       infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		embedder: infra.NewEmbedder(config.GetConfig().Embedding, config.GetConfig().Environment),
+		config:   config.GetConfig(),
+	}
+}
+
+// index chunks and embeds the text of the snapshot, replacing the chunks of a
+// previous text in Postgres and in the search engine. The chunks are removed
+// when the snapshot has no text anymore.
+func (svc *fileSemanticSearch) index(snapshot model.Snapshot) error {
+	if !svc.config.Embedding.Enabled {
+		return nil
+	}
+	previous, err := svc.snapshotChunkRepo.FindIDsForSnapshot(snapshot.GetID())
+	if err != nil {
+		return err
+	}
+	opts := make([]repo.SnapshotChunkInsertOptions, 0)
+	if snapshot.HasText() {
+		text, err := svc.s3.GetText(snapshot.GetText().Key, snapshot.GetText().Bucket, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		chunks := svc.chunk(text)
+		for batch := range slices.Chunk(chunks, FileSemanticSearchEmbedBatch) {
+			texts := make([]string, 0, len(batch))
+			for _, c := range batch {
+				texts = append(texts, c.Text)
+			}
+			vectors, err := svc.embedder.Embed(texts)
+			if err != nil {
+				return err
+			}
+			for i, c := range batch {
+				c.Embedding = vectors[i]
+			}
+		}
+		for _, c := range chunks {
+			opts = append(opts, *c)
+		}
+	}
+	if err := svc.snapshotChunkRepo.ReplaceForSnapshot(snapshot.GetID(), svc.embedder.Model(), opts); err != nil {
+		return err
+	}
+	chunks, err := svc.snapshotChunkRepo.FindAllForSnapshot(snapshot.GetID())
+	if err != nil {
+		return err
+	}
+	if err := svc.snapshotChunkSearch.Delete(previous); err != nil {
+		return err
+	}
+	if err := svc.snapshotChunkSearch.Index(chunks); err != nil {
+		return err
+	}
+	return nil
+}
+
+// backfill indexes the snapshots that have a text but no chunks embedded with
+// the current model, like the ones processed before the semantic search was
+// enabled, or before the model was changed.
+func (svc *fileSemanticSearch) backfill() error {
+	if !svc.config.Embedding.Enabled {
+		return nil
+	}
+	var after string
+	for {
+		ids, err := svc.snapshotChunkRepo.FindSnapshotIDsToEmbedAfter(after, svc.embedder.Model(), SearchIndexBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		for _, id := range ids {
+			// The snapshots deleted since their IDs were read are skipped
+			if snapshot := svc.snapshotRepo.FindOrNil(id); snapshot != nil {
+				if err := svc.index(snapshot); err != nil {
+					return err
+				}
+			}
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// search asks the search engine for the chunks closest to the query, among the
+// workspaces the user can access. The candidates are then checked against the
+// permissions and the trash in Postgres, and against the guard like the
+// keyword search does.
+func (svc *fileSemanticSearch) search(opts FileSemanticSearchOptions, userID string) (*dto.FileSemanticSearchResult, error) {
+	if !svc.config.Embedding.Enabled {
+		return nil, errorpkg.NewSemanticSearchDisabledError()
+	}
+	res := &dto.FileSemanticSearchResult{
+		Data:  make([]*dto.FileSemanticSearchHit, 0),
+		Query: &opts.Query,
+	}
+	vectors, err := svc.embedder.Embed([]string{*opts.Query.Text})
+	if err != nil {
+		return nil, err
+	}
+	var workspaceIDs []string
+	if opts.Query.WorkspaceID != nil {
+		workspaceIDs = []string{*opts.Query.WorkspaceID}
+	} else {
+		workspaceIDs, err = svc.workspaceRepo.FindIDsForUser(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hits, err := svc.snapshotChunkSearch.Query(vectors[0], search.SnapshotChunkQueryOptions{
+		Model:        svc.embedder.Model(),
+		WorkspaceIDs: workspaceIDs,
+		Limit:        int64(min(opts.Size*FileSemanticSearchCandidates, FileSemanticSearchMaxCandidates)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	candidates, err := svc.snapshotChunkRepo.FindCandidates(ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	candidateByID := make(map[string]repo.SnapshotChunkCandidate, len(candidates))
+	for _, c := range candidates {
+		candidateByID[c.Chunk.GetID()] = c
+	}
+	files := make(map[string]*dto.File)
+	for _, hit := range hits {
+		if uint64(len(res.Data)) >= opts.Size {
+			break
+		}
+		candidate, ok := candidateByID[hit.ID]
+		if !ok {
+			continue
+		}
+		mapped, ok := files[candidate.FileID]
+		if !ok {
+			mapped, err = svc.mapFile(candidate.FileID, userID)
+			if err != nil {
+				return nil, err
+			}
+			files[candidate.FileID] = mapped
+		}
+		if mapped == nil {
+			continue
+		}
+		res.Data = append(res.Data, &dto.FileSemanticSearchHit{
+			File:        mapped,
+			SnapshotID:  candidate.Chunk.GetSnapshotID(),
+			Text:        candidate.Chunk.GetText(),
+			StartOffset: candidate.Chunk.GetStartOffset(),
+			EndOffset:   candidate.Chunk.GetEndOffset(),
+			Score:       hit.Score,
+		})
+	}
+	return res, nil
+}
+
+// mapFile returns nil when the file is gone or the user is not allowed to see
+// it, so that the caller can skip its chunks.
+func (svc *fileSemanticSearch) mapFile(id string, userID string) (*dto.File, error) {
+	file, err := svc.fileCache.Get(id)
+	if err != nil {
+		var e *errorpkg.ErrorResponse
+		if errors.As(err, &e) && e.Code == errorpkg.NewFileNotFoundError(nil).Code {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+	if !svc.fileGuard.IsAuthorized(userID, file, model.PermissionViewer) {
+		return nil, nil
+	}
+	return svc.fileMapper.Map(file, userID)
+}
+
+// chunk splits the text in overlapping chunks, ending them at whitespace when
+// possible so that words are not cut in half.
+func (svc *fileSemanticSearch) chunk(text string) []*repo.SnapshotChunkInsertOptions {
+	runes := []rune(text)
+	res := make([]*repo.SnapshotChunkInsertOptions, 0)
+	start := 0
+	for start < len(runes) {
+		for start < len(runes) && unicode.IsSpace(runes[start]) {
+			start++
+		}
+		if start == len(runes) {
+			break
+		}
+		end := min(start+FileSemanticSearchChunkSize, len(runes))
+		if end < len(runes) {
+			for i := end; i > start+FileSemanticSearchChunkSize/2; i-- {
+				if unicode.IsSpace(runes[i-1]) {
+					end = i
+					break
+				}
+			}
+		}
+		trimmed := end
+		for trimmed > start && unicode.IsSpace(runes[trimmed-1]) {
+			trimmed--
+		}
+		res = append(res, &repo.SnapshotChunkInsertOptions{
+			Ordinal:     len(res),
+			StartOffset: start,
+			EndOffset:   trimmed,
+			Text:        string(runes[start:trimmed]),
+		})
+		if end == len(runes) {
+			break
+		}
+		// Start the next chunk at a word boundary, unless the text has no
+		// whitespace to break at, like Chinese or Japanese
+		next := end - FileSemanticSearchChunkOverlap
+		aligned := next
+		for aligned < end && !unicode.IsSpace(runes[aligned-1]) {
+			aligned++
+		}
+		if aligned < end {
+			next = aligned
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return res
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type FileSemanticSearchSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestFileSemanticSearchSuite(t *testing.T) {
+	suite.Run(t, new(FileSemanticSearchSuite))
+}
+
+func (s *FileSemanticSearchSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *FileSemanticSearchSuite) TestSemanticSearch() {
+	invoices := s.createFileWithText("Reminder: the invoices for March are overdue, please settle the payment.")
+	hiking := s.createFileWithText("The mountain trails are closed during winter because of avalanches.")
+
+	var res *dto.FileSemanticSearchResult
+	s.Eventually(func() bool {
+		var err error
+		res, err = service.NewFileService().SemanticSearch(s.options("overdue invoice payments"), s.users[0].GetID())
+		s.Require().NoError(err)
+		return len(res.Data) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	s.Equal(invoices.ID, res.Data[0].File.ID)
+	for _, hit := range res.Data[1:] {
+		if hit.File.ID == hiking.ID {
+			s.Less(hit.Score, res.Data[0].Score)
+		}
+	}
+	s.Equal(invoices.Snapshot.ID, res.Data[0].SnapshotID)
+}
+
+func (s *FileSemanticSearchSuite) TestSemanticSearch_Permissions() {
+	file := s.createFileWithText("Quarterly revenue forecast for the sales department.")
+
+	s.Eventually(func() bool {
+		res, err := service.NewFileService().SemanticSearch(s.options("revenue forecast"), s.users[0].GetID())
+		s.Require().NoError(err)
+		return len(res.Data) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	res, err := service.NewFileService().SemanticSearch(s.options("revenue forecast"), s.users[1].GetID())
+	s.Require().NoError(err)
+	s.Empty(res.Data)
+
+	err = service.NewFileService().GrantUserPermission([]string{file.ID}, s.users[1].GetID(), model.PermissionViewer, s.users[0].GetID())
+	s.Require().NoError(err)
+	res, err = service.NewFileService().SemanticSearch(s.options("revenue forecast"), s.users[1].GetID())
+	s.Require().NoError(err)
+	s.Require().NotEmpty(res.Data)
+	s.Equal(file.ID, res.Data[0].File.ID)
+}
+
+func (s *FileSemanticSearchSuite) TestSemanticSearch_Offsets() {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 3*service.FileSemanticSearchChunkSize; i++ {
+		sb.WriteString("Die Überweisung für die Lieferung ist eingegangen. ")
+	}
+	text := sb.String()
+	file := s.createFileWithText(text)
+
+	var chunks []model.SnapshotChunk
+	s.Eventually(func() bool {
+		var err error
+		chunks, err = repo.NewSnapshotChunkRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		).FindAllForSnapshot(file.Snapshot.ID)
+		s.Require().NoError(err)
+		return len(chunks) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	s.Greater(len(chunks), 3)
+	runes := []rune(text)
+	for i, chunk := range chunks {
+		s.Equal(i, chunk.GetOrdinal())
+		s.Equal(string(runes[chunk.GetStartOffset():chunk.GetEndOffset()]), chunk.GetText())
+		s.LessOrEqual(chunk.GetEndOffset()-chunk.GetStartOffset(), service.FileSemanticSearchChunkSize)
+		if i > 0 {
+			// Consecutive chunks overlap
+			s.Less(chunk.GetStartOffset(), chunks[i-1].GetEndOffset())
+		}
+	}
+	s.Equal(0, chunks[0].GetStartOffset())
+	s.Equal(len([]rune(strings.TrimSpace(text))), chunks[len(chunks)-1].GetEndOffset())
+}
+
+func (s *FileSemanticSearchSuite) TestSemanticSearch_Backfill() {
+	file := s.createFileWithUnembeddedText("The warranty of the dishwasher expires next spring.")
+
+	res, err := service.NewFileService().SemanticSearch(s.options("dishwasher warranty"), s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(res.Data)
+
+	_, err = service.NewSearchIndexService().Reindex(dto.SearchIndexReindexOptions{
+		Indexes: []string{dto.SearchIndexSnapshotChunk},
+	}, s.users[0].GetID(), true)
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		res, err = service.NewFileService().SemanticSearch(s.options("dishwasher warranty"), s.users[0].GetID())
+		s.Require().NoError(err)
+		return len(res.Data) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	s.Equal(file.ID, res.Data[0].File.ID)
+}
+
+func (s *FileSemanticSearchSuite) options(text string) service.FileSemanticSearchOptions {
+	return service.FileSemanticSearchOptions{
+		Size:  10,
+		Query: dto.FileSemanticSearchQuery{Text: &text},
+	}
+}
+
+// createFileWithText creates a file in a workspace of its own, then patches and
+// embeds the text of its active snapshot the way the conversion pipeline does.
+func (s *FileSemanticSearchSuite) createFileWithText(text string) *dto.File {
+	file := s.createFileWithUnembeddedText(text)
+	s.Require().NoError(service.NewSnapshotService().Embed(file.Snapshot.ID))
+	return file
+}
+
+// createFileWithUnembeddedText is like createFileWithText, but leaves the text
+// without chunks, like the texts extracted before the semantic search was enabled.
+func (s *FileSemanticSearchSuite) createFileWithUnembeddedText(text string) *dto.File {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	id := helper.NewID()
+	snapshot := repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
+		ID:         id,
+		Version:    1,
+		Original:   &model.S3Object{Bucket: "bucket", Key: id + "/original.txt"},
+		CreateTime: helper.NewTimeString(),
+	})
+	snapshotRepo := repo.NewSnapshotRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	s.Require().NoError(snapshotRepo.Insert(snapshot))
+	s.Require().NoError(snapshotRepo.MapWithFile(id, file.ID))
+	s.Require().NoError(cache.NewSnapshotCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	).Set(snapshot))
+	file, err = service.NewSnapshotService().Activate(id, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	key := id + "/text.txt"
+	err = infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment).
+		PutText(key, text, "text/plain", "bucket", minio.PutObjectOptions{})
+	s.Require().NoError(err)
+	_, err = service.NewSnapshotService().Patch(id, dto.SnapshotPatchOptions{
+		Fields: []string{model.SnapshotFieldText},
+		Text:   &model.S3Object{Bucket: "bucket", Key: key, Size: int64(len(text))},
+	})
+	s.Require().NoError(err)
+	return file
+}
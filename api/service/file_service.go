@@ -12,11 +12,16 @@ package service
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/gosimple/slug"
@@ -40,36 +45,42 @@ import (
 )
 
 type FileService struct {
-	fileCreate      *fileCreate
-	fileStore       *fileStore
-	fileDelete      *fileDelete
-	fileMove        *fileMove
-	fileCopy        *fileCopy
-	fileDownload    *fileDownload
-	fileFetch       *fileFetch
-	fileList        *fileList
-	fileSortService *fileSortService
-	fileReprocess   *fileReprocess
-	filePermission  *filePermission
-	fileCompute     *fileCompute
-	filePatch       *filePatch
+	fileCreate         *fileCreate
+	fileStore          *fileStore
+	fileDelete         *fileDelete
+	fileMove           *fileMove
+	fileCopy           *fileCopy
+	fileDownload       *fileDownload
+	fileFetch          *fileFetch
+	fileList           *fileList
+	fileSearchService  *fileSearchService
+	fileSemanticSearch *fileSemanticSearch
+	fileSortService    *fileSortService
+	fileReprocess      *fileReprocess
+	filePermission     *filePermission
+	fileCompute        *fileCompute
+	filePatch          *filePatch
+	fileProperty       *fileProperty
 }
 
 func NewFileService() *FileService {
 	return &FileService{
-		fileCreate:      newFileCreate(),
-		fileStore:       newFileStore(),
-		fileDelete:      newFileDelete(),
-		fileMove:        newFileMove(),
-		fileCopy:        newFileCopy(),
-		fileDownload:    newFileDownload(),
-		fileFetch:       newFileFetch(),
-		fileList:        newFileList(),
-		fileSortService: newFileSortService(),
-		fileReprocess:   newFileReprocess(),
-		filePermission:  newFilePermission(),
-		fileCompute:     newFileCompute(),
-		filePatch:       newFilePatch(),
+		fileCreate:         newFileCreate(),
+		fileStore:          newFileStore(),
+		fileDelete:         newFileDelete(),
+		fileMove:           newFileMove(),
+		fileCopy:           newFileCopy(),
+		fileDownload:       newFileDownload(),
+		fileFetch:          newFileFetch(),
+		fileList:           newFileList(),
+		fileSearchService:  newFileSearchService(),
+		fileSemanticSearch: newFileSemanticSearch(),
+		fileSortService:    newFileSortService(),
+		fileReprocess:      newFileReprocess(),
+		filePermission:     newFilePermission(),
+		fileCompute:        newFileCompute(),
+		filePatch:          newFilePatch(),
+		fileProperty:       newFileProperty(),
 	}
 }
 
@@ -107,6 +118,9 @@ type FileListOptions struct {
 	SortBy    string
 	SortOrder string
 	Query     *dto.FileQuery
+	// Cursor is the next or previous cursor of a former list response, when set
+	// it takes precedence over Page.
+	Cursor string
 }
 
 func (svc *FileService) Probe(id string, opts FileListOptions, userID string) (*dto.FileProbe, error) {
@@ -117,6 +131,26 @@ func (svc *FileService) List(id string, opts FileListOptions, userID string) (*d
 	return svc.fileList.list(id, opts, userID)
 }
 
+type FileSearchOptions struct {
+	Page      uint64
+	Size      uint64
+	SortBy    string
+	SortOrder string
+	Query     dto.FileSearchQuery
+}
+
+func (svc *FileService) Search(opts FileSearchOptions, userID string) (*dto.FileSearchResult, error) {
+	return svc.fileSearchService.search(opts, userID)
+}
+
+func (svc *FileService) SemanticSearch(opts FileSemanticSearchOptions, userID string) (*dto.FileSemanticSearchResult, error) {
+	return svc.fileSemanticSearch.search(opts, userID)
+}
+
+func (svc *FileService) IsValidSearchSortBy(value string) bool {
+	return svc.fileSearchService.isValidSortBy(value)
+}
+
 func (svc *FileService) IsValidSortBy(value string) bool {
 	return svc.fileSortService.isValidSortBy(value)
 }
@@ -157,6 +191,10 @@ func (svc *FileService) DownloadPreviewBuffer(id string, rangeHeader string, buf
 	return svc.fileDownload.downloadPreviewBuffer(id, rangeHeader, buf, userID)
 }
 
+func (svc *FileService) DownloadHLSBuffer(id string, rendition string, name string, rangeHeader string, buf *bytes.Buffer, userID string) (*DownloadResult, error) {
+	return svc.fileDownload.downloadHLSBuffer(id, rendition, name, rangeHeader, buf, userID)
+}
+
 func (svc *FileService) DownloadTextBuffer(id string, buf *bytes.Buffer, userID string) (*DownloadResult, error) {
 	return svc.fileDownload.downloadTextBuffer(id, buf, userID)
 }
@@ -181,6 +219,18 @@ func (svc *FileService) PatchName(id string, name string, userID string) (*dto.F
 	return svc.filePatch.patchName(id, name, userID)
 }
 
+func (svc *FileService) ListProperties(id string, userID string) ([]*dto.FileProperty, error) {
+	return svc.fileProperty.list(id, userID)
+}
+
+func (svc *FileService) ListPropertiesMany(opts dto.FileListPropertiesManyOptions, userID string) (map[string][]*dto.FileProperty, error) {
+	return svc.fileProperty.listMany(opts, userID)
+}
+
+func (svc *FileService) PatchProperties(id string, opts dto.FilePatchPropertiesOptions, userID string) (*dto.FilePatchPropertiesResult, error) {
+	return svc.fileProperty.patch(id, opts, userID)
+}
+
 func (svc *FileService) GrantUserPermission(ids []string, assigneeID string, permission string, userID string) error {
 	return svc.filePermission.grantUserPermissions(ids, assigneeID, permission, userID)
 }
@@ -214,14 +264,15 @@ func (svc *FileService) Store(id string, opts FileStoreOptions, userID string) (
 }
 
 type fileCreate struct {
-	fileRepo       *repo.FileRepo
-	fileSearch     *search.FileSearch
-	fileCache      *cache.FileCache
-	fileGuard      *guard.FileGuard
-	fileMapper     *mapper.FileMapper
-	fileCoreSvc    *fileCoreService
-	workspaceCache *cache.WorkspaceCache
-	workspaceGuard *guard.WorkspaceGuard
+	fileRepo         *repo.FileRepo
+	fileSearch       *search.FileSearch
+	fileCache        *cache.FileCache
+	fileGuard        *guard.FileGuard
+	fileMapper       *mapper.FileMapper
+	fileCoreSvc      *fileCoreService
+	workspaceCache   *cache.WorkspaceCache
+	workspaceGuard   *guard.WorkspaceGuard
+	webhookPublisher *webhookPublisher
 }
 
 func newFileCreate() *fileCreate {
@@ -262,6 +313,7 @@ func newFileCreate() *fileCreate {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -367,6 +419,7 @@ func (svc *fileCreate) performCreate(opts FileCreateOptions, failOnDuplicateName
 	if err = svc.fileSearch.Index([]model.File{file}); err != nil {
 		return nil, err
 	}
+	svc.webhookPublisher.publishFile(model.WebhookEventFileCreated, file, userID)
 	res, err := svc.fileMapper.Map(file, userID)
 	if err != nil {
 		return nil, err
@@ -385,6 +438,9 @@ func (svc *fileCreate) validateParent(id string, userID string) error {
 	if file.GetType() != model.FileTypeFolder {
 		return errorpkg.NewFileIsNotAFolderError(file)
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -464,6 +520,13 @@ func (svc *fileFetch) find(ids []string, userID string) ([]*dto.File, error) {
 		if err = svc.fileGuard.Authorize(userID, file, model.PermissionViewer); err != nil {
 			return nil, err
 		}
+		inTrash, err := svc.fileCoreSvc.isInTrash(file)
+		if err != nil {
+			return nil, err
+		}
+		if inTrash {
+			continue
+		}
 		mapped, err := svc.fileMapper.Map(file, userID)
 		if err != nil {
 			return nil, err
@@ -729,6 +792,7 @@ func (svc *fileFetch) validatePathForFileType(path string, fileType string) erro
 type fileList struct {
 	fileCache      *cache.FileCache
 	fileRepo       *repo.FileRepo
+	groupRepo      *repo.GroupRepo
 	fileSearch     *search.FileSearch
 	fileGuard      *guard.FileGuard
 	fileCoreSvc    *fileCoreService
@@ -737,6 +801,7 @@ type fileList struct {
 	fileMapper     *mapper.FileMapper
 	workspaceRepo  *repo.WorkspaceRepo
 	workspaceGuard *guard.WorkspaceGuard
+	fileIdent      *infra.FileIdentifier
 }
 
 func newFileList() *fileList {
@@ -750,6 +815,10 @@ func newFileList() *fileList {
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
 		),
+		groupRepo: repo.NewGroupRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
 		fileSearch: search.NewFileSearch(
 			config.GetConfig().Postgres,
 			config.GetConfig().Search,
@@ -778,6 +847,7 @@ func newFileList() *fileList {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		fileIdent: infra.NewFileIdentifier(),
 	}
 }
 
@@ -792,15 +862,10 @@ func (svc *fileList) probe(id string, opts FileListOptions, userID string) (*dto
 	if file.GetType() != model.FileTypeFolder {
 		return nil, errorpkg.NewFileIsNotAFolderError(file)
 	}
-	data, err := svc.getChildren(id)
+	totalElements, err := svc.fileRepo.CountListedChildren(id, repo.FileListChildrenOptions{UserID: userID})
 	if err != nil {
 		return nil, err
 	}
-	authorized, err := svc.fileCoreSvc.authorize(userID, data, model.PermissionViewer)
-	if err != nil {
-		return nil, err
-	}
-	totalElements := uint64(len(authorized))
 	return &dto.FileProbe{
 		TotalElements: totalElements,
 		TotalPages:    (totalElements + opts.Size - 1) / opts.Size,
@@ -818,6 +883,9 @@ func (svc *fileList) list(id string, opts FileListOptions, userID string) (*dto.
 	if file.GetType() != model.FileTypeFolder {
 		return nil, errorpkg.NewFileIsNotAFolderError(file)
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
 	workspace, err := svc.workspaceRepo.Find(file.GetWorkspaceID())
 	if err != nil {
 		return nil, err
@@ -825,31 +893,218 @@ func (svc *fileList) list(id string, opts FileListOptions, userID string) (*dto.
 	if err := svc.workspaceGuard.Authorize(userID, workspace, model.PermissionViewer); err != nil {
 		return nil, err
 	}
-	var data []model.File
 	if opts.Query != nil && opts.Query.Text != nil {
-		data, err = svc.search(opts.Query, workspace)
+		// The hits come ranked by the search engine, so they can't be paged with a cursor
+		if opts.Cursor != "" {
+			return nil, errorpkg.NewInvalidQueryParamError("cursor")
+		}
+		data, err := svc.search(opts.Query, workspace, userID)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		data, err = svc.getChildren(id)
+		return svc.createList(data, file, opts, userID)
+	}
+	return svc.listChildren(file, opts, userID)
+}
+
+// listChildren pages the children in the database, which checks the
+// permissions, filters and sorts them, then only the files of the page are
+// read from the cache.
+func (svc *fileList) listChildren(parent model.File, opts FileListOptions, userID string) (*dto.FileList, error) {
+	if opts.SortBy == "" {
+		opts.SortBy = dto.FileSortByDateCreated
+	}
+	if opts.SortOrder == "" {
+		opts.SortOrder = dto.FileSortOrderAsc
+	}
+	listOpts := repo.FileListChildrenOptions{
+		UserID:     userID,
+		OrderBy:    svc.orderBy(opts.SortBy),
+		Descending: opts.SortOrder == dto.FileSortOrderDesc,
+		// One more than a page tells whether there is a page after it
+		Limit: opts.Size + 1,
+	}
+	if opts.Query != nil {
+		listOpts.Type = opts.Query.Type
+		listOpts.CreateTimeAfter = opts.Query.CreateTimeAfter
+		listOpts.CreateTimeBefore = opts.Query.CreateTimeBefore
+		listOpts.UpdateTimeAfter = opts.Query.UpdateTimeAfter
+		listOpts.UpdateTimeBefore = opts.Query.UpdateTimeBefore
+	}
+	if opts.SortBy == dto.FileSortByKind {
+		kindRanks, err := svc.kindRanks(parent.GetID())
 		if err != nil {
 			return nil, err
 		}
+		listOpts.KindRanks = kindRanks
 	}
-	return svc.createList(data, file, opts, userID)
+	if opts.Cursor != "" {
+		cursor, err := svc.decodeCursor(opts.Cursor, opts.SortBy, opts.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Backward {
+			listOpts.Before = &cursor.Keyset
+		} else {
+			listOpts.After = &cursor.Keyset
+		}
+	} else if opts.Page > 1 {
+		listOpts.Offset = (opts.Page - 1) * opts.Size
+	}
+	totalElements, err := svc.fileRepo.CountListedChildren(parent.GetID(), listOpts)
+	if err != nil {
+		return nil, err
+	}
+	keysets, err := svc.fileRepo.ListChildren(parent.GetID(), listOpts)
+	if err != nil {
+		return nil, err
+	}
+	hasMore := uint64(len(keysets)) > opts.Size
+	if hasMore {
+		if listOpts.Before != nil {
+			keysets = keysets[1:]
+		} else {
+			keysets = keysets[:opts.Size]
+		}
+	}
+	ids := make([]string, 0, len(keysets))
+	for _, k := range keysets {
+		ids = append(ids, k.ID)
+	}
+	files, err := svc.fileCache.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := svc.fileMapper.MapMany(files, parent.GetWorkspaceID(), userID)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.FileList{
+		Data:          mapped,
+		TotalElements: totalElements,
+		TotalPages:    (totalElements + opts.Size - 1) / opts.Size,
+		Page:          opts.Page,
+		Size:          uint64(len(mapped)),
+		Query:         opts.Query,
+	}
+	if len(keysets) > 0 {
+		if (hasMore && listOpts.Before == nil) || listOpts.Before != nil {
+			next, err := svc.encodeCursor(fileListCursor{
+				SortBy:    opts.SortBy,
+				SortOrder: opts.SortOrder,
+				Keyset:    keysets[len(keysets)-1],
+			})
+			if err != nil {
+				return nil, err
+			}
+			res.NextCursor = &next
+		}
+		if (hasMore && listOpts.Before != nil) || listOpts.After != nil || listOpts.Offset > 0 {
+			previous, err := svc.encodeCursor(fileListCursor{
+				SortBy:    opts.SortBy,
+				SortOrder: opts.SortOrder,
+				Backward:  true,
+				Keyset:    keysets[0],
+			})
+			if err != nil {
+				return nil, err
+			}
+			res.PreviousCursor = &previous
+		}
+	}
+	return res, nil
 }
 
-func (svc *fileList) search(query *dto.FileQuery, workspace model.Workspace) ([]model.File, error) {
+func (svc *fileList) orderBy(sortBy string) string {
+	switch sortBy {
+	case dto.FileSortByName:
+		return repo.FileOrderByName
+	case dto.FileSortByKind:
+		return repo.FileOrderByKind
+	case dto.FileSortBySize:
+		return repo.FileOrderBySize
+	case dto.FileSortByDateModified:
+		return repo.FileOrderByDateModified
+	default:
+		return repo.FileOrderByDateCreated
+	}
+}
+
+// kindRanks ranks the extensions found among the children in the same order
+// as fileSortService.sortByKind: images, PDFs, documents, videos, texts, and
+// then everything else.
+func (svc *fileList) kindRanks(id string) (map[string]int, error) {
+	extensions, err := svc.fileRepo.FindChildrenExtensions(id)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]int, len(extensions))
+	for _, ext := range extensions {
+		if svc.fileIdent.IsImage(ext) {
+			res[ext] = 1
+		} else if svc.fileIdent.IsPDF(ext) {
+			res[ext] = 2
+		} else if svc.fileIdent.IsOffice(ext) {
+			res[ext] = 3
+		} else if svc.fileIdent.IsVideo(ext) {
+			res[ext] = 4
+		} else if svc.fileIdent.IsPlainText(ext) {
+			res[ext] = 5
+		} else {
+			res[ext] = 6
+		}
+	}
+	return res, nil
+}
+
+// fileListCursor is handed out opaque in the list response, it is only valid
+// for the sorting it was created with.
+type fileListCursor struct {
+	SortBy    string          `json:"sortBy"`
+	SortOrder string          `json:"sortOrder"`
+	Backward  bool            `json:"backward,omitempty"`
+	Keyset    repo.FileKeyset `json:"keyset"`
+}
+
+func (svc *fileList) encodeCursor(cursor fileListCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (svc *fileList) decodeCursor(value string, sortBy string, sortOrder string) (*fileListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("cursor")
+	}
+	var res fileListCursor
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, errorpkg.NewInvalidQueryParamError("cursor")
+	}
+	if res.SortBy != sortBy || res.SortOrder != sortOrder || res.Keyset.ID == "" {
+		return nil, errorpkg.NewInvalidQueryParamError("cursor")
+	}
+	return &res, nil
+}
+
+func (svc *fileList) search(query *dto.FileQuery, workspace model.Workspace, userID string) ([]model.File, error) {
 	var res []model.File
 	count, err := svc.fileRepo.Count()
 	if err != nil {
 		return nil, err
 	}
+	groupIDs, err := svc.groupRepo.FindIDsByMember(userID)
+	if err != nil {
+		return nil, err
+	}
 	filter := fmt.Sprintf("workspaceId=\"%s\"", workspace.GetID())
+	filter += " AND " + search.FilePrincipalFilter(userID, groupIDs)
 	if query.Type != nil {
 		filter += fmt.Sprintf(" AND type=\"%s\"", *query.Type)
 	}
+	filter += svc.metadataFilter(query)
 	hits, err := svc.fileSearch.Query(*query.Text, infra.SearchQueryOptions{
 		Limit:  count,
 		Filter: filter,
@@ -874,21 +1129,42 @@ func (svc *fileList) search(query *dto.FileQuery, workspace model.Workspace) ([]
 	return res, nil
 }
 
-func (svc *fileList) getChildren(id string) ([]model.File, error) {
-	var res []model.File
-	ids, err := svc.fileRepo.FindChildrenIDs(id)
-	if err != nil {
-		return nil, err
+// metadataFilter narrows the search with the snapshot metadata, which is only
+// known to the search engine, so unlike the other fields of the query it can't
+// be filtered after the search.
+func (svc *fileList) metadataFilter(query *dto.FileQuery) string {
+	var res string
+	if query.DurationMin != nil {
+		res += fmt.Sprintf(" AND duration>=%s", strconv.FormatFloat(*query.DurationMin, 'f', -1, 64))
 	}
-	for _, id := range ids {
-		var file model.File
-		file, err := svc.fileCache.Get(id)
-		if err != nil {
-			return nil, err
-		}
-		res = append(res, file)
+	if query.DurationMax != nil {
+		res += fmt.Sprintf(" AND duration<=%s", strconv.FormatFloat(*query.DurationMax, 'f', -1, 64))
 	}
-	return res, nil
+	if query.VideoCodec != nil {
+		res += fmt.Sprintf(" AND videoCodec=%s", strconv.Quote(*query.VideoCodec))
+	}
+	if query.AudioCodec != nil {
+		res += fmt.Sprintf(" AND audioCodec=%s", strconv.Quote(*query.AudioCodec))
+	}
+	if query.CameraMake != nil {
+		res += fmt.Sprintf(" AND cameraMake=%s", strconv.Quote(*query.CameraMake))
+	}
+	if query.CameraModel != nil {
+		res += fmt.Sprintf(" AND cameraModel=%s", strconv.Quote(*query.CameraModel))
+	}
+	if query.CaptureTimeAfter != nil {
+		res += fmt.Sprintf(" AND captureTime>=%d", *query.CaptureTimeAfter)
+	}
+	if query.CaptureTimeBefore != nil {
+		res += fmt.Sprintf(" AND captureTime<=%d", *query.CaptureTimeBefore)
+	}
+	if query.HasLocation != nil {
+		res += fmt.Sprintf(" AND hasLocation=%t", *query.HasLocation)
+	}
+	if query.Author != nil {
+		res += fmt.Sprintf(" AND documentAuthor=%s", strconv.Quote(*query.Author))
+	}
+	return res
 }
 
 func (svc *fileList) createList(data []model.File, parent model.File, opts FileListOptions, userID string) (*dto.FileList, error) {
@@ -940,7 +1216,221 @@ func (svc *fileList) paginate(data []model.File, page, size uint64) (pageData []
 	if endIndex > totalElements {
 		endIndex = totalElements
 	}
-	return data[startIndex:endIndex], totalElements, totalPages
+	return data[startIndex:endIndex], totalElements, totalPages
+}
+
+type fileSearchService struct {
+	fileCache      *cache.FileCache
+	fileSearch     *search.FileSearch
+	fileGuard      *guard.FileGuard
+	fileMapper     *mapper.FileMapper
+	groupRepo      *repo.GroupRepo
+	workspaceRepo  *repo.WorkspaceRepo
+	workspaceCache *cache.WorkspaceCache
+	workspaceGuard *guard.WorkspaceGuard
+}
+
+func newFileSearchService() *fileSearchService {
+	return &fileSearchService{
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileSearch: search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileMapper: mapper.NewFileMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		groupRepo: repo.NewGroupRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		workspaceRepo: repo.NewWorkspaceRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		workspaceCache: cache.NewWorkspaceCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceGuard: guard.NewWorkspaceGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+	}
+}
+
+// search queries the files of all the workspaces the user can access, unlike
+// fileList.search the filtering by permission, the pagination and the sorting
+// are done by the search engine.
+func (svc *fileSearchService) search(opts FileSearchOptions, userID string) (*dto.FileSearchResult, error) {
+	res := &dto.FileSearchResult{
+		Data:  make([]*dto.FileSearchHit, 0),
+		Page:  opts.Page,
+		Query: &opts.Query,
+		Facets: &dto.FileSearchFacets{
+			Type:      make(map[string]int64),
+			Intent:    make(map[string]int64),
+			Language:  make(map[string]int64),
+			Workspace: make(map[string]int64),
+			Date:      make(map[string]int64),
+		},
+	}
+	workspaceIDs, err := svc.findWorkspaceIDs(opts.Query.WorkspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(workspaceIDs) == 0 {
+		return res, nil
+	}
+	groupIDs, err := svc.groupRepo.FindIDsByMember(userID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := svc.fileSearch.Search(*opts.Query.Text, infra.SearchOptions{
+		Filter: svc.filter(opts.Query, workspaceIDs) + " AND " + search.FilePrincipalFilter(userID, groupIDs),
+		Page:   int64(opts.Page),
+		Size:   int64(opts.Size),
+		Sort:   svc.sort(opts.SortBy, opts.SortOrder),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range result.Hits {
+		file, err := svc.fileCache.Get(hit.ID)
+		if err != nil {
+			var e *errorpkg.ErrorResponse
+			// We don't want to break if the search engine contains files that shouldn't be there
+			if errors.As(err, &e) && e.Code == errorpkg.NewFileNotFoundError(nil).Code {
+				continue
+			} else {
+				return nil, err
+			}
+		}
+		// The principals in the index can lag behind the permissions, for example
+		// when a user is removed from a group
+		if !svc.fileGuard.IsAuthorized(userID, file, model.PermissionViewer) {
+			continue
+		}
+		mapped, err := svc.fileMapper.Map(file, userID)
+		if err != nil {
+			return nil, err
+		}
+		res.Data = append(res.Data, &dto.FileSearchHit{
+			File:       mapped,
+			Highlights: hit.Highlights,
+		})
+	}
+	res.Size = uint64(len(res.Data))
+	res.TotalElements = uint64(result.TotalHits)
+	res.TotalPages = (res.TotalElements + opts.Size - 1) / opts.Size
+	svc.mapFacets(result.Facets, res.Facets)
+	return res, nil
+}
+
+func (svc *fileSearchService) findWorkspaceIDs(workspaceID *string, userID string) ([]string, error) {
+	var ids []string
+	if workspaceID != nil {
+		ids = []string{*workspaceID}
+	} else {
+		var err error
+		ids, err = svc.workspaceRepo.FindIDsForUser(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		workspace, err := svc.workspaceCache.Get(id)
+		if err != nil {
+			var e *errorpkg.ErrorResponse
+			if errors.As(err, &e) && e.Code == errorpkg.NewWorkspaceNotFoundError(nil).Code {
+				continue
+			} else {
+				return nil, err
+			}
+		}
+		if svc.workspaceGuard.IsAuthorized(userID, workspace, model.PermissionViewer) {
+			res = append(res, workspace.GetID())
+		}
+	}
+	return res, nil
+}
+
+func (svc *fileSearchService) filter(query dto.FileSearchQuery, workspaceIDs []string) string {
+	quoted := make([]string, 0, len(workspaceIDs))
+	for _, id := range workspaceIDs {
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	res := fmt.Sprintf("%s IN [%s]", search.FileFacetWorkspace, strings.Join(quoted, ","))
+	if query.Type != nil {
+		res += fmt.Sprintf(" AND %s=%s", search.FileFacetType, strconv.Quote(*query.Type))
+	}
+	if query.Intent != nil {
+		res += fmt.Sprintf(" AND %s=%s", search.FileFacetIntent, strconv.Quote(*query.Intent))
+	}
+	if query.Language != nil {
+		res += fmt.Sprintf(" AND %s=%s", search.FileFacetLanguage, strconv.Quote(*query.Language))
+	}
+	if query.Date != nil {
+		res += fmt.Sprintf(" AND %s=%s", search.FileFacetDate, strconv.Quote(*query.Date))
+	}
+	return res
+}
+
+// sort returns nil when sortBy is empty, so that the hits are sorted by relevance.
+func (svc *fileSearchService) sort(sortBy string, sortOrder string) []infra.SearchSort {
+	var field string
+	switch sortBy {
+	case dto.FileSortByName:
+		field = "name"
+	case dto.FileSortByDateCreated:
+		field = "createTime"
+	case dto.FileSortByDateModified:
+		field = "updateTime"
+	default:
+		return nil
+	}
+	return []infra.SearchSort{{
+		Field:      field,
+		Descending: sortOrder == dto.FileSortOrderDesc,
+	}}
+}
+
+func (svc *fileSearchService) mapFacets(facets map[string]map[string]int64, res *dto.FileSearchFacets) {
+	for name, target := range map[string]map[string]int64{
+		search.FileFacetType:      res.Type,
+		search.FileFacetIntent:    res.Intent,
+		search.FileFacetLanguage:  res.Language,
+		search.FileFacetWorkspace: res.Workspace,
+		search.FileFacetDate:      res.Date,
+	} {
+		for value, count := range facets[name] {
+			target[value] = count
+		}
+	}
+}
+
+// isValidSortBy rejects kind and size, which are not known to the search engine.
+func (svc *fileSearchService) isValidSortBy(value string) bool {
+	return value == "" ||
+		value == dto.FileSortByName ||
+		value == dto.FileSortByDateCreated ||
+		value == dto.FileSortByDateModified
 }
 
 type fileCompute struct {
@@ -1002,14 +1492,15 @@ func (svc *fileCompute) getCount(id string, userID string) (*int64, error) {
 }
 
 type fileCopy struct {
-	fileRepo     *repo.FileRepo
-	fileSearch   *search.FileSearch
-	fileCache    *cache.FileCache
-	fileGuard    *guard.FileGuard
-	fileMapper   *mapper.FileMapper
-	fileCoreSvc  *fileCoreService
-	taskSvc      *TaskService
-	snapshotRepo *repo.SnapshotRepo
+	fileRepo         *repo.FileRepo
+	fileSearch       *search.FileSearch
+	fileCache        *cache.FileCache
+	fileGuard        *guard.FileGuard
+	fileMapper       *mapper.FileMapper
+	fileCoreSvc      *fileCoreService
+	taskSvc          *TaskService
+	snapshotRepo     *repo.SnapshotRepo
+	webhookPublisher *webhookPublisher
 }
 
 func newFileCopy() *fileCopy {
@@ -1045,6 +1536,7 @@ func newFileCopy() *fileCopy {
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
 		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -1107,6 +1599,7 @@ func (svc *fileCopy) performCopy(source model.File, target model.File, userID st
 	}
 	svc.cache(cloneResult.Clones, userID)
 	go svc.index(cloneResult.Clones)
+	svc.webhookPublisher.publishFile(model.WebhookEventFileCreated, cloneResult.Root, userID)
 	if err := svc.refreshUpdateTime(target); err != nil {
 		return nil, err
 	}
@@ -1145,6 +1638,12 @@ func (svc *fileCopy) check(source model.File, target model.File, userID string)
 	if target.GetType() != model.FileTypeFolder {
 		return errorpkg.NewFileIsNotAFolderError(target)
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(source); err != nil {
+		return err
+	}
+	if err := svc.fileCoreSvc.checkNotInTrash(target); err != nil {
+		return err
+	}
 	isGrandChild, err := svc.fileRepo.IsGrandChildOf(target.GetID(), source.GetID())
 	if err != nil {
 		return err
@@ -1290,14 +1789,17 @@ func (svc *fileCopy) refreshUpdateTime(target model.File) error {
 }
 
 type fileDelete struct {
-	fileRepo       *repo.FileRepo
-	fileSearch     *search.FileSearch
-	fileGuard      *guard.FileGuard
-	fileCache      *cache.FileCache
-	workspaceCache *cache.WorkspaceCache
-	taskSvc        *TaskService
-	snapshotRepo   *repo.SnapshotRepo
-	snapshotSvc    *SnapshotService
+	fileRepo         *repo.FileRepo
+	fileSearch       *search.FileSearch
+	fileGuard        *guard.FileGuard
+	fileCache        *cache.FileCache
+	fileCoreSvc      *fileCoreService
+	workspaceCache   *cache.WorkspaceCache
+	taskSvc          *TaskService
+	snapshotRepo     *repo.SnapshotRepo
+	snapshotSvc      *SnapshotService
+	trashItemRepo    *repo.TrashItemRepo
+	webhookPublisher *webhookPublisher
 }
 
 func newFileDelete() *fileDelete {
@@ -1333,6 +1835,12 @@ func newFileDelete() *fileDelete {
 			config.GetConfig().Environment,
 		),
 		snapshotSvc: NewSnapshotService(),
+		fileCoreSvc: newFileCoreService(),
+		trashItemRepo: repo.NewTrashItemRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -1356,7 +1864,11 @@ func (svc *fileDelete) delete(id string, userID string) error {
 	if err := svc.check(file); err != nil {
 		return err
 	}
-	return svc.performDelete(file)
+	if err := svc.moveToTrash(file, userID); err != nil {
+		return err
+	}
+	svc.webhookPublisher.publishFile(model.WebhookEventFileDeleted, file, userID)
+	return nil
 }
 
 func (svc *fileDelete) deleteMany(opts dto.FileDeleteManyOptions, userID string) (*dto.FileDeleteManyResult, error) {
@@ -1384,6 +1896,9 @@ func (svc *fileDelete) performDelete(file model.File) error {
 }
 
 func (svc *fileDelete) check(file model.File) error {
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return err
+	}
 	if file.GetParentID() == nil {
 		workspace, err := svc.workspaceCache.Get(file.GetWorkspaceID())
 		if err != nil {
@@ -1394,6 +1909,54 @@ func (svc *fileDelete) check(file model.File) error {
 	return nil
 }
 
+// moveToTrash detaches the file from its parent and records where it was, the
+// file and its descendants are kept along with their snapshots until purged.
+func (svc *fileDelete) moveToTrash(file model.File, userID string) error {
+	originalPath, err := svc.getOriginalPath(file)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.trashItemRepo.Insert(repo.TrashItemInsertOptions{
+		ID:               helper.NewID(),
+		FileID:           file.GetID(),
+		WorkspaceID:      file.GetWorkspaceID(),
+		UserID:           userID,
+		OriginalParentID: file.GetParentID(),
+		OriginalPath:     originalPath,
+	}); err != nil {
+		return err
+	}
+	file.SetParentID(nil)
+	if err := svc.fileRepo.Save(file); err != nil {
+		return err
+	}
+	if err := svc.fileCache.Set(file); err != nil {
+		return err
+	}
+	treeIDs, err := svc.fileRepo.FindTreeIDs(file.GetID())
+	if err != nil {
+		return err
+	}
+	svc.deleteFromSearch(treeIDs)
+	return nil
+}
+
+// getOriginalPath returns the path of the file relative to the workspace root,
+// e.g. "/Documents/report.pdf".
+func (svc *fileDelete) getOriginalPath(file model.File) (string, error) {
+	path, err := svc.fileRepo.FindPath(file.GetID())
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, leaf := range path {
+		if leaf.GetParentID() != nil {
+			names = append([]string{leaf.GetName()}, names...)
+		}
+	}
+	return "/" + strings.Join(names, "/"), nil
+}
+
 func (svc *fileDelete) createTask(file model.File, userID string) (model.Task, error) {
 	res, err := svc.taskSvc.insertAndSync(repo.TaskInsertOptions{
 		ID:              helper.NewID(),
@@ -1488,6 +2051,7 @@ func (svc *fileDelete) deleteFromSearch(ids []string) {
 type fileDownload struct {
 	fileCache     *cache.FileCache
 	fileGuard     *guard.FileGuard
+	fileCoreSvc   *fileCoreService
 	snapshotCache *cache.SnapshotCache
 	s3            infra.S3Manager
 }
@@ -1504,6 +2068,7 @@ func newFileDownload() *fileDownload {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		fileCoreSvc: newFileCoreService(),
 		snapshotCache: cache.NewSnapshotCache(
 			config.GetConfig().Postgres,
 			config.GetConfig().Redis,
@@ -1579,6 +2144,61 @@ func (svc *fileDownload) downloadPreviewBuffer(id string, rangeHeader string, bu
 	}
 }
 
+// downloadHLSBuffer downloads the master playlist when the rendition is empty,
+// otherwise the playlist or a segment of the rendition.
+func (svc *fileDownload) downloadHLSBuffer(id string, rendition string, name string, rangeHeader string, buf *bytes.Buffer, userID string) (*DownloadResult, error) {
+	file, err := svc.fileCache.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	if err = svc.check(file); err != nil {
+		return nil, err
+	}
+	snapshot, err := svc.snapshotCache.Get(*file.GetSnapshotID())
+	if err != nil {
+		return nil, err
+	}
+	if !snapshot.HasHLS() {
+		return nil, errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	hls := snapshot.GetHLS()
+	key, ok := svc.hlsKey(hls, rendition, name)
+	if !ok {
+		return nil, errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	rangeInterval, err := svc.downloadS3Object(&model.S3Object{Bucket: hls.Bucket, Key: key}, rangeHeader, buf)
+	if err != nil {
+		return nil, err
+	}
+	return &DownloadResult{
+		File:          file,
+		Snapshot:      snapshot,
+		RangeInterval: rangeInterval,
+	}, nil
+}
+
+var hlsSegmentRegex = regexp.MustCompile(`^(index\.m3u8|segment_\d+\.ts)$`)
+
+// hlsKey only resolves the names produced by the conversion pipeline, so that
+// the path can't be used to read other objects of the bucket.
+func (svc *fileDownload) hlsKey(hls *model.S3Object, rendition string, name string) (string, bool) {
+	if rendition == "" {
+		return hls.Key + "/" + name, name == "master.m3u8"
+	}
+	if hls.Media == nil || !hlsSegmentRegex.MatchString(name) {
+		return "", false
+	}
+	for _, r := range hls.Media.Renditions {
+		if r.Name == rendition {
+			return hls.Key + "/" + rendition + "/" + name, true
+		}
+	}
+	return "", false
+}
+
 func (svc *fileDownload) downloadTextBuffer(id string, buf *bytes.Buffer, userID string) (*DownloadResult, error) {
 	file, err := svc.fileCache.Get(id)
 	if err != nil {
@@ -1668,6 +2288,9 @@ func (svc *fileDownload) check(file model.File) error {
 	if file.GetType() != model.FileTypeFile || file.GetSnapshotID() == nil {
 		return errorpkg.NewFileIsNotAFileError(file)
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1691,13 +2314,14 @@ func (svc *fileDownload) downloadS3Object(s3Object *model.S3Object, rangeHeader
 }
 
 type fileMove struct {
-	fileRepo    *repo.FileRepo
-	fileSearch  *search.FileSearch
-	fileCache   *cache.FileCache
-	fileGuard   *guard.FileGuard
-	fileMapper  *mapper.FileMapper
-	fileCoreSvc *fileCoreService
-	taskSvc     *TaskService
+	fileRepo         *repo.FileRepo
+	fileSearch       *search.FileSearch
+	fileCache        *cache.FileCache
+	fileGuard        *guard.FileGuard
+	fileMapper       *mapper.FileMapper
+	fileCoreSvc      *fileCoreService
+	taskSvc          *TaskService
+	webhookPublisher *webhookPublisher
 }
 
 func newFileMove() *fileMove {
@@ -1727,8 +2351,9 @@ func newFileMove() *fileMove {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
-		fileCoreSvc: newFileCoreService(),
-		taskSvc:     NewTaskService(),
+		fileCoreSvc:      newFileCoreService(),
+		taskSvc:          NewTaskService(),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -1772,6 +2397,7 @@ func (svc *fileMove) moveMany(opts dto.FileMoveManyOptions, userID string) (*dto
 }
 
 func (svc *fileMove) performMove(source model.File, target model.File, userID string) (*dto.File, error) {
+	previousParentID := source.GetParentID()
 	if err := svc.fileRepo.MoveSourceIntoTarget(target.GetID(), source.GetID()); err != nil {
 		return nil, err
 	}
@@ -1783,6 +2409,15 @@ func (svc *fileMove) performMove(source model.File, target model.File, userID st
 	if err := svc.refreshUpdateAndCreateTime(source, target); err != nil {
 		return nil, err
 	}
+	svc.webhookPublisher.publish(source.GetWorkspaceID(), model.WebhookEventFileMoved, dto.WebhookFileEventData{
+		ID:               source.GetID(),
+		Name:             source.GetName(),
+		Type:             source.GetType(),
+		ParentID:         source.GetParentID(),
+		PreviousParentID: previousParentID,
+		SnapshotID:       source.GetSnapshotID(),
+		UserID:           &userID,
+	})
 	res, err := svc.fileMapper.Map(source, userID)
 	if err != nil {
 		return nil, err
@@ -1830,6 +2465,12 @@ func (svc *fileMove) check(source model.File, target model.File, userID string)
 	if target.GetType() != model.FileTypeFolder {
 		return errorpkg.NewFileIsNotAFolderError(target)
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(source); err != nil {
+		return err
+	}
+	if err := svc.fileCoreSvc.checkNotInTrash(target); err != nil {
+		return err
+	}
 	isGrandChild, err := svc.fileRepo.IsGrandChildOf(target.GetID(), source.GetID())
 	if err != nil {
 		return err
@@ -1860,11 +2501,12 @@ func (svc *fileMove) refreshUpdateAndCreateTime(source model.File, target model.
 }
 
 type filePatch struct {
-	fileCache   *cache.FileCache
-	fileRepo    *repo.FileRepo
-	fileGuard   *guard.FileGuard
-	fileCoreSvc *fileCoreService
-	fileMapper  *mapper.FileMapper
+	fileCache        *cache.FileCache
+	fileRepo         *repo.FileRepo
+	fileGuard        *guard.FileGuard
+	fileCoreSvc      *fileCoreService
+	fileMapper       *mapper.FileMapper
+	webhookPublisher *webhookPublisher
 }
 
 func newFilePatch() *filePatch {
@@ -1889,6 +2531,7 @@ func newFilePatch() *filePatch {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -1900,6 +2543,9 @@ func (svc *filePatch) patchName(id string, name string, userID string) (*dto.Fil
 	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
 		return nil, err
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
 	if file.GetParentID() != nil {
 		existing, err := svc.fileCoreSvc.getChildWithName(*file.GetParentID(), name)
 		if err != nil {
@@ -1916,6 +2562,7 @@ func (svc *filePatch) patchName(id string, name string, userID string) (*dto.Fil
 	if err := svc.fileCoreSvc.sync(file); err != nil {
 		return nil, err
 	}
+	svc.webhookPublisher.publishFile(model.WebhookEventFileUpdated, file, userID)
 	res, err := svc.fileMapper.Map(file, userID)
 	if err != nil {
 		return nil, err
@@ -1923,19 +2570,173 @@ func (svc *filePatch) patchName(id string, name string, userID string) (*dto.Fil
 	return res, nil
 }
 
+type fileProperty struct {
+	fileCache        *cache.FileCache
+	fileGuard        *guard.FileGuard
+	filePropertyRepo *repo.FilePropertyRepo
+}
+
+func newFileProperty() *fileProperty {
+	return &fileProperty{
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		filePropertyRepo: repo.NewFilePropertyRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+	}
+}
+
+func (svc *fileProperty) list(id string, userID string) ([]*dto.FileProperty, error) {
+	file, err := svc.fileCache.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	return svc.find(file.GetID())
+}
+
+// listMany returns the properties of the files the user can view, keyed by
+// file ID, the other files are left out.
+func (svc *fileProperty) listMany(opts dto.FileListPropertiesManyOptions, userID string) (map[string][]*dto.FileProperty, error) {
+	files, err := svc.fileCache.GetMany(opts.IDs)
+	if err != nil {
+		return nil, err
+	}
+	files = svc.fileGuard.FilterAuthorized(userID, files, model.PermissionViewer)
+	res := make(map[string][]*dto.FileProperty)
+	if len(files) == 0 {
+		return res, nil
+	}
+	var ids []string
+	for _, f := range files {
+		ids = append(ids, f.GetID())
+		res[f.GetID()] = make([]*dto.FileProperty, 0)
+	}
+	properties, err := svc.filePropertyRepo.FindByFileIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range properties {
+		res[p.GetFileID()] = append(res[p.GetFileID()], svc.mapProperty(p))
+	}
+	return res, nil
+}
+
+// FilePropertyProtectedNamespace holds the live properties of WebDAV, which
+// are computed rather than stored, so they cannot be changed.
+const FilePropertyProtectedNamespace = "DAV:"
+
+// patch applies the operations in order within a single transaction, when one
+// of them fails everything is rolled back, the failing operation gets its own
+// status and all the others get 424 Failed Dependency.
+func (svc *fileProperty) patch(id string, opts dto.FilePatchPropertiesOptions, userID string) (*dto.FilePatchPropertiesResult, error) {
+	file, err := svc.fileCache.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	statuses := make([]dto.FilePropertyStatus, 0, len(opts.Operations))
+	for _, op := range opts.Operations {
+		statuses = append(statuses, dto.FilePropertyStatus{Namespace: op.Namespace, Name: op.Name, Status: http.StatusOK})
+	}
+	failed := -1
+	err = svc.filePropertyRepo.Transaction(func(tx *repo.FilePropertyRepo) error {
+		for i, op := range opts.Operations {
+			var err error
+			if op.Namespace == FilePropertyProtectedNamespace {
+				statuses[i].Status = http.StatusForbidden
+				err = fmt.Errorf("property %s%s is protected", op.Namespace, op.Name)
+			} else if op.Action == dto.FilePropertyActionSet {
+				err = tx.Upsert(repo.FilePropertyUpsertOptions{
+					FileID:    file.GetID(),
+					Namespace: op.Namespace,
+					Name:      op.Name,
+					Value:     op.Value,
+				})
+			} else {
+				err = tx.Delete(file.GetID(), op.Namespace, op.Name)
+			}
+			if err != nil {
+				if statuses[i].Status == http.StatusOK {
+					statuses[i].Status = http.StatusInternalServerError
+				}
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed == -1 {
+			return nil, err
+		}
+		if statuses[failed].Status == http.StatusInternalServerError {
+			logger.GetLogger().Error(err)
+		}
+		for i := range statuses {
+			if i != failed {
+				statuses[i].Status = http.StatusFailedDependency
+			}
+		}
+	}
+	properties, err := svc.find(file.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return &dto.FilePatchPropertiesResult{
+		Properties: properties,
+		Statuses:   statuses,
+	}, nil
+}
+
+func (svc *fileProperty) find(id string) ([]*dto.FileProperty, error) {
+	properties, err := svc.filePropertyRepo.FindByFileID(id)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*dto.FileProperty, 0)
+	for _, p := range properties {
+		res = append(res, svc.mapProperty(p))
+	}
+	return res, nil
+}
+
+func (svc *fileProperty) mapProperty(p model.FileProperty) *dto.FileProperty {
+	return &dto.FileProperty{
+		Namespace: p.GetNamespace(),
+		Name:      p.GetName(),
+		Value:     p.GetValue(),
+	}
+}
+
 type filePermission struct {
-	fileCache      *cache.FileCache
-	fileRepo       *repo.FileRepo
-	fileGuard      *guard.FileGuard
-	fileCoreSvc    *fileCoreService
-	userRepo       *repo.UserRepo
-	userMapper     *mapper.UserMapper
-	workspaceRepo  *repo.WorkspaceRepo
-	workspaceCache *cache.WorkspaceCache
-	groupCache     *cache.GroupCache
-	groupGuard     *guard.GroupGuard
-	groupMapper    *mapper.GroupMapper
-	permissionRepo *repo.PermissionRepo
+	fileCache        *cache.FileCache
+	fileRepo         *repo.FileRepo
+	fileSearch       *search.FileSearch
+	fileGuard        *guard.FileGuard
+	fileCoreSvc      *fileCoreService
+	userRepo         *repo.UserRepo
+	userMapper       *mapper.UserMapper
+	workspaceRepo    *repo.WorkspaceRepo
+	workspaceCache   *cache.WorkspaceCache
+	groupCache       *cache.GroupCache
+	groupGuard       *guard.GroupGuard
+	groupMapper      *mapper.GroupMapper
+	permissionRepo   *repo.PermissionRepo
+	webhookPublisher *webhookPublisher
 }
 
 func newFilePermission() *filePermission {
@@ -1949,6 +2750,12 @@ func newFilePermission() *filePermission {
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
 		),
+		fileSearch: search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		),
 		fileGuard: guard.NewFileGuard(
 			config.GetConfig().Postgres,
 			config.GetConfig().Redis,
@@ -1988,6 +2795,7 @@ func newFilePermission() *filePermission {
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
 		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -2011,6 +2819,12 @@ func (svc *filePermission) grantUserPermission(id string, assigneeID string, per
 	if _, err := svc.workspaceCache.Refresh(file.GetWorkspaceID()); err != nil {
 		return err
 	}
+	svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventPermissionChanged, dto.WebhookPermissionEventData{
+		FileID:     id,
+		UserID:     &assigneeID,
+		Permission: &permission,
+		ChangedBy:  userID,
+	})
 	if err := svc.refreshPathAndTree(id); err != nil {
 		return nil
 	}
@@ -2027,7 +2841,8 @@ func (svc *filePermission) revokeUserPermissions(ids []string, assigneeID string
 }
 
 func (svc *filePermission) revokeUserPermission(id string, assigneeID string, userID string) error {
-	if _, err := svc.authorizeUserPermission(id, assigneeID, userID); err != nil {
+	file, err := svc.authorizeUserPermission(id, assigneeID, userID)
+	if err != nil {
 		return err
 	}
 	tree, err := svc.fileRepo.FindTree(id)
@@ -2037,11 +2852,22 @@ func (svc *filePermission) revokeUserPermission(id string, assigneeID string, us
 	if err := svc.fileRepo.RevokeUserPermission(tree, assigneeID); err != nil {
 		return err
 	}
+	var refreshed []model.File
 	for _, leaf := range tree {
-		if _, err := svc.fileCache.Refresh(leaf.GetID()); err != nil {
+		f, err := svc.fileCache.Refresh(leaf.GetID())
+		if err != nil {
 			return err
 		}
+		refreshed = append(refreshed, f)
 	}
+	if err := svc.fileSearch.Update(refreshed); err != nil {
+		return err
+	}
+	svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventPermissionChanged, dto.WebhookPermissionEventData{
+		FileID:    id,
+		UserID:    &assigneeID,
+		ChangedBy: userID,
+	})
 	return nil
 }
 
@@ -2065,6 +2891,12 @@ func (svc *filePermission) grantGroupPermission(id string, groupID string, permi
 	if _, err := svc.workspaceCache.Refresh(file.GetWorkspaceID()); err != nil {
 		return err
 	}
+	svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventPermissionChanged, dto.WebhookPermissionEventData{
+		FileID:     id,
+		GroupID:    &groupID,
+		Permission: &permission,
+		ChangedBy:  userID,
+	})
 	if err := svc.refreshPathAndTree(id); err != nil {
 		return nil
 	}
@@ -2081,7 +2913,8 @@ func (svc *filePermission) revokeGroupPermissions(ids []string, groupID string,
 }
 
 func (svc *filePermission) revokeGroupPermission(id string, groupID string, userID string) error {
-	if _, _, err := svc.authorizeGroupPermission(id, groupID, userID); err != nil {
+	file, _, err := svc.authorizeGroupPermission(id, groupID, userID)
+	if err != nil {
 		return err
 	}
 	tree, err := svc.fileRepo.FindTree(id)
@@ -2091,11 +2924,22 @@ func (svc *filePermission) revokeGroupPermission(id string, groupID string, user
 	if err := svc.fileRepo.RevokeGroupPermission(tree, groupID); err != nil {
 		return err
 	}
+	var refreshed []model.File
 	for _, leaf := range tree {
-		if _, err := svc.fileCache.Refresh(leaf.GetID()); err != nil {
+		f, err := svc.fileCache.Refresh(leaf.GetID())
+		if err != nil {
 			return err
 		}
+		refreshed = append(refreshed, f)
+	}
+	if err := svc.fileSearch.Update(refreshed); err != nil {
+		return err
 	}
+	svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventPermissionChanged, dto.WebhookPermissionEventData{
+		FileID:    id,
+		GroupID:   &groupID,
+		ChangedBy: userID,
+	})
 	return nil
 }
 
@@ -2107,6 +2951,9 @@ func (svc *filePermission) authorizeUserPermission(id string, assigneeID string,
 	if err := svc.fileGuard.Authorize(userID, file, model.PermissionOwner); err != nil {
 		return nil, err
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
 	if _, err := svc.userRepo.Find(assigneeID); err != nil {
 		return nil, err
 	}
@@ -2121,6 +2968,9 @@ func (svc *filePermission) authorizeGroupPermission(id string, groupID string, u
 	if err := svc.fileGuard.Authorize(userID, file, model.PermissionOwner); err != nil {
 		return nil, nil, err
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, nil, err
+	}
 	group, err := svc.groupCache.Get(groupID)
 	if err != nil {
 		return nil, nil, err
@@ -2131,24 +2981,39 @@ func (svc *filePermission) authorizeGroupPermission(id string, groupID string, u
 	return file, group, nil
 }
 
+// refreshPathAndTree refreshes the cache and the search index of the files
+// whose permissions were changed by a grant, so that the principals stored in
+// the index stay in sync.
 func (svc *filePermission) refreshPathAndTree(id string) error {
+	var refreshed []model.File
 	path, err := svc.fileRepo.FindPath(id)
 	if err != nil {
 		return err
 	}
 	for _, f := range path {
-		if _, err := svc.fileCache.Refresh(f.GetID()); err != nil {
+		f, err := svc.fileCache.Refresh(f.GetID())
+		if err != nil {
 			return err
 		}
+		refreshed = append(refreshed, f)
 	}
 	tree, err := svc.fileRepo.FindTree(id)
 	if err != nil {
 		return err
 	}
 	for _, leaf := range tree {
-		if _, err := svc.fileCache.Refresh(leaf.GetID()); err != nil {
+		// The file itself is part of both the path and the tree
+		if leaf.GetID() == id {
+			continue
+		}
+		f, err := svc.fileCache.Refresh(leaf.GetID())
+		if err != nil {
 			return err
 		}
+		refreshed = append(refreshed, f)
+	}
+	if err := svc.fileSearch.Update(refreshed); err != nil {
+		return err
 	}
 	return nil
 }
@@ -2385,12 +3250,14 @@ func (svc *fileReprocess) runPipeline(file model.File, snapshot model.Snapshot,
 		return err
 	}
 	if err := svc.pipelineClient.Run(&dto.PipelineRunOptions{
-		TaskID:     helper.ToPtr(task.GetID()),
-		SnapshotID: snapshot.GetID(),
-		Bucket:     snapshot.GetOriginal().Bucket,
-		Key:        snapshot.GetOriginal().Key,
-		Intent:     snapshot.GetIntent(),
-		Language:   snapshot.GetLanguage(),
+		TaskID:      helper.ToPtr(task.GetID()),
+		SnapshotID:  snapshot.GetID(),
+		WorkspaceID: helper.ToPtr(file.GetWorkspaceID()),
+		Priority:    helper.ToPtr(dto.PipelinePriorityBulk),
+		Bucket:      snapshot.GetOriginal().Bucket,
+		Key:         snapshot.GetOriginal().Key,
+		Intent:      snapshot.GetIntent(),
+		Language:    snapshot.GetLanguage(),
 	}); err != nil {
 		return err
 	}
@@ -2412,6 +3279,7 @@ type fileStore struct {
 	s3                    infra.S3Manager
 	pipelineClient        client.PipelineClient
 	config                *config.Config
+	webhookPublisher      *webhookPublisher
 }
 
 func newFileStore() *fileStore {
@@ -2457,13 +3325,17 @@ func newFileStore() *fileStore {
 			config.GetConfig().ConversionURL,
 			config.GetConfig().Environment.IsTest,
 		),
-		config: config.GetConfig(),
+		config:           config.GetConfig(),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
 type FileStoreOptions struct {
 	S3Reference *model.S3Reference
 	Path        *string
+	// Label and Note are set on the snapshot created from the upload.
+	Label *string
+	Note  *string
 }
 
 func (svc *fileStore) store(id string, opts FileStoreOptions, userID string) (*dto.File, error) {
@@ -2471,6 +3343,9 @@ func (svc *fileStore) store(id string, opts FileStoreOptions, userID string) (*d
 	if err != nil {
 		return nil, err
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
 	props, err := svc.getProperties(file, opts)
 	if err != nil {
 		return nil, err
@@ -2480,7 +3355,7 @@ func (svc *fileStore) store(id string, opts FileStoreOptions, userID string) (*d
 			return nil, err
 		}
 	}
-	snapshot, err := svc.createSnapshot(file, props)
+	snapshot, err := svc.createSnapshot(file, props, opts, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -2491,6 +3366,7 @@ func (svc *fileStore) store(id string, opts FileStoreOptions, userID string) (*d
 	if err != nil {
 		return nil, err
 	}
+	svc.webhookPublisher.publishFile(model.WebhookEventFileUpdated, file, userID)
 	if !props.ExceedsProcessingLimit {
 		if err := svc.runPipeline(file, snapshot, props, userID); err != nil {
 			return nil, err
@@ -2574,9 +3450,12 @@ func (svc *fileStore) performStore(props fileStoreProperties) error {
 	return nil
 }
 
-func (svc *fileStore) createSnapshot(file model.File, props fileStoreProperties) (model.Snapshot, error) {
+func (svc *fileStore) createSnapshot(file model.File, props fileStoreProperties, opts FileStoreOptions, userID string) (model.Snapshot, error) {
 	res := repo.NewSnapshotModel()
 	res.SetID(props.SnapshotID)
+	res.SetLabel(opts.Label)
+	res.SetNote(opts.Note)
+	res.SetUserID(&userID)
 	latestVersion, err := svc.snapshotRepo.FindLatestVersionForFile(file.GetID())
 	if err != nil {
 		return nil, err
@@ -2642,12 +3521,14 @@ func (svc *fileStore) runPipeline(file model.File, snapshot model.Snapshot, prop
 		return err
 	}
 	if err := svc.pipelineClient.Run(&dto.PipelineRunOptions{
-		TaskID:     helper.ToPtr(task.GetID()),
-		SnapshotID: snapshot.GetID(),
-		Bucket:     props.Original.Bucket,
-		Key:        props.Original.Key,
-		Intent:     snapshot.GetIntent(),
-		Language:   snapshot.GetLanguage(),
+		TaskID:      helper.ToPtr(task.GetID()),
+		SnapshotID:  snapshot.GetID(),
+		WorkspaceID: helper.ToPtr(file.GetWorkspaceID()),
+		Priority:    helper.ToPtr(dto.PipelinePriorityInteractive),
+		Bucket:      props.Original.Bucket,
+		Key:         props.Original.Key,
+		Intent:      snapshot.GetIntent(),
+		Language:    snapshot.GetLanguage(),
 	}); err != nil {
 		return err
 	}
@@ -2655,12 +3536,13 @@ func (svc *fileStore) runPipeline(file model.File, snapshot model.Snapshot, prop
 }
 
 type fileCoreService struct {
-	fileRepo   *repo.FileRepo
-	fileSearch *search.FileSearch
-	fileCache  *cache.FileCache
-	fileGuard  *guard.FileGuard
-	fileIdent  *infra.FileIdentifier
-	config     *config.Config
+	fileRepo      *repo.FileRepo
+	fileSearch    *search.FileSearch
+	fileCache     *cache.FileCache
+	fileGuard     *guard.FileGuard
+	fileIdent     *infra.FileIdentifier
+	trashItemRepo *repo.TrashItemRepo
+	config        *config.Config
 }
 
 func newFileCoreService() *fileCoreService {
@@ -2686,7 +3568,11 @@ func newFileCoreService() *fileCoreService {
 			config.GetConfig().Environment,
 		),
 		fileIdent: infra.NewFileIdentifier(),
-		config:    config.GetConfig(),
+		trashItemRepo: repo.NewTrashItemRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		config: config.GetConfig(),
 	}
 }
 
@@ -2703,6 +3589,23 @@ func (svc *fileCoreService) getChildWithName(id string, name string) (model.File
 	return nil, nil
 }
 
+// isInTrash tells whether the file is in the trash, either directly or
+// because one of its ancestors is.
+func (svc *fileCoreService) isInTrash(file model.File) (bool, error) {
+	return svc.trashItemRepo.IsInTrash(file.GetID())
+}
+
+func (svc *fileCoreService) checkNotInTrash(file model.File) error {
+	inTrash, err := svc.isInTrash(file)
+	if err != nil {
+		return err
+	}
+	if inTrash {
+		return errorpkg.NewFileIsInTrashError(file)
+	}
+	return nil
+}
+
 func (svc *fileCoreService) sync(file model.File) error {
 	if err := svc.fileSearch.Update([]model.File{file}); err != nil {
 		return err
@@ -2724,32 +3627,15 @@ func (svc *fileCoreService) saveAndSync(file model.File) error {
 }
 
 func (svc *fileCoreService) authorize(userID string, files []model.File, permission string) ([]model.File, error) {
-	var res []model.File
-	for _, f := range files {
-		if svc.fileGuard.IsAuthorized(userID, f, permission) {
-			res = append(res, f)
-		}
-	}
-	return res, nil
+	return svc.fileGuard.FilterAuthorized(userID, files, permission), nil
 }
 
 func (svc *fileCoreService) authorizeIDs(userID string, ids []string, permission string) ([]model.File, error) {
-	var res []model.File
-	for _, id := range ids {
-		f, err := svc.fileCache.Get(id)
-		if err != nil {
-			var e *errorpkg.ErrorResponse
-			if errors.As(err, &e) && e.Code == errorpkg.NewFileNotFoundError(nil).Code {
-				continue
-			} else {
-				return nil, err
-			}
-		}
-		if svc.fileGuard.IsAuthorized(userID, f, permission) {
-			res = append(res, f)
-		}
+	files, err := svc.fileCache.GetMany(ids)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return svc.fileGuard.FilterAuthorized(userID, files, permission), nil
 }
 
 func (svc *fileCoreService) getProcessingLimitMB(path string) int64 {
@@ -13,17 +13,20 @@ package service_test
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/dto"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 
@@ -738,6 +741,72 @@ func (s *FileServiceTestSuite) TestList_Paginate() {
 	s.Equal("file C", list.Data[0].Name)
 }
 
+func (s *FileServiceTestSuite) TestList_Cursor() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	for _, name := range []string{"file A", "file B", "file C"} {
+		_, err := service.NewFileService().Create(service.FileCreateOptions{
+			WorkspaceID: workspace.ID,
+			Name:        name,
+			Type:        model.FileTypeFile,
+			ParentID:    workspace.RootID,
+		}, s.users[0].GetID())
+		s.Require().NoError(err)
+	}
+
+	list, err := service.NewFileService().List(workspace.RootID, service.FileListOptions{
+		Page:   1,
+		Size:   2,
+		SortBy: dto.FileSortByName,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 2)
+	s.Equal("file A", list.Data[0].Name)
+	s.Equal("file B", list.Data[1].Name)
+	s.Nil(list.PreviousCursor)
+	s.Require().NotNil(list.NextCursor)
+
+	list, err = service.NewFileService().List(workspace.RootID, service.FileListOptions{
+		Size:   2,
+		SortBy: dto.FileSortByName,
+		Cursor: *list.NextCursor,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(uint64(3), list.TotalElements)
+	s.Require().Len(list.Data, 1)
+	s.Equal("file C", list.Data[0].Name)
+	s.Nil(list.NextCursor)
+	s.Require().NotNil(list.PreviousCursor)
+
+	list, err = service.NewFileService().List(workspace.RootID, service.FileListOptions{
+		Size:   2,
+		SortBy: dto.FileSortByName,
+		Cursor: *list.PreviousCursor,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 2)
+	s.Equal("file A", list.Data[0].Name)
+	s.Equal("file B", list.Data[1].Name)
+	s.Nil(list.PreviousCursor)
+	s.NotNil(list.NextCursor)
+}
+
+func (s *FileServiceTestSuite) TestList_InvalidCursor() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewFileService().List(workspace.RootID, service.FileListOptions{
+		Size:   2,
+		Cursor: "invalid",
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewInvalidQueryParamError("cursor").Error(), err.Error())
+}
+
 func (s *FileServiceTestSuite) TestList_SortByNameDescending() {
 	org, err := test.CreateOrganization(s.users[0].GetID())
 	s.Require().NoError(err)
@@ -1365,6 +1434,83 @@ func (s *FileServiceTestSuite) TestDownloadOriginalBuffer_MissingPermission() {
 	s.Equal(errorpkg.NewFileNotFoundError(err).Error(), err.Error())
 }
 
+func (s *FileServiceTestSuite) TestDownloadHLSBuffer() {
+	file := s.createFileWithHLS()
+
+	buf := new(bytes.Buffer)
+	_, err := service.NewFileService().DownloadHLSBuffer(file.ID, "", "master.m3u8", "", buf, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("#EXTM3U\n360p/index.m3u8\n", buf.String())
+
+	buf.Reset()
+	_, err = service.NewFileService().DownloadHLSBuffer(file.ID, "360p", "segment_00000.ts", "", buf, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("segment", buf.String())
+}
+
+func (s *FileServiceTestSuite) TestDownloadHLSBuffer_UnknownRendition() {
+	file := s.createFileWithHLS()
+
+	_, err := service.NewFileService().DownloadHLSBuffer(file.ID, "1080p", "index.m3u8", "", new(bytes.Buffer), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewS3ObjectNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *FileServiceTestSuite) TestDownloadHLSBuffer_InvalidName() {
+	file := s.createFileWithHLS()
+
+	_, err := service.NewFileService().DownloadHLSBuffer(file.ID, "360p", "../../original.txt", "", new(bytes.Buffer), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewS3ObjectNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *FileServiceTestSuite) TestDownloadHLSBuffer_MissingPermission() {
+	file := s.createFileWithHLS()
+
+	s.revokeUserPermissionForFile(file, s.users[0])
+
+	_, err := service.NewFileService().DownloadHLSBuffer(file.ID, "", "master.m3u8", "", new(bytes.Buffer), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(err).Error(), err.Error())
+}
+
+func (s *FileServiceTestSuite) createFileWithHLS() *dto.File {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err = service.NewFileService().Store(file.ID, service.FileStoreOptions{
+		Path: helper.ToPtr(filepath.Join("fixtures", "files", "file.txt")),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	snapshot, err := service.NewSnapshotService().Find(file.Snapshot.ID)
+	s.Require().NoError(err)
+	hls := &model.S3Object{
+		Bucket: snapshot.Original.Bucket,
+		Key:    snapshot.ID + "/hls",
+		Media: &model.MediaProps{
+			Renditions: []model.RenditionProps{{Name: "360p", Width: 640, Height: 360, Bandwidth: 896000}},
+		},
+	}
+	s3 := infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment)
+	s.Require().NoError(s3.PutText(hls.Key+"/master.m3u8", "#EXTM3U\n360p/index.m3u8\n", "application/vnd.apple.mpegurl", hls.Bucket, minio.PutObjectOptions{}))
+	s.Require().NoError(s3.PutText(hls.Key+"/360p/segment_00000.ts", "segment", "video/mp2t", hls.Bucket, minio.PutObjectOptions{}))
+	_, err = service.NewSnapshotService().Patch(snapshot.ID, dto.SnapshotPatchOptions{
+		Fields: []string{model.SnapshotFieldHLS},
+		HLS:    hls,
+	})
+	s.Require().NoError(err)
+	return file
+}
+
 func (s *FileServiceTestSuite) TestMove() {
 	org, err := test.CreateOrganization(s.users[0].GetID())
 	s.Require().NoError(err)
@@ -1625,6 +1771,225 @@ func (s *FileServiceTestSuite) TestPatchName_InsufficientPermission() {
 	)
 }
 
+func (s *FileServiceTestSuite) TestPatchProperties() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	res, err := service.NewFileService().PatchProperties(file.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{
+				Action:    dto.FilePropertyActionSet,
+				Namespace: "urn:schemas-microsoft-com:",
+				Name:      "Win32FileAttributes",
+				Value:     helper.ToPtr("00000020"),
+			},
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("red")},
+		},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Len(res.Properties, 2)
+	s.Require().Len(res.Statuses, 2)
+	s.Equal(http.StatusOK, res.Statuses[0].Status)
+	s.Equal(http.StatusOK, res.Statuses[1].Status)
+
+	res, err = service.NewFileService().PatchProperties(file.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("blue")},
+			{Action: dto.FilePropertyActionRemove, Namespace: "urn:schemas-microsoft-com:", Name: "Win32FileAttributes"},
+		},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(res.Properties, 1)
+	s.Equal("http://example.com/ns", res.Properties[0].Namespace)
+	s.Equal("color", res.Properties[0].Name)
+	s.Equal("blue", *res.Properties[0].Value)
+
+	properties, err := service.NewFileService().ListProperties(file.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Len(properties, 1)
+}
+
+func (s *FileServiceTestSuite) TestPatchProperties_DocumentOrder() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	res, err := service.NewFileService().PatchProperties(file.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("red")},
+			{Action: dto.FilePropertyActionRemove, Namespace: "http://example.com/ns", Name: "color"},
+			{Action: dto.FilePropertyActionRemove, Namespace: "http://example.com/ns", Name: "size"},
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "size", Value: helper.ToPtr("large")},
+		},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(res.Properties, 1)
+	s.Equal("size", res.Properties[0].Name)
+	s.Equal("large", *res.Properties[0].Value)
+}
+
+func (s *FileServiceTestSuite) TestPatchProperties_ProtectedRollsBack() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	res, err := service.NewFileService().PatchProperties(file.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("red")},
+			{Action: dto.FilePropertyActionSet, Namespace: "DAV:", Name: "getetag", Value: helper.ToPtr("etag")},
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "size", Value: helper.ToPtr("large")},
+		},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(res.Properties)
+	s.Require().Len(res.Statuses, 3)
+	s.Equal(http.StatusFailedDependency, res.Statuses[0].Status)
+	s.Equal(http.StatusForbidden, res.Statuses[1].Status)
+	s.Equal(http.StatusFailedDependency, res.Statuses[2].Status)
+
+	properties, err := service.NewFileService().ListProperties(file.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(properties)
+}
+
+func (s *FileServiceTestSuite) TestListPropertiesMany() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	fileA, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file A.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	fileB, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file B.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewFileService().PatchProperties(fileA.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("red")},
+		},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	res, err := service.NewFileService().ListPropertiesMany(dto.FileListPropertiesManyOptions{
+		IDs: []string{fileA.ID, fileB.ID, helper.NewID()},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Len(res, 2)
+	s.Require().Len(res[fileA.ID], 1)
+	s.Equal("red", *res[fileA.ID][0].Value)
+	s.Empty(res[fileB.ID])
+}
+
+func (s *FileServiceTestSuite) TestListPropertiesMany_MissingPermission() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	s.revokeUserPermissionForFile(file, s.users[0])
+
+	res, err := service.NewFileService().ListPropertiesMany(dto.FileListPropertiesManyOptions{
+		IDs: []string{file.ID},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(res)
+}
+
+func (s *FileServiceTestSuite) TestListProperties_MissingPermission() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	s.revokeUserPermissionForFile(file, s.users[0])
+
+	_, err = service.NewFileService().ListProperties(file.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(err).Error(), err.Error())
+}
+
+func (s *FileServiceTestSuite) TestPatchProperties_InsufficientPermission() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	s.grantUserPermissionForFile(file, s.users[0], model.PermissionViewer)
+
+	_, err = service.NewFileService().PatchProperties(file.ID, dto.FilePatchPropertiesOptions{
+		Operations: []dto.FilePropertyOperation{
+			{Action: dto.FilePropertyActionSet, Namespace: "http://example.com/ns", Name: "color", Value: helper.ToPtr("red")},
+		},
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(
+		errorpkg.NewFilePermissionError(
+			s.users[0].GetID(),
+			cache.NewFileCache(
+				config.GetConfig().Postgres,
+				config.GetConfig().Redis,
+				config.GetConfig().Environment,
+			).GetOrNil(file.ID),
+			model.PermissionEditor,
+		).Error(),
+		err.Error(),
+	)
+}
+
 func (s *FileServiceTestSuite) TestGrantUserPermission() {
 	org, err := test.CreateOrganization(s.users[0].GetID())
 	s.Require().NoError(err)
@@ -1992,6 +2357,31 @@ func (s *FileServiceTestSuite) TestRevokeGroupPermission_InsufficientFilePermiss
 	)
 }
 
+func (s *FileServiceTestSuite) TestStore_LabelAndNote() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	file, err = service.NewFileService().Store(file.ID, service.FileStoreOptions{
+		Path:  helper.ToPtr(filepath.Join("fixtures", "files", "file.txt")),
+		Label: helper.ToPtr("signed contract"),
+		Note:  helper.ToPtr("Signed by both parties."),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("signed contract", *file.Snapshot.Label)
+	s.Equal("Signed by both parties.", *file.Snapshot.Note)
+	s.Equal(s.users[0].GetID(), *file.Snapshot.UserID)
+	s.False(file.Snapshot.IsPinned)
+}
+
 func (s *FileServiceTestSuite) TestReprocess() {
 	org, err := test.CreateOrganization(s.users[0].GetID())
 	s.Require().NoError(err)
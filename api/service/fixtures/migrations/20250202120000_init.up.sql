@@ -18,7 +18,9 @@ CREATE TABLE "snapshot"
     ocr         jsonb NULL,
     entities    jsonb NULL,
     mosaic      jsonb NULL,
+    hls         jsonb NULL,
     thumbnail   jsonb NULL,
+    metadata    jsonb NULL,
     "language"  text  NULL,
     status      text  NULL,
     task_id     text  NULL,
@@ -26,6 +28,10 @@ CREATE TABLE "snapshot"
     update_time text  NULL,
     summary     text  NULL,
     intent      text  NULL,
+    is_pinned   bool  NOT NULL DEFAULT false,
+    label       text  NULL,
+    note        text  NULL,
+    user_id     text  NULL,
     CONSTRAINT snapshot_pkey PRIMARY KEY (id)
 );
 
@@ -191,3 +197,193 @@ ALTER TABLE workspace
     ADD CONSTRAINT workspace_organization_id_fkey FOREIGN KEY (organization_id) REFERENCES organization (id) ON DELETE CASCADE;
 ALTER TABLE workspace
     ADD CONSTRAINT workspace_root_id_fkey FOREIGN KEY (root_id) REFERENCES file (id);
+
+CREATE TABLE file_property
+(
+    id          text NOT NULL,
+    file_id     text NOT NULL,
+    namespace   text NOT NULL,
+    "name"      text NOT NULL,
+    "value"     text NULL,
+    create_time text NOT NULL,
+    update_time text NULL,
+    CONSTRAINT file_property_pkey PRIMARY KEY (id),
+    CONSTRAINT file_property_file_id_fkey FOREIGN KEY (file_id) REFERENCES file (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX file_property_file_id_namespace_name_idx ON file_property USING btree (file_id, namespace, "name");
+
+CREATE TABLE upload_session
+(
+    id            text NOT NULL,
+    user_id       text NOT NULL,
+    workspace_id  text NOT NULL,
+    parent_id     text NULL,
+    file_id       text NULL,
+    "name"        text NOT NULL,
+    "size"        int8 NOT NULL,
+    content_type  text NOT NULL,
+    bucket        text NOT NULL,
+    "key"         text NOT NULL,
+    snapshot_id   text NOT NULL,
+    s3_upload_id  text NOT NULL,
+    status        text NOT NULL DEFAULT 'uploading',
+    expiry_time   text NOT NULL,
+    create_time   text NOT NULL,
+    update_time   text NULL,
+    CONSTRAINT upload_session_pkey PRIMARY KEY (id)
+);
+CREATE INDEX upload_session_expiry_time_idx ON upload_session USING btree (expiry_time);
+
+CREATE TABLE upload_session_part
+(
+    session_id  text NOT NULL,
+    "number"    int4 NOT NULL,
+    "size"      int8 NOT NULL,
+    etag        text NOT NULL,
+    create_time text NOT NULL,
+    CONSTRAINT upload_session_part_pkey PRIMARY KEY (session_id, "number"),
+    CONSTRAINT upload_session_part_session_id_fkey FOREIGN KEY (session_id) REFERENCES upload_session (id) ON DELETE CASCADE
+);
+
+CREATE TABLE share_link
+(
+    id             text NOT NULL,
+    file_id        text NOT NULL,
+    workspace_id   text NOT NULL,
+    user_id        text NOT NULL,
+    token          text NOT NULL,
+    "mode"         text NOT NULL,
+    password_hash  text NULL,
+    expiry_time    text NULL,
+    max_downloads  int4 NULL,
+    download_count int4 NOT NULL DEFAULT 0,
+    revoke_time    text NULL,
+    create_time    text NOT NULL,
+    update_time    text NULL,
+    CONSTRAINT share_link_pkey PRIMARY KEY (id),
+    CONSTRAINT share_link_token_key UNIQUE (token),
+    CONSTRAINT share_link_file_id_fkey FOREIGN KEY (file_id) REFERENCES file (id) ON DELETE CASCADE
+);
+CREATE INDEX share_link_file_id_idx ON share_link USING btree (file_id);
+
+CREATE TABLE share_link_access
+(
+    id            text NOT NULL,
+    share_link_id text NOT NULL,
+    file_id       text NULL,
+    "action"      text NOT NULL,
+    granted       bool NOT NULL,
+    error_code    text NULL,
+    ip            text NULL,
+    user_agent    text NULL,
+    create_time   text NOT NULL,
+    CONSTRAINT share_link_access_pkey PRIMARY KEY (id),
+    CONSTRAINT share_link_access_share_link_id_fkey FOREIGN KEY (share_link_id) REFERENCES share_link (id) ON DELETE CASCADE
+);
+CREATE INDEX share_link_access_share_link_id_create_time_idx ON share_link_access USING btree (share_link_id, create_time);
+
+CREATE TABLE webhook_subscription
+(
+    id           text  NOT NULL,
+    workspace_id text  NOT NULL,
+    user_id      text  NOT NULL,
+    url          text  NOT NULL,
+    secret       text  NOT NULL,
+    events       jsonb NOT NULL,
+    is_active    bool  NOT NULL DEFAULT true,
+    create_time  text  NOT NULL,
+    update_time  text  NULL,
+    CONSTRAINT webhook_subscription_pkey PRIMARY KEY (id),
+    CONSTRAINT webhook_subscription_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE
+);
+CREATE INDEX webhook_subscription_workspace_id_idx ON webhook_subscription USING btree (workspace_id);
+
+CREATE TABLE webhook_delivery
+(
+    id                text NOT NULL,
+    subscription_id   text NOT NULL,
+    event_type        text NOT NULL,
+    payload           text NOT NULL,
+    status            text NOT NULL,
+    attempts          int4 NOT NULL DEFAULT 0,
+    next_attempt_time text NOT NULL,
+    last_attempt_time text NULL,
+    response_status   int4 NULL,
+    response_body     text NULL,
+    error             text NULL,
+    create_time       text NOT NULL,
+    update_time       text NULL,
+    CONSTRAINT webhook_delivery_pkey PRIMARY KEY (id),
+    CONSTRAINT webhook_delivery_subscription_id_fkey FOREIGN KEY (subscription_id) REFERENCES webhook_subscription (id) ON DELETE CASCADE
+);
+CREATE INDEX webhook_delivery_status_next_attempt_time_idx ON webhook_delivery USING btree (status, next_attempt_time);
+CREATE INDEX webhook_delivery_subscription_id_create_time_idx ON webhook_delivery USING btree (subscription_id, create_time);
+
+CREATE TABLE trash_item
+(
+    id                 text NOT NULL,
+    file_id            text NOT NULL,
+    workspace_id       text NOT NULL,
+    user_id            text NOT NULL,
+    original_parent_id text NULL,
+    original_path      text NOT NULL,
+    create_time        text NOT NULL,
+    CONSTRAINT trash_item_pkey PRIMARY KEY (id),
+    CONSTRAINT trash_item_file_id_key UNIQUE (file_id),
+    CONSTRAINT trash_item_file_id_fkey FOREIGN KEY (file_id) REFERENCES file (id) ON DELETE CASCADE,
+    CONSTRAINT trash_item_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE
+);
+CREATE INDEX trash_item_workspace_id_create_time_idx ON trash_item USING btree (workspace_id, create_time);
+CREATE INDEX trash_item_create_time_idx ON trash_item USING btree (create_time);
+
+CREATE TABLE snapshot_retention_policy
+(
+    id                 text NOT NULL,
+    workspace_id       text NOT NULL,
+    keep_last          int4 NULL,
+    keep_all_days      int4 NULL,
+    keep_daily_days    int4 NULL,
+    keep_weekly_weeks  int4 NULL,
+    last_run_time      text NULL,
+    last_deleted_count int4 NULL,
+    last_freed_bytes   int8 NULL,
+    create_time        text NOT NULL,
+    update_time        text NULL,
+    CONSTRAINT snapshot_retention_policy_pkey PRIMARY KEY (id),
+    CONSTRAINT snapshot_retention_policy_workspace_id_key UNIQUE (workspace_id),
+    CONSTRAINT snapshot_retention_policy_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE
+);
+
+CREATE TABLE snapshot_diff
+(
+    id                 text NOT NULL,
+    base_snapshot_id   text NOT NULL,
+    target_snapshot_id text NOT NULL,
+    type               text NOT NULL,
+    artifact           jsonb NULL,
+    score              float8 NULL,
+    task_id            text NULL,
+    create_time        text NOT NULL,
+    update_time        text NULL,
+    CONSTRAINT snapshot_diff_pkey PRIMARY KEY (id),
+    CONSTRAINT snapshot_diff_base_snapshot_id_fkey FOREIGN KEY (base_snapshot_id) REFERENCES snapshot (id) ON DELETE CASCADE,
+    CONSTRAINT snapshot_diff_target_snapshot_id_fkey FOREIGN KEY (target_snapshot_id) REFERENCES snapshot (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX snapshot_diff_base_snapshot_id_target_snapshot_id_idx ON snapshot_diff USING btree (base_snapshot_id, target_snapshot_id);
+CREATE INDEX snapshot_diff_target_snapshot_id_idx ON snapshot_diff USING btree (target_snapshot_id);
+
+CREATE TABLE snapshot_chunk
+(
+    id           text NOT NULL,
+    snapshot_id  text NOT NULL,
+    ordinal      int4 NOT NULL,
+    start_offset int4 NOT NULL,
+    end_offset   int4 NOT NULL,
+    "text"       text NOT NULL,
+    embedding    bytea NOT NULL,
+    model        text NOT NULL,
+    create_time  text NOT NULL,
+    CONSTRAINT snapshot_chunk_pkey PRIMARY KEY (id),
+    CONSTRAINT snapshot_chunk_snapshot_id_fkey FOREIGN KEY (snapshot_id) REFERENCES snapshot (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX snapshot_chunk_snapshot_id_ordinal_idx ON snapshot_chunk USING btree (snapshot_id, ordinal);
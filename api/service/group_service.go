@@ -502,22 +502,11 @@ func (svc *GroupService) authorize(data []model.Group, userID string) ([]model.G
 }
 
 func (svc *GroupService) authorizeIDs(ids []string, userID string) ([]model.Group, error) {
-	var res []model.Group
-	for _, id := range ids {
-		g, err := svc.groupCache.Get(id)
-		if err != nil {
-			var e *errorpkg.ErrorResponse
-			if errors.As(err, &e) && e.Code == errorpkg.NewGroupNotFoundError(nil).Code {
-				continue
-			} else {
-				return nil, err
-			}
-		}
-		if svc.groupGuard.IsAuthorized(userID, g, model.PermissionViewer) {
-			res = append(res, g)
-		}
+	data, err := svc.groupCache.GetMany(ids)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return svc.authorize(data, userID)
 }
 
 func (svc *GroupService) sort(data []model.Group, sortBy string, sortOrder string) []model.Group {
@@ -100,7 +100,7 @@ func (svc *MosaicService) Create(fileID string, userID string) (*dto.Task, error
 	if err := svc.snapshotSvc.saveAndSync(snapshot); err != nil {
 		return nil, err
 	}
-	if err := svc.runPipeline(snapshot, task); err != nil {
+	if err := svc.runPipeline(file, snapshot, task); err != nil {
 		return nil, err
 	}
 	res, err := svc.taskMapper.Map(task)
@@ -151,7 +151,9 @@ func (svc *MosaicService) Delete(fileID string, userID string) (*dto.Task, error
 	return res, nil
 }
 
-func (svc *MosaicService) GetMetadata(fileID string, userID string) (*dto.MosaicMetadata, error) {
+// GetMetadata returns the metadata of the mosaic of the file, or of one of its
+// pages when page is set and the file is a document.
+func (svc *MosaicService) GetMetadata(fileID string, page *int, userID string) (*dto.MosaicMetadata, error) {
 	file, err := svc.fileCache.Get(fileID)
 	if err != nil {
 		return nil, err
@@ -172,6 +174,7 @@ func (svc *MosaicService) GetMetadata(fileID string, userID string) (*dto.Mosaic
 	res, err := svc.mosaicClient.GetMetadata(client.MosaicGetMetadataOptions{
 		S3Key:    filepath.FromSlash(snapshot.GetID()),
 		S3Bucket: snapshot.GetPreview().Bucket,
+		Page:     page,
 	})
 	if err != nil {
 		return nil, err
@@ -184,6 +187,7 @@ type MosaicDownloadTileOptions struct {
 	Row       int
 	Column    int
 	Extension string
+	Page      *int
 }
 
 func (svc *MosaicService) DownloadTileBuffer(fileID string, opts MosaicDownloadTileOptions, userID string) ([]byte, model.Snapshot, error) {
@@ -211,6 +215,7 @@ func (svc *MosaicService) DownloadTileBuffer(fileID string, opts MosaicDownloadT
 		Row:       opts.Row,
 		Column:    opts.Column,
 		Extension: opts.Extension,
+		Page:      opts.Page,
 	})
 	if err != nil {
 		return nil, nil, err
@@ -218,15 +223,123 @@ func (svc *MosaicService) DownloadTileBuffer(fileID string, opts MosaicDownloadT
 	return res, snapshot, err
 }
 
-func (svc *MosaicService) runPipeline(snapshot model.Snapshot, task model.Task) error {
+// GetIIIFInfo returns the IIIF info.json of the mosaic of the file, id is the
+// public base URI of the image service.
+func (svc *MosaicService) GetIIIFInfo(fileID string, page *int, id string, userID string) (*dto.MosaicIIIFInfo, error) {
+	snapshot, err := svc.getSnapshotWithMosaic(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mosaicClient.GetIIIFInfo(client.MosaicGetIIIFInfoOptions{
+		S3Key:    filepath.FromSlash(snapshot.GetID()),
+		S3Bucket: snapshot.GetPreview().Bucket,
+		Page:     page,
+		ID:       id,
+	})
+}
+
+type MosaicDownloadIIIFImageOptions struct {
+	Region   string
+	Size     string
+	Rotation string
+	Quality  string
+	Format   string
+	Page     *int
+}
+
+func (svc *MosaicService) DownloadIIIFImageBuffer(fileID string, opts MosaicDownloadIIIFImageOptions, userID string) ([]byte, error) {
+	snapshot, err := svc.getSnapshotWithMosaic(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mosaicClient.DownloadIIIFImageBuffer(client.MosaicDownloadIIIFImageOptions{
+		S3Key:    filepath.FromSlash(snapshot.GetID()),
+		S3Bucket: snapshot.GetPreview().Bucket,
+		Page:     opts.Page,
+		Region:   opts.Region,
+		Size:     opts.Size,
+		Rotation: opts.Rotation,
+		Quality:  opts.Quality,
+		Format:   opts.Format,
+	})
+}
+
+func (svc *MosaicService) GetDeepZoomDescriptorBuffer(fileID string, page *int, userID string) ([]byte, error) {
+	snapshot, err := svc.getSnapshotWithMosaic(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mosaicClient.GetDeepZoomDescriptorBuffer(client.MosaicGetDeepZoomDescriptorOptions{
+		S3Key:    filepath.FromSlash(snapshot.GetID()),
+		S3Bucket: snapshot.GetPreview().Bucket,
+		Page:     page,
+	})
+}
+
+type MosaicDownloadDeepZoomTileOptions struct {
+	Level  int
+	Column int
+	Row    int
+	Format string
+	Page   *int
+}
+
+func (svc *MosaicService) DownloadDeepZoomTileBuffer(fileID string, opts MosaicDownloadDeepZoomTileOptions, userID string) ([]byte, error) {
+	snapshot, err := svc.getSnapshotWithMosaic(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mosaicClient.DownloadDeepZoomTileBuffer(client.MosaicDownloadDeepZoomTileOptions{
+		S3Key:    filepath.FromSlash(snapshot.GetID()),
+		S3Bucket: snapshot.GetPreview().Bucket,
+		Page:     opts.Page,
+		Level:    opts.Level,
+		Column:   opts.Column,
+		Row:      opts.Row,
+		Format:   opts.Format,
+	})
+}
+
+// GetSnapshot returns the active snapshot of the file, which identifies the
+// version of the mosaic, provided the user can view it and it has a mosaic.
+func (svc *MosaicService) GetSnapshot(fileID string, userID string) (model.Snapshot, error) {
+	return svc.getSnapshotWithMosaic(fileID, userID)
+}
+
+// getSnapshotWithMosaic returns the active snapshot of a file the user can
+// view, provided the snapshot has a mosaic.
+func (svc *MosaicService) getSnapshotWithMosaic(fileID string, userID string) (model.Snapshot, error) {
+	file, err := svc.fileCache.Get(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	if file.GetType() != model.FileTypeFile || file.GetSnapshotID() == nil {
+		return nil, errorpkg.NewFileIsNotAFileError(file)
+	}
+	snapshot, err := svc.snapshotCache.Get(*file.GetSnapshotID())
+	if err != nil {
+		return nil, err
+	}
+	if !snapshot.HasMosaic() {
+		return nil, errorpkg.NewMosaicNotFoundError(nil)
+	}
+	return snapshot, nil
+}
+
+func (svc *MosaicService) runPipeline(file model.File, snapshot model.Snapshot, task model.Task) error {
 	if err := svc.pipelineClient.Run(&dto.PipelineRunOptions{
-		PipelineID: helper.ToPtr(dto.PipelineMosaic),
-		TaskID:     helper.ToPtr(task.GetID()),
-		SnapshotID: snapshot.GetID(),
-		Bucket:     snapshot.GetPreview().Bucket,
-		Key:        snapshot.GetPreview().Key,
-		Intent:     snapshot.GetIntent(),
-		Language:   snapshot.GetLanguage(),
+		PipelineID:  helper.ToPtr(dto.PipelineMosaic),
+		TaskID:      helper.ToPtr(task.GetID()),
+		SnapshotID:  snapshot.GetID(),
+		WorkspaceID: helper.ToPtr(file.GetWorkspaceID()),
+		Priority:    helper.ToPtr(dto.PipelinePriorityInteractive),
+		Bucket:      snapshot.GetPreview().Bucket,
+		Key:         snapshot.GetPreview().Key,
+		Intent:      snapshot.GetIntent(),
+		Language:    snapshot.GetLanguage(),
 	}); err != nil {
 		return err
 	}
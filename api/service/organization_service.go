@@ -473,22 +473,11 @@ func (svc *OrganizationService) authorize(data []model.Organization, userID stri
 }
 
 func (svc *OrganizationService) authorizeIDs(ids []string, userID string) ([]model.Organization, error) {
-	var res []model.Organization
-	for _, id := range ids {
-		o, err := svc.orgCache.Get(id)
-		if err != nil {
-			var e *errorpkg.ErrorResponse
-			if errors.As(err, &e) && e.Code == errorpkg.NewOrganizationNotFoundError(nil).Code {
-				continue
-			} else {
-				return nil, err
-			}
-		}
-		if svc.orgGuard.IsAuthorized(userID, o, model.PermissionViewer) {
-			res = append(res, o)
-		}
+	data, err := svc.orgCache.GetMany(ids)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return svc.authorize(data, userID)
 }
 
 func (svc *OrganizationService) sort(data []model.Organization, sortBy string, sortOrder string) []model.Organization {
@@ -0,0 +1,461 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/mapper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+	"github.com/kouprlabs/voltaserve/shared/search"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+// SearchIndexBatchSize is the number of rows read from Postgres and sent to the
+// search engine at once.
+const SearchIndexBatchSize = 500
+
+// SearchIndexBackfillLeaseDuration bounds how long a replica that died while
+// backfilling keeps the others from doing it.
+const SearchIndexBackfillLeaseDuration = 1 * time.Hour
+
+type SearchIndexService struct {
+	indexers   map[string]*searchIndexer
+	taskSvc    *TaskService
+	taskMapper *mapper.TaskMapper
+	redis      *infra.RedisManager
+}
+
+// searchIndexer binds an index to the repo its documents come from.
+type searchIndexer struct {
+	count        func() (int64, error)
+	findIDsAfter func(id string, limit int) ([]string, error)
+	index        func(ids []string) error
+	findIDs      func() ([]string, error)
+	delete       func(ids []string) error
+	// prepare is called before reindexing, it is optional.
+	prepare func() error
+}
+
+func NewSearchIndexService() *SearchIndexService {
+	fileRepo := repo.NewFileRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	fileSearch := search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	)
+	groupRepo := repo.NewGroupRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	groupSearch := search.NewGroupSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	workspaceRepo := repo.NewWorkspaceRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	workspaceSearch := search.NewWorkspaceSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	orgRepo := repo.NewOrganizationRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	orgSearch := search.NewOrganizationSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	taskRepo := repo.NewTaskRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	taskSearch := search.NewTaskSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	userRepo := repo.NewUserRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	userSearch := search.NewUserSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	// The rows deleted since their IDs were read are skipped
+	svc := &SearchIndexService{
+		indexers: map[string]*searchIndexer{
+			dto.SearchIndexFile: {
+				count:        fileRepo.Count,
+				findIDsAfter: fileRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var files []model.File
+					for _, id := range ids {
+						if f := fileRepo.FindOrNil(id); f != nil {
+							files = append(files, f)
+						}
+					}
+					return fileSearch.Index(files)
+				},
+				findIDs: fileSearch.FindIDs,
+				delete:  fileSearch.Delete,
+			},
+			dto.SearchIndexGroup: {
+				count:        groupRepo.Count,
+				findIDsAfter: groupRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var groups []model.Group
+					for _, id := range ids {
+						if g := groupRepo.FindOrNil(id); g != nil {
+							groups = append(groups, g)
+						}
+					}
+					return groupSearch.Index(groups)
+				},
+				findIDs: groupSearch.FindIDs,
+				delete:  groupSearch.Delete,
+			},
+			dto.SearchIndexWorkspace: {
+				count:        workspaceRepo.Count,
+				findIDsAfter: workspaceRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var workspaces []model.Workspace
+					for _, id := range ids {
+						if w := workspaceRepo.FindOrNil(id); w != nil {
+							workspaces = append(workspaces, w)
+						}
+					}
+					return workspaceSearch.Index(workspaces)
+				},
+				findIDs: workspaceSearch.FindIDs,
+				delete:  workspaceSearch.Delete,
+			},
+			dto.SearchIndexOrganization: {
+				count:        orgRepo.Count,
+				findIDsAfter: orgRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var orgs []model.Organization
+					for _, id := range ids {
+						if o := orgRepo.FindOrNil(id); o != nil {
+							orgs = append(orgs, o)
+						}
+					}
+					return orgSearch.Index(orgs)
+				},
+				findIDs: orgSearch.FindIDs,
+				delete:  orgSearch.Delete,
+			},
+			dto.SearchIndexTask: {
+				count:        taskRepo.Count,
+				findIDsAfter: taskRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var tasks []model.Task
+					for _, id := range ids {
+						if t := taskRepo.FindOrNil(id); t != nil {
+							tasks = append(tasks, t)
+						}
+					}
+					return taskSearch.Index(tasks)
+				},
+				findIDs: taskSearch.FindIDs,
+				delete:  taskSearch.Delete,
+			},
+			dto.SearchIndexUser: {
+				count:        userRepo.Count,
+				findIDsAfter: userRepo.FindIDsAfter,
+				index: func(ids []string) error {
+					var users []model.User
+					for _, id := range ids {
+						if u := userRepo.FindOrNil(id); u != nil {
+							users = append(users, u)
+						}
+					}
+					return userSearch.Index(users)
+				},
+				findIDs: userSearch.FindIDs,
+				delete:  userSearch.Delete,
+			},
+		},
+		taskSvc: NewTaskService(),
+		taskMapper: mapper.NewTaskMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		redis: infra.NewRedisManager(config.GetConfig().Redis),
+	}
+	if config.GetConfig().Embedding.Enabled {
+		chunkRepo := repo.NewSnapshotChunkRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+		chunkSearch := search.NewSnapshotChunkSearch(
+			config.GetConfig().Search,
+			config.GetConfig().Embedding,
+			config.GetConfig().Environment,
+		)
+		// The vectors are read from Postgres, only the texts without chunks
+		// are sent to the embedder
+		svc.indexers[dto.SearchIndexSnapshotChunk] = &searchIndexer{
+			count:        chunkRepo.Count,
+			findIDsAfter: chunkRepo.FindIDsAfter,
+			index: func(ids []string) error {
+				chunks, err := chunkRepo.FindMany(ids)
+				if err != nil {
+					return err
+				}
+				return chunkSearch.Index(chunks)
+			},
+			findIDs: chunkSearch.FindIDs,
+			delete:  chunkSearch.Delete,
+			prepare: newFileSemanticSearch().backfill,
+		}
+	}
+	return svc
+}
+
+// Reindex rebuilds the indexes in the background from the rows of Postgres,
+// the progress is reported by the returned task, which is deleted once done.
+func (svc *SearchIndexService) Reindex(opts dto.SearchIndexReindexOptions, userID string, isAdmin bool) (*dto.Task, error) {
+	if !isAdmin {
+		return nil, errorpkg.NewUserIsNotAdminError()
+	}
+	task, err := svc.taskSvc.insertAndSync(repo.TaskInsertOptions{
+		ID:         helper.NewID(),
+		Name:       "Reindexing search.",
+		UserID:     userID,
+		Percentage: helper.ToPtr(0),
+		Status:     model.TaskStatusRunning,
+	})
+	if err != nil {
+		return nil, err
+	}
+	go svc.reindex(svc.getIndexes(opts.Indexes), task)
+	res, err := svc.taskMapper.Map(task)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Check compares the IDs of the rows of Postgres with the IDs of the documents
+// of the indexes, and optionally fixes the drift.
+func (svc *SearchIndexService) Check(opts dto.SearchIndexCheckOptions, isAdmin bool) (*dto.SearchIndexCheckResult, error) {
+	if !isAdmin {
+		return nil, errorpkg.NewUserIsNotAdminError()
+	}
+	res := &dto.SearchIndexCheckResult{Indexes: make([]*dto.SearchIndexDrift, 0)}
+	for _, name := range svc.getIndexes(opts.Indexes) {
+		drift, err := svc.findDrift(name)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Fix {
+			if err := svc.fixDrift(name, drift); err != nil {
+				return nil, err
+			}
+			drift.Fixed = true
+		}
+		res.Indexes = append(res.Indexes, drift)
+	}
+	return res, nil
+}
+
+// StartBackfill runs ReindexFiles in the background, once per deployment, so
+// that the files indexed before the documents carried the principals that the
+// search is filtered on can be found again. Replicas take a lease so that only
+// one of them does it.
+func (svc *SearchIndexService) StartBackfill() {
+	go func() {
+		if err := svc.backfill(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}()
+}
+
+// ReindexFiles indexes all the files again from the rows of Postgres.
+func (svc *SearchIndexService) ReindexFiles() error {
+	indexer := svc.indexers[dto.SearchIndexFile]
+	var after string
+	for {
+		ids, err := indexer.findIDsAfter(after, SearchIndexBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := indexer.index(ids); err != nil {
+			return err
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func (svc *SearchIndexService) backfill() error {
+	key := "search_index_backfill:file_principals"
+	if _, err := svc.redis.Get(key); err == nil {
+		return nil
+	} else if !errors.Is(err, redis.Nil) {
+		return err
+	}
+	token := helper.NewID()
+	acquired, err := svc.redis.SetNX(key+":lease", token, SearchIndexBackfillLeaseDuration)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+	defer func() {
+		if _, err := svc.redis.DeleteIfEqual(key+":lease", token); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}()
+	if err := svc.ReindexFiles(); err != nil {
+		return err
+	}
+	logger.GetLogger().Info("Backfilled the principals of the file index.")
+	return svc.redis.Set(key, helper.NewTimeString())
+}
+
+func (svc *SearchIndexService) reindex(indexes []string, task model.Task) {
+	if err := svc.performReindex(indexes, task); err != nil {
+		value := err.Error()
+		task.SetError(&value)
+		task.SetStatus(model.TaskStatusError)
+		if err := svc.taskSvc.saveAndSync(task); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		return
+	}
+	if err := svc.taskSvc.deleteAndSync(task.GetID()); err != nil {
+		logger.GetLogger().Error(err)
+	}
+}
+
+func (svc *SearchIndexService) performReindex(indexes []string, task model.Task) error {
+	for _, name := range indexes {
+		if prepare := svc.indexers[name].prepare; prepare != nil {
+			if err := prepare(); err != nil {
+				return err
+			}
+		}
+	}
+	var total int64
+	for _, name := range indexes {
+		count, err := svc.indexers[name].count()
+		if err != nil {
+			return err
+		}
+		total += count
+	}
+	var done int64
+	for _, name := range indexes {
+		indexer := svc.indexers[name]
+		task.SetPayload(map[string]string{repo.TaskPayloadObjectKey: name})
+		var after string
+		for {
+			ids, err := indexer.findIDsAfter(after, SearchIndexBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := indexer.index(ids); err != nil {
+				return err
+			}
+			after = ids[len(ids)-1]
+			done += int64(len(ids))
+			task.SetPercentage(helper.ToPtr(svc.percentage(done, total)))
+			if err := svc.taskSvc.saveAndSync(task); err != nil {
+				return err
+			}
+		}
+		// The documents without a row are only found once everything is indexed
+		drift, err := svc.findDrift(name)
+		if err != nil {
+			return err
+		}
+		if err := indexer.delete(drift.Orphaned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc *SearchIndexService) findDrift(name string) (*dto.SearchIndexDrift, error) {
+	indexer := svc.indexers[name]
+	rowIDs := make(map[string]bool)
+	var after string
+	for {
+		ids, err := indexer.findIDsAfter(after, SearchIndexBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		for _, id := range ids {
+			rowIDs[id] = true
+		}
+		after = ids[len(ids)-1]
+	}
+	documentIDs, err := indexer.findIDs()
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.SearchIndexDrift{
+		Index:    name,
+		Missing:  make([]string, 0),
+		Orphaned: make([]string, 0),
+	}
+	indexed := make(map[string]bool)
+	for _, id := range documentIDs {
+		indexed[id] = true
+		if !rowIDs[id] {
+			res.Orphaned = append(res.Orphaned, id)
+		}
+	}
+	for id := range rowIDs {
+		if !indexed[id] {
+			res.Missing = append(res.Missing, id)
+		}
+	}
+	slices.Sort(res.Missing)
+	slices.Sort(res.Orphaned)
+	return res, nil
+}
+
+func (svc *SearchIndexService) fixDrift(name string, drift *dto.SearchIndexDrift) error {
+	indexer := svc.indexers[name]
+	for ids := range slices.Chunk(drift.Missing, SearchIndexBatchSize) {
+		if err := indexer.index(ids); err != nil {
+			return err
+		}
+	}
+	if err := indexer.delete(drift.Orphaned); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getIndexes skips the indexes that are not available, like the chunks when
+// the semantic search is disabled.
+func (svc *SearchIndexService) getIndexes(indexes []string) []string {
+	if len(indexes) == 0 {
+		indexes = []string{
+			dto.SearchIndexFile,
+			dto.SearchIndexGroup,
+			dto.SearchIndexWorkspace,
+			dto.SearchIndexOrganization,
+			dto.SearchIndexTask,
+			dto.SearchIndexUser,
+			dto.SearchIndexSnapshotChunk,
+		}
+	} else {
+		indexes = slices.Compact(slices.Sorted(slices.Values(indexes)))
+	}
+	return slices.DeleteFunc(indexes, func(name string) bool {
+		_, ok := svc.indexers[name]
+		return !ok
+	})
+}
+
+// percentage is capped, since rows can be inserted while reindexing.
+func (svc *SearchIndexService) percentage(done int64, total int64) int {
+	if total == 0 {
+		return 100
+	}
+	return int(min(done*100/total, 100))
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+	"github.com/kouprlabs/voltaserve/shared/search"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type SearchIndexServiceTestSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestSearchIndexServiceSuite(t *testing.T) {
+	suite.Run(t, new(SearchIndexServiceTestSuite))
+}
+
+func (s *SearchIndexServiceTestSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(1)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *SearchIndexServiceTestSuite) TestCheck() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	workspaceSearch := search.NewWorkspaceSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	s.Require().NoError(workspaceSearch.Delete([]string{workspace.ID}))
+	orphan := repo.NewWorkspaceModelWithOptions(repo.WorkspaceNewModelOptions{
+		ID:             helper.NewID(),
+		Name:           "orphan",
+		OrganizationID: org.ID,
+		CreateTime:     helper.NewTimeString(),
+	})
+	s.Require().NoError(workspaceSearch.Index([]model.Workspace{orphan}))
+
+	res, err := service.NewSearchIndexService().Check(dto.SearchIndexCheckOptions{
+		Indexes: []string{dto.SearchIndexWorkspace},
+	}, true)
+	s.Require().NoError(err)
+	s.Require().Len(res.Indexes, 1)
+	s.Equal(dto.SearchIndexWorkspace, res.Indexes[0].Index)
+	s.Contains(res.Indexes[0].Missing, workspace.ID)
+	s.Contains(res.Indexes[0].Orphaned, orphan.GetID())
+	s.False(res.Indexes[0].Fixed)
+}
+
+func (s *SearchIndexServiceTestSuite) TestCheck_Fix() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	workspaceSearch := search.NewWorkspaceSearch(config.GetConfig().Search, config.GetConfig().Environment)
+	s.Require().NoError(workspaceSearch.Delete([]string{workspace.ID}))
+
+	res, err := service.NewSearchIndexService().Check(dto.SearchIndexCheckOptions{
+		Indexes: []string{dto.SearchIndexWorkspace},
+		Fix:     true,
+	}, true)
+	s.Require().NoError(err)
+	s.Require().Len(res.Indexes, 1)
+	s.Contains(res.Indexes[0].Missing, workspace.ID)
+	s.True(res.Indexes[0].Fixed)
+
+	res, err = service.NewSearchIndexService().Check(dto.SearchIndexCheckOptions{
+		Indexes: []string{dto.SearchIndexWorkspace},
+	}, true)
+	s.Require().NoError(err)
+	s.Empty(res.Indexes[0].Missing)
+	s.Empty(res.Indexes[0].Orphaned)
+}
+
+// staleFileDocument is a file document indexed before it carried principals.
+type staleFileDocument struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspaceId"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+}
+
+func (d staleFileDocument) GetID() string {
+	return d.ID
+}
+
+func (s *SearchIndexServiceTestSuite) TestReindexFiles_Principals() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	err = infra.NewSearchManager(config.GetConfig().Search, config.GetConfig().Environment).
+		Index(infra.FileSearchIndex, []infra.SearchModel{staleFileDocument{
+			ID:          file.ID,
+			WorkspaceID: workspace.ID,
+			Name:        file.Name,
+			Type:        file.Type,
+		}})
+	s.Require().NoError(err)
+	fileSearch := search.NewFileSearch(
+		config.GetConfig().Postgres,
+		config.GetConfig().Search,
+		config.GetConfig().S3,
+		config.GetConfig().Environment,
+	)
+	opts := infra.SearchQueryOptions{
+		Limit:  10,
+		Filter: "workspaceId=\"" + workspace.ID + "\" AND " + search.FilePrincipalFilter(s.users[0].GetID(), nil),
+	}
+	hits, err := fileSearch.Query(file.Name, opts)
+	s.Require().NoError(err)
+	s.Empty(hits)
+
+	s.Require().NoError(service.NewSearchIndexService().ReindexFiles())
+
+	hits, err = fileSearch.Query(file.Name, opts)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(file.ID, hits[0].GetID())
+}
+
+func (s *SearchIndexServiceTestSuite) TestCheck_NotAdmin() {
+	_, err := service.NewSearchIndexService().Check(dto.SearchIndexCheckOptions{}, false)
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUserIsNotAdminError().Error(), err.Error())
+}
+
+func (s *SearchIndexServiceTestSuite) TestReindex_NotAdmin() {
+	_, err := service.NewSearchIndexService().Reindex(dto.SearchIndexReindexOptions{}, s.users[0].GetID(), false)
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUserIsNotAdminError().Error(), err.Error())
+}
@@ -0,0 +1,744 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/mapper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// ShareLinkTokenSize is the number of random bytes of a share link token.
+	ShareLinkTokenSize = 32
+	// ShareLinkUnlockDuration is how long a share link stays unlocked after its password was entered.
+	ShareLinkUnlockDuration = 1 * time.Hour
+	// ShareLinkDownloadSessionDuration is how long the partial requests of a visitor continue a counted download.
+	ShareLinkDownloadSessionDuration = 1 * time.Hour
+	// ShareLinkUnlockMaxAttemptsPerLink is how many wrong passwords a link accepts, from anywhere, within ShareLinkUnlockLockoutDuration.
+	ShareLinkUnlockMaxAttemptsPerLink = 50
+	// ShareLinkUnlockMaxAttemptsPerIP is how many wrong passwords an IP can send, to any link, within ShareLinkUnlockLockoutDuration.
+	ShareLinkUnlockMaxAttemptsPerIP = 10
+	// ShareLinkUnlockLockoutDuration is how long failed unlock attempts are counted.
+	ShareLinkUnlockLockoutDuration = 15 * time.Minute
+)
+
+type ShareLinkService struct {
+	shareLinkRepo *repo.ShareLinkRepo
+	fileCache     *cache.FileCache
+	fileGuard     *guard.FileGuard
+	fileRepo      *repo.FileRepo
+	fileMapper    *mapper.FileMapper
+	fileCreate    *fileCreate
+	fileCoreSvc   *fileCoreService
+	fileStore     *fileStore
+	fileList      *fileList
+	fileDownload  *fileDownload
+	workspaceSvc  *WorkspaceService
+	redis         *infra.RedisManager
+	config        *config.Config
+}
+
+func NewShareLinkService() *ShareLinkService {
+	return &ShareLinkService{
+		shareLinkRepo: repo.NewShareLinkRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileRepo: repo.NewFileRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileMapper: mapper.NewFileMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileCreate:   newFileCreate(),
+		fileCoreSvc:  newFileCoreService(),
+		fileStore:    newFileStore(),
+		fileList:     newFileList(),
+		fileDownload: newFileDownload(),
+		workspaceSvc: NewWorkspaceService(),
+		redis:        infra.NewRedisManager(config.GetConfig().Redis),
+		config:       config.GetConfig(),
+	}
+}
+
+func (svc *ShareLinkService) Create(opts dto.ShareLinkCreateOptions, userID string) (*dto.ShareLink, error) {
+	file, err := svc.fileCache.Get(opts.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	if opts.Mode == model.ShareLinkModeUpload && file.GetType() != model.FileTypeFolder {
+		return nil, errorpkg.NewFileIsNotAFolderError(file)
+	}
+	token, err := svc.newToken()
+	if err != nil {
+		return nil, err
+	}
+	insertOpts := repo.ShareLinkInsertOptions{
+		ID:           helper.NewID(),
+		FileID:       file.GetID(),
+		WorkspaceID:  file.GetWorkspaceID(),
+		UserID:       userID,
+		Token:        token,
+		Mode:         opts.Mode,
+		MaxDownloads: opts.MaxDownloads,
+	}
+	if opts.Password != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		insertOpts.PasswordHash = helper.ToPtr(string(hash))
+	}
+	if opts.ExpiryTime != nil {
+		insertOpts.ExpiryTime = helper.ToPtr(helper.TimeToString(helper.StringToTime(*opts.ExpiryTime)))
+	}
+	link, err := svc.shareLinkRepo.Insert(insertOpts)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapLink(link), nil
+}
+
+func (svc *ShareLinkService) Find(id string, userID string) (*dto.ShareLink, error) {
+	link, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapLink(link), nil
+}
+
+func (svc *ShareLinkService) List(fileID string, userID string) ([]*dto.ShareLink, error) {
+	file, err := svc.fileCache.Get(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	links, err := svc.shareLinkRepo.FindByFileID(file.GetID())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*dto.ShareLink, 0, len(links))
+	for _, l := range links {
+		res = append(res, svc.mapLink(l))
+	}
+	return res, nil
+}
+
+// Revoke disables the link for good, it is kept rather than deleted so that
+// its access log remains available.
+func (svc *ShareLinkService) Revoke(id string, userID string) (*dto.ShareLink, error) {
+	link, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if link.GetRevokeTime() == nil {
+		link.SetRevokeTime(helper.ToPtr(helper.NewTimeString()))
+		if err := svc.shareLinkRepo.Save(link); err != nil {
+			return nil, err
+		}
+	}
+	return svc.mapLink(link), nil
+}
+
+func (svc *ShareLinkService) ListAccesses(id string, page uint64, size uint64, userID string) (*dto.ShareLinkAccessList, error) {
+	link, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := svc.shareLinkRepo.CountAccesses(link.GetID())
+	if err != nil {
+		return nil, err
+	}
+	accesses, err := svc.shareLinkRepo.FindAccesses(link.GetID(), page, size)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]*dto.ShareLinkAccess, 0, len(accesses))
+	for _, a := range accesses {
+		data = append(data, &dto.ShareLinkAccess{
+			ID:         a.GetID(),
+			FileID:     a.GetFileID(),
+			Action:     a.GetAction(),
+			Granted:    a.GetGranted(),
+			ErrorCode:  a.GetErrorCode(),
+			IP:         a.GetIP(),
+			UserAgent:  a.GetUserAgent(),
+			CreateTime: a.GetCreateTime(),
+		})
+	}
+	return &dto.ShareLinkAccessList{
+		Data:          data,
+		TotalPages:    uint64(math.Ceil(float64(count) / float64(size))),
+		TotalElements: uint64(count),
+		Page:          page,
+		Size:          size,
+	}, nil
+}
+
+// ShareRequest identifies an anonymous request made through a share link.
+type ShareRequest struct {
+	// Token is the token of the share link.
+	Token string
+	// AccessToken is returned by Unlock, it is required if the link is protected by a password.
+	AccessToken string
+	IP          string
+	UserAgent   string
+}
+
+func (svc *ShareLinkService) GetShare(req ShareRequest) (res *dto.Share, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionFind, nil, err) }()
+	res = &dto.Share{
+		Mode:        link.GetMode(),
+		HasPassword: link.HasPassword(),
+		ExpiryTime:  link.GetExpiryTime(),
+	}
+	if err := svc.checkUnlocked(link, req.AccessToken); err != nil {
+		res.IsLocked = true
+		return res, nil
+	}
+	file, err := svc.fileCache.Get(link.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := svc.fileMapper.Map(file, link.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	res.File = svc.mapFile(mapped, link)
+	return res, nil
+}
+
+// Unlock checks the password of the link and returns an access token that
+// unlocks it for a limited time. The token is signed with a key derived from
+// the JWT signing key, so it can't be mistaken for a user's access token.
+func (svc *ShareLinkService) Unlock(req ShareRequest, password string) (res *dto.ShareUnlockResult, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionUnlock, nil, err) }()
+	if !link.HasPassword() {
+		return nil, errorpkg.NewShareLinkModeNotAllowedError(link)
+	}
+	if err := svc.checkUnlockAttempts(link, req); err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(*link.GetPasswordHash()), []byte(password)); err != nil {
+		svc.countUnlockAttempt(link, req)
+		return nil, errorpkg.NewInvalidShareLinkPasswordError(link)
+	}
+	expiry := time.Now().Add(ShareLinkUnlockDuration)
+	if link.GetExpiryTime() != nil && helper.StringToTime(*link.GetExpiryTime()).Before(expiry) {
+		expiry = helper.StringToTime(*link.GetExpiryTime())
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": link.GetID(),
+		"iat": time.Now().Unix(),
+		"exp": expiry.Unix(),
+	}).SignedString(svc.unlockSigningKey())
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ShareUnlockResult{
+		AccessToken: accessToken,
+		ExpiryTime:  helper.TimeToString(expiry),
+	}, nil
+}
+
+// ListFiles lists the children of a folder that is, or is inside of, the folder
+// shared by a viewer link.
+func (svc *ShareLinkService) ListFiles(req ShareRequest, id string, opts FileListOptions) (res *dto.SharedFileList, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionList, &id, err) }()
+	if err := svc.authorizeViewer(link, req, id); err != nil {
+		return nil, err
+	}
+	/* Searching covers the whole workspace, which is beyond what the link shares */
+	opts.Query = nil
+	list, err := svc.fileList.list(id, opts, link.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	res = &dto.SharedFileList{
+		Data:          make([]*dto.SharedFile, 0, len(list.Data)),
+		TotalPages:    list.TotalPages,
+		TotalElements: list.TotalElements,
+		Page:          list.Page,
+		Size:          list.Size,
+	}
+	for _, f := range list.Data {
+		res.Data = append(res.Data, svc.mapFile(f, link))
+	}
+	return res, nil
+}
+
+// DownloadOriginalBuffer downloads the original of a shared file, see
+// countDownload for how it counts towards the download limit.
+func (svc *ShareLinkService) DownloadOriginalBuffer(req ShareRequest, id string, rangeHeader string, buf *bytes.Buffer) (res *DownloadResult, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionDownloadOriginal, &id, err) }()
+	if err := svc.authorizeViewer(link, req, id); err != nil {
+		return nil, err
+	}
+	undo, err := svc.countDownload(link, req, rangeHeader == "")
+	if err != nil {
+		return nil, err
+	}
+	res, err = svc.fileDownload.downloadOriginalBuffer(id, rangeHeader, buf, link.GetUserID())
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (svc *ShareLinkService) DownloadPreviewBuffer(req ShareRequest, id string, rangeHeader string, buf *bytes.Buffer) (res *DownloadResult, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionDownloadPreview, &id, err) }()
+	if err := svc.authorizeViewer(link, req, id); err != nil {
+		return nil, err
+	}
+	undo, err := svc.countDownload(link, req, rangeHeader == "")
+	if err != nil {
+		return nil, err
+	}
+	res, err = svc.fileDownload.downloadPreviewBuffer(id, rangeHeader, buf, link.GetUserID())
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (svc *ShareLinkService) DownloadThumbnailBuffer(req ShareRequest, id string, buf *bytes.Buffer) (res model.Snapshot, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { svc.logAccess(link, req, model.ShareLinkActionDownloadThumbnail, &id, err) }()
+	if err := svc.authorizeViewer(link, req, id); err != nil {
+		return nil, err
+	}
+	/* The thumbnails of a folder come in bulk, they share the download of the session */
+	undo, err := svc.countDownload(link, req, false)
+	if err != nil {
+		return nil, err
+	}
+	res, err = svc.fileDownload.downloadThumbnailBuffer(id, buf, link.GetUserID())
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return res, nil
+}
+
+type ShareUploadOptions struct {
+	Name string
+	Path string
+	Size int64
+}
+
+// Upload drops a file into the folder shared by an upload link. The file is
+// created on behalf of the creator of the link, and renamed if the folder
+// already contains a file with the same name, since the visitor can't see it.
+func (svc *ShareLinkService) Upload(req ShareRequest, opts ShareUploadOptions) (res *dto.SharedFile, err error) {
+	link, err := svc.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		var fileID *string
+		if res != nil {
+			fileID = &res.ID
+		}
+		svc.logAccess(link, req, model.ShareLinkActionUpload, fileID, err)
+	}()
+	if link.GetMode() != model.ShareLinkModeUpload {
+		return nil, errorpkg.NewShareLinkModeNotAllowedError(link)
+	}
+	if err := svc.checkUnlocked(link, req.AccessToken); err != nil {
+		return nil, err
+	}
+	folder, err := svc.fileCache.Get(link.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.fileGuard.Authorize(link.GetUserID(), folder, model.PermissionEditor); err != nil {
+		return nil, errorpkg.NewShareLinkNotFoundError(err)
+	}
+	hasEnoughSpace, err := svc.workspaceSvc.HasEnoughSpaceForByteSize(folder.GetWorkspaceID(), opts.Size, link.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	if !hasEnoughSpace {
+		return nil, errorpkg.NewStorageLimitExceededError()
+	}
+	name, err := svc.getUploadName(folder.GetID(), opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := svc.fileCreate.create(FileCreateOptions{
+		WorkspaceID: folder.GetWorkspaceID(),
+		Name:        name,
+		Type:        model.FileTypeFile,
+		ParentID:    folder.GetID(),
+	}, link.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	file, err = svc.fileStore.store(file.ID, FileStoreOptions{Path: &opts.Path}, link.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+	return &dto.SharedFile{
+		ID:         file.ID,
+		Name:       file.Name,
+		Type:       file.Type,
+		CreateTime: file.CreateTime,
+	}, nil
+}
+
+func (svc *ShareLinkService) find(id string, userID string) (model.ShareLink, error) {
+	link, err := svc.shareLinkRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := svc.fileCache.Get(link.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// resolve finds the link of an anonymous request and checks that it is still
+// usable. A link is only as good as the permission of its creator, so it stops
+// working as soon as the creator loses access to the shared file.
+func (svc *ShareLinkService) resolve(req ShareRequest) (model.ShareLink, error) {
+	link, err := svc.shareLinkRepo.FindByToken(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.check(link); err != nil {
+		svc.logAccess(link, req, model.ShareLinkActionFind, nil, err)
+		return nil, err
+	}
+	return link, nil
+}
+
+func (svc *ShareLinkService) check(link model.ShareLink) error {
+	if link.GetRevokeTime() != nil {
+		return errorpkg.NewShareLinkRevokedError(link)
+	}
+	if link.GetExpiryTime() != nil && time.Now().After(helper.StringToTime(*link.GetExpiryTime())) {
+		return errorpkg.NewShareLinkExpiredError(link)
+	}
+	file, err := svc.fileCache.Get(link.GetFileID())
+	if err != nil {
+		return errorpkg.NewShareLinkNotFoundError(err)
+	}
+	if err := svc.fileGuard.Authorize(link.GetUserID(), file, model.PermissionViewer); err != nil {
+		return errorpkg.NewShareLinkNotFoundError(err)
+	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return errorpkg.NewShareLinkNotFoundError(err)
+	}
+	return nil
+}
+
+// authorizeViewer checks that the link lets its visitor see the file with the
+// given ID, which is either the shared file or one of its descendants.
+func (svc *ShareLinkService) authorizeViewer(link model.ShareLink, req ShareRequest, id string) error {
+	if link.GetMode() != model.ShareLinkModeViewer {
+		return errorpkg.NewShareLinkModeNotAllowedError(link)
+	}
+	if err := svc.checkUnlocked(link, req.AccessToken); err != nil {
+		return err
+	}
+	if id == link.GetFileID() {
+		return nil
+	}
+	isGrandChild, err := svc.fileRepo.IsGrandChildOf(id, link.GetFileID())
+	if err != nil {
+		return err
+	}
+	if !isGrandChild {
+		return errorpkg.NewFileNotFoundError(nil)
+	}
+	return nil
+}
+
+func (svc *ShareLinkService) checkUnlocked(link model.ShareLink, accessToken string) error {
+	if !link.HasPassword() {
+		return nil
+	}
+	if accessToken == "" {
+		return errorpkg.NewShareLinkPasswordRequiredError(link)
+	}
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return svc.unlockSigningKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return errorpkg.NewShareLinkPasswordRequiredError(link)
+	}
+	sub, err := token.Claims.GetSubject()
+	if err != nil || sub != link.GetID() {
+		return errorpkg.NewShareLinkPasswordRequiredError(link)
+	}
+	return nil
+}
+
+// checkUnlockAttempts rejects the unlock once too many wrong passwords were
+// sent to the link, or from the IP of the visitor, so that passwords can't be
+// brute-forced. The rejection is recorded in the access log like any other.
+func (svc *ShareLinkService) checkUnlockAttempts(link model.ShareLink, req ShareRequest) error {
+	limits := map[string]int{svc.unlockAttemptsLinkKey(link): ShareLinkUnlockMaxAttemptsPerLink}
+	if req.IP != "" {
+		limits[svc.unlockAttemptsIPKey(req)] = ShareLinkUnlockMaxAttemptsPerIP
+	}
+	for key, limit := range limits {
+		value, err := svc.redis.Get(key)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if count >= limit {
+			return errorpkg.NewShareLinkUnlockLockedError(link)
+		}
+	}
+	return nil
+}
+
+func (svc *ShareLinkService) countUnlockAttempt(link model.ShareLink, req ShareRequest) {
+	keys := []string{svc.unlockAttemptsLinkKey(link)}
+	if req.IP != "" {
+		keys = append(keys, svc.unlockAttemptsIPKey(req))
+	}
+	for _, key := range keys {
+		if _, err := svc.redis.IncrWithExpiry(key, ShareLinkUnlockLockoutDuration); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+}
+
+func (svc *ShareLinkService) unlockAttemptsLinkKey(link model.ShareLink) string {
+	return "share_link_unlock_attempts:link:" + link.GetID()
+}
+
+func (svc *ShareLinkService) unlockAttemptsIPKey(req ShareRequest) string {
+	return "share_link_unlock_attempts:ip:" + req.IP
+}
+
+func (svc *ShareLinkService) unlockSigningKey() []byte {
+	mac := hmac.New(sha256.New, []byte(svc.config.Security.JWTSigningKey))
+	mac.Write([]byte("share_link"))
+	return mac.Sum(nil)
+}
+
+func (svc *ShareLinkService) getUploadName(folderID string, name string) (string, error) {
+	/* Visitors can't create folders, so any path is dropped */
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "", errorpkg.NewInvalidFormFileError("file")
+	}
+	existing, err := svc.fileCoreSvc.getChildWithName(folderID, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return helper.UniqueFilename(name), nil
+	}
+	return name, nil
+}
+
+// countDownload gates every download of a rendition of the link against the
+// download limit. A visitor's session, identified by its access token, IP and
+// user agent, counts one download, which its partial requests and thumbnails
+// then continue for ShareLinkDownloadSessionDuration, whatever their range.
+// A fresh download, a request for a whole rendition, always counts a new one.
+// The returned function gives the download back when serving it failed.
+func (svc *ShareLinkService) countDownload(link model.ShareLink, req ShareRequest, fresh bool) (func(), error) {
+	key := svc.downloadSessionKey(link, req)
+	if !fresh {
+		value, err := svc.redis.Get(key)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if value != "" {
+			return func() {}, nil
+		}
+	}
+	ok, err := svc.shareLinkRepo.IncrementDownloadCount(link.GetID())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errorpkg.NewShareLinkDownloadLimitReachedError(link)
+	}
+	if err := svc.redis.SetWithExpiry(key, "1", ShareLinkDownloadSessionDuration); err != nil {
+		logger.GetLogger().Error(err)
+	}
+	return func() {
+		if err := svc.shareLinkRepo.DecrementDownloadCount(link.GetID()); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		if err := svc.redis.Delete(key); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}, nil
+}
+
+func (svc *ShareLinkService) downloadSessionKey(link model.ShareLink, req ShareRequest) string {
+	h := sha256.Sum256([]byte(req.AccessToken + "\n" + req.IP + "\n" + req.UserAgent))
+	return "share_link_download:" + link.GetID() + ":" + hex.EncodeToString(h[:])
+}
+
+func (svc *ShareLinkService) logAccess(link model.ShareLink, req ShareRequest, action string, fileID *string, err error) {
+	opts := repo.ShareLinkAccessInsertOptions{
+		ShareLinkID: link.GetID(),
+		FileID:      fileID,
+		Action:      action,
+		Granted:     err == nil,
+	}
+	if req.IP != "" {
+		opts.IP = helper.ToPtr(req.IP)
+	}
+	if req.UserAgent != "" {
+		opts.UserAgent = helper.ToPtr(req.UserAgent)
+	}
+	if err != nil {
+		var e *errorpkg.ErrorResponse
+		if errors.As(err, &e) {
+			opts.ErrorCode = helper.ToPtr(e.Code)
+		} else {
+			opts.ErrorCode = helper.ToPtr("internal_server_error")
+		}
+	}
+	if err := svc.shareLinkRepo.InsertAccess(opts); err != nil {
+		logger.GetLogger().Error(err)
+	}
+}
+
+func (svc *ShareLinkService) newToken() (string, error) {
+	b := make([]byte, ShareLinkTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (svc *ShareLinkService) mapLink(link model.ShareLink) *dto.ShareLink {
+	return &dto.ShareLink{
+		ID:            link.GetID(),
+		FileID:        link.GetFileID(),
+		Token:         link.GetToken(),
+		Mode:          link.GetMode(),
+		HasPassword:   link.HasPassword(),
+		ExpiryTime:    link.GetExpiryTime(),
+		MaxDownloads:  link.GetMaxDownloads(),
+		DownloadCount: link.GetDownloadCount(),
+		RevokeTime:    link.GetRevokeTime(),
+		CreateTime:    link.GetCreateTime(),
+		UpdateTime:    link.GetUpdateTime(),
+	}
+}
+
+func (svc *ShareLinkService) mapFile(file *dto.File, link model.ShareLink) *dto.SharedFile {
+	res := &dto.SharedFile{
+		ID:         file.ID,
+		Name:       file.Name,
+		Type:       file.Type,
+		CreateTime: file.CreateTime,
+		UpdateTime: file.UpdateTime,
+	}
+	/* The parent of the shared file is outside of the share */
+	if file.ID != link.GetFileID() {
+		res.ParentID = file.ParentID
+	}
+	if file.Snapshot != nil {
+		res.Snapshot = &dto.SharedSnapshot{
+			Original:  file.Snapshot.Original,
+			Preview:   file.Snapshot.Preview,
+			Thumbnail: file.Snapshot.Thumbnail,
+		}
+	}
+	return res
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type ShareLinkServiceTestSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestShareLinkServiceSuite(t *testing.T) {
+	suite.Run(t, new(ShareLinkServiceTestSuite))
+}
+
+func (s *ShareLinkServiceTestSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *ShareLinkServiceTestSuite) TestDownloadOriginalBuffer() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: file.ID,
+		Mode:   model.ShareLinkModeViewer,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	content, err := os.ReadFile(filepath.Join("fixtures", "files", "file.txt")) //nolint:gosec // Used for tests only
+	s.Require().NoError(err)
+	buf := new(bytes.Buffer)
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(service.ShareRequest{Token: link.Token}, file.ID, "", buf)
+	s.Require().NoError(err)
+	s.Equal(string(content), buf.String())
+
+	link, err = service.NewShareLinkService().Find(link.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(1, link.DownloadCount)
+}
+
+func (s *ShareLinkServiceTestSuite) TestDownloadOriginalBuffer_DownloadLimitReached() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:       file.ID,
+		Mode:         model.ShareLinkModeViewer,
+		MaxDownloads: helper.ToPtr(1),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	req := service.ShareRequest{Token: link.Token}
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(req, file.ID, "", new(bytes.Buffer))
+	s.Require().NoError(err)
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(req, file.ID, "", new(bytes.Buffer))
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkDownloadLimitReachedError(s.findLink(link.ID)).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestDownloadOriginalBuffer_PartialRequestAfterLimitReached() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:       file.ID,
+		Mode:         model.ShareLinkModeViewer,
+		MaxDownloads: helper.ToPtr(1),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	visitor := service.ShareRequest{Token: link.Token, IP: "192.0.2.1", UserAgent: "visitor"}
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(visitor, file.ID, "", new(bytes.Buffer))
+	s.Require().NoError(err)
+	/* The visitor whose download was counted can resume it */
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(visitor, file.ID, "bytes=1-", new(bytes.Buffer))
+	s.Require().NoError(err)
+
+	other := service.ShareRequest{Token: link.Token, IP: "192.0.2.2", UserAgent: "other"}
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(other, file.ID, "bytes=1-", new(bytes.Buffer))
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkDownloadLimitReachedError(s.findLink(link.ID)).Error(), err.Error())
+	_, err = service.NewShareLinkService().DownloadThumbnailBuffer(other, file.ID, new(bytes.Buffer))
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkDownloadLimitReachedError(s.findLink(link.ID)).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestDownloadOriginalBuffer_OutsideOfShare() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "folder",
+		Type:        model.FileTypeFolder,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	inside := s.createFile(workspace, folder.ID)
+	outside := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: folder.ID,
+		Mode:   model.ShareLinkModeViewer,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	req := service.ShareRequest{Token: link.Token}
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(req, inside.ID, "", new(bytes.Buffer))
+	s.Require().NoError(err)
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(req, outside.ID, "", new(bytes.Buffer))
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestUnlock() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:   file.ID,
+		Mode:     model.ShareLinkModeViewer,
+		Password: helper.ToPtr("secret"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.True(link.HasPassword)
+
+	share, err := service.NewShareLinkService().GetShare(service.ShareRequest{Token: link.Token})
+	s.Require().NoError(err)
+	s.True(share.IsLocked)
+	s.Nil(share.File)
+
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(service.ShareRequest{Token: link.Token}, file.ID, "", new(bytes.Buffer))
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkPasswordRequiredError(s.findLink(link.ID)).Error(), err.Error())
+
+	_, err = service.NewShareLinkService().Unlock(service.ShareRequest{Token: link.Token}, "wrong")
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewInvalidShareLinkPasswordError(s.findLink(link.ID)).Error(), err.Error())
+
+	unlock, err := service.NewShareLinkService().Unlock(service.ShareRequest{Token: link.Token}, "secret")
+	s.Require().NoError(err)
+	req := service.ShareRequest{Token: link.Token, AccessToken: unlock.AccessToken}
+	share, err = service.NewShareLinkService().GetShare(req)
+	s.Require().NoError(err)
+	s.False(share.IsLocked)
+	s.Require().NotNil(share.File)
+	s.Equal(file.ID, share.File.ID)
+	_, err = service.NewShareLinkService().DownloadOriginalBuffer(req, file.ID, "", new(bytes.Buffer))
+	s.Require().NoError(err)
+}
+
+func (s *ShareLinkServiceTestSuite) TestUnlock_TooManyAttempts() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:   file.ID,
+		Mode:     model.ShareLinkModeViewer,
+		Password: helper.ToPtr("secret"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	req := service.ShareRequest{Token: link.Token, IP: helper.NewID()}
+	for i := 0; i < service.ShareLinkUnlockMaxAttemptsPerIP; i++ {
+		_, err = service.NewShareLinkService().Unlock(req, "wrong")
+		s.Require().Error(err)
+		s.Equal(errorpkg.NewInvalidShareLinkPasswordError(s.findLink(link.ID)).Error(), err.Error())
+	}
+
+	/* Even the right password is rejected during the lockout */
+	_, err = service.NewShareLinkService().Unlock(req, "secret")
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkUnlockLockedError(s.findLink(link.ID)).Error(), err.Error())
+
+	_, err = service.NewShareLinkService().Unlock(service.ShareRequest{Token: link.Token, IP: helper.NewID()}, "secret")
+	s.Require().NoError(err)
+}
+
+func (s *ShareLinkServiceTestSuite) TestGetShare_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: file.ID,
+		Mode:   model.ShareLinkModeViewer,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewShareLinkService().GetShare(service.ShareRequest{Token: link.Token})
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestGetShare_Revoked() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: file.ID,
+		Mode:   model.ShareLinkModeViewer,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	link, err = service.NewShareLinkService().Revoke(link.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.NotNil(link.RevokeTime)
+
+	_, err = service.NewShareLinkService().GetShare(service.ShareRequest{Token: link.Token})
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkRevokedError(s.findLink(link.ID)).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestGetShare_Expired() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:     file.ID,
+		Mode:       model.ShareLinkModeViewer,
+		ExpiryTime: helper.ToPtr(helper.TimeToString(time.Now().Add(-time.Minute))),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewShareLinkService().GetShare(service.ShareRequest{Token: link.Token})
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkExpiredError(s.findLink(link.ID)).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestCreate_MissingPermission() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+
+	_, err = service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: file.ID,
+		Mode:   model.ShareLinkModeViewer,
+	}, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestUpload() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: workspace.RootID,
+		Mode:   model.ShareLinkModeUpload,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	req := service.ShareRequest{Token: link.Token}
+	opts := service.ShareUploadOptions{
+		Name: "file.txt",
+		Path: filepath.Join("fixtures", "files", "file.txt"),
+		Size: 3,
+	}
+	first, err := service.NewShareLinkService().Upload(req, opts)
+	s.Require().NoError(err)
+	s.Equal("file.txt", first.Name)
+	second, err := service.NewShareLinkService().Upload(req, opts)
+	s.Require().NoError(err)
+	s.NotEqual(first.Name, second.Name)
+
+	_, err = service.NewShareLinkService().ListFiles(req, workspace.RootID, service.FileListOptions{Page: 1, Size: 10})
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewShareLinkModeNotAllowedError(s.findLink(link.ID)).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestCreate_UploadModeRequiresFolder() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	fileModel, err := repo.NewFileRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).Find(file.ID)
+	s.Require().NoError(err)
+
+	_, err = service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID: file.ID,
+		Mode:   model.ShareLinkModeUpload,
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsNotAFolderError(fileModel).Error(), err.Error())
+}
+
+func (s *ShareLinkServiceTestSuite) TestListAccesses() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID)
+	link, err := service.NewShareLinkService().Create(dto.ShareLinkCreateOptions{
+		FileID:   file.ID,
+		Mode:     model.ShareLinkModeViewer,
+		Password: helper.ToPtr("secret"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	req := service.ShareRequest{Token: link.Token, IP: "127.0.0.1", UserAgent: "test"}
+	_, err = service.NewShareLinkService().GetShare(req)
+	s.Require().NoError(err)
+	_, err = service.NewShareLinkService().Unlock(req, "wrong")
+	s.Require().Error(err)
+
+	list, err := service.NewShareLinkService().ListAccesses(link.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 2)
+	s.Equal(uint64(2), list.TotalElements)
+	for _, a := range list.Data {
+		s.Require().NotNil(a.IP)
+		s.Equal("127.0.0.1", *a.IP)
+	}
+	var denied *dto.ShareLinkAccess
+	for _, a := range list.Data {
+		if a.Action == model.ShareLinkActionUnlock {
+			denied = a
+		}
+	}
+	s.Require().NotNil(denied)
+	s.False(denied.Granted)
+	s.Require().NotNil(denied.ErrorCode)
+	s.Equal(errorpkg.NewInvalidShareLinkPasswordError(s.findLink(link.ID)).Code, *denied.ErrorCode)
+
+	_, err = service.NewShareLinkService().ListAccesses(link.ID, 1, 10, s.users[1].GetID())
+	s.Require().Error(err)
+}
+
+func (s *ShareLinkServiceTestSuite) createFile(workspace *dto.Workspace, parentID string) *dto.File {
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    parentID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err = service.NewFileService().Store(file.ID, service.FileStoreOptions{
+		Path: helper.ToPtr(filepath.Join("fixtures", "files", "file.txt")),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	return file
+}
+
+// findLink returns the model of a link, which is what error creators take.
+func (s *ShareLinkServiceTestSuite) findLink(id string) model.ShareLink {
+	link, err := repo.NewShareLinkRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).Find(id)
+	s.Require().NoError(err)
+	return link
+}
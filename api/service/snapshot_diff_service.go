@@ -0,0 +1,440 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/mapper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// SnapshotDiffContextLines is the number of unchanged lines surrounding
+	// each hunk of a unified text diff.
+	SnapshotDiffContextLines = 3
+	// SnapshotDiffMaxTextSize caps the size in bytes of each text compared,
+	// since text diffs are computed while handling the request.
+	SnapshotDiffMaxTextSize = 512 * 1024
+)
+
+type SnapshotDiffService struct {
+	snapshotDiffRepo *repo.SnapshotDiffRepo
+	snapshotRepo     *repo.SnapshotRepo
+	snapshotCache    *cache.SnapshotCache
+	snapshotSvc      *SnapshotService
+	fileCache        *cache.FileCache
+	fileGuard        *guard.FileGuard
+	fileIdent        *infra.FileIdentifier
+	taskSvc          *TaskService
+	taskCache        *cache.TaskCache
+	taskMapper       *mapper.TaskMapper
+	s3               infra.S3Manager
+	pipelineClient   client.PipelineClient
+}
+
+func NewSnapshotDiffService() *SnapshotDiffService {
+	return &SnapshotDiffService{
+		snapshotDiffRepo: repo.NewSnapshotDiffRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		snapshotRepo: repo.NewSnapshotRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		snapshotCache: cache.NewSnapshotCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		snapshotSvc: NewSnapshotService(),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileIdent: infra.NewFileIdentifier(),
+		taskSvc:   NewTaskService(),
+		taskCache: cache.NewTaskCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		taskMapper: mapper.NewTaskMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		s3: infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		pipelineClient: client.NewPipelineClient(
+			config.GetConfig().ConversionURL,
+			config.GetConfig().Environment.IsTest,
+		),
+	}
+}
+
+// Create compares two snapshots of a file. Texts are compared right away,
+// images are compared by the conversion pipeline, in which case the returned
+// diff is processing until the pipeline reports back. Diffs are kept per pair
+// of snapshots, so comparing the same snapshots again returns the existing
+// diff, unless it failed or was cancelled.
+func (svc *SnapshotDiffService) Create(opts dto.SnapshotDiffCreateOptions, userID string) (*dto.SnapshotDiff, error) {
+	if opts.SnapshotID == opts.OtherSnapshotID {
+		return nil, errorpkg.NewSnapshotDiffSameSnapshotError(nil)
+	}
+	file, err := svc.fileCache.Get(opts.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	if file.GetType() != model.FileTypeFile {
+		return nil, errorpkg.NewFileIsNotAFileError(file)
+	}
+	ids, err := svc.snapshotRepo.FindIDsByFile(file.GetID())
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(ids, opts.SnapshotID) || !slices.Contains(ids, opts.OtherSnapshotID) {
+		return nil, errorpkg.NewSnapshotDiffFileMismatchError(nil)
+	}
+	base, err := svc.snapshotCache.Get(opts.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := svc.snapshotCache.Get(opts.OtherSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if base.GetVersion() > target.GetVersion() {
+		base, target = target, base
+	}
+	if diff := svc.snapshotDiffRepo.FindByPairOrNil(base.GetID(), target.GetID()); diff != nil {
+		if svc.getStatus(diff) != dto.SnapshotDiffStatusError {
+			return svc.mapDiff(diff)
+		}
+		if err := svc.delete(diff); err != nil {
+			return nil, err
+		}
+	}
+	if svc.isImage(base) && svc.isImage(target) {
+		return svc.createImageDiff(file, base, target, userID)
+	} else if base.HasText() && target.HasText() {
+		return svc.createTextDiff(base, target)
+	} else {
+		return nil, errorpkg.NewSnapshotDiffUnsupportedError(nil)
+	}
+}
+
+func (svc *SnapshotDiffService) Find(id string, userID string) (*dto.SnapshotDiff, error) {
+	diff, err := svc.authorize(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapDiff(diff)
+}
+
+func (svc *SnapshotDiffService) DownloadImageBuffer(id string, buf *bytes.Buffer, userID string) (model.SnapshotDiff, error) {
+	diff, err := svc.authorize(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if diff.GetType() != model.SnapshotDiffTypeImage {
+		return nil, errorpkg.NewS3ObjectNotFoundError(nil)
+	}
+	if !diff.HasArtifact() {
+		return nil, errorpkg.NewSnapshotDiffNotReadyError(nil)
+	}
+	if _, err := svc.s3.GetObjectWithBuffer(diff.GetArtifact().Key, diff.GetArtifact().Bucket, buf, minio.GetObjectOptions{}); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Patch stores the result of an image diff, it is called by the conversion
+// pipeline.
+func (svc *SnapshotDiffService) Patch(id string, opts dto.SnapshotDiffPatchOptions) error {
+	diff, err := svc.snapshotDiffRepo.Find(id)
+	if err != nil {
+		return err
+	}
+	diff.SetArtifact(opts.Artifact)
+	diff.SetScore(opts.Score)
+	if err := svc.snapshotDiffRepo.Save(diff); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (svc *SnapshotDiffService) createTextDiff(base model.Snapshot, target model.Snapshot) (*dto.SnapshotDiff, error) {
+	if base.GetText().Size > SnapshotDiffMaxTextSize || target.GetText().Size > SnapshotDiffMaxTextSize {
+		return nil, errorpkg.NewSnapshotDiffTextTooLargeError(SnapshotDiffMaxTextSize)
+	}
+	baseText, err := svc.s3.GetText(base.GetText().Key, base.GetText().Bucket, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	targetText, err := svc.s3.GetText(target.GetText().Key, target.GetText().Bucket, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	/* The size of the objects may be missing */
+	if len(baseText) > SnapshotDiffMaxTextSize || len(targetText) > SnapshotDiffMaxTextSize {
+		return nil, errorpkg.NewSnapshotDiffTextTooLargeError(SnapshotDiffMaxTextSize)
+	}
+	textDiff, err := computeTextDiff(
+		baseText,
+		targetText,
+		fmt.Sprintf("v%d", base.GetVersion()),
+		fmt.Sprintf("v%d", target.GetVersion()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(textDiff)
+	if err != nil {
+		return nil, err
+	}
+	artifact := &model.S3Object{
+		Bucket: target.GetText().Bucket,
+		Key:    svc.getArtifactKey(base, target, ".json"),
+		Size:   int64(len(b)),
+	}
+	if err := svc.s3.PutText(artifact.Key, string(b), "application/json", artifact.Bucket, minio.PutObjectOptions{}); err != nil {
+		return nil, err
+	}
+	diff, err := svc.snapshotDiffRepo.Insert(repo.SnapshotDiffInsertOptions{
+		ID:               helper.NewID(),
+		BaseSnapshotID:   base.GetID(),
+		TargetSnapshotID: target.GetID(),
+		Type:             model.SnapshotDiffTypeText,
+		Artifact:         artifact,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := svc.mapBase(diff)
+	res.Status = dto.SnapshotDiffStatusReady
+	res.Text = textDiff
+	return res, nil
+}
+
+func (svc *SnapshotDiffService) createImageDiff(file model.File, base model.Snapshot, target model.Snapshot, userID string) (*dto.SnapshotDiff, error) {
+	task, err := svc.taskSvc.insertAndSync(repo.TaskInsertOptions{
+		ID:              helper.NewID(),
+		Name:            "Waiting.",
+		UserID:          userID,
+		IsIndeterminate: true,
+		Status:          model.TaskStatusWaiting,
+		Payload:         map[string]string{repo.TaskPayloadObjectKey: file.GetName()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	diff, err := svc.snapshotDiffRepo.Insert(repo.SnapshotDiffInsertOptions{
+		ID:               helper.NewID(),
+		BaseSnapshotID:   base.GetID(),
+		TargetSnapshotID: target.GetID(),
+		Type:             model.SnapshotDiffTypeImage,
+		TaskID:           helper.ToPtr(task.GetID()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	baseImage := svc.getImage(base)
+	targetImage := svc.getImage(target)
+	if err := svc.pipelineClient.Run(&dto.PipelineRunOptions{
+		PipelineID:  helper.ToPtr(dto.PipelineImageDiff),
+		TaskID:      helper.ToPtr(task.GetID()),
+		SnapshotID:  target.GetID(),
+		WorkspaceID: helper.ToPtr(file.GetWorkspaceID()),
+		Priority:    helper.ToPtr(dto.PipelinePriorityInteractive),
+		Bucket:      baseImage.Bucket,
+		Key:         baseImage.Key,
+		Payload: map[string]string{
+			dto.PipelinePayloadDiffID:      diff.GetID(),
+			dto.PipelinePayloadOtherBucket: targetImage.Bucket,
+			dto.PipelinePayloadOtherKey:    targetImage.Key,
+			dto.PipelinePayloadOutputKey:   svc.getArtifactKey(base, target, ".png"),
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return svc.mapDiff(diff)
+}
+
+func (svc *SnapshotDiffService) authorize(id string, userID string) (model.SnapshotDiff, error) {
+	diff, err := svc.snapshotDiffRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := svc.snapshotSvc.authorize(diff.GetTargetSnapshotID(), userID, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func (svc *SnapshotDiffService) delete(diff model.SnapshotDiff) error {
+	if diff.HasArtifact() {
+		if err := svc.s3.RemoveObject(diff.GetArtifact().Key, diff.GetArtifact().Bucket, minio.RemoveObjectOptions{}); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	if err := svc.snapshotDiffRepo.Delete(diff.GetID()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (svc *SnapshotDiffService) isImage(snapshot model.Snapshot) bool {
+	return snapshot.HasOriginal() && svc.fileIdent.IsImage(snapshot.GetOriginal().Key)
+}
+
+// getImage returns the preview when there is one, because it is in a format
+// that is easier to decode than the original.
+func (svc *SnapshotDiffService) getImage(snapshot model.Snapshot) *model.S3Object {
+	if snapshot.HasPreview() {
+		return snapshot.GetPreview()
+	}
+	return snapshot.GetOriginal()
+}
+
+func (svc *SnapshotDiffService) getArtifactKey(base model.Snapshot, target model.Snapshot, extension string) string {
+	return filepath.FromSlash(target.GetID() + "/diffs/" + base.GetID() + extension)
+}
+
+func (svc *SnapshotDiffService) getStatus(diff model.SnapshotDiff) string {
+	if diff.HasArtifact() {
+		return dto.SnapshotDiffStatusReady
+	}
+	if diff.GetTaskID() != nil {
+		task := svc.taskCache.GetOrNil(*diff.GetTaskID())
+		if task != nil && task.GetStatus() != model.TaskStatusError && task.GetStatus() != model.TaskStatusCancelled {
+			return dto.SnapshotDiffStatusProcessing
+		}
+	}
+	return dto.SnapshotDiffStatusError
+}
+
+func (svc *SnapshotDiffService) mapDiff(diff model.SnapshotDiff) (*dto.SnapshotDiff, error) {
+	res := svc.mapBase(diff)
+	res.Status = svc.getStatus(diff)
+	if res.Status == dto.SnapshotDiffStatusReady {
+		if diff.GetType() == model.SnapshotDiffTypeText {
+			text, err := svc.s3.GetText(diff.GetArtifact().Key, diff.GetArtifact().Bucket, minio.GetObjectOptions{})
+			if err != nil {
+				return nil, err
+			}
+			res.Text = &dto.SnapshotTextDiff{}
+			if err := json.Unmarshal([]byte(text), res.Text); err != nil {
+				return nil, err
+			}
+		} else {
+			res.Image = diff.GetArtifact().Image
+		}
+	}
+	if diff.GetTaskID() != nil {
+		if task := svc.taskCache.GetOrNil(*diff.GetTaskID()); task != nil {
+			var err error
+			res.Task, err = svc.taskMapper.Map(task)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func (svc *SnapshotDiffService) mapBase(diff model.SnapshotDiff) *dto.SnapshotDiff {
+	return &dto.SnapshotDiff{
+		ID:               diff.GetID(),
+		BaseSnapshotID:   diff.GetBaseSnapshotID(),
+		TargetSnapshotID: diff.GetTargetSnapshotID(),
+		Type:             diff.GetType(),
+		Score:            diff.GetScore(),
+		CreateTime:       diff.GetCreateTime(),
+		UpdateTime:       diff.GetUpdateTime(),
+	}
+}
+
+// computeTextDiff returns a line based unified diff of both texts, along with
+// a word based diff that is suited to highlight changes inline.
+func computeTextDiff(a string, b string, fromName string, toName string) (*dto.SnapshotTextDiff, error) {
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  SnapshotDiffContextLines,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.SnapshotTextDiff{
+		Unified: unified,
+		Words:   make([]*dto.SnapshotDiffOperation, 0),
+	}
+	aWords := strings.Fields(a)
+	bWords := strings.Fields(b)
+	appendOperation := func(opType string, words []string) {
+		if len(words) == 0 {
+			return
+		}
+		res.Words = append(res.Words, &dto.SnapshotDiffOperation{
+			Type: opType,
+			Text: strings.Join(words, " "),
+		})
+	}
+	for _, op := range difflib.NewMatcher(aWords, bWords).GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			appendOperation(dto.SnapshotDiffOperationEqual, aWords[op.I1:op.I2])
+		case 'd':
+			appendOperation(dto.SnapshotDiffOperationDelete, aWords[op.I1:op.I2])
+			res.Deletions += op.I2 - op.I1
+		case 'i':
+			appendOperation(dto.SnapshotDiffOperationInsert, bWords[op.J1:op.J2])
+			res.Insertions += op.J2 - op.J1
+		case 'r':
+			appendOperation(dto.SnapshotDiffOperationDelete, aWords[op.I1:op.I2])
+			appendOperation(dto.SnapshotDiffOperationInsert, bWords[op.J1:op.J2])
+			res.Deletions += op.I2 - op.I1
+			res.Insertions += op.J2 - op.J1
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type SnapshotDiffServiceSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestSnapshotDiffServiceSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotDiffServiceSuite))
+}
+
+func (s *SnapshotDiffServiceSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(1)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_Text() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "the quick brown fox\njumps over the dog\n")
+	v2 := s.createTextSnapshot(file.ID, 2, "the quick red fox\njumps over the lazy dog\n")
+
+	diff, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v2.GetID(),
+		OtherSnapshotID: v1.GetID(),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(v1.GetID(), diff.BaseSnapshotID)
+	s.Equal(v2.GetID(), diff.TargetSnapshotID)
+	s.Equal(model.SnapshotDiffTypeText, diff.Type)
+	s.Equal(dto.SnapshotDiffStatusReady, diff.Status)
+	s.Require().NotNil(diff.Text)
+	s.Contains(diff.Text.Unified, "--- v1")
+	s.Contains(diff.Text.Unified, "+++ v2")
+	s.Contains(diff.Text.Unified, "-the quick brown fox")
+	s.Contains(diff.Text.Unified, "+the quick red fox")
+	s.Equal(2, diff.Text.Insertions)
+	s.Equal(1, diff.Text.Deletions)
+	s.Equal([]*dto.SnapshotDiffOperation{
+		{Type: dto.SnapshotDiffOperationEqual, Text: "the quick"},
+		{Type: dto.SnapshotDiffOperationDelete, Text: "brown"},
+		{Type: dto.SnapshotDiffOperationInsert, Text: "red"},
+		{Type: dto.SnapshotDiffOperationEqual, Text: "fox jumps over the"},
+		{Type: dto.SnapshotDiffOperationInsert, Text: "lazy"},
+		{Type: dto.SnapshotDiffOperationEqual, Text: "dog"},
+	}, diff.Text.Words)
+
+	found, err := service.NewSnapshotDiffService().Find(diff.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(diff.Text, found.Text)
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_ReturnsExisting() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+	v2 := s.createTextSnapshot(file.ID, 2, "ipsum")
+
+	diff, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	again, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v2.GetID(),
+		OtherSnapshotID: v1.GetID(),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(diff.ID, again.ID)
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_Image() {
+	file := s.createFile()
+	v1 := s.createImageSnapshot(file.ID, 1)
+	v2 := s.createImageSnapshot(file.ID, 2)
+
+	diff, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(model.SnapshotDiffTypeImage, diff.Type)
+	s.Equal(dto.SnapshotDiffStatusProcessing, diff.Status)
+	s.Require().NotNil(diff.Task)
+
+	err = service.NewSnapshotDiffService().Patch(diff.ID, dto.SnapshotDiffPatchOptions{
+		Artifact: &model.S3Object{
+			Bucket: "bucket",
+			Key:    v2.GetID() + "/diffs/" + v1.GetID() + ".png",
+			Image:  &model.ImageProps{Width: 10, Height: 10},
+		},
+		Score: helper.ToPtr(0.25),
+	})
+	s.Require().NoError(err)
+
+	found, err := service.NewSnapshotDiffService().Find(diff.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(dto.SnapshotDiffStatusReady, found.Status)
+	s.Require().NotNil(found.Score)
+	s.InDelta(0.25, *found.Score, 0.0001)
+	s.Require().NotNil(found.Image)
+	s.Equal(10, found.Image.Width)
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_RetriesCancelled() {
+	file := s.createFile()
+	v1 := s.createImageSnapshot(file.ID, 1)
+	v2 := s.createImageSnapshot(file.ID, 2)
+	opts := dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}
+
+	diff, err := service.NewSnapshotDiffService().Create(opts, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NotNil(diff.Task)
+	_, err = service.NewTaskService().Cancel(diff.Task.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	found, err := service.NewSnapshotDiffService().Find(diff.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(dto.SnapshotDiffStatusError, found.Status)
+
+	again, err := service.NewSnapshotDiffService().Create(opts, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.NotEqual(diff.ID, again.ID)
+	s.Equal(dto.SnapshotDiffStatusProcessing, again.Status)
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_TextTooLarge() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+	v2 := s.createTextSnapshot(file.ID, 2, strings.Repeat("ipsum ", service.SnapshotDiffMaxTextSize/5))
+
+	_, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotDiffTextTooLargeError(service.SnapshotDiffMaxTextSize).Error(), err.Error())
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_SameSnapshot() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+
+	_, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v1.GetID(),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotDiffSameSnapshotError(nil).Error(), err.Error())
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_FileMismatch() {
+	file := s.createFile()
+	otherFile := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+	v2 := s.createTextSnapshot(otherFile.ID, 1, "ipsum")
+
+	_, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotDiffFileMismatchError(nil).Error(), err.Error())
+}
+
+func (s *SnapshotDiffServiceSuite) TestCreate_Unsupported() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+	v2 := s.insertSnapshot(file.ID, repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
+		ID:         helper.NewID(),
+		Version:    2,
+		CreateTime: helper.NewTimeString(),
+	}))
+
+	_, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotDiffUnsupportedError(nil).Error(), err.Error())
+}
+
+func (s *SnapshotDiffServiceSuite) TestFind_MissingFilePermission() {
+	file := s.createFile()
+	v1 := s.createTextSnapshot(file.ID, 1, "lorem")
+	v2 := s.createTextSnapshot(file.ID, 2, "ipsum")
+	diff, err := service.NewSnapshotDiffService().Create(dto.SnapshotDiffCreateOptions{
+		FileID:          file.ID,
+		SnapshotID:      v1.GetID(),
+		OtherSnapshotID: v2.GetID(),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	err = repo.NewFileRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).RevokeUserPermission(
+		[]model.File{cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		).GetOrNil(file.ID)},
+		s.users[0].GetID(),
+	)
+	s.Require().NoError(err)
+	_, err = cache.NewFileCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	).Refresh(file.ID)
+	s.Require().NoError(err)
+
+	_, err = service.NewSnapshotDiffService().Find(diff.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *SnapshotDiffServiceSuite) createFile() *dto.File {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	return file
+}
+
+func (s *SnapshotDiffServiceSuite) createTextSnapshot(fileID string, version int64, text string) model.Snapshot {
+	id := helper.NewID()
+	key := id + "/text.txt"
+	err := infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment).
+		PutText(key, text, "text/plain", "bucket", minio.PutObjectOptions{})
+	s.Require().NoError(err)
+	return s.insertSnapshot(fileID, repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
+		ID:         id,
+		Version:    version,
+		Original:   &model.S3Object{Bucket: "bucket", Key: id + "/original.txt"},
+		Text:       &model.S3Object{Bucket: "bucket", Key: key, Size: int64(len(text))},
+		CreateTime: helper.NewTimeString(),
+	}))
+}
+
+func (s *SnapshotDiffServiceSuite) createImageSnapshot(fileID string, version int64) model.Snapshot {
+	id := helper.NewID()
+	return s.insertSnapshot(fileID, repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
+		ID:         id,
+		Version:    version,
+		Original:   &model.S3Object{Bucket: "bucket", Key: id + "/original.png"},
+		CreateTime: helper.NewTimeString(),
+	}))
+}
+
+func (s *SnapshotDiffServiceSuite) insertSnapshot(fileID string, snapshot model.Snapshot) model.Snapshot {
+	err := repo.NewSnapshotRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).Insert(snapshot)
+	s.Require().NoError(err)
+	err = cache.NewSnapshotCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	).Set(snapshot)
+	s.Require().NoError(err)
+	err = repo.NewSnapshotRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).MapWithFile(snapshot.GetID(), fileID)
+	s.Require().NoError(err)
+	return snapshot
+}
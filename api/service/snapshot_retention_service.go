@@ -0,0 +1,380 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// SnapshotPruneInterval is how often the retention policies are enforced.
+	SnapshotPruneInterval = 1 * time.Hour
+	// SnapshotPruneLeaseDuration bounds how long a replica that died while
+	// pruning a workspace keeps the others from pruning it.
+	SnapshotPruneLeaseDuration = 30 * time.Minute
+)
+
+type SnapshotRetentionService struct {
+	policyRepo     *repo.SnapshotRetentionPolicyRepo
+	snapshotRepo   *repo.SnapshotRepo
+	snapshotSvc    *SnapshotService
+	fileRepo       *repo.FileRepo
+	fileCache      *cache.FileCache
+	workspaceCache *cache.WorkspaceCache
+	workspaceGuard *guard.WorkspaceGuard
+	redis          *infra.RedisManager
+}
+
+func NewSnapshotRetentionService() *SnapshotRetentionService {
+	return &SnapshotRetentionService{
+		policyRepo: repo.NewSnapshotRetentionPolicyRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		snapshotRepo: repo.NewSnapshotRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		snapshotSvc: NewSnapshotService(),
+		fileRepo: repo.NewFileRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceCache: cache.NewWorkspaceCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceGuard: guard.NewWorkspaceGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		redis: infra.NewRedisManager(config.GetConfig().Redis),
+	}
+}
+
+func (svc *SnapshotRetentionService) Find(workspaceID string, userID string) (*dto.SnapshotRetentionPolicy, error) {
+	if err := svc.authorizeWorkspace(workspaceID, userID, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	policy, err := svc.policyRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapPolicy(policy), nil
+}
+
+// Put creates the retention policy of the workspace, or replaces its rules if
+// it already has one. It requires ownership of the workspace.
+func (svc *SnapshotRetentionService) Put(workspaceID string, opts dto.SnapshotRetentionPolicyOptions, userID string) (*dto.SnapshotRetentionPolicy, error) {
+	if err := svc.authorizeWorkspace(workspaceID, userID, model.PermissionOwner); err != nil {
+		return nil, err
+	}
+	policy := svc.policyRepo.FindByWorkspaceOrNil(workspaceID)
+	if policy == nil {
+		var err error
+		policy, err = svc.policyRepo.Insert(repo.SnapshotRetentionPolicyInsertOptions{
+			ID:              helper.NewID(),
+			WorkspaceID:     workspaceID,
+			KeepLast:        opts.KeepLast,
+			KeepAllDays:     opts.KeepAllDays,
+			KeepDailyDays:   opts.KeepDailyDays,
+			KeepWeeklyWeeks: opts.KeepWeeklyWeeks,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		policy.SetKeepLast(opts.KeepLast)
+		policy.SetKeepAllDays(opts.KeepAllDays)
+		policy.SetKeepDailyDays(opts.KeepDailyDays)
+		policy.SetKeepWeeklyWeeks(opts.KeepWeeklyWeeks)
+		if err := svc.policyRepo.Save(policy); err != nil {
+			return nil, err
+		}
+	}
+	return svc.mapPolicy(policy), nil
+}
+
+func (svc *SnapshotRetentionService) Delete(workspaceID string, userID string) error {
+	if err := svc.authorizeWorkspace(workspaceID, userID, model.PermissionOwner); err != nil {
+		return err
+	}
+	policy, err := svc.policyRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return err
+	}
+	return svc.policyRepo.Delete(policy.GetID())
+}
+
+// Prune enforces the retention policy of the workspace right away, it requires
+// ownership of the workspace.
+func (svc *SnapshotRetentionService) Prune(workspaceID string, userID string) (*dto.SnapshotPruneResult, error) {
+	if err := svc.authorizeWorkspace(workspaceID, userID, model.PermissionOwner); err != nil {
+		return nil, err
+	}
+	policy, err := svc.policyRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := svc.prune(policy)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, errorpkg.NewSnapshotPruneInProgressError()
+	}
+	return res, nil
+}
+
+// PruneAll enforces the retention policies of all the workspaces, and returns
+// the number of bytes freed. The workspaces being pruned by another replica
+// are skipped.
+func (svc *SnapshotRetentionService) PruneAll() (int64, error) {
+	policies, err := svc.policyRepo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+	var freedBytes int64
+	for _, policy := range policies {
+		res, err := svc.prune(policy)
+		if err != nil {
+			logger.GetLogger().Error(err)
+			continue
+		}
+		if res == nil {
+			continue
+		}
+		freedBytes += res.FreedBytes
+	}
+	return freedBytes, nil
+}
+
+// StartPruner runs PruneAll periodically in the background.
+func (svc *SnapshotRetentionService) StartPruner() {
+	go func() {
+		ticker := time.NewTicker(SnapshotPruneInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			freedBytes, err := svc.PruneAll()
+			if err != nil {
+				logger.GetLogger().Error(err)
+				continue
+			}
+			if freedBytes > 0 {
+				logger.GetLogger().Infow("Pruned snapshots.", "freedBytes", freedBytes)
+			}
+		}
+	}()
+}
+
+// prune enforces the policy while holding the lease of its workspace, so that
+// replicas don't detach and delete the same snapshots at the same time. It
+// returns a nil result if another replica holds the lease.
+func (svc *SnapshotRetentionService) prune(policy model.SnapshotRetentionPolicy) (*dto.SnapshotPruneResult, error) {
+	key := "snapshot_prune_lease:" + policy.GetWorkspaceID()
+	token := helper.NewID()
+	acquired, err := svc.redis.SetNX(key, token, SnapshotPruneLeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, nil
+	}
+	defer func() {
+		if _, err := svc.redis.DeleteIfEqual(key, token); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}()
+	/* Only files with more snapshots than the ones always kept can have some to prune */
+	files, err := svc.fileRepo.FindWithMoreSnapshotsThan(policy.GetWorkspaceID(), max(derefInt(policy.GetKeepLast()), 1))
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.SnapshotPruneResult{}
+	now := time.Now()
+	for _, file := range files {
+		if err := svc.pruneFile(file, policy, now, res); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	policy.SetLastRunTime(helper.ToPtr(helper.TimeToString(now)))
+	policy.SetLastDeletedCount(helper.ToPtr(res.DeletedCount))
+	policy.SetLastFreedBytes(helper.ToPtr(res.FreedBytes))
+	if err := svc.policyRepo.Save(policy); err != nil {
+		return nil, err
+	}
+	if res.DeletedCount > 0 || res.DetachedCount > 0 {
+		logger.GetLogger().Infow(
+			fmt.Sprintf("Pruned snapshots of workspace '%s'.", policy.GetWorkspaceID()),
+			"deleted", res.DeletedCount,
+			"detached", res.DetachedCount,
+			"freedBytes", res.FreedBytes,
+		)
+	}
+	return res, nil
+}
+
+func (svc *SnapshotRetentionService) pruneFile(file model.File, policy model.SnapshotRetentionPolicy, now time.Time, res *dto.SnapshotPruneResult) error {
+	snapshots, err := svc.snapshotRepo.FindAllForFile(file.GetID())
+	if err != nil {
+		return err
+	}
+	pruned := selectSnapshotsToPrune(snapshots, file.GetSnapshotID(), policy, now)
+	if len(pruned) == 0 {
+		return nil
+	}
+	for _, snapshot := range pruned {
+		if pending, err := svc.snapshotSvc.isTaskPending(snapshot); err != nil || pending {
+			continue
+		}
+		if err := svc.snapshotRepo.Detach(snapshot.GetID(), file.GetID()); err != nil {
+			return err
+		}
+		/* The snapshot is shared with other files, only this file lets go of it */
+		count, err := svc.snapshotRepo.CountAssociations(snapshot.GetID())
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			res.DetachedCount++
+			continue
+		}
+		svc.snapshotSvc.deleteAssociatedTasks([]model.Snapshot{snapshot})
+		svc.snapshotSvc.deleteFromS3([]model.Snapshot{snapshot})
+		svc.snapshotSvc.deleteFromRepo([]model.Snapshot{snapshot})
+		svc.snapshotSvc.deleteFromCache([]model.Snapshot{snapshot})
+		res.DeletedCount++
+		res.FreedBytes += computeSnapshotSize(snapshot)
+	}
+	if _, err := svc.fileCache.Refresh(file.GetID()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (svc *SnapshotRetentionService) authorizeWorkspace(workspaceID string, userID string, permission string) error {
+	workspace, err := svc.workspaceCache.Get(workspaceID)
+	if err != nil {
+		return err
+	}
+	if err = svc.workspaceGuard.Authorize(userID, workspace, permission); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (svc *SnapshotRetentionService) mapPolicy(policy model.SnapshotRetentionPolicy) *dto.SnapshotRetentionPolicy {
+	return &dto.SnapshotRetentionPolicy{
+		ID:               policy.GetID(),
+		WorkspaceID:      policy.GetWorkspaceID(),
+		KeepLast:         policy.GetKeepLast(),
+		KeepAllDays:      policy.GetKeepAllDays(),
+		KeepDailyDays:    policy.GetKeepDailyDays(),
+		KeepWeeklyWeeks:  policy.GetKeepWeeklyWeeks(),
+		LastRunTime:      policy.GetLastRunTime(),
+		LastDeletedCount: policy.GetLastDeletedCount(),
+		LastFreedBytes:   policy.GetLastFreedBytes(),
+		CreateTime:       policy.GetCreateTime(),
+		UpdateTime:       policy.GetUpdateTime(),
+	}
+}
+
+// selectSnapshotsToPrune returns the snapshots that the policy doesn't keep.
+// Snapshots are walked from the newest version, so that when thinning to one
+// snapshot per day or week, the newest snapshot of each period is the one kept.
+func selectSnapshotsToPrune(snapshots []model.Snapshot, activeID *string, policy model.SnapshotRetentionPolicy, now time.Time) []model.Snapshot {
+	sorted := make([]model.Snapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetVersion() > sorted[j].GetVersion()
+	})
+	day := 24 * time.Hour
+	allCutoff := now.Add(-time.Duration(derefInt(policy.GetKeepAllDays())) * day)
+	dailyCutoff := allCutoff.Add(-time.Duration(derefInt(policy.GetKeepDailyDays())) * day)
+	weeklyCutoff := dailyCutoff.Add(-time.Duration(derefInt(policy.GetKeepWeeklyWeeks())) * 7 * day)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	res := make([]model.Snapshot, 0)
+	for i, snapshot := range sorted {
+		createTime := helper.StringToTime(snapshot.GetCreateTime())
+		dayKey := createTime.UTC().Format(time.DateOnly)
+		year, week := createTime.UTC().ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		keep := false
+		if activeID != nil && snapshot.GetID() == *activeID {
+			keep = true
+		} else if snapshot.GetIsPinned() {
+			keep = true
+		} else if i < derefInt(policy.GetKeepLast()) {
+			keep = true
+		} else if createTime.After(allCutoff) {
+			keep = true
+		} else if createTime.After(dailyCutoff) && !days[dayKey] {
+			keep = true
+		} else if createTime.After(weeklyCutoff) && !weeks[weekKey] {
+			keep = true
+		}
+		if keep {
+			days[dayKey] = true
+			weeks[weekKey] = true
+		} else {
+			res = append(res, snapshot)
+		}
+	}
+	return res
+}
+
+func computeSnapshotSize(snapshot model.Snapshot) int64 {
+	var res int64
+	for _, object := range []*model.S3Object{
+		snapshot.GetOriginal(),
+		snapshot.GetPreview(),
+		snapshot.GetText(),
+		snapshot.GetOCR(),
+		snapshot.GetEntities(),
+		snapshot.GetMosaic(),
+		snapshot.GetHLS(),
+		snapshot.GetThumbnail(),
+	} {
+		if object != nil {
+			res += object.Size
+		}
+	}
+	return res
+}
+
+func derefInt(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type SnapshotRetentionServiceSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestSnapshotRetentionServiceSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotRetentionServiceSuite))
+}
+
+func (s *SnapshotRetentionServiceSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPut() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+
+	policy, err := service.NewSnapshotRetentionService().Put(workspace.ID, dto.SnapshotRetentionPolicyOptions{
+		KeepLast: helper.ToPtr(5),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(workspace.ID, policy.WorkspaceID)
+	s.Equal(5, *policy.KeepLast)
+
+	policy, err = service.NewSnapshotRetentionService().Put(workspace.ID, dto.SnapshotRetentionPolicyOptions{
+		KeepAllDays:   helper.ToPtr(7),
+		KeepDailyDays: helper.ToPtr(30),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	found, err := service.NewSnapshotRetentionService().Find(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(policy.ID, found.ID)
+	s.Nil(found.KeepLast)
+	s.Equal(7, *found.KeepAllDays)
+	s.Equal(30, *found.KeepDailyDays)
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPut_MissingPermission() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewSnapshotRetentionService().Put(workspace.ID, dto.SnapshotRetentionPolicyOptions{
+		KeepLast: helper.ToPtr(5),
+	}, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewWorkspaceNotFoundError(err).Error(), err.Error())
+}
+
+func (s *SnapshotRetentionServiceSuite) TestDelete() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewSnapshotRetentionService().Put(workspace.ID, dto.SnapshotRetentionPolicyOptions{
+		KeepLast: helper.ToPtr(5),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	s.Require().NoError(service.NewSnapshotRetentionService().Delete(workspace.ID, s.users[0].GetID()))
+
+	_, err = service.NewSnapshotRetentionService().Find(workspace.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotRetentionPolicyNotFoundError(err).Error(), err.Error())
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPrune_KeepLast() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID, 4)
+	s.putPolicy(workspace, dto.SnapshotRetentionPolicyOptions{KeepLast: helper.ToPtr(2)})
+
+	res, err := service.NewSnapshotRetentionService().Prune(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(2, res.DeletedCount)
+	s.Zero(res.DetachedCount)
+	s.Equal(int64(2048), res.FreedBytes)
+	s.Equal([]string{snapshots[2].GetID(), snapshots[3].GetID()}, s.findSnapshotIDs(file.ID))
+
+	policy, err := service.NewSnapshotRetentionService().Find(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.NotNil(policy.LastRunTime)
+	s.Equal(2, *policy.LastDeletedCount)
+	s.Equal(int64(2048), *policy.LastFreedBytes)
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPrune_KeepActiveAndPinned() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID, 4)
+
+	snapshotRepo := repo.NewSnapshotRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	snapshots[0].SetIsPinned(true)
+	s.Require().NoError(snapshotRepo.Save(snapshots[0]))
+	fileRepo := repo.NewFileRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	fileModel, err := fileRepo.Find(file.ID)
+	s.Require().NoError(err)
+	fileModel.SetSnapshotID(helper.ToPtr(snapshots[1].GetID()))
+	s.Require().NoError(fileRepo.Save(fileModel))
+
+	s.putPolicy(workspace, dto.SnapshotRetentionPolicyOptions{KeepLast: helper.ToPtr(1)})
+
+	res, err := service.NewSnapshotRetentionService().Prune(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(1, res.DeletedCount)
+	s.Equal([]string{snapshots[0].GetID(), snapshots[1].GetID(), snapshots[3].GetID()}, s.findSnapshotIDs(file.ID))
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPrune_SharedSnapshot() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	other, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "other",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID, 2)
+	snapshotRepo := repo.NewSnapshotRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	s.Require().NoError(snapshotRepo.MapWithFile(snapshots[0].GetID(), other.ID))
+
+	s.putPolicy(workspace, dto.SnapshotRetentionPolicyOptions{KeepLast: helper.ToPtr(1)})
+
+	res, err := service.NewSnapshotRetentionService().Prune(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Zero(res.DeletedCount)
+	s.Equal(1, res.DetachedCount)
+	s.Zero(res.FreedBytes)
+	s.Equal([]string{snapshots[1].GetID()}, s.findSnapshotIDs(file.ID))
+	s.Equal([]string{snapshots[0].GetID()}, s.findSnapshotIDs(other.ID))
+}
+
+func (s *SnapshotRetentionServiceSuite) TestPrune_InProgress() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID, 2)
+	s.putPolicy(workspace, dto.SnapshotRetentionPolicyOptions{KeepLast: helper.ToPtr(1)})
+
+	/* Another replica holds the lease of the workspace */
+	_, err = infra.NewRedisManager(config.GetConfig().Redis).
+		SetNX("snapshot_prune_lease:"+workspace.ID, helper.NewID(), service.SnapshotPruneLeaseDuration)
+	s.Require().NoError(err)
+
+	_, err = service.NewSnapshotRetentionService().Prune(workspace.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewSnapshotPruneInProgressError().Error(), err.Error())
+	s.Equal([]string{snapshots[0].GetID(), snapshots[1].GetID()}, s.findSnapshotIDs(file.ID))
+
+	_, err = service.NewSnapshotRetentionService().PruneAll()
+	s.Require().NoError(err)
+	s.Equal([]string{snapshots[0].GetID(), snapshots[1].GetID()}, s.findSnapshotIDs(file.ID))
+}
+
+func (s *SnapshotRetentionServiceSuite) putPolicy(workspace *dto.Workspace, opts dto.SnapshotRetentionPolicyOptions) {
+	_, err := service.NewSnapshotRetentionService().Put(workspace.ID, opts, s.users[0].GetID())
+	s.Require().NoError(err)
+}
+
+// createSnapshots creates count snapshots of 1 KB each, ordered from the oldest
+// version to the newest.
+func (s *SnapshotRetentionServiceSuite) createSnapshots(fileID string, count int) []model.Snapshot {
+	snapshotRepo := repo.NewSnapshotRepo(config.GetConfig().Postgres, config.GetConfig().Environment)
+	snapshotCache := cache.NewSnapshotCache(
+		config.GetConfig().Postgres,
+		config.GetConfig().Redis,
+		config.GetConfig().Environment,
+	)
+	res := make([]model.Snapshot, 0)
+	for i := range count {
+		snapshot := repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
+			ID:      helper.NewID(),
+			Version: int64(i + 1),
+			Original: &model.S3Object{
+				Bucket: "bucket",
+				Key:    fileID + "/" + helper.NewID() + "/original.txt",
+				Size:   1024,
+			},
+			CreateTime: helper.NewTimeString(),
+		})
+		s.Require().NoError(snapshotRepo.Insert(snapshot))
+		s.Require().NoError(snapshotCache.Set(snapshot))
+		s.Require().NoError(snapshotRepo.MapWithFile(snapshot.GetID(), fileID))
+		res = append(res, snapshot)
+	}
+	return res
+}
+
+func (s *SnapshotRetentionServiceSuite) findSnapshotIDs(fileID string) []string {
+	snapshots, err := repo.NewSnapshotRepo(config.GetConfig().Postgres, config.GetConfig().Environment).FindAllForFile(fileID)
+	s.Require().NoError(err)
+	res := make([]string, 0)
+	for _, snapshot := range snapshots {
+		res = append(res, snapshot.GetID())
+	}
+	return res
+}
@@ -12,6 +12,7 @@ package service
 
 import (
 	"sort"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 
@@ -36,17 +37,21 @@ type SnapshotService struct {
 	snapshotCache         *cache.SnapshotCache
 	snapshotMapper        *mapper.SnapshotMapper
 	snapshotWebhookClient *client.SnapshotWebhookClient
+	snapshotDiffRepo      *repo.SnapshotDiffRepo
 	fileCache             *cache.FileCache
 	fileGuard             *guard.FileGuard
+	fileCoreSvc           *fileCoreService
 	fileRepo              *repo.FileRepo
 	fileSearch            *search.FileSearch
 	fileMapper            *mapper.FileMapper
+	fileSemanticSearch    *fileSemanticSearch
 	taskRepo              *repo.TaskRepo
 	taskCache             *cache.TaskCache
 	taskSearch            *search.TaskSearch
 	s3                    infra.S3Manager
 	config                *config.Config
 	languages             []*dto.SnapshotLanguage
+	webhookPublisher      *webhookPublisher
 }
 
 func NewSnapshotService() *SnapshotService {
@@ -68,6 +73,10 @@ func NewSnapshotService() *SnapshotService {
 		snapshotWebhookClient: client.NewSnapshotWebhookClient(
 			config.GetConfig().Security,
 		),
+		snapshotDiffRepo: repo.NewSnapshotDiffRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
 		fileCache: cache.NewFileCache(
 			config.GetConfig().Postgres,
 			config.GetConfig().Redis,
@@ -78,6 +87,7 @@ func NewSnapshotService() *SnapshotService {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		fileCoreSvc: newFileCoreService(),
 		fileSearch: search.NewFileSearch(
 			config.GetConfig().Postgres,
 			config.GetConfig().Search,
@@ -93,6 +103,7 @@ func NewSnapshotService() *SnapshotService {
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
 		),
+		fileSemanticSearch: newFileSemanticSearch(),
 		taskRepo: repo.NewTaskRepo(
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
@@ -127,6 +138,7 @@ func NewSnapshotService() *SnapshotService {
 			{ID: "fin", ISO6393: "fin", Name: "Finnish"},
 			{ID: "dan", ISO6393: "dan", Name: "Danish"},
 		},
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -135,6 +147,11 @@ type SnapshotListOptions struct {
 	Size      uint64
 	SortBy    string
 	SortOrder string
+	// Label and Note match snapshots containing the value, ignoring case.
+	Label    *string
+	Note     *string
+	UserID   *string
+	IsPinned *bool
 }
 
 func (svc *SnapshotService) List(fileID string, opts SnapshotListOptions, userID string) (*dto.SnapshotList, error) {
@@ -142,6 +159,7 @@ func (svc *SnapshotService) List(fileID string, opts SnapshotListOptions, userID
 	if err != nil {
 		return nil, err
 	}
+	all = svc.filter(all, opts)
 	sorted := svc.sort(all, opts.SortBy, opts.SortOrder)
 	paged, totalElements, totalPages := svc.paginate(sorted, opts.Page, opts.Size)
 	mapped := svc.snapshotMapper.MapMany(paged, file.GetSnapshotID())
@@ -159,6 +177,7 @@ func (svc *SnapshotService) Probe(fileID string, opts SnapshotListOptions, userI
 	if err != nil {
 		return nil, err
 	}
+	all = svc.filter(all, opts)
 	totalElements := uint64(len(all))
 	return &dto.SnapshotProbe{
 		TotalElements: totalElements,
@@ -178,7 +197,11 @@ func (svc *SnapshotService) Activate(id string, userID string) (*dto.File, error
 	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
 		return nil, err
 	}
-	if _, err := svc.snapshotCache.Get(id); err != nil {
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
+	snapshot, err := svc.snapshotCache.Get(id)
+	if err != nil {
 		return nil, err
 	}
 	file.SetSnapshotID(&id)
@@ -192,6 +215,12 @@ func (svc *SnapshotService) Activate(id string, userID string) (*dto.File, error
 	if err != nil {
 		return nil, err
 	}
+	svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventSnapshotActivated, dto.WebhookSnapshotEventData{
+		ID:      snapshot.GetID(),
+		FileID:  file.GetID(),
+		Version: snapshot.GetVersion(),
+		UserID:  userID,
+	})
 	res, err := svc.fileMapper.Map(file, userID)
 	if err != nil {
 		return nil, err
@@ -211,6 +240,9 @@ func (svc *SnapshotService) Detach(id string, userID string) (*dto.File, error)
 	if err = svc.fileGuard.Authorize(userID, file, model.PermissionOwner); err != nil {
 		return nil, err
 	}
+	if err := svc.fileCoreSvc.checkNotInTrash(file); err != nil {
+		return nil, err
+	}
 	snapshot, err := svc.snapshotCache.Get(id)
 	if err != nil {
 		return nil, err
@@ -249,6 +281,40 @@ func (svc *SnapshotService) Detach(id string, userID string) (*dto.File, error)
 	return res, nil
 }
 
+// Pin protects the snapshot from being pruned by the retention policy of the
+// workspace.
+func (svc *SnapshotService) Pin(id string, userID string) (*dto.Snapshot, error) {
+	return svc.setIsPinned(id, true, userID)
+}
+
+func (svc *SnapshotService) Unpin(id string, userID string) (*dto.Snapshot, error) {
+	return svc.setIsPinned(id, false, userID)
+}
+
+func (svc *SnapshotService) PatchLabel(id string, opts dto.SnapshotPatchLabelOptions, userID string) (*dto.Snapshot, error) {
+	snapshot, file, err := svc.authorize(id, userID, model.PermissionEditor)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.SetLabel(opts.Label)
+	if err := svc.saveAndSync(snapshot); err != nil {
+		return nil, err
+	}
+	return svc.snapshotMapper.MapMany([]model.Snapshot{snapshot}, file.GetSnapshotID())[0], nil
+}
+
+func (svc *SnapshotService) PatchNote(id string, opts dto.SnapshotPatchNoteOptions, userID string) (*dto.Snapshot, error) {
+	snapshot, file, err := svc.authorize(id, userID, model.PermissionEditor)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.SetNote(opts.Note)
+	if err := svc.saveAndSync(snapshot); err != nil {
+		return nil, err
+	}
+	return svc.snapshotMapper.MapMany([]model.Snapshot{snapshot}, file.GetSnapshotID())[0], nil
+}
+
 func (svc *SnapshotService) Find(id string) (*dto.SnapshotWithS3Objects, error) {
 	snapshot, err := svc.snapshotCache.Get(id)
 	if err != nil {
@@ -266,7 +332,9 @@ func (svc *SnapshotService) Patch(id string, opts dto.SnapshotPatchOptions) (*dt
 		OCR:       opts.OCR,
 		Entities:  opts.Entities,
 		Mosaic:    opts.Mosaic,
+		HLS:       opts.HLS,
 		Thumbnail: opts.Thumbnail,
+		Metadata:  opts.Metadata,
 		Language:  opts.Language,
 		Summary:   opts.Summary,
 		Intent:    opts.Intent,
@@ -298,6 +366,17 @@ func (svc *SnapshotService) Patch(id string, opts dto.SnapshotPatchOptions) (*dt
 	return svc.snapshotMapper.MapWithS3Objects(snapshot), nil
 }
 
+// Embed chunks and embeds the text of the snapshot for the semantic search, it
+// is called by the conversion pipelines once the text is extracted, so that a
+// failure is retried along with the pipeline.
+func (svc *SnapshotService) Embed(id string) error {
+	snapshot, err := svc.snapshotCache.Get(id)
+	if err != nil {
+		return err
+	}
+	return svc.fileSemanticSearch.index(snapshot)
+}
+
 func (svc *SnapshotService) GetLanguages() ([]*dto.SnapshotLanguage, error) {
 	return svc.languages, nil
 }
@@ -306,7 +385,9 @@ func (svc *SnapshotService) IsValidSortBy(value string) bool {
 	return value == "" ||
 		value == dto.SnapshotSortByVersion ||
 		value == dto.SnapshotSortByDateCreated ||
-		value == dto.SnapshotSortByDateModified
+		value == dto.SnapshotSortByDateModified ||
+		value == dto.SnapshotSortByLabel ||
+		value == dto.SnapshotSortByPinned
 }
 
 func (svc *SnapshotService) IsValidSortOrder(value string) bool {
@@ -346,6 +427,26 @@ func (svc *SnapshotService) findAll(fileID string, opts SnapshotListOptions, use
 	return res, file, nil
 }
 
+func (svc *SnapshotService) filter(data []model.Snapshot, opts SnapshotListOptions) []model.Snapshot {
+	res := make([]model.Snapshot, 0)
+	for _, s := range data {
+		if opts.Label != nil && !containsIgnoreCase(s.GetLabel(), *opts.Label) {
+			continue
+		}
+		if opts.Note != nil && !containsIgnoreCase(s.GetNote(), *opts.Note) {
+			continue
+		}
+		if opts.UserID != nil && (s.GetUserID() == nil || *s.GetUserID() != *opts.UserID) {
+			continue
+		}
+		if opts.IsPinned != nil && s.GetIsPinned() != *opts.IsPinned {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
 func (svc *SnapshotService) sort(data []model.Snapshot, sortBy string, sortOrder string) []model.Snapshot {
 	if sortBy == dto.SnapshotSortByVersion {
 		sort.Slice(data, func(i, j int) bool {
@@ -382,6 +483,30 @@ func (svc *SnapshotService) sort(data []model.Snapshot, sortBy string, sortOrder
 			}
 		})
 		return data
+	} else if sortBy == dto.SnapshotSortByLabel {
+		sort.SliceStable(data, func(i, j int) bool {
+			/* Snapshots without a label come last */
+			if data[i].GetLabel() == nil || data[j].GetLabel() == nil {
+				return data[i].GetLabel() != nil && data[j].GetLabel() == nil
+			}
+			a := strings.ToLower(*data[i].GetLabel())
+			b := strings.ToLower(*data[j].GetLabel())
+			if sortOrder == dto.SnapshotSortOrderDesc {
+				return a > b
+			} else {
+				return a < b
+			}
+		})
+		return data
+	} else if sortBy == dto.SnapshotSortByPinned {
+		sort.SliceStable(data, func(i, j int) bool {
+			if sortOrder == dto.SnapshotSortOrderDesc {
+				return data[i].GetIsPinned() && !data[j].GetIsPinned()
+			} else {
+				return !data[i].GetIsPinned() && data[j].GetIsPinned()
+			}
+		})
+		return data
 	}
 	return data
 }
@@ -400,6 +525,37 @@ func (svc *SnapshotService) paginate(data []model.Snapshot, page, size uint64) (
 	return data[startIndex:endIndex], totalElements, totalPages
 }
 
+func (svc *SnapshotService) setIsPinned(id string, isPinned bool, userID string) (*dto.Snapshot, error) {
+	snapshot, file, err := svc.authorize(id, userID, model.PermissionEditor)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.SetIsPinned(isPinned)
+	if err := svc.saveAndSync(snapshot); err != nil {
+		return nil, err
+	}
+	return svc.snapshotMapper.MapMany([]model.Snapshot{snapshot}, file.GetSnapshotID())[0], nil
+}
+
+func (svc *SnapshotService) authorize(id string, userID string, permission string) (model.Snapshot, model.File, error) {
+	fileID, err := svc.snapshotRepo.FindFileID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := svc.fileCache.Get(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, permission); err != nil {
+		return nil, nil, err
+	}
+	snapshot, err := svc.snapshotCache.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot, file, nil
+}
+
 func (svc *SnapshotService) deleteForFile(fileID string) error {
 	var snapshots []model.Snapshot
 	snapshots, err := svc.snapshotRepo.FindExclusiveForFile(fileID)
@@ -460,6 +616,11 @@ func (svc *SnapshotService) deleteFromS3(snapshots []model.Snapshot) {
 				logger.GetLogger().Error(err)
 			}
 		}
+		if s.HasHLS() {
+			if err := svc.s3.RemoveFolder(s.GetHLS().Key, s.GetHLS().Bucket, minio.RemoveObjectOptions{}); err != nil {
+				logger.GetLogger().Error(err)
+			}
+		}
 		if s.HasEntities() {
 			if err := svc.s3.RemoveObject(s.GetEntities().Key, s.GetEntities().Bucket, minio.RemoveObjectOptions{}); err != nil {
 				logger.GetLogger().Error(err)
@@ -470,6 +631,24 @@ func (svc *SnapshotService) deleteFromS3(snapshots []model.Snapshot) {
 				logger.GetLogger().Error(err)
 			}
 		}
+		svc.deleteDiffsFromS3(s)
+	}
+}
+
+// deleteDiffsFromS3 removes the artifacts of the diffs involving the snapshot,
+// the diffs themselves are removed along with the snapshot.
+func (svc *SnapshotService) deleteDiffsFromS3(snapshot model.Snapshot) {
+	diffs, err := svc.snapshotDiffRepo.FindAllForSnapshot(snapshot.GetID())
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	for _, d := range diffs {
+		if d.HasArtifact() {
+			if err := svc.s3.RemoveObject(d.GetArtifact().Key, d.GetArtifact().Bucket, minio.RemoveObjectOptions{}); err != nil {
+				logger.GetLogger().Error(err)
+			}
+		}
 	}
 }
 
@@ -543,3 +722,7 @@ func isTaskPending(snapshot model.Snapshot, taskCache *cache.TaskCache) (bool, e
 	}
 	return false, nil
 }
+
+func containsIgnoreCase(value *string, substr string) bool {
+	return value != nil && strings.Contains(strings.ToLower(*value), strings.ToLower(substr))
+}
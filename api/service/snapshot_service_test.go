@@ -142,6 +142,70 @@ func (s *SnapshotServiceSuite) TestList_SortByVersionDescending() {
 	s.Equal(snapshots[0].GetID(), list.Data[2].ID)
 }
 
+func (s *SnapshotServiceSuite) TestList_Filter() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID)
+	_, err = service.NewSnapshotService().PatchLabel(snapshots[1].GetID(), dto.SnapshotPatchLabelOptions{
+		Label: helper.ToPtr("Signed Contract"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewSnapshotService().Pin(snapshots[2].GetID(), s.users[0].GetID())
+	s.Require().NoError(err)
+
+	list, err := service.NewSnapshotService().List(file.ID, service.SnapshotListOptions{
+		Page:  1,
+		Size:  10,
+		Label: helper.ToPtr("contract"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 1)
+	s.Equal(snapshots[1].GetID(), list.Data[0].ID)
+
+	list, err = service.NewSnapshotService().List(file.ID, service.SnapshotListOptions{
+		Page:     1,
+		Size:     10,
+		IsPinned: helper.ToPtr(true),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 1)
+	s.Equal(snapshots[2].GetID(), list.Data[0].ID)
+	s.True(list.Data[0].IsPinned)
+}
+
+func (s *SnapshotServiceSuite) TestList_SortByLabel() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshots := s.createSnapshots(file.ID)
+	_, err = service.NewSnapshotService().PatchLabel(snapshots[0].GetID(), dto.SnapshotPatchLabelOptions{
+		Label: helper.ToPtr("b"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewSnapshotService().PatchLabel(snapshots[2].GetID(), dto.SnapshotPatchLabelOptions{
+		Label: helper.ToPtr("a"),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	list, err := service.NewSnapshotService().List(file.ID, service.SnapshotListOptions{
+		Page:      1,
+		Size:      3,
+		SortBy:    dto.SnapshotSortByLabel,
+		SortOrder: dto.SnapshotSortOrderAsc,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(snapshots[2].GetID(), list.Data[0].ID)
+	s.Equal(snapshots[0].GetID(), list.Data[1].ID)
+	s.Equal(snapshots[1].GetID(), list.Data[2].ID)
+}
+
 func (s *SnapshotServiceSuite) TestProbe() {
 	org, err := test.CreateOrganization(s.users[0].GetID())
 	s.Require().NoError(err)
@@ -239,6 +303,60 @@ func (s *SnapshotServiceSuite) TestDetach_MissingFilePermission() {
 	s.Equal(errorpkg.NewFileNotFoundError(err).Error(), err.Error())
 }
 
+func (s *SnapshotServiceSuite) TestPinAndUnpin() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshot := s.createSnapshot(file.ID)
+
+	pinned, err := service.NewSnapshotService().Pin(snapshot.GetID(), s.users[0].GetID())
+	s.Require().NoError(err)
+	s.True(pinned.IsPinned)
+
+	unpinned, err := service.NewSnapshotService().Unpin(snapshot.GetID(), s.users[0].GetID())
+	s.Require().NoError(err)
+	s.False(unpinned.IsPinned)
+}
+
+func (s *SnapshotServiceSuite) TestPin_MissingFilePermission() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshot := s.createSnapshot(file.ID)
+
+	s.revokeUserPermissionForFile(file, s.users[0])
+
+	_, err = service.NewSnapshotService().Pin(snapshot.GetID(), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileNotFoundError(err).Error(), err.Error())
+}
+
+func (s *SnapshotServiceSuite) TestPatchNote() {
+	org, err := test.CreateOrganization(s.users[0].GetID())
+	s.Require().NoError(err)
+	workspace, err := test.CreateWorkspace(org.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := test.CreateFile(workspace.ID, workspace.RootID, s.users[0].GetID())
+	s.Require().NoError(err)
+	snapshot := s.createSnapshot(file.ID)
+
+	patched, err := service.NewSnapshotService().PatchNote(snapshot.GetID(), dto.SnapshotPatchNoteOptions{
+		Note: helper.ToPtr("Fixed typos."),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("Fixed typos.", *patched.Note)
+
+	patched, err = service.NewSnapshotService().PatchNote(snapshot.GetID(), dto.SnapshotPatchNoteOptions{}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Nil(patched.Note)
+}
+
 func (s *SnapshotServiceSuite) TestPatch() {
 	snapshot := repo.NewSnapshotModelWithOptions(repo.SnapshotNewModelOptions{
 		ID:         helper.NewID(),
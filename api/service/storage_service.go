@@ -27,6 +27,7 @@ type StorageService struct {
 	fileRepo       *repo.FileRepo
 	fileCache      *cache.FileCache
 	fileGuard      *guard.FileGuard
+	trashItemRepo  *repo.TrashItemRepo
 	storageMapper  *storageMapper
 }
 
@@ -60,6 +61,10 @@ func NewStorageService() *StorageService {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		trashItemRepo: repo.NewTrashItemRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
 		storageMapper: newStorageMapper(),
 	}
 }
@@ -91,7 +96,11 @@ func (svc *StorageService) GetAccountUsage(userID string) (*dto.StorageUsage, er
 		if err != nil {
 			return nil, err
 		}
-		b = b + size
+		trashSize, err := svc.trashItemRepo.ComputeSize(w.GetID())
+		if err != nil {
+			return nil, err
+		}
+		b = b + size + trashSize
 		maxBytes = maxBytes + w.GetStorageCapacity()
 	}
 	return svc.storageMapper.mapStorageUsage(b, maxBytes), nil
@@ -116,7 +125,12 @@ func (svc *StorageService) GetWorkspaceUsage(workspaceID string, userID string)
 	if err != nil {
 		return nil, err
 	}
-	return svc.storageMapper.mapStorageUsage(size, workspace.GetStorageCapacity()), nil
+	/* Trashed items keep using storage until they are purged */
+	trashSize, err := svc.trashItemRepo.ComputeSize(workspace.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return svc.storageMapper.mapStorageUsage(size+trashSize, workspace.GetStorageCapacity()), nil
 }
 
 func (svc *StorageService) GetFileUsage(fileID string, userID string) (*dto.StorageUsage, error) {
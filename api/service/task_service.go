@@ -16,6 +16,7 @@ import (
 	"sort"
 
 	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/client"
 	"github.com/kouprlabs/voltaserve/shared/dto"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 	"github.com/kouprlabs/voltaserve/shared/helper"
@@ -30,14 +31,16 @@ import (
 )
 
 type TaskService struct {
-	taskMapper    *mapper.TaskMapper
-	taskCache     *cache.TaskCache
-	taskSearch    *search.TaskSearch
-	taskRepo      *repo.TaskRepo
-	snapshotRepo  *repo.SnapshotRepo
-	snapshotCache *cache.SnapshotCache
-	fileRepo      *repo.FileRepo
-	fileCache     *cache.FileCache
+	taskMapper       *mapper.TaskMapper
+	taskCache        *cache.TaskCache
+	taskSearch       *search.TaskSearch
+	taskRepo         *repo.TaskRepo
+	snapshotRepo     *repo.SnapshotRepo
+	snapshotCache    *cache.SnapshotCache
+	fileRepo         *repo.FileRepo
+	fileCache        *cache.FileCache
+	pipelineClient   client.PipelineClient
+	webhookPublisher *webhookPublisher
 }
 
 func NewTaskService() *TaskService {
@@ -78,6 +81,11 @@ func NewTaskService() *TaskService {
 			config.GetConfig().Redis,
 			config.GetConfig().Environment,
 		),
+		pipelineClient: client.NewPipelineClient(
+			config.GetConfig().ConversionURL,
+			config.GetConfig().Environment.IsTest,
+		),
+		webhookPublisher: newWebhookPublisher(),
 	}
 }
 
@@ -107,6 +115,10 @@ func (svc *TaskService) Patch(id string, opts dto.TaskPatchOptions) (*dto.Task,
 	if err != nil {
 		return nil, err
 	}
+	if task.GetStatus() == model.TaskStatusCancelled {
+		/* A cancelled task is final, late updates from the worker are ignored */
+		return svc.taskMapper.Map(task)
+	}
 	if slices.Contains(opts.Fields, model.TaskFieldName) {
 		task.SetName(*opts.Name)
 	}
@@ -122,7 +134,10 @@ func (svc *TaskService) Patch(id string, opts dto.TaskPatchOptions) (*dto.Task,
 	if slices.Contains(opts.Fields, model.TaskFieldUserID) {
 		task.SetUserID(*opts.UserID)
 	}
+	finished := false
 	if slices.Contains(opts.Fields, model.TaskFieldStatus) {
+		finished = task.GetStatus() != *opts.Status &&
+			(*opts.Status == model.TaskStatusSuccess || *opts.Status == model.TaskStatusError)
 		task.SetStatus(*opts.Status)
 	}
 	if slices.Contains(opts.Fields, model.TaskFieldPayload) {
@@ -131,6 +146,9 @@ func (svc *TaskService) Patch(id string, opts dto.TaskPatchOptions) (*dto.Task,
 	if err := svc.saveAndSync(task); err != nil {
 		return nil, err
 	}
+	if finished {
+		svc.publishFinished(task)
+	}
 	res, err := svc.taskMapper.Map(task)
 	if err != nil {
 		return nil, err
@@ -138,6 +156,37 @@ func (svc *TaskService) Patch(id string, opts dto.TaskPatchOptions) (*dto.Task,
 	return res, nil
 }
 
+// publishFinished notifies the webhook subscriptions of the workspaces whose
+// files the task processed.
+func (svc *TaskService) publishFinished(task model.Task) {
+	snapshots, err := svc.snapshotRepo.FindAllForTask(task.GetID())
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	for _, snapshot := range snapshots {
+		fileIDs, err := svc.fileRepo.FindIDsBySnapshot(snapshot.GetID())
+		if err != nil {
+			logger.GetLogger().Error(err)
+			continue
+		}
+		for _, fileID := range fileIDs {
+			file, err := svc.fileCache.Get(fileID)
+			if err != nil {
+				logger.GetLogger().Error(err)
+				continue
+			}
+			svc.webhookPublisher.publish(file.GetWorkspaceID(), model.WebhookEventTaskFinished, dto.WebhookTaskEventData{
+				ID:     task.GetID(),
+				FileID: file.GetID(),
+				Name:   task.GetName(),
+				Status: task.GetStatus(),
+				Error:  task.GetError(),
+			})
+		}
+	}
+}
+
 func (svc *TaskService) Find(id string, userID string) (*dto.Task, error) {
 	task, err := svc.taskCache.Get(id)
 	if err != nil {
@@ -228,12 +277,40 @@ func (svc *TaskService) Dismiss(id string, userID string) error {
 	if task.GetUserID() != userID {
 		return errorpkg.NewTaskBelongsToAnotherUserError(nil)
 	}
-	if task.GetStatus() != model.TaskStatusSuccess && task.GetStatus() != model.TaskStatusError {
+	if !svc.isDone(task) {
 		return errorpkg.NewTaskIsRunningError(nil)
 	}
 	return svc.deleteAndSync(id)
 }
 
+// Cancel stops the pipeline of the task, whether it is waiting in the queue
+// or already running, the task is then kept in cancelled status.
+func (svc *TaskService) Cancel(id string, userID string) (*dto.Task, error) {
+	task, err := svc.taskCache.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.GetUserID() != userID {
+		return nil, errorpkg.NewTaskBelongsToAnotherUserError(nil)
+	}
+	if task.GetStatus() != model.TaskStatusWaiting && task.GetStatus() != model.TaskStatusRunning {
+		return nil, errorpkg.NewTaskIsNotPendingError(nil)
+	}
+	task.SetName("Cancelled.")
+	task.SetStatus(model.TaskStatusCancelled)
+	if err := svc.saveAndSync(task); err != nil {
+		return nil, err
+	}
+	if err := svc.pipelineClient.Cancel(&dto.PipelineCancelOptions{TaskID: task.GetID()}); err != nil {
+		return nil, err
+	}
+	res, err := svc.taskMapper.Map(task)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (svc *TaskService) DismissAll(userID string) (*dto.TaskDismissAllResult, error) {
 	ids, err := svc.taskRepo.FindIDs(userID)
 	if err != nil {
@@ -248,7 +325,7 @@ func (svc *TaskService) DismissAll(userID string) (*dto.TaskDismissAllResult, er
 		Failed:    make([]string, 0),
 	}
 	for _, t := range authorized {
-		if t.GetStatus() == model.TaskStatusSuccess || t.GetStatus() == model.TaskStatusError {
+		if svc.isDone(t) {
 			if err := svc.deleteAndSync(t.GetID()); err != nil {
 				res.Failed = append(res.Failed, t.GetID())
 			} else {
@@ -263,6 +340,12 @@ func (svc *TaskService) Delete(id string) error {
 	return svc.deleteAndSync(id)
 }
 
+func (svc *TaskService) isDone(task model.Task) bool {
+	return task.GetStatus() == model.TaskStatusSuccess ||
+		task.GetStatus() == model.TaskStatusError ||
+		task.GetStatus() == model.TaskStatusCancelled
+}
+
 func (svc *TaskService) findAll(opts TaskListOptions, userID string) ([]model.Task, error) {
 	var res []model.Task
 	var err error
@@ -335,22 +418,11 @@ func (svc *TaskService) authorize(data []model.Task, userID string) ([]model.Tas
 }
 
 func (svc *TaskService) authorizeIDs(ids []string, userID string) ([]model.Task, error) {
-	var res []model.Task
-	for _, id := range ids {
-		t, err := svc.taskCache.Get(id)
-		if err != nil {
-			var e *errorpkg.ErrorResponse
-			if errors.As(err, &e) && e.Code == errorpkg.NewTaskNotFoundError(nil).Code {
-				continue
-			} else {
-				return nil, err
-			}
-		}
-		if t.GetUserID() == userID {
-			res = append(res, t)
-		}
+	data, err := svc.taskCache.GetMany(ids)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return svc.authorize(data, userID)
 }
 
 func (svc *TaskService) sort(data []model.Task, sortBy string, sortOrder string) []model.Task {
@@ -331,6 +331,67 @@ func (s *TaskServiceSuite) TestDismiss_StatusRunning() {
 	s.Equal(errorpkg.NewTaskIsRunningError(nil).Error(), err.Error())
 }
 
+func (s *TaskServiceSuite) TestCancel() {
+	task, err := service.NewTaskService().Create(dto.TaskCreateOptions{
+		Name:   "task",
+		UserID: s.users[0].GetID(),
+		Status: model.TaskStatusRunning,
+	})
+	s.Require().NoError(err)
+
+	task, err = service.NewTaskService().Cancel(task.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(model.TaskStatusCancelled, task.Status)
+	s.True(task.IsDismissible)
+
+	err = service.NewTaskService().Dismiss(task.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+}
+
+func (s *TaskServiceSuite) TestCancel_UnauthorizedUser() {
+	task, err := service.NewTaskService().Create(dto.TaskCreateOptions{
+		Name:   "task",
+		UserID: s.users[0].GetID(),
+		Status: model.TaskStatusWaiting,
+	})
+	s.Require().NoError(err)
+
+	_, err = service.NewTaskService().Cancel(task.ID, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewTaskBelongsToAnotherUserError(nil).Error(), err.Error())
+}
+
+func (s *TaskServiceSuite) TestCancel_StatusSuccess() {
+	task, err := service.NewTaskService().Create(dto.TaskCreateOptions{
+		Name:   "task",
+		UserID: s.users[0].GetID(),
+		Status: model.TaskStatusSuccess,
+	})
+	s.Require().NoError(err)
+
+	_, err = service.NewTaskService().Cancel(task.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewTaskIsNotPendingError(nil).Error(), err.Error())
+}
+
+func (s *TaskServiceSuite) TestPatch_Cancelled() {
+	task, err := service.NewTaskService().Create(dto.TaskCreateOptions{
+		Name:   "task",
+		UserID: s.users[0].GetID(),
+		Status: model.TaskStatusWaiting,
+	})
+	s.Require().NoError(err)
+	_, err = service.NewTaskService().Cancel(task.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	task, err = service.NewTaskService().Patch(task.ID, dto.TaskPatchOptions{
+		Fields: []string{model.TaskFieldStatus},
+		Status: helper.ToPtr(model.TaskStatusSuccess),
+	})
+	s.Require().NoError(err)
+	s.Equal(model.TaskStatusCancelled, task.Status)
+}
+
 func (s *TaskServiceSuite) TestDismissAll() {
 	_, err := service.NewTaskService().Create(dto.TaskCreateOptions{
 		Name:   "task A",
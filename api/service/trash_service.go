@@ -0,0 +1,377 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"time"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/mapper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+	"github.com/kouprlabs/voltaserve/shared/search"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// TrashCollectInterval is how often items past the retention period are purged.
+	TrashCollectInterval = 1 * time.Hour
+	// TrashCollectBatchSize is the maximum number of items purged per collection.
+	TrashCollectBatchSize = 100
+)
+
+type TrashService struct {
+	trashItemRepo  *repo.TrashItemRepo
+	fileRepo       *repo.FileRepo
+	fileCache      *cache.FileCache
+	fileGuard      *guard.FileGuard
+	fileSearch     *search.FileSearch
+	fileMapper     *mapper.FileMapper
+	fileCoreSvc    *fileCoreService
+	fileDelete     *fileDelete
+	workspaceCache *cache.WorkspaceCache
+	workspaceGuard *guard.WorkspaceGuard
+	taskSvc        *TaskService
+	config         *config.Config
+}
+
+func NewTrashService() *TrashService {
+	return &TrashService{
+		trashItemRepo: repo.NewTrashItemRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileRepo: repo.NewFileRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileSearch: search.NewFileSearch(
+			config.GetConfig().Postgres,
+			config.GetConfig().Search,
+			config.GetConfig().S3,
+			config.GetConfig().Environment,
+		),
+		fileMapper: mapper.NewFileMapper(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileCoreSvc: newFileCoreService(),
+		fileDelete:  newFileDelete(),
+		workspaceCache: cache.NewWorkspaceCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceGuard: guard.NewWorkspaceGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		taskSvc: NewTaskService(),
+		config:  config.GetConfig(),
+	}
+}
+
+type TrashListOptions struct {
+	Page uint64
+	Size uint64
+}
+
+// List returns the items in the trash of the workspace that the user can
+// view, most recently trashed first.
+func (svc *TrashService) List(workspaceID string, opts TrashListOptions, userID string) (*dto.TrashItemList, error) {
+	workspace, err := svc.workspaceCache.Get(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.workspaceGuard.Authorize(userID, workspace, model.PermissionViewer); err != nil {
+		return nil, err
+	}
+	items, err := svc.trashItemRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	authorized := make([]model.TrashItem, 0)
+	for _, item := range items {
+		file, err := svc.fileCache.Get(item.GetFileID())
+		if err != nil {
+			continue
+		}
+		if svc.fileGuard.IsAuthorized(userID, file, model.PermissionViewer) {
+			authorized = append(authorized, item)
+		}
+	}
+	totalElements := uint64(len(authorized))
+	data := make([]*dto.TrashItem, 0)
+	startIndex := (opts.Page - 1) * opts.Size
+	endIndex := startIndex + opts.Size
+	if startIndex < totalElements {
+		if endIndex > totalElements {
+			endIndex = totalElements
+		}
+		for _, item := range authorized[startIndex:endIndex] {
+			mapped, err := svc.mapItem(item, userID)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, mapped)
+		}
+	}
+	return &dto.TrashItemList{
+		Data:          data,
+		TotalPages:    (totalElements + opts.Size - 1) / opts.Size,
+		TotalElements: totalElements,
+		Page:          opts.Page,
+		Size:          uint64(len(data)),
+	}, nil
+}
+
+// Restore moves the item out of the trash, into its original parent unless
+// another one is given.
+func (svc *TrashService) Restore(id string, opts dto.TrashRestoreOptions, userID string) (*dto.File, error) {
+	item, err := svc.trashItemRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := svc.fileCache.Get(item.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	parent, err := svc.getRestoreParent(item, opts, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := svc.fileCoreSvc.getChildWithName(parent.GetID(), file.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if opts.OnConflict != nil && *opts.OnConflict == dto.TrashRestoreOnConflictFail {
+			return nil, errorpkg.NewFileWithSimilarNameExistsError()
+		}
+		file.SetName(helper.UniqueFilename(file.GetName()))
+	}
+	file.SetParentID(helper.ToPtr(parent.GetID()))
+	if err := svc.fileRepo.Save(file); err != nil {
+		return nil, err
+	}
+	if err := svc.fileCache.Set(file); err != nil {
+		return nil, err
+	}
+	if err := svc.trashItemRepo.Delete(item.GetID()); err != nil {
+		return nil, err
+	}
+	tree, err := svc.fileRepo.FindTree(file.GetID())
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.fileSearch.Index(tree); err != nil {
+		return nil, err
+	}
+	res, err := svc.fileMapper.Map(file, userID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Purge deletes the item permanently, along with its descendants and
+// snapshots.
+func (svc *TrashService) Purge(id string, userID string) error {
+	item, err := svc.trashItemRepo.Find(id)
+	if err != nil {
+		return err
+	}
+	file, err := svc.fileCache.Get(item.GetFileID())
+	if err != nil {
+		return err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionOwner); err != nil {
+		return err
+	}
+	task, err := svc.fileDelete.createTask(file, userID)
+	if err != nil {
+		return err
+	}
+	defer func(taskID string) {
+		if err := svc.taskSvc.deleteAndSync(taskID); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(task.GetID())
+	return svc.purge(item)
+}
+
+// Empty purges all the items in the trash of the workspace, it requires
+// ownership of the workspace.
+func (svc *TrashService) Empty(workspaceID string, userID string) (*dto.TrashEmptyResult, error) {
+	workspace, err := svc.workspaceCache.Get(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.workspaceGuard.Authorize(userID, workspace, model.PermissionOwner); err != nil {
+		return nil, err
+	}
+	items, err := svc.trashItemRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.TrashEmptyResult{
+		Failed:    make([]string, 0),
+		Succeeded: make([]string, 0),
+	}
+	for _, item := range items {
+		if err := svc.purge(item); err != nil {
+			logger.GetLogger().Error(err)
+			res.Failed = append(res.Failed, item.GetID())
+		} else {
+			res.Succeeded = append(res.Succeeded, item.GetID())
+		}
+	}
+	return res, nil
+}
+
+// CollectGarbage purges the items that have been in the trash longer than
+// the retention period, and returns how many were purged.
+func (svc *TrashService) CollectGarbage() (int, error) {
+	if svc.config.Limits.TrashRetentionDays <= 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-svc.getRetention())
+	items, err := svc.trashItemRepo.FindCreatedBefore(helper.TimeToString(before), TrashCollectBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, item := range items {
+		if err := svc.purge(item); err != nil {
+			logger.GetLogger().Error(err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// StartGarbageCollector runs CollectGarbage periodically in the background.
+func (svc *TrashService) StartGarbageCollector() {
+	go func() {
+		ticker := time.NewTicker(TrashCollectInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			count, err := svc.CollectGarbage()
+			if err != nil {
+				logger.GetLogger().Error(err)
+				continue
+			}
+			if count > 0 {
+				logger.GetLogger().Infow("Purged expired trash items.", "count", count)
+			}
+		}
+	}()
+}
+
+func (svc *TrashService) purge(item model.TrashItem) error {
+	file, err := svc.fileRepo.Find(item.GetFileID())
+	if err != nil {
+		return err
+	}
+	if err := svc.fileDelete.performDelete(file); err != nil {
+		return err
+	}
+	/* The item is normally deleted along with its file, this covers a purge that was interrupted */
+	return svc.trashItemRepo.Delete(item.GetID())
+}
+
+func (svc *TrashService) getRestoreParent(item model.TrashItem, opts dto.TrashRestoreOptions, userID string) (model.File, error) {
+	var parent model.File
+	if opts.ParentID != nil {
+		var err error
+		parent, err = svc.fileCache.Get(*opts.ParentID)
+		if err != nil {
+			return nil, err
+		}
+	} else if item.GetOriginalParentID() != nil {
+		parent = svc.fileRepo.FindOrNil(*item.GetOriginalParentID())
+	}
+	if parent == nil {
+		/* The original parent was purged, fall back to the workspace root */
+		workspace, err := svc.workspaceCache.Get(item.GetWorkspaceID())
+		if err != nil {
+			return nil, err
+		}
+		parent, err = svc.fileCache.Get(workspace.GetRootID())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parent.GetWorkspaceID() != item.GetWorkspaceID() {
+		return nil, errorpkg.NewFileNotFoundError(nil)
+	}
+	if err := svc.fileGuard.Authorize(userID, parent, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	if parent.GetType() != model.FileTypeFolder {
+		return nil, errorpkg.NewFileIsNotAFolderError(parent)
+	}
+	if err := svc.fileCoreSvc.checkNotInTrash(parent); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+func (svc *TrashService) getRetention() time.Duration {
+	return time.Duration(svc.config.Limits.TrashRetentionDays) * 24 * time.Hour
+}
+
+func (svc *TrashService) mapItem(item model.TrashItem, userID string) (*dto.TrashItem, error) {
+	file, err := svc.fileCache.Get(item.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := svc.fileMapper.Map(file, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.TrashItem{
+		ID:               item.GetID(),
+		WorkspaceID:      item.GetWorkspaceID(),
+		File:             mapped,
+		OriginalParentID: item.GetOriginalParentID(),
+		OriginalPath:     item.GetOriginalPath(),
+		DeletedBy:        item.GetUserID(),
+		CreateTime:       item.GetCreateTime(),
+	}
+	if svc.config.Limits.TrashRetentionDays > 0 {
+		purgeTime := helper.StringToTime(item.GetCreateTime()).Add(svc.getRetention())
+		res.PurgeTime = helper.ToPtr(helper.TimeToString(purgeTime))
+	}
+	return res, nil
+}
@@ -0,0 +1,364 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type TrashServiceTestSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestTrashServiceSuite(t *testing.T) {
+	suite.Run(t, new(TrashServiceTestSuite))
+}
+
+func (s *TrashServiceTestSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *TrashServiceTestSuite) TestList() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	file := s.createFile(workspace, folder.ID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	list, err := service.NewTrashService().List(workspace.ID, service.TrashListOptions{Page: 1, Size: 10}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list.Data, 1)
+	s.Equal(file.ID, list.Data[0].File.ID)
+	s.Equal("/folder/file.txt", list.Data[0].OriginalPath)
+	s.Equal(folder.ID, *list.Data[0].OriginalParentID)
+	s.Equal(s.users[0].GetID(), list.Data[0].DeletedBy)
+
+	children, err := service.NewFileService().Find([]string{file.ID}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(children)
+}
+
+func (s *TrashServiceTestSuite) TestList_MissingPermission() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewTrashService().List(workspace.ID, service.TrashListOptions{Page: 1, Size: 10}, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewWorkspaceNotFoundError(err).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestStorageUsage() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	before, err := service.NewStorageService().GetWorkspaceUsage(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Positive(before.Bytes)
+
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+	after, err := service.NewStorageService().GetWorkspaceUsage(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(before.Bytes, after.Bytes)
+
+	item := s.findItem(workspace, file.ID)
+	s.Require().NoError(service.NewTrashService().Purge(item.ID, s.users[0].GetID()))
+	after, err = service.NewStorageService().GetWorkspaceUsage(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Zero(after.Bytes)
+}
+
+func (s *TrashServiceTestSuite) TestRestore() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	file := s.createFile(workspace, folder.ID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+	item := s.findItem(workspace, file.ID)
+
+	restored, err := service.NewTrashService().Restore(item.ID, dto.TrashRestoreOptions{}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(file.ID, restored.ID)
+	s.Equal("file.txt", restored.Name)
+	s.Equal(folder.ID, *restored.ParentID)
+
+	files, err := service.NewFileService().Find([]string{file.ID}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Len(files, 1)
+}
+
+func (s *TrashServiceTestSuite) TestRestore_NameConflict() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+	s.createFile(workspace, workspace.RootID, "file.txt")
+	item := s.findItem(workspace, file.ID)
+
+	_, err = service.NewTrashService().Restore(item.ID, dto.TrashRestoreOptions{
+		OnConflict: helper.ToPtr(dto.TrashRestoreOnConflictFail),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileWithSimilarNameExistsError().Error(), err.Error())
+
+	restored, err := service.NewTrashService().Restore(item.ID, dto.TrashRestoreOptions{}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.NotEqual("file.txt", restored.Name)
+	s.Equal(workspace.RootID, *restored.ParentID)
+}
+
+func (s *TrashServiceTestSuite) TestRestore_OriginalParentPurged() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	file := s.createFile(workspace, folder.ID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+	s.Require().NoError(service.NewFileService().Delete(folder.ID, s.users[0].GetID()))
+	s.Require().NoError(service.NewTrashService().Purge(s.findItem(workspace, folder.ID).ID, s.users[0].GetID()))
+
+	restored, err := service.NewTrashService().Restore(s.findItem(workspace, file.ID).ID, dto.TrashRestoreOptions{}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(workspace.RootID, *restored.ParentID)
+}
+
+func (s *TrashServiceTestSuite) TestRestore_ParentInTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	file := s.createFile(workspace, folder.ID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+	s.Require().NoError(service.NewFileService().Delete(folder.ID, s.users[0].GetID()))
+
+	parent, err := repo.NewFileRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).Find(folder.ID)
+	s.Require().NoError(err)
+
+	_, err = service.NewTrashService().Restore(s.findItem(workspace, file.ID).ID, dto.TrashRestoreOptions{}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(parent).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestEmpty() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	s.createFile(workspace, folder.ID, "file.txt")
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(folder.ID, s.users[0].GetID()))
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	res, err := service.NewTrashService().Empty(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Len(res.Succeeded, 2)
+	s.Empty(res.Failed)
+
+	list, err := service.NewTrashService().List(workspace.ID, service.TrashListOptions{Page: 1, Size: 10}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(list.Data)
+}
+
+func (s *TrashServiceTestSuite) TestStore_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewFileService().Store(file.ID, service.FileStoreOptions{
+		Path: helper.ToPtr(filepath.Join("fixtures", "files", "file.txt")),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestPatchName_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewFileService().PatchName(file.ID, "renamed.txt", s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestDownloadOriginalBuffer_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewFileService().DownloadOriginalBuffer(file.ID, "", new(bytes.Buffer), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestGrantUserPermission_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	err = service.NewFileService().GrantUserPermission([]string{file.ID}, s.users[1].GetID(), model.PermissionViewer, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestRevokeUserPermission_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().GrantUserPermission([]string{file.ID}, s.users[1].GetID(), model.PermissionViewer, s.users[0].GetID()))
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	err = service.NewFileService().RevokeUserPermission([]string{file.ID}, s.users[1].GetID(), s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestGrantGroupPermission_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	group, err := test.CreateGroup(workspace.Organization.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	err = service.NewFileService().GrantGroupPermission([]string{file.ID}, group.ID, model.PermissionViewer, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestRevokeGroupPermission_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	group, err := test.CreateGroup(workspace.Organization.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NoError(service.NewFileService().GrantGroupPermission([]string{file.ID}, group.ID, model.PermissionViewer, s.users[0].GetID()))
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	err = service.NewFileService().RevokeGroupPermission([]string{file.ID}, group.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestCopy_SourceInTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewFileService().Copy(file.ID, folder.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestList_FolderInTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	folder := s.createFolder(workspace, workspace.RootID, "folder")
+	s.createFile(workspace, folder.ID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(folder.ID, s.users[0].GetID()))
+
+	_, err = service.NewFileService().List(folder.ID, service.FileListOptions{Page: 1, Size: 10}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(folder.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestActivateSnapshot_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewSnapshotService().Activate(file.Snapshot.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) TestDetachSnapshot_InTrash() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFile(workspace, workspace.RootID, "file.txt")
+	s.Require().NoError(service.NewFileService().Delete(file.ID, s.users[0].GetID()))
+
+	_, err = service.NewSnapshotService().Detach(file.Snapshot.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileIsInTrashError(s.findFile(file.ID)).Error(), err.Error())
+}
+
+func (s *TrashServiceTestSuite) createFolder(workspace *dto.Workspace, parentID string, name string) *dto.File {
+	folder, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        name,
+		Type:        model.FileTypeFolder,
+		ParentID:    parentID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	return folder
+}
+
+func (s *TrashServiceTestSuite) createFile(workspace *dto.Workspace, parentID string, name string) *dto.File {
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        name,
+		Type:        model.FileTypeFile,
+		ParentID:    parentID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err = service.NewFileService().Store(file.ID, service.FileStoreOptions{
+		Path: helper.ToPtr(filepath.Join("fixtures", "files", "file.txt")),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	return file
+}
+
+func (s *TrashServiceTestSuite) findItem(workspace *dto.Workspace, fileID string) *dto.TrashItem {
+	list, err := service.NewTrashService().List(workspace.ID, service.TrashListOptions{Page: 1, Size: 100}, s.users[0].GetID())
+	s.Require().NoError(err)
+	for _, item := range list.Data {
+		if item.File.ID == fileID {
+			return item
+		}
+	}
+	s.FailNow("trash item not found")
+	return nil
+}
+
+func (s *TrashServiceTestSuite) findFile(id string) model.File {
+	file, err := repo.NewFileRepo(
+		config.GetConfig().Postgres,
+		config.GetConfig().Environment,
+	).Find(id)
+	s.Require().NoError(err)
+	return file
+}
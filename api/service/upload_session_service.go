@@ -0,0 +1,494 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// UploadSessionMaxParts is the maximum number of parts of an S3 multipart upload.
+	UploadSessionMaxParts = 10000
+	// UploadSessionMinPartSize is the minimum size of every part except the last one, as required by S3.
+	UploadSessionMinPartSize = 5 * 1024 * 1024
+	// UploadSessionCollectInterval is how often abandoned sessions are garbage collected.
+	UploadSessionCollectInterval = 15 * time.Minute
+)
+
+type UploadSessionService struct {
+	uploadSessionRepo *repo.UploadSessionRepo
+	fileCache         *cache.FileCache
+	fileGuard         *guard.FileGuard
+	fileCreate        *fileCreate
+	fileCoreSvc       *fileCoreService
+	fileStore         *fileStore
+	workspaceCache    *cache.WorkspaceCache
+	workspaceGuard    *guard.WorkspaceGuard
+	workspaceSvc      *WorkspaceService
+	s3                infra.S3Manager
+	config            *config.Config
+}
+
+func NewUploadSessionService() *UploadSessionService {
+	return &UploadSessionService{
+		uploadSessionRepo: repo.NewUploadSessionRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		fileCache: cache.NewFileCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileGuard: guard.NewFileGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		fileCreate:  newFileCreate(),
+		fileCoreSvc: newFileCoreService(),
+		fileStore:   newFileStore(),
+		workspaceCache: cache.NewWorkspaceCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceGuard: guard.NewWorkspaceGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceSvc: NewWorkspaceService(),
+		s3:           infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		config:       config.GetConfig(),
+	}
+}
+
+func (svc *UploadSessionService) Create(opts dto.UploadSessionCreateOptions, userID string) (*dto.UploadSession, error) {
+	insertOpts, err := svc.getInsertOptions(opts, userID)
+	if err != nil {
+		return nil, err
+	}
+	hasEnoughSpace, err := svc.workspaceSvc.HasEnoughSpaceForByteSize(insertOpts.WorkspaceID, opts.Size, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasEnoughSpace {
+		return nil, errorpkg.NewStorageLimitExceededError()
+	}
+	insertOpts.S3UploadID, err = svc.s3.NewMultipartUpload(insertOpts.Key, insertOpts.ContentType, insertOpts.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	session, err := svc.uploadSessionRepo.Insert(insertOpts)
+	if err != nil {
+		if err := svc.s3.AbortMultipartUpload(insertOpts.Key, insertOpts.S3UploadID, insertOpts.Bucket); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		return nil, err
+	}
+	return svc.mapSession(session, nil), nil
+}
+
+func (svc *UploadSessionService) Find(id string, userID string) (*dto.UploadSession, error) {
+	session, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := svc.uploadSessionRepo.FindParts(session.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapSession(session, parts), nil
+}
+
+// PutChunk uploads the chunk with the given number as a part of the multipart
+// upload. Chunks are numbered from 1, can be sent in any order, and sending the
+// same number twice replaces the previous chunk.
+func (svc *UploadSessionService) PutChunk(id string, number int, reader io.Reader, size int64, userID string) (*dto.UploadSession, error) {
+	session, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if number < 1 || number > UploadSessionMaxParts {
+		return nil, errorpkg.NewInvalidUploadChunkError(number, "number is out of range.")
+	}
+	if size == 0 {
+		return nil, errorpkg.NewInvalidUploadChunkError(number, "chunk is empty.")
+	}
+	if size > helper.MegabyteToByte(svc.config.Limits.UploadChunkMB) {
+		return nil, errorpkg.NewInvalidUploadChunkError(
+			number,
+			fmt.Sprintf("chunk exceeds %d MB.", svc.config.Limits.UploadChunkMB),
+		)
+	}
+	// The session stays locked until the part is recorded, and Finalize claims
+	// it only once no chunk holds the lock, so no part changes while assembling
+	if err := svc.uploadSessionRepo.Transaction(func(tx *repo.UploadSessionRepo) error {
+		uploading, err := tx.LockUploading(session.GetID())
+		if err != nil {
+			return err
+		}
+		if !uploading {
+			return errorpkg.NewUploadSessionFinalizingError()
+		}
+		parts, err := tx.FindParts(session.GetID())
+		if err != nil {
+			return err
+		}
+		received := size
+		for _, p := range parts {
+			if p.GetNumber() != number {
+				received += p.GetSize()
+			}
+		}
+		if received > session.GetSize() {
+			return errorpkg.NewUploadSessionSizeExceededError(session.GetSize())
+		}
+		etag, err := svc.s3.PutObjectPart(session.GetKey(), session.GetS3UploadID(), number, reader, size, session.GetBucket())
+		if err != nil {
+			return err
+		}
+		return tx.UpsertPart(repo.UploadSessionPartUpsertOptions{
+			SessionID: session.GetID(),
+			Number:    number,
+			Size:      size,
+			ETag:      etag,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	/* Every chunk keeps the session alive, so that only abandoned sessions expire */
+	if err := svc.uploadSessionRepo.Touch(session.GetID(), svc.newExpiryTime()); err != nil {
+		return nil, err
+	}
+	return svc.Find(session.GetID(), userID)
+}
+
+// Finalize completes the multipart upload and stores the resulting object as a
+// new snapshot, either of the file targeted by the session or of a new file.
+// The session is claimed first, so that concurrent calls cannot assemble the
+// same upload twice, and chunks cannot be replaced while it is being assembled.
+func (svc *UploadSessionService) Finalize(id string, userID string) (*dto.File, error) {
+	session, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := svc.uploadSessionRepo.UpdateStatus(
+		session.GetID(),
+		model.UploadSessionStatusUploading,
+		model.UploadSessionStatusFinalizing,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errorpkg.NewUploadSessionFinalizingError()
+	}
+	file, err := svc.assemble(session, userID)
+	if err != nil {
+		/* Nothing was assembled, so the client can resume the upload */
+		if _, err := svc.uploadSessionRepo.UpdateStatus(
+			session.GetID(),
+			model.UploadSessionStatusFinalizing,
+			model.UploadSessionStatusUploading,
+		); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		return nil, err
+	}
+	/* The multipart upload no longer exists past this point, so the session is useless even if storing fails */
+	if err := svc.uploadSessionRepo.Delete(session.GetID()); err != nil {
+		logger.GetLogger().Error(err)
+	}
+	if file == nil {
+		file, err = svc.fileCreate.create(FileCreateOptions{
+			WorkspaceID: session.GetWorkspaceID(),
+			Name:        session.GetName(),
+			Type:        model.FileTypeFile,
+			ParentID:    *session.GetParentID(),
+		}, userID)
+		if err != nil {
+			svc.removeObject(session)
+			return nil, err
+		}
+	}
+	res, err := svc.fileStore.store(file.ID, FileStoreOptions{
+		S3Reference: &model.S3Reference{
+			Bucket:      session.GetBucket(),
+			Key:         session.GetKey(),
+			SnapshotID:  session.GetSnapshotID(),
+			Size:        session.GetSize(),
+			ContentType: session.GetContentType(),
+		},
+	}, userID)
+	if err != nil {
+		svc.removeObject(session)
+		return nil, err
+	}
+	return res, nil
+}
+
+func (svc *UploadSessionService) Delete(id string, userID string) error {
+	session, err := svc.find(id, userID)
+	if err != nil {
+		return err
+	}
+	if session.GetStatus() != model.UploadSessionStatusUploading {
+		return errorpkg.NewUploadSessionFinalizingError()
+	}
+	if err := svc.s3.AbortMultipartUpload(session.GetKey(), session.GetS3UploadID(), session.GetBucket()); err != nil {
+		return err
+	}
+	if err := svc.uploadSessionRepo.Delete(session.GetID()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CollectGarbage aborts the multipart uploads of expired sessions and deletes
+// them, it returns the number of collected sessions.
+func (svc *UploadSessionService) CollectGarbage() (int, error) {
+	sessions, err := svc.uploadSessionRepo.DeleteExpired(helper.NewTimeString())
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range sessions {
+		if err := svc.s3.AbortMultipartUpload(s.GetKey(), s.GetS3UploadID(), s.GetBucket()); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	return len(sessions), nil
+}
+
+// StartGarbageCollector runs CollectGarbage periodically in the background.
+// Every API replica can run it, expired sessions are collected only once.
+func (svc *UploadSessionService) StartGarbageCollector() {
+	go func() {
+		ticker := time.NewTicker(UploadSessionCollectInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			count, err := svc.CollectGarbage()
+			if err != nil {
+				logger.GetLogger().Error(err)
+				continue
+			}
+			if count > 0 {
+				logger.GetLogger().Infow("Collected expired upload sessions.", "count", count)
+			}
+		}
+	}()
+}
+
+func (svc *UploadSessionService) getInsertOptions(opts dto.UploadSessionCreateOptions, userID string) (repo.UploadSessionInsertOptions, error) {
+	res := repo.UploadSessionInsertOptions{
+		ID:         helper.NewID(),
+		UserID:     userID,
+		Size:       opts.Size,
+		SnapshotID: helper.NewID(),
+		ExpiryTime: svc.newExpiryTime(),
+	}
+	if opts.FileID != nil {
+		file, err := svc.fileCache.Get(*opts.FileID)
+		if err != nil {
+			return repo.UploadSessionInsertOptions{}, err
+		}
+		if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+			return repo.UploadSessionInsertOptions{}, err
+		}
+		if file.GetType() != model.FileTypeFile {
+			return repo.UploadSessionInsertOptions{}, errorpkg.NewFileIsNotAFileError(file)
+		}
+		res.FileID = helper.ToPtr(file.GetID())
+		res.WorkspaceID = file.GetWorkspaceID()
+		res.Name = file.GetName()
+	} else {
+		workspace, err := svc.workspaceCache.Get(opts.WorkspaceID)
+		if err != nil {
+			return repo.UploadSessionInsertOptions{}, err
+		}
+		if err = svc.workspaceGuard.Authorize(userID, workspace, model.PermissionViewer); err != nil {
+			return repo.UploadSessionInsertOptions{}, err
+		}
+		parentID := workspace.GetRootID()
+		if opts.ParentID != nil {
+			parentID = *opts.ParentID
+		}
+		if err := svc.fileCreate.validateParent(parentID, userID); err != nil {
+			return repo.UploadSessionInsertOptions{}, err
+		}
+		/* Fail early rather than after the whole file has been uploaded */
+		if len(helper.PathFromFilename(opts.Name)) == 1 {
+			existing, err := svc.fileCoreSvc.getChildWithName(parentID, opts.Name)
+			if err != nil {
+				return repo.UploadSessionInsertOptions{}, err
+			}
+			if existing != nil {
+				return repo.UploadSessionInsertOptions{}, errorpkg.NewFileWithSimilarNameExistsError()
+			}
+		}
+		res.ParentID = &parentID
+		res.WorkspaceID = workspace.GetID()
+		res.Name = opts.Name
+	}
+	if opts.Name != "" {
+		res.Name = opts.Name
+	}
+	workspace, err := svc.workspaceCache.Get(res.WorkspaceID)
+	if err != nil {
+		return repo.UploadSessionInsertOptions{}, err
+	}
+	res.Bucket = workspace.GetBucket()
+	res.Key = res.SnapshotID + "/original" + strings.ToLower(filepath.Ext(res.Name))
+	if opts.ContentType != nil {
+		res.ContentType = *opts.ContentType
+	} else if contentType := mime.TypeByExtension(filepath.Ext(res.Name)); contentType != "" {
+		res.ContentType = contentType
+	} else {
+		res.ContentType = "application/octet-stream"
+	}
+	return res, nil
+}
+
+func (svc *UploadSessionService) find(id string, userID string) (model.UploadSession, error) {
+	session, err := svc.uploadSessionRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	/* Sessions are private to the user who created them */
+	if session.GetUserID() != userID {
+		return nil, errorpkg.NewUploadSessionNotFoundError(nil)
+	}
+	if time.Now().After(helper.StringToTime(session.GetExpiryTime())) {
+		return nil, errorpkg.NewUploadSessionNotFoundError(nil)
+	}
+	return session, nil
+}
+
+// assemble checks that the session is complete and that the user can still
+// write to its target, then completes the multipart upload. It returns the
+// target file, or nil if the session creates a new file.
+func (svc *UploadSessionService) assemble(session model.UploadSession, userID string) (*dto.File, error) {
+	parts, err := svc.uploadSessionRepo.FindParts(session.GetID())
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.checkComplete(session, parts); err != nil {
+		return nil, err
+	}
+	file, err := svc.authorizeTarget(session, userID)
+	if err != nil {
+		return nil, err
+	}
+	var completeParts []minio.CompletePart
+	for _, p := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: p.GetNumber(), ETag: p.GetETag()})
+	}
+	if err := svc.s3.CompleteMultipartUpload(session.GetKey(), session.GetS3UploadID(), completeParts, session.GetBucket()); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// authorizeTarget checks again the permissions the session was created with,
+// since they might have been revoked while the upload was in progress. It
+// returns the target file, or nil if the session creates a new file.
+func (svc *UploadSessionService) authorizeTarget(session model.UploadSession, userID string) (*dto.File, error) {
+	if session.GetFileID() == nil {
+		if err := svc.fileCreate.validateParent(*session.GetParentID(), userID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	file, err := svc.fileCache.Get(*session.GetFileID())
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.fileGuard.Authorize(userID, file, model.PermissionEditor); err != nil {
+		return nil, err
+	}
+	return &dto.File{ID: file.GetID()}, nil
+}
+
+func (svc *UploadSessionService) checkComplete(session model.UploadSession, parts []model.UploadSessionPart) error {
+	offset := svc.computeOffset(parts)
+	if offset != session.GetSize() || len(parts) == 0 || parts[len(parts)-1].GetNumber() != len(parts) {
+		return errorpkg.NewUploadSessionIncompleteError(offset, session.GetSize())
+	}
+	for i, p := range parts {
+		if i < len(parts)-1 && p.GetSize() < UploadSessionMinPartSize {
+			return errorpkg.NewInvalidUploadChunkError(p.GetNumber(), "only the last chunk can be smaller than 5 MiB.")
+		}
+	}
+	return nil
+}
+
+// computeOffset returns the number of bytes received contiguously from the
+// first chunk, which is where the client should resume the upload.
+func (svc *UploadSessionService) computeOffset(parts []model.UploadSessionPart) int64 {
+	var res int64
+	for i, p := range parts {
+		if p.GetNumber() != i+1 {
+			break
+		}
+		res += p.GetSize()
+	}
+	return res
+}
+
+func (svc *UploadSessionService) newExpiryTime() string {
+	return helper.TimeToString(time.Now().Add(time.Duration(svc.config.Limits.UploadSessionExpiryHours) * time.Hour))
+}
+
+func (svc *UploadSessionService) removeObject(session model.UploadSession) {
+	if err := svc.s3.RemoveObject(session.GetKey(), session.GetBucket(), minio.RemoveObjectOptions{}); err != nil {
+		logger.GetLogger().Error(err)
+	}
+}
+
+func (svc *UploadSessionService) mapSession(session model.UploadSession, parts []model.UploadSessionPart) *dto.UploadSession {
+	res := &dto.UploadSession{
+		ID:          session.GetID(),
+		WorkspaceID: session.GetWorkspaceID(),
+		ParentID:    session.GetParentID(),
+		FileID:      session.GetFileID(),
+		Name:        session.GetName(),
+		Size:        session.GetSize(),
+		Offset:      svc.computeOffset(parts),
+		Parts:       make([]*dto.UploadSessionPart, 0),
+		ExpiryTime:  session.GetExpiryTime(),
+		CreateTime:  session.GetCreateTime(),
+		UpdateTime:  session.GetUpdateTime(),
+	}
+	for _, p := range parts {
+		res.Parts = append(res.Parts, &dto.UploadSessionPart{Number: p.GetNumber(), Size: p.GetSize()})
+	}
+	return res
+}
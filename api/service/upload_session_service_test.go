@@ -0,0 +1,308 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type UploadSessionServiceTestSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestUploadSessionServiceSuite(t *testing.T) {
+	suite.Run(t, new(UploadSessionServiceTestSuite))
+}
+
+func (s *UploadSessionServiceTestSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	first := bytes.Repeat([]byte("a"), service.UploadSessionMinPartSize)
+	second := []byte("bcd")
+
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        int64(len(first) + len(second)),
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(int64(0), session.Offset)
+
+	session = s.putChunk(session.ID, 2, second)
+	s.Equal(int64(0), session.Offset)
+	session = s.putChunk(session.ID, 1, first)
+	s.Equal(session.Size, session.Offset)
+
+	file, err := service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("file.txt", file.Name)
+	s.Require().NotNil(file.Snapshot)
+	s.Equal(int64(1), file.Snapshot.Version)
+
+	buf := new(bytes.Buffer)
+	_, err = service.NewFileService().DownloadOriginalBuffer(file.ID, "", buf, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal(append(first, second...), buf.Bytes())
+
+	_, err = service.NewUploadSessionService().Find(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize_ExistingFile() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		FileID: helper.ToPtr(file.ID),
+		Size:   3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Equal("file.txt", session.Name)
+	s.putChunk(session.ID, 1, []byte("abc"))
+
+	file, err = service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NotNil(file.Snapshot)
+	s.Equal(int64(3), file.Snapshot.Original.Size)
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize_Incomplete() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        6,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.putChunk(session.ID, 1, []byte("abc"))
+
+	_, err = service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionIncompleteError(3, 6).Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize_IncompleteCanResume() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionIncompleteError(0, 3).Error(), err.Error())
+
+	s.putChunk(session.ID, 1, []byte("abc"))
+	_, err = service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize_AlreadyFinalizing() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.putChunk(session.ID, 1, []byte("abc"))
+
+	/* Simulate a concurrent Finalize that claimed the session first */
+	claimed, err := repo.NewUploadSessionRepo(config.GetConfig().Postgres, config.GetConfig().Environment).
+		UpdateStatus(session.ID, model.UploadSessionStatusUploading, model.UploadSessionStatusFinalizing)
+	s.Require().NoError(err)
+	s.Require().True(claimed)
+
+	_, err = service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionFinalizingError().Error(), err.Error())
+
+	_, err = service.NewUploadSessionService().PutChunk(session.ID, 1, bytes.NewReader([]byte("xyz")), 3, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionFinalizingError().Error(), err.Error())
+
+	err = service.NewUploadSessionService().Delete(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionFinalizingError().Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestFinalize_WaitsForChunks() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.putChunk(session.ID, 1, []byte("abc"))
+
+	/* Simulate a chunk that is still being stored */
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	chunkDone := make(chan error, 1)
+	go func() {
+		chunkDone <- repo.NewUploadSessionRepo(config.GetConfig().Postgres, config.GetConfig().Environment).
+			Transaction(func(tx *repo.UploadSessionRepo) error {
+				uploading, err := tx.LockUploading(session.ID)
+				if err != nil {
+					return err
+				}
+				if !uploading {
+					return errorpkg.NewUploadSessionFinalizingError()
+				}
+				close(locked)
+				<-release
+				return nil
+			})
+	}()
+	<-locked
+
+	finalized := make(chan error, 1)
+	go func() {
+		_, err := service.NewUploadSessionService().Finalize(session.ID, s.users[0].GetID())
+		finalized <- err
+	}()
+	s.Never(func() bool { return len(finalized) > 0 }, 500*time.Millisecond, 50*time.Millisecond)
+
+	close(release)
+	s.Require().NoError(<-chunkDone)
+	s.Require().NoError(<-finalized)
+}
+
+func (s *UploadSessionServiceTestSuite) TestPutChunk_ChunkTooLarge() {
+	s.T().Setenv("LIMITS_UPLOAD_CHUNK_MB", "1")
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	size := helper.MegabyteToByte(1) + 1
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        size,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().PutChunk(session.ID, 1, bytes.NewReader(make([]byte, size)), size, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewInvalidUploadChunkError(1, "chunk exceeds 1 MB.").Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestPutChunk_SizeExceeded() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        2,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().PutChunk(session.ID, 1, bytes.NewReader([]byte("abc")), 3, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionSizeExceededError(2).Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestCreate_FileWithSimilarNameExists() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Type:        model.FileTypeFile,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewFileWithSimilarNameExistsError().Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestFind_AnotherUser() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().Find(session.ID, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) TestDelete() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	session, err := service.NewUploadSessionService().Create(dto.UploadSessionCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "file.txt",
+		Size:        3,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.putChunk(session.ID, 1, []byte("abc"))
+
+	err = service.NewUploadSessionService().Delete(session.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	_, err = service.NewUploadSessionService().Find(session.ID, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewUploadSessionNotFoundError(nil).Error(), err.Error())
+}
+
+func (s *UploadSessionServiceTestSuite) putChunk(id string, number int, data []byte) *dto.UploadSession {
+	res, err := service.NewUploadSessionService().PutChunk(id, number, bytes.NewReader(data), int64(len(data)), s.users[0].GetID())
+	s.Require().NoError(err)
+	return res
+}
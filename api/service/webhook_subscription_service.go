@@ -0,0 +1,444 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/guard"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+	"github.com/kouprlabs/voltaserve/shared/repo"
+
+	"github.com/kouprlabs/voltaserve/api/config"
+	"github.com/kouprlabs/voltaserve/api/logger"
+)
+
+const (
+	// WebhookDispatchInterval is how often the outbox is checked for due deliveries.
+	WebhookDispatchInterval = 10 * time.Second
+	// WebhookDispatchBatchSize is the maximum number of deliveries attempted per check.
+	WebhookDispatchBatchSize = 20
+	// WebhookDeliveryTimeout is how long to wait for the receiver to respond.
+	WebhookDeliveryTimeout = 10 * time.Second
+	// WebhookDeliveryLease is how long a claimed delivery is hidden from other replicas, it must exceed the timeout.
+	WebhookDeliveryLease = 2 * time.Minute
+	// WebhookMaxAttempts is the number of attempts after which a delivery is marked as failed.
+	WebhookMaxAttempts = 8
+	// WebhookRetryBaseDelay is the delay before the first retry, it doubles on every attempt.
+	WebhookRetryBaseDelay = 30 * time.Second
+	// WebhookRetryMaxDelay caps the delay between two attempts.
+	WebhookRetryMaxDelay = 6 * time.Hour
+	// WebhookSecretSize is the number of random bytes of a subscription secret.
+	WebhookSecretSize = 32
+)
+
+type WebhookSubscriptionService struct {
+	webhookSubscriptionRepo *repo.WebhookSubscriptionRepo
+	webhookDeliveryClient   *client.WebhookDeliveryClient
+	workspaceCache          *cache.WorkspaceCache
+	workspaceGuard          *guard.WorkspaceGuard
+	config                  *config.Config
+}
+
+func NewWebhookSubscriptionService() *WebhookSubscriptionService {
+	return &WebhookSubscriptionService{
+		webhookSubscriptionRepo: repo.NewWebhookSubscriptionRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+		webhookDeliveryClient: client.NewWebhookDeliveryClient(
+			WebhookDeliveryTimeout,
+			config.GetConfig().Environment.IsDevelopment,
+		),
+		workspaceCache: cache.NewWorkspaceCache(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		workspaceGuard: guard.NewWorkspaceGuard(
+			config.GetConfig().Postgres,
+			config.GetConfig().Redis,
+			config.GetConfig().Environment,
+		),
+		config: config.GetConfig(),
+	}
+}
+
+// Create subscribes the URL to events of the workspace. The returned secret is
+// used to sign deliveries, it can't be retrieved afterwards.
+func (svc *WebhookSubscriptionService) Create(opts dto.WebhookSubscriptionCreateOptions, userID string) (*dto.WebhookSubscription, error) {
+	if err := svc.authorizeWorkspace(opts.WorkspaceID, userID); err != nil {
+		return nil, err
+	}
+	if err := svc.webhookDeliveryClient.Validate(opts.URL); err != nil {
+		return nil, errorpkg.NewWebhookURLNotAllowedError(err)
+	}
+	secret, err := svc.newSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := svc.webhookSubscriptionRepo.Insert(repo.WebhookSubscriptionInsertOptions{
+		ID:          helper.NewID(),
+		WorkspaceID: opts.WorkspaceID,
+		UserID:      userID,
+		URL:         opts.URL,
+		Secret:      secret,
+		Events:      svc.uniqueEvents(opts.Events),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := svc.mapSubscription(subscription)
+	res.Secret = helper.ToPtr(subscription.GetSecret())
+	return res, nil
+}
+
+func (svc *WebhookSubscriptionService) Find(id string, userID string) (*dto.WebhookSubscription, error) {
+	subscription, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapSubscription(subscription), nil
+}
+
+func (svc *WebhookSubscriptionService) List(workspaceID string, userID string) ([]*dto.WebhookSubscription, error) {
+	if err := svc.authorizeWorkspace(workspaceID, userID); err != nil {
+		return nil, err
+	}
+	subscriptions, err := svc.webhookSubscriptionRepo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*dto.WebhookSubscription, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		res = append(res, svc.mapSubscription(s))
+	}
+	return res, nil
+}
+
+func (svc *WebhookSubscriptionService) Patch(id string, opts dto.WebhookSubscriptionPatchOptions, userID string) (*dto.WebhookSubscription, error) {
+	subscription, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.URL != nil {
+		if err := svc.webhookDeliveryClient.Validate(*opts.URL); err != nil {
+			return nil, errorpkg.NewWebhookURLNotAllowedError(err)
+		}
+		subscription.SetURL(*opts.URL)
+	}
+	if opts.Events != nil {
+		subscription.SetEvents(svc.uniqueEvents(opts.Events))
+	}
+	if opts.IsActive != nil {
+		subscription.SetIsActive(*opts.IsActive)
+	}
+	if err := svc.webhookSubscriptionRepo.Save(subscription); err != nil {
+		return nil, err
+	}
+	return svc.mapSubscription(subscription), nil
+}
+
+func (svc *WebhookSubscriptionService) Delete(id string, userID string) error {
+	subscription, err := svc.find(id, userID)
+	if err != nil {
+		return err
+	}
+	return svc.webhookSubscriptionRepo.Delete(subscription.GetID())
+}
+
+func (svc *WebhookSubscriptionService) ListDeliveries(id string, page uint64, size uint64, userID string) (*dto.WebhookDeliveryList, error) {
+	subscription, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := svc.webhookSubscriptionRepo.CountDeliveries(subscription.GetID())
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := svc.webhookSubscriptionRepo.FindDeliveries(subscription.GetID(), page, size)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]*dto.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		data = append(data, svc.mapDelivery(d))
+	}
+	return &dto.WebhookDeliveryList{
+		Data:          data,
+		TotalPages:    uint64(math.Ceil(float64(count) / float64(size))),
+		TotalElements: uint64(count),
+		Page:          page,
+		Size:          size,
+	}, nil
+}
+
+// Redeliver queues a new delivery of the same event, the original delivery is
+// left as is so that the log keeps track of both.
+func (svc *WebhookSubscriptionService) Redeliver(id string, deliveryID string, userID string) (*dto.WebhookDelivery, error) {
+	subscription, err := svc.find(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := svc.webhookSubscriptionRepo.FindDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.GetSubscriptionID() != subscription.GetID() {
+		return nil, errorpkg.NewWebhookDeliveryNotFoundError(nil)
+	}
+	res, err := svc.webhookSubscriptionRepo.InsertDelivery(repo.WebhookDeliveryInsertOptions{
+		ID:             helper.NewID(),
+		SubscriptionID: subscription.GetID(),
+		EventType:      delivery.GetEventType(),
+		Payload:        delivery.GetPayload(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return svc.mapDelivery(res), nil
+}
+
+// Dispatch attempts the deliveries that are due, and returns how many were
+// attempted. Failed attempts are retried with an exponential backoff, until
+// WebhookMaxAttempts is reached.
+func (svc *WebhookSubscriptionService) Dispatch() (int, error) {
+	now := time.Now()
+	deliveries, err := svc.webhookSubscriptionRepo.ClaimDueDeliveries(
+		helper.TimeToString(now),
+		helper.TimeToString(now.Add(WebhookDeliveryLease)),
+		WebhookDispatchBatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deliveries {
+		if err := svc.attempt(d); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// StartDispatcher runs Dispatch periodically in the background. Every API
+// replica can run it, a delivery is only claimed by one of them at a time.
+func (svc *WebhookSubscriptionService) StartDispatcher() {
+	go func() {
+		ticker := time.NewTicker(WebhookDispatchInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				count, err := svc.Dispatch()
+				if err != nil {
+					logger.GetLogger().Error(err)
+					break
+				}
+				/* Drain the outbox before waiting for the next tick */
+				if count < WebhookDispatchBatchSize {
+					break
+				}
+			}
+		}
+	}()
+}
+
+func (svc *WebhookSubscriptionService) attempt(delivery model.WebhookDelivery) error {
+	subscription, err := svc.webhookSubscriptionRepo.Find(delivery.GetSubscriptionID())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	delivery.SetAttempts(delivery.GetAttempts() + 1)
+	delivery.SetLastAttemptTime(helper.ToPtr(helper.TimeToString(now)))
+	delivery.SetResponseStatus(nil)
+	delivery.SetResponseBody(nil)
+	delivery.SetError(nil)
+	succeeded := false
+	if !subscription.GetIsActive() {
+		delivery.SetError(helper.ToPtr("Subscription is inactive."))
+	} else {
+		resp, err := svc.webhookDeliveryClient.Deliver(
+			subscription.GetURL(),
+			subscription.GetSecret(),
+			delivery.GetID(),
+			delivery.GetEventType(),
+			delivery.GetPayload(),
+		)
+		if err != nil {
+			delivery.SetError(helper.ToPtr(err.Error()))
+		} else {
+			delivery.SetResponseStatus(helper.ToPtr(resp.StatusCode))
+			delivery.SetResponseBody(helper.ToPtr(resp.Body))
+			succeeded = resp.StatusCode >= 200 && resp.StatusCode <= 299
+		}
+	}
+	if succeeded {
+		delivery.SetStatus(model.WebhookDeliveryStatusSuccess)
+	} else if delivery.GetAttempts() >= WebhookMaxAttempts || !subscription.GetIsActive() {
+		delivery.SetStatus(model.WebhookDeliveryStatusFailed)
+	} else {
+		delivery.SetNextAttemptTime(helper.TimeToString(now.Add(svc.backoff(delivery.GetAttempts()))))
+	}
+	return svc.webhookSubscriptionRepo.SaveDelivery(delivery)
+}
+
+func (svc *WebhookSubscriptionService) backoff(attempts int) time.Duration {
+	delay := WebhookRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= WebhookRetryMaxDelay {
+			return WebhookRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+func (svc *WebhookSubscriptionService) find(id string, userID string) (model.WebhookSubscription, error) {
+	subscription, err := svc.webhookSubscriptionRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.authorizeWorkspace(subscription.GetWorkspaceID(), userID); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// authorizeWorkspace checks that the user owns the workspace, since the
+// receiver of a subscription gets to know about every file in it.
+func (svc *WebhookSubscriptionService) authorizeWorkspace(workspaceID string, userID string) error {
+	workspace, err := svc.workspaceCache.Get(workspaceID)
+	if err != nil {
+		return err
+	}
+	return svc.workspaceGuard.Authorize(userID, workspace, model.PermissionOwner)
+}
+
+func (svc *WebhookSubscriptionService) uniqueEvents(events []string) []string {
+	res := make([]string, 0, len(events))
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (svc *WebhookSubscriptionService) newSecret() (string, error) {
+	b := make([]byte, WebhookSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (svc *WebhookSubscriptionService) mapSubscription(subscription model.WebhookSubscription) *dto.WebhookSubscription {
+	return &dto.WebhookSubscription{
+		ID:          subscription.GetID(),
+		WorkspaceID: subscription.GetWorkspaceID(),
+		URL:         subscription.GetURL(),
+		Events:      subscription.GetEvents(),
+		IsActive:    subscription.GetIsActive(),
+		CreateTime:  subscription.GetCreateTime(),
+		UpdateTime:  subscription.GetUpdateTime(),
+	}
+}
+
+func (svc *WebhookSubscriptionService) mapDelivery(delivery model.WebhookDelivery) *dto.WebhookDelivery {
+	res := &dto.WebhookDelivery{
+		ID:              delivery.GetID(),
+		SubscriptionID:  delivery.GetSubscriptionID(),
+		EventType:       delivery.GetEventType(),
+		Payload:         delivery.GetPayload(),
+		Status:          delivery.GetStatus(),
+		Attempts:        delivery.GetAttempts(),
+		LastAttemptTime: delivery.GetLastAttemptTime(),
+		ResponseStatus:  delivery.GetResponseStatus(),
+		ResponseBody:    delivery.GetResponseBody(),
+		Error:           delivery.GetError(),
+		CreateTime:      delivery.GetCreateTime(),
+		UpdateTime:      delivery.GetUpdateTime(),
+	}
+	if delivery.GetStatus() == model.WebhookDeliveryStatusPending {
+		res.NextAttemptTime = helper.ToPtr(delivery.GetNextAttemptTime())
+	}
+	return res
+}
+
+type webhookPublisher struct {
+	webhookSubscriptionRepo *repo.WebhookSubscriptionRepo
+}
+
+func newWebhookPublisher() *webhookPublisher {
+	return &webhookPublisher{
+		webhookSubscriptionRepo: repo.NewWebhookSubscriptionRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
+	}
+}
+
+// publish queues a delivery of the event for every subscription of the
+// workspace to its type. Errors are logged rather than returned, since the
+// change the event is about has already happened.
+func (svc *webhookPublisher) publish(workspaceID string, eventType string, data interface{}) {
+	subscriptions, err := svc.webhookSubscriptionRepo.FindActiveForEvent(workspaceID, eventType)
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	payload, err := json.Marshal(dto.WebhookEvent{
+		ID:          helper.NewID(),
+		Type:        eventType,
+		WorkspaceID: workspaceID,
+		CreateTime:  helper.NewTimeString(),
+		Data:        data,
+	})
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	for _, s := range subscriptions {
+		if _, err := svc.webhookSubscriptionRepo.InsertDelivery(repo.WebhookDeliveryInsertOptions{
+			ID:             helper.NewID(),
+			SubscriptionID: s.GetID(),
+			EventType:      eventType,
+			Payload:        string(payload),
+		}); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+}
+
+func (svc *webhookPublisher) publishFile(eventType string, file model.File, userID string) {
+	svc.publish(file.GetWorkspaceID(), eventType, dto.WebhookFileEventData{
+		ID:         file.GetID(),
+		Name:       file.GetName(),
+		Type:       file.GetType(),
+		ParentID:   file.GetParentID(),
+		SnapshotID: file.GetSnapshotID(),
+		UserID:     &userID,
+	})
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+
+	"github.com/kouprlabs/voltaserve/api/service"
+	"github.com/kouprlabs/voltaserve/api/test"
+)
+
+type WebhookSubscriptionServiceTestSuite struct {
+	suite.Suite
+	users []model.User
+}
+
+func TestWebhookSubscriptionServiceSuite(t *testing.T) {
+	suite.Run(t, new(WebhookSubscriptionServiceTestSuite))
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) SetupTest() {
+	var err error
+	s.users, err = test.CreateUsers(2)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestCreate() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated, model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().NotNil(subscription.Secret)
+	s.Equal([]string{model.WebhookEventFileCreated}, subscription.Events)
+	s.True(subscription.IsActive)
+
+	list, err := service.NewWebhookSubscriptionService().List(workspace.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	s.Equal(subscription.ID, list[0].ID)
+	s.Nil(list[0].Secret)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestCreate_MissingPermission() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[1].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewWorkspaceNotFoundError(err).Error(), err.Error())
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestCreate_InsecureURL() {
+	s.T().Setenv("DEVELOPMENT", "false")
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	_, err = service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://example.com/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewWebhookURLNotAllowedError(nil).Error(), err.Error())
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestCreate_PrivateAddress() {
+	s.T().Setenv("DEVELOPMENT", "false")
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	for _, url := range []string{
+		"https://127.0.0.1/webhook",
+		"https://10.0.0.1/webhook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/webhook",
+	} {
+		_, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+			WorkspaceID: workspace.ID,
+			URL:         url,
+			Events:      []string{model.WebhookEventFileCreated},
+		}, s.users[0].GetID())
+		s.Require().Error(err, url)
+		s.Equal(errorpkg.NewWebhookURLNotAllowedError(nil).Error(), err.Error())
+	}
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestPatch_PrivateAddress() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	s.T().Setenv("DEVELOPMENT", "false")
+	_, err = service.NewWebhookSubscriptionService().Patch(subscription.ID, dto.WebhookSubscriptionPatchOptions{
+		URL: helper.ToPtr("https://127.0.0.1/webhook"),
+	}, s.users[0].GetID())
+	s.Require().Error(err)
+	s.Equal(errorpkg.NewWebhookURLNotAllowedError(nil).Error(), err.Error())
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestDispatch() {
+	var received []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         server.URL,
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	file := s.createFolder(workspace)
+
+	_, err = service.NewWebhookSubscriptionService().Dispatch()
+	s.Require().NoError(err)
+	s.Require().Len(received, 1)
+	s.Equal(model.WebhookEventFileCreated, received[0].Header.Get(client.WebhookEventHeader))
+
+	event := dto.WebhookEvent{}
+	s.Require().NoError(json.Unmarshal([]byte(bodies[0]), &event))
+	s.Equal(model.WebhookEventFileCreated, event.Type)
+	s.Equal(workspace.ID, event.WorkspaceID)
+	s.Equal(file.ID, event.Data.(map[string]interface{})["id"])
+
+	signature := received[0].Header.Get(client.WebhookSignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	s.Require().NoError(err)
+	s.Equal(client.SignWebhookPayload(*subscription.Secret, timestamp, bodies[0]), signature)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+	s.Equal(model.WebhookDeliveryStatusSuccess, deliveries.Data[0].Status)
+	s.Equal(1, deliveries.Data[0].Attempts)
+	s.Equal(http.StatusNoContent, *deliveries.Data[0].ResponseStatus)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestDispatch_Retry() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         server.URL,
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.createFolder(workspace)
+
+	_, err = service.NewWebhookSubscriptionService().Dispatch()
+	s.Require().NoError(err)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+	s.Equal(model.WebhookDeliveryStatusPending, deliveries.Data[0].Status)
+	s.Equal(1, deliveries.Data[0].Attempts)
+	s.Require().NotNil(deliveries.Data[0].NextAttemptTime)
+	s.Greater(*deliveries.Data[0].NextAttemptTime, *deliveries.Data[0].LastAttemptTime)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestDispatch_NoRedirect() {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		redirected = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         server.URL,
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.createFolder(workspace)
+
+	_, err = service.NewWebhookSubscriptionService().Dispatch()
+	s.Require().NoError(err)
+	s.False(redirected)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+	s.Equal(model.WebhookDeliveryStatusPending, deliveries.Data[0].Status)
+	s.Equal(http.StatusTemporaryRedirect, *deliveries.Data[0].ResponseStatus)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestDispatch_AddressNotAllowed() {
+	received := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		received = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         server.URL,
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.createFolder(workspace)
+
+	s.T().Setenv("DEVELOPMENT", "false")
+	_, err = service.NewWebhookSubscriptionService().Dispatch()
+	s.Require().NoError(err)
+	s.False(received)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+	s.Equal(model.WebhookDeliveryStatusPending, deliveries.Data[0].Status)
+	s.Nil(deliveries.Data[0].ResponseStatus)
+	s.NotNil(deliveries.Data[0].Error)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestRedeliver() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.createFolder(workspace)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+
+	delivery, err := service.NewWebhookSubscriptionService().Redeliver(subscription.ID, deliveries.Data[0].ID, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.NotEqual(deliveries.Data[0].ID, delivery.ID)
+	s.Equal(deliveries.Data[0].Payload, delivery.Payload)
+	s.Equal(model.WebhookDeliveryStatusPending, delivery.Status)
+	s.Equal(0, delivery.Attempts)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestPublish_InactiveSubscription() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	isActive := false
+	_, err = service.NewWebhookSubscriptionService().Patch(subscription.ID, dto.WebhookSubscriptionPatchOptions{
+		IsActive: &isActive,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.createFolder(workspace)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Empty(deliveries.Data)
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) TestPublish_FileCopied() {
+	workspace, err := test.CreateOrganizationAndWorkspace(s.users[0].GetID())
+	s.Require().NoError(err)
+	source := s.createFolder(workspace)
+	target := s.createFolder(workspace)
+	subscription, err := service.NewWebhookSubscriptionService().Create(dto.WebhookSubscriptionCreateOptions{
+		WorkspaceID: workspace.ID,
+		URL:         "http://localhost/webhook",
+		Events:      []string{model.WebhookEventFileCreated},
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	copied, err := service.NewFileService().Copy(source.ID, target.ID, s.users[0].GetID())
+	s.Require().NoError(err)
+
+	deliveries, err := service.NewWebhookSubscriptionService().ListDeliveries(subscription.ID, 1, 10, s.users[0].GetID())
+	s.Require().NoError(err)
+	s.Require().Len(deliveries.Data, 1)
+	s.Equal(model.WebhookEventFileCreated, deliveries.Data[0].EventType)
+	event := dto.WebhookEvent{}
+	s.Require().NoError(json.Unmarshal([]byte(deliveries.Data[0].Payload), &event))
+	s.Equal(copied.ID, event.Data.(map[string]interface{})["id"])
+	s.Equal(target.ID, event.Data.(map[string]interface{})["parentId"])
+}
+
+func (s *WebhookSubscriptionServiceTestSuite) createFolder(workspace *dto.Workspace) *dto.File {
+	file, err := service.NewFileService().Create(service.FileCreateOptions{
+		WorkspaceID: workspace.ID,
+		Name:        "folder",
+		Type:        model.FileTypeFolder,
+		ParentID:    workspace.RootID,
+	}, s.users[0].GetID())
+	s.Require().NoError(err)
+	return file
+}
@@ -46,6 +46,7 @@ type WorkspaceService struct {
 	fileGuard              *guard.FileGuard
 	fileMapper             *mapper.FileMapper
 	fileDelete             *fileDelete
+	trashItemRepo          *repo.TrashItemRepo
 	storageQuotaRepo       *repo.StorageQuotaRepo
 	permissionRepo         *repo.PermissionRepo
 	s3                     infra.S3Manager
@@ -110,6 +111,10 @@ func NewWorkspaceService() *WorkspaceService {
 			config.GetConfig().Environment,
 		),
 		fileDelete: newFileDelete(),
+		trashItemRepo: repo.NewTrashItemRepo(
+			config.GetConfig().Postgres,
+			config.GetConfig().Environment,
+		),
 		storageQuotaRepo: repo.NewStorageQuotaRepo(
 			config.GetConfig().Postgres,
 			config.GetConfig().Environment,
@@ -275,7 +280,7 @@ func (svc *WorkspaceService) PatchStorageCapacity(id string, storageCapacity int
 	if err := svc.checkStorageQuotaOnPatch(id, storageCapacity); err != nil {
 		return nil, err
 	}
-	size, err := svc.fileRepo.ComputeSize(workspace.GetRootID())
+	size, err := svc.computeUsage(workspace)
 	if err != nil {
 		return nil, err
 	}
@@ -337,11 +342,7 @@ func (svc *WorkspaceService) HasEnoughSpaceForByteSize(id string, byteSize int64
 	if err = svc.workspaceGuard.Authorize(userID, workspace, model.PermissionViewer); err != nil {
 		return false, err
 	}
-	root, err := svc.fileRepo.Find(workspace.GetRootID())
-	if err != nil {
-		return false, err
-	}
-	usage, err := svc.fileRepo.ComputeSize(root.GetID())
+	usage, err := svc.computeUsage(workspace)
 	if err != nil {
 		return false, err
 	}
@@ -523,22 +524,11 @@ func (svc *WorkspaceService) authorize(data []model.Workspace, userID string) ([
 }
 
 func (svc *WorkspaceService) authorizeIDs(ids []string, userID string) ([]model.Workspace, error) {
-	var res []model.Workspace
-	for _, id := range ids {
-		w, err := svc.workspaceCache.Get(id)
-		if err != nil {
-			var e *errorpkg.ErrorResponse
-			if errors.As(err, &e) && e.Code == errorpkg.NewWorkspaceNotFoundError(nil).Code {
-				continue
-			} else {
-				return nil, err
-			}
-		}
-		if svc.workspaceGuard.IsAuthorized(userID, w, model.PermissionViewer) {
-			res = append(res, w)
-		}
+	data, err := svc.workspaceCache.GetMany(ids)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return svc.authorize(data, userID)
 }
 
 func (svc *WorkspaceService) sort(data []model.Workspace, sortBy string, sortOrder string) []model.Workspace {
@@ -630,11 +620,34 @@ func (svc *WorkspaceService) delete(id string) error {
 	return nil
 }
 
+// computeUsage returns the size of the files of the workspace, including the
+// ones in the trash.
+func (svc *WorkspaceService) computeUsage(workspace model.Workspace) (int64, error) {
+	size, err := svc.fileRepo.ComputeSize(workspace.GetRootID())
+	if err != nil {
+		return 0, err
+	}
+	trashSize, err := svc.trashItemRepo.ComputeSize(workspace.GetID())
+	if err != nil {
+		return 0, err
+	}
+	return size + trashSize, nil
+}
+
 func (svc *WorkspaceService) deleteFiles(id string) error {
 	workspace, err := svc.workspaceCache.Get(id)
 	if err != nil {
 		return err
 	}
+	items, err := svc.trashItemRepo.FindByWorkspace(id)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := svc.fileDelete.deleteFolder(item.GetFileID()); err != nil {
+			return err
+		}
+	}
 	if err := svc.workspaceRepo.ClearRootID(id); err != nil {
 		return err
 	} else {
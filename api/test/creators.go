@@ -85,6 +85,16 @@ func CreateWorkspace(orgID string, userID string) (*dto.Workspace, error) {
 	return workspace, nil
 }
 
+// CreateOrganizationAndWorkspace creates a workspace in a new organization,
+// both owned by the user.
+func CreateOrganizationAndWorkspace(userID string) (*dto.Workspace, error) {
+	org, err := CreateOrganization(userID)
+	if err != nil {
+		return nil, err
+	}
+	return CreateWorkspace(org.ID, userID)
+}
+
 func CreateFile(workspaceID string, workspaceRootID string, userID string) (*dto.File, error) {
 	file, err := service.NewFileService().Create(service.FileCreateOptions{
 		WorkspaceID: workspaceID,
@@ -22,11 +22,18 @@ func (e *Env) Apply() error {
 	if err := os.Setenv("TEST", "true"); err != nil {
 		return err
 	}
+	/* Lets webhooks be delivered to the local test servers */
+	if err := os.Setenv("DEVELOPMENT", "true"); err != nil {
+		return err
+	}
 	if err := os.Setenv("LIMITS_FILE_PROCESSING_MB", "video:10000,*:1000"); err != nil {
 		return err
 	}
 	if err := os.Setenv("DEFAULTS_WORKSPACE_STORAGE_CAPACITY_MB", "100000"); err != nil {
 		return err
 	}
+	if err := os.Setenv("EMBEDDING_ENABLED", "true"); err != nil {
+		return err
+	}
 	return nil
 }
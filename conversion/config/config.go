@@ -13,6 +13,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
 )
@@ -27,17 +29,39 @@ type Config struct {
 	Scheduler       SchedulerConfig
 	Security        config.SecurityConfig
 	S3              config.S3Config
+	Redis           config.RedisConfig
 	Environment     config.EnvironmentConfig
 }
 
+const ExternalCommandEverythingElse = "*"
+
 type LimitsConfig struct {
 	ExternalCommandTimeoutSeconds int
-	ImagePreviewMaxWidth          int
-	ImagePreviewMaxHeight         int
+	// The following are keyed by tool name, for example soffice or blender,
+	// the * key applies to the tools that are not listed.
+	ExternalCommandToolTimeoutSeconds map[string]int
+	ExternalCommandMemoryMB           map[string]int
+	ExternalCommandCPUSeconds         map[string]int
+	ExternalCommandConcurrency        map[string]int
+	ImagePreviewMaxWidth              int
+	ImagePreviewMaxHeight             int
+	// MosaicMaxPages caps how many pages of a document get a mosaic, the
+	// following pages are skipped, zero means unlimited.
+	MosaicMaxPages int
 }
 
 type SchedulerConfig struct {
-	PipelineWorkerCount int
+	PipelineWorkerCount           int
+	QueueVisibilityTimeoutSeconds int
+	QueueMaxAttempts              int
+	QueueRetryBackoffSeconds      int
+	// PipelineConcurrency caps how many jobs of a pipeline run at the same
+	// time on this instance, pipelines without a cap can use every worker.
+	PipelineConcurrency map[string]int
+	// PriorityWeights tells how often each priority is served first, for
+	// example interactive:4,bulk:1 serves bulk jobs first once every five
+	// jobs, so that they don't starve.
+	PriorityWeights map[string]int
 }
 
 func GetConfig() *Config {
@@ -49,10 +73,54 @@ func GetConfig() *Config {
 	readScheduler(cfg)
 	config.ReadSecurity(&cfg.Security)
 	config.ReadS3(&cfg.S3)
+	config.ReadRedis(&cfg.Redis)
 	config.ReadEnvironment(&cfg.Environment)
 	return cfg
 }
 
+// GetExternalCommandTimeout returns the wall-clock time limit of the tool, it
+// falls back to the * key, then to the default timeout.
+func (l *LimitsConfig) GetExternalCommandTimeout(tool string) time.Duration {
+	v, ok := l.ExternalCommandToolTimeoutSeconds[tool]
+	if !ok || v == 0 {
+		v = l.ExternalCommandToolTimeoutSeconds[ExternalCommandEverythingElse]
+	}
+	if v == 0 {
+		v = l.ExternalCommandTimeoutSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
+// GetExternalCommandMemoryMB returns the address space limit of the tool,
+// zero means unlimited.
+func (l *LimitsConfig) GetExternalCommandMemoryMB(tool string) int {
+	v, ok := l.ExternalCommandMemoryMB[tool]
+	if !ok {
+		return l.ExternalCommandMemoryMB[ExternalCommandEverythingElse]
+	}
+	return v
+}
+
+// GetExternalCommandCPUSeconds returns the CPU time limit of the tool, zero
+// means unlimited.
+func (l *LimitsConfig) GetExternalCommandCPUSeconds(tool string) int {
+	v, ok := l.ExternalCommandCPUSeconds[tool]
+	if !ok {
+		return l.ExternalCommandCPUSeconds[ExternalCommandEverythingElse]
+	}
+	return v
+}
+
+// GetExternalCommandConcurrency returns how many instances of the tool can run
+// at the same time, zero means unlimited.
+func (l *LimitsConfig) GetExternalCommandConcurrency(tool string) int {
+	v, ok := l.ExternalCommandConcurrency[tool]
+	if !ok {
+		return l.ExternalCommandConcurrency[ExternalCommandEverythingElse]
+	}
+	return v
+}
+
 func readPort(config *Config) {
 	if len(os.Getenv("PORT")) > 0 {
 		port, err := strconv.Atoi(os.Getenv("PORT"))
@@ -86,6 +154,18 @@ func readLimits(config *Config) {
 		}
 		config.Limits.ExternalCommandTimeoutSeconds = int(v)
 	}
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS")) > 0 {
+		config.Limits.ExternalCommandToolTimeoutSeconds = readIntMap("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS")
+	}
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_MEMORY_MB")) > 0 {
+		config.Limits.ExternalCommandMemoryMB = readIntMap("LIMITS_EXTERNAL_COMMAND_MEMORY_MB")
+	}
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_CPU_SECONDS")) > 0 {
+		config.Limits.ExternalCommandCPUSeconds = readIntMap("LIMITS_EXTERNAL_COMMAND_CPU_SECONDS")
+	}
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_CONCURRENCY")) > 0 {
+		config.Limits.ExternalCommandConcurrency = readIntMap("LIMITS_EXTERNAL_COMMAND_CONCURRENCY")
+	}
 	if len(os.Getenv("LIMITS_IMAGE_PREVIEW_MAX_WIDTH")) > 0 {
 		v, err := strconv.ParseInt(os.Getenv("LIMITS_IMAGE_PREVIEW_MAX_WIDTH"), 10, 32)
 		if err != nil {
@@ -100,6 +180,13 @@ func readLimits(config *Config) {
 		}
 		config.Limits.ImagePreviewMaxHeight = int(v)
 	}
+	if len(os.Getenv("LIMITS_MOSAIC_MAX_PAGES")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_MOSAIC_MAX_PAGES"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.MosaicMaxPages = int(v)
+	}
 }
 
 func readScheduler(config *Config) {
@@ -110,4 +197,47 @@ func readScheduler(config *Config) {
 		}
 		config.Scheduler.PipelineWorkerCount = int(v)
 	}
+	if len(os.Getenv("SCHEDULER_QUEUE_VISIBILITY_TIMEOUT_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("SCHEDULER_QUEUE_VISIBILITY_TIMEOUT_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Scheduler.QueueVisibilityTimeoutSeconds = int(v)
+	}
+	if len(os.Getenv("SCHEDULER_QUEUE_MAX_ATTEMPTS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("SCHEDULER_QUEUE_MAX_ATTEMPTS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Scheduler.QueueMaxAttempts = int(v)
+	}
+	if len(os.Getenv("SCHEDULER_QUEUE_RETRY_BACKOFF_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("SCHEDULER_QUEUE_RETRY_BACKOFF_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Scheduler.QueueRetryBackoffSeconds = int(v)
+	}
+	if len(os.Getenv("SCHEDULER_PIPELINE_CONCURRENCY")) > 0 {
+		config.Scheduler.PipelineConcurrency = readIntMap("SCHEDULER_PIPELINE_CONCURRENCY")
+	}
+	if len(os.Getenv("SCHEDULER_PRIORITY_WEIGHTS")) > 0 {
+		config.Scheduler.PriorityWeights = readIntMap("SCHEDULER_PRIORITY_WEIGHTS")
+	}
+}
+
+func readIntMap(name string) map[string]int {
+	res := make(map[string]int)
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		entry := strings.Split(part, ":")
+		if len(entry) != 2 {
+			panic("invalid " + name + " format")
+		}
+		v, err := strconv.ParseInt(entry[1], 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		res[entry[0]] = int(v)
+	}
+	return res
 }
@@ -12,18 +12,34 @@ package infra
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/kouprlabs/voltaserve/shared/helper"
 
 	"github.com/kouprlabs/voltaserve/conversion/config"
+	"github.com/kouprlabs/voltaserve/conversion/logger"
 )
 
-var commandMutex sync.Mutex
+// ErrCommandTimedOut is returned when a command is killed because it ran out
+// of wall-clock or CPU time, which usually means that the input is corrupt.
+var ErrCommandTimedOut = errors.New("command timed out")
+
+// commandWaitDelay bounds how long Wait() waits for the output pipes to be
+// closed after the process is killed, in case a child process inherited them.
+const commandWaitDelay = 5 * time.Second
+
+// commandSlots caps how many instances of each tool run at the same time,
+// waiting for a slot is cancellable, unlike a mutex.
+var (
+	commandSlots   = make(map[string]chan struct{})
+	commandSlotsMu sync.Mutex
+)
 
 type Command struct {
 	config *config.Config
@@ -40,54 +56,114 @@ func (r *Command) RunWithConsoleOutput(name string, arg ...string) error {
 	return cmd.Run()
 }
 
-func (r *Command) Exec(name string, arg ...string) error {
-	commandMutex.Lock()
-	defer commandMutex.Unlock()
+// Exec runs the command and waits for it to exit. The process is killed, with
+// its children, once the context is cancelled or the timeout of the tool elapses.
+func (r *Command) Exec(ctx context.Context, name string, arg ...string) error {
+	release, err := r.acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	timeout := time.Duration(r.config.Limits.ExternalCommandTimeoutSeconds) * time.Second
-	cmd := exec.Command(name, arg...)
+	ctx, cancel := context.WithTimeout(ctx, r.config.Limits.GetExternalCommandTimeout(name))
+	defer cancel()
+	cmd := r.command(ctx, name, arg...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Start(); err != nil {
-		return err
+	if err := cmd.Run(); err != nil {
+		return r.error(ctx, name, err, stderr)
 	}
-	timer := time.AfterFunc(timeout, func() {
-		_ = cmd.Process.Kill()
-	})
-	if err := cmd.Wait(); err != nil {
-		if stderr.Len() > 0 {
-			return errors.New(stderr.String())
-		} else {
-			return err
-		}
-	}
-	timer.Stop()
 	return nil
 }
 
-func (r *Command) ReadOutput(name string, arg ...string) (*string, error) {
-	commandMutex.Lock()
-	defer commandMutex.Unlock()
+// ReadOutput runs the command and returns its standard output, see Exec().
+func (r *Command) ReadOutput(ctx context.Context, name string, arg ...string) (*string, error) {
+	release, err := r.acquire(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	timeout := time.Duration(r.config.Limits.ExternalCommandTimeoutSeconds) * time.Second
-	cmd := exec.Command(name, arg...)
+	ctx, cancel := context.WithTimeout(ctx, r.config.Limits.GetExternalCommandTimeout(name))
+	defer cancel()
+	cmd := r.command(ctx, name, arg...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	res, err := cmd.Output()
 	if err != nil {
-		if stderr.Len() > 0 {
-			return nil, errors.New(stderr.String())
+		return nil, r.error(ctx, name, err, stderr)
+	}
+	return helper.ToPtr(string(res)), nil
+}
+
+func (r *Command) acquire(ctx context.Context, name string) (func(), error) {
+	slots := r.slots(name)
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// slots returns the semaphore of the tool, sized from the configuration the
+// first time the tool runs, or nil if the tool has no concurrency limit.
+func (r *Command) slots(name string) chan struct{} {
+	commandSlotsMu.Lock()
+	defer commandSlotsMu.Unlock()
+	res, ok := commandSlots[name]
+	if !ok {
+		if n := r.config.Limits.GetExternalCommandConcurrency(name); n > 0 {
+			res = make(chan struct{}, n)
+		}
+		commandSlots[name] = res
+	}
+	return res
+}
+
+// command builds the command, wrapped with prlimit when the tool has resource
+// limits and prlimit is available.
+func (r *Command) command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	var limits []string
+	if mb := r.config.Limits.GetExternalCommandMemoryMB(name); mb > 0 {
+		limits = append(limits, "--as="+strconv.FormatInt(int64(mb)*1024*1024, 10))
+	}
+	if seconds := r.config.Limits.GetExternalCommandCPUSeconds(name); seconds > 0 {
+		limits = append(limits, "--cpu="+strconv.Itoa(seconds))
+	}
+	if len(limits) > 0 {
+		if prlimit, err := exec.LookPath("prlimit"); err == nil {
+			arg = append(append(limits, "--", name), arg...)
+			name = prlimit
 		} else {
-			return nil, err
+			logger.GetLogger().Warnw("prlimit not found, running without resource limits", "command", name)
 		}
 	}
-	timer := time.AfterFunc(timeout, func() {
-		_ = cmd.Process.Kill()
-	})
-	timer.Stop()
-	return helper.ToPtr(string(res)), nil
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.WaitDelay = commandWaitDelay
+	killProcessGroup(cmd)
+	return cmd
+}
+
+// error tells a cancelled or timed out command apart from a failed one,
+// rather than reporting the signal that killed the process.
+func (r *Command) error(ctx context.Context, name string, err error, stderr bytes.Buffer) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || isCPULimitExceeded(err) {
+		logger.GetLogger().Warnw("command timed out", "command", name)
+		return ErrCommandTimedOut
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	if stderr.Len() > 0 {
+		return errors.New(stderr.String())
+	}
+	return err
 }
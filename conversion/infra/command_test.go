@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package infra_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/conversion/infra"
+)
+
+type CommandSuite struct {
+	suite.Suite
+}
+
+func TestCommandSuite(t *testing.T) {
+	suite.Run(t, new(CommandSuite))
+}
+
+func (s *CommandSuite) SetupTest() {
+	s.T().Setenv("LIMITS_EXTERNAL_COMMAND_TIMEOUT_SECONDS", "60")
+}
+
+func (s *CommandSuite) TestExec() {
+	s.NoError(infra.NewCommand().Exec(context.Background(), "true"))
+	s.Error(infra.NewCommand().Exec(context.Background(), "false"))
+}
+
+func (s *CommandSuite) TestExec_ToolTimeout() {
+	s.T().Setenv("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS", "sleep:1")
+
+	start := time.Now()
+	err := infra.NewCommand().Exec(context.Background(), "sleep", "60")
+	s.ErrorIs(err, infra.ErrCommandTimedOut)
+	s.Less(time.Since(start), 10*time.Second)
+}
+
+func (s *CommandSuite) TestExec_Cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := infra.NewCommand().Exec(ctx, "sleep", "60")
+	s.ErrorIs(err, context.Canceled)
+	s.Less(time.Since(start), 10*time.Second)
+}
+
+func (s *CommandSuite) TestReadOutput_ToolTimeout() {
+	s.T().Setenv("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS", "sh:1")
+
+	_, err := infra.NewCommand().ReadOutput(context.Background(), "sh", "-c", "echo started; sleep 60")
+	s.ErrorIs(err, infra.ErrCommandTimedOut)
+}
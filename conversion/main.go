@@ -14,10 +14,12 @@ import (
 	"fmt"
 	"os"
 	goruntime "runtime"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 
+	"github.com/kouprlabs/voltaserve/shared/dto"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 
 	"github.com/kouprlabs/voltaserve/conversion/config"
@@ -45,14 +47,41 @@ func main() {
 
 	cfg := config.GetConfig()
 
+	if cfg.Limits.ExternalCommandTimeoutSeconds == 0 {
+		cfg.Limits.ExternalCommandTimeoutSeconds = 900
+	}
+
 	if cfg.Scheduler.PipelineWorkerCount == 0 {
 		cfg.Scheduler.PipelineWorkerCount = goruntime.NumCPU()
 	}
 
+	if cfg.Scheduler.QueueVisibilityTimeoutSeconds == 0 {
+		cfg.Scheduler.QueueVisibilityTimeoutSeconds = 60
+	}
+	if cfg.Scheduler.QueueMaxAttempts == 0 {
+		cfg.Scheduler.QueueMaxAttempts = 3
+	}
+	if cfg.Scheduler.QueueRetryBackoffSeconds == 0 {
+		cfg.Scheduler.QueueRetryBackoffSeconds = 30
+	}
+	if cfg.Scheduler.PriorityWeights == nil {
+		cfg.Scheduler.PriorityWeights = map[string]int{
+			dto.PipelinePriorityInteractive: 4,
+			dto.PipelinePriorityBulk:        1,
+		}
+	}
+
 	installer := runtime.NewInstaller()
 	scheduler := runtime.NewScheduler(runtime.SchedulerOptions{
 		PipelineWorkerCount: cfg.Scheduler.PipelineWorkerCount,
-		Installer:           installer,
+		PipelineConcurrency: cfg.Scheduler.PipelineConcurrency,
+		PriorityWeights:     cfg.Scheduler.PriorityWeights,
+		Queue: runtime.NewPipelineQueue(cfg.Redis, runtime.PipelineQueueOptions{
+			VisibilityTimeout: time.Duration(cfg.Scheduler.QueueVisibilityTimeoutSeconds) * time.Second,
+			MaxAttempts:       cfg.Scheduler.QueueMaxAttempts,
+			RetryBackoff:      time.Duration(cfg.Scheduler.QueueRetryBackoffSeconds) * time.Second,
+		}),
+		Installer: installer,
 	})
 
 	app := fiber.New(fiber.Config{
@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 type audioVideoPipeline struct {
 	videoProc      *processor.VideoProcessor
 	imageProc      *processor.ImageProcessor
+	metadataProc   *processor.MetadataProcessor
 	s3             infra.S3Manager
 	taskClient     *client.TaskClient
 	snapshotClient *client.SnapshotClient
@@ -41,6 +43,7 @@ func NewAudioVideoPipeline() Pipeline {
 	return &audioVideoPipeline{
 		videoProc:      processor.NewVideoProcessor(),
 		imageProc:      processor.NewImageProcessor(),
+		metadataProc:   processor.NewMetadataProcessor(),
 		s3:             infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
 		taskClient:     client.NewTaskClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		snapshotClient: client.NewSnapshotClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
@@ -48,7 +51,7 @@ func NewAudioVideoPipeline() Pipeline {
 	}
 }
 
-func (p *audioVideoPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *audioVideoPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -60,10 +63,10 @@ func (p *audioVideoPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *audioVideoPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *audioVideoPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -74,17 +77,64 @@ func (p *audioVideoPipeline) RunFromLocalPath(inputPath string, opts dto.Pipelin
 	}
 	// Here we intentionally ignore the error, as the media file may contain just audio
 	// Additionally, we don't consider failing to create the thumbnail an error
-	_ = p.patchThumbnail(inputPath, opts)
+	_ = p.patchThumbnail(ctx, inputPath, opts)
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
-			Name:   helper.ToPtr("Saving preview."),
+			Name:   helper.ToPtr("Extracting metadata."),
 		}); err != nil {
 			return err
 		}
 	}
-	if err := p.patchPreviewWithOriginal(inputPath, opts); err != nil {
-		return err
+	// Metadata is informative, so we don't consider failing to extract it an error
+	_ = p.patchMetadata(ctx, inputPath, opts)
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName},
+			Name:   helper.ToPtr("Transcoding."),
+		}); err != nil {
+			return err
+		}
+	}
+	// Media which ffmpeg cannot read is still previewed with the original,
+	// as it may be playable by the browser
+	probe, err := p.videoProc.Probe(ctx, inputPath)
+	if err == nil {
+		err = p.patchPreview(ctx, inputPath, probe, opts)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.GetLogger().Named(logger.StrPipeline).Warnw("🎬  transcoding failed, previewing the original", "key", opts.Key, "error", err)
+		if opts.TaskID != nil {
+			if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+				Fields: []string{model.TaskFieldName},
+				Name:   helper.ToPtr("Saving preview."),
+			}); err != nil {
+				return err
+			}
+		}
+		if err := p.patchPreviewWithOriginal(inputPath, opts); err != nil {
+			return err
+		}
+	} else {
+		if opts.TaskID != nil {
+			if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+				Fields: []string{model.TaskFieldName},
+				Name:   helper.ToPtr("Creating streaming renditions."),
+			}); err != nil {
+				return err
+			}
+		}
+		// The MP4 preview is already playable, so we don't consider failing to
+		// create the HLS renditions an error
+		if err := p.patchHLS(ctx, inputPath, probe, opts); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.GetLogger().Named(logger.StrPipeline).Warnw("🎬  creating HLS renditions failed", "key", opts.Key, "error", err)
+		}
 	}
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
@@ -98,7 +148,7 @@ func (p *audioVideoPipeline) RunFromLocalPath(inputPath string, opts dto.Pipelin
 	return nil
 }
 
-func (p *audioVideoPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *audioVideoPipeline) patchThumbnail(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".png")
 	defer func(path string) {
 		_, err := os.Stat(path)
@@ -108,10 +158,10 @@ func (p *audioVideoPipeline) patchThumbnail(inputPath string, opts dto.PipelineR
 			}
 		}
 	}(outputPath)
-	if err := p.videoProc.Thumbnail(inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
+	if err := p.videoProc.Thumbnail(ctx, inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
 		return err
 	}
-	props, err := p.imageProc.MeasureImage(outputPath)
+	props, err := p.imageProc.MeasureImage(ctx, outputPath)
 	if err != nil {
 		return err
 	}
@@ -137,6 +187,102 @@ func (p *audioVideoPipeline) patchThumbnail(inputPath string, opts dto.PipelineR
 	return nil
 }
 
+func (p *audioVideoPipeline) patchPreview(ctx context.Context, inputPath string, probe *processor.MediaProbe, opts dto.PipelineRunOptions) error {
+	extension := ".mp4"
+	if !probe.HasVideo {
+		extension = ".m4a"
+	}
+	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + extension)
+	defer func(path string) {
+		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(outputPath)
+	if err := p.videoProc.TranscodeMP4(ctx, inputPath, probe, outputPath); err != nil {
+		return err
+	}
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+	s3Object := &model.S3Object{
+		Bucket: opts.Bucket,
+		Key:    opts.SnapshotID + "/preview" + extension,
+		Size:   stat.Size(),
+		Media: &model.MediaProps{
+			Duration: probe.Duration,
+			Width:    probe.Width,
+			Height:   probe.Height,
+		},
+	}
+	if err := p.s3.PutFile(s3Object.Key, outputPath, helper.DetectMIMEFromPath(outputPath), s3Object.Bucket, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields:  []string{model.SnapshotFieldPreview},
+		Preview: s3Object,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *audioVideoPipeline) patchHLS(ctx context.Context, inputPath string, probe *processor.MediaProbe, opts dto.PipelineRunOptions) error {
+	outputDir := filepath.FromSlash(os.TempDir() + "/" + helper.NewID())
+	defer func(path string) {
+		if err := os.RemoveAll(path); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(outputDir)
+	renditions, err := p.videoProc.TranscodeHLS(ctx, inputPath, probe, outputDir)
+	if err != nil {
+		return err
+	}
+	s3Object := &model.S3Object{
+		Bucket: opts.Bucket,
+		Key:    opts.SnapshotID + "/hls",
+		Media: &model.MediaProps{
+			Duration:   probe.Duration,
+			Width:      probe.Width,
+			Height:     probe.Height,
+			Renditions: renditions,
+		},
+	}
+	if err := filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		s3Object.Size += info.Size()
+		return p.s3.PutFile(s3Object.Key+"/"+filepath.ToSlash(rel), path, hlsContentType(path), s3Object.Bucket, minio.PutObjectOptions{})
+	}); err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields: []string{model.SnapshotFieldHLS},
+		HLS:    s3Object,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func hlsContentType(path string) string {
+	if filepath.Ext(path) == ".m3u8" {
+		return "application/vnd.apple.mpegurl"
+	}
+	return "video/mp2t"
+}
+
 func (p *audioVideoPipeline) patchPreviewWithOriginal(inputPath string, opts dto.PipelineRunOptions) error {
 	stat, err := os.Stat(inputPath)
 	if err != nil {
@@ -154,3 +300,17 @@ func (p *audioVideoPipeline) patchPreviewWithOriginal(inputPath string, opts dto
 	}
 	return nil
 }
+
+func (p *audioVideoPipeline) patchMetadata(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	metadata, err := p.metadataProc.Extract(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields:   []string{model.SnapshotFieldMetadata},
+		Metadata: metadata,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
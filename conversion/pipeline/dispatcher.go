@@ -11,13 +11,19 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
+
 	"github.com/kouprlabs/voltaserve/shared/client"
 	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 	"github.com/kouprlabs/voltaserve/shared/helper"
 	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 
 	"github.com/kouprlabs/voltaserve/conversion/config"
+	conversioninfra "github.com/kouprlabs/voltaserve/conversion/infra"
+	"github.com/kouprlabs/voltaserve/conversion/processor"
 )
 
 type Dispatcher struct {
@@ -30,6 +36,7 @@ type Dispatcher struct {
 	glbPipeline        Pipeline
 	zipPipeline        Pipeline
 	ocrPipeline        Pipeline
+	imageDiffPipeline  Pipeline
 	taskClient         *client.TaskClient
 	snapshotClient     *client.SnapshotClient
 	fileIdent          *infra.FileIdentifier
@@ -46,13 +53,19 @@ func NewDispatcher() *Dispatcher {
 		glbPipeline:        NewGLBPipeline(),
 		zipPipeline:        NewZIPPipeline(),
 		ocrPipeline:        NewOCRPipeline(),
+		imageDiffPipeline:  NewImageDiffPipeline(),
 		taskClient:         client.NewTaskClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		snapshotClient:     client.NewSnapshotClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		fileIdent:          infra.NewFileIdentifier(),
 	}
 }
 
-func (d *Dispatcher) Dispatch(opts dto.PipelineRunOptions) error {
+// Dispatch runs the pipeline matching the file, it stops and returns the
+// context error as soon as the context is cancelled.
+func (d *Dispatcher) Dispatch(ctx context.Context, opts dto.PipelineRunOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if opts.TaskID != nil {
 		if _, err := d.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Name:   helper.ToPtr("Processing."),
@@ -62,38 +75,33 @@ func (d *Dispatcher) Dispatch(opts dto.PipelineRunOptions) error {
 			return err
 		}
 	}
-	id := d.identify(opts)
+	id := d.Identify(opts)
 	var err error
 	if id == dto.PipelinePDF {
-		err = d.pdfPipeline.Run(opts)
+		err = d.pdfPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineOffice {
-		err = d.officePipeline.Run(opts)
+		err = d.officePipeline.Run(ctx, opts)
 	} else if id == dto.PipelineImage {
-		err = d.imagePipeline.Run(opts)
+		err = d.imagePipeline.Run(ctx, opts)
 	} else if id == dto.PipelineAudioVideo {
-		err = d.audioVideoPipeline.Run(opts)
+		err = d.audioVideoPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineEntity {
-		err = d.entityPipeline.Run(opts)
+		err = d.entityPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineMosaic {
-		err = d.mosaicPipeline.Run(opts)
+		err = d.mosaicPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineGLB {
-		err = d.glbPipeline.Run(opts)
+		err = d.glbPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineZIP {
-		err = d.zipPipeline.Run(opts)
+		err = d.zipPipeline.Run(ctx, opts)
 	} else if id == dto.PipelineOCR {
-		err = d.ocrPipeline.Run(opts)
+		err = d.ocrPipeline.Run(ctx, opts)
+	} else if id == dto.PipelineImageDiff {
+		err = d.imageDiffPipeline.Run(ctx, opts)
 	}
 	if err != nil {
-		if opts.TaskID != nil {
-			if _, err := d.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
-				Fields: []string{model.TaskFieldStatus, model.TaskFieldError},
-				Status: helper.ToPtr(model.TaskStatusError),
-				Error:  helper.ToPtr(d.getUserFriendlyMessage(err.Error())),
-			}); err != nil {
-				return err
-			}
-		}
 		return err
+	} else if ctx.Err() != nil {
+		return ctx.Err()
 	} else {
 		if _, err := d.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldStatus},
@@ -105,7 +113,40 @@ func (d *Dispatcher) Dispatch(opts dto.PipelineRunOptions) error {
 	}
 }
 
-func (d *Dispatcher) identify(opts dto.PipelineRunOptions) string {
+// Defer puts the task back in waiting status, because the pipeline failed and
+// will be retried later.
+func (d *Dispatcher) Defer(opts dto.PipelineRunOptions) error {
+	if opts.TaskID == nil {
+		return nil
+	}
+	if _, err := d.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+		Name:   helper.ToPtr("Waiting."),
+		Fields: []string{model.TaskFieldName, model.TaskFieldStatus},
+		Status: helper.ToPtr(model.TaskStatusWaiting),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Fail reports the error of the pipeline on the task, once it won't be retried anymore.
+func (d *Dispatcher) Fail(opts dto.PipelineRunOptions, err error) error {
+	if opts.TaskID == nil {
+		return nil
+	}
+	if _, err := d.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+		Fields: []string{model.TaskFieldStatus, model.TaskFieldError},
+		Status: helper.ToPtr(model.TaskStatusError),
+		Error:  helper.ToPtr(d.getUserFriendlyMessage(err)),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Identify returns the ID of the pipeline that processes the file, or an empty
+// string if no pipeline matches.
+func (d *Dispatcher) Identify(opts dto.PipelineRunOptions) string {
 	if opts.PipelineID != nil {
 		return *opts.PipelineID
 	} else {
@@ -126,21 +167,20 @@ func (d *Dispatcher) identify(opts dto.PipelineRunOptions) string {
 	return ""
 }
 
-func (d *Dispatcher) getUserFriendlyMessage(code string) string {
-	messages := map[string]string{
-		"mosaic not found":                                 "Mosaic not found.",
-		"no matching pipeline found":                       "This file type cannot be processed.",
-		"language is undefined":                            "Language is undefined.",
-		"unsupported file type":                            "Unsupported file type.",
-		"text is empty":                                    "Text is empty.",
-		"text exceeds supported limit of 1000K characters": "Text exceeds supported limit of 1000K characters.",
-		"missing query param api_key":                      "Missing query param api_key.",
-		"invalid query param api_key":                      "Invalid query param api_key.",
-		"invalid content type":                             "Invalid content type.",
-	}
-	res, ok := messages[code]
-	if !ok {
-		return "An error occurred while processing the file."
+// getUserFriendlyMessage classifies the error by type, errors returned by
+// other services carry their own user message.
+func (d *Dispatcher) getUserFriendlyMessage(err error) string {
+	var errorResponse *errorpkg.ErrorResponse
+	if errors.Is(err, conversioninfra.ErrCommandTimedOut) {
+		return "Processing took too long and was stopped, the file might be corrupt."
+	} else if errors.Is(err, processor.ErrUnsupportedFileType) {
+		return "Unsupported file type."
+	} else if errors.Is(err, errLanguageUndefined) {
+		return "Language is undefined."
+	} else if errors.Is(err, errTextEmpty) {
+		return "Text is empty."
+	} else if errors.As(err, &errorResponse) && errorResponse.UserMessage != "" {
+		return errorResponse.UserMessage
 	}
-	return res
+	return "An error occurred while processing the file."
 }
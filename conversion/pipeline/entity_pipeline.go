@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 
@@ -26,6 +27,11 @@ import (
 	"github.com/kouprlabs/voltaserve/conversion/processor"
 )
 
+var (
+	errLanguageUndefined = errors.New("language is undefined")
+	errTextEmpty         = errors.New("text is empty")
+)
+
 type entityPipeline struct {
 	imageProc      *processor.ImageProcessor
 	pdfProc        *processor.PDFProcessor
@@ -50,14 +56,14 @@ func NewEntityPipeline() Pipeline {
 	}
 }
 
-func (p *entityPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *entityPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	if opts.Language == nil {
-		return errors.New("language is undefined")
+		return errLanguageUndefined
 	}
-	return p.RunFromLocalPath("", opts)
+	return p.RunFromLocalPath(ctx, "", opts)
 }
 
-func (p *entityPipeline) RunFromLocalPath(_ string, opts dto.PipelineRunOptions) error {
+func (p *entityPipeline) RunFromLocalPath(_ context.Context, _ string, opts dto.PipelineRunOptions) error {
 	snapshot, err := p.snapshotClient.Find(opts.SnapshotID)
 	if err != nil {
 		return err
@@ -94,7 +100,7 @@ func (p *entityPipeline) RunFromLocalPath(_ string, opts dto.PipelineRunOptions)
 
 func (p *entityPipeline) patchEntities(text string, opts dto.PipelineRunOptions) error {
 	if len(text) == 0 {
-		return errors.New("text is empty")
+		return errTextEmpty
 	}
 	res, err := p.languageClient.GetEntities(client.GetEntitiesOptions{
 		Text:     text,
@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -48,7 +49,7 @@ func NewGLBPipeline() Pipeline {
 	}
 }
 
-func (p *glbPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *glbPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -60,10 +61,10 @@ func (p *glbPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *glbPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *glbPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -72,7 +73,7 @@ func (p *glbPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 			return err
 		}
 	}
-	_ = p.patchThumbnail(inputPath, opts)
+	_ = p.patchThumbnail(ctx, inputPath, opts)
 	if err := p.patchPreview(inputPath, opts); err != nil {
 		return err
 	}
@@ -112,7 +113,7 @@ func (p *glbPipeline) patchPreview(inputPath string, opts dto.PipelineRunOptions
 	return nil
 }
 
-func (p *glbPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *glbPipeline) patchThumbnail(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".png")
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
@@ -121,12 +122,12 @@ func (p *glbPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptio
 			logger.GetLogger().Error(err)
 		}
 	}(outputPath)
-	if err := p.glbProc.Thumbnail(inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
+	if err := p.glbProc.Thumbnail(ctx, inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
 		return err
 	}
 	stat, err := os.Stat(outputPath)
 	if err == nil {
-		props, err := p.imageProc.MeasureImage(outputPath)
+		props, err := p.imageProc.MeasureImage(ctx, outputPath)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,158 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+
+	"github.com/kouprlabs/voltaserve/conversion/config"
+	"github.com/kouprlabs/voltaserve/conversion/logger"
+	"github.com/kouprlabs/voltaserve/conversion/processor"
+)
+
+type imageDiffPipeline struct {
+	imageProc          *processor.ImageProcessor
+	fileIdent          *infra.FileIdentifier
+	s3                 infra.S3Manager
+	taskClient         *client.TaskClient
+	snapshotDiffClient *client.SnapshotDiffClient
+}
+
+func NewImageDiffPipeline() Pipeline {
+	return &imageDiffPipeline{
+		imageProc:          processor.NewImageProcessor(),
+		fileIdent:          infra.NewFileIdentifier(),
+		s3:                 infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		taskClient:         client.NewTaskClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
+		snapshotDiffClient: client.NewSnapshotDiffClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
+	}
+}
+
+func (p *imageDiffPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
+	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
+	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
+		return err
+	}
+	defer p.removeFile(inputPath)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
+}
+
+func (p *imageDiffPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	diffID := opts.Payload[dto.PipelinePayloadDiffID]
+	otherBucket := opts.Payload[dto.PipelinePayloadOtherBucket]
+	otherKey := opts.Payload[dto.PipelinePayloadOtherKey]
+	outputKey := opts.Payload[dto.PipelinePayloadOutputKey]
+	if diffID == "" || otherBucket == "" || otherKey == "" || outputKey == "" {
+		return errors.New("missing payload")
+	}
+	if !p.fileIdent.IsImage(opts.Key) || !p.fileIdent.IsImage(otherKey) {
+		return processor.ErrUnsupportedFileType
+	}
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName},
+			Name:   helper.ToPtr("Comparing images."),
+		}); err != nil {
+			return err
+		}
+	}
+	otherPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(otherKey))
+	if err := p.s3.GetFile(otherKey, otherPath, otherBucket, minio.GetObjectOptions{}); err != nil {
+		return err
+	}
+	defer p.removeFile(otherPath)
+	basePath, err := p.toSupportedImage(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if basePath != inputPath {
+		defer p.removeFile(basePath)
+	}
+	targetPath, err := p.toSupportedImage(ctx, otherPath)
+	if err != nil {
+		return err
+	}
+	if targetPath != otherPath {
+		defer p.removeFile(targetPath)
+	}
+	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".png")
+	defer p.removeFile(outputPath)
+	res, err := p.imageProc.DiffImages(ctx, basePath, targetPath, outputPath)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+	s3Object := &model.S3Object{
+		Bucket: opts.Bucket,
+		Key:    outputKey,
+		Size:   stat.Size(),
+		Image: &model.ImageProps{
+			Width:  res.Width,
+			Height: res.Height,
+		},
+	}
+	if err := p.s3.PutFile(s3Object.Key, outputPath, helper.DetectMIMEFromPath(outputPath), s3Object.Bucket, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+	if err := p.snapshotDiffClient.Patch(diffID, dto.SnapshotDiffPatchOptions{
+		Artifact: s3Object,
+		Score:    helper.ToPtr(res.Score),
+	}); err != nil {
+		return err
+	}
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName, model.TaskFieldStatus},
+			Name:   helper.ToPtr("Done."),
+			Status: helper.ToPtr(model.TaskStatusSuccess),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toSupportedImage converts the image to PNG when bild cannot decode it, it
+// returns the path of the converted image, or the given path if no conversion
+// was needed.
+func (p *imageDiffPipeline) toSupportedImage(ctx context.Context, path string) (string, error) {
+	if p.imageProc.IsSupportedByBild(path) {
+		return path, nil
+	}
+	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".png")
+	if err := p.imageProc.ConvertImage(ctx, path, outputPath); err != nil {
+		p.removeFile(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+func (p *imageDiffPipeline) removeFile(path string) {
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return
+	} else if err != nil {
+		logger.GetLogger().Error(err)
+	}
+}
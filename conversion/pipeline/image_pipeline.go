@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 type imagePipeline struct {
 	ocrPipeline    Pipeline
 	imageProc      *processor.ImageProcessor
+	metadataProc   *processor.MetadataProcessor
 	ocrProc        *processor.OCRProcessor
 	pdfProc        *processor.PDFProcessor
 	s3             infra.S3Manager
@@ -44,6 +46,7 @@ func NewImagePipeline() Pipeline {
 	return &imagePipeline{
 		ocrPipeline:    NewOCRPipeline(),
 		imageProc:      processor.NewImageProcessor(),
+		metadataProc:   processor.NewMetadataProcessor(),
 		ocrProc:        processor.NewOCRProcessor(),
 		pdfProc:        processor.NewPDFProcessor(),
 		s3:             infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
@@ -54,7 +57,7 @@ func NewImagePipeline() Pipeline {
 	}
 }
 
-func (p *imagePipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *imagePipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -66,10 +69,10 @@ func (p *imagePipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *imagePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *imagePipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -78,7 +81,7 @@ func (p *imagePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunO
 			return err
 		}
 	}
-	imageProps, err := p.patchOriginalWithImageDimensions(inputPath, opts)
+	imageProps, err := p.patchOriginalWithImageDimensions(ctx, inputPath, opts)
 	if err != nil {
 		return err
 	}
@@ -92,7 +95,7 @@ func (p *imagePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunO
 				return err
 			}
 		}
-		jpegPath, err := p.patchPreviewWithJPEG(inputPath, *imageProps, opts)
+		jpegPath, err := p.patchPreviewWithJPEG(ctx, inputPath, *imageProps, opts)
 		if err != nil {
 			return err
 		}
@@ -118,9 +121,19 @@ func (p *imagePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunO
 			return err
 		}
 	}
-	_ = p.patchThumbnail(imagePath, opts)
+	_ = p.patchThumbnail(ctx, imagePath, opts)
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName},
+			Name:   helper.ToPtr("Extracting metadata."),
+		}); err != nil {
+			return err
+		}
+	}
+	// Metadata is informative, so we don't consider failing to extract it an error
+	_ = p.patchMetadata(ctx, inputPath, opts)
 	if opts.Intent != nil && *opts.Intent == model.SnapshotIntentDocument && opts.Language != nil && *opts.Language != "" {
-		_ = p.ocrPipeline.RunFromLocalPath(imagePath, opts)
+		_ = p.ocrPipeline.RunFromLocalPath(ctx, imagePath, opts)
 	}
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
@@ -134,8 +147,8 @@ func (p *imagePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunO
 	return nil
 }
 
-func (p *imagePipeline) patchOriginalWithImageDimensions(inputPath string, opts dto.PipelineRunOptions) (*model.ImageProps, error) {
-	imageProps, err := p.imageProc.MeasureImage(inputPath)
+func (p *imagePipeline) patchOriginalWithImageDimensions(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) (*model.ImageProps, error) {
+	imageProps, err := p.imageProc.MeasureImage(ctx, inputPath)
 	if err != nil {
 		return nil, err
 	}
@@ -157,21 +170,20 @@ func (p *imagePipeline) patchOriginalWithImageDimensions(inputPath string, opts
 	return imageProps, nil
 }
 
-func (p *imagePipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *imagePipeline) patchThumbnail(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(inputPath))
-	res, err := p.imageProc.Thumbnail(inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath)
+	defer func(path string) {
+		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(outputPath)
+	res, err := p.imageProc.Thumbnail(ctx, inputPath, p.config.Limits.ImagePreviewMaxWidth, p.config.Limits.ImagePreviewMaxHeight, outputPath)
 	if err != nil {
 		return err
 	}
-	if res.IsCreated {
-		defer func(path string) {
-			if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
-				return
-			} else if err != nil {
-				logger.GetLogger().Error(err)
-			}
-		}(outputPath)
-	} else {
+	if !res.IsCreated {
 		outputPath = inputPath
 	}
 	stat, err := os.Stat(outputPath)
@@ -199,9 +211,10 @@ func (p *imagePipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOpt
 	return nil
 }
 
-func (p *imagePipeline) patchPreviewWithJPEG(inputPath string, imageProps model.ImageProps, opts dto.PipelineRunOptions) (*string, error) {
+func (p *imagePipeline) patchPreviewWithJPEG(ctx context.Context, inputPath string, imageProps model.ImageProps, opts dto.PipelineRunOptions) (*string, error) {
 	jpegPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".jpg")
-	if err := p.imageProc.ConvertImage(inputPath, jpegPath); err != nil {
+	if err := p.imageProc.ConvertImage(ctx, inputPath, jpegPath); err != nil {
+		_ = os.Remove(jpegPath)
 		return nil, err
 	}
 	stat, err := os.Stat(jpegPath)
@@ -244,3 +257,17 @@ func (p *imagePipeline) patchPreviewWithOriginal(inputPath string, imageProps mo
 	}
 	return nil
 }
+
+func (p *imagePipeline) patchMetadata(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	metadata, err := p.metadataProc.Extract(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields:   []string{model.SnapshotFieldMetadata},
+		Metadata: metadata,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -11,7 +11,10 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 
@@ -28,29 +31,44 @@ import (
 	"github.com/kouprlabs/voltaserve/conversion/processor"
 )
 
+const (
+	// MosaicPageDPI is the resolution the pages of documents are rendered at, which
+	// keeps the details of large-format drawings readable when zooming in.
+	MosaicPageDPI = 300
+	// MosaicPageMaxPixels caps the size of a rendered page, the resolution is
+	// lowered for the pages that would exceed it, an A0 page at 300 DPI fits.
+	MosaicPageMaxPixels = 150_000_000
+	// MosaicPageMaxSide caps the width and the height of a rendered page.
+	MosaicPageMaxSide = 30_000
+)
+
 type mosaicPipeline struct {
 	videoProc      *processor.VideoProcessor
 	imageProc      *processor.ImageProcessor
+	pdfProc        *processor.PDFProcessor
 	fileIdent      *infra.FileIdentifier
 	s3             infra.S3Manager
 	taskClient     *client.TaskClient
 	snapshotClient *client.SnapshotClient
 	mosaicClient   *client.MosaicClient
+	config         *config.Config
 }
 
 func NewMosaicPipeline() Pipeline {
 	return &mosaicPipeline{
 		videoProc:      processor.NewVideoProcessor(),
 		imageProc:      processor.NewImageProcessor(),
+		pdfProc:        processor.NewPDFProcessor(),
 		fileIdent:      infra.NewFileIdentifier(),
 		s3:             infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
 		taskClient:     client.NewTaskClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		snapshotClient: client.NewSnapshotClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		mosaicClient:   client.NewMosaicClient(config.GetConfig().MosaicURL),
+		config:         config.GetConfig(),
 	}
 }
 
-func (p *mosaicPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *mosaicPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -62,12 +80,13 @@ func (p *mosaicPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *mosaicPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
-	if !p.fileIdent.IsImage(opts.Key) {
-		return errors.New("unsupported file type")
+func (p *mosaicPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	isPDF := p.fileIdent.IsPDF(opts.Key)
+	if !p.fileIdent.IsImage(opts.Key) && !isPDF {
+		return processor.ErrUnsupportedFileType
 	}
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
@@ -77,11 +96,37 @@ func (p *mosaicPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRun
 			return err
 		}
 	}
-	if !p.imageProc.IsSupportedByBild(inputPath) {
-		outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".jpg")
-		if err := p.imageProc.ConvertImage(inputPath, outputPath); err != nil {
+	if isPDF {
+		if err := p.createPages(ctx, inputPath, opts); err != nil {
 			return err
 		}
+	} else if err := p.create(ctx, inputPath, opts); err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields: []string{model.SnapshotFieldMosaic},
+		Mosaic: &model.S3Object{
+			Key:    filepath.FromSlash(opts.SnapshotID + "/mosaic"),
+			Bucket: opts.Bucket,
+		},
+	}); err != nil {
+		return err
+	}
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName, model.TaskFieldStatus},
+			Name:   helper.ToPtr("Done."),
+			Status: helper.ToPtr(model.TaskStatusSuccess),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *mosaicPipeline) create(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	if !p.imageProc.IsSupportedByBild(inputPath) {
+		outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".jpg")
 		defer func(path string) {
 			if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 				return
@@ -89,6 +134,9 @@ func (p *mosaicPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRun
 				logger.GetLogger().Error(err)
 			}
 		}(outputPath)
+		if err := p.imageProc.ConvertImage(ctx, inputPath, outputPath); err != nil {
+			return err
+		}
 		inputPath = outputPath
 	}
 	if _, err := p.mosaicClient.Create(client.MosaicCreateOptions{
@@ -98,23 +146,78 @@ func (p *mosaicPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRun
 	}); err != nil {
 		return err
 	}
-	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
-		Fields: []string{model.SnapshotFieldMosaic},
-		Mosaic: &model.S3Object{
-			Key:    filepath.FromSlash(opts.SnapshotID + "/mosaic"),
-			Bucket: opts.Bucket,
-		},
-	}); err != nil {
+	return nil
+}
+
+// createPages builds a mosaic for each page of the document, one page at a time
+// so that only a single rendered page is on disk at once. Only the first
+// pages are built when the document has more than the configured maximum.
+func (p *mosaicPipeline) createPages(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	count, err := p.pdfProc.CountPages(ctx, inputPath)
+	if err != nil {
 		return err
 	}
-	if opts.TaskID != nil {
-		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
-			Fields: []string{model.TaskFieldName, model.TaskFieldStatus},
-			Name:   helper.ToPtr("Done."),
-			Status: helper.ToPtr(model.TaskStatusSuccess),
-		}); err != nil {
+	if maxPages := p.config.Limits.MosaicMaxPages; maxPages > 0 && *count > maxPages {
+		logger.GetLogger().Named(logger.StrPipeline).
+			Warnw("🧩  too many pages, skipping the last ones", "key", opts.Key, "pages", *count, "max", maxPages)
+		count = helper.ToPtr(maxPages)
+	}
+	for page := 0; page < *count; page++ {
+		if opts.TaskID != nil {
+			if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+				Fields: []string{model.TaskFieldName},
+				Name:   helper.ToPtr(fmt.Sprintf("Creating mosaic of page %d of %d.", page+1, *count)),
+			}); err != nil {
+				return err
+			}
+		}
+		if err := p.createPage(ctx, inputPath, page, opts); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func (p *mosaicPipeline) createPage(ctx context.Context, inputPath string, page int, opts dto.PipelineRunOptions) error {
+	dpi, err := p.pageDPI(ctx, inputPath, page)
+	if err != nil {
+		return err
+	}
+	outputPath, err := p.pdfProc.RenderPage(ctx, inputPath, page, dpi, filepath.Join(os.TempDir(), helper.NewID()))
+	if err != nil {
+		return err
+	}
+	defer func(path string) {
+		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(outputPath)
+	if _, err := p.mosaicClient.Create(client.MosaicCreateOptions{
+		Path:     outputPath,
+		S3Key:    filepath.FromSlash(opts.SnapshotID),
+		S3Bucket: opts.Bucket,
+		Page:     helper.ToPtr(page),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// pageDPI returns the resolution to render the page at, MosaicPageDPI unless
+// the page is so large that it would exceed MosaicPageMaxPixels or MosaicPageMaxSide.
+func (p *mosaicPipeline) pageDPI(ctx context.Context, inputPath string, page int) (int, error) {
+	width, height, err := p.pdfProc.PageSize(ctx, inputPath, page)
+	if err != nil {
+		return 0, err
+	}
+	if width <= 0 || height <= 0 {
+		return 0, fmt.Errorf("invalid size of page %d: %gx%g", page+1, width, height)
+	}
+	/* Page sizes are in points, there are 72 points per inch */
+	dpi := float64(MosaicPageDPI)
+	dpi = min(dpi, math.Sqrt(MosaicPageMaxPixels/(width/72*height/72)))
+	dpi = min(dpi, MosaicPageMaxSide/(max(width, height)/72))
+	return max(int(dpi), 1), nil
+}
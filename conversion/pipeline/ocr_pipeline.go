@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -52,7 +53,7 @@ func NewOCRPipeline() Pipeline {
 	}
 }
 
-func (p *ocrPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *ocrPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -64,10 +65,10 @@ func (p *ocrPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *ocrPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *ocrPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -77,7 +78,7 @@ func (p *ocrPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 		}
 	}
 	if opts.Intent != nil && *opts.Intent == model.SnapshotIntentDocument && opts.Language != nil && *opts.Language != "" {
-		_ = p.patchText(inputPath, opts)
+		_ = p.patchText(ctx, inputPath, opts)
 	}
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
@@ -91,19 +92,16 @@ func (p *ocrPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 	return nil
 }
 
-func (p *ocrPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *ocrPipeline) patchText(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	// Generate PDF/A
 	var pdfPath string
 	// Get DPI
-	dpi, err := p.imageProc.DPIFromImage(inputPath)
+	dpi, err := p.imageProc.DPIFromImage(ctx, inputPath)
 	if err != nil {
 		dpi = helper.ToPtr(72)
 	}
 	// Remove alpha channel
 	noAlphaImagePath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
-	if err := p.imageProc.RemoveAlphaChannel(inputPath, noAlphaImagePath); err != nil {
-		return err
-	}
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 			return
@@ -111,11 +109,11 @@ func (p *ocrPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) e
 			logger.GetLogger().Error(err)
 		}
 	}(noAlphaImagePath)
-	// Convert to PDF/A
-	pdfPath = filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".pdf")
-	if err := p.ocrProc.SearchablePDFFromFile(noAlphaImagePath, *opts.Language, *dpi, pdfPath); err != nil {
+	if err := p.imageProc.RemoveAlphaChannel(ctx, inputPath, noAlphaImagePath); err != nil {
 		return err
 	}
+	// Convert to PDF/A
+	pdfPath = filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".pdf")
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 			return
@@ -123,12 +121,15 @@ func (p *ocrPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) e
 			logger.GetLogger().Error(err)
 		}
 	}(pdfPath)
+	if err := p.ocrProc.SearchablePDFFromFile(ctx, noAlphaImagePath, *opts.Language, *dpi, pdfPath); err != nil {
+		return err
+	}
 	// Set OCR S3 object
 	stat, err := os.Stat(pdfPath)
 	if err != nil {
 		return err
 	}
-	count, err := p.pdfProc.CountPages(pdfPath)
+	count, err := p.pdfProc.CountPages(ctx, pdfPath)
 	if err != nil {
 		return err
 	}
@@ -153,7 +154,7 @@ func (p *ocrPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) e
 		return err
 	}
 	// Extract text
-	text, err := p.pdfProc.TextFromPDF(pdfPath)
+	text, err := p.pdfProc.TextFromPDF(ctx, pdfPath)
 	if err != nil {
 		return err
 	}
@@ -175,5 +176,10 @@ func (p *ocrPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) e
 	}); err != nil {
 		return err
 	}
+	// The text is chunked and embedded for the semantic search, which is
+	// optional, so the preview and the text are kept even if it fails
+	if err := p.snapshotClient.Embed(opts.SnapshotID); err != nil {
+		logger.GetLogger().Error(err)
+	}
 	return nil
 }
@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 type officePipeline struct {
 	pdfPipeline    Pipeline
 	officeProc     *processor.OfficeProcessor
+	metadataProc   *processor.MetadataProcessor
 	pdfProc        *processor.PDFProcessor
 	s3             infra.S3Manager
 	config         *config.Config
@@ -42,6 +44,7 @@ func NewOfficePipeline() Pipeline {
 	return &officePipeline{
 		pdfPipeline:    NewPDFPipeline(),
 		officeProc:     processor.NewOfficeProcessor(),
+		metadataProc:   processor.NewMetadataProcessor(),
 		pdfProc:        processor.NewPDFProcessor(),
 		s3:             infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
 		config:         config.GetConfig(),
@@ -50,7 +53,7 @@ func NewOfficePipeline() Pipeline {
 	}
 }
 
-func (p *officePipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *officePipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -63,10 +66,20 @@ func (p *officePipeline) Run(opts dto.PipelineRunOptions) error {
 			}
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *officePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *officePipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	if opts.TaskID != nil {
+		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+			Fields: []string{model.TaskFieldName},
+			Name:   helper.ToPtr("Extracting metadata."),
+		}); err != nil {
+			return err
+		}
+	}
+	// Metadata is informative, so we don't consider failing to extract it an error
+	_ = p.patchMetadata(ctx, inputPath, opts)
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -75,7 +88,7 @@ func (p *officePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRun
 			return err
 		}
 	}
-	pdfPath, err := p.patchPreviewWithPDF(inputPath, opts)
+	pdfPath, err := p.patchPreviewWithPDF(ctx, inputPath, opts)
 	if err != nil {
 		return err
 	}
@@ -86,20 +99,20 @@ func (p *officePipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRun
 			logger.GetLogger().Error(err)
 		}
 	}(*pdfPath)
-	return p.pdfPipeline.RunFromLocalPath(*pdfPath, opts)
+	return p.pdfPipeline.RunFromLocalPath(ctx, *pdfPath, opts)
 }
 
-func (p *officePipeline) patchPreviewWithPDF(inputPath string, opts dto.PipelineRunOptions) (*string, error) {
+func (p *officePipeline) patchPreviewWithPDF(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) (*string, error) {
 	outputDir := filepath.FromSlash(os.TempDir() + "/" + helper.NewID())
-	outputPath, err := p.officeProc.PDF(inputPath, outputDir)
-	if err != nil {
-		return nil, err
-	}
 	defer func(path string) {
 		if err := os.RemoveAll(path); err != nil {
 			logger.GetLogger().Error(err)
 		}
 	}(outputDir)
+	outputPath, err := p.officeProc.PDF(ctx, inputPath, outputDir)
+	if err != nil {
+		return nil, err
+	}
 	pdfPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".pdf")
 	if err := os.Rename(*outputPath, pdfPath); err != nil {
 		return nil, err
@@ -124,3 +137,17 @@ func (p *officePipeline) patchPreviewWithPDF(inputPath string, opts dto.Pipeline
 	}
 	return &pdfPath, nil
 }
+
+func (p *officePipeline) patchMetadata(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	metadata, err := p.metadataProc.Extract(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields:   []string{model.SnapshotFieldMetadata},
+		Metadata: metadata,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 type pdfPipeline struct {
 	pdfProc        *processor.PDFProcessor
 	imageProc      *processor.ImageProcessor
+	metadataProc   *processor.MetadataProcessor
 	s3             infra.S3Manager
 	taskClient     *client.TaskClient
 	snapshotClient *client.SnapshotClient
@@ -42,6 +44,7 @@ func NewPDFPipeline() Pipeline {
 	return &pdfPipeline{
 		pdfProc:        processor.NewPDFProcessor(),
 		imageProc:      processor.NewImageProcessor(),
+		metadataProc:   processor.NewMetadataProcessor(),
 		s3:             infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
 		taskClient:     client.NewTaskClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
 		snapshotClient: client.NewSnapshotClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey),
@@ -50,7 +53,7 @@ func NewPDFPipeline() Pipeline {
 	}
 }
 
-func (p *pdfPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *pdfPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -62,11 +65,11 @@ func (p *pdfPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *pdfPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
-	count, err := p.pdfProc.CountPages(inputPath)
+func (p *pdfPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	count, err := p.pdfProc.CountPages(ctx, inputPath)
 	if err != nil {
 		return err
 	}
@@ -87,7 +90,21 @@ func (p *pdfPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 			return err
 		}
 	}
-	_ = p.patchThumbnail(inputPath, opts)
+	_ = p.patchThumbnail(ctx, inputPath, opts)
+	// Office files are converted to PDF and passed to this pipeline, their
+	// metadata has already been extracted from the original
+	if p.fileIdent.IsPDF(opts.Key) {
+		if opts.TaskID != nil {
+			if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
+				Fields: []string{model.TaskFieldName},
+				Name:   helper.ToPtr("Extracting metadata."),
+			}); err != nil {
+				return err
+			}
+		}
+		// Metadata is informative, so we don't consider failing to extract it an error
+		_ = p.patchMetadata(ctx, inputPath, opts)
+	}
 	if opts.TaskID != nil {
 		if _, err := p.taskClient.Patch(*opts.TaskID, dto.TaskPatchOptions{
 			Fields: []string{model.TaskFieldName},
@@ -102,7 +119,7 @@ func (p *pdfPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 			return err
 		}
 	}
-	if err := p.patchText(inputPath, opts); err != nil {
+	if err := p.patchText(ctx, inputPath, opts); err != nil {
 		return err
 	}
 	if opts.TaskID != nil {
@@ -117,11 +134,8 @@ func (p *pdfPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 	return nil
 }
 
-func (p *pdfPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *pdfPipeline) patchThumbnail(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".png")
-	if err := p.pdfProc.Thumbnail(inputPath, 0, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
-		return err
-	}
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 			return
@@ -129,7 +143,10 @@ func (p *pdfPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptio
 			logger.GetLogger().Error(err)
 		}
 	}(outputPath)
-	props, err := p.imageProc.MeasureImage(outputPath)
+	if err := p.pdfProc.Thumbnail(ctx, inputPath, 0, p.config.Limits.ImagePreviewMaxHeight, outputPath); err != nil {
+		return err
+	}
+	props, err := p.imageProc.MeasureImage(ctx, outputPath)
 	if err != nil {
 		return err
 	}
@@ -155,8 +172,8 @@ func (p *pdfPipeline) patchThumbnail(inputPath string, opts dto.PipelineRunOptio
 	return nil
 }
 
-func (p *pdfPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) error {
-	text, err := p.pdfProc.TextFromPDF(inputPath)
+func (p *pdfPipeline) patchText(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	text, err := p.pdfProc.TextFromPDF(ctx, inputPath)
 	if err != nil {
 		return err
 	}
@@ -177,6 +194,11 @@ func (p *pdfPipeline) patchText(inputPath string, opts dto.PipelineRunOptions) e
 	}); err != nil {
 		return err
 	}
+	// The text is chunked and embedded for the semantic search, which is
+	// optional, so the preview and the text are kept even if it fails
+	if err := p.snapshotClient.Embed(opts.SnapshotID); err != nil {
+		logger.GetLogger().Error(err)
+	}
 	return nil
 }
 
@@ -214,3 +236,17 @@ func (p *pdfPipeline) patchPreviewWithOriginal(inputPath string, document *model
 	}
 	return nil
 }
+
+func (p *pdfPipeline) patchMetadata(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
+	metadata, err := p.metadataProc.Extract(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := p.snapshotClient.Patch(opts.SnapshotID, dto.SnapshotPatchOptions{
+		Fields:   []string{model.SnapshotFieldMetadata},
+		Metadata: metadata,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -10,9 +10,13 @@
 
 package pipeline
 
-import "github.com/kouprlabs/voltaserve/shared/dto"
+import (
+	"context"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+)
 
 type Pipeline interface {
-	Run(dto.PipelineRunOptions) error
-	RunFromLocalPath(string, dto.PipelineRunOptions) error
+	Run(context.Context, dto.PipelineRunOptions) error
+	RunFromLocalPath(context.Context, string, dto.PipelineRunOptions) error
 }
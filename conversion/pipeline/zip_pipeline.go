@@ -11,6 +11,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -50,7 +51,7 @@ func NewZIPPipeline() Pipeline {
 	}
 }
 
-func (p *zipPipeline) Run(opts dto.PipelineRunOptions) error {
+func (p *zipPipeline) Run(ctx context.Context, opts dto.PipelineRunOptions) error {
 	inputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(opts.Key))
 	if err := p.s3.GetFile(opts.Key, inputPath, opts.Bucket, minio.GetObjectOptions{}); err != nil {
 		return err
@@ -62,10 +63,10 @@ func (p *zipPipeline) Run(opts dto.PipelineRunOptions) error {
 			logger.GetLogger().Error(err)
 		}
 	}(inputPath)
-	return p.RunFromLocalPath(inputPath, opts)
+	return p.RunFromLocalPath(ctx, inputPath, opts)
 }
 
-func (p *zipPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOptions) error {
+func (p *zipPipeline) RunFromLocalPath(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) error {
 	isGLTF, err := p.fileIdent.IsGLTF(inputPath)
 	if err != nil {
 		return err
@@ -85,7 +86,7 @@ func (p *zipPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 				logger.GetLogger().Error(err)
 			}
 		}(tmpDir)
-		if err := p.zipProc.Extract(inputPath, tmpDir); err != nil {
+		if err := p.zipProc.Extract(ctx, inputPath, tmpDir); err != nil {
 			return err
 		}
 		gltfPath, err := helper.FindFileWithExtension(tmpDir, ".gltf")
@@ -104,7 +105,7 @@ func (p *zipPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 				return err
 			}
 		}
-		glbPath, err := p.patchPreviewWithGLB(*gltfPath, opts)
+		glbPath, err := p.patchPreviewWithGLB(ctx, *gltfPath, opts)
 		if err != nil {
 			return err
 		}
@@ -115,7 +116,7 @@ func (p *zipPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 				logger.GetLogger().Error(err)
 			}
 		}(*glbPath)
-		if err := p.glbPipeline.RunFromLocalPath(*glbPath, opts); err != nil {
+		if err := p.glbPipeline.RunFromLocalPath(ctx, *glbPath, opts); err != nil {
 			return err
 		}
 	}
@@ -123,9 +124,10 @@ func (p *zipPipeline) RunFromLocalPath(inputPath string, opts dto.PipelineRunOpt
 	return nil
 }
 
-func (p *zipPipeline) patchPreviewWithGLB(inputPath string, opts dto.PipelineRunOptions) (*string, error) {
+func (p *zipPipeline) patchPreviewWithGLB(ctx context.Context, inputPath string, opts dto.PipelineRunOptions) (*string, error) {
 	outputPath := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + ".glb")
-	if err := p.gltfProc.ToGLB(inputPath, outputPath); err != nil {
+	if err := p.gltfProc.ToGLB(ctx, inputPath, outputPath); err != nil {
+		_ = os.Remove(outputPath)
 		return nil, err
 	}
 	stat, err := os.Stat(outputPath)
@@ -11,6 +11,7 @@
 package processor
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -36,8 +37,8 @@ func NewGLBProcessor() *GLBProcessor {
 	}
 }
 
-func (p *GLBProcessor) Thumbnail(inputPath string, width int, height int, outputPath string) error {
-	err := infra.NewCommand().Exec("blender", "--background", "--python-expr", `
+func (p *GLBProcessor) Thumbnail(ctx context.Context, inputPath string, width int, height int, outputPath string) error {
+	err := infra.NewCommand().Exec(ctx, "blender", "--background", "--python-expr", `
 import bpy
 import sys
 from mathutils import Vector
@@ -11,6 +11,11 @@
 package processor
 
 import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
 	"strconv"
 	"strings"
 
@@ -23,6 +28,7 @@ import (
 
 	"github.com/kouprlabs/voltaserve/conversion/config"
 	conversioninfra "github.com/kouprlabs/voltaserve/conversion/infra"
+	"github.com/kouprlabs/voltaserve/conversion/logger"
 )
 
 type ImageProcessor struct {
@@ -37,14 +43,26 @@ func NewImageProcessor() *ImageProcessor {
 	}
 }
 
+// ImageDiffThreshold is how much a color channel of a pixel can change, on a
+// scale of 0 to 255, before the pixel is considered different. It absorbs the
+// noise introduced by lossy compression.
+const ImageDiffThreshold = 16
+
+// ImageDiffMaxPixels caps the size of the canvas on which images are compared,
+// since both images and the output are decoded in memory.
+const ImageDiffMaxPixels = 40_000_000
+
+// ErrImageTooLarge is returned when images are too large to be compared.
+var ErrImageTooLarge = errors.New("image too large")
+
 type ThumbnailResult struct {
 	Width     int
 	Height    int
 	IsCreated bool
 }
 
-func (p *ImageProcessor) Thumbnail(inputPath string, width int, height int, outputPath string) (*ThumbnailResult, error) {
-	props, err := p.MeasureImage(inputPath)
+func (p *ImageProcessor) Thumbnail(ctx context.Context, inputPath string, width int, height int, outputPath string) (*ThumbnailResult, error) {
+	props, err := p.MeasureImage(ctx, inputPath)
 	if err != nil {
 		return nil, err
 	}
@@ -53,12 +71,12 @@ func (p *ImageProcessor) Thumbnail(inputPath string, width int, height int, outp
 	if props.Width > p.config.Limits.ImagePreviewMaxWidth || props.Height > p.config.Limits.ImagePreviewMaxHeight {
 		if props.Width > props.Height {
 			newWidth, newHeight = helper.AspectRatio(width, 0, props.Width, props.Height)
-			if err := p.ResizeImage(inputPath, newWidth, newHeight, outputPath); err != nil {
+			if err := p.ResizeImage(ctx, inputPath, newWidth, newHeight, outputPath); err != nil {
 				return nil, err
 			}
 		} else {
 			newWidth, newHeight = helper.AspectRatio(0, height, props.Width, props.Height)
-			if err := p.ResizeImage(inputPath, newWidth, newHeight, outputPath); err != nil {
+			if err := p.ResizeImage(ctx, inputPath, newWidth, newHeight, outputPath); err != nil {
 				return nil, err
 			}
 		}
@@ -75,7 +93,7 @@ func (p *ImageProcessor) Thumbnail(inputPath string, width int, height int, outp
 	}
 }
 
-func (p *ImageProcessor) MeasureImage(inputPath string) (*model.ImageProps, error) {
+func (p *ImageProcessor) MeasureImage(ctx context.Context, inputPath string) (*model.ImageProps, error) {
 	bildImage, err := imgio.Open(inputPath)
 	if err == nil {
 		return &model.ImageProps{
@@ -83,7 +101,7 @@ func (p *ImageProcessor) MeasureImage(inputPath string) (*model.ImageProps, erro
 			Height: bildImage.Bounds().Dy(),
 		}, nil
 	} else {
-		size, err := conversioninfra.NewCommand().ReadOutput("identify", "-format", "%w,%h", inputPath)
+		size, err := conversioninfra.NewCommand().ReadOutput(ctx, "identify", "-format", "%w,%h", inputPath)
 		if err != nil {
 			return nil, err
 		}
@@ -103,7 +121,7 @@ func (p *ImageProcessor) MeasureImage(inputPath string) (*model.ImageProps, erro
 	}
 }
 
-func (p *ImageProcessor) ResizeImage(inputPath string, width int, height int, outputPath string) error {
+func (p *ImageProcessor) ResizeImage(ctx context.Context, inputPath string, width int, height int, outputPath string) error {
 	bildImage, err := imgio.Open(inputPath)
 	if err == nil && p.IsSupportedByBild(outputPath) {
 		newImage := transform.Resize(bildImage, width, height, transform.Lanczos)
@@ -127,14 +145,14 @@ func (p *ImageProcessor) ResizeImage(inputPath string, width int, height int, ou
 		} else {
 			heightStr = strconv.FormatInt(int64(height), 10)
 		}
-		if err := conversioninfra.NewCommand().Exec("convert", "-resize", widthStr+"x"+heightStr, inputPath, outputPath); err != nil {
+		if err := conversioninfra.NewCommand().Exec(ctx, "convert", "-resize", widthStr+"x"+heightStr, inputPath, outputPath); err != nil {
 			return err
 		}
 		return nil
 	}
 }
 
-func (p *ImageProcessor) ConvertImage(inputPath string, outputPath string) error {
+func (p *ImageProcessor) ConvertImage(ctx context.Context, inputPath string, outputPath string) error {
 	bildImage, err := imgio.Open(inputPath)
 	if err == nil && p.IsSupportedByBild(inputPath) && p.IsSupportedByBild(outputPath) {
 		var encoder imgio.Encoder
@@ -145,27 +163,27 @@ func (p *ImageProcessor) ConvertImage(inputPath string, outputPath string) error
 		}
 		return imgio.Save(outputPath, bildImage, encoder)
 	} else {
-		if err := conversioninfra.NewCommand().Exec("convert", inputPath, outputPath); err != nil {
+		if err := conversioninfra.NewCommand().Exec(ctx, "convert", inputPath, outputPath); err != nil {
 			return err
 		}
 		return nil
 	}
 }
 
-func (p *ImageProcessor) RemoveAlphaChannel(inputPath string, outputPath string) error {
+func (p *ImageProcessor) RemoveAlphaChannel(ctx context.Context, inputPath string, outputPath string) error {
 	bildImage, err := imgio.Open(inputPath)
 	if err == nil && p.IsSupportedByBild(outputPath) {
 		return imgio.Save(outputPath, bildImage, imgio.JPEGEncoder(100))
 	} else {
-		if err := conversioninfra.NewCommand().Exec("convert", inputPath, "-alpha", "off", outputPath); err != nil {
+		if err := conversioninfra.NewCommand().Exec(ctx, "convert", inputPath, "-alpha", "off", outputPath); err != nil {
 			return err
 		}
 		return nil
 	}
 }
 
-func (p *ImageProcessor) DPIFromImage(inputPath string) (*int, error) {
-	output, err := conversioninfra.NewCommand().ReadOutput("exiftool", "-S", "-s", "-ImageWidth", "-ImageHeight", "-XResolution", "-YResolution", "-ResolutionUnit", inputPath)
+func (p *ImageProcessor) DPIFromImage(ctx context.Context, inputPath string) (*int, error) {
+	output, err := conversioninfra.NewCommand().ReadOutput(ctx, "exiftool", "-S", "-s", "-ImageWidth", "-ImageHeight", "-XResolution", "-YResolution", "-ResolutionUnit", inputPath)
 	if err != nil {
 		return nil, err
 	}
@@ -187,3 +205,104 @@ func (p *ImageProcessor) DPIFromImage(inputPath string) (*int, error) {
 func (p *ImageProcessor) IsSupportedByBild(path string) bool {
 	return p.fileIdent.IsJPEG(path) || p.fileIdent.IsPNG(path)
 }
+
+type DiffImagesResult struct {
+	Width  int
+	Height int
+	// Score is the fraction of pixels that differ, from 0 to 1.
+	Score float64
+}
+
+// DiffImages compares both images pixel by pixel and writes a PNG to outputPath,
+// where the pixels that differ are red and the others are a faded version of
+// the target image. Images of different sizes are compared on a canvas that
+// fits both, the area covered by only one of them counts as different. Both
+// images must be supported by bild, and the canvas must not exceed
+// ImageDiffMaxPixels, which is checked from the headers before decoding.
+func (p *ImageProcessor) DiffImages(ctx context.Context, basePath string, targetPath string, outputPath string) (*DiffImagesResult, error) {
+	baseConfig, err := p.decodeConfig(basePath)
+	if err != nil {
+		return nil, err
+	}
+	targetConfig, err := p.decodeConfig(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	if int64(max(baseConfig.Width, targetConfig.Width))*int64(max(baseConfig.Height, targetConfig.Height)) > ImageDiffMaxPixels {
+		return nil, ErrImageTooLarge
+	}
+	base, err := imgio.Open(basePath)
+	if err != nil {
+		return nil, err
+	}
+	target, err := imgio.Open(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	baseBounds := base.Bounds()
+	targetBounds := target.Bounds()
+	width := max(baseBounds.Dx(), targetBounds.Dx())
+	height := max(baseBounds.Dy(), targetBounds.Dy())
+	output := image.NewRGBA(image.Rect(0, 0, width, height))
+	highlight := color.RGBA{R: 255, A: 255}
+	var changed int
+	for y := 0; y < height; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for x := 0; x < width; x++ {
+			inBase := x < baseBounds.Dx() && y < baseBounds.Dy()
+			inTarget := x < targetBounds.Dx() && y < targetBounds.Dy()
+			if !inBase || !inTarget {
+				changed++
+				output.SetRGBA(x, y, highlight)
+				continue
+			}
+			a := color.RGBAModel.Convert(base.At(baseBounds.Min.X+x, baseBounds.Min.Y+y)).(color.RGBA)
+			b := color.RGBAModel.Convert(target.At(targetBounds.Min.X+x, targetBounds.Min.Y+y)).(color.RGBA)
+			if p.channelDelta(a.R, b.R) > ImageDiffThreshold ||
+				p.channelDelta(a.G, b.G) > ImageDiffThreshold ||
+				p.channelDelta(a.B, b.B) > ImageDiffThreshold ||
+				p.channelDelta(a.A, b.A) > ImageDiffThreshold {
+				changed++
+				output.SetRGBA(x, y, highlight)
+			} else {
+				gray := color.GrayModel.Convert(b).(color.Gray).Y
+				faded := 192 + gray/4
+				output.SetRGBA(x, y, color.RGBA{R: faded, G: faded, B: faded, A: 255})
+			}
+		}
+	}
+	if err := imgio.Save(outputPath, output, imgio.PNGEncoder()); err != nil {
+		return nil, err
+	}
+	res := &DiffImagesResult{
+		Width:  width,
+		Height: height,
+	}
+	if width > 0 && height > 0 {
+		res.Score = float64(changed) / float64(width*height)
+	}
+	return res, nil
+}
+
+func (p *ImageProcessor) decodeConfig(path string) (image.Config, error) {
+	file, err := os.Open(path) //nolint:gosec // Known path
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer func(file *os.File) {
+		if err := file.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(file)
+	res, _, err := image.DecodeConfig(file)
+	return res, err
+}
+
+func (p *ImageProcessor) channelDelta(a uint8, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
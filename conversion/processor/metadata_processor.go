@@ -0,0 +1,206 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+
+	conversioninfra "github.com/kouprlabs/voltaserve/conversion/infra"
+)
+
+// ErrUnsupportedFileType is returned when no tool can process the file.
+var ErrUnsupportedFileType = errors.New("unsupported file type")
+
+type MetadataProcessor struct {
+	cmd       *conversioninfra.Command
+	fileIdent *infra.FileIdentifier
+}
+
+func NewMetadataProcessor() *MetadataProcessor {
+	return &MetadataProcessor{
+		cmd:       conversioninfra.NewCommand(),
+		fileIdent: infra.NewFileIdentifier(),
+	}
+}
+
+// Extract reads the metadata relevant to the type of the file: stream
+// properties for audio and video, EXIF for images, and document properties
+// for PDF and Office files.
+func (p *MetadataProcessor) Extract(ctx context.Context, inputPath string) (*model.SnapshotMetadata, error) {
+	if p.fileIdent.IsAudio(inputPath) || p.fileIdent.IsVideo(inputPath) {
+		media, err := p.extractMedia(ctx, inputPath)
+		if err != nil {
+			return nil, err
+		}
+		return &model.SnapshotMetadata{Media: media}, nil
+	} else if p.fileIdent.IsImage(inputPath) {
+		image, err := p.extractImage(ctx, inputPath)
+		if err != nil {
+			return nil, err
+		}
+		return &model.SnapshotMetadata{Image: image}, nil
+	} else if p.fileIdent.IsPDF(inputPath) || p.fileIdent.IsOffice(inputPath) {
+		document, err := p.extractDocument(ctx, inputPath)
+		if err != nil {
+			return nil, err
+		}
+		return &model.SnapshotMetadata{Document: document}, nil
+	}
+	return nil, ErrUnsupportedFileType
+}
+
+func (p *MetadataProcessor) extractMedia(ctx context.Context, inputPath string) (*model.MediaMetadata, error) {
+	probe, err := ffprobe(ctx, p.cmd, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	res := &model.MediaMetadata{}
+	res.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	res.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 && res.VideoCodec == nil {
+			res.VideoCodec = helper.ToPtr(stream.CodecName)
+			res.FrameRate = parseFrameRate(stream.AvgFrameRate)
+		} else if stream.CodecType == "audio" && res.AudioCodec == nil {
+			res.AudioCodec = helper.ToPtr(stream.CodecName)
+			res.AudioChannels = stream.Channels
+		}
+	}
+	return res, nil
+}
+
+func (p *MetadataProcessor) extractImage(ctx context.Context, inputPath string) (*model.ImageMetadata, error) {
+	// The # suffix disables the print conversion, so that GPS coordinates are
+	// signed decimal degrees and the orientation is the EXIF number
+	tags, err := p.exiftool(ctx, inputPath, "-Make", "-Model", "-GPSLatitude#", "-GPSLongitude#", "-DateTimeOriginal", "-CreateDate", "-Orientation#")
+	if err != nil {
+		return nil, err
+	}
+	res := &model.ImageMetadata{
+		CameraMake:  tagString(tags, "Make"),
+		CameraModel: tagString(tags, "Model"),
+		Latitude:    tagFloat(tags, "GPSLatitude"),
+		Longitude:   tagFloat(tags, "GPSLongitude"),
+		CaptureTime: tagTime(tags, "DateTimeOriginal", "CreateDate"),
+	}
+	if orientation := tagFloat(tags, "Orientation"); orientation != nil {
+		res.Orientation = helper.ToPtr(int(*orientation))
+	}
+	return res, nil
+}
+
+func (p *MetadataProcessor) extractDocument(ctx context.Context, inputPath string) (*model.DocumentMetadata, error) {
+	// The creator of the PDF info dictionary is the application which created
+	// the file, hence only the Dublin Core one is used for the author
+	tags, err := p.exiftool(ctx, inputPath, "-Title", "-Author", "-XMP-dc:Creator", "-Initial-creator", "-CreateDate", "-Creation-date")
+	if err != nil {
+		return nil, err
+	}
+	res := &model.DocumentMetadata{
+		Title:        tagString(tags, "Title"),
+		Author:       tagString(tags, "Author", "Creator", "Initial-creator"),
+		CreationDate: tagTime(tags, "CreateDate", "Creation-date"),
+	}
+	return res, nil
+}
+
+func (p *MetadataProcessor) exiftool(ctx context.Context, inputPath string, tags ...string) (map[string]any, error) {
+	output, err := p.cmd.ReadOutput(ctx, "exiftool", append(append([]string{"-json"}, tags...), inputPath)...)
+	if err != nil {
+		return nil, err
+	}
+	var res []map[string]any
+	if err := json.Unmarshal([]byte(*output), &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return map[string]any{}, nil
+	}
+	return res[0], nil
+}
+
+// tagString returns the first of the tags which is set, exiftool outputs
+// numeric looking values as numbers and repeated ones as arrays.
+func tagString(tags map[string]any, names ...string) *string {
+	for _, name := range names {
+		switch v := tags[name].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return helper.ToPtr(v)
+			}
+		case float64:
+			return helper.ToPtr(strconv.FormatFloat(v, 'f', -1, 64))
+		case []any:
+			if len(v) > 0 {
+				return helper.ToPtr(fmt.Sprint(v[0]))
+			}
+		}
+	}
+	return nil
+}
+
+func tagFloat(tags map[string]any, name string) *float64 {
+	switch v := tags[name].(type) {
+	case float64:
+		return helper.ToPtr(v)
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return helper.ToPtr(f)
+		}
+	}
+	return nil
+}
+
+// tagTime converts the EXIF date format to RFC 3339, dates without a time
+// zone are assumed to be UTC.
+func tagTime(tags map[string]any, names ...string) *string {
+	for _, name := range names {
+		value := tagString(tags, name)
+		if value == nil {
+			continue
+		}
+		for _, layout := range []string{
+			"2006:01:02 15:04:05.999999999Z07:00",
+			"2006:01:02 15:04:05.999999999",
+		} {
+			if t, err := time.Parse(layout, *value); err == nil {
+				return helper.ToPtr(helper.TimeToString(t))
+			}
+		}
+	}
+	return nil
+}
+
+func parseFrameRate(value string) float64 {
+	numerator, denominator, found := strings.Cut(value, "/")
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -11,6 +11,7 @@
 package processor
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -39,11 +40,8 @@ func NewPDFProcessor() *PDFProcessor {
 	}
 }
 
-func (p *PDFProcessor) TextFromPDF(inputPath string) (*string, error) {
+func (p *PDFProcessor) TextFromPDF(ctx context.Context, inputPath string) (*string, error) {
 	path := filepath.Join(os.TempDir(), helper.NewID()+".txt")
-	if err := infra.NewCommand().Exec("pdftotext", inputPath, path); err != nil {
-		return nil, err
-	}
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 			return
@@ -51,6 +49,9 @@ func (p *PDFProcessor) TextFromPDF(inputPath string) (*string, error) {
 			logger.GetLogger().Error(err)
 		}
 	}(path)
+	if err := infra.NewCommand().Exec(ctx, "pdftotext", inputPath, path); err != nil {
+		return nil, err
+	}
 	b, err := os.ReadFile(path) //nolint:gosec // Known path
 	if err != nil {
 		return nil, err
@@ -59,7 +60,7 @@ func (p *PDFProcessor) TextFromPDF(inputPath string) (*string, error) {
 	return helper.ToPtr(strings.TrimSpace(string(b))), nil
 }
 
-func (p *PDFProcessor) Thumbnail(inputPath string, width int, height int, outputPath string) error {
+func (p *PDFProcessor) Thumbnail(ctx context.Context, inputPath string, width int, height int, outputPath string) error {
 	var widthStr string
 	if width == 0 {
 		widthStr = ""
@@ -72,14 +73,14 @@ func (p *PDFProcessor) Thumbnail(inputPath string, width int, height int, output
 	} else {
 		heightStr = strconv.FormatInt(int64(height), 10)
 	}
-	if err := infra.NewCommand().Exec("convert", "-thumbnail", widthStr+"x"+heightStr, "-background", "white", "-alpha", "remove", "-flatten", fmt.Sprintf("%s[0]", inputPath), outputPath); err != nil {
+	if err := infra.NewCommand().Exec(ctx, "convert", "-thumbnail", widthStr+"x"+heightStr, "-background", "white", "-alpha", "remove", "-flatten", fmt.Sprintf("%s[0]", inputPath), outputPath); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *PDFProcessor) CountPages(inputPath string) (*int, error) {
-	output, err := infra.NewCommand().ReadOutput("qpdf", "--show-npages", inputPath)
+func (p *PDFProcessor) CountPages(ctx context.Context, inputPath string) (*int, error) {
+	output, err := infra.NewCommand().ReadOutput(ctx, "qpdf", "--show-npages", inputPath)
 	if err != nil {
 		return nil, err
 	}
@@ -89,3 +90,38 @@ func (p *PDFProcessor) CountPages(inputPath string) (*int, error) {
 	}
 	return &count, nil
 }
+
+// PageSize returns the width and height of a page, starting from zero, in
+// points, which are 1/72 of an inch.
+func (p *PDFProcessor) PageSize(ctx context.Context, inputPath string, page int) (float64, float64, error) {
+	number := strconv.Itoa(page + 1)
+	output, err := infra.NewCommand().ReadOutput(ctx, "pdfinfo", "-f", number, "-l", number, inputPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(*output, "\n") {
+		/* The line looks like "Page    1 size: 612 x 792 pts (letter)" */
+		if !strings.HasPrefix(line, "Page") || !strings.Contains(line, "size:") {
+			continue
+		}
+		var width, height float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(strings.SplitN(line, "size:", 2)[1]), "%f x %f", &width, &height); err != nil {
+			return 0, 0, err
+		}
+		return width, height, nil
+	}
+	return 0, 0, fmt.Errorf("page size of page %s not found", number)
+}
+
+// RenderPage rasterizes a page, starting from zero, to a JPEG at the given
+// resolution. The output is written to outputPrefix with a .jpg extension.
+func (p *PDFProcessor) RenderPage(ctx context.Context, inputPath string, page int, dpi int, outputPrefix string) (string, error) {
+	number := strconv.Itoa(page + 1)
+	if err := infra.NewCommand().Exec(
+		ctx, "pdftoppm", "-jpeg", "-jpegopt", "quality=90", "-r", strconv.Itoa(dpi),
+		"-f", number, "-l", number, "-singlefile", inputPath, outputPrefix,
+	); err != nil {
+		return "", err
+	}
+	return outputPrefix + ".jpg", nil
+}
@@ -11,11 +11,17 @@
 package processor
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
 
 	"github.com/kouprlabs/voltaserve/conversion/config"
 	"github.com/kouprlabs/voltaserve/conversion/infra"
@@ -36,11 +42,8 @@ func NewVideoProcessor() *VideoProcessor {
 	}
 }
 
-func (p *VideoProcessor) Thumbnail(inputPath string, width int, height int, outputPath string) error {
+func (p *VideoProcessor) Thumbnail(ctx context.Context, inputPath string, width int, height int, outputPath string) error {
 	path := filepath.FromSlash(os.TempDir() + "/" + helper.NewID() + filepath.Ext(outputPath))
-	if err := infra.NewCommand().Exec("ffmpeg", "-i", inputPath, "-frames:v", "1", path); err != nil {
-		return err
-	}
 	defer func(path string) {
 		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
 			return
@@ -48,20 +51,231 @@ func (p *VideoProcessor) Thumbnail(inputPath string, width int, height int, outp
 			logger.GetLogger().Error(err)
 		}
 	}(path)
-	size, err := p.imageProc.MeasureImage(path)
+	if err := infra.NewCommand().Exec(ctx, "ffmpeg", "-i", inputPath, "-frames:v", "1", path); err != nil {
+		return err
+	}
+	size, err := p.imageProc.MeasureImage(ctx, path)
 	if err != nil {
 		return err
 	}
 	if size.Width > size.Height {
 		newWidth, newHeight := helper.AspectRatio(width, 0, size.Width, size.Height)
-		if err := p.imageProc.ResizeImage(path, newWidth, newHeight, outputPath); err != nil {
+		if err := p.imageProc.ResizeImage(ctx, path, newWidth, newHeight, outputPath); err != nil {
 			return err
 		}
 	} else {
 		newWidth, newHeight := helper.AspectRatio(0, height, size.Width, size.Height)
-		if err := p.imageProc.ResizeImage(path, newWidth, newHeight, outputPath); err != nil {
+		if err := p.imageProc.ResizeImage(ctx, path, newWidth, newHeight, outputPath); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+type MediaProbe struct {
+	Duration float64
+	Width    int
+	Height   int
+	HasVideo bool
+	HasAudio bool
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		Channels     int    `json:"channels"`
+		Disposition  struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+func ffprobe(ctx context.Context, cmd *infra.Command, inputPath string) (*ffprobeOutput, error) {
+	output, err := cmd.ReadOutput(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_streams", "-show_format", inputPath)
+	if err != nil {
+		return nil, err
+	}
+	var res ffprobeOutput
+	if err := json.Unmarshal([]byte(*output), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Probe reads the streams of the media file, the cover art embedded in audio
+// files is reported as a video stream by ffprobe, so it is skipped.
+func (p *VideoProcessor) Probe(ctx context.Context, inputPath string) (*MediaProbe, error) {
+	probe, err := ffprobe(ctx, p.cmd, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	res := &MediaProbe{}
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 && !res.HasVideo {
+			res.HasVideo = true
+			res.Width = stream.Width
+			res.Height = stream.Height
+		} else if stream.CodecType == "audio" {
+			res.HasAudio = true
+		}
+	}
+	if !res.HasVideo && !res.HasAudio {
+		return nil, errors.New("no audio or video stream found")
+	}
+	if probe.Format.Duration != "" {
+		res.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	}
+	return res, nil
+}
+
+// TranscodeMP4 converts the media file to H.264/AAC, which every browser can
+// play, the video is downscaled to 1080p and the moov atom is moved to the front
+// of the file, so that playback starts before the whole file is downloaded.
+func (p *VideoProcessor) TranscodeMP4(ctx context.Context, inputPath string, probe *MediaProbe, outputPath string) error {
+	args := []string{"-y", "-i", inputPath}
+	if probe.HasVideo {
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p")
+		args = append(args, "-vf", scaleFilter(probe, mp4MaxShortSide))
+	} else {
+		args = append(args, "-map", "0:a:0", "-vn")
+	}
+	args = append(args, "-c:a", "aac", "-b:a", "192k", "-ac", "2", "-movflags", "+faststart", outputPath)
+	return p.cmd.Exec(ctx, "ffmpeg", args...)
+}
+
+// TranscodeHLS writes an HLS rendition per entry of the ladder which does not
+// upscale the source in a sub-directory of the output directory, and a master
+// playlist referencing them. Audio files get a single audio-only rendition.
+func (p *VideoProcessor) TranscodeHLS(ctx context.Context, inputPath string, probe *MediaProbe, outputDir string) ([]model.RenditionProps, error) {
+	var renditions []model.RenditionProps
+	if probe.HasVideo {
+		for _, r := range hlsLadder(probe) {
+			width, height := scaledSize(probe, r.shortSide)
+			args := []string{"-y", "-i", inputPath, "-map", "0:v:0", "-map", "0:a:0?",
+				"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+				"-b:v", fmt.Sprintf("%dk", r.videoBitrate),
+				"-maxrate", fmt.Sprintf("%dk", r.videoBitrate*107/100),
+				"-bufsize", fmt.Sprintf("%dk", r.videoBitrate*3/2),
+				"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds)}
+			args = append(args, "-vf", scaleFilter(probe, r.shortSide), "-c:a", "aac", "-b:a", fmt.Sprintf("%dk", r.audioBitrate), "-ac", "2")
+			if err := p.segment(ctx, args, filepath.Join(outputDir, r.name)); err != nil {
+				return nil, err
+			}
+			renditions = append(renditions, model.RenditionProps{
+				Name:      r.name,
+				Width:     width,
+				Height:    height,
+				Bandwidth: (r.videoBitrate + r.audioBitrate) * 1000,
+			})
+		}
+	} else {
+		args := []string{"-y", "-i", inputPath, "-map", "0:a:0", "-vn", "-c:a", "aac", "-b:a", "128k", "-ac", "2"}
+		if err := p.segment(ctx, args, filepath.Join(outputDir, hlsAudioRendition)); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, model.RenditionProps{
+			Name:      hlsAudioRendition,
+			Bandwidth: 128 * 1000,
+		})
+	}
+	if err := writeHLSMasterPlaylist(filepath.Join(outputDir, HLSMasterPlaylist), renditions); err != nil {
+		return nil, err
+	}
+	return renditions, nil
+}
+
+func (p *VideoProcessor) segment(ctx context.Context, args []string, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0o750); err != nil {
+		return err
+	}
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outputDir, "segment_%05d.ts"),
+		filepath.Join(outputDir, HLSRenditionPlaylist))
+	return p.cmd.Exec(ctx, "ffmpeg", args...)
+}
+
+const (
+	HLSMasterPlaylist    = "master.m3u8"
+	HLSRenditionPlaylist = "index.m3u8"
+	hlsAudioRendition    = "audio"
+	hlsSegmentSeconds    = 6
+	mp4MaxShortSide      = 1080
+)
+
+type hlsRendition struct {
+	name         string
+	shortSide    int
+	videoBitrate int
+	audioBitrate int
+}
+
+var hlsRenditions = []hlsRendition{
+	{name: "1080p", shortSide: 1080, videoBitrate: 5000, audioBitrate: 192},
+	{name: "720p", shortSide: 720, videoBitrate: 2800, audioBitrate: 128},
+	{name: "480p", shortSide: 480, videoBitrate: 1400, audioBitrate: 128},
+	{name: "360p", shortSide: 360, videoBitrate: 800, audioBitrate: 96},
+}
+
+// hlsLadder returns the renditions which do not upscale the source, or the
+// smallest one when the source is smaller than all of them.
+func hlsLadder(probe *MediaProbe) []hlsRendition {
+	shortSide := min(probe.Width, probe.Height)
+	var res []hlsRendition
+	for _, r := range hlsRenditions {
+		if r.shortSide <= shortSide {
+			res = append(res, r)
+		}
+	}
+	if len(res) == 0 {
+		res = append(res, hlsRenditions[len(hlsRenditions)-1])
+	}
+	return res
+}
+
+// scaleFilter downscales the short side of the video to the given size, which
+// keeps portrait videos in the same rendition as landscape ones. The dimensions
+// are rounded to even numbers, as required by yuv420p.
+func scaleFilter(probe *MediaProbe, shortSide int) string {
+	if min(probe.Width, probe.Height) <= shortSide {
+		return "scale=trunc(iw/2)*2:trunc(ih/2)*2"
+	}
+	if probe.Width < probe.Height {
+		return fmt.Sprintf("scale=%d:-2", shortSide)
+	}
+	return fmt.Sprintf("scale=-2:%d", shortSide)
+}
+
+func scaledSize(probe *MediaProbe, shortSide int) (int, int) {
+	if min(probe.Width, probe.Height) <= shortSide {
+		return probe.Width / 2 * 2, probe.Height / 2 * 2
+	}
+	if probe.Width < probe.Height {
+		return shortSide, probe.Height * shortSide / probe.Width / 2 * 2
+	}
+	return probe.Width * shortSide / probe.Height / 2 * 2, shortSide
+}
+
+func writeHLSMasterPlaylist(path string, renditions []model.RenditionProps) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", r.Bandwidth)
+		if r.Width > 0 && r.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", r.Width, r.Height)
+		}
+		b.WriteString("\n" + r.Name + "/" + HLSRenditionPlaylist + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
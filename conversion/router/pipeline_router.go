@@ -39,6 +39,7 @@ func NewPipelineRouter(opts NewPipelineRouterOptions) *PipelineRouter {
 
 func (r *PipelineRouter) AppendRoutes(g fiber.Router) {
 	g.Post("pipelines/run", r.Run)
+	g.Post("pipelines/cancel", r.Cancel)
 }
 
 // Run godoc
@@ -62,6 +63,35 @@ func (r *PipelineRouter) Run(c *fiber.Ctx) error {
 	if err := validator.New().Struct(opts); err != nil {
 		return errorpkg.NewRequestBodyValidationError(err)
 	}
-	r.scheduler.SchedulePipeline(opts)
+	if err := r.scheduler.SchedulePipeline(opts); err != nil {
+		return err
+	}
+	return c.SendStatus(200)
+}
+
+// Cancel godoc
+//
+//	@Summary		Cancel
+//	@Description	Cancel
+//	@Tags			Pipelines
+//	@Id				pipelines_cancel
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			body	body	dto.PipelineCancelOptions	true	"Body"
+//	@Success		200
+//	@Failure		400	{object}	errorpkg.ErrorResponse
+//	@Failure		500	{object}	errorpkg.ErrorResponse
+//	@Router			/pipelines/cancel [post]
+func (r *PipelineRouter) Cancel(c *fiber.Ctx) error {
+	opts := new(dto.PipelineCancelOptions)
+	if err := c.BodyParser(opts); err != nil {
+		return err
+	}
+	if err := validator.New().Struct(opts); err != nil {
+		return errorpkg.NewRequestBodyValidationError(err)
+	}
+	if err := r.scheduler.CancelPipeline(opts.TaskID); err != nil {
+		return err
+	}
 	return c.SendStatus(200)
 }
@@ -11,6 +11,7 @@
 package runtime
 
 import (
+	"context"
 	"github.com/kouprlabs/voltaserve/conversion/infra"
 	"github.com/kouprlabs/voltaserve/conversion/logger"
 )
@@ -46,7 +47,7 @@ func (d *Installer) Start() {
 
 func (d *Installer) updatePackageList() {
 	logger.GetLogger().Named(logger.StrInstaller).Infow("🔄  updating", "debian", "package list")
-	if err := d.cmd.Exec("apt-get", "update"); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", "update"); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "debian", "package list")
 		return
@@ -71,7 +72,7 @@ func (d *Installer) installCoreTools() {
 		"npm",
 	}
 	args := append([]string{"install", "-y"}, packages...)
-	if err := d.cmd.Exec("apt-get", args...); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", args...); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "core-tools")
 		return
@@ -81,7 +82,7 @@ func (d *Installer) installCoreTools() {
 
 func (d *Installer) installGltfPipeline() {
 	logger.GetLogger().Named(logger.StrInstaller).Infow("⬇️  installing", "package", "gltf-pipeline")
-	if err := d.cmd.Exec("npm", "i", "-g", "gltf-pipeline@4.1.0"); err != nil {
+	if err := d.cmd.Exec(context.Background(), "npm", "i", "-g", "gltf-pipeline@4.1.0"); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "gltf-pipeline")
 		return
@@ -96,7 +97,7 @@ func (d *Installer) installBlender() {
 		"python3-numpy",
 	}
 	args := append([]string{"install", "-y"}, packages...)
-	if err := d.cmd.Exec("apt-get", args...); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", args...); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "blender")
 		return
@@ -116,7 +117,7 @@ func (d *Installer) installLibreOffice() {
 		"libreoffice-math",
 	}
 	args := append([]string{"install", "-y"}, packages...)
-	if err := d.cmd.Exec("apt-get", args...); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", args...); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "libreoffice")
 		return
@@ -147,7 +148,7 @@ func (d *Installer) installTesseract() {
 		"tesseract-ocr-rus",
 	}
 	args := append([]string{"install", "-y"}, packages...)
-	if err := d.cmd.Exec("apt-get", args...); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", args...); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "tesseract")
 		return
@@ -696,7 +697,7 @@ func (d *Installer) installFonts() {
 		"fonts-yusei-magic",
 	}
 	args := append([]string{"install", "-y"}, packages...)
-	if err := d.cmd.Exec("apt-get", args...); err != nil {
+	if err := d.cmd.Exec(context.Background(), "apt-get", args...); err != nil {
 		logger.GetLogger().Error(err)
 		logger.GetLogger().Named(logger.StrInstaller).Infow("❌️  failed", "package", "fonts")
 		return
@@ -0,0 +1,390 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+
+	"github.com/kouprlabs/voltaserve/conversion/logger"
+)
+
+const (
+	queueStreamPrefix = "conversion:pipelines:ready:"
+	queueIndex        = "conversion:pipelines:streams"
+	queueGroup        = "conversion"
+	queueDelayed      = "conversion:pipelines:delayed"
+	queueDeadLetter   = "conversion:pipelines:dead_letter"
+	queueBatchSize    = 100
+	queueStreamsTTL   = 1 * time.Second
+	queueNone         = "_"
+	// queueCancelled marks the tasks whose job must not run, for a day, which
+	// is long enough for any job of the task to be drained
+	queueCancelled       = "conversion:pipelines:cancelled:"
+	queueCancelledExpiry = 24 * time.Hour
+	queueCancelChannel   = "conversion:pipelines:cancel"
+)
+
+const (
+	jobFieldOptions = "options"
+	jobFieldAttempt = "attempt"
+	jobFieldError   = "error"
+)
+
+// PipelineQueue is a durable queue of pipeline runs backed by Redis streams
+// and consumer groups, so that it survives restarts and is shared by every
+// conversion replica. Delivery is at-least-once: a job that is not acked
+// within the visibility timeout, because its worker died, is delivered again.
+// Failed jobs are retried with an exponential backoff, then moved to the
+// dead-letter stream once they run out of attempts.
+//
+// Jobs are split in one stream per priority, pipeline and workspace, which
+// lets the scheduler decide what to run next. The streams that may hold jobs
+// are tracked in an index, so that idle workspaces cost nothing.
+type PipelineQueue struct {
+	redis             *infra.RedisManager
+	consumer          string
+	visibilityTimeout time.Duration
+	maxAttempts       int
+	retryBackoff      time.Duration
+	groups            sync.Map
+	streams           []PipelineStream
+	streamsTime       time.Time
+	streamsMu         sync.Mutex
+}
+
+type PipelineQueueOptions struct {
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	RetryBackoff      time.Duration
+}
+
+type PipelineStream struct {
+	Key         string
+	Priority    string
+	Pipeline    string
+	WorkspaceID string
+}
+
+type PipelineJob struct {
+	ID      string
+	Stream  PipelineStream
+	Options dto.PipelineRunOptions
+	Attempt int
+}
+
+type delayedJob struct {
+	Options  dto.PipelineRunOptions `json:"options"`
+	Pipeline string                 `json:"pipeline"`
+	Attempt  int                    `json:"attempt"`
+	// Nonce keeps identical jobs apart in the sorted set
+	Nonce string `json:"nonce"`
+}
+
+func NewPipelineQueue(redisConfig config.RedisConfig, opts PipelineQueueOptions) *PipelineQueue {
+	hostname, _ := os.Hostname()
+	return &PipelineQueue{
+		redis:             infra.NewRedisManager(redisConfig),
+		consumer:          hostname + "-" + helper.NewID(),
+		visibilityTimeout: opts.VisibilityTimeout,
+		maxAttempts:       opts.MaxAttempts,
+		retryBackoff:      opts.RetryBackoff,
+	}
+}
+
+// Push enqueues the job in the stream of its priority, pipeline and workspace.
+func (q *PipelineQueue) Push(opts dto.PipelineRunOptions, pipeline string) error {
+	return q.push(q.streamOf(opts, pipeline), opts, 1)
+}
+
+// Streams returns the streams that may hold jobs, the list is cached for a
+// short while since every worker asks for it each time it looks for a job.
+func (q *PipelineQueue) Streams() ([]PipelineStream, error) {
+	q.streamsMu.Lock()
+	defer q.streamsMu.Unlock()
+	if q.streams != nil && time.Since(q.streamsTime) < queueStreamsTTL {
+		return q.streams, nil
+	}
+	keys, err := q.redis.ZRangeByMaxScore(queueIndex, math.Inf(1), 0)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]PipelineStream, 0, len(keys))
+	for _, key := range keys {
+		stream, ok := q.parseStream(key)
+		if !ok {
+			continue
+		}
+		res = append(res, stream)
+	}
+	q.streams = res
+	q.streamsTime = time.Now()
+	return res, nil
+}
+
+// Read returns the next job of the stream without blocking, or nil if there
+// is none, in which case the stream is dropped from the index if it is empty.
+func (q *PipelineQueue) Read(stream PipelineStream) (*PipelineJob, error) {
+	messages, err := q.redis.XReadGroup(stream.Key, queueGroup, q.consumer, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, q.unindex(stream)
+	}
+	return q.parse(stream, messages[0]), nil
+}
+
+// Reclaim takes over the jobs whose worker stopped extending them.
+func (q *PipelineQueue) Reclaim() ([]*PipelineJob, error) {
+	streams, err := q.Streams()
+	if err != nil {
+		return nil, err
+	}
+	var res []*PipelineJob
+	for _, stream := range streams {
+		messages, err := q.redis.XAutoClaim(stream.Key, queueGroup, q.consumer, q.visibilityTimeout, queueBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			res = append(res, q.parse(stream, m))
+		}
+	}
+	return res, nil
+}
+
+// Extend resets the visibility timeout of the job, it must be called
+// periodically while the job is being processed.
+func (q *PipelineQueue) Extend(job *PipelineJob) error {
+	return q.redis.XClaimJustID(job.Stream.Key, queueGroup, q.consumer, job.ID)
+}
+
+func (q *PipelineQueue) Ack(job *PipelineJob) error {
+	return q.redis.XAckDel(job.Stream.Key, queueGroup, job.ID)
+}
+
+// Retry schedules the job again after a backoff, or moves it to the dead-letter
+// stream if it ran out of attempts, in which case it returns true.
+func (q *PipelineQueue) Retry(job *PipelineJob, cause error) (bool, error) {
+	if job.Attempt >= q.maxAttempts {
+		if err := q.deadLetter(job, cause); err != nil {
+			return false, err
+		}
+		return true, q.Ack(job)
+	}
+	b, err := json.Marshal(delayedJob{
+		Options:  job.Options,
+		Pipeline: job.Stream.Pipeline,
+		Attempt:  job.Attempt + 1,
+		Nonce:    helper.NewID(),
+	})
+	if err != nil {
+		return false, err
+	}
+	backoff := q.retryBackoff * time.Duration(1<<(job.Attempt-1))
+	if err := q.redis.ZAdd(queueDelayed, float64(time.Now().Add(backoff).UnixMilli()), string(b)); err != nil {
+		return false, err
+	}
+	return false, q.Ack(job)
+}
+
+// Promote moves the delayed jobs that are due back to their stream. Replicas
+// can promote concurrently, only the one that removes a job re-adds it.
+func (q *PipelineQueue) Promote() error {
+	members, err := q.redis.ZRangeByMaxScore(queueDelayed, float64(time.Now().UnixMilli()), queueBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		removed, err := q.redis.ZRem(queueDelayed, m)
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		job := delayedJob{}
+		if err := json.Unmarshal([]byte(m), &job); err != nil {
+			logger.GetLogger().Error(err)
+			continue
+		}
+		if err := q.push(q.streamOf(job.Options, job.Pipeline), job.Options, job.Attempt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of jobs that are waiting, being processed or delayed.
+func (q *PipelineQueue) Len() (int64, error) {
+	streams, err := q.Streams()
+	if err != nil {
+		return 0, err
+	}
+	var res int64
+	for _, stream := range streams {
+		n, err := q.redis.XLen(stream.Key)
+		if err != nil {
+			return 0, err
+		}
+		res += n
+	}
+	delayed, err := q.redis.ZCard(queueDelayed)
+	if err != nil {
+		return 0, err
+	}
+	return res + delayed, nil
+}
+
+// Cancel marks the jobs of the task as cancelled, so that they are dropped
+// when read, and notifies every replica in case one of them is running it.
+func (q *PipelineQueue) Cancel(taskID string) error {
+	if err := q.redis.SetWithExpiry(queueCancelled+taskID, "1", queueCancelledExpiry); err != nil {
+		return err
+	}
+	return q.redis.Publish(queueCancelChannel, taskID)
+}
+
+func (q *PipelineQueue) IsCancelled(taskID string) (bool, error) {
+	if _, err := q.redis.Get(queueCancelled + taskID); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SubscribeCancellations receives the IDs of the cancelled tasks.
+func (q *PipelineQueue) SubscribeCancellations() (*redis.PubSub, error) {
+	return q.redis.Subscribe(queueCancelChannel)
+}
+
+func (q *PipelineQueue) push(stream PipelineStream, opts dto.PipelineRunOptions, attempt int) error {
+	if _, ok := q.groups.Load(stream.Key); !ok {
+		if err := q.redis.XGroupCreate(stream.Key, queueGroup); err != nil {
+			return err
+		}
+		q.groups.Store(stream.Key, true)
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := q.redis.XAdd(stream.Key, map[string]interface{}{
+		jobFieldOptions: string(b),
+		jobFieldAttempt: attempt,
+	}); err != nil {
+		return err
+	}
+	/* The index is updated after the job is added, see unindex() */
+	return q.redis.ZAdd(queueIndex, float64(time.Now().UnixMilli()), stream.Key)
+}
+
+func (q *PipelineQueue) deadLetter(job *PipelineJob, cause error) error {
+	b, err := json.Marshal(job.Options)
+	if err != nil {
+		return err
+	}
+	if _, err := q.redis.XAdd(queueDeadLetter, map[string]interface{}{
+		jobFieldOptions: string(b),
+		jobFieldAttempt: job.Attempt,
+		jobFieldError:   cause.Error(),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// unindex drops an empty stream from the index. Since push() adds the job
+// before indexing the stream, checking the length again after the removal
+// catches a job pushed in between, and the stream is indexed back.
+func (q *PipelineQueue) unindex(stream PipelineStream) error {
+	n, err := q.redis.XLen(stream.Key)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := q.redis.ZRem(queueIndex, stream.Key); err != nil {
+		return err
+	}
+	n, err = q.redis.XLen(stream.Key)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return q.redis.ZAdd(queueIndex, float64(time.Now().UnixMilli()), stream.Key)
+	}
+	return nil
+}
+
+func (q *PipelineQueue) streamOf(opts dto.PipelineRunOptions, pipeline string) PipelineStream {
+	res := PipelineStream{
+		Priority:    dto.PipelinePriorityInteractive,
+		Pipeline:    pipeline,
+		WorkspaceID: queueNone,
+	}
+	if opts.Priority != nil {
+		res.Priority = *opts.Priority
+	}
+	if res.Pipeline == "" {
+		res.Pipeline = queueNone
+	}
+	if opts.WorkspaceID != nil {
+		res.WorkspaceID = *opts.WorkspaceID
+	}
+	res.Key = queueStreamPrefix + res.Priority + ":" + res.Pipeline + ":" + res.WorkspaceID
+	return res
+}
+
+func (q *PipelineQueue) parseStream(key string) (PipelineStream, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, queueStreamPrefix), ":")
+	if len(parts) != 3 {
+		return PipelineStream{}, false
+	}
+	return PipelineStream{
+		Key:         key,
+		Priority:    parts[0],
+		Pipeline:    parts[1],
+		WorkspaceID: parts[2],
+	}, true
+}
+
+func (q *PipelineQueue) parse(stream PipelineStream, message redis.XMessage) *PipelineJob {
+	res := &PipelineJob{ID: message.ID, Stream: stream, Attempt: 1}
+	if v, ok := message.Values[jobFieldOptions].(string); ok {
+		if err := json.Unmarshal([]byte(v), &res.Options); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	if v, ok := message.Values[jobFieldAttempt].(string); ok {
+		if attempt, err := strconv.Atoi(v); err == nil {
+			res.Attempt = attempt
+		}
+	}
+	return res
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+)
+
+const (
+	testVisibilityTimeout = 100 * time.Millisecond
+	testRetryBackoff      = 10 * time.Millisecond
+	testMaxAttempts       = 2
+)
+
+type PipelineQueueSuite struct {
+	suite.Suite
+	miniredis *miniredis.Miniredis
+}
+
+func TestPipelineQueueSuite(t *testing.T) {
+	suite.Run(t, new(PipelineQueueSuite))
+}
+
+func (s *PipelineQueueSuite) SetupTest() {
+	var err error
+	s.miniredis, err = miniredis.Run()
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+}
+
+func (s *PipelineQueueSuite) TearDownTest() {
+	s.miniredis.Close()
+}
+
+func (s *PipelineQueueSuite) TestReclaim_Unacked() {
+	q1 := s.newQueue()
+	q2 := s.newQueue()
+	s.Require().NoError(q1.Push(newTestRunOptions("workspace"), "pdf"))
+
+	job := s.readOne(q1)
+	s.Equal(1, job.Attempt)
+
+	jobs, err := q2.Reclaim()
+	s.Require().NoError(err)
+	s.Empty(jobs)
+
+	time.Sleep(2 * testVisibilityTimeout)
+
+	jobs, err = q2.Reclaim()
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 1)
+	s.Equal(job.ID, jobs[0].ID)
+	s.Equal(job.Options.SnapshotID, jobs[0].Options.SnapshotID)
+}
+
+func (s *PipelineQueueSuite) TestReclaim_Extended() {
+	q1 := s.newQueue()
+	q2 := s.newQueue()
+	s.Require().NoError(q1.Push(newTestRunOptions("workspace"), "pdf"))
+
+	job := s.readOne(q1)
+	for range 3 {
+		time.Sleep(testVisibilityTimeout / 2)
+		s.Require().NoError(q1.Extend(job))
+	}
+
+	jobs, err := q2.Reclaim()
+	s.Require().NoError(err)
+	s.Empty(jobs)
+}
+
+func (s *PipelineQueueSuite) TestReclaim_Acked() {
+	q1 := s.newQueue()
+	q2 := s.newQueue()
+	s.Require().NoError(q1.Push(newTestRunOptions("workspace"), "pdf"))
+
+	job := s.readOne(q1)
+	s.Require().NoError(q1.Ack(job))
+
+	time.Sleep(2 * testVisibilityTimeout)
+
+	jobs, err := q2.Reclaim()
+	s.Require().NoError(err)
+	s.Empty(jobs)
+	s.Equal(int64(0), s.len(q1))
+}
+
+func (s *PipelineQueueSuite) TestRetry_DeadLetter() {
+	q := s.newQueue()
+	s.Require().NoError(q.Push(newTestRunOptions("workspace"), "pdf"))
+
+	job := s.readOne(q)
+	s.Equal(1, job.Attempt)
+	dead, err := q.Retry(job, errors.New("failed"))
+	s.Require().NoError(err)
+	s.False(dead)
+	s.Equal(int64(1), s.len(q))
+
+	/* The job is delayed, so it can't be read before the backoff elapses */
+	s.Require().NoError(q.Promote())
+	s.Nil(s.read(q, job.Stream))
+
+	time.Sleep(2 * testRetryBackoff)
+	s.Require().NoError(q.Promote())
+
+	job = s.readOne(q)
+	s.Equal(2, job.Attempt)
+	dead, err = q.Retry(job, errors.New("failed"))
+	s.Require().NoError(err)
+	s.True(dead)
+
+	n, err := q.redis.XLen(queueDeadLetter)
+	s.Require().NoError(err)
+	s.Equal(int64(1), n)
+	s.Equal(int64(0), s.len(q))
+}
+
+func (s *PipelineQueueSuite) TestCancel() {
+	q := s.newQueue()
+
+	cancelled, err := q.IsCancelled("task")
+	s.Require().NoError(err)
+	s.False(cancelled)
+
+	s.Require().NoError(q.Cancel("task"))
+
+	cancelled, err = q.IsCancelled("task")
+	s.Require().NoError(err)
+	s.True(cancelled)
+
+	cancelled, err = q.IsCancelled("another-task")
+	s.Require().NoError(err)
+	s.False(cancelled)
+}
+
+func (s *PipelineQueueSuite) TestStreams_SplitByPriorityAndWorkspace() {
+	q := s.newQueue()
+	interactive := newTestRunOptions("workspace-a")
+	interactive.Priority = helper.ToPtr(dto.PipelinePriorityInteractive)
+	bulk := newTestRunOptions("workspace-a")
+	bulk.Priority = helper.ToPtr(dto.PipelinePriorityBulk)
+	s.Require().NoError(q.Push(interactive, "pdf"))
+	s.Require().NoError(q.Push(bulk, "pdf"))
+	s.Require().NoError(q.Push(newTestRunOptions("workspace-b"), "pdf"))
+
+	streams, err := q.Streams()
+	s.Require().NoError(err)
+	s.Require().Len(streams, 3)
+	s.ElementsMatch([]PipelineStream{
+		q.streamOf(interactive, "pdf"),
+		q.streamOf(bulk, "pdf"),
+		q.streamOf(newTestRunOptions("workspace-b"), "pdf"),
+	}, streams)
+}
+
+func (s *PipelineQueueSuite) newQueue() *PipelineQueue {
+	return NewPipelineQueue(config.RedisConfig{Address: s.miniredis.Addr()}, PipelineQueueOptions{
+		VisibilityTimeout: testVisibilityTimeout,
+		MaxAttempts:       testMaxAttempts,
+		RetryBackoff:      testRetryBackoff,
+	})
+}
+
+func (s *PipelineQueueSuite) readOne(q *PipelineQueue) *PipelineJob {
+	streams, err := q.Streams()
+	s.Require().NoError(err)
+	s.Require().Len(streams, 1)
+	job := s.read(q, streams[0])
+	s.Require().NotNil(job)
+	return job
+}
+
+func (s *PipelineQueueSuite) read(q *PipelineQueue, stream PipelineStream) *PipelineJob {
+	job, err := q.Read(stream)
+	s.Require().NoError(err)
+	return job
+}
+
+func (s *PipelineQueueSuite) len(q *PipelineQueue) int64 {
+	n, err := q.Len()
+	s.Require().NoError(err)
+	return n
+}
+
+func newTestRunOptions(workspaceID string) dto.PipelineRunOptions {
+	return dto.PipelineRunOptions{
+		TaskID:      helper.ToPtr(helper.NewID()),
+		SnapshotID:  helper.NewID(),
+		WorkspaceID: helper.ToPtr(workspaceID),
+		Bucket:      "bucket",
+		Key:         "key",
+	}
+}
@@ -11,24 +11,57 @@
 package runtime
 
 import (
+	"context"
+	"errors"
 	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kouprlabs/voltaserve/shared/dto"
 
+	"github.com/kouprlabs/voltaserve/conversion/infra"
 	"github.com/kouprlabs/voltaserve/conversion/logger"
 	"github.com/kouprlabs/voltaserve/conversion/pipeline"
 )
 
+const (
+	schedulerIdleSleep      = 500 * time.Millisecond
+	schedulerErrorSleep     = 5 * time.Second
+	schedulerPromotePeriod  = 1 * time.Second
+	schedulerInstallerSleep = 500 * time.Millisecond
+)
+
+// schedulerPriorities are ordered from the most to the least urgent.
+var schedulerPriorities = []string{dto.PipelinePriorityInteractive, dto.PipelinePriorityBulk}
+
+var errJobAbandoned = errors.New("job abandoned by its worker")
+
 type Scheduler struct {
-	pipelineQueue       [][]dto.PipelineRunOptions
+	queue               *PipelineQueue
 	pipelineWorkerCount int
-	activePipelineCount int
-	installer           *Installer
+	activePipelineCount atomic.Int32
+	// pipelineSlots holds a semaphore for each pipeline with a concurrency cap
+	pipelineSlots   map[string]chan struct{}
+	priorityWeights map[string]int
+	priorityTurn    atomic.Uint64
+	// workspaceServed tells when a job of each workspace was last picked, the
+	// workspace that waited the longest is served first
+	workspaceServed   map[string]time.Time
+	workspaceServedMu sync.Mutex
+	// running holds the cancel function of the job of each running task
+	running    map[string]context.CancelFunc
+	runningMu  sync.Mutex
+	dispatcher *pipeline.Dispatcher
+	installer  *Installer
 }
 
 type SchedulerOptions struct {
 	PipelineWorkerCount int
+	PipelineConcurrency map[string]int
+	PriorityWeights     map[string]int
+	Queue               *PipelineQueue
 	Installer           *Installer
 }
 
@@ -43,9 +76,20 @@ func NewDefaultSchedulerOptions() SchedulerOptions {
 }
 
 func NewScheduler(opts SchedulerOptions) *Scheduler {
+	slots := make(map[string]chan struct{})
+	for id, limit := range opts.PipelineConcurrency {
+		if limit > 0 {
+			slots[id] = make(chan struct{}, limit)
+		}
+	}
 	return &Scheduler{
-		pipelineQueue:       make([][]dto.PipelineRunOptions, opts.PipelineWorkerCount),
+		queue:               opts.Queue,
 		pipelineWorkerCount: opts.PipelineWorkerCount,
+		pipelineSlots:       slots,
+		priorityWeights:     opts.PriorityWeights,
+		workspaceServed:     make(map[string]time.Time),
+		running:             make(map[string]context.CancelFunc),
+		dispatcher:          pipeline.NewDispatcher(),
 		installer:           opts.Installer,
 	}
 }
@@ -55,63 +99,321 @@ func (s *Scheduler) Start() {
 	for i := 0; i < s.pipelineWorkerCount; i++ {
 		go s.pipelineWorker(i)
 	}
+	go s.pipelinePromoter()
+	go s.pipelineReclaimer()
+	go s.pipelineCanceller()
 	go s.pipelineQueueStatus()
 	go s.pipelineWorkerStatus()
 }
 
-func (s *Scheduler) SchedulePipeline(opts *dto.PipelineRunOptions) {
-	index := s.choosePipeline()
-	logger.GetLogger().Named(logger.StrScheduler).Infow("👉  choosing", "pipeline", index)
-	s.pipelineQueue[index] = append(s.pipelineQueue[index], *opts)
+func (s *Scheduler) SchedulePipeline(opts *dto.PipelineRunOptions) error {
+	return s.queue.Push(*opts, s.dispatcher.Identify(*opts))
 }
 
-// Choose the pipeline with the least number of items in the queue.
-func (s *Scheduler) choosePipeline() int {
-	index := 0
-	length := len(s.pipelineQueue[0])
-	for i := 0; i < s.pipelineWorkerCount; i++ {
-		if len(s.pipelineQueue[i]) < length {
-			index = i
-			length = len(s.pipelineQueue[i])
-		}
-	}
-	return index
+// CancelPipeline stops the job of the task, whether it is waiting in the
+// queue or running on any replica.
+func (s *Scheduler) CancelPipeline(taskID string) error {
+	return s.queue.Cancel(taskID)
 }
 
 func (s *Scheduler) pipelineWorker(index int) {
 	dispatcher := pipeline.NewDispatcher()
-	s.pipelineQueue[index] = make([]dto.PipelineRunOptions, 0)
 	logger.GetLogger().Named(logger.StrPipeline).Infow("⚙️  running", "worker", index)
 	for {
-		if len(s.pipelineQueue[index]) > 0 && !s.installer.IsRunning() {
-			s.activePipelineCount++
-			opts := s.pipelineQueue[index][0]
-			logger.GetLogger().Named(logger.StrPipeline).Infow("🔨  working", "worker", index, "bucket", opts.Bucket, "key", opts.Key)
-			start := time.Now()
-			err := dispatcher.Dispatch(opts)
-			elapsed := time.Since(start)
-			if err == nil {
-				logger.GetLogger().Named(logger.StrPipeline).
-					Infow("🎉  succeeded", "worker", index, "elapsed", elapsed, "bucket", opts.Bucket, "key", opts.Key)
-			} else {
-				logger.GetLogger().Named(logger.StrPipeline).
-					Errorw("⛈️  failed", "worker", index, "elapsed", elapsed, "bucket", opts.Bucket, "key", opts.Key, "error", err.Error())
+		if s.installer.IsRunning() {
+			time.Sleep(schedulerInstallerSleep)
+			continue
+		}
+		job, release, err := s.next()
+		if err != nil {
+			logger.GetLogger().Error(err)
+			time.Sleep(schedulerErrorSleep)
+			continue
+		}
+		if job == nil {
+			time.Sleep(schedulerIdleSleep)
+			continue
+		}
+		s.run(dispatcher, index, job)
+		release()
+	}
+}
+
+// next picks the job to run: priorities are tried in the order given by
+// their weights, pipelines that reached their cap are skipped, and among
+// the remaining streams the workspace that waited the longest comes first.
+// The returned function releases the pipeline slot taken by the job.
+func (s *Scheduler) next() (*PipelineJob, func(), error) {
+	streams, err := s.queue.Streams()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, priority := range s.nextPriorities() {
+		var candidates []PipelineStream
+		for _, stream := range streams {
+			if stream.Priority == priority {
+				candidates = append(candidates, stream)
+			}
+		}
+		s.sortByWorkspaceServed(candidates)
+		for _, stream := range candidates {
+			release, ok := s.acquire(stream.Pipeline)
+			if !ok {
+				continue
+			}
+			job, err := s.queue.Read(stream)
+			if err != nil {
+				release()
+				return nil, nil, err
+			}
+			if job != nil {
+				s.markWorkspaceServed(stream.WorkspaceID)
+				return job, release, nil
+			}
+			release()
+		}
+	}
+	return nil, nil, nil
+}
+
+func (s *Scheduler) run(dispatcher *pipeline.Dispatcher, index int, job *PipelineJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	/* Registered before checking, so that a cancellation in between is not missed */
+	s.register(job, cancel)
+	defer s.unregister(job)
+	if s.drop(job) {
+		return
+	}
+	s.activePipelineCount.Add(1)
+	defer s.activePipelineCount.Add(-1)
+	logger.GetLogger().Named(logger.StrPipeline).
+		Infow("🔨  working", "worker", index, "pipeline", job.Stream.Pipeline, "priority", job.Stream.Priority, "attempt", job.Attempt, "bucket", job.Options.Bucket, "key", job.Options.Key)
+	start := time.Now()
+	stop := s.extendWhileRunning(job)
+	err := dispatcher.Dispatch(ctx, job.Options)
+	close(stop)
+	elapsed := time.Since(start)
+	if err == nil {
+		logger.GetLogger().Named(logger.StrPipeline).
+			Infow("🎉  succeeded", "worker", index, "elapsed", elapsed, "bucket", job.Options.Bucket, "key", job.Options.Key)
+		if err := s.queue.Ack(job); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	} else if errors.Is(err, context.Canceled) {
+		logger.GetLogger().Named(logger.StrPipeline).
+			Infow("🚫  cancelled", "worker", index, "elapsed", elapsed, "bucket", job.Options.Bucket, "key", job.Options.Key)
+		if err := s.queue.Ack(job); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	} else if errors.Is(err, infra.ErrCommandTimedOut) {
+		/* The machine might have been busy, the attempts are bounded, so a corrupt input still ends up dead-lettered */
+		logger.GetLogger().Named(logger.StrPipeline).
+			Errorw("⏰  timed out", "worker", index, "elapsed", elapsed, "bucket", job.Options.Bucket, "key", job.Options.Key)
+		s.retry(dispatcher, job, err)
+	} else {
+		logger.GetLogger().Named(logger.StrPipeline).
+			Errorw("⛈️  failed", "worker", index, "elapsed", elapsed, "bucket", job.Options.Bucket, "key", job.Options.Key, "error", err.Error())
+		s.retry(dispatcher, job, err)
+	}
+}
+
+// drop acks the job without running it if its task was cancelled.
+func (s *Scheduler) drop(job *PipelineJob) bool {
+	if job.Options.TaskID == nil {
+		return false
+	}
+	cancelled, err := s.queue.IsCancelled(*job.Options.TaskID)
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return false
+	}
+	if !cancelled {
+		return false
+	}
+	logger.GetLogger().Named(logger.StrPipeline).
+		Infow("🚫  dropped", "bucket", job.Options.Bucket, "key", job.Options.Key)
+	if err := s.queue.Ack(job); err != nil {
+		logger.GetLogger().Error(err)
+	}
+	return true
+}
+
+func (s *Scheduler) register(job *PipelineJob, cancel context.CancelFunc) {
+	if job.Options.TaskID == nil {
+		return
+	}
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	s.running[*job.Options.TaskID] = cancel
+}
+
+func (s *Scheduler) unregister(job *PipelineJob) {
+	if job.Options.TaskID == nil {
+		return
+	}
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	delete(s.running, *job.Options.TaskID)
+}
+
+// nextPriorities returns the priorities in the order they should be tried,
+// the first one is picked in a weighted round-robin fashion.
+func (s *Scheduler) nextPriorities() []string {
+	total := 0
+	for _, priority := range schedulerPriorities {
+		total += s.priorityWeights[priority]
+	}
+	if total == 0 {
+		return schedulerPriorities
+	}
+	turn := int(s.priorityTurn.Add(1) % uint64(total))
+	first := schedulerPriorities[0]
+	for _, priority := range schedulerPriorities {
+		if turn < s.priorityWeights[priority] {
+			first = priority
+			break
+		}
+		turn -= s.priorityWeights[priority]
+	}
+	res := []string{first}
+	for _, priority := range schedulerPriorities {
+		if priority != first {
+			res = append(res, priority)
+		}
+	}
+	return res
+}
+
+func (s *Scheduler) acquire(pipelineID string) (func(), bool) {
+	slots, ok := s.pipelineSlots[pipelineID]
+	if !ok {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+func (s *Scheduler) sortByWorkspaceServed(streams []PipelineStream) {
+	s.workspaceServedMu.Lock()
+	defer s.workspaceServedMu.Unlock()
+	sort.SliceStable(streams, func(i, j int) bool {
+		return s.workspaceServed[streams[i].WorkspaceID].Before(s.workspaceServed[streams[j].WorkspaceID])
+	})
+}
+
+func (s *Scheduler) markWorkspaceServed(workspaceID string) {
+	s.workspaceServedMu.Lock()
+	defer s.workspaceServedMu.Unlock()
+	s.workspaceServed[workspaceID] = time.Now()
+}
+
+// extendWhileRunning keeps the job invisible to other workers until the
+// returned channel is closed.
+func (s *Scheduler) extendWhileRunning(job *PipelineJob) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.queue.visibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.queue.Extend(job); err != nil {
+					logger.GetLogger().Error(err)
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+func (s *Scheduler) retry(dispatcher *pipeline.Dispatcher, job *PipelineJob, cause error) {
+	if s.drop(job) {
+		return
+	}
+	dead, err := s.queue.Retry(job, cause)
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	if dead {
+		logger.GetLogger().Named(logger.StrPipeline).
+			Errorw("🪦  dead-lettered", "attempt", job.Attempt, "bucket", job.Options.Bucket, "key", job.Options.Key)
+		if err := dispatcher.Fail(job.Options, cause); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	} else {
+		if err := dispatcher.Defer(job.Options); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+}
+
+func (s *Scheduler) pipelinePromoter() {
+	for {
+		time.Sleep(schedulerPromotePeriod)
+		if err := s.queue.Promote(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+}
+
+// pipelineReclaimer retries the jobs abandoned by a dead worker, rather than
+// running them right away, so that a job which keeps crashing its worker
+// ends up in the dead-letter stream.
+func (s *Scheduler) pipelineReclaimer() {
+	dispatcher := pipeline.NewDispatcher()
+	for {
+		time.Sleep(s.queue.visibilityTimeout / 3)
+		jobs, err := s.queue.Reclaim()
+		if err != nil {
+			logger.GetLogger().Error(err)
+			continue
+		}
+		for _, job := range jobs {
+			logger.GetLogger().Named(logger.StrPipeline).
+				Warnw("♻️  reclaimed", "attempt", job.Attempt, "bucket", job.Options.Bucket, "key", job.Options.Key)
+			s.retry(dispatcher, job, errJobAbandoned)
+		}
+	}
+}
+
+// pipelineCanceller kills the running jobs of the tasks cancelled through
+// any replica.
+func (s *Scheduler) pipelineCanceller() {
+	for {
+		sub, err := s.queue.SubscribeCancellations()
+		if err != nil {
+			logger.GetLogger().Error(err)
+			time.Sleep(schedulerErrorSleep)
+			continue
+		}
+		for msg := range sub.Channel() {
+			s.runningMu.Lock()
+			cancel, ok := s.running[msg.Payload]
+			s.runningMu.Unlock()
+			if ok {
+				cancel()
 			}
-			s.pipelineQueue[index] = s.pipelineQueue[index][1:]
-			s.activePipelineCount--
-		} else {
-			time.Sleep(500 * time.Millisecond)
 		}
+		_ = sub.Close()
 	}
 }
 
 func (s *Scheduler) pipelineQueueStatus() {
-	previous := -1
+	previous := int64(-1)
 	for {
 		time.Sleep(5 * time.Second)
-		sum := 0
-		for i := 0; i < s.pipelineWorkerCount; i++ {
-			sum += len(s.pipelineQueue[i])
+		sum, err := s.queue.Len()
+		if err != nil {
+			logger.GetLogger().Error(err)
+			continue
 		}
 		if sum != previous {
 			if sum == 0 {
@@ -125,17 +427,18 @@ func (s *Scheduler) pipelineQueueStatus() {
 }
 
 func (s *Scheduler) pipelineWorkerStatus() {
-	previous := -1
+	previous := int32(-1)
 	for {
 		time.Sleep(3 * time.Second)
-		if previous != s.activePipelineCount {
-			if s.activePipelineCount == 0 {
+		active := s.activePipelineCount.Load()
+		if previous != active {
+			if active == 0 {
 				logger.GetLogger().Named(logger.StrWorkerStatus).Infow("🌤️  all idle", "type", "pipeline")
 			} else {
 				logger.GetLogger().Named(logger.StrWorkerStatus).
-					Infow("🔥  active", "type", "pipeline", "count", s.activePipelineCount)
+					Infow("🔥  active", "type", "pipeline", "count", active)
 			}
 		}
-		previous = s.activePipelineCount
+		previous = active
 	}
 }
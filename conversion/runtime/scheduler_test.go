@@ -0,0 +1,170 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+
+	"github.com/kouprlabs/voltaserve/conversion/infra"
+)
+
+type SchedulerSuite struct {
+	suite.Suite
+	miniredis *miniredis.Miniredis
+	queue     *PipelineQueue
+}
+
+func TestSchedulerSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerSuite))
+}
+
+func (s *SchedulerSuite) SetupTest() {
+	var err error
+	s.miniredis, err = miniredis.Run()
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.queue = NewPipelineQueue(config.RedisConfig{Address: s.miniredis.Addr()}, PipelineQueueOptions{
+		VisibilityTimeout: testVisibilityTimeout,
+		MaxAttempts:       testMaxAttempts,
+		RetryBackoff:      testRetryBackoff,
+	})
+}
+
+func (s *SchedulerSuite) TearDownTest() {
+	s.miniredis.Close()
+}
+
+func (s *SchedulerSuite) TestCancel_KillsCommand() {
+	s.T().Setenv("LIMITS_EXTERNAL_COMMAND_TIMEOUT_SECONDS", "60")
+
+	scheduler := NewScheduler(SchedulerOptions{Queue: s.queue})
+	go scheduler.pipelineCanceller()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job := &PipelineJob{Options: newTestRunOptions("workspace")}
+	scheduler.register(job, cancel)
+	defer scheduler.unregister(job)
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- infra.NewCommand().Exec(ctx, "sleep", "60")
+	}()
+
+	// The canceller subscribes in the background, so the cancellation is
+	// published until the command exits, in case the first one was missed.
+	var err error
+	s.Eventually(func() bool {
+		s.Require().NoError(s.queue.Cancel(*job.Options.TaskID))
+		select {
+		case err = <-done:
+			return true
+		default:
+			return false
+		}
+	}, 10*time.Second, 100*time.Millisecond)
+	s.ErrorIs(err, context.Canceled)
+	s.Less(time.Since(start), 10*time.Second)
+}
+
+func (s *SchedulerSuite) TestDrop_Cancelled() {
+	scheduler := NewScheduler(SchedulerOptions{Queue: s.queue})
+	opts := newTestRunOptions("workspace")
+	s.Require().NoError(s.queue.Push(opts, "pdf"))
+
+	job, release, err := scheduler.next()
+	s.Require().NoError(err)
+	s.Require().NotNil(job)
+	release()
+	s.False(scheduler.drop(job))
+
+	s.Require().NoError(s.queue.Cancel(*opts.TaskID))
+	s.True(scheduler.drop(job))
+
+	n, err := s.queue.Len()
+	s.Require().NoError(err)
+	s.Equal(int64(0), n)
+}
+
+func (s *SchedulerSuite) TestNextPriorities_Weighted() {
+	scheduler := NewScheduler(SchedulerOptions{
+		Queue: s.queue,
+		PriorityWeights: map[string]int{
+			dto.PipelinePriorityInteractive: 2,
+			dto.PipelinePriorityBulk:        1,
+		},
+	})
+	counts := make(map[string]int)
+	for range 30 {
+		priorities := scheduler.nextPriorities()
+		s.Require().Len(priorities, 2)
+		counts[priorities[0]]++
+	}
+	s.Equal(20, counts[dto.PipelinePriorityInteractive])
+	s.Equal(10, counts[dto.PipelinePriorityBulk])
+}
+
+func (s *SchedulerSuite) TestNext_WorkspaceFairness() {
+	scheduler := NewScheduler(SchedulerOptions{Queue: s.queue})
+	for range 3 {
+		s.Require().NoError(s.queue.Push(newTestRunOptions("workspace-a"), "pdf"))
+	}
+	s.Require().NoError(s.queue.Push(newTestRunOptions("workspace-b"), "pdf"))
+
+	var workspaces []string
+	for range 4 {
+		job, release, err := scheduler.next()
+		s.Require().NoError(err)
+		s.Require().NotNil(job)
+		release()
+		workspaces = append(workspaces, job.Stream.WorkspaceID)
+		/* Served times must differ, since the workspace served first is the one that waited the longest */
+		time.Sleep(time.Millisecond)
+	}
+	s.Equal([]string{"workspace-a", "workspace-b", "workspace-a", "workspace-a"}, workspaces)
+}
+
+func (s *SchedulerSuite) TestNext_PipelineConcurrency() {
+	scheduler := NewScheduler(SchedulerOptions{
+		Queue:               s.queue,
+		PipelineConcurrency: map[string]int{"glb": 1},
+	})
+	for range 2 {
+		s.Require().NoError(s.queue.Push(newTestRunOptions("workspace"), "glb"))
+	}
+
+	job, release, err := scheduler.next()
+	s.Require().NoError(err)
+	s.Require().NotNil(job)
+
+	/* The only slot of the pipeline is taken */
+	other, _, err := scheduler.next()
+	s.Require().NoError(err)
+	s.Nil(other)
+
+	release()
+	other, release, err = scheduler.next()
+	s.Require().NoError(err)
+	s.Require().NotNil(other)
+	release()
+	s.NotEqual(job.ID, other.ID)
+}
@@ -46,6 +46,18 @@ mod m20250421_000004_create_murph_quota;
 mod m20250729_000001_add_workspace_image_column;
 mod m20250729_000002_add_group_image_column;
 mod m20250729_000003_add_organization_image_column;
+mod m20251020_000001_create_file_property;
+mod m20251021_000001_create_upload_session;
+mod m20251022_000001_add_snapshot_hls_column;
+mod m20251023_000001_add_snapshot_metadata_column;
+mod m20251024_000001_create_share_link;
+mod m20251025_000001_create_webhook_subscription;
+mod m20251026_000001_create_trash_item;
+mod m20251027_000001_add_snapshot_is_pinned_column;
+mod m20251027_000002_create_snapshot_retention_policy;
+mod m20251028_000001_add_snapshot_label_note_user_id_columns;
+mod m20251029_000001_create_snapshot_diff;
+mod m20251030_000001_create_snapshot_chunk;
 
 #[async_trait::async_trait]
 impl MigratorTrait for Migrator {
@@ -84,6 +96,18 @@ impl MigratorTrait for Migrator {
             Box::new(m20250729_000001_add_workspace_image_column::Migration),
             Box::new(m20250729_000002_add_group_image_column::Migration),
             Box::new(m20250729_000003_add_organization_image_column::Migration),
+            Box::new(m20251020_000001_create_file_property::Migration),
+            Box::new(m20251021_000001_create_upload_session::Migration),
+            Box::new(m20251022_000001_add_snapshot_hls_column::Migration),
+            Box::new(m20251023_000001_add_snapshot_metadata_column::Migration),
+            Box::new(m20251024_000001_create_share_link::Migration),
+            Box::new(m20251025_000001_create_webhook_subscription::Migration),
+            Box::new(m20251026_000001_create_trash_item::Migration),
+            Box::new(m20251027_000001_add_snapshot_is_pinned_column::Migration),
+            Box::new(m20251027_000002_create_snapshot_retention_policy::Migration),
+            Box::new(m20251028_000001_add_snapshot_label_note_user_id_columns::Migration),
+            Box::new(m20251029_000001_create_snapshot_diff::Migration),
+            Box::new(m20251030_000001_create_snapshot_chunk::Migration),
         ]
     }
 }
@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{File, FileProperty};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(FileProperty::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(FileProperty::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(FileProperty::FileId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(FileProperty::Table, FileProperty::FileId)
+                            .to(File::Table, File::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(FileProperty::Namespace)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(FileProperty::Name)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(FileProperty::Value).text())
+                    .col(
+                        ColumnDef::new(FileProperty::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(FileProperty::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("file_property_file_id_namespace_name_idx")
+                    .if_not_exists()
+                    .table(FileProperty::Table)
+                    .col(FileProperty::FileId)
+                    .col(FileProperty::Namespace)
+                    .col(FileProperty::Name)
+                    .unique()
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(FileProperty::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
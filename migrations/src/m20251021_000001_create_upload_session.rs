@@ -0,0 +1,182 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{UploadSession, UploadSessionPart};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(UploadSession::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(UploadSession::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::UserId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::WorkspaceId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(UploadSession::ParentId).text())
+                    .col(ColumnDef::new(UploadSession::FileId).text())
+                    .col(
+                        ColumnDef::new(UploadSession::Name)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::Size)
+                            .big_integer()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::ContentType)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::Bucket)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::Key)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::SnapshotId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::S3UploadId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::Status)
+                            .text()
+                            .not_null()
+                            .default("uploading"),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::ExpiryTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSession::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(UploadSession::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("upload_session_expiry_time_idx")
+                    .if_not_exists()
+                    .table(UploadSession::Table)
+                    .col(UploadSession::ExpiryTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_table(
+                Table::create()
+                    .table(UploadSessionPart::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(UploadSessionPart::SessionId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSessionPart::Number)
+                            .integer()
+                            .not_null(),
+                    )
+                    .primary_key(
+                        Index::create()
+                            .col(UploadSessionPart::SessionId)
+                            .col(UploadSessionPart::Number),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(UploadSessionPart::Table, UploadSessionPart::SessionId)
+                            .to(UploadSession::Table, UploadSession::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSessionPart::Size)
+                            .big_integer()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSessionPart::Etag)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(UploadSessionPart::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(UploadSessionPart::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(UploadSession::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{File, ShareLink, ShareLinkAccess};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(ShareLink::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(ShareLink::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLink::FileId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(ShareLink::Table, ShareLink::FileId)
+                            .to(File::Table, File::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLink::WorkspaceId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLink::UserId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLink::Token)
+                            .text()
+                            .not_null()
+                            .unique_key(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLink::Mode)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(ShareLink::PasswordHash).text())
+                    .col(ColumnDef::new(ShareLink::ExpiryTime).text())
+                    .col(ColumnDef::new(ShareLink::MaxDownloads).integer())
+                    .col(
+                        ColumnDef::new(ShareLink::DownloadCount)
+                            .integer()
+                            .not_null()
+                            .default(0),
+                    )
+                    .col(ColumnDef::new(ShareLink::RevokeTime).text())
+                    .col(
+                        ColumnDef::new(ShareLink::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(ShareLink::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("share_link_file_id_idx")
+                    .if_not_exists()
+                    .table(ShareLink::Table)
+                    .col(ShareLink::FileId)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_table(
+                Table::create()
+                    .table(ShareLinkAccess::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(ShareLinkAccess::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLinkAccess::ShareLinkId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(ShareLinkAccess::Table, ShareLinkAccess::ShareLinkId)
+                            .to(ShareLink::Table, ShareLink::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(ColumnDef::new(ShareLinkAccess::FileId).text())
+                    .col(
+                        ColumnDef::new(ShareLinkAccess::Action)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(ShareLinkAccess::Granted)
+                            .boolean()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(ShareLinkAccess::ErrorCode).text())
+                    .col(ColumnDef::new(ShareLinkAccess::Ip).text())
+                    .col(ColumnDef::new(ShareLinkAccess::UserAgent).text())
+                    .col(
+                        ColumnDef::new(ShareLinkAccess::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("share_link_access_share_link_id_create_time_idx")
+                    .if_not_exists()
+                    .table(ShareLinkAccess::Table)
+                    .col(ShareLinkAccess::ShareLinkId)
+                    .col(ShareLinkAccess::CreateTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(ShareLinkAccess::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(ShareLink::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
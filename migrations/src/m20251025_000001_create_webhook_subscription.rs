@@ -0,0 +1,201 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{WebhookDelivery, WebhookSubscription, Workspace};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(WebhookSubscription::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(WebhookSubscription::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::WorkspaceId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(WebhookSubscription::Table, WebhookSubscription::WorkspaceId)
+                            .to(Workspace::Table, Workspace::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::UserId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::Url)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::Secret)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::Events)
+                            .json_binary()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::IsActive)
+                            .boolean()
+                            .not_null()
+                            .default(true),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookSubscription::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(WebhookSubscription::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("webhook_subscription_workspace_id_idx")
+                    .if_not_exists()
+                    .table(WebhookSubscription::Table)
+                    .col(WebhookSubscription::WorkspaceId)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_table(
+                Table::create()
+                    .table(WebhookDelivery::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(WebhookDelivery::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::SubscriptionId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(WebhookDelivery::Table, WebhookDelivery::SubscriptionId)
+                            .to(WebhookSubscription::Table, WebhookSubscription::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::EventType)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::Payload)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::Status)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::Attempts)
+                            .integer()
+                            .not_null()
+                            .default(0),
+                    )
+                    .col(
+                        ColumnDef::new(WebhookDelivery::NextAttemptTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(WebhookDelivery::LastAttemptTime).text())
+                    .col(ColumnDef::new(WebhookDelivery::ResponseStatus).integer())
+                    .col(ColumnDef::new(WebhookDelivery::ResponseBody).text())
+                    .col(ColumnDef::new(WebhookDelivery::Error).text())
+                    .col(
+                        ColumnDef::new(WebhookDelivery::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(WebhookDelivery::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("webhook_delivery_status_next_attempt_time_idx")
+                    .if_not_exists()
+                    .table(WebhookDelivery::Table)
+                    .col(WebhookDelivery::Status)
+                    .col(WebhookDelivery::NextAttemptTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("webhook_delivery_subscription_id_create_time_idx")
+                    .if_not_exists()
+                    .table(WebhookDelivery::Table)
+                    .col(WebhookDelivery::SubscriptionId)
+                    .col(WebhookDelivery::CreateTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(WebhookDelivery::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(WebhookSubscription::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
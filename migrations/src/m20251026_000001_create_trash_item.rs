@@ -0,0 +1,116 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{File, TrashItem, Workspace};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(TrashItem::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(TrashItem::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(TrashItem::FileId)
+                            .text()
+                            .not_null()
+                            .unique_key(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(TrashItem::Table, TrashItem::FileId)
+                            .to(File::Table, File::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(TrashItem::WorkspaceId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(TrashItem::Table, TrashItem::WorkspaceId)
+                            .to(Workspace::Table, Workspace::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(TrashItem::UserId)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(TrashItem::OriginalParentId).text())
+                    .col(
+                        ColumnDef::new(TrashItem::OriginalPath)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(TrashItem::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("trash_item_workspace_id_create_time_idx")
+                    .if_not_exists()
+                    .table(TrashItem::Table)
+                    .col(TrashItem::WorkspaceId)
+                    .col(TrashItem::CreateTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("trash_item_create_time_idx")
+                    .if_not_exists()
+                    .table(TrashItem::Table)
+                    .col(TrashItem::CreateTime)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(TrashItem::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{SnapshotRetentionPolicy, Workspace};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(SnapshotRetentionPolicy::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(SnapshotRetentionPolicy::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotRetentionPolicy::WorkspaceId)
+                            .text()
+                            .not_null()
+                            .unique_key(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(SnapshotRetentionPolicy::Table, SnapshotRetentionPolicy::WorkspaceId)
+                            .to(Workspace::Table, Workspace::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::KeepLast).integer())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::KeepAllDays).integer())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::KeepDailyDays).integer())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::KeepWeeklyWeeks).integer())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::LastRunTime).text())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::LastDeletedCount).integer())
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::LastFreedBytes).big_integer())
+                    .col(
+                        ColumnDef::new(SnapshotRetentionPolicy::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(SnapshotRetentionPolicy::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(SnapshotRetentionPolicy::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
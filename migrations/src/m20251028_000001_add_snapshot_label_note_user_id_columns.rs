@@ -0,0 +1,54 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{Snapshot};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .alter_table(
+                Table::alter()
+                    .table(Snapshot::Table)
+                    .add_column(ColumnDef::new(Snapshot::Label).text())
+                    .add_column(ColumnDef::new(Snapshot::Note).text())
+                    .add_column(ColumnDef::new(Snapshot::UserId).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .alter_table(
+                Table::alter()
+                    .table(Snapshot::Table)
+                    .drop_column(Snapshot::Label)
+                    .drop_column(Snapshot::Note)
+                    .drop_column(Snapshot::UserId)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
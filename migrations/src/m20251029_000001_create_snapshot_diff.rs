@@ -0,0 +1,114 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{Snapshot, SnapshotDiff};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(SnapshotDiff::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(SnapshotDiff::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotDiff::BaseSnapshotId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(SnapshotDiff::Table, SnapshotDiff::BaseSnapshotId)
+                            .to(Snapshot::Table, Snapshot::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotDiff::TargetSnapshotId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(SnapshotDiff::Table, SnapshotDiff::TargetSnapshotId)
+                            .to(Snapshot::Table, Snapshot::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotDiff::Type)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(SnapshotDiff::Artifact).json_binary())
+                    .col(ColumnDef::new(SnapshotDiff::Score).double())
+                    .col(ColumnDef::new(SnapshotDiff::TaskId).text())
+                    .col(
+                        ColumnDef::new(SnapshotDiff::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(ColumnDef::new(SnapshotDiff::UpdateTime).text())
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("snapshot_diff_base_snapshot_id_target_snapshot_id_idx")
+                    .if_not_exists()
+                    .table(SnapshotDiff::Table)
+                    .col(SnapshotDiff::BaseSnapshotId)
+                    .col(SnapshotDiff::TargetSnapshotId)
+                    .unique()
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("snapshot_diff_target_snapshot_id_idx")
+                    .if_not_exists()
+                    .table(SnapshotDiff::Table)
+                    .col(SnapshotDiff::TargetSnapshotId)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(SnapshotDiff::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
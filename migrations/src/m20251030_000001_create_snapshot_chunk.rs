@@ -0,0 +1,113 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+use sea_orm_migration::prelude::*;
+
+use crate::models::v1::{Snapshot, SnapshotChunk};
+
+#[derive(DeriveMigrationName)]
+pub struct Migration;
+
+#[async_trait::async_trait]
+impl MigrationTrait for Migration {
+    async fn up(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .create_table(
+                Table::create()
+                    .table(SnapshotChunk::Table)
+                    .if_not_exists()
+                    .col(
+                        ColumnDef::new(SnapshotChunk::Id)
+                            .text()
+                            .primary_key(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::SnapshotId)
+                            .text()
+                            .not_null(),
+                    )
+                    .foreign_key(
+                        ForeignKey::create()
+                            .from(SnapshotChunk::Table, SnapshotChunk::SnapshotId)
+                            .to(Snapshot::Table, Snapshot::Id)
+                            .on_delete(ForeignKeyAction::Cascade),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::Ordinal)
+                            .integer()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::StartOffset)
+                            .integer()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::EndOffset)
+                            .integer()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::Text)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::Embedding)
+                            .binary()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::Model)
+                            .text()
+                            .not_null(),
+                    )
+                    .col(
+                        ColumnDef::new(SnapshotChunk::CreateTime)
+                            .text()
+                            .not_null(),
+                    )
+                    .to_owned(),
+            )
+            .await?;
+
+        manager
+            .create_index(
+                Index::create()
+                    .name("snapshot_chunk_snapshot_id_ordinal_idx")
+                    .if_not_exists()
+                    .table(SnapshotChunk::Table)
+                    .col(SnapshotChunk::SnapshotId)
+                    .col(SnapshotChunk::Ordinal)
+                    .unique()
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+
+    async fn down(
+        &self,
+        manager: &SchemaManager,
+    ) -> Result<(), DbErr> {
+        manager
+            .drop_table(
+                Table::drop()
+                    .table(SnapshotChunk::Table)
+                    .to_owned(),
+            )
+            .await?;
+
+        Ok(())
+    }
+}
@@ -19,8 +19,19 @@ mod action;
 mod run;
 mod storage_quota;
 mod murph_quota;
+mod file_property;
+mod upload_session;
+mod share_link;
+mod webhook_subscription;
+mod trash_item;
+mod snapshot_retention_policy;
+mod snapshot_diff;
+mod snapshot_chunk;
 
 pub use {
     file::*, group::*, invitation::*, organization::*, snapshot::*, task::*, user::*, workspace::*,
-    action::*, run::*, storage_quota::*, murph_quota::*
+    action::*, run::*, storage_quota::*, murph_quota::*,
+    file_property::*, upload_session::*, share_link::*,
+    webhook_subscription::*, trash_item::*, snapshot_retention_policy::*, snapshot_diff::*,
+    snapshot_chunk::*
 };
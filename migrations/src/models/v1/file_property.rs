@@ -0,0 +1,13 @@
+use sea_orm_migration::prelude::*;
+
+#[derive(Iden)]
+pub enum FileProperty {
+    Table,
+    Id,
+    FileId,
+    Namespace,
+    Name,
+    Value,
+    CreateTime,
+    UpdateTime,
+}
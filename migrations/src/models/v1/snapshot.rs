@@ -20,13 +20,19 @@ pub enum Snapshot {
     Ocr,
     Entities,
     Mosaic,
+    Hls,
     Segmentation,
     Thumbnail,
+    Metadata,
     Language,
     Summary,
     Intent,
     Status,
     TaskId,
+    IsPinned,
+    Label,
+    Note,
+    UserId,
     CreateTime,
     UpdateTime,
 }
@@ -0,0 +1,32 @@
+use sea_orm_migration::prelude::*;
+
+#[derive(Iden)]
+pub enum UploadSession {
+    Table,
+    Id,
+    UserId,
+    WorkspaceId,
+    ParentId,
+    FileId,
+    Name,
+    Size,
+    ContentType,
+    Bucket,
+    Key,
+    SnapshotId,
+    S3UploadId,
+    Status,
+    ExpiryTime,
+    CreateTime,
+    UpdateTime,
+}
+
+#[derive(Iden)]
+pub enum UploadSessionPart {
+    Table,
+    SessionId,
+    Number,
+    Size,
+    Etag,
+    CreateTime,
+}
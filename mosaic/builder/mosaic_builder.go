@@ -17,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/anthonynsimon/bild/imgio"
 	"github.com/anthonynsimon/bild/transform"
@@ -230,6 +231,7 @@ const (
 
 type MosaicBuilder struct {
 	image                     *Image
+	size                      Size
 	scaleDownPercentage       *ScaleDownPercentage
 	minimumScaleSize          *MinimumScaleSize
 	tileSize                  *TileSize
@@ -240,6 +242,14 @@ type MosaicBuilder struct {
 type MosaicBuilderOptions struct {
 	File            string
 	OutputDirectory string
+	// Page is written to the metadata when the image is a page of a document.
+	Page *int
+	// InMemoryPixelLimit is the number of pixels above which the image is
+	// tiled by libvips instead of being decoded in memory, zero means no limit.
+	InMemoryPixelLimit int64
+	// CommandTimeout returns how long the libvips tool can run before it is
+	// killed, no timeout applies when it is nil.
+	CommandTimeout func(tool string) time.Duration
 }
 
 func NewMosaicBuilder(opts MosaicBuilderOptions) *MosaicBuilder {
@@ -306,11 +316,21 @@ func (mb *MosaicBuilder) Build() (*model.Metadata, error) {
 		}
 	}()
 
+	if size, ok := mb.probeSize(mb.options.File); !ok || mb.exceedsInMemoryPixelLimit(size) {
+		if IsVipsAvailable() {
+			return mb.buildWithVips()
+		}
+		if ok {
+			return nil, fmt.Errorf("image of %dx%d pixels exceeds the in-memory limit and libvips is not available", size.Width, size.Height)
+		}
+	}
+
 	image, err := NewImage(mb.options.File)
 	if err != nil {
 		return nil, err
 	}
 	mb.image = image
+	mb.size = Size{Width: image.Width(), Height: image.Height()}
 
 	zoomLevelsIndexes := mb.RequiredZoomLevelIndexes()
 	if len(zoomLevelsIndexes) == 0 {
@@ -328,11 +348,16 @@ func (mb *MosaicBuilder) Build() (*model.Metadata, error) {
 		zoomLevels = append(zoomLevels, zoomLevel)
 	}
 
+	return mb.writeMetadata(zoomLevels)
+}
+
+func (mb *MosaicBuilder) writeMetadata(zoomLevels []model.ZoomLevel) (*model.Metadata, error) {
 	metadata := &model.Metadata{
-		Width:      mb.image.Width(),
-		Height:     mb.image.Height(),
+		Width:      mb.size.Width,
+		Height:     mb.size.Height,
 		Extension:  filepath.Ext(mb.options.File),
 		ZoomLevels: zoomLevels,
+		Page:       mb.options.Page,
 	}
 
 	metadataFilePath := mb.GetMetadataFilePath()
@@ -344,6 +369,11 @@ func (mb *MosaicBuilder) Build() (*model.Metadata, error) {
 	return metadata, nil
 }
 
+func (mb *MosaicBuilder) exceedsInMemoryPixelLimit(size Size) bool {
+	return mb.options.InMemoryPixelLimit > 0 &&
+		int64(size.Width)*int64(size.Height) > mb.options.InMemoryPixelLimit
+}
+
 func (mb *MosaicBuilder) Decompose(image *Image, zoomLevel int, region Region) model.ZoomLevel {
 	tileWidthExceeded := image.Width() > mb.TileSize().Width()
 	tileHeightExceeded := image.Height() > mb.TileSize().Height()
@@ -373,14 +403,6 @@ func (mb *MosaicBuilder) Decompose(image *Image, zoomLevel int, region Region) m
 		totalRows = rows + 1
 	}
 
-	adaptedTileSize := *mb.TileSize()
-	if !tileWidthExceeded {
-		adaptedTileSize.SetWidth(image.Width())
-	}
-	if !tileHeightExceeded {
-		adaptedTileSize.SetHeight(image.Height())
-	}
-
 	colStart, colEnd, rowStart, rowEnd := 0, cols-1, 0, rows-1
 	includesRemainingTiles := true
 	if !region.IsNull() {
@@ -469,16 +491,43 @@ func (mb *MosaicBuilder) Decompose(image *Image, zoomLevel int, region Region) m
 		}
 	}
 
+	return mb.NewZoomLevel(zoomLevel, image.Width(), image.Height())
+}
+
+// NewZoomLevel describes the grid of tiles of a zoom level, the last column and
+// row hold the remaining pixels that don't fill a whole tile.
+func (mb *MosaicBuilder) NewZoomLevel(index, width, height int) model.ZoomLevel {
+	tile := *mb.TileSize()
+	cols, rows := 1, 1
+	remainingWidth, remainingHeight := 0, 0
+	if width > tile.Width() {
+		cols = width / tile.Width()
+		remainingWidth = width - tile.Width()*cols
+	} else {
+		tile.SetWidth(width)
+	}
+	if height > tile.Height() {
+		rows = height / tile.Height()
+		remainingHeight = height - tile.Height()*rows
+	} else {
+		tile.SetHeight(height)
+	}
+	if remainingWidth != 0 {
+		cols++
+	}
+	if remainingHeight != 0 {
+		rows++
+	}
 	return model.ZoomLevel{
-		Index:               zoomLevel,
-		Width:               image.Width(),
-		Height:              image.Height(),
-		Rows:                totalRows,
-		Cols:                totalCols,
-		ScaleDownPercentage: float32(mb.GetScaleDownPercentage(zoomLevel)),
+		Index:               index,
+		Width:               width,
+		Height:              height,
+		Rows:                rows,
+		Cols:                cols,
+		ScaleDownPercentage: float32(mb.GetScaleDownPercentage(index)),
 		Tile: model.Tile{
-			Width:         adaptedTileSize.Width(),
-			Height:        adaptedTileSize.Height(),
+			Width:         tile.Width(),
+			Height:        tile.Height(),
 			LastColWidth:  remainingWidth,
 			LastRowHeight: remainingHeight,
 		},
@@ -507,7 +556,7 @@ func (mb *MosaicBuilder) Scale(zoomLevel int) (*Image, error) {
 }
 
 func (mb *MosaicBuilder) GetImageSizeForZoomLevel(zoomLevel int) Size {
-	size := Size{Width: mb.image.Width(), Height: mb.image.Height()}
+	size := mb.size
 	counter := 0
 	for {
 		if counter == zoomLevel {
@@ -523,7 +572,7 @@ func (mb *MosaicBuilder) GetImageSizeForZoomLevel(zoomLevel int) Size {
 func (mb *MosaicBuilder) RequiredZoomLevelIndexes() []int {
 	var levels []int
 	zoomLevelCount := 0
-	imageSize := Size{Width: mb.image.Width(), Height: mb.image.Height()}
+	imageSize := mb.size
 	for {
 		imageSize.Width = int(float64(imageSize.Width) * mb.ScaleDownPercentage().Factor())
 		imageSize.Height = int(float64(imageSize.Height) * mb.ScaleDownPercentage().Factor())
@@ -541,14 +590,21 @@ func (mb *MosaicBuilder) GetMetadataFilePath() string {
 }
 
 func (mb *MosaicBuilder) GetTileOutputPath(zoomLevel, row, col int) string {
+	return filepath.Join(mb.options.OutputDirectory, fmt.Sprintf("%d/%dx%d.%s", zoomLevel, row, col, mb.extension()))
+}
+
+func (mb *MosaicBuilder) extension() string {
 	extension := filepath.Ext(mb.options.File)
-	if extension == "" {
+	if extension == "" && mb.image != nil {
 		extension = mb.image.Extension()
 	}
+	if extension == "" {
+		return "jpg"
+	}
 	if extension[0] == '.' {
 		extension = extension[1:]
 	}
-	return filepath.Join(mb.options.OutputDirectory, fmt.Sprintf("%d/%dx%d.%s", zoomLevel, row, col, extension))
+	return extension
 }
 
 func (mb *MosaicBuilder) GetZoomLevelDirectoryPath(zoomLevel int) string {
@@ -0,0 +1,204 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kouprlabs/voltaserve/mosaic/model"
+)
+
+const VipsJPEGQuality = 90
+
+// vipsWaitDelay bounds how long Wait() waits for the output pipes to be closed
+// after the process is killed.
+const vipsWaitDelay = 5 * time.Second
+
+// IsVipsAvailable reports whether the libvips command line tools are installed.
+func IsVipsAvailable() bool {
+	if _, err := exec.LookPath("vips"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("vipsheader")
+	return err == nil
+}
+
+// probeSize reads the dimensions of the image from its header without decoding
+// the pixels, it falls back to libvips for the formats the standard library
+// doesn't know about.
+func (mb *MosaicBuilder) probeSize(file string) (Size, bool) {
+	f, err := os.Open(file) //nolint:gosec // Path is provided by the service
+	if err == nil {
+		defer func() { _ = f.Close() }()
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			return Size{Width: cfg.Width, Height: cfg.Height}, true
+		}
+	}
+	if !IsVipsAvailable() {
+		return Size{}, false
+	}
+	width, err := mb.vipsHeaderField(file, "width")
+	if err != nil {
+		return Size{}, false
+	}
+	height, err := mb.vipsHeaderField(file, "height")
+	if err != nil {
+		return Size{}, false
+	}
+	return Size{Width: width, Height: height}, true
+}
+
+func (mb *MosaicBuilder) vipsHeaderField(file string, field string) (int, error) {
+	out, err := mb.runVips("vipsheader", "-f", field, file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(out))
+}
+
+// runVips runs the libvips tool, it is killed once the timeout of the tool
+// elapses, so that a crafted image cannot hold the service forever.
+func (mb *MosaicBuilder) runVips(name string, args ...string) (string, error) {
+	ctx := context.Background()
+	if mb.options.CommandTimeout != nil {
+		if timeout := mb.options.CommandTimeout(name); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	cmd := exec.CommandContext(ctx, name, args...) //nolint:gosec // Arguments are built by the builder
+	cmd.WaitDelay = vipsWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("%s: timed out", name)
+		}
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// buildWithVips produces the same layout as Build, but streams the image through
+// libvips, which processes it in bands, so that the memory stays bounded
+// regardless of the size of the image.
+func (mb *MosaicBuilder) buildWithVips() (*model.Metadata, error) {
+	size, ok := mb.probeSize(mb.options.File)
+	if !ok {
+		return nil, fmt.Errorf("cannot read the size of the image: %s", mb.options.File)
+	}
+	mb.size = size
+
+	zoomLevelsIndexes := mb.RequiredZoomLevelIndexes()
+	if len(zoomLevelsIndexes) == 0 {
+		return nil, fmt.Errorf("creating zoom levels is not required for this image")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "mosaic-vips-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	var zoomLevels []model.ZoomLevel
+	for _, index := range zoomLevelsIndexes {
+		zoomLevel, err := mb.vipsZoomLevel(index, tmpDir)
+		if err != nil {
+			return nil, err
+		}
+		zoomLevels = append(zoomLevels, zoomLevel)
+	}
+
+	return mb.writeMetadata(zoomLevels)
+}
+
+func (mb *MosaicBuilder) vipsZoomLevel(index int, tmpDir string) (model.ZoomLevel, error) {
+	size := mb.GetImageSizeForZoomLevel(index)
+	source := mb.options.File
+	if size != mb.size {
+		source = filepath.Join(tmpDir, fmt.Sprintf("%d.v", index))
+		if _, err := mb.runVips(
+			"vips", "thumbnail", mb.options.File, source, strconv.Itoa(size.Width),
+			"--height", strconv.Itoa(size.Height),
+			"--size", "force",
+		); err != nil {
+			return model.ZoomLevel{}, err
+		}
+		defer func() { _ = os.Remove(source) }()
+	}
+
+	// With a depth of one, dzsave cuts the image at its own resolution only,
+	// into a single directory of {col}_{row} tiles.
+	base := filepath.Join(tmpDir, fmt.Sprintf("level_%d", index))
+	if _, err := mb.runVips(
+		"vips", "dzsave", source, base,
+		"--layout", "dz",
+		"--depth", "one",
+		"--tile-size", strconv.Itoa(mb.TileSize().Width()),
+		"--overlap", "0",
+		"--suffix", mb.vipsSuffix(),
+	); err != nil {
+		return model.ZoomLevel{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(base + "_files")
+		_ = os.Remove(base + ".dzi")
+	}()
+	levels, err := os.ReadDir(base + "_files")
+	if err != nil {
+		return model.ZoomLevel{}, err
+	}
+	if len(levels) != 1 || !levels[0].IsDir() {
+		return model.ZoomLevel{}, fmt.Errorf("unexpected output of dzsave for zoom level %d", index)
+	}
+	tilesDir := filepath.Join(base+"_files", levels[0].Name())
+	tiles, err := os.ReadDir(tilesDir)
+	if err != nil {
+		return model.ZoomLevel{}, err
+	}
+
+	mb.CreateZoomLevelDirectory(index)
+	for _, tile := range tiles {
+		var col, row int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(tile.Name(), filepath.Ext(tile.Name())), "%d_%d", &col, &row); err != nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(tilesDir, tile.Name()), mb.GetTileOutputPath(index, row, col)); err != nil {
+			return model.ZoomLevel{}, err
+		}
+	}
+
+	return mb.NewZoomLevel(index, size.Width, size.Height), nil
+}
+
+// vipsSuffix keeps the extension of the original, like the tiles built in
+// memory, so that the tiles are served under the same extension.
+func (mb *MosaicBuilder) vipsSuffix() string {
+	extension := strings.ToLower(mb.extension())
+	if extension == "jpg" || extension == "jpeg" {
+		return fmt.Sprintf(".%s[Q=%d]", mb.extension(), VipsJPEGQuality)
+	}
+	return "." + mb.extension()
+}
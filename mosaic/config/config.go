@@ -13,6 +13,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
 )
@@ -24,8 +26,15 @@ type Config struct {
 	Environment config.EnvironmentConfig
 }
 
+const ExternalCommandEverythingElse = "*"
+
 type LimitsConfig struct {
-	MultipartBodyLengthLimitMB int64
+	MultipartBodyLengthLimitMB    int64
+	InMemoryMegapixels            int64
+	ExternalCommandTimeoutSeconds int
+	// ExternalCommandToolTimeoutSeconds is keyed by tool name, for example vips,
+	// the * key applies to the tools that are not listed.
+	ExternalCommandToolTimeoutSeconds map[string]int
 }
 
 func GetConfig() *Config {
@@ -37,6 +46,19 @@ func GetConfig() *Config {
 	return cfg
 }
 
+// GetExternalCommandTimeout returns the wall-clock time limit of the tool, it
+// falls back to the * key, then to the default timeout.
+func (l *LimitsConfig) GetExternalCommandTimeout(tool string) time.Duration {
+	v, ok := l.ExternalCommandToolTimeoutSeconds[tool]
+	if !ok || v == 0 {
+		v = l.ExternalCommandToolTimeoutSeconds[ExternalCommandEverythingElse]
+	}
+	if v == 0 {
+		v = l.ExternalCommandTimeoutSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
 func readPort(config *Config) {
 	if len(os.Getenv("PORT")) > 0 {
 		port, err := strconv.Atoi(os.Getenv("PORT"))
@@ -54,4 +76,39 @@ func readLimits(config *Config) {
 		}
 		config.Limits.MultipartBodyLengthLimitMB = v
 	}
+	config.Limits.InMemoryMegapixels = 100
+	if len(os.Getenv("LIMITS_IN_MEMORY_MEGAPIXELS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_IN_MEMORY_MEGAPIXELS"), 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.InMemoryMegapixels = v
+	}
+	config.Limits.ExternalCommandTimeoutSeconds = 900
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_TIMEOUT_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("LIMITS_EXTERNAL_COMMAND_TIMEOUT_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Limits.ExternalCommandTimeoutSeconds = int(v)
+	}
+	if len(os.Getenv("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS")) > 0 {
+		config.Limits.ExternalCommandToolTimeoutSeconds = readIntMap("LIMITS_EXTERNAL_COMMAND_TOOL_TIMEOUT_SECONDS")
+	}
+}
+
+func readIntMap(name string) map[string]int {
+	res := make(map[string]int)
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		entry := strings.Split(part, ":")
+		if len(entry) != 2 {
+			panic("invalid " + name + " format")
+		}
+		v, err := strconv.ParseInt(entry[1], 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		res[entry[0]] = int(v)
+	}
+	return res
 }
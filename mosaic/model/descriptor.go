@@ -0,0 +1,63 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package model
+
+import "encoding/xml"
+
+const (
+	IIIFContext  = "http://iiif.io/api/image/3/context.json"
+	IIIFProtocol = "http://iiif.io/api/image"
+	IIIFType     = "ImageService3"
+	IIIFProfile  = "level2"
+)
+
+type IIIFInfo struct {
+	Context        string     `json:"@context"`
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	Protocol       string     `json:"protocol"`
+	Profile        string     `json:"profile"`
+	Width          int        `json:"width"`
+	Height         int        `json:"height"`
+	MaxWidth       int        `json:"maxWidth"`
+	MaxHeight      int        `json:"maxHeight"`
+	MaxArea        int        `json:"maxArea"`
+	Sizes          []IIIFSize `json:"sizes"`
+	Tiles          []IIIFTile `json:"tiles"`
+	ExtraQualities []string   `json:"extraQualities"`
+	ExtraFeatures  []string   `json:"extraFeatures"`
+}
+
+type IIIFSize struct {
+	Type   string `json:"type"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type IIIFTile struct {
+	Type         string `json:"type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	ScaleFactors []int  `json:"scaleFactors"`
+}
+
+type DeepZoomImage struct {
+	XMLName  xml.Name     `xml:"http://schemas.microsoft.com/deepzoom/2008 Image"`
+	TileSize int          `xml:"TileSize,attr"`
+	Overlap  int          `xml:"Overlap,attr"`
+	Format   string       `xml:"Format,attr"`
+	Size     DeepZoomSize `xml:"Size"`
+}
+
+type DeepZoomSize struct {
+	Width  int `xml:"Width,attr"`
+	Height int `xml:"Height,attr"`
+}
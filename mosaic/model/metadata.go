@@ -15,6 +15,12 @@ type Metadata struct {
 	Height     int         `json:"height"`
 	Extension  string      `json:"extension"`
 	ZoomLevels []ZoomLevel `json:"zoomLevels"`
+	// Page is the index, starting from zero, of the page of a document this
+	// mosaic was built from.
+	Page *int `json:"page,omitempty"`
+	// Pages lists the mosaics of the pages of a document, the metadata holding
+	// them describes no image of its own.
+	Pages []Metadata `json:"pages,omitempty"`
 }
 
 type ZoomLevel struct {
@@ -11,34 +11,59 @@
 package router
 
 import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/kouprlabs/voltaserve/mosaic/config"
 	"github.com/kouprlabs/voltaserve/mosaic/logger"
+	"github.com/kouprlabs/voltaserve/mosaic/model"
 	"github.com/kouprlabs/voltaserve/mosaic/service"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 	"github.com/kouprlabs/voltaserve/shared/helper"
 )
 
 type MosaicRouter struct {
-	mosaicSvc *service.MosaicService
-	config    *config.Config
+	mosaicSvc   *service.MosaicService
+	iiifSvc     *service.IIIFService
+	deepZoomSvc *service.DeepZoomService
+	config      *config.Config
 }
 
 func NewMosaicRouter() *MosaicRouter {
 	return &MosaicRouter{
-		mosaicSvc: service.NewMosaicService(),
-		config:    config.GetConfig(),
+		mosaicSvc:   service.NewMosaicService(),
+		iiifSvc:     service.NewIIIFService(),
+		deepZoomSvc: service.NewDeepZoomService(),
+		config:      config.GetConfig(),
 	}
 }
 
+const (
+	IIIFProfileLink  = "<http://iiif.io/api/image/3/level2.json>;rel=\"profile\""
+	IIIFJSONLDType   = "application/ld+json;profile=\"" + model.IIIFContext + "\""
+	DeepZoomMIMEType = "application/xml"
+)
+
 func (r *MosaicRouter) AppendRoutes(g fiber.Router) {
 	g.Post("/", r.Create)
-	g.Get("/:s3_bucket/:s3_key/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", r.DownloadTile)
-	g.Get("/:s3_bucket/:s3_key/metadata", r.GetMetadata)
+	// The mosaics of the pages of a document are served under the same routes,
+	// prefixed by the index of the page.
+	for _, prefix := range []string{"/:s3_bucket/:s3_key", "/:s3_bucket/:s3_key/pages/:page"} {
+		g.Get(prefix+"/zoom_level/:zoom_level/row/:row/column/:column/extension/:extension", r.DownloadTile)
+		g.Get(prefix+"/metadata", r.GetMetadata)
+		g.Get(prefix+"/iiif", r.RedirectIIIF)
+		g.Get(prefix+"/iiif/info.json", r.GetIIIFInfo)
+		g.Get(prefix+"/iiif/:region/:size/:rotation/:quality.:format", r.GetIIIFImage)
+		g.Get(prefix+"/deep_zoom.dzi", r.GetDeepZoomDescriptor)
+		g.Get(prefix+"/deep_zoom_files/:level/:tile", r.DownloadDeepZoomTile)
+	}
 	g.Delete("/:s3_bucket/:s3_key", r.Delete)
 }
 
@@ -53,6 +78,7 @@ func (r *MosaicRouter) AppendRoutes(g fiber.Router) {
 //	@Param			file		formData	file	true	"File to upload"
 //	@Param			s3_key		formData	string	true	"S3 Key"
 //	@Param			s3_bucket	formData	string	true	"S3 Bucket"
+//	@Param			page		formData	int		false	"Page of the document the file was rendered from"
 //	@Success		201			{object}	model.Metadata
 //	@Failure		400			{object}	errorpkg.ErrorResponse
 //	@Failure		500			{object}	errorpkg.ErrorResponse
@@ -78,7 +104,15 @@ func (r *MosaicRouter) Create(c *fiber.Ctx) error {
 	}
 	s3Key := form.Value["s3_key"][0]
 	s3Bucket := form.Value["s3_bucket"][0]
-	metadata, err := r.mosaicSvc.Create(path, s3Key, s3Bucket)
+	var page *int
+	if values := form.Value["page"]; len(values) > 0 {
+		v, err := strconv.Atoi(values[0])
+		if err != nil || v < 0 {
+			return errorpkg.NewInvalidFormValueError("page")
+		}
+		page = &v
+	}
+	metadata, err := r.mosaicSvc.Create(path, s3Key, s3Bucket, page)
 	if err != nil {
 		return err
 	}
@@ -114,14 +148,20 @@ func (r *MosaicRouter) Delete(c *fiber.Ctx) error {
 //	@Id				mosaics_get_metadata
 //	@Param			s3_bucket	path		string	true	"S3 Bucket"
 //	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
 //	@Success		200			{object}	model.Metadata
 //	@Failure		404			{object}	errorpkg.ErrorResponse
 //	@Failure		500			{object}	errorpkg.ErrorResponse
 //	@Router			/mosaics/{s3_bucket}/{s3_key}/metadata [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/metadata [get]
 func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 	s3Bucket := c.Params("s3_bucket")
 	s3Key := c.Params("s3_key")
-	metadata, err := r.mosaicSvc.GetMetadata(s3Bucket, s3Key)
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	metadata, err := r.mosaicSvc.GetMetadata(s3Bucket, s3Key, page)
 	if err != nil {
 		return err
 	}
@@ -137,6 +177,7 @@ func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 //	@Produce		application/octet-stream
 //	@Param			s3_bucket	path		string	true	"S3 Bucket"
 //	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
 //	@Param			zoom_level	path		int		true	"Zoom Level"
 //	@Param			row			path		int		true	"Row"
 //	@Param			column		path		int		true	"Column"
@@ -145,6 +186,7 @@ func (r *MosaicRouter) GetMetadata(c *fiber.Ctx) error {
 //	@Failure		404			{object}	errorpkg.ErrorResponse
 //	@Failure		500			{object}	errorpkg.ErrorResponse
 //	@Router			/mosaics/{s3_bucket}/{s3_key}/zoom_level/{zoom_level}/row/{row}/column/{column}/extension/{extension} [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/zoom_level/{zoom_level}/row/{row}/column/{column}/extension/{extension} [get]
 func (r *MosaicRouter) DownloadTile(c *fiber.Ctx) error {
 	s3Bucket := c.Params("s3_bucket")
 	s3Key := c.Params("s3_key")
@@ -152,7 +194,11 @@ func (r *MosaicRouter) DownloadTile(c *fiber.Ctx) error {
 	row, _ := strconv.Atoi(c.Params("row"))
 	column, _ := strconv.Atoi(c.Params("column"))
 	extension := c.Params("extension")
-	buf, contentType, err := r.mosaicSvc.GetTileBuffer(s3Bucket, s3Key, zoomLevel, row, column, extension)
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	buf, contentType, err := r.mosaicSvc.GetTileBuffer(s3Bucket, s3Key, page, zoomLevel, row, column, extension)
 	if err != nil {
 		return err
 	}
@@ -160,3 +206,195 @@ func (r *MosaicRouter) DownloadTile(c *fiber.Ctx) error {
 	c.Set("Content-Type", *contentType)
 	return c.Send(b)
 }
+
+// RedirectIIIF godoc
+//
+//	@Summary		Redirect IIIF
+//	@Description	Redirect the base URI of the IIIF image service to its info.json
+//	@Tags			Mosaics
+//	@Id				mosaics_redirect_iiif
+//	@Param			s3_bucket	path	string	true	"S3 Bucket"
+//	@Param			s3_key		path	string	true	"S3 Key"
+//	@Param			page		path	int		false	"Page"
+//	@Success		303
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/iiif [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/iiif [get]
+func (r *MosaicRouter) RedirectIIIF(c *fiber.Ctx) error {
+	return c.Redirect(strings.TrimSuffix(c.OriginalURL(), "/")+"/info.json", fiber.StatusSeeOther)
+}
+
+// GetIIIFInfo godoc
+//
+//	@Summary		Get IIIF Info
+//	@Description	Get the IIIF Image API 3.0 info.json of the mosaic
+//	@Tags			Mosaics
+//	@Id				mosaics_get_iiif_info
+//	@Produce		application/json
+//	@Param			s3_bucket	path		string	true	"S3 Bucket"
+//	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
+//	@Param			id			query		string	true	"Base URI of the image service, as seen by the clients"
+//	@Success		200			{object}	model.IIIFInfo
+//	@Failure		400			{object}	errorpkg.ErrorResponse
+//	@Failure		404			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/iiif/info.json [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/iiif/info.json [get]
+func (r *MosaicRouter) GetIIIFInfo(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	id := c.Query("id")
+	if id == "" {
+		return errorpkg.NewMissingQueryParamError("id")
+	}
+	info, err := r.iiifSvc.GetInfo(c.Params("s3_bucket"), c.Params("s3_key"), page, id)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(c.Get(fiber.HeaderAccept), "application/ld+json") {
+		return c.JSON(info, IIIFJSONLDType)
+	}
+	return c.JSON(info)
+}
+
+// GetIIIFImage godoc
+//
+//	@Summary		Get IIIF Image
+//	@Description	Get a region of the mosaic, scaled, rotated and encoded as described by the IIIF Image API 3.0
+//	@Tags			Mosaics
+//	@Id				mosaics_get_iiif_image
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			s3_bucket	path		string	true	"S3 Bucket"
+//	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
+//	@Param			region		path		string	true	"Region"
+//	@Param			size		path		string	true	"Size"
+//	@Param			rotation	path		string	true	"Rotation"
+//	@Param			quality		path		string	true	"Quality"
+//	@Param			format		path		string	true	"Format"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	errorpkg.ErrorResponse
+//	@Failure		404			{object}	errorpkg.ErrorResponse
+//	@Failure		413			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/iiif/{region}/{size}/{rotation}/{quality}.{format} [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/iiif/{region}/{size}/{rotation}/{quality}.{format} [get]
+func (r *MosaicRouter) GetIIIFImage(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	opts := service.IIIFImageOptions{}
+	for param, value := range map[string]*string{
+		"region":   &opts.Region,
+		"size":     &opts.Size,
+		"rotation": &opts.Rotation,
+		"quality":  &opts.Quality,
+		"format":   &opts.Format,
+	} {
+		unescaped, err := url.PathUnescape(c.Params(param))
+		if err != nil {
+			return errorpkg.NewInvalidPathParamError(param)
+		}
+		*value = unescaped
+	}
+	b, contentType, err := r.iiifSvc.GetImage(c.Params("s3_bucket"), c.Params("s3_key"), page, opts)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderLink, IIIFProfileLink)
+	return c.Send(b)
+}
+
+// GetDeepZoomDescriptor godoc
+//
+//	@Summary		Get Deep Zoom Descriptor
+//	@Description	Get the Deep Zoom (DZI) descriptor of the mosaic, the tiles are served next to it under deep_zoom_files
+//	@Tags			Mosaics
+//	@Id				mosaics_get_deep_zoom_descriptor
+//	@Produce		application/xml
+//	@Param			s3_bucket	path		string	true	"S3 Bucket"
+//	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
+//	@Success		200			{object}	model.DeepZoomImage
+//	@Failure		404			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/deep_zoom.dzi [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/deep_zoom.dzi [get]
+func (r *MosaicRouter) GetDeepZoomDescriptor(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	descriptor, err := r.deepZoomSvc.GetDescriptor(c.Params("s3_bucket"), c.Params("s3_key"), page)
+	if err != nil {
+		return err
+	}
+	b, err := xml.Marshal(descriptor)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, DeepZoomMIMEType)
+	return c.Send(append([]byte(xml.Header), b...))
+}
+
+// DownloadDeepZoomTile godoc
+//
+//	@Summary		Download Deep Zoom Tile
+//	@Description	Download Deep Zoom Tile
+//	@Tags			Mosaics
+//	@Id				mosaics_download_deep_zoom_tile
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			s3_bucket	path		string	true	"S3 Bucket"
+//	@Param			s3_key		path		string	true	"S3 Key"
+//	@Param			page		path		int		false	"Page"
+//	@Param			level		path		int		true	"Level"
+//	@Param			tile		path		string	true	"Tile, formatted as {column}_{row}.{extension}"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	errorpkg.ErrorResponse
+//	@Failure		404			{object}	errorpkg.ErrorResponse
+//	@Failure		500			{object}	errorpkg.ErrorResponse
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/deep_zoom_files/{level}/{tile} [get]
+//	@Router			/mosaics/{s3_bucket}/{s3_key}/pages/{page}/deep_zoom_files/{level}/{tile} [get]
+func (r *MosaicRouter) DownloadDeepZoomTile(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return err
+	}
+	level, err := strconv.Atoi(c.Params("level"))
+	if err != nil {
+		return errorpkg.NewInvalidPathParamError("level")
+	}
+	var column, row int
+	name, extension, found := strings.Cut(c.Params("tile"), ".")
+	if !found {
+		return errorpkg.NewInvalidPathParamError("tile")
+	}
+	if _, err := fmt.Sscanf(name, "%d_%d", &column, &row); err != nil {
+		return errorpkg.NewInvalidPathParamError("tile")
+	}
+	b, contentType, err := r.deepZoomSvc.GetTile(c.Params("s3_bucket"), c.Params("s3_key"), page, level, column, row, extension)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(b)
+}
+
+// parsePage returns the page of the document the route is prefixed with, or nil
+// when the route addresses the mosaic of an image.
+func (r *MosaicRouter) parsePage(c *fiber.Ctx) (*int, error) {
+	if c.Params("page") == "" {
+		return nil, nil
+	}
+	page, err := strconv.Atoi(c.Params("page"))
+	if err != nil || page < 0 {
+		return nil, errorpkg.NewInvalidPathParamError("page")
+	}
+	return &page, nil
+}
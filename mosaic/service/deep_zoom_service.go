@@ -0,0 +1,109 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"image"
+	"math/bits"
+	"strings"
+
+	"github.com/kouprlabs/voltaserve/mosaic/model"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+)
+
+const DeepZoomOverlap = 0
+
+// DeepZoomService serves the mosaics as Deep Zoom images, each level of a Deep
+// Zoom pyramid halves the one above it, so its tiles are drawn from the zoom
+// levels of the mosaic rather than stored next to them.
+type DeepZoomService struct {
+	iiifSvc *IIIFService
+}
+
+func NewDeepZoomService() *DeepZoomService {
+	return &DeepZoomService{
+		iiifSvc: NewIIIFService(),
+	}
+}
+
+func (svc *DeepZoomService) GetDescriptor(s3Bucket, s3Key string, page *int) (*model.DeepZoomImage, error) {
+	metadata, err := svc.iiifSvc.getMetadata(s3Bucket, s3Key, page)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeepZoomImage{
+		TileSize: svc.tileSize(metadata),
+		Overlap:  DeepZoomOverlap,
+		Format:   svc.format(metadata),
+		Size: model.DeepZoomSize{
+			Width:  metadata.Width,
+			Height: metadata.Height,
+		},
+	}, nil
+}
+
+// GetTile renders the tile of a level of the pyramid, it returns the encoded
+// tile and its content type.
+func (svc *DeepZoomService) GetTile(s3Bucket, s3Key string, page *int, level, col, row int, format string) ([]byte, string, error) {
+	metadata, err := svc.iiifSvc.getMetadata(s3Bucket, s3Key, page)
+	if err != nil {
+		return nil, "", err
+	}
+	if format != svc.format(metadata) {
+		return nil, "", errorpkg.NewInvalidPathParamError("tile")
+	}
+	maxLevel := svc.maxLevel(metadata.Width, metadata.Height)
+	if level < 0 || level > maxLevel {
+		return nil, "", errorpkg.NewInvalidPathParamError("level")
+	}
+	scale := 1 << (maxLevel - level)
+	levelWidth := (metadata.Width + scale - 1) / scale
+	levelHeight := (metadata.Height + scale - 1) / scale
+	tileSize := svc.tileSize(metadata)
+	x, y := col*tileSize, row*tileSize
+	if col < 0 || row < 0 || x >= levelWidth || y >= levelHeight {
+		return nil, "", errorpkg.NewInvalidPathParamError("tile")
+	}
+	size := image.Pt(min(tileSize, levelWidth-x), min(tileSize, levelHeight-y))
+	return svc.iiifSvc.render(s3Bucket, s3Key, page, metadata, iiifRenderOptions{
+		Region: image.Rect(
+			x*scale,
+			y*scale,
+			min((x+size.X)*scale, metadata.Width),
+			min((y+size.Y)*scale, metadata.Height),
+		),
+		Size:    size,
+		Quality: IIIFQualityDefault,
+		Format:  format,
+	})
+}
+
+// maxLevel returns the level of the full resolution image, which is the number
+// of times its largest side can be halved before reaching one pixel.
+func (svc *DeepZoomService) maxLevel(width, height int) int {
+	return bits.Len(uint(max(width, height) - 1))
+}
+
+// tileSize returns the size of the tiles of the mosaic, so that the tiles of
+// the full resolution level are the stored ones.
+func (svc *DeepZoomService) tileSize(metadata *model.Metadata) int {
+	tile := svc.iiifSvc.fullResolution(metadata).Tile
+	return max(tile.Width, tile.Height)
+}
+
+// format returns the format of the tiles, which keeps the format of the mosaic
+// when it is one that can be encoded.
+func (svc *DeepZoomService) format(metadata *model.Metadata) string {
+	if strings.EqualFold(svc.iiifSvc.tileExtension(metadata), IIIFFormatPNG) {
+		return IIIFFormatPNG
+	}
+	return IIIFFormatJPG
+}
@@ -0,0 +1,496 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/effect"
+	"github.com/anthonynsimon/bild/segment"
+	"github.com/anthonynsimon/bild/transform"
+
+	"github.com/kouprlabs/voltaserve/mosaic/model"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+)
+
+const (
+	IIIFQualityDefault = "default"
+	IIIFQualityColor   = "color"
+	IIIFQualityGray    = "gray"
+	IIIFQualityBitonal = "bitonal"
+)
+
+const (
+	IIIFFormatJPG = "jpg"
+	IIIFFormatPNG = "png"
+)
+
+const IIIFJPEGQuality = 90
+
+// The largest image that can be requested, the image is rendered in memory so
+// larger requests are rejected rather than scaled.
+const (
+	IIIFMaxWidth  = 4096
+	IIIFMaxHeight = 4096
+	IIIFMaxArea   = IIIFMaxWidth * IIIFMaxHeight
+)
+
+// IIIFService implements the IIIF Image API 3.0 on top of the zoom levels of
+// the mosaics, requests are drawn from the tiles of the smallest zoom level that
+// still has the requested resolution, so that the original image never needs to
+// be loaded.
+type IIIFService struct {
+	mosaicSvc *MosaicService
+}
+
+func NewIIIFService() *IIIFService {
+	return &IIIFService{
+		mosaicSvc: NewMosaicService(),
+	}
+}
+
+type IIIFImageOptions struct {
+	Region   string
+	Size     string
+	Rotation string
+	Quality  string
+	Format   string
+}
+
+type iiifRenderOptions struct {
+	Region  image.Rectangle
+	Size    image.Point
+	Degrees int
+	Mirror  bool
+	Quality string
+	Format  string
+}
+
+// GetInfo returns the info.json of the image, id is the base URI of the image
+// service, which viewers append the image requests to.
+func (svc *IIIFService) GetInfo(s3Bucket, s3Key string, page *int, id string) (*model.IIIFInfo, error) {
+	metadata, err := svc.getMetadata(s3Bucket, s3Key, page)
+	if err != nil {
+		return nil, err
+	}
+	maxWidth, maxHeight, maxArea := svc.limits(metadata)
+	res := &model.IIIFInfo{
+		Context:        model.IIIFContext,
+		ID:             id,
+		Type:           model.IIIFType,
+		Protocol:       model.IIIFProtocol,
+		Profile:        model.IIIFProfile,
+		Width:          metadata.Width,
+		Height:         metadata.Height,
+		MaxWidth:       maxWidth,
+		MaxHeight:      maxHeight,
+		MaxArea:        maxArea,
+		Sizes:          make([]model.IIIFSize, 0),
+		ExtraQualities: []string{IIIFQualityGray, IIIFQualityBitonal},
+		ExtraFeatures:  []string{"baseUriRedirect", "jsonldMediaType", "mirroring", "profileLinkHeader", "sizeUpscaling"},
+	}
+	// Whole zoom levels are drawn from their own tiles, smallest first
+	for i := len(metadata.ZoomLevels) - 1; i >= 0; i-- {
+		zoomLevel := metadata.ZoomLevels[i]
+		if zoomLevel.Width <= maxWidth && zoomLevel.Height <= maxHeight && zoomLevel.Width*zoomLevel.Height <= maxArea {
+			res.Sizes = append(res.Sizes, model.IIIFSize{Type: "Size", Width: zoomLevel.Width, Height: zoomLevel.Height})
+		}
+	}
+	// Tiles at a scale factor of one are the tiles of the full resolution zoom
+	// level, the others are drawn from the closest zoom level.
+	tile := svc.fullResolution(metadata).Tile
+	scaleFactors := make([]int, 0)
+	for scale := 1; ; scale *= 2 {
+		scaleFactors = append(scaleFactors, scale)
+		if (metadata.Width+scale-1)/scale <= tile.Width && (metadata.Height+scale-1)/scale <= tile.Height {
+			break
+		}
+	}
+	res.Tiles = []model.IIIFTile{{
+		Type:         "Tile",
+		Width:        tile.Width,
+		Height:       tile.Height,
+		ScaleFactors: scaleFactors,
+	}}
+	return res, nil
+}
+
+// GetImage renders an image request, it returns the encoded image and its
+// content type.
+func (svc *IIIFService) GetImage(s3Bucket, s3Key string, page *int, opts IIIFImageOptions) ([]byte, string, error) {
+	metadata, err := svc.getMetadata(s3Bucket, s3Key, page)
+	if err != nil {
+		return nil, "", err
+	}
+	region, err := svc.parseRegion(opts.Region, metadata.Width, metadata.Height)
+	if err != nil {
+		return nil, "", err
+	}
+	maxWidth, maxHeight, maxArea := svc.limits(metadata)
+	size, err := svc.parseSize(opts.Size, region.Dx(), region.Dy(), maxWidth, maxHeight, maxArea)
+	if err != nil {
+		return nil, "", err
+	}
+	degrees, mirror, err := svc.parseRotation(opts.Rotation)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Quality != IIIFQualityDefault && opts.Quality != IIIFQualityColor &&
+		opts.Quality != IIIFQualityGray && opts.Quality != IIIFQualityBitonal {
+		return nil, "", errorpkg.NewInvalidPathParamError("quality")
+	}
+	if opts.Format != IIIFFormatJPG && opts.Format != IIIFFormatPNG {
+		return nil, "", errorpkg.NewInvalidPathParamError("format")
+	}
+	return svc.render(s3Bucket, s3Key, page, metadata, iiifRenderOptions{
+		Region:  region,
+		Size:    size,
+		Degrees: degrees,
+		Mirror:  mirror,
+		Quality: opts.Quality,
+		Format:  opts.Format,
+	})
+}
+
+// render draws a region of the image from the tiles of a single zoom level, so
+// that the canvas is never much larger than the requested size.
+func (svc *IIIFService) render(s3Bucket, s3Key string, page *int, metadata *model.Metadata, opts iiifRenderOptions) ([]byte, string, error) {
+	contentType := "image/jpeg"
+	if opts.Format == IIIFFormatPNG {
+		contentType = "image/png"
+	}
+	zoomLevel := svc.findZoomLevel(metadata, opts.Region, opts.Size)
+	scaled := svc.scaleRegion(metadata, zoomLevel, opts.Region)
+	extension := svc.tileExtension(metadata)
+	tile := zoomLevel.Tile
+
+	// Requests which map to a stored tile get the tile as it is
+	if scaled.Min.X%tile.Width == 0 && scaled.Min.Y%tile.Height == 0 &&
+		scaled.Dx() == min(tile.Width, zoomLevel.Width-scaled.Min.X) &&
+		scaled.Dy() == min(tile.Height, zoomLevel.Height-scaled.Min.Y) &&
+		opts.Size.X == scaled.Dx() && opts.Size.Y == scaled.Dy() &&
+		opts.Degrees == 0 && !opts.Mirror &&
+		(opts.Quality == IIIFQualityDefault || opts.Quality == IIIFQualityColor) &&
+		svc.isSameFormat(opts.Format, extension) {
+		buf, _, err := svc.mosaicSvc.GetTileBuffer(
+			s3Bucket, s3Key, page, zoomLevel.Index, scaled.Min.Y/tile.Height, scaled.Min.X/tile.Width, extension)
+		if err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, scaled.Dx(), scaled.Dy()))
+	for row := scaled.Min.Y / tile.Height; row < zoomLevel.Rows && row*tile.Height < scaled.Max.Y; row++ {
+		for col := scaled.Min.X / tile.Width; col < zoomLevel.Cols && col*tile.Width < scaled.Max.X; col++ {
+			buf, _, err := svc.mosaicSvc.GetTileBuffer(s3Bucket, s3Key, page, zoomLevel.Index, row, col, extension)
+			if err != nil {
+				return nil, "", err
+			}
+			decoded, _, err := image.Decode(buf)
+			if err != nil {
+				return nil, "", err
+			}
+			origin := image.Pt(col*tile.Width-scaled.Min.X, row*tile.Height-scaled.Min.Y)
+			draw.Draw(canvas, decoded.Bounds().Sub(decoded.Bounds().Min).Add(origin), decoded, decoded.Bounds().Min, draw.Src)
+		}
+	}
+
+	var img image.Image = canvas
+	if opts.Size.X != canvas.Bounds().Dx() || opts.Size.Y != canvas.Bounds().Dy() {
+		img = transform.Resize(img, opts.Size.X, opts.Size.Y, transform.Lanczos)
+	}
+	if opts.Mirror {
+		img = transform.FlipH(img)
+	}
+	img = svc.rotate(img, opts.Degrees)
+	switch opts.Quality {
+	case IIIFQualityGray:
+		img = effect.Grayscale(img)
+	case IIIFQualityBitonal:
+		img = segment.Threshold(img, 128)
+	}
+
+	buf := new(bytes.Buffer)
+	var err error
+	if opts.Format == IIIFFormatPNG {
+		err = png.Encode(buf, img)
+	} else {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: IIIFJPEGQuality})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// getMetadata returns the metadata of a mosaic which describes an image, the
+// root metadata of a document only lists its pages.
+func (svc *IIIFService) getMetadata(s3Bucket, s3Key string, page *int) (*model.Metadata, error) {
+	metadata, err := svc.mosaicSvc.GetMetadata(s3Bucket, s3Key, page)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.ZoomLevels) == 0 {
+		return nil, errorpkg.NewResourceNotFoundError(errors.New("mosaic has no zoom levels"))
+	}
+	return metadata, nil
+}
+
+// limits returns the maximum width, height and area of the requests, which are
+// never larger than the image itself.
+func (svc *IIIFService) limits(metadata *model.Metadata) (int, int, int) {
+	return min(metadata.Width, IIIFMaxWidth),
+		min(metadata.Height, IIIFMaxHeight),
+		min(metadata.Width*metadata.Height, IIIFMaxArea)
+}
+
+// fullResolution returns the zoom level which has the size of the image.
+func (svc *IIIFService) fullResolution(metadata *model.Metadata) model.ZoomLevel {
+	res := metadata.ZoomLevels[0]
+	for _, zoomLevel := range metadata.ZoomLevels {
+		if zoomLevel.Width > res.Width {
+			res = zoomLevel
+		}
+	}
+	return res
+}
+
+// findZoomLevel returns the smallest zoom level which still has at least the
+// requested resolution, or the full resolution one when upscaling.
+func (svc *IIIFService) findZoomLevel(metadata *model.Metadata, region image.Rectangle, size image.Point) model.ZoomLevel {
+	res := svc.fullResolution(metadata)
+	for _, zoomLevel := range metadata.ZoomLevels {
+		if zoomLevel.Width < res.Width &&
+			float64(region.Dx())*float64(zoomLevel.Width)/float64(metadata.Width) >= float64(size.X) &&
+			float64(region.Dy())*float64(zoomLevel.Height)/float64(metadata.Height) >= float64(size.Y) {
+			res = zoomLevel
+		}
+	}
+	return res
+}
+
+// scaleRegion maps a region of the image to the pixels of a zoom level.
+func (svc *IIIFService) scaleRegion(metadata *model.Metadata, zoomLevel model.ZoomLevel, region image.Rectangle) image.Rectangle {
+	sx := float64(zoomLevel.Width) / float64(metadata.Width)
+	sy := float64(zoomLevel.Height) / float64(metadata.Height)
+	res := image.Rect(
+		int(math.Floor(float64(region.Min.X)*sx)),
+		int(math.Floor(float64(region.Min.Y)*sy)),
+		int(math.Ceil(float64(region.Max.X)*sx)),
+		int(math.Ceil(float64(region.Max.Y)*sy)),
+	).Intersect(image.Rect(0, 0, zoomLevel.Width, zoomLevel.Height))
+	if res.Dx() == 0 {
+		res.Max.X = min(res.Min.X+1, zoomLevel.Width)
+		res.Min.X = res.Max.X - 1
+	}
+	if res.Dy() == 0 {
+		res.Max.Y = min(res.Min.Y+1, zoomLevel.Height)
+		res.Min.Y = res.Max.Y - 1
+	}
+	return res
+}
+
+// tileExtension returns the extension the tiles were stored with, which is the
+// extension of the image the mosaic was built from.
+func (svc *IIIFService) tileExtension(metadata *model.Metadata) string {
+	extension := strings.TrimPrefix(metadata.Extension, ".")
+	if extension == "" {
+		return "jpg"
+	}
+	return extension
+}
+
+func (svc *IIIFService) parseRegion(value string, width, height int) (image.Rectangle, error) {
+	var res image.Rectangle
+	switch {
+	case value == "full":
+		res = image.Rect(0, 0, width, height)
+	case value == "square":
+		side := min(width, height)
+		x, y := (width-side)/2, (height-side)/2
+		res = image.Rect(x, y, x+side, y+side)
+	case strings.HasPrefix(value, "pct:"):
+		n, err := svc.parseNumbers(strings.TrimPrefix(value, "pct:"), 4)
+		if err != nil {
+			return image.Rectangle{}, errorpkg.NewInvalidPathParamError("region")
+		}
+		x := int(math.Round(n[0] * float64(width) / 100))
+		y := int(math.Round(n[1] * float64(height) / 100))
+		res = image.Rect(x, y,
+			x+int(math.Round(n[2]*float64(width)/100)),
+			y+int(math.Round(n[3]*float64(height)/100)))
+	default:
+		n, err := svc.parseIntegers(value, 4)
+		if err != nil {
+			return image.Rectangle{}, errorpkg.NewInvalidPathParamError("region")
+		}
+		res = image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3])
+	}
+	res = res.Intersect(image.Rect(0, 0, width, height))
+	if res.Empty() {
+		return image.Rectangle{}, errorpkg.NewInvalidPathParamError("region")
+	}
+	return res, nil
+}
+
+// parseSize returns the size of the requested image, which can only be larger
+// than the region when the request allows upscaling with a caret, and never
+// exceeds the limits of the service.
+func (svc *IIIFService) parseSize(value string, regionWidth, regionHeight, maxWidth, maxHeight, maxArea int) (image.Point, error) {
+	upscale := strings.HasPrefix(value, "^")
+	value = strings.TrimPrefix(value, "^")
+	w, h := float64(regionWidth), float64(regionHeight)
+	var res image.Point
+	switch {
+	case value == "max":
+		// The largest size allowed by the limits, without upscaling unless asked
+		scale := math.Min(float64(maxWidth)/w, float64(maxHeight)/h)
+		scale = math.Min(scale, math.Sqrt(float64(maxArea)/(w*h)))
+		if !upscale {
+			scale = math.Min(scale, 1)
+		}
+		if scale == 1 {
+			res = image.Pt(regionWidth, regionHeight)
+		} else {
+			res = image.Pt(max(int(w*scale), 1), max(int(h*scale), 1))
+		}
+	case strings.HasPrefix(value, "pct:"):
+		n, err := svc.parseNumbers(strings.TrimPrefix(value, "pct:"), 1)
+		if err != nil {
+			return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+		}
+		res = image.Pt(int(math.Round(w*n[0]/100)), int(math.Round(h*n[0]/100)))
+	case strings.HasPrefix(value, "!"):
+		n, err := svc.parseIntegers(strings.TrimPrefix(value, "!"), 2)
+		if err != nil {
+			return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+		}
+		scale := math.Min(float64(n[0])/w, float64(n[1])/h)
+		res = image.Pt(int(math.Round(w*scale)), int(math.Round(h*scale)))
+	case strings.HasSuffix(value, ","):
+		n, err := svc.parseIntegers(strings.TrimSuffix(value, ","), 1)
+		if err != nil {
+			return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+		}
+		res = image.Pt(n[0], int(math.Round(h*float64(n[0])/w)))
+	case strings.HasPrefix(value, ","):
+		n, err := svc.parseIntegers(strings.TrimPrefix(value, ","), 1)
+		if err != nil {
+			return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+		}
+		res = image.Pt(int(math.Round(w*float64(n[0])/h)), n[0])
+	default:
+		n, err := svc.parseIntegers(value, 2)
+		if err != nil {
+			return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+		}
+		res = image.Pt(n[0], n[1])
+	}
+	if res.X <= 0 || res.Y <= 0 {
+		return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+	}
+	if !upscale && (res.X > regionWidth || res.Y > regionHeight) {
+		return image.Point{}, errorpkg.NewInvalidPathParamError("size")
+	}
+	if res.X > maxWidth || res.Y > maxHeight || res.X*res.Y > maxArea {
+		return image.Point{}, errorpkg.NewMosaicSizeExceededError(maxWidth, maxHeight, maxArea)
+	}
+	return res, nil
+}
+
+// parseRotation only accepts multiples of 90 degrees, optionally preceded by
+// an exclamation mark to mirror the image before rotating it.
+func (svc *IIIFService) parseRotation(value string) (int, bool, error) {
+	mirror := strings.HasPrefix(value, "!")
+	n, err := strconv.ParseFloat(strings.TrimPrefix(value, "!"), 64)
+	if err != nil || n < 0 || n > 360 || math.Mod(n, 90) != 0 {
+		return 0, false, errorpkg.NewInvalidPathParamError("rotation")
+	}
+	return int(n) % 360, mirror, nil
+}
+
+func (svc *IIIFService) parseNumbers(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, strconv.ErrSyntax
+	}
+	res := make([]float64, 0, count)
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return nil, strconv.ErrSyntax
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func (svc *IIIFService) parseIntegers(value string, count int) ([]int, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, strconv.ErrSyntax
+	}
+	res := make([]int, 0, count)
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, strconv.ErrSyntax
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// rotate turns the image clockwise by a multiple of 90 degrees, pixels are
+// moved rather than interpolated so that the result stays sharp.
+func (svc *IIIFService) rotate(img image.Image, degrees int) image.Image {
+	if degrees == 0 {
+		return img
+	}
+	b := img.Bounds()
+	var res *image.RGBA
+	if degrees == 180 {
+		res = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	} else {
+		res = image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				res.Set(b.Dy()-1-y, x, c)
+			case 180:
+				res.Set(b.Dx()-1-x, b.Dy()-1-y, c)
+			case 270:
+				res.Set(y, b.Dx()-1-x, c)
+			}
+		}
+	}
+	return res
+}
+
+func (svc *IIIFService) isSameFormat(format string, extension string) bool {
+	extension = strings.ToLower(extension)
+	if format == IIIFFormatJPG {
+		return extension == "jpg" || extension == "jpeg"
+	}
+	return extension == format
+}
@@ -17,6 +17,8 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 
 	"github.com/minio/minio-go/v7"
 
@@ -41,7 +43,9 @@ func NewMosaicService() *MosaicService {
 	}
 }
 
-func (svc *MosaicService) Create(path, s3Key, s3Bucket string) (*model.Metadata, error) {
+// Create builds the mosaic of an image, or of a page of a document when page
+// is set, in which case the page is also listed in the root metadata.
+func (svc *MosaicService) Create(path, s3Key, s3Bucket string, page *int) (*model.Metadata, error) {
 	tmpDir := filepath.Join(os.TempDir(), helper.NewID())
 	defer func() {
 		if err := os.RemoveAll(tmpDir); err != nil {
@@ -49,8 +53,11 @@ func (svc *MosaicService) Create(path, s3Key, s3Bucket string) (*model.Metadata,
 		}
 	}()
 	metadata, err := builder.NewMosaicBuilder(builder.MosaicBuilderOptions{
-		File:            path,
-		OutputDirectory: tmpDir,
+		File:               path,
+		OutputDirectory:    tmpDir,
+		Page:               page,
+		InMemoryPixelLimit: svc.config.Limits.InMemoryMegapixels * 1_000_000,
+		CommandTimeout:     svc.config.Limits.GetExternalCommandTimeout,
 	}).Build()
 	if err != nil {
 		return nil, err
@@ -78,14 +85,57 @@ func (svc *MosaicService) Create(path, s3Key, s3Bucket string) (*model.Metadata,
 		if err != nil {
 			return nil, err
 		}
-		destinationKey := filepath.Join(s3Key, "mosaic", relativePath)
+		destinationKey := filepath.Join(svc.prefix(s3Key, page), relativePath)
 		if err := svc.s3.PutFile(destinationKey, file, contentType, s3Bucket, putOptions); err != nil {
 			return nil, err
 		}
 	}
+	if page != nil {
+		if err := svc.addPage(s3Bucket, s3Key, *metadata); err != nil {
+			return nil, err
+		}
+	}
 	return metadata, nil
 }
 
+// addPage replaces or appends the page in the root metadata, keeping the pages
+// sorted by index.
+func (svc *MosaicService) addPage(s3Bucket, s3Key string, page model.Metadata) error {
+	root, err := svc.GetMetadata(s3Bucket, s3Key, nil)
+	if err != nil {
+		root = &model.Metadata{}
+	}
+	pages := make([]model.Metadata, 0, len(root.Pages)+1)
+	for _, p := range root.Pages {
+		if p.Page != nil && *p.Page != *page.Page {
+			pages = append(pages, p)
+		}
+	}
+	pages = append(pages, page)
+	sort.Slice(pages, func(i, j int) bool {
+		return *pages[i].Page < *pages[j].Page
+	})
+	root.Pages = pages
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	return svc.s3.PutText(svc.metadataObjectName(s3Key, nil), string(b), "application/json", s3Bucket, minio.PutObjectOptions{})
+}
+
+// prefix returns where the mosaic is stored, the mosaics of the pages of a
+// document are nested under the mosaic of the document.
+func (svc *MosaicService) prefix(s3Key string, page *int) string {
+	if page == nil {
+		return filepath.Join(s3Key, "mosaic")
+	}
+	return filepath.Join(s3Key, "mosaic", "pages", strconv.Itoa(*page))
+}
+
+func (svc *MosaicService) metadataObjectName(s3Key string, page *int) string {
+	return filepath.Join(svc.prefix(s3Key, page), "mosaic.json")
+}
+
 func (svc *MosaicService) Delete(s3Bucket, s3Key string) error {
 	listOptions := minio.ListObjectsOptions{
 		Prefix:    filepath.Join(s3Key, "mosaic"),
@@ -103,8 +153,8 @@ func (svc *MosaicService) Delete(s3Bucket, s3Key string) error {
 	return nil
 }
 
-func (svc *MosaicService) GetTileBuffer(s3Bucket, s3Key string, zoomLevel, row int, column int, extension string) (*bytes.Buffer, *string, error) {
-	objectName := filepath.Join(s3Key, "mosaic", fmt.Sprintf("%d/%dx%d.%s", zoomLevel, row, column, extension))
+func (svc *MosaicService) GetTileBuffer(s3Bucket, s3Key string, page *int, zoomLevel, row int, column int, extension string) (*bytes.Buffer, *string, error) {
+	objectName := filepath.Join(svc.prefix(s3Key, page), fmt.Sprintf("%d/%dx%d.%s", zoomLevel, row, column, extension))
 	buf := new(bytes.Buffer)
 	if _, err := svc.s3.GetObjectWithBuffer(objectName, s3Bucket, buf, minio.GetObjectOptions{}); err != nil {
 		return nil, nil, errorpkg.NewResourceNotFoundError(err)
@@ -116,9 +166,8 @@ func (svc *MosaicService) GetTileBuffer(s3Bucket, s3Key string, zoomLevel, row i
 	return buf, &contentType, nil
 }
 
-func (svc *MosaicService) GetMetadata(s3Bucket, s3Key string) (*model.Metadata, error) {
-	objectName := filepath.Join(s3Key, "mosaic", "mosaic.json")
-	text, err := svc.s3.GetText(objectName, s3Bucket, minio.GetObjectOptions{})
+func (svc *MosaicService) GetMetadata(s3Bucket, s3Key string, page *int) (*model.Metadata, error) {
+	text, err := svc.s3.GetText(svc.metadataObjectName(s3Key, page), s3Bucket, minio.GetObjectOptions{})
 	if err != nil {
 		return nil, errorpkg.NewResourceNotFoundError(err)
 	}
@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type FileCache struct {
-	redis     *infra.RedisManager
-	fileRepo  *repo.FileRepo
-	keyPrefix string
+	store    *store
+	fileRepo *repo.FileRepo
 }
 
 func NewFileCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *FileCache {
 	return &FileCache{
-		redis:     infra.NewRedisManager(redis),
-		fileRepo:  repo.NewFileRepo(postgres, environment),
-		keyPrefix: "file:",
+		store:    newStore("file", redis),
+		fileRepo: repo.NewFileRepo(postgres, environment),
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *FileCache) Set(file model.File) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+file.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(file.GetID(), string(b))
 	return nil
 }
 
 func (c *FileCache) Get(id string) (model.File, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	res := repo.NewFileModel()
-	if err = json.Unmarshal([]byte(value), &res); err != nil {
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetMany returns the files of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *FileCache) GetMany(ids []string) ([]model.File, error) {
+	return getMany(c.store, ids, repo.NewFileModel, c.fileRepo.FindMany)
+}
+
 func (c *FileCache) GetOrNil(id string) model.File {
 	res, err := c.Get(id)
 	if err != nil {
@@ -88,8 +88,6 @@ func (c *FileCache) RefreshWithExisting(file model.File, userID string) (model.F
 }
 
 func (c *FileCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return nil
-	}
+	c.store.delete(id)
 	return nil
 }
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package cache
+
+import (
+	"encoding/json"
+)
+
+// GetManyChunkSize is the number of IDs read from Redis, and then from the
+// database for those which missed, at once.
+const GetManyChunkSize = 1000
+
+type identifiable interface {
+	GetID() string
+}
+
+// getMany returns the models of the IDs, in the same order, skipping the IDs
+// which exist neither in the cache nor in the database. The misses are read
+// from the database with a single query per chunk, and written back.
+func getMany[T identifiable](
+	s *store,
+	ids []string,
+	newModel func() T,
+	findMany func(ids []string) ([]T, error),
+) ([]T, error) {
+	found := make(map[string]T, len(ids))
+	for start := 0; start < len(ids); start += GetManyChunkSize {
+		chunk := ids[start:min(start+GetManyChunkSize, len(ids))]
+		values, missing := s.getMany(chunk)
+		for id, value := range values {
+			m := newModel()
+			if err := json.Unmarshal([]byte(value), &m); err != nil {
+				return nil, err
+			}
+			found[id] = m
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		models, err := findMany(missing)
+		if err != nil {
+			return nil, err
+		}
+		fill := make(map[string]string, len(models))
+		for _, m := range models {
+			b, err := json.Marshal(m)
+			if err != nil {
+				return nil, err
+			}
+			fill[m.GetID()] = string(b)
+			found[m.GetID()] = m
+		}
+		s.fill(fill)
+	}
+	res := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if m, ok := found[id]; ok {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
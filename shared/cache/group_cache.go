@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type GroupCache struct {
-	redis     *infra.RedisManager
+	store     *store
 	groupRepo *repo.GroupRepo
-	keyPrefix string
 }
 
 func NewGroupCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *GroupCache {
 	return &GroupCache{
-		redis:     infra.NewRedisManager(redis),
+		store:     newStore("group", redis),
 		groupRepo: repo.NewGroupRepo(postgres, environment),
-		keyPrefix: "group:",
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *GroupCache) Set(group model.Group) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+group.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(group.GetID(), string(b))
 	return nil
 }
 
 func (c *GroupCache) Get(id string) (model.Group, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	res := repo.NewGroupModel()
-	if err = json.Unmarshal([]byte(value), &res); err != nil {
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetMany returns the groups of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *GroupCache) GetMany(ids []string) ([]model.Group, error) {
+	return getMany(c.store, ids, repo.NewGroupModel, c.groupRepo.FindMany)
+}
+
 func (c *GroupCache) GetOrNil(id string) model.Group {
 	res, err := c.Get(id)
 	if err != nil {
@@ -77,8 +77,6 @@ func (c *GroupCache) Refresh(id string) (model.Group, error) {
 }
 
 func (c *GroupCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return err
-	}
+	c.store.delete(id)
 	return nil
 }
@@ -0,0 +1,162 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+)
+
+// localMaxEntries bounds the memory of a local store, it is emptied when full.
+const localMaxEntries = 10_000
+
+var (
+	localStores       sync.Map
+	listenerOnce      sync.Once
+	listenerConnected atomic.Bool
+)
+
+type localEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// localStore is the copy of the entries of a kind kept in the memory of the
+// replica, it is shared by all the caches of that kind in the process.
+type localStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]localEntry
+}
+
+func getLocalStore(kind string, ttl time.Duration) *localStore {
+	res, _ := localStores.LoadOrStore(kind, &localStore{
+		ttl:     ttl,
+		entries: make(map[string]localEntry),
+	})
+	return res.(*localStore)
+}
+
+// get only answers while the replica receives the invalidations, otherwise it
+// could return an entry that another replica changed meanwhile.
+func (l *localStore) get(id string) (string, bool) {
+	if l.ttl == 0 || !listenerConnected.Load() || !isRedisAvailable() {
+		return "", false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[id]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(l.entries, id)
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (l *localStore) set(id string, value string) {
+	if l.ttl == 0 || !listenerConnected.Load() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) >= localMaxEntries {
+		l.entries = make(map[string]localEntry)
+	}
+	l.entries[id] = localEntry{value: value, expiresAt: time.Now().Add(l.ttl)}
+}
+
+func (l *localStore) delete(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, id)
+}
+
+func (l *localStore) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make(map[string]localEntry)
+}
+
+func clearLocalStores() {
+	localStores.Range(func(_, value any) bool {
+		value.(*localStore).clear()
+		return true
+	})
+}
+
+func startInvalidationListener(redisConfig config.RedisConfig) {
+	listenerOnce.Do(func() {
+		go listenInvalidations(infra.NewRedisManager(redisConfig))
+	})
+}
+
+// listenInvalidations evicts the local copies that other replicas changed. The
+// invalidations sent while disconnected are lost, so the local copies are
+// dropped, and left unused, until the subscription is back.
+func listenInvalidations(mgr *infra.RedisManager) {
+	for {
+		sub, err := mgr.Subscribe(invalidationChannel)
+		if err != nil {
+			logger.GetLogger().Error(err)
+			time.Sleep(redisRetryInterval)
+			continue
+		}
+		clearLocalStores()
+		listenerConnected.Store(true)
+		for {
+			msg, err := sub.Receive(context.Background())
+			if err != nil {
+				logger.GetLogger().Error(err)
+				break
+			}
+			switch m := msg.(type) {
+			case *redis.Message:
+				evict(m.Payload)
+			case *redis.Subscription:
+				/* Subscribed again after a reconnection, invalidations may have been missed */
+				clearLocalStores()
+			}
+		}
+		listenerConnected.Store(false)
+		clearLocalStores()
+		if err := sub.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		time.Sleep(redisRetryInterval)
+	}
+}
+
+func evict(payload string) {
+	var inv invalidation
+	if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	if inv.Origin == origin {
+		return
+	}
+	if value, ok := localStores.Load(inv.Kind); ok {
+		value.(*localStore).delete(inv.ID)
+		getKindMetrics(inv.Kind).evictions.Add(1)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var kindsMetrics sync.Map
+
+type kindMetrics struct {
+	localHits atomic.Int64
+	hits      atomic.Int64
+	misses    atomic.Int64
+	bypasses  atomic.Int64
+	errors    atomic.Int64
+	evictions atomic.Int64
+}
+
+func getKindMetrics(kind string) *kindMetrics {
+	res, _ := kindsMetrics.LoadOrStore(kind, &kindMetrics{})
+	return res.(*kindMetrics)
+}
+
+// Metrics counts what happened to the lookups of a kind of entry since the
+// process started.
+type Metrics struct {
+	Kind string
+	// LocalHits were answered from the memory of the replica.
+	LocalHits int64
+	// Hits were answered from Redis.
+	Hits   int64
+	Misses int64
+	// Bypasses went straight to the database because Redis was unavailable.
+	Bypasses int64
+	Errors   int64
+	// Evictions are the local copies dropped because another replica changed them.
+	Evictions int64
+}
+
+func GetMetrics() []Metrics {
+	var res []Metrics
+	kindsMetrics.Range(func(key, value any) bool {
+		m := value.(*kindMetrics)
+		res = append(res, Metrics{
+			Kind:      key.(string),
+			LocalHits: m.localHits.Load(),
+			Hits:      m.hits.Load(),
+			Misses:    m.misses.Load(),
+			Bypasses:  m.bypasses.Load(),
+			Errors:    m.errors.Load(),
+			Evictions: m.evictions.Load(),
+		})
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Kind < res[j].Kind
+	})
+	return res
+}
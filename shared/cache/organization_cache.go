@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type OrganizationCache struct {
-	redis     *infra.RedisManager
-	orgRepo   *repo.OrganizationRepo
-	keyPrefix string
+	store   *store
+	orgRepo *repo.OrganizationRepo
 }
 
 func NewOrganizationCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *OrganizationCache {
 	return &OrganizationCache{
-		redis:     infra.NewRedisManager(redis),
-		orgRepo:   repo.NewOrganizationRepo(postgres, environment),
-		keyPrefix: "organization:",
+		store:   newStore("organization", redis),
+		orgRepo: repo.NewOrganizationRepo(postgres, environment),
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *OrganizationCache) Set(organization model.Organization) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+organization.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(organization.GetID(), string(b))
 	return nil
 }
 
 func (c *OrganizationCache) Get(id string) (model.Organization, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	res := repo.NewOrganizationModel()
-	if err = json.Unmarshal([]byte(value), &res); err != nil {
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetMany returns the organizations of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *OrganizationCache) GetMany(ids []string) ([]model.Organization, error) {
+	return getMany(c.store, ids, repo.NewOrganizationModel, c.orgRepo.FindMany)
+}
+
 func (c *OrganizationCache) GetOrNil(id string) model.Organization {
 	res, err := c.Get(id)
 	if err != nil {
@@ -77,8 +77,6 @@ func (c *OrganizationCache) Refresh(id string) (model.Organization, error) {
 }
 
 func (c *OrganizationCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return err
-	}
+	c.store.delete(id)
 	return nil
 }
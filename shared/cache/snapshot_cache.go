@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type SnapshotCache struct {
-	redis        *infra.RedisManager
+	store        *store
 	snapshotRepo *repo.SnapshotRepo
-	keyPrefix    string
 }
 
 func NewSnapshotCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *SnapshotCache {
 	return &SnapshotCache{
-		redis:        infra.NewRedisManager(redis),
+		store:        newStore("snapshot", redis),
 		snapshotRepo: repo.NewSnapshotRepo(postgres, environment),
-		keyPrefix:    "snapshot:",
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *SnapshotCache) Set(snapshot model.Snapshot) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+snapshot.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(snapshot.GetID(), string(b))
 	return nil
 }
 
 func (c *SnapshotCache) Get(id string) (model.Snapshot, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	res := repo.NewSnapshotModel()
-	if err = json.Unmarshal([]byte(value), &res); err != nil {
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetMany returns the snapshots of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *SnapshotCache) GetMany(ids []string) ([]model.Snapshot, error) {
+	return getMany(c.store, ids, repo.NewSnapshotModel, c.snapshotRepo.FindMany)
+}
+
 func (c *SnapshotCache) GetOrNil(id string) model.Snapshot {
 	res, err := c.Get(id)
 	if err != nil {
@@ -77,8 +77,6 @@ func (c *SnapshotCache) Refresh(id string) (model.Snapshot, error) {
 }
 
 func (c *SnapshotCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return nil
-	}
+	c.store.delete(id)
 	return nil
 }
@@ -0,0 +1,294 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+)
+
+// KeyVersion is part of every key, it is bumped whenever the serialized form of
+// a cached model changes, so that the entries written by a previous release are
+// ignored instead of being decoded into the new models.
+const KeyVersion = "v1"
+
+const (
+	invalidationChannel = "cache:invalidate"
+	// redisRetryInterval is how long Redis is bypassed after a failure, so that
+	// an outage costs a timeout per interval rather than one per lookup.
+	redisRetryInterval = 5 * time.Second
+)
+
+var (
+	// origin identifies this replica in the invalidations it broadcasts.
+	origin         = helper.NewID()
+	redisDownUntil atomic.Int64
+	// pendingEvictions holds the entries that could not be deleted from Redis
+	// during an outage, they are deleted as soon as Redis is back, otherwise the
+	// previous version of an entry changed meanwhile would be served until its TTL.
+	pendingEvictions = &evictionQueue{entries: make(map[string]invalidation)}
+)
+
+type invalidation struct {
+	Origin string `json:"origin"`
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+}
+
+// store keeps the serialized entries of a kind of model in Redis, with a short
+// lived copy in memory. Redis errors are never returned, a lookup that cannot
+// reach Redis is reported as a miss, so that callers fall through to the repos.
+type store struct {
+	kind    string
+	redis   *infra.RedisManager
+	ttl     time.Duration
+	local   *localStore
+	metrics *kindMetrics
+}
+
+func newStore(kind string, redisConfig config.RedisConfig) *store {
+	s := &store{
+		kind:    kind,
+		redis:   infra.NewRedisManager(redisConfig),
+		ttl:     redisConfig.Cache.TTL,
+		local:   getLocalStore(kind, redisConfig.Cache.LocalTTL),
+		metrics: getKindMetrics(kind),
+	}
+	if redisConfig.Cache.LocalTTL > 0 {
+		startInvalidationListener(redisConfig)
+	}
+	return s
+}
+
+func (s *store) get(id string) (string, bool) {
+	if value, ok := s.local.get(id); ok {
+		s.metrics.localHits.Add(1)
+		return value, true
+	}
+	if !isRedisAvailable() {
+		s.metrics.bypasses.Add(1)
+		return "", false
+	}
+	s.flushEvictions()
+	value, err := s.redis.Get(s.key(id))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.fail(err)
+		}
+		s.metrics.misses.Add(1)
+		return "", false
+	}
+	s.metrics.hits.Add(1)
+	s.local.set(id, value)
+	return value, true
+}
+
+// getMany returns the entries found, keyed by ID, and the IDs that missed. The
+// IDs that are not in memory are read from Redis in a single round-trip.
+func (s *store) getMany(ids []string) (map[string]string, []string) {
+	res := make(map[string]string, len(ids))
+	var remaining []string
+	for _, id := range ids {
+		if value, ok := s.local.get(id); ok {
+			s.metrics.localHits.Add(1)
+			res[id] = value
+		} else {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		return res, nil
+	}
+	if !isRedisAvailable() {
+		s.metrics.bypasses.Add(int64(len(remaining)))
+		return res, remaining
+	}
+	s.flushEvictions()
+	keys := make([]string, len(remaining))
+	for i, id := range remaining {
+		keys[i] = s.key(id)
+	}
+	values, err := s.redis.MGet(keys...)
+	if err != nil {
+		s.fail(err)
+		return res, remaining
+	}
+	var missing []string
+	for i, id := range remaining {
+		if value, ok := values[i].(string); ok {
+			s.metrics.hits.Add(1)
+			res[id] = value
+			s.local.set(id, value)
+		} else {
+			s.metrics.misses.Add(1)
+			missing = append(missing, id)
+		}
+	}
+	return res, missing
+}
+
+// fill writes the entries that missed once read from the database, it doesn't
+// broadcast, since no replica holds a newer copy of an entry missing in Redis.
+// The entries written in the meantime are left untouched, as they might be
+// newer than what was read from the database.
+func (s *store) fill(values map[string]string) {
+	if len(values) == 0 || !isRedisAvailable() {
+		return
+	}
+	s.flushEvictions()
+	keyed := make(map[string]interface{}, len(values))
+	ids := make(map[string]string, len(values))
+	for id, value := range values {
+		keyed[s.key(id)] = value
+		ids[s.key(id)] = id
+	}
+	written, err := s.redis.SetManyNX(keyed, s.ttl)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	for _, key := range written {
+		s.local.set(ids[key], values[ids[key]])
+	}
+}
+
+// set writes the entry through to Redis, and tells the other replicas to drop
+// their local copy, which is now outdated. While Redis is bypassed, the entry
+// is evicted instead, see evict.
+func (s *store) set(id string, value string) {
+	s.local.delete(id)
+	if !isRedisAvailable() {
+		s.metrics.bypasses.Add(1)
+		s.evict(id)
+		return
+	}
+	s.flushEvictions()
+	if err := s.redis.SetWithExpiry(s.key(id), value, s.ttl); err != nil {
+		s.fail(err)
+		s.evict(id)
+		return
+	}
+	s.local.set(id, value)
+	s.broadcast(id)
+}
+
+func (s *store) delete(id string) {
+	s.local.delete(id)
+	if !isRedisAvailable() {
+		s.metrics.bypasses.Add(1)
+	} else {
+		s.flushEvictions()
+	}
+	s.evict(id)
+}
+
+// evict deletes the entry from Redis, even while Redis is bypassed, since the
+// failure that started the bypass may have been transient, and the entry left
+// there would be served to every replica once it's back. If Redis can't be
+// reached, the entry is queued and deleted by the first store that reaches it.
+func (s *store) evict(id string) {
+	if err := s.redis.Delete(s.key(id)); err != nil {
+		s.fail(err)
+		pendingEvictions.add(s.key(id), invalidation{Origin: origin, Kind: s.kind, ID: id})
+		return
+	}
+	s.broadcast(id)
+}
+
+func (s *store) flushEvictions() {
+	entries := pendingEvictions.take()
+	for key, inv := range entries {
+		if err := s.redis.Delete(key); err != nil {
+			s.fail(err)
+			pendingEvictions.addAll(entries)
+			return
+		}
+		delete(entries, key)
+		s.publish(inv)
+	}
+}
+
+func (s *store) broadcast(id string) {
+	s.publish(invalidation{Origin: origin, Kind: s.kind, ID: id})
+}
+
+func (s *store) publish(inv invalidation) {
+	b, err := json.Marshal(inv)
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return
+	}
+	if err := s.redis.Publish(invalidationChannel, string(b)); err != nil {
+		s.fail(err)
+	}
+}
+
+func (s *store) key(id string) string {
+	return s.kind + ":" + KeyVersion + ":" + id
+}
+
+func (s *store) fail(err error) {
+	s.metrics.errors.Add(1)
+	redisDownUntil.Store(time.Now().Add(redisRetryInterval).UnixNano())
+	logger.GetLogger().Errorw("cache: Redis is unavailable, falling through to the database", "kind", s.kind, "error", err)
+}
+
+func isRedisAvailable() bool {
+	return time.Now().UnixNano() >= redisDownUntil.Load()
+}
+
+// evictionQueue is shared by the stores of every kind, its entries are keyed by
+// the Redis key of the entry to delete.
+type evictionQueue struct {
+	mu      sync.Mutex
+	size    atomic.Int64
+	entries map[string]invalidation
+}
+
+func (q *evictionQueue) add(key string, inv invalidation) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries[key] = inv
+	q.size.Store(int64(len(q.entries)))
+}
+
+func (q *evictionQueue) addAll(entries map[string]invalidation) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, inv := range entries {
+		q.entries[key] = inv
+	}
+	q.size.Store(int64(len(q.entries)))
+}
+
+// take empties the queue and returns what it held, without locking when it is
+// already empty, which is the case outside of outages.
+func (q *evictionQueue) take() map[string]invalidation {
+	if q.size.Load() == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res := q.entries
+	q.entries = make(map[string]invalidation)
+	q.size.Store(0)
+	return res
+}
@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type TaskCache struct {
-	redis     *infra.RedisManager
-	taskRepo  *repo.TaskRepo
-	keyPrefix string
+	store    *store
+	taskRepo *repo.TaskRepo
 }
 
 func NewTaskCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *TaskCache {
 	return &TaskCache{
-		redis:     infra.NewRedisManager(redis),
-		taskRepo:  repo.NewTaskRepo(postgres, environment),
-		keyPrefix: "task:",
+		store:    newStore("task", redis),
+		taskRepo: repo.NewTaskRepo(postgres, environment),
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *TaskCache) Set(file model.Task) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+file.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(file.GetID(), string(b))
 	return nil
 }
 
 func (c *TaskCache) Get(id string) (model.Task, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	task := repo.NewTaskModel()
-	if err = json.Unmarshal([]byte(value), &task); err != nil {
+	if err := json.Unmarshal([]byte(value), &task); err != nil {
 		return nil, err
 	}
 	return task, nil
 }
 
+// GetMany returns the tasks of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *TaskCache) GetMany(ids []string) ([]model.Task, error) {
+	return getMany(c.store, ids, repo.NewTaskModel, c.taskRepo.FindMany)
+}
+
 func (c *TaskCache) GetOrNil(id string) model.Task {
 	res, err := c.Get(id)
 	if err != nil {
@@ -77,8 +77,6 @@ func (c *TaskCache) Refresh(id string) (model.Task, error) {
 }
 
 func (c *TaskCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return nil
-	}
+	c.store.delete(id)
 	return nil
 }
@@ -14,22 +14,19 @@ import (
 	"encoding/json"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
-	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
 )
 
 type WorkspaceCache struct {
-	redis         *infra.RedisManager
+	store         *store
 	workspaceRepo *repo.WorkspaceRepo
-	keyPrefix     string
 }
 
 func NewWorkspaceCache(postgres config.PostgresConfig, redis config.RedisConfig, environment config.EnvironmentConfig) *WorkspaceCache {
 	return &WorkspaceCache{
-		redis:         infra.NewRedisManager(redis),
+		store:         newStore("workspace", redis),
 		workspaceRepo: repo.NewWorkspaceRepo(postgres, environment),
-		keyPrefix:     "workspace:",
 	}
 }
 
@@ -38,25 +35,28 @@ func (c *WorkspaceCache) Set(workspace model.Workspace) error {
 	if err != nil {
 		return err
 	}
-	err = c.redis.Set(c.keyPrefix+workspace.GetID(), string(b))
-	if err != nil {
-		return err
-	}
+	c.store.set(workspace.GetID(), string(b))
 	return nil
 }
 
 func (c *WorkspaceCache) Get(id string) (model.Workspace, error) {
-	value, err := c.redis.Get(c.keyPrefix + id)
-	if err != nil {
+	value, ok := c.store.get(id)
+	if !ok {
 		return c.Refresh(id)
 	}
 	res := repo.NewWorkspaceModel()
-	if err = json.Unmarshal([]byte(value), &res); err != nil {
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetMany returns the workspaces of the IDs in the same order, with a single Redis
+// round-trip and a single query for the misses, IDs not found are skipped.
+func (c *WorkspaceCache) GetMany(ids []string) ([]model.Workspace, error) {
+	return getMany(c.store, ids, repo.NewWorkspaceModel, c.workspaceRepo.FindMany)
+}
+
 func (c *WorkspaceCache) GetOrNil(id string) model.Workspace {
 	res, err := c.Get(id)
 	if err != nil {
@@ -77,8 +77,6 @@ func (c *WorkspaceCache) Refresh(id string) (model.Workspace, error) {
 }
 
 func (c *WorkspaceCache) Delete(id string) error {
-	if err := c.redis.Delete(c.keyPrefix + id); err != nil {
-		return err
-	}
+	c.store.delete(id)
 	return nil
 }
@@ -86,6 +86,9 @@ type FileCreateFromS3Options struct {
 	ParentID    string
 	Name        string
 	S3Reference model.S3Reference
+	// Label and Note are set on the snapshot created from the upload.
+	Label *string
+	Note  *string
 }
 
 func (cl *FileClient) CreateFromS3(opts FileCreateFromS3Options) (*dto.File, error) {
@@ -105,6 +108,12 @@ func (cl *FileClient) CreateFromS3(opts FileCreateFromS3Options) (*dto.File, err
 		"content_type": []string{opts.S3Reference.ContentType},
 		"size":         []string{strconv.FormatInt(opts.S3Reference.Size, 10)},
 	}
+	if opts.Label != nil {
+		args.Set("label", *opts.Label)
+	}
+	if opts.Note != nil {
+		args.Set("note", *opts.Note)
+	}
 	req, err := http.NewRequest("POST",
 		cl.url+"/v3/files/create_from_s3?"+args.Encode(),
 		bytes.NewBuffer(b))
@@ -138,6 +147,9 @@ type FilePatchFromS3Options struct {
 	ID          string
 	Name        string
 	S3Reference model.S3Reference
+	// Label and Note are set on the snapshot created from the upload.
+	Label *string
+	Note  *string
 }
 
 func (cl *FileClient) PatchFromS3(opts FilePatchFromS3Options) (*dto.File, error) {
@@ -155,6 +167,12 @@ func (cl *FileClient) PatchFromS3(opts FilePatchFromS3Options) (*dto.File, error
 		"content_type": []string{opts.S3Reference.ContentType},
 		"size":         []string{strconv.FormatInt(opts.S3Reference.Size, 10)},
 	}
+	if opts.Label != nil {
+		args.Set("label", *opts.Label)
+	}
+	if opts.Note != nil {
+		args.Set("note", *opts.Note)
+	}
 	req, err := http.NewRequest("PATCH",
 		cl.url+"/v3/files/"+opts.ID+"/patch_from_s3?"+args.Encode(),
 		bytes.NewBuffer(b))
@@ -328,6 +346,72 @@ func (cl *FileClient) PatchName(id string, opts dto.FilePatchNameOptions) (*dto.
 	return &res, nil
 }
 
+// ListPropertiesMany returns the properties of the files keyed by file ID, the
+// files the user cannot view are left out.
+func (cl *FileClient) ListPropertiesMany(opts dto.FileListPropertiesManyOptions) (map[string][]dto.FileProperty, error) {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v3/files/properties", cl.url), bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cl.token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err.Error())
+		}
+	}(resp.Body)
+	b, err = JsonResponseOrError(resp)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string][]dto.FileProperty
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (cl *FileClient) PatchProperties(id string, opts dto.FilePatchPropertiesOptions) (*dto.FilePatchPropertiesResult, error) {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("PATCH", fmt.Sprintf("%s/v3/files/%s/properties", cl.url, id), bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cl.token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err.Error())
+		}
+	}(resp.Body)
+	b, err = JsonResponseOrError(resp)
+	if err != nil {
+		return nil, err
+	}
+	var res dto.FilePatchPropertiesResult
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
 func (cl *FileClient) DeleteOne(id string) error {
 	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/v3/files/%s", cl.url, id), nil)
 	if err != nil {
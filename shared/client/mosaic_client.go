@@ -17,7 +17,9 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/kouprlabs/voltaserve/shared/dto"
 	"github.com/kouprlabs/voltaserve/shared/logger"
@@ -37,6 +39,8 @@ type MosaicCreateOptions struct {
 	Path     string
 	S3Key    string
 	S3Bucket string
+	// Page is set when the file is a page rendered from a document.
+	Page *int
 }
 
 func (cl *MosaicClient) Create(opts MosaicCreateOptions) (*dto.MosaicMetadata, error) {
@@ -64,6 +68,11 @@ func (cl *MosaicClient) Create(opts MosaicCreateOptions) (*dto.MosaicMetadata, e
 	if err = mw.WriteField("s3_bucket", opts.S3Bucket); err != nil {
 		return nil, err
 	}
+	if opts.Page != nil {
+		if err = mw.WriteField("page", strconv.Itoa(*opts.Page)); err != nil {
+			return nil, err
+		}
+	}
 	if err := mw.Close(); err != nil {
 		return nil, err
 	}
@@ -96,10 +105,11 @@ func (cl *MosaicClient) Create(opts MosaicCreateOptions) (*dto.MosaicMetadata, e
 type MosaicGetMetadataOptions struct {
 	S3Key    string `json:"s3Key"`
 	S3Bucket string `json:"s3Bucket"`
+	Page     *int   `json:"page,omitempty"`
 }
 
 func (cl *MosaicClient) GetMetadata(opts MosaicGetMetadataOptions) (*dto.MosaicMetadata, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v3/mosaics/%s/%s/metadata", cl.url, opts.S3Bucket, opts.S3Key), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/metadata", cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page)), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -150,14 +160,154 @@ type MosaicDownloadTileOptions struct {
 	Row       int    `json:"row"`
 	Column    int    `json:"column"`
 	Extension string `json:"extension"`
+	Page      *int   `json:"page,omitempty"`
 }
 
 func (cl *MosaicClient) DownloadTileBuffer(opts MosaicDownloadTileOptions) ([]byte, error) {
 	req, err := http.NewRequest(
 		"GET",
 		fmt.Sprintf(
-			"%s/v3/mosaics/%s/%s/zoom_level/%d/row/%d/column/%d/extension/%s",
-			cl.url, opts.S3Bucket, opts.S3Key, opts.ZoomLevel, opts.Row, opts.Column, opts.Extension,
+			"%s/zoom_level/%d/row/%d/column/%d/extension/%s",
+			cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page), opts.ZoomLevel, opts.Row, opts.Column, opts.Extension,
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	return ByteResponseOrError(resp)
+}
+
+type MosaicGetIIIFInfoOptions struct {
+	S3Key    string `json:"s3Key"`
+	S3Bucket string `json:"s3Bucket"`
+	Page     *int   `json:"page,omitempty"`
+	// ID is the base URI of the image service, as seen by the clients.
+	ID string `json:"id"`
+}
+
+func (cl *MosaicClient) GetIIIFInfo(opts MosaicGetIIIFInfoOptions) (*dto.MosaicIIIFInfo, error) {
+	req, err := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s/iiif/info.json?id=%s", cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page), url.QueryEscape(opts.ID)),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	b, err := JsonResponseOrError(resp)
+	if err != nil {
+		return nil, err
+	}
+	var res dto.MosaicIIIFInfo
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type MosaicDownloadIIIFImageOptions struct {
+	S3Key    string `json:"s3Key"`
+	S3Bucket string `json:"s3Bucket"`
+	Page     *int   `json:"page,omitempty"`
+	Region   string `json:"region"`
+	Size     string `json:"size"`
+	Rotation string `json:"rotation"`
+	Quality  string `json:"quality"`
+	Format   string `json:"format"`
+}
+
+func (cl *MosaicClient) DownloadIIIFImageBuffer(opts MosaicDownloadIIIFImageOptions) ([]byte, error) {
+	req, err := http.NewRequest(
+		"GET",
+		fmt.Sprintf(
+			"%s/iiif/%s/%s/%s/%s.%s",
+			cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page),
+			url.PathEscape(opts.Region),
+			url.PathEscape(opts.Size),
+			url.PathEscape(opts.Rotation),
+			url.PathEscape(opts.Quality),
+			url.PathEscape(opts.Format),
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	return ByteResponseOrError(resp)
+}
+
+type MosaicGetDeepZoomDescriptorOptions struct {
+	S3Key    string `json:"s3Key"`
+	S3Bucket string `json:"s3Bucket"`
+	Page     *int   `json:"page,omitempty"`
+}
+
+// GetDeepZoomDescriptorBuffer returns the DZI descriptor, which is XML.
+func (cl *MosaicClient) GetDeepZoomDescriptorBuffer(opts MosaicGetDeepZoomDescriptorOptions) ([]byte, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/deep_zoom.dzi", cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page)), nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	return ByteResponseOrError(resp)
+}
+
+type MosaicDownloadDeepZoomTileOptions struct {
+	S3Key    string `json:"s3Key"`
+	S3Bucket string `json:"s3Bucket"`
+	Page     *int   `json:"page,omitempty"`
+	Level    int    `json:"level"`
+	Column   int    `json:"column"`
+	Row      int    `json:"row"`
+	Format   string `json:"format"`
+}
+
+func (cl *MosaicClient) DownloadDeepZoomTileBuffer(opts MosaicDownloadDeepZoomTileOptions) ([]byte, error) {
+	req, err := http.NewRequest(
+		"GET",
+		fmt.Sprintf(
+			"%s/deep_zoom_files/%d/%d_%d.%s",
+			cl.mosaicURL(opts.S3Bucket, opts.S3Key, opts.Page), opts.Level, opts.Column, opts.Row, url.PathEscape(opts.Format),
 		),
 		nil,
 	)
@@ -176,3 +326,12 @@ func (cl *MosaicClient) DownloadTileBuffer(opts MosaicDownloadTileOptions) ([]by
 	}(resp.Body)
 	return ByteResponseOrError(resp)
 }
+
+// mosaicURL returns the URL of the mosaic of an image, or of the mosaic of a page
+// of a document when page is set.
+func (cl *MosaicClient) mosaicURL(s3Bucket, s3Key string, page *int) string {
+	if page == nil {
+		return fmt.Sprintf("%s/v3/mosaics/%s/%s", cl.url, s3Bucket, s3Key)
+	}
+	return fmt.Sprintf("%s/v3/mosaics/%s/%s/pages/%d", cl.url, s3Bucket, s3Key, *page)
+}
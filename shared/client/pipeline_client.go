@@ -21,6 +21,7 @@ import (
 
 type PipelineClient interface {
 	Run(opts *dto.PipelineRunOptions) error
+	Cancel(opts *dto.PipelineCancelOptions) error
 }
 
 func NewPipelineClient(url string, isTest bool) PipelineClient {
@@ -59,6 +60,24 @@ func (cl *pipelineClient) Run(opts *dto.PipelineRunOptions) error {
 	return SuccessfulResponseOrError(resp)
 }
 
+func (cl *pipelineClient) Cancel(opts *dto.PipelineCancelOptions) error {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v3/pipelines/cancel", cl.url), bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	return SuccessfulResponseOrError(resp)
+}
+
 type mockPipelineClient struct{}
 
 func newMockPipelineClient() *mockPipelineClient {
@@ -68,3 +87,7 @@ func newMockPipelineClient() *mockPipelineClient {
 func (m *mockPipelineClient) Run(_ *dto.PipelineRunOptions) error {
 	return nil
 }
+
+func (m *mockPipelineClient) Cancel(_ *dto.PipelineCancelOptions) error {
+	return nil
+}
@@ -94,3 +94,22 @@ func (cl *SnapshotClient) Patch(id string, opts dto.SnapshotPatchOptions) (*dto.
 	}
 	return &res, nil
 }
+
+// Embed chunks and embeds the text of the snapshot for the semantic search.
+func (cl *SnapshotClient) Embed(id string) error {
+	req, err := http.NewRequest(
+		"POST",
+		fmt.Sprintf("%s/v3/snapshots/%s/embed?api_key=%s", cl.url, id, cl.apiKey),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	return SuccessfulResponseOrError(resp)
+}
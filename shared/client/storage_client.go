@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+)
+
+type StorageClient struct {
+	url    string
+	apiKey string
+	token  *dto.Token
+}
+
+func NewStorageClient(token *dto.Token, url string, apiKey string) *StorageClient {
+	return &StorageClient{
+		token:  token,
+		url:    url,
+		apiKey: apiKey,
+	}
+}
+
+func (cl *StorageClient) GetWorkspaceUsage(id string) (*dto.StorageUsage, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v3/storage/workspace_usage?id=%s", cl.url, url.QueryEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cl.token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	c := &http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err.Error())
+		}
+	}(resp.Body)
+	b, err := JsonResponseOrError(resp)
+	if err != nil {
+		return nil, err
+	}
+	var res dto.StorageUsage
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
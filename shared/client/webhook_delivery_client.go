@@ -0,0 +1,174 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kouprlabs/voltaserve/shared/logger"
+)
+
+const (
+	WebhookSignatureHeader = "X-Voltaserve-Signature"
+	WebhookEventHeader     = "X-Voltaserve-Event"
+	WebhookDeliveryHeader  = "X-Voltaserve-Delivery"
+	// WebhookResponseBodyLimit is how much of the response body is kept for the delivery log.
+	WebhookResponseBodyLimit = 1024
+)
+
+var (
+	ErrWebhookInsecureURL       = errors.New("webhook URL must use https")
+	ErrWebhookAddressNotAllowed = errors.New("webhook URL resolves to a loopback, link-local or private address")
+)
+
+type WebhookDeliveryClient struct {
+	client        *http.Client
+	allowInsecure bool
+}
+
+// NewWebhookDeliveryClient returns a client that only posts to public https
+// URLs, unless allowInsecure is set, which is meant for development only.
+func NewWebhookDeliveryClient(timeout time.Duration, allowInsecure bool) *WebhookDeliveryClient {
+	cl := &WebhookDeliveryClient{allowInsecure: allowInsecure}
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		/* The address is checked once resolved, right before connecting, so that DNS rebinding doesn't get through */
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return cl.checkIP(net.ParseIP(host))
+		},
+	}
+	cl.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			/* No proxy, it would be the one whose address is checked */
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: timeout,
+		},
+		/* A redirect could point to an address that is not allowed, the receiver must answer directly */
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return cl
+}
+
+// Validate checks that the URL uses https and that its host resolves to public
+// addresses only.
+func (cl *WebhookDeliveryClient) Validate(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && !(cl.allowInsecure && u.Scheme == "http") {
+		return ErrWebhookInsecureURL
+	}
+	if cl.allowInsecure {
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupIP(context.Background(), "ip", u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := cl.checkIP(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type WebhookDeliveryResponse struct {
+	StatusCode int
+	Body       string
+}
+
+// Deliver posts the payload to the URL, signed with the secret of the
+// subscription. A response is returned whatever its status code, an error
+// means that no response was received.
+func (cl *WebhookDeliveryClient) Deliver(url string, secret string, deliveryID string, eventType string, payload string) (*WebhookDeliveryResponse, error) {
+	if err := cl.Validate(url); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("User-Agent", "Voltaserve-Webhook")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, time.Now().Unix(), payload))
+	resp, err := cl.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	b, err := io.ReadAll(io.LimitReader(resp.Body, WebhookResponseBodyLimit))
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDeliveryResponse{
+		StatusCode: resp.StatusCode,
+		Body:       string(b),
+	}, nil
+}
+
+func (cl *WebhookDeliveryClient) checkIP(ip net.IP) error {
+	if cl.allowInsecure {
+		return nil
+	}
+	if ip == nil ||
+		ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the value of the signature header, in the form
+// "t=<timestamp>,v1=<signature>". The signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<payload>", receivers should recompute it and
+// reject old timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, payload string) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "." + payload))
+	return fmt.Sprintf("t=%s,v1=%s", t, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// EmbeddingProviderOpenAI works with any service that implements the
+	// embeddings endpoint of the OpenAI API, like Ollama, vLLM or LocalAI.
+	EmbeddingProviderOpenAI = "openai"
+)
+
+type EmbeddingConfig struct {
+	Enabled  bool
+	Provider string
+	URL      string
+	APIKey   string
+	Model    string
+	// Dimensions is the length of the vectors returned by the model, the
+	// search engine rejects vectors of any other length.
+	Dimensions int
+	Timeout    time.Duration
+}
+
+func ReadEmbedding(config *EmbeddingConfig) {
+	if os.Getenv("EMBEDDING_ENABLED") == "true" {
+		config.Enabled = true
+	}
+	config.Provider = EmbeddingProviderOpenAI
+	if len(os.Getenv("EMBEDDING_PROVIDER")) > 0 {
+		config.Provider = os.Getenv("EMBEDDING_PROVIDER")
+	}
+	config.URL = "https://api.openai.com/v1"
+	if len(os.Getenv("EMBEDDING_URL")) > 0 {
+		config.URL = os.Getenv("EMBEDDING_URL")
+	}
+	config.APIKey = os.Getenv("EMBEDDING_API_KEY")
+	config.Model = "text-embedding-3-small"
+	if len(os.Getenv("EMBEDDING_MODEL")) > 0 {
+		config.Model = os.Getenv("EMBEDDING_MODEL")
+	}
+	config.Dimensions = 1536
+	if len(os.Getenv("EMBEDDING_DIMENSIONS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("EMBEDDING_DIMENSIONS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Dimensions = int(v)
+	}
+	config.Timeout = 60 * time.Second
+	if len(os.Getenv("EMBEDDING_TIMEOUT_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("EMBEDDING_TIMEOUT_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.Timeout = time.Duration(v) * time.Second
+	}
+}
@@ -13,11 +13,15 @@ package config
 import "os"
 
 type EnvironmentConfig struct {
-	IsTest bool
+	IsTest        bool
+	IsDevelopment bool
 }
 
 func ReadEnvironment(config *EnvironmentConfig) {
 	if os.Getenv("TEST") == "true" {
 		config.IsTest = true
 	}
+	if os.Getenv("DEVELOPMENT") == "true" {
+		config.IsDevelopment = true
+	}
 }
@@ -13,6 +13,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type RedisConfig struct {
@@ -20,6 +21,16 @@ type RedisConfig struct {
 	Username string
 	Password string
 	DB       int
+	Cache    RedisCacheConfig
+}
+
+type RedisCacheConfig struct {
+	// TTL bounds how long an entry can outlive a change that no code path
+	// invalidated, zero keeps the entries until they are deleted.
+	TTL time.Duration
+	// LocalTTL is how long each replica keeps an entry in memory, evictions
+	// are broadcast to the replicas, zero disables the local copies.
+	LocalTTL time.Duration
 }
 
 func ReadRedis(config *RedisConfig) {
@@ -33,4 +44,24 @@ func ReadRedis(config *RedisConfig) {
 		}
 		config.DB = int(v)
 	}
+	readRedisCache(&config.Cache)
+}
+
+func readRedisCache(config *RedisCacheConfig) {
+	config.TTL = 10 * time.Minute
+	if len(os.Getenv("REDIS_CACHE_TTL_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("REDIS_CACHE_TTL_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.TTL = time.Duration(v) * time.Second
+	}
+	config.LocalTTL = 10 * time.Second
+	if len(os.Getenv("REDIS_CACHE_LOCAL_TTL_SECONDS")) > 0 {
+		v, err := strconv.ParseInt(os.Getenv("REDIS_CACHE_LOCAL_TTL_SECONDS"), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		config.LocalTTL = time.Duration(v) * time.Second
+	}
 }
@@ -0,0 +1,28 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+type CacheMetricsResult struct {
+	Data []*CacheMetrics `json:"data"`
+}
+
+// CacheMetrics counts the lookups of a kind of entry on the replica which
+// answered, since it started.
+type CacheMetrics struct {
+	Kind      string  `json:"kind"`
+	LocalHits int64   `json:"localHits"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Bypasses  int64   `json:"bypasses"`
+	Errors    int64   `json:"errors"`
+	Evictions int64   `json:"evictions"`
+	HitRatio  float64 `json:"hitRatio"`
+}
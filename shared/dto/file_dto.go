@@ -41,21 +41,88 @@ type File struct {
 }
 
 type FileQuery struct {
-	Text             *string `json:"text"                       validate:"required"`
-	Type             *string `json:"type,omitempty"             validate:"omitempty,oneof=file folder"`
-	CreateTimeAfter  *int64  `json:"createTimeAfter,omitempty"`
-	CreateTimeBefore *int64  `json:"createTimeBefore,omitempty"`
-	UpdateTimeAfter  *int64  `json:"updateTimeAfter,omitempty"`
-	UpdateTimeBefore *int64  `json:"updateTimeBefore,omitempty"`
+	Text              *string  `json:"text"                       validate:"required"`
+	Type              *string  `json:"type,omitempty"             validate:"omitempty,oneof=file folder"`
+	CreateTimeAfter   *int64   `json:"createTimeAfter,omitempty"`
+	CreateTimeBefore  *int64   `json:"createTimeBefore,omitempty"`
+	UpdateTimeAfter   *int64   `json:"updateTimeAfter,omitempty"`
+	UpdateTimeBefore  *int64   `json:"updateTimeBefore,omitempty"`
+	DurationMin       *float64 `json:"durationMin,omitempty"`
+	DurationMax       *float64 `json:"durationMax,omitempty"`
+	VideoCodec        *string  `json:"videoCodec,omitempty"`
+	AudioCodec        *string  `json:"audioCodec,omitempty"`
+	CameraMake        *string  `json:"cameraMake,omitempty"`
+	CameraModel       *string  `json:"cameraModel,omitempty"`
+	CaptureTimeAfter  *int64   `json:"captureTimeAfter,omitempty"`
+	CaptureTimeBefore *int64   `json:"captureTimeBefore,omitempty"`
+	HasLocation       *bool    `json:"hasLocation,omitempty"`
+	Author            *string  `json:"author,omitempty"`
 }
 
 type FileList struct {
-	Data          []*File    `json:"data"`
-	TotalPages    uint64     `json:"totalPages"`
-	TotalElements uint64     `json:"totalElements"`
-	Page          uint64     `json:"page"`
-	Size          uint64     `json:"size"`
-	Query         *FileQuery `json:"query,omitempty"`
+	Data           []*File    `json:"data"`
+	TotalPages     uint64     `json:"totalPages"`
+	TotalElements  uint64     `json:"totalElements"`
+	Page           uint64     `json:"page"`
+	Size           uint64     `json:"size"`
+	Query          *FileQuery `json:"query,omitempty"`
+	NextCursor     *string    `json:"nextCursor,omitempty"`
+	PreviousCursor *string    `json:"previousCursor,omitempty"`
+}
+
+type FileSearchQuery struct {
+	Text        *string `json:"text"                  validate:"required"`
+	Type        *string `json:"type,omitempty"        validate:"omitempty,oneof=file folder"`
+	Intent      *string `json:"intent,omitempty"      validate:"omitempty,oneof=document image video audio 3d"`
+	Language    *string `json:"language,omitempty"`
+	WorkspaceID *string `json:"workspaceId,omitempty"`
+	Date        *string `json:"date,omitempty"        validate:"omitempty,datetime=2006-01"`
+}
+
+type FileSearchResult struct {
+	Data          []*FileSearchHit  `json:"data"`
+	TotalPages    uint64            `json:"totalPages"`
+	TotalElements uint64            `json:"totalElements"`
+	Page          uint64            `json:"page"`
+	Size          uint64            `json:"size"`
+	Facets        *FileSearchFacets `json:"facets"`
+	Query         *FileSearchQuery  `json:"query,omitempty"`
+}
+
+type FileSearchHit struct {
+	File       *File    `json:"file"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// FileSearchFacets counts the hits of a search by value, workspaces are keyed by
+// ID and dates by month of creation, formatted as YYYY-MM.
+type FileSearchFacets struct {
+	Type      map[string]int64 `json:"type"`
+	Intent    map[string]int64 `json:"intent"`
+	Language  map[string]int64 `json:"language"`
+	Workspace map[string]int64 `json:"workspace"`
+	Date      map[string]int64 `json:"date"`
+}
+
+type FileSemanticSearchQuery struct {
+	Text        *string `json:"text"                  validate:"required"`
+	WorkspaceID *string `json:"workspaceId,omitempty"`
+}
+
+type FileSemanticSearchResult struct {
+	Data  []*FileSemanticSearchHit `json:"data"`
+	Query *FileSemanticSearchQuery `json:"query,omitempty"`
+}
+
+// FileSemanticSearchHit is a chunk of the text of a file, the offsets are in
+// characters (Unicode code points) from the start of the text of the snapshot.
+type FileSemanticSearchHit struct {
+	File        *File   `json:"file"`
+	SnapshotID  string  `json:"snapshotId"`
+	Text        string  `json:"text"`
+	StartOffset int     `json:"startOffset"`
+	EndOffset   int     `json:"endOffset"`
+	Score       float64 `json:"score"`
 }
 
 type FileProbe struct {
@@ -67,6 +134,45 @@ type FilePatchNameOptions struct {
 	Name string `json:"name" validate:"required,max=255"`
 }
 
+type FileProperty struct {
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"            validate:"required,max=255"`
+	Value     *string `json:"value,omitempty"`
+}
+
+const (
+	FilePropertyActionSet    = "set"
+	FilePropertyActionRemove = "remove"
+)
+
+type FilePropertyOperation struct {
+	Action    string  `json:"action"          validate:"required,oneof=set remove"`
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"            validate:"required,max=255"`
+	Value     *string `json:"value,omitempty"`
+}
+
+type FilePatchPropertiesOptions struct {
+	// Operations are applied in order, either all of them or none.
+	Operations []FilePropertyOperation `json:"operations" validate:"required,min=1,dive"`
+}
+
+type FilePropertyStatus struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    int    `json:"status"`
+}
+
+type FilePatchPropertiesResult struct {
+	Properties []*FileProperty `json:"properties"`
+	// Statuses holds the HTTP status of each operation, in the same order.
+	Statuses []FilePropertyStatus `json:"statuses"`
+}
+
+type FileListPropertiesManyOptions struct {
+	IDs []string `json:"ids" validate:"required,max=1000"`
+}
+
 type FileCopyManyOptions struct {
 	SourceIDs []string `json:"sourceIds" validate:"required"`
 	TargetID  string   `json:"targetId"  validate:"required"`
@@ -15,6 +15,8 @@ type MosaicMetadata struct {
 	Height     int               `json:"height"`
 	Extension  string            `json:"extension"`
 	ZoomLevels []MosaicZoomLevel `json:"zoomLevels"`
+	Page       *int              `json:"page,omitempty"`
+	Pages      []MosaicMetadata  `json:"pages,omitempty"`
 }
 
 type MosaicZoomLevel struct {
@@ -33,3 +35,33 @@ type MosaicTile struct {
 	LastColWidth  int `json:"lastColWidth"`
 	LastRowHeight int `json:"lastRowHeight"`
 }
+
+type MosaicIIIFInfo struct {
+	Context        string           `json:"@context"`
+	ID             string           `json:"id"`
+	Type           string           `json:"type"`
+	Protocol       string           `json:"protocol"`
+	Profile        string           `json:"profile"`
+	Width          int              `json:"width"`
+	Height         int              `json:"height"`
+	MaxWidth       int              `json:"maxWidth"`
+	MaxHeight      int              `json:"maxHeight"`
+	MaxArea        int              `json:"maxArea"`
+	Sizes          []MosaicIIIFSize `json:"sizes"`
+	Tiles          []MosaicIIIFTile `json:"tiles"`
+	ExtraQualities []string         `json:"extraQualities"`
+	ExtraFeatures  []string         `json:"extraFeatures"`
+}
+
+type MosaicIIIFSize struct {
+	Type   string `json:"type"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type MosaicIIIFTile struct {
+	Type         string `json:"type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	ScaleFactors []int  `json:"scaleFactors"`
+}
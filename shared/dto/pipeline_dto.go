@@ -20,15 +20,37 @@ const (
 	PipelineGLB        = "glb"
 	PipelineZIP        = "zip"
 	PipelineOCR        = "ocr"
+	PipelineImageDiff  = "image_diff"
+)
+
+// Payload keys of the image diff pipeline, the base image is given by Bucket
+// and Key, the other image by PipelinePayloadOtherBucket and
+// PipelinePayloadOtherKey.
+const (
+	PipelinePayloadDiffID      = "diffId"
+	PipelinePayloadOtherBucket = "otherBucket"
+	PipelinePayloadOtherKey    = "otherKey"
+	PipelinePayloadOutputKey   = "outputKey"
+)
+
+const (
+	PipelinePriorityInteractive = "interactive"
+	PipelinePriorityBulk        = "bulk"
 )
 
 type PipelineRunOptions struct {
-	PipelineID *string           `json:"pipelineId,omitempty"`
-	TaskID     *string           `json:"taskId"`
-	SnapshotID string            `json:"snapshotId"           validate:"required"`
-	Bucket     string            `json:"bucket"               validate:"required"`
-	Key        string            `json:"key"                  validate:"required"`
-	Intent     *string           `json:"intent,omitempty"`
-	Language   *string           `json:"language,omitempty"`
-	Payload    map[string]string `json:"payload,omitempty"`
+	PipelineID  *string           `json:"pipelineId,omitempty"`
+	TaskID      *string           `json:"taskId"`
+	SnapshotID  string            `json:"snapshotId"            validate:"required"`
+	WorkspaceID *string           `json:"workspaceId,omitempty"`
+	Priority    *string           `json:"priority,omitempty"    validate:"omitempty,oneof=interactive bulk"`
+	Bucket      string            `json:"bucket"                validate:"required"`
+	Key         string            `json:"key"                   validate:"required"`
+	Intent      *string           `json:"intent,omitempty"`
+	Language    *string           `json:"language,omitempty"`
+	Payload     map[string]string `json:"payload,omitempty"`
+}
+
+type PipelineCancelOptions struct {
+	TaskID string `json:"taskId" validate:"required"`
 }
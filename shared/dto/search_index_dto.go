@@ -0,0 +1,50 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+const (
+	SearchIndexFile         = "file"
+	SearchIndexGroup        = "group"
+	SearchIndexWorkspace    = "workspace"
+	SearchIndexOrganization = "organization"
+	SearchIndexTask         = "task"
+	SearchIndexUser         = "user"
+	// SearchIndexSnapshotChunk is only available when the semantic search is
+	// enabled, reindexing it also embeds the texts that have no chunks yet.
+	SearchIndexSnapshotChunk = "snapshot_chunk"
+)
+
+// SearchIndexReindexOptions lists the indexes to rebuild, all of them if empty.
+type SearchIndexReindexOptions struct {
+	Indexes []string `json:"indexes,omitempty" validate:"omitempty,dive,oneof=file group workspace organization task user snapshot_chunk"`
+}
+
+// SearchIndexCheckOptions lists the indexes to check, all of them if empty.
+// When Fix is set the drift is repaired, by indexing the missing documents and
+// deleting the orphaned ones.
+type SearchIndexCheckOptions struct {
+	Indexes []string `json:"indexes,omitempty" validate:"omitempty,dive,oneof=file group workspace organization task user snapshot_chunk"`
+	Fix     bool     `json:"fix"`
+}
+
+type SearchIndexCheckResult struct {
+	Indexes []*SearchIndexDrift `json:"indexes"`
+}
+
+type SearchIndexDrift struct {
+	Index string `json:"index"`
+	// Missing contains the IDs of the rows of Postgres that are not in the index.
+	Missing []string `json:"missing"`
+	// Orphaned contains the IDs of the documents of the index that have no
+	// matching row in Postgres.
+	Orphaned []string `json:"orphaned"`
+	Fixed    bool     `json:"fixed"`
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+type ShareLink struct {
+	ID            string  `json:"id"`
+	FileID        string  `json:"fileId"`
+	Token         string  `json:"token"`
+	Mode          string  `json:"mode"`
+	HasPassword   bool    `json:"hasPassword"`
+	ExpiryTime    *string `json:"expiryTime,omitempty"`
+	MaxDownloads  *int    `json:"maxDownloads,omitempty"`
+	DownloadCount int     `json:"downloadCount"`
+	RevokeTime    *string `json:"revokeTime,omitempty"`
+	CreateTime    string  `json:"createTime"`
+	UpdateTime    *string `json:"updateTime,omitempty"`
+}
+
+type ShareLinkCreateOptions struct {
+	FileID       string  `json:"fileId"                 validate:"required"`
+	Mode         string  `json:"mode"                   validate:"required,oneof=viewer upload"`
+	Password     *string `json:"password,omitempty"     validate:"omitempty,min=4,max=255"`
+	ExpiryTime   *string `json:"expiryTime,omitempty"   validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	MaxDownloads *int    `json:"maxDownloads,omitempty" validate:"omitempty,min=1"`
+}
+
+type ShareLinkAccess struct {
+	ID         string  `json:"id"`
+	FileID     *string `json:"fileId,omitempty"`
+	Action     string  `json:"action"`
+	Granted    bool    `json:"granted"`
+	ErrorCode  *string `json:"errorCode,omitempty"`
+	IP         *string `json:"ip,omitempty"`
+	UserAgent  *string `json:"userAgent,omitempty"`
+	CreateTime string  `json:"createTime"`
+}
+
+type ShareLinkAccessList struct {
+	Data          []*ShareLinkAccess `json:"data"`
+	TotalPages    uint64             `json:"totalPages"`
+	TotalElements uint64             `json:"totalElements"`
+	Page          uint64             `json:"page"`
+	Size          uint64             `json:"size"`
+}
+
+// Share is what an anonymous visitor of a share link gets to see, the file is
+// omitted until the link is unlocked if it is protected by a password.
+type Share struct {
+	Mode        string      `json:"mode"`
+	HasPassword bool        `json:"hasPassword"`
+	IsLocked    bool        `json:"isLocked"`
+	ExpiryTime  *string     `json:"expiryTime,omitempty"`
+	File        *SharedFile `json:"file,omitempty"`
+}
+
+type SharedFile struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	ParentID   *string         `json:"parentId,omitempty"`
+	Snapshot   *SharedSnapshot `json:"snapshot,omitempty"`
+	CreateTime string          `json:"createTime"`
+	UpdateTime *string         `json:"updateTime,omitempty"`
+}
+
+type SharedSnapshot struct {
+	Original  *SnapshotDownloadable `json:"original,omitempty"`
+	Preview   *SnapshotDownloadable `json:"preview,omitempty"`
+	Thumbnail *SnapshotDownloadable `json:"thumbnail,omitempty"`
+}
+
+type SharedFileList struct {
+	Data          []*SharedFile `json:"data"`
+	TotalPages    uint64        `json:"totalPages"`
+	TotalElements uint64        `json:"totalElements"`
+	Page          uint64        `json:"page"`
+	Size          uint64        `json:"size"`
+}
+
+type ShareUnlockOptions struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type ShareUnlockResult struct {
+	AccessToken string `json:"accessToken"`
+	ExpiryTime  string `json:"expiryTime"`
+}
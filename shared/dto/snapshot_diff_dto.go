@@ -0,0 +1,68 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+import (
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+const (
+	SnapshotDiffStatusProcessing = "processing"
+	SnapshotDiffStatusReady      = "ready"
+	SnapshotDiffStatusError      = "error"
+)
+
+const (
+	SnapshotDiffOperationEqual  = "equal"
+	SnapshotDiffOperationInsert = "insert"
+	SnapshotDiffOperationDelete = "delete"
+)
+
+// SnapshotDiff compares two snapshots of the same file, the base snapshot is
+// always the one with the lowest version.
+type SnapshotDiff struct {
+	ID               string            `json:"id"`
+	BaseSnapshotID   string            `json:"baseSnapshotId"`
+	TargetSnapshotID string            `json:"targetSnapshotId"`
+	Type             string            `json:"type"`
+	Status           string            `json:"status"`
+	Text             *SnapshotTextDiff `json:"text,omitempty"`
+	Image            *model.ImageProps `json:"image,omitempty"`
+	// Score is the fraction of pixels that differ between both images, from 0
+	// (identical) to 1 (every pixel changed).
+	Score      *float64 `json:"score,omitempty"`
+	Task       *Task    `json:"task,omitempty"`
+	CreateTime string   `json:"createTime"`
+	UpdateTime *string  `json:"updateTime,omitempty"`
+}
+
+type SnapshotTextDiff struct {
+	Unified    string                   `json:"unified"`
+	Words      []*SnapshotDiffOperation `json:"words"`
+	Insertions int                      `json:"insertions"`
+	Deletions  int                      `json:"deletions"`
+}
+
+type SnapshotDiffOperation struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SnapshotDiffCreateOptions struct {
+	FileID          string `json:"fileId"          validate:"required"`
+	SnapshotID      string `json:"snapshotId"      validate:"required"`
+	OtherSnapshotID string `json:"otherSnapshotId" validate:"required"`
+}
+
+type SnapshotDiffPatchOptions struct {
+	Artifact *model.S3Object `json:"artifact" validate:"required"`
+	Score    *float64        `json:"score"    validate:"required,min=0,max=1"`
+}
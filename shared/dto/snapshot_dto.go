@@ -18,24 +18,32 @@ const (
 	SnapshotSortByVersion      = "version"
 	SnapshotSortByDateCreated  = "date_created"
 	SnapshotSortByDateModified = "date_modified"
+	SnapshotSortByLabel        = "label"
+	SnapshotSortByPinned       = "pinned"
 )
 
 type Snapshot struct {
-	ID           string                `json:"id"`
-	Version      int64                 `json:"version"`
-	Original     *SnapshotDownloadable `json:"original,omitempty"`
-	Preview      *SnapshotDownloadable `json:"preview,omitempty"`
-	OCR          *SnapshotDownloadable `json:"ocr,omitempty"`
-	Text         *SnapshotDownloadable `json:"text,omitempty"`
-	Thumbnail    *SnapshotDownloadable `json:"thumbnail,omitempty"`
-	Summary      *string               `json:"summary,omitempty"`
-	Intent       *string               `json:"intent,omitempty"`
-	Language     *string               `json:"language,omitempty"`
-	Capabilities SnapshotCapabilities  `json:"capabilities"`
-	IsActive     bool                  `json:"isActive"`
-	Task         *Task                 `json:"task,omitempty"`
-	CreateTime   string                `json:"createTime"`
-	UpdateTime   *string               `json:"updateTime,omitempty"`
+	ID           string                  `json:"id"`
+	Version      int64                   `json:"version"`
+	Original     *SnapshotDownloadable   `json:"original,omitempty"`
+	Preview      *SnapshotDownloadable   `json:"preview,omitempty"`
+	OCR          *SnapshotDownloadable   `json:"ocr,omitempty"`
+	Text         *SnapshotDownloadable   `json:"text,omitempty"`
+	Thumbnail    *SnapshotDownloadable   `json:"thumbnail,omitempty"`
+	HLS          *SnapshotDownloadable   `json:"hls,omitempty"`
+	Metadata     *model.SnapshotMetadata `json:"metadata,omitempty"`
+	Summary      *string                 `json:"summary,omitempty"`
+	Intent       *string                 `json:"intent,omitempty"`
+	Language     *string                 `json:"language,omitempty"`
+	Capabilities SnapshotCapabilities    `json:"capabilities"`
+	Label        *string                 `json:"label,omitempty"`
+	Note         *string                 `json:"note,omitempty"`
+	UserID       *string                 `json:"userId,omitempty"`
+	IsPinned     bool                    `json:"isPinned"`
+	IsActive     bool                    `json:"isActive"`
+	Task         *Task                   `json:"task,omitempty"`
+	CreateTime   string                  `json:"createTime"`
+	UpdateTime   *string                 `json:"updateTime,omitempty"`
 }
 
 type SnapshotCapabilities struct {
@@ -46,6 +54,7 @@ type SnapshotCapabilities struct {
 	Summary   bool `json:"summary"`
 	Entities  bool `json:"entities"`
 	Mosaic    bool `json:"mosaic"`
+	HLS       bool `json:"hls"`
 	Thumbnail bool `json:"thumbnail"`
 }
 
@@ -54,6 +63,7 @@ type SnapshotDownloadable struct {
 	Size      int64                `json:"size"`
 	Image     *model.ImageProps    `json:"image,omitempty"`
 	Document  *model.DocumentProps `json:"document,omitempty"`
+	Media     *model.MediaProps    `json:"media,omitempty"`
 }
 
 type SnapshotList struct {
@@ -75,18 +85,30 @@ type SnapshotProbe struct {
 }
 
 type SnapshotPatchOptions struct {
-	Fields    []string        `json:"fields"`
-	Original  *model.S3Object `json:"original"`
-	Preview   *model.S3Object `json:"preview"`
-	Text      *model.S3Object `json:"text"`
-	OCR       *model.S3Object `json:"ocr"`
-	Entities  *model.S3Object `json:"entities"`
-	Mosaic    *model.S3Object `json:"mosaic"`
-	Thumbnail *model.S3Object `json:"thumbnail"`
-	TaskID    *string         `json:"taskId"`
-	Language  *string         `json:"language"`
-	Summary   *string         `json:"summary"`
-	Intent    *string         `json:"intent"`
+	Fields    []string                `json:"fields"`
+	Original  *model.S3Object         `json:"original"`
+	Preview   *model.S3Object         `json:"preview"`
+	Text      *model.S3Object         `json:"text"`
+	OCR       *model.S3Object         `json:"ocr"`
+	Entities  *model.S3Object         `json:"entities"`
+	Mosaic    *model.S3Object         `json:"mosaic"`
+	HLS       *model.S3Object         `json:"hls"`
+	Thumbnail *model.S3Object         `json:"thumbnail"`
+	Metadata  *model.SnapshotMetadata `json:"metadata"`
+	TaskID    *string                 `json:"taskId"`
+	Language  *string                 `json:"language"`
+	Summary   *string                 `json:"summary"`
+	Intent    *string                 `json:"intent"`
+}
+
+type SnapshotPatchLabelOptions struct {
+	// Label is removed when nil.
+	Label *string `json:"label,omitempty" validate:"omitempty,min=1,max=255"`
+}
+
+type SnapshotPatchNoteOptions struct {
+	// Note is removed when nil.
+	Note *string `json:"note,omitempty" validate:"omitempty,min=1,max=4096"`
 }
 
 type SnapshotLanguage struct {
@@ -96,21 +118,27 @@ type SnapshotLanguage struct {
 }
 
 type SnapshotWithS3Objects struct {
-	ID         string          `json:"id"`
-	Version    int64           `json:"version"`
-	Original   *model.S3Object `json:"original,omitempty"`
-	Preview    *model.S3Object `json:"preview,omitempty"`
-	Text       *model.S3Object `json:"text,omitempty"`
-	OCR        *model.S3Object `json:"ocr,omitempty"`
-	Entities   *model.S3Object `json:"entities,omitempty"`
-	Mosaic     *model.S3Object `json:"mosaic,omitempty"`
-	Thumbnail  *model.S3Object `json:"thumbnail,omitempty"`
-	Summary    *string         `json:"summary,omitempty"`
-	Intent     *string         `json:"intent,omitempty"`
-	Language   *string         `json:"language,omitempty"`
-	Task       *Task           `json:"taskId,omitempty"`
-	CreateTime string          `json:"createTime"`
-	UpdateTime *string         `json:"updateTime,omitempty"`
+	ID         string                  `json:"id"`
+	Version    int64                   `json:"version"`
+	Original   *model.S3Object         `json:"original,omitempty"`
+	Preview    *model.S3Object         `json:"preview,omitempty"`
+	Text       *model.S3Object         `json:"text,omitempty"`
+	OCR        *model.S3Object         `json:"ocr,omitempty"`
+	Entities   *model.S3Object         `json:"entities,omitempty"`
+	Mosaic     *model.S3Object         `json:"mosaic,omitempty"`
+	HLS        *model.S3Object         `json:"hls,omitempty"`
+	Thumbnail  *model.S3Object         `json:"thumbnail,omitempty"`
+	Metadata   *model.SnapshotMetadata `json:"metadata,omitempty"`
+	Summary    *string                 `json:"summary,omitempty"`
+	Intent     *string                 `json:"intent,omitempty"`
+	Language   *string                 `json:"language,omitempty"`
+	Label      *string                 `json:"label,omitempty"`
+	Note       *string                 `json:"note,omitempty"`
+	UserID     *string                 `json:"userId,omitempty"`
+	IsPinned   bool                    `json:"isPinned"`
+	Task       *Task                   `json:"taskId,omitempty"`
+	CreateTime string                  `json:"createTime"`
+	UpdateTime *string                 `json:"updateTime,omitempty"`
 }
 
 const (
@@ -0,0 +1,47 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+type SnapshotRetentionPolicy struct {
+	ID               string  `json:"id"`
+	WorkspaceID      string  `json:"workspaceId"`
+	KeepLast         *int    `json:"keepLast,omitempty"`
+	KeepAllDays      *int    `json:"keepAllDays,omitempty"`
+	KeepDailyDays    *int    `json:"keepDailyDays,omitempty"`
+	KeepWeeklyWeeks  *int    `json:"keepWeeklyWeeks,omitempty"`
+	LastRunTime      *string `json:"lastRunTime,omitempty"`
+	LastDeletedCount *int    `json:"lastDeletedCount,omitempty"`
+	LastFreedBytes   *int64  `json:"lastFreedBytes,omitempty"`
+	CreateTime       string  `json:"createTime"`
+	UpdateTime       *string `json:"updateTime,omitempty"`
+}
+
+// SnapshotRetentionPolicyOptions describes which snapshots of a file are kept,
+// the others are pruned. The newest KeepLast snapshots are kept, then all the
+// snapshots of the last KeepAllDays days, then the newest snapshot of each day
+// for KeepDailyDays more days, then the newest snapshot of each week for
+// KeepWeeklyWeeks more weeks. The active and pinned snapshots are never pruned.
+type SnapshotRetentionPolicyOptions struct {
+	KeepLast        *int `json:"keepLast,omitempty"        validate:"required_without=KeepAllDays,omitempty,min=1"`
+	KeepAllDays     *int `json:"keepAllDays,omitempty"     validate:"required_without=KeepLast,omitempty,min=1"`
+	KeepDailyDays   *int `json:"keepDailyDays,omitempty"   validate:"omitempty,min=1"`
+	KeepWeeklyWeeks *int `json:"keepWeeklyWeeks,omitempty" validate:"omitempty,min=1"`
+}
+
+type SnapshotPruneResult struct {
+	// DeletedCount is the number of snapshots that were deleted along with
+	// their objects.
+	DeletedCount int `json:"deletedCount"`
+	// DetachedCount is the number of snapshots that were removed from a file,
+	// but kept because other files still reference them.
+	DetachedCount int   `json:"detachedCount"`
+	FreedBytes    int64 `json:"freedBytes"`
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+const (
+	TrashRestoreOnConflictRename = "rename"
+	TrashRestoreOnConflictFail   = "fail"
+)
+
+type TrashItem struct {
+	ID               string  `json:"id"`
+	WorkspaceID      string  `json:"workspaceId"`
+	File             *File   `json:"file"`
+	OriginalParentID *string `json:"originalParentId,omitempty"`
+	OriginalPath     string  `json:"originalPath"`
+	DeletedBy        string  `json:"deletedBy"`
+	// PurgeTime is when the item is purged automatically, it is nil when trash
+	// retention is disabled.
+	PurgeTime  *string `json:"purgeTime,omitempty"`
+	CreateTime string  `json:"createTime"`
+}
+
+type TrashItemList struct {
+	Data          []*TrashItem `json:"data"`
+	TotalPages    uint64       `json:"totalPages"`
+	TotalElements uint64       `json:"totalElements"`
+	Page          uint64       `json:"page"`
+	Size          uint64       `json:"size"`
+}
+
+type TrashRestoreOptions struct {
+	// ParentID defaults to the original parent, or to the workspace root if the
+	// original parent doesn't exist anymore.
+	ParentID *string `json:"parentId,omitempty"`
+	// OnConflict tells what to do when the parent has an item with the same
+	// name, it defaults to renaming the restored item.
+	OnConflict *string `json:"onConflict,omitempty" validate:"omitempty,oneof=rename fail"`
+}
+
+type TrashEmptyResult struct {
+	Succeeded []string `json:"succeeded"`
+	Failed    []string `json:"failed"`
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+type UploadSession struct {
+	ID          string               `json:"id"`
+	WorkspaceID string               `json:"workspaceId"`
+	ParentID    *string              `json:"parentId,omitempty"`
+	FileID      *string              `json:"fileId,omitempty"`
+	Name        string               `json:"name"`
+	Size        int64                `json:"size"`
+	Offset      int64                `json:"offset"`
+	Parts       []*UploadSessionPart `json:"parts"`
+	ExpiryTime  string               `json:"expiryTime"`
+	CreateTime  string               `json:"createTime"`
+	UpdateTime  *string              `json:"updateTime,omitempty"`
+}
+
+type UploadSessionPart struct {
+	Number int   `json:"number"`
+	Size   int64 `json:"size"`
+}
+
+type UploadSessionCreateOptions struct {
+	WorkspaceID string  `json:"workspaceId"           validate:"required_without=FileID"`
+	ParentID    *string `json:"parentId,omitempty"`
+	FileID      *string `json:"fileId,omitempty"`
+	Name        string  `json:"name"                  validate:"required_without=FileID,max=255"`
+	Size        int64   `json:"size"                  validate:"required,min=1"`
+	ContentType *string `json:"contentType,omitempty"`
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package dto
+
+type WebhookSubscription struct {
+	ID          string   `json:"id"`
+	WorkspaceID string   `json:"workspaceId"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	IsActive    bool     `json:"isActive"`
+	// Secret is only returned when the subscription is created.
+	Secret     *string `json:"secret,omitempty"`
+	CreateTime string  `json:"createTime"`
+	UpdateTime *string `json:"updateTime,omitempty"`
+}
+
+type WebhookSubscriptionCreateOptions struct {
+	WorkspaceID string   `json:"workspaceId" validate:"required"`
+	URL         string   `json:"url"         validate:"required,http_url,max=2048"`
+	Events      []string `json:"events"      validate:"required,min=1,dive,oneof=file.created file.updated file.moved file.deleted snapshot.activated permission.changed task.finished"`
+}
+
+type WebhookSubscriptionPatchOptions struct {
+	URL      *string  `json:"url,omitempty"      validate:"omitempty,http_url,max=2048"`
+	Events   []string `json:"events,omitempty"   validate:"omitempty,min=1,dive,oneof=file.created file.updated file.moved file.deleted snapshot.activated permission.changed task.finished"`
+	IsActive *bool    `json:"isActive,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID              string  `json:"id"`
+	SubscriptionID  string  `json:"subscriptionId"`
+	EventType       string  `json:"eventType"`
+	Payload         string  `json:"payload"`
+	Status          string  `json:"status"`
+	Attempts        int     `json:"attempts"`
+	NextAttemptTime *string `json:"nextAttemptTime,omitempty"`
+	LastAttemptTime *string `json:"lastAttemptTime,omitempty"`
+	ResponseStatus  *int    `json:"responseStatus,omitempty"`
+	ResponseBody    *string `json:"responseBody,omitempty"`
+	Error           *string `json:"error,omitempty"`
+	CreateTime      string  `json:"createTime"`
+	UpdateTime      *string `json:"updateTime,omitempty"`
+}
+
+type WebhookDeliveryList struct {
+	Data          []*WebhookDelivery `json:"data"`
+	TotalPages    uint64             `json:"totalPages"`
+	TotalElements uint64             `json:"totalElements"`
+	Page          uint64             `json:"page"`
+	Size          uint64             `json:"size"`
+}
+
+// WebhookEvent is the body posted to the URL of a subscription.
+type WebhookEvent struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	WorkspaceID string      `json:"workspaceId"`
+	CreateTime  string      `json:"createTime"`
+	Data        interface{} `json:"data"`
+}
+
+type WebhookFileEventData struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	Type             string  `json:"type"`
+	ParentID         *string `json:"parentId,omitempty"`
+	PreviousParentID *string `json:"previousParentId,omitempty"`
+	SnapshotID       *string `json:"snapshotId,omitempty"`
+	UserID           *string `json:"userId,omitempty"`
+}
+
+type WebhookSnapshotEventData struct {
+	ID      string `json:"id"`
+	FileID  string `json:"fileId"`
+	Version int64  `json:"version"`
+	UserID  string `json:"userId"`
+}
+
+type WebhookPermissionEventData struct {
+	FileID string `json:"fileId"`
+	// UserID or GroupID is set, depending on who the permission is for.
+	UserID  *string `json:"userId,omitempty"`
+	GroupID *string `json:"groupId,omitempty"`
+	// Permission is nil when the permission was revoked.
+	Permission *string `json:"permission,omitempty"`
+	ChangedBy  string  `json:"changedBy"`
+}
+
+type WebhookTaskEventData struct {
+	ID     string  `json:"id"`
+	FileID string  `json:"fileId"`
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Error  *string `json:"error,omitempty"`
+}
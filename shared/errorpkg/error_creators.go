@@ -160,6 +160,16 @@ func NewMosaicNotFoundError(err error) *ErrorResponse {
 	)
 }
 
+func NewMosaicSizeExceededError(maxWidth int, maxHeight int, maxArea int) *ErrorResponse {
+	return NewErrorResponse(
+		"mosaic_size_exceeded",
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Requested size exceeds %dx%d pixels or an area of %d pixels.", maxWidth, maxHeight, maxArea),
+		"The requested image is too large.",
+		nil,
+	)
+}
+
 func NewInvitationNotFoundError(err error) *ErrorResponse {
 	return NewErrorResponse(
 		"invitation_not_found",
@@ -190,6 +200,16 @@ func NewTaskIsRunningError(err error) *ErrorResponse {
 	)
 }
 
+func NewTaskIsNotPendingError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"task_is_not_pending",
+		http.StatusBadRequest,
+		"Task is not pending.",
+		"Task is not pending.",
+		err,
+	)
+}
+
 func NewTaskBelongsToAnotherUserError(err error) *ErrorResponse {
 	return NewErrorResponse(
 		"task_belongs_to_another_user",
@@ -322,6 +342,16 @@ func NewInvalidQueryParamError(param string) *ErrorResponse {
 	)
 }
 
+func NewInvalidPathParamError(param string) *ErrorResponse {
+	return NewErrorResponse(
+		"invalid_path_param",
+		http.StatusBadRequest,
+		fmt.Sprintf("Path param '%s' is invalid.", param),
+		"An invalid request was sent to the server.",
+		nil,
+	)
+}
+
 func NewInvalidFormFileError(field string) *ErrorResponse {
 	return NewErrorResponse(
 		"invalid_form_file",
@@ -332,6 +362,16 @@ func NewInvalidFormFileError(field string) *ErrorResponse {
 	)
 }
 
+func NewInvalidFormValueError(field string) *ErrorResponse {
+	return NewErrorResponse(
+		"invalid_form_value",
+		http.StatusBadRequest,
+		fmt.Sprintf("Form value '%s' is invalid.", field),
+		"An invalid request was sent to the server.",
+		nil,
+	)
+}
+
 func NewLargeFormFileError(field string) *ErrorResponse {
 	return NewErrorResponse(
 		"large_form_file",
@@ -605,3 +645,283 @@ func NewStorageQuotaExceededError() *ErrorResponse {
 		nil,
 	)
 }
+
+func NewUploadSessionNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"upload_session_not_found",
+		http.StatusNotFound,
+		"Upload session not found.",
+		"Upload session not found, it may have expired.",
+		err,
+	)
+}
+
+func NewUploadSessionIncompleteError(offset int64, size int64) *ErrorResponse {
+	return NewErrorResponse(
+		"upload_session_incomplete",
+		http.StatusBadRequest,
+		fmt.Sprintf("Upload session has received %d out of %d bytes.", offset, size),
+		"The upload is incomplete, please resume it and try again.",
+		nil,
+	)
+}
+
+func NewUploadSessionSizeExceededError(size int64) *ErrorResponse {
+	return NewErrorResponse(
+		"upload_session_size_exceeded",
+		http.StatusBadRequest,
+		fmt.Sprintf("Chunks exceed the declared size of %d bytes.", size),
+		"An invalid request was sent to the server.",
+		nil,
+	)
+}
+
+func NewUploadSessionFinalizingError() *ErrorResponse {
+	return NewErrorResponse(
+		"upload_session_finalizing",
+		http.StatusConflict,
+		"Upload session is being finalized.",
+		"The upload is already being finalized.",
+		nil,
+	)
+}
+
+func NewInvalidUploadChunkError(number int, message string) *ErrorResponse {
+	return NewErrorResponse(
+		"invalid_upload_chunk",
+		http.StatusBadRequest,
+		fmt.Sprintf("Invalid chunk %d: %s", number, message),
+		"An invalid request was sent to the server.",
+		nil,
+	)
+}
+
+func NewShareLinkNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_not_found",
+		http.StatusNotFound,
+		"Share link not found.",
+		"Share link not found.",
+		err,
+	)
+}
+
+func NewShareLinkExpiredError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_expired",
+		http.StatusGone,
+		fmt.Sprintf("Share link '%s' has expired.", link.GetID()),
+		"This link has expired.",
+		nil,
+	)
+}
+
+func NewShareLinkRevokedError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_revoked",
+		http.StatusGone,
+		fmt.Sprintf("Share link '%s' has been revoked.", link.GetID()),
+		"This link is no longer available.",
+		nil,
+	)
+}
+
+func NewShareLinkDownloadLimitReachedError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_download_limit_reached",
+		http.StatusGone,
+		fmt.Sprintf("Share link '%s' has reached its maximum number of downloads.", link.GetID()),
+		"This link has reached its maximum number of downloads.",
+		nil,
+	)
+}
+
+func NewShareLinkPasswordRequiredError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_password_required",
+		http.StatusUnauthorized,
+		fmt.Sprintf("Share link '%s' is protected by a password.", link.GetID()),
+		"This link is protected by a password.",
+		nil,
+	)
+}
+
+func NewInvalidShareLinkPasswordError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"invalid_share_link_password",
+		http.StatusUnauthorized,
+		fmt.Sprintf("Invalid password for share link '%s'.", link.GetID()),
+		"The password is incorrect.",
+		nil,
+	)
+}
+
+func NewShareLinkUnlockLockedError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_unlock_locked",
+		http.StatusTooManyRequests,
+		fmt.Sprintf("Too many failed attempts to unlock share link '%s'.", link.GetID()),
+		"Too many failed attempts, please try again later.",
+		nil,
+	)
+}
+
+func NewShareLinkModeNotAllowedError(link model.ShareLink) *ErrorResponse {
+	return NewErrorResponse(
+		"share_link_mode_not_allowed",
+		http.StatusForbidden,
+		fmt.Sprintf("Share link '%s' with mode '%s' does not allow this operation.", link.GetID(), link.GetMode()),
+		"This link does not allow this operation.",
+		nil,
+	)
+}
+
+func NewWebhookSubscriptionNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"webhook_subscription_not_found",
+		http.StatusNotFound,
+		"Webhook subscription not found.",
+		"Webhook subscription not found.",
+		err,
+	)
+}
+
+func NewWebhookURLNotAllowedError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"webhook_url_not_allowed",
+		http.StatusBadRequest,
+		"Webhook URL not allowed.",
+		"Webhook URL must use HTTPS and point to a public address.",
+		err,
+	)
+}
+
+func NewWebhookDeliveryNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"webhook_delivery_not_found",
+		http.StatusNotFound,
+		"Webhook delivery not found.",
+		"Webhook delivery not found.",
+		err,
+	)
+}
+
+func NewTrashItemNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"trash_item_not_found",
+		http.StatusNotFound,
+		"Trash item not found.",
+		"Item not found in trash.",
+		err,
+	)
+}
+
+func NewFileIsInTrashError(file model.File) *ErrorResponse {
+	return NewErrorResponse(
+		"file_is_in_trash",
+		http.StatusForbidden,
+		fmt.Sprintf("File '%s' is in the trash.", file.GetID()),
+		fmt.Sprintf("Item '%s' is in the trash.", file.GetName()),
+		nil,
+	)
+}
+
+func NewSnapshotRetentionPolicyNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_retention_policy_not_found",
+		http.StatusNotFound,
+		"Snapshot retention policy not found.",
+		"Version retention policy not found.",
+		err,
+	)
+}
+
+func NewSnapshotPruneInProgressError() *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_prune_in_progress",
+		http.StatusConflict,
+		"Snapshots of this workspace are already being pruned.",
+		"Versions of this workspace are already being pruned.",
+		nil,
+	)
+}
+
+func NewSnapshotDiffNotFoundError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_not_found",
+		http.StatusNotFound,
+		"Snapshot diff not found.",
+		"Version comparison not found.",
+		err,
+	)
+}
+
+func NewSnapshotDiffSameSnapshotError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_same_snapshot",
+		http.StatusBadRequest,
+		"Cannot compare a snapshot with itself.",
+		"Cannot compare a version with itself.",
+		err,
+	)
+}
+
+func NewSnapshotDiffFileMismatchError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_file_mismatch",
+		http.StatusBadRequest,
+		"Both snapshots must belong to the same file.",
+		"Both versions must belong to the same file.",
+		err,
+	)
+}
+
+func NewSnapshotDiffUnsupportedError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_unsupported",
+		http.StatusBadRequest,
+		"Snapshots have neither extracted text nor images to compare.",
+		"These versions cannot be compared.",
+		err,
+	)
+}
+
+func NewSnapshotDiffTextTooLargeError(maxSize int) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_text_too_large",
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Snapshot texts exceed %d bytes.", maxSize),
+		"These versions are too large to be compared.",
+		nil,
+	)
+}
+
+func NewSnapshotDiffNotReadyError(err error) *ErrorResponse {
+	return NewErrorResponse(
+		"snapshot_diff_not_ready",
+		http.StatusBadRequest,
+		"Snapshot diff is not ready.",
+		"Version comparison is still being processed.",
+		err,
+	)
+}
+
+func NewUserIsNotAdminError() *ErrorResponse {
+	return NewErrorResponse(
+		"user_is_not_admin",
+		http.StatusForbidden,
+		"User is not an admin.",
+		"This action is restricted to admins.",
+		nil,
+	)
+}
+
+func NewSemanticSearchDisabledError() *ErrorResponse {
+	return NewErrorResponse(
+		"semantic_search_disabled",
+		http.StatusServiceUnavailable,
+		"Semantic search is disabled.",
+		"Semantic search is not available.",
+		nil,
+	)
+}
@@ -11,6 +11,8 @@
 package guard
 
 import (
+	"slices"
+
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/config"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
@@ -29,24 +31,50 @@ func NewFileGuard(postgres config.PostgresConfig, redis config.RedisConfig, envi
 }
 
 func (g *FileGuard) IsAuthorized(userID string, file model.File, permission string) bool {
-	for _, p := range file.GetUserPermissions() {
-		if p.GetUserID() == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
-			return true
+	if g.isAuthorizedByUser(userID, file, permission) {
+		return true
+	}
+	return isAuthorizedByGroups(g.groupCache, userID, file.GetGroupPermissions(), permission)
+}
+
+// FilterAuthorized returns the files the user is granted the permission on,
+// the groups of all files are fetched from the cache in a single batch.
+func (g *FileGuard) FilterAuthorized(userID string, files []model.File, permission string) []model.File {
+	var groupIDs []string
+	for _, f := range files {
+		for _, p := range f.GetGroupPermissions() {
+			if model.IsEquivalentPermission(p.GetValue(), permission) {
+				groupIDs = append(groupIDs, p.GetGroupID())
+			}
 		}
 	}
-	for _, p := range file.GetGroupPermissions() {
-		g, err := g.groupCache.Get(p.GetGroupID())
+	memberOf := make(map[string]bool)
+	if len(groupIDs) > 0 {
+		slices.Sort(groupIDs)
+		groups, err := g.groupCache.GetMany(slices.Compact(groupIDs))
 		if err != nil {
 			logger.GetLogger().Error(err)
-			return false
 		}
-		for _, u := range g.GetMembers() {
-			if u == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
-				return true
+		for _, group := range groups {
+			if slices.Contains(group.GetMembers(), userID) {
+				memberOf[group.GetID()] = true
 			}
 		}
 	}
-	return false
+	var res []model.File
+	for _, f := range files {
+		if g.isAuthorizedByUser(userID, f, permission) {
+			res = append(res, f)
+			continue
+		}
+		for _, p := range f.GetGroupPermissions() {
+			if memberOf[p.GetGroupID()] && model.IsEquivalentPermission(p.GetValue(), permission) {
+				res = append(res, f)
+				break
+			}
+		}
+	}
+	return res
 }
 
 func (g *FileGuard) Authorize(userID string, file model.File, permission string) error {
@@ -60,3 +88,12 @@ func (g *FileGuard) Authorize(userID string, file model.File, permission string)
 	}
 	return nil
 }
+
+func (g *FileGuard) isAuthorizedByUser(userID string, file model.File, permission string) bool {
+	for _, p := range file.GetUserPermissions() {
+		if p.GetUserID() == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
+			return true
+		}
+	}
+	return false
+}
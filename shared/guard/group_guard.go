@@ -14,7 +14,6 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/config"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
-	"github.com/kouprlabs/voltaserve/shared/logger"
 	"github.com/kouprlabs/voltaserve/shared/model"
 )
 
@@ -34,19 +33,7 @@ func (g *GroupGuard) IsAuthorized(userID string, group model.Group, permission s
 			return true
 		}
 	}
-	for _, p := range group.GetGroupPermissions() {
-		g, err := g.groupCache.Get(p.GetGroupID())
-		if err != nil {
-			logger.GetLogger().Error(err)
-			return false
-		}
-		for _, u := range g.GetMembers() {
-			if u == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
-				return true
-			}
-		}
-	}
-	return false
+	return isAuthorizedByGroups(g.groupCache, userID, group.GetGroupPermissions(), permission)
 }
 
 func (g *GroupGuard) Authorize(userID string, group model.Group, permission string) error {
@@ -0,0 +1,44 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package guard
+
+import (
+	"slices"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+// isAuthorizedByGroups checks whether the user is a member of a group granted
+// the permission, the groups are fetched from the cache in a single batch.
+func isAuthorizedByGroups(groupCache *cache.GroupCache, userID string, permissions []model.CoreGroupPermission, permission string) bool {
+	var ids []string
+	for _, p := range permissions {
+		if model.IsEquivalentPermission(p.GetValue(), permission) {
+			ids = append(ids, p.GetGroupID())
+		}
+	}
+	if len(ids) == 0 {
+		return false
+	}
+	groups, err := groupCache.GetMany(ids)
+	if err != nil {
+		logger.GetLogger().Error(err)
+		return false
+	}
+	for _, g := range groups {
+		if slices.Contains(g.GetMembers(), userID) {
+			return true
+		}
+	}
+	return false
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/config"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
-	"github.com/kouprlabs/voltaserve/shared/logger"
 	"github.com/kouprlabs/voltaserve/shared/model"
 )
 
@@ -34,19 +33,7 @@ func (g *OrganizationGuard) IsAuthorized(userID string, org model.Organization,
 			return true
 		}
 	}
-	for _, p := range org.GetGroupPermissions() {
-		g, err := g.groupCache.Get(p.GetGroupID())
-		if err != nil {
-			logger.GetLogger().Error(err)
-			return false
-		}
-		for _, u := range g.GetMembers() {
-			if u == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
-				return true
-			}
-		}
-	}
-	return false
+	return isAuthorizedByGroups(g.groupCache, userID, org.GetGroupPermissions(), permission)
 }
 
 func (g *OrganizationGuard) Authorize(userID string, org model.Organization, permission string) error {
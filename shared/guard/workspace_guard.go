@@ -14,7 +14,6 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/cache"
 	"github.com/kouprlabs/voltaserve/shared/config"
 	"github.com/kouprlabs/voltaserve/shared/errorpkg"
-	"github.com/kouprlabs/voltaserve/shared/logger"
 	"github.com/kouprlabs/voltaserve/shared/model"
 )
 
@@ -34,19 +33,7 @@ func (g *WorkspaceGuard) IsAuthorized(userID string, workspace model.Workspace,
 			return true
 		}
 	}
-	for _, p := range workspace.GetGroupPermissions() {
-		g, err := g.groupCache.Get(p.GetGroupID())
-		if err != nil {
-			logger.GetLogger().Error(err)
-			return false
-		}
-		for _, u := range g.GetMembers() {
-			if u == userID && model.IsEquivalentPermission(p.GetValue(), permission) {
-				return true
-			}
-		}
-	}
-	return false
+	return isAuthorizedByGroups(g.groupCache, userID, workspace.GetGroupPermissions(), permission)
 }
 
 func (g *WorkspaceGuard) Authorize(userID string, workspace model.Workspace, permission string) error {
@@ -26,3 +26,18 @@ func GetUserID(c *fiber.Ctx) (string, error) {
 	claims := token.Claims.(jwt.MapClaims)
 	return claims.GetSubject()
 }
+
+// IsAdmin tells whether the access token of the request has the admin claim
+// set by the identity provider.
+func IsAdmin(c *fiber.Ctx) bool {
+	user := c.Locals("user")
+	if user == nil {
+		return false
+	}
+	claims, ok := user.(*jwt.Token).Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	isAdmin, ok := claims["is_admin"].(bool)
+	return ok && isAdmin
+}
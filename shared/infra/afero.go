@@ -15,10 +15,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/spf13/afero"
 
+	"github.com/kouprlabs/voltaserve/shared/helper"
 	"github.com/kouprlabs/voltaserve/shared/logger"
 )
 
@@ -81,6 +83,48 @@ func (mgr *aferoManager) PutText(objectName string, text string, _ string, bucke
 	return afero.WriteFile(mgr.fs, mgr.getObjectPath(objectName, bucketName), []byte(text), 0o644)
 }
 
+func (mgr *aferoManager) PutObject(objectName string, reader io.Reader, _ int64, _ string, bucketName string, _ minio.PutObjectOptions) error {
+	return afero.WriteReader(mgr.fs, mgr.getObjectPath(objectName, bucketName), reader)
+}
+
+func (mgr *aferoManager) RemoveIncompleteUpload(string, string) error {
+	return nil
+}
+
+func (mgr *aferoManager) NewMultipartUpload(string, string, string) (string, error) {
+	return helper.NewID(), nil
+}
+
+func (mgr *aferoManager) PutObjectPart(objectName string, uploadID string, partNumber int, reader io.Reader, _ int64, bucketName string) (string, error) {
+	if err := afero.WriteReader(mgr.fs, mgr.getPartPath(objectName, uploadID, partNumber, bucketName), reader); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(partNumber), nil
+}
+
+func (mgr *aferoManager) CompleteMultipartUpload(objectName string, uploadID string, parts []minio.CompletePart, bucketName string) error {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		data, err := afero.ReadFile(mgr.fs, mgr.getPartPath(objectName, uploadID, part.PartNumber, bucketName))
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	if err := afero.WriteFile(mgr.fs, mgr.getObjectPath(objectName, bucketName), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return mgr.AbortMultipartUpload(objectName, uploadID, bucketName)
+}
+
+func (mgr *aferoManager) AbortMultipartUpload(objectName string, uploadID string, bucketName string) error {
+	return mgr.fs.RemoveAll(mgr.getObjectPath(objectName+"."+uploadID, bucketName))
+}
+
+func (mgr *aferoManager) getPartPath(objectName string, uploadID string, partNumber int, bucketName string) string {
+	return filepath.Join(mgr.getObjectPath(objectName+"."+uploadID, bucketName), strconv.Itoa(partNumber))
+}
+
 func (mgr *aferoManager) GetObject(objectName string, bucketName string, _ minio.GetObjectOptions) (*bytes.Buffer, *int64, error) {
 	file, err := mgr.fs.Open(mgr.getObjectPath(objectName, bucketName))
 	if err != nil {
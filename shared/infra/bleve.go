@@ -13,17 +13,31 @@ package infra
 import (
 	"errors"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
 	blevemapping "github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
 	blevequery "github.com/blevesearch/bleve/v2/search/query"
 	bleveindex "github.com/blevesearch/bleve_index_api"
+
+	"github.com/kouprlabs/voltaserve/shared/helper"
 )
 
 var indices map[string]bleve.Index
 
+// vectors holds the vectors of the documents per index, Bleve only supports
+// vectors when built with FAISS, so the nearest neighbors are found by
+// comparing the vector of the query with all the vectors matching the filter.
+var (
+	vectors   = make(map[string]map[string][]float32)
+	vectorsMu sync.RWMutex
+)
+
 type bleveSearchManager struct{}
 
 func newBleveSearchManager() SearchManager {
@@ -48,6 +62,9 @@ func newBleveSearchManager() SearchManager {
 		if err := mgr.createTaskIndex(); err != nil {
 			panic(err)
 		}
+		if err := mgr.createSnapshotChunkIndex(); err != nil {
+			panic(err)
+		}
 	}
 	return mgr
 }
@@ -57,21 +74,7 @@ func (mgr *bleveSearchManager) Query(indexName string, query string, opts Search
 	if !ok {
 		return nil, errors.New("index not found")
 	}
-	var searchRequest *bleve.SearchRequest
-	var err error
-	if opts.Filter == nil {
-		searchRequest = bleve.NewSearchRequestOptions(
-			bleve.NewQueryStringQuery(query),
-			int(opts.Limit), 0, false,
-		)
-	} else {
-		filterQueries := mgr.buildFilter(opts.Filter)
-		conjunctionQuery := bleve.NewConjunctionQuery(bleve.NewQueryStringQuery(query))
-		for _, v := range filterQueries {
-			conjunctionQuery.AddQuery(v)
-		}
-		searchRequest = bleve.NewSearchRequestOptions(conjunctionQuery, int(opts.Limit), 0, false)
-	}
+	searchRequest := bleve.NewSearchRequestOptions(mgr.buildQuery(query, opts.Filter), int(opts.Limit), 0, false)
 	searchResult, err := index.Search(searchRequest)
 	if err != nil {
 		return nil, err
@@ -87,6 +90,76 @@ func (mgr *bleveSearchManager) Query(indexName string, query string, opts Search
 	return res, nil
 }
 
+func (mgr *bleveSearchManager) Search(indexName string, query string, opts SearchOptions) (*SearchResult, error) {
+	index, ok := indices[indexName]
+	if !ok {
+		return nil, errors.New("index not found")
+	}
+	searchRequest := bleve.NewSearchRequestOptions(
+		mgr.buildQuery(query, opts.Filter),
+		int(opts.Size), int((opts.Page-1)*opts.Size), false,
+	)
+	if len(opts.Sort) > 0 {
+		var order []string
+		for _, sort := range opts.Sort {
+			if sort.Descending {
+				order = append(order, "-"+sort.Field)
+			} else {
+				order = append(order, sort.Field)
+			}
+		}
+		searchRequest.SortBy(order)
+	}
+	for _, facet := range opts.Facets {
+		searchRequest.AddFacet(facet, bleve.NewFacetRequest(facet, SearchFacetSize))
+	}
+	if len(opts.Highlight) > 0 {
+		searchRequest.Highlight = bleve.NewHighlightWithStyle(html.Name)
+		searchRequest.Highlight.Fields = opts.Highlight
+	}
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	res := &SearchResult{
+		Hits:      make([]*SearchHit, len(searchResult.Hits)),
+		TotalHits: int64(searchResult.Total),
+		Facets:    make(map[string]map[string]int64),
+	}
+	for i, hit := range searchResult.Hits {
+		doc, err := index.Document(hit.ID)
+		if err != nil {
+			return nil, err
+		}
+		document := mgr.documentToMap(doc)
+		if len(opts.Retrieve) > 0 {
+			retrieved := make(map[string]interface{})
+			for _, field := range opts.Retrieve {
+				if v, ok := document[field]; ok {
+					retrieved[field] = v
+				}
+			}
+			document = retrieved
+		}
+		res.Hits[i] = &SearchHit{
+			Document:   document,
+			Highlights: make(map[string][]string),
+		}
+		for _, field := range opts.Highlight {
+			if fragments, ok := hit.Fragments[field]; ok && len(fragments) > 0 {
+				res.Hits[i].Highlights[field] = fragments
+			}
+		}
+	}
+	for name, facet := range searchResult.Facets {
+		res.Facets[name] = make(map[string]int64)
+		for _, term := range facet.Terms.Terms() {
+			res.Facets[name][term.Term] = int64(term.Count)
+		}
+	}
+	return res, nil
+}
+
 func (mgr *bleveSearchManager) Index(indexName string, models []SearchModel) error {
 	index, ok := indices[indexName]
 	if !ok {
@@ -98,7 +171,20 @@ func (mgr *bleveSearchManager) Index(indexName string, models []SearchModel) err
 			return err
 		}
 	}
-	return index.Batch(batch)
+	if err := index.Batch(batch); err != nil {
+		return err
+	}
+	vectorsMu.Lock()
+	defer vectorsMu.Unlock()
+	for _, model := range models {
+		if v, ok := model.(SearchVectorModel); ok {
+			if vectors[indexName] == nil {
+				vectors[indexName] = make(map[string][]float32)
+			}
+			vectors[indexName][model.GetID()] = v.GetVector()
+		}
+	}
+	return nil
 }
 
 func (mgr *bleveSearchManager) Update(indexName string, models []SearchModel) error {
@@ -114,12 +200,108 @@ func (mgr *bleveSearchManager) Delete(indexName string, ids []string) error {
 	for _, id := range ids {
 		batch.Delete(id)
 	}
-	return index.Batch(batch)
+	if err := index.Batch(batch); err != nil {
+		return err
+	}
+	vectorsMu.Lock()
+	defer vectorsMu.Unlock()
+	for _, id := range ids {
+		delete(vectors[indexName], id)
+	}
+	return nil
+}
+
+func (mgr *bleveSearchManager) FindIDs(indexName string) ([]string, error) {
+	index, ok := indices[indexName]
+	if !ok {
+		return nil, errors.New("index not found")
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	searchResult, err := index.Search(bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		res = append(res, hit.ID)
+	}
+	return res, nil
+}
+
+func (mgr *bleveSearchManager) EnableVectors(string, int) error {
+	return nil
+}
+
+func (mgr *bleveSearchManager) SearchVector(indexName string, vector []float32, opts SearchVectorOptions) ([]*SearchVectorHit, error) {
+	index, ok := indices[indexName]
+	if !ok {
+		return nil, errors.New("index not found")
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	query := bleve.NewConjunctionQuery(bleve.NewMatchAllQuery())
+	for _, v := range mgr.buildFilter(opts.Filter) {
+		query.AddQuery(v)
+	}
+	searchResult, err := index.Search(bleve.NewSearchRequestOptions(query, int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	type scored struct {
+		id    string
+		score float64
+	}
+	ranked := make([]scored, 0, len(searchResult.Hits))
+	vectorsMu.RLock()
+	for _, hit := range searchResult.Hits {
+		if v, ok := vectors[indexName][hit.ID]; ok && len(v) == len(vector) {
+			var dot float64
+			for i := range v {
+				dot += float64(v[i]) * float64(vector[i])
+			}
+			// Same scale as Meilisearch, which maps the cosine to [0, 1]
+			ranked = append(ranked, scored{id: hit.ID, score: (1 + dot) / 2})
+		}
+	}
+	vectorsMu.RUnlock()
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	if opts.Limit > 0 && int64(len(ranked)) > opts.Limit {
+		ranked = ranked[:opts.Limit]
+	}
+	res := make([]*SearchVectorHit, 0, len(ranked))
+	for _, r := range ranked {
+		doc, err := index.Document(r.id)
+		if err != nil {
+			return nil, err
+		}
+		document := mgr.documentToMap(doc)
+		if len(opts.Retrieve) > 0 {
+			retrieved := make(map[string]interface{})
+			for _, field := range opts.Retrieve {
+				if v, ok := document[field]; ok {
+					retrieved[field] = v
+				}
+			}
+			document = retrieved
+		}
+		res = append(res, &SearchVectorHit{Document: document, Score: r.score})
+	}
+	return res, nil
 }
 
 func (mgr *bleveSearchManager) createFileIndex() error {
 	mapping := bleve.NewIndexMapping()
 	mgr.appendCommonFields(mapping)
+	for _, name := range []string{"workspaceId", "type", "intent", "language", "createMonth", "users", "groups"} {
+		mgr.appendKeywordField(name, mapping)
+	}
 	index, err := bleve.NewMemOnly(mapping)
 	if err != nil {
 		return err
@@ -185,9 +367,35 @@ func (mgr *bleveSearchManager) createTaskIndex() error {
 	return nil
 }
 
+func (mgr *bleveSearchManager) createSnapshotChunkIndex() error {
+	mapping := bleve.NewIndexMapping()
+	mgr.appendCommonFields(mapping)
+	for _, name := range []string{"snapshotId", "workspaceId", "model"} {
+		mgr.appendKeywordField(name, mapping)
+	}
+	// The vectors are kept aside, see SearchVector
+	mapping.DefaultMapping.AddSubDocumentMapping("_vectors", bleve.NewDocumentDisabledMapping())
+	index, err := bleve.NewMemOnly(mapping)
+	if err != nil {
+		return err
+	}
+	indices[SnapshotChunkSearchIndex] = index
+	return nil
+}
+
+// appendCommonFields indexes the times as single terms, so that the hits can
+// be sorted by them.
 func (mgr *bleveSearchManager) appendCommonFields(mapping *blevemapping.IndexMappingImpl) {
-	mapping.DefaultMapping.AddFieldMappingsAt("createTime", bleve.NewTextFieldMapping())
-	mapping.DefaultMapping.AddFieldMappingsAt("updateTime", bleve.NewTextFieldMapping())
+	mgr.appendKeywordField("createTime", mapping)
+	mgr.appendKeywordField("updateTime", mapping)
+}
+
+// appendKeywordField indexes the field as a single term, which is required to
+// facet or sort on it.
+func (mgr *bleveSearchManager) appendKeywordField(name string, mapping *blevemapping.IndexMappingImpl) {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Analyzer = keyword.Name
+	mapping.DefaultMapping.AddFieldMappingsAt(name, fieldMapping)
 }
 
 func (mgr *bleveSearchManager) disableField(name string, mapping *blevemapping.IndexMappingImpl) {
@@ -197,23 +405,100 @@ func (mgr *bleveSearchManager) disableField(name string, mapping *blevemapping.I
 	mapping.DefaultMapping.AddFieldMappingsAt(name, fieldMapping)
 }
 
-func (mgr *bleveSearchManager) buildFilter(filter interface{}) []*blevequery.MatchQuery {
-	res := make([]*blevequery.MatchQuery, 0)
+func (mgr *bleveSearchManager) buildQuery(query string, filter interface{}) blevequery.Query {
+	if filter == nil {
+		return bleve.NewQueryStringQuery(query)
+	}
+	res := bleve.NewConjunctionQuery(bleve.NewQueryStringQuery(query))
+	for _, v := range mgr.buildFilter(filter) {
+		res.AddQuery(v)
+	}
+	return res
+}
+
+// buildFilter translates the subset of the Meilisearch filter syntax used by
+// the services: conditions joined with AND, using =, >=, <= or IN, where a
+// condition can also be a parenthesized group of conditions joined with OR.
+func (mgr *bleveSearchManager) buildFilter(filter interface{}) []blevequery.Query {
+	res := make([]blevequery.Query, 0)
 	expression, ok := filter.(string)
 	if !ok {
 		return nil
 	}
-	expression = regexp.MustCompile(`\s+`).ReplaceAllString(expression, "")
-	parts := strings.Split(expression, "AND")
+	parts := regexp.MustCompile(`\s+AND\s+`).Split(strings.TrimSpace(expression), -1)
 	for _, part := range parts {
-		condition := strings.Split(part, "=")
-		matchQuery := bleve.NewMatchQuery(condition[1])
-		matchQuery.SetField(condition[0])
-		res = append(res, matchQuery)
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+			disjunction := bleve.NewDisjunctionQuery()
+			for _, v := range regexp.MustCompile(`\s+OR\s+`).Split(part[1:len(part)-1], -1) {
+				if q := mgr.buildCondition(strings.TrimSpace(v)); q != nil {
+					disjunction.AddQuery(q)
+				}
+			}
+			res = append(res, disjunction)
+			continue
+		}
+		if q := mgr.buildCondition(part); q != nil {
+			res = append(res, q)
+		}
 	}
 	return res
 }
 
+func (mgr *bleveSearchManager) buildCondition(condition string) blevequery.Query {
+	if matches := regexp.MustCompile(`^(\w+)\s+IN\s+\[(.*)]$`).FindStringSubmatch(condition); matches != nil {
+		disjunction := bleve.NewDisjunctionQuery()
+		for _, value := range strings.Split(matches[2], ",") {
+			value = strings.TrimSpace(value)
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			if value == "" {
+				continue
+			}
+			q := bleve.NewMatchQuery(value)
+			q.SetField(matches[1])
+			q.SetOperator(blevequery.MatchQueryOperatorAnd)
+			disjunction.AddQuery(q)
+		}
+		if len(disjunction.Disjuncts) == 0 {
+			return bleve.NewMatchNoneQuery()
+		}
+		return disjunction
+	}
+	if field, value, found := strings.Cut(condition, ">="); found {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q := bleve.NewNumericRangeInclusiveQuery(&v, nil, helper.ToPtr(true), nil)
+			q.SetField(strings.TrimSpace(field))
+			return q
+		}
+		return nil
+	}
+	if field, value, found := strings.Cut(condition, "<="); found {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q := bleve.NewNumericRangeInclusiveQuery(nil, &v, nil, helper.ToPtr(true))
+			q.SetField(strings.TrimSpace(field))
+			return q
+		}
+		return nil
+	}
+	field, value, _ := strings.Cut(condition, "=")
+	field = strings.TrimSpace(field)
+	value = strings.TrimSpace(value)
+	if b, err := strconv.ParseBool(value); err == nil {
+		q := bleve.NewBoolFieldQuery(b)
+		q.SetField(field)
+		return q
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	q := bleve.NewMatchQuery(value)
+	q.SetField(field)
+	q.SetOperator(blevequery.MatchQueryOperatorAnd)
+	return q
+}
+
 func (mgr *bleveSearchManager) documentToMap(doc bleveindex.Document) map[string]interface{} {
 	res := make(map[string]interface{})
 	doc.VisitFields(func(field bleveindex.Field) {
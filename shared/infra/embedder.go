@@ -0,0 +1,218 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+)
+
+type Embedder interface {
+	// Embed returns one L2-normalized vector per text, in the same order.
+	Embed(texts []string) ([][]float32, error)
+	// Model identifies the vector space, vectors of different models must
+	// never be compared with each other.
+	Model() string
+}
+
+func NewEmbedder(embeddingConfig config.EmbeddingConfig, envConfig config.EnvironmentConfig) Embedder {
+	if envConfig.IsTest {
+		return newHashingEmbedder()
+	}
+	switch embeddingConfig.Provider {
+	case config.EmbeddingProviderOpenAI:
+		return newOpenAIEmbedder(embeddingConfig)
+	default:
+		panic(fmt.Sprintf("unknown embedding provider: %s", embeddingConfig.Provider))
+	}
+}
+
+// openAIEmbedder calls the embeddings endpoint of the OpenAI API, which is
+// also implemented by the self-hosted model servers.
+type openAIEmbedder struct {
+	config config.EmbeddingConfig
+	client *http.Client
+}
+
+func newOpenAIEmbedder(embeddingConfig config.EmbeddingConfig) *openAIEmbedder {
+	return &openAIEmbedder{
+		config: embeddingConfig,
+		client: &http.Client{Timeout: embeddingConfig.Timeout},
+	}
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (e *openAIEmbedder) Model() string {
+	return fmt.Sprintf("%s:%s", config.EmbeddingProviderOpenAI, e.config.Model)
+}
+
+func (e *openAIEmbedder) Embed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+	b, err := json.Marshal(openAIEmbeddingRequest{Model: e.config.Model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(e.config.URL, "/")+"/embeddings", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if e.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rc io.ReadCloser) {
+		if err := rc.Close(); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}(resp.Body)
+	b, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, string(b))
+	}
+	var body openAIEmbeddingResponse
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(body.Data))
+	}
+	res := make([][]float32, len(texts))
+	for _, d := range body.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d is out of range", d.Index)
+		}
+		if len(d.Embedding) != e.config.Dimensions {
+			return nil, fmt.Errorf("expected embeddings of %d dimensions, got %d", e.config.Dimensions, len(d.Embedding))
+		}
+		res[d.Index] = normalizeVector(d.Embedding)
+	}
+	for _, v := range res {
+		if v == nil {
+			return nil, errors.New("embedding response is missing an input")
+		}
+	}
+	return res, nil
+}
+
+// normalizeVector scales the vector to a length of one, most models already
+// do it, but the cosine similarity of the search engine relies on it.
+func normalizeVector(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return vector
+	}
+	res := make([]float32, len(vector))
+	for i, v := range vector {
+		res[i] = float32(float64(v) / norm)
+	}
+	return res
+}
+
+const (
+	hashingEmbedderDimensions = 512
+	hashingEmbedderNGram      = 3
+	hashingEmbedderNGramBoost = 0.5
+)
+
+// hashingEmbedder stands in for a model in tests, the same way Bleve stands in
+// for Meilisearch. Words and their character trigrams are hashed into a fixed
+// number of dimensions, so texts sharing words end up close to each other.
+type hashingEmbedder struct{}
+
+func newHashingEmbedder() *hashingEmbedder {
+	return &hashingEmbedder{}
+}
+
+func (e *hashingEmbedder) Model() string {
+	return fmt.Sprintf("hashing-%d-v1", hashingEmbedderDimensions)
+}
+
+func (e *hashingEmbedder) Embed(texts []string) ([][]float32, error) {
+	res := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		res = append(res, e.embed(text))
+	}
+	return res, nil
+}
+
+func (e *hashingEmbedder) embed(text string) []float32 {
+	vector := make([]float64, hashingEmbedderDimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		e.add(vector, "w:"+word, 1)
+		padded := []rune("<" + word + ">")
+		for i := 0; i+hashingEmbedderNGram <= len(padded); i++ {
+			e.add(vector, "g:"+string(padded[i:i+hashingEmbedderNGram]), hashingEmbedderNGramBoost)
+		}
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	res := make([]float32, hashingEmbedderDimensions)
+	if norm == 0 {
+		return res
+	}
+	for i, v := range vector {
+		res[i] = float32(v / norm)
+	}
+	return res
+}
+
+func (e *hashingEmbedder) add(vector []float64, feature string, weight float64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum64()
+	index := sum % hashingEmbedderDimensions
+	// The sign bit keeps collisions from always adding up
+	if (sum>>63)&1 == 1 {
+		weight = -weight
+	}
+	vector[index] += weight
+}
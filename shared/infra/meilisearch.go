@@ -11,6 +11,9 @@
 package infra
 
 import (
+	"strings"
+	"sync"
+
 	"github.com/meilisearch/meilisearch-go"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
@@ -22,6 +25,10 @@ type meilisearchManager struct {
 
 var meilisearchClient meilisearch.ServiceManager
 
+// meilisearchVectorIndexes keeps the indexes whose embedder was configured by
+// this process, so that the settings are only updated once.
+var meilisearchVectorIndexes sync.Map
+
 func newMeilisearchManager(searchConfig config.SearchConfig) *meilisearchManager {
 	if meilisearchClient == nil {
 		if searchConfig.APIKey == "" {
@@ -51,6 +58,9 @@ func newMeilisearchManager(searchConfig config.SearchConfig) *meilisearchManager
 	if err := mgr.createTaskIndex(); err != nil {
 		panic(err)
 	}
+	if err := mgr.createSnapshotChunkIndex(); err != nil {
+		panic(err)
+	}
 	return mgr
 }
 
@@ -65,6 +75,60 @@ func (mgr *meilisearchManager) Query(index string, query string, opts SearchQuer
 	return res.Hits, nil
 }
 
+func (mgr *meilisearchManager) Search(index string, query string, opts SearchOptions) (*SearchResult, error) {
+	req := &meilisearch.SearchRequest{
+		Filter:               opts.Filter,
+		Page:                 opts.Page,
+		HitsPerPage:          opts.Size,
+		Facets:               opts.Facets,
+		AttributesToRetrieve: opts.Retrieve,
+	}
+	for _, sort := range opts.Sort {
+		if sort.Descending {
+			req.Sort = append(req.Sort, sort.Field+":desc")
+		} else {
+			req.Sort = append(req.Sort, sort.Field+":asc")
+		}
+	}
+	if len(opts.Highlight) > 0 {
+		req.AttributesToHighlight = opts.Highlight
+		req.AttributesToCrop = opts.Highlight
+		req.CropLength = SearchSnippetLength
+		req.HighlightPreTag = SearchHighlightPreTag
+		req.HighlightPostTag = SearchHighlightPostTag
+	}
+	res, err := meilisearchClient.Index(index).Search(query, req)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*SearchHit, 0, len(res.Hits))
+	for _, v := range res.Hits {
+		document, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hit := &SearchHit{
+			Document:   document,
+			Highlights: make(map[string][]string),
+		}
+		if formatted, ok := document["_formatted"].(map[string]interface{}); ok {
+			for _, field := range opts.Highlight {
+				// Meilisearch returns the cropped field even without a match
+				if snippet, ok := formatted[field].(string); ok && strings.Contains(snippet, SearchHighlightPreTag) {
+					hit.Highlights[field] = []string{snippet}
+				}
+			}
+		}
+		delete(document, "_formatted")
+		hits = append(hits, hit)
+	}
+	return &SearchResult{
+		Hits:      hits,
+		TotalHits: res.TotalHits,
+		Facets:    mgr.mapFacets(res.FacetDistribution),
+	}, nil
+}
+
 func (mgr *meilisearchManager) Index(index string, models []SearchModel) error {
 	_, err := meilisearchClient.Index(index).AddDocuments(models)
 	if err != nil {
@@ -89,6 +153,99 @@ func (mgr *meilisearchManager) Delete(index string, ids []string) error {
 	return nil
 }
 
+func (mgr *meilisearchManager) FindIDs(index string) ([]string, error) {
+	const PageSize = 1000
+	res := make([]string, 0)
+	for offset := int64(0); ; offset += PageSize {
+		var page meilisearch.DocumentsResult
+		if err := meilisearchClient.Index(index).GetDocuments(&meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  PageSize,
+			Fields: []string{"id"},
+		}, &page); err != nil {
+			return nil, err
+		}
+		for _, doc := range page.Results {
+			if id, ok := doc["id"].(string); ok {
+				res = append(res, id)
+			}
+		}
+		if offset+PageSize >= page.Total {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (mgr *meilisearchManager) EnableVectors(index string, dimensions int) error {
+	if _, ok := meilisearchVectorIndexes.Load(index); ok {
+		return nil
+	}
+	// The vectors are computed by the embedder of the API, not by Meilisearch
+	if _, err := meilisearchClient.Index(index).UpdateEmbedders(map[string]meilisearch.Embedder{
+		SearchVectorEmbedder: {
+			Source:     "userProvided",
+			Dimensions: dimensions,
+		},
+	}); err != nil {
+		return err
+	}
+	meilisearchVectorIndexes.Store(index, true)
+	return nil
+}
+
+func (mgr *meilisearchManager) SearchVector(index string, vector []float32, opts SearchVectorOptions) ([]*SearchVectorHit, error) {
+	res, err := meilisearchClient.Index(index).Search("", &meilisearch.SearchRequest{
+		Vector: vector,
+		Hybrid: &meilisearch.SearchRequestHybrid{
+			Embedder:      SearchVectorEmbedder,
+			SemanticRatio: 1,
+		},
+		Filter:               opts.Filter,
+		Limit:                opts.Limit,
+		AttributesToRetrieve: opts.Retrieve,
+		ShowRankingScore:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*SearchVectorHit, 0, len(res.Hits))
+	for _, v := range res.Hits {
+		document, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hit := &SearchVectorHit{Document: document}
+		if score, ok := document["_rankingScore"].(float64); ok {
+			hit.Score = score
+		}
+		delete(document, "_rankingScore")
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+func (mgr *meilisearchManager) mapFacets(distribution interface{}) map[string]map[string]int64 {
+	res := make(map[string]map[string]int64)
+	facets, ok := distribution.(map[string]interface{})
+	if !ok {
+		return res
+	}
+	for name, v := range facets {
+		counts, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		res[name] = make(map[string]int64)
+		for value, count := range counts {
+			if n, ok := count.(float64); ok {
+				res[name][value] = int64(n)
+			}
+		}
+	}
+	return res
+}
+
 func (mgr *meilisearchManager) createFileIndex() error {
 	if _, err := meilisearchClient.CreateIndex(&meilisearch.IndexConfig{
 		Uid:        FileSearchIndex,
@@ -97,7 +254,7 @@ func (mgr *meilisearchManager) createFileIndex() error {
 		return err
 	}
 	if _, err := meilisearchClient.Index(FileSearchIndex).UpdateSettings(&meilisearch.Settings{
-		SearchableAttributes: []string{"name", "text", "summary"},
+		SearchableAttributes: []string{"name", "text", "summary", "documentTitle", "documentAuthor"},
 		FilterableAttributes: []string{
 			"id",
 			"workspaceId",
@@ -106,7 +263,21 @@ func (mgr *meilisearchManager) createFileIndex() error {
 			"snapshotId",
 			"createTime",
 			"updateTime",
+			"duration",
+			"videoCodec",
+			"audioCodec",
+			"cameraMake",
+			"cameraModel",
+			"captureTime",
+			"hasLocation",
+			"documentAuthor",
+			"intent",
+			"language",
+			"createMonth",
+			"users",
+			"groups",
 		},
+		SortableAttributes: []string{"name", "createTime", "updateTime"},
 	}); err != nil {
 		return err
 	}
@@ -225,3 +396,24 @@ func (mgr *meilisearchManager) createTaskIndex() error {
 	}
 	return nil
 }
+
+func (mgr *meilisearchManager) createSnapshotChunkIndex() error {
+	if _, err := meilisearchClient.CreateIndex(&meilisearch.IndexConfig{
+		Uid:        SnapshotChunkSearchIndex,
+		PrimaryKey: "id",
+	}); err != nil {
+		return err
+	}
+	if _, err := meilisearchClient.Index(SnapshotChunkSearchIndex).UpdateSettings(&meilisearch.Settings{
+		SearchableAttributes: []string{"id"},
+		FilterableAttributes: []string{
+			"id",
+			"snapshotId",
+			"workspaceId",
+			"model",
+		},
+	}); err != nil {
+		return err
+	}
+	return nil
+}
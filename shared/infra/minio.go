@@ -99,6 +99,74 @@ func (mgr *minioManager) PutText(objectName string, text string, contentType str
 	return nil
 }
 
+// PutObject streams the reader to S3, a size of -1 means that the size is not known
+// in advance, in which case the object is uploaded as a multipart upload with parts
+// of opts.PartSize bytes.
+func (mgr *minioManager) PutObject(objectName string, reader io.Reader, size int64, contentType string, bucketName string, opts minio.PutObjectOptions) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	opts.ContentType = contentType
+	if _, err := mgr.client.PutObject(context.Background(), bucketName, objectName, reader, size, opts); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mgr *minioManager) RemoveIncompleteUpload(objectName string, bucketName string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	return mgr.client.RemoveIncompleteUpload(context.Background(), bucketName, objectName)
+}
+
+func (mgr *minioManager) NewMultipartUpload(objectName string, contentType string, bucketName string) (string, error) {
+	if err := mgr.Connect(); err != nil {
+		return "", err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	core := minio.Core{Client: mgr.client}
+	return core.NewMultipartUpload(context.Background(), bucketName, objectName, minio.PutObjectOptions{ContentType: contentType})
+}
+
+// PutObjectPart uploads one part of a multipart upload, and returns the ETag that
+// must be passed to CompleteMultipartUpload.
+func (mgr *minioManager) PutObjectPart(objectName string, uploadID string, partNumber int, reader io.Reader, size int64, bucketName string) (string, error) {
+	if err := mgr.Connect(); err != nil {
+		return "", err
+	}
+	core := minio.Core{Client: mgr.client}
+	part, err := core.PutObjectPart(context.Background(), bucketName, objectName, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+func (mgr *minioManager) CompleteMultipartUpload(objectName string, uploadID string, parts []minio.CompletePart, bucketName string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	core := minio.Core{Client: mgr.client}
+	if _, err := core.CompleteMultipartUpload(context.Background(), bucketName, objectName, uploadID, parts, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mgr *minioManager) AbortMultipartUpload(objectName string, uploadID string, bucketName string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	core := minio.Core{Client: mgr.client}
+	return core.AbortMultipartUpload(context.Background(), bucketName, objectName, uploadID)
+}
+
 func (mgr *minioManager) GetObject(objectName string, bucketName string, opts minio.GetObjectOptions) (*bytes.Buffer, *int64, error) {
 	if err := mgr.Connect(); err != nil {
 		return nil, nil, err
@@ -12,7 +12,11 @@ package infra
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
@@ -23,6 +27,9 @@ type RedisManager struct {
 	redisConfig   config.RedisConfig
 	client        *redis.Client
 	clusterClient *redis.ClusterClient
+	// connectMu guards the lazy connection, since the manager can be shared
+	// by goroutines
+	connectMu sync.Mutex
 }
 
 func NewRedisManager(redisConfig config.RedisConfig) *RedisManager {
@@ -82,7 +89,427 @@ func (mgr *RedisManager) Delete(key string) error {
 	return nil
 }
 
+func (mgr *RedisManager) SetWithExpiry(key string, value interface{}, expiration time.Duration) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.Set(context.Background(), key, value, expiration).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.Set(context.Background(), key, value, expiration).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MGet returns the values of the keys in the same order, with nil for the keys
+// that don't exist. A cluster cannot serve keys of different slots with a
+// single MGET, so the keys are read through a pipeline of GET instead.
+func (mgr *RedisManager) MGet(keys ...string) ([]interface{}, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if mgr.clusterClient != nil {
+		pipe := mgr.clusterClient.Pipeline()
+		cmds := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.Get(context.Background(), key)
+		}
+		if _, err := pipe.Exec(context.Background()); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		res := make([]interface{}, len(keys))
+		for i, cmd := range cmds {
+			if value, err := cmd.Result(); err == nil {
+				res[i] = value
+			}
+		}
+		return res, nil
+	} else {
+		return mgr.client.MGet(context.Background(), keys...).Result()
+	}
+}
+
+// SetManyNX sets the values of the keys that don't exist yet in a single
+// round-trip, each with its own expiry, and returns the keys that were set.
+func (mgr *RedisManager) SetManyNX(values map[string]interface{}, expiration time.Duration) ([]string, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var pipe redis.Pipeliner
+	if mgr.clusterClient != nil {
+		pipe = mgr.clusterClient.Pipeline()
+	} else {
+		pipe = mgr.client.Pipeline()
+	}
+	cmds := make(map[string]*redis.BoolCmd, len(values))
+	for key, value := range values {
+		cmds[key] = pipe.SetNX(context.Background(), key, value, expiration)
+	}
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return nil, err
+	}
+	var res []string
+	for key, cmd := range cmds {
+		if cmd.Val() {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+func (mgr *RedisManager) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	if err := mgr.Connect(); err != nil {
+		return false, err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.SetNX(context.Background(), key, value, expiration).Result()
+	} else {
+		return mgr.client.SetNX(context.Background(), key, value, expiration).Result()
+	}
+}
+
+var deleteIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfEqual atomically deletes the key only if it still holds value, so
+// that a holder whose key has expired cannot delete the one set by another.
+func (mgr *RedisManager) DeleteIfEqual(key string, value string) (bool, error) {
+	if err := mgr.Connect(); err != nil {
+		return false, err
+	}
+	var res int64
+	var err error
+	if mgr.clusterClient != nil {
+		res, err = deleteIfEqualScript.Run(context.Background(), mgr.clusterClient, []string{key}, value).Int64()
+	} else {
+		res, err = deleteIfEqualScript.Run(context.Background(), mgr.client, []string{key}, value).Int64()
+	}
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+var incrWithExpiryScript = redis.NewScript(`
+local res = redis.call("INCR", KEYS[1])
+if res == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return res
+`)
+
+// IncrWithExpiry atomically increments the counter at key and returns its new
+// value, the counter expires after expiration counting from its first increment.
+func (mgr *RedisManager) IncrWithExpiry(key string, expiration time.Duration) (int64, error) {
+	if err := mgr.Connect(); err != nil {
+		return 0, err
+	}
+	if mgr.clusterClient != nil {
+		return incrWithExpiryScript.Run(context.Background(), mgr.clusterClient, []string{key}, expiration.Milliseconds()).Int64()
+	} else {
+		return incrWithExpiryScript.Run(context.Background(), mgr.client, []string{key}, expiration.Milliseconds()).Int64()
+	}
+}
+
+func (mgr *RedisManager) HSet(key string, field string, value interface{}) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.HSet(context.Background(), key, field, value).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.HSet(context.Background(), key, field, value).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mgr *RedisManager) HGetAll(key string) (map[string]string, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.HGetAll(context.Background(), key).Result()
+	} else {
+		return mgr.client.HGetAll(context.Background(), key).Result()
+	}
+}
+
+func (mgr *RedisManager) HDel(key string, fields ...string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.HDel(context.Background(), key, fields...).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.HDel(context.Background(), key, fields...).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// XGroupCreate creates the consumer group and the stream if they don't exist
+// yet, it succeeds if the group already exists.
+func (mgr *RedisManager) XGroupCreate(stream string, group string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	var err error
+	if mgr.clusterClient != nil {
+		err = mgr.clusterClient.XGroupCreateMkStream(context.Background(), stream, group, "0").Err()
+	} else {
+		err = mgr.client.XGroupCreateMkStream(context.Background(), stream, group, "0").Err()
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (mgr *RedisManager) XAdd(stream string, values map[string]interface{}) (string, error) {
+	if err := mgr.Connect(); err != nil {
+		return "", err
+	}
+	args := &redis.XAddArgs{Stream: stream, Values: values}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.XAdd(context.Background(), args).Result()
+	} else {
+		return mgr.client.XAdd(context.Background(), args).Result()
+	}
+}
+
+// XReadGroup reads new messages for the consumer, it blocks up to the given
+// duration and returns no messages and no error if nothing arrived meanwhile.
+func (mgr *RedisManager) XReadGroup(stream string, group string, consumer string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	args := &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}
+	var res []redis.XStream
+	var err error
+	if mgr.clusterClient != nil {
+		res, err = mgr.clusterClient.XReadGroup(context.Background(), args).Result()
+	} else {
+		res, err = mgr.client.XReadGroup(context.Background(), args).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var messages []redis.XMessage
+	for _, s := range res {
+		messages = append(messages, s.Messages...)
+	}
+	return messages, nil
+}
+
+// XAutoClaim transfers to the consumer the pending messages that have been
+// idle for at least minIdle, i.e. whose previous consumer likely died.
+func (mgr *RedisManager) XAutoClaim(stream string, group string, consumer string, minIdle time.Duration, count int64) ([]redis.XMessage, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	args := &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    count,
+	}
+	var res []redis.XMessage
+	var err error
+	if mgr.clusterClient != nil {
+		res, _, err = mgr.clusterClient.XAutoClaim(context.Background(), args).Result()
+	} else {
+		res, _, err = mgr.client.XAutoClaim(context.Background(), args).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// XClaimJustID claims the messages again for the same consumer, which resets
+// their idle time, this is used as a heartbeat for long-running work.
+func (mgr *RedisManager) XClaimJustID(stream string, group string, consumer string, ids ...string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	args := &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		Messages: ids,
+	}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.XClaimJustID(context.Background(), args).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.XClaimJustID(context.Background(), args).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// XAckDel acknowledges and deletes the messages, so that the stream only holds
+// the messages that are either waiting or being processed.
+func (mgr *RedisManager) XAckDel(stream string, group string, ids ...string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.XAck(context.Background(), stream, group, ids...).Result(); err != nil {
+			return err
+		}
+		if _, err := mgr.clusterClient.XDel(context.Background(), stream, ids...).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.XAck(context.Background(), stream, group, ids...).Result(); err != nil {
+			return err
+		}
+		if _, err := mgr.client.XDel(context.Background(), stream, ids...).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mgr *RedisManager) XLen(stream string) (int64, error) {
+	if err := mgr.Connect(); err != nil {
+		return 0, err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.XLen(context.Background(), stream).Result()
+	} else {
+		return mgr.client.XLen(context.Background(), stream).Result()
+	}
+}
+
+func (mgr *RedisManager) ZAdd(key string, score float64, member string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	z := redis.Z{Score: score, Member: member}
+	if mgr.clusterClient != nil {
+		if _, err := mgr.clusterClient.ZAdd(context.Background(), key, z).Result(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := mgr.client.ZAdd(context.Background(), key, z).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZRangeByMaxScore returns up to count members whose score is lower than or
+// equal to max, in ascending order of score.
+func (mgr *RedisManager) ZRangeByMaxScore(key string, max float64, count int64) ([]string, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	opt := &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.ZRangeByScore(context.Background(), key, opt).Result()
+	} else {
+		return mgr.client.ZRangeByScore(context.Background(), key, opt).Result()
+	}
+}
+
+// ZRem removes the members and returns how many were actually removed, which
+// lets concurrent callers find out which one of them removed a member.
+func (mgr *RedisManager) ZRem(key string, members ...interface{}) (int64, error) {
+	if err := mgr.Connect(); err != nil {
+		return 0, err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.ZRem(context.Background(), key, members...).Result()
+	} else {
+		return mgr.client.ZRem(context.Background(), key, members...).Result()
+	}
+}
+
+func (mgr *RedisManager) ZCard(key string) (int64, error) {
+	if err := mgr.Connect(); err != nil {
+		return 0, err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.ZCard(context.Background(), key).Result()
+	} else {
+		return mgr.client.ZCard(context.Background(), key).Result()
+	}
+}
+
+func (mgr *RedisManager) Publish(channel string, message string) error {
+	if err := mgr.Connect(); err != nil {
+		return err
+	}
+	if mgr.clusterClient != nil {
+		return mgr.clusterClient.Publish(context.Background(), channel, message).Err()
+	} else {
+		return mgr.client.Publish(context.Background(), channel, message).Err()
+	}
+}
+
+// Subscribe listens to the channels, the messages are received through
+// Channel() of the returned subscription, which must be closed once done.
+func (mgr *RedisManager) Subscribe(channels ...string) (*redis.PubSub, error) {
+	if err := mgr.Connect(); err != nil {
+		return nil, err
+	}
+	var res *redis.PubSub
+	if mgr.clusterClient != nil {
+		res = mgr.clusterClient.Subscribe(context.Background(), channels...)
+	} else {
+		res = mgr.client.Subscribe(context.Background(), channels...)
+	}
+	/* Wait for the confirmation, so that no message published afterwards is missed */
+	if _, err := res.Receive(context.Background()); err != nil {
+		_ = res.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
 func (mgr *RedisManager) Close() error {
+	mgr.connectMu.Lock()
+	defer mgr.connectMu.Unlock()
 	if mgr.client != nil {
 		if err := mgr.client.Close(); err != nil {
 			return err
@@ -92,6 +519,8 @@ func (mgr *RedisManager) Close() error {
 }
 
 func (mgr *RedisManager) Connect() error {
+	mgr.connectMu.Lock()
+	defer mgr.connectMu.Unlock()
 	if mgr.client != nil || mgr.clusterClient != nil {
 		return nil
 	}
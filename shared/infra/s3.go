@@ -12,6 +12,7 @@ package infra
 
 import (
 	"bytes"
+	"io"
 
 	"github.com/minio/minio-go/v7"
 
@@ -24,6 +25,12 @@ type S3Manager interface {
 	GetFile(objectName string, filePath string, bucketName string, opts minio.GetObjectOptions) error
 	PutFile(objectName string, filePath string, contentType string, bucketName string, opts minio.PutObjectOptions) error
 	PutText(objectName string, text string, contentType string, bucketName string, opts minio.PutObjectOptions) error
+	PutObject(objectName string, reader io.Reader, size int64, contentType string, bucketName string, opts minio.PutObjectOptions) error
+	RemoveIncompleteUpload(objectName string, bucketName string) error
+	NewMultipartUpload(objectName string, contentType string, bucketName string) (string, error)
+	PutObjectPart(objectName string, uploadID string, partNumber int, reader io.Reader, size int64, bucketName string) (string, error)
+	CompleteMultipartUpload(objectName string, uploadID string, parts []minio.CompletePart, bucketName string) error
+	AbortMultipartUpload(objectName string, uploadID string, bucketName string) error
 	GetObject(objectName string, bucketName string, opts minio.GetObjectOptions) (*bytes.Buffer, *int64, error)
 	GetObjectWithBuffer(objectName string, bucketName string, buf *bytes.Buffer, opts minio.GetObjectOptions) (*int64, error)
 	GetText(objectName string, bucketName string, opts minio.GetObjectOptions) (string, error)
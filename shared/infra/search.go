@@ -14,9 +14,16 @@ import "github.com/kouprlabs/voltaserve/shared/config"
 
 type SearchManager interface {
 	Query(index string, query string, opts SearchQueryOptions) ([]interface{}, error)
+	Search(index string, query string, opts SearchOptions) (*SearchResult, error)
 	Index(index string, models []SearchModel) error
 	Update(index string, models []SearchModel) error
 	Delete(index string, ids []string) error
+	// FindIDs returns the IDs of all the documents of the index.
+	FindIDs(index string) ([]string, error)
+	// EnableVectors lets the documents of the index carry a vector of the
+	// given dimensions, which is required before calling SearchVector.
+	EnableVectors(index string, dimensions int) error
+	SearchVector(index string, vector []float32, opts SearchVectorOptions) ([]*SearchVectorHit, error)
 }
 
 func NewSearchManager(searchConfig config.SearchConfig, envConfig config.EnvironmentConfig) SearchManager {
@@ -31,11 +38,75 @@ type SearchModel interface {
 	GetID() string
 }
 
+// SearchVectorModel is a document that is also found by its vector.
+type SearchVectorModel interface {
+	SearchModel
+	GetVector() []float32
+}
+
+// SearchVectorEmbedder is the name under which the documents of an index
+// carry their vector.
+const SearchVectorEmbedder = "default"
+
 type SearchQueryOptions struct {
 	Limit  int64
 	Filter interface{}
 }
 
+const (
+	SearchHighlightPreTag  = "<mark>"
+	SearchHighlightPostTag = "</mark>"
+	// SearchSnippetLength is the number of words of a highlighted snippet.
+	SearchSnippetLength = 30
+	// SearchFacetSize is the maximum number of values returned per facet.
+	SearchFacetSize = 100
+)
+
+// SearchOptions describes a search that is paginated and sorted by the search
+// engine. Page starts at 1. Without Sort, hits are sorted by relevance.
+type SearchOptions struct {
+	Filter    interface{}
+	Page      int64
+	Size      int64
+	Sort      []SearchSort
+	Facets    []string
+	Highlight []string
+	Retrieve  []string
+}
+
+type SearchSort struct {
+	Field      string
+	Descending bool
+}
+
+type SearchResult struct {
+	Hits      []*SearchHit
+	TotalHits int64
+	// Facets counts the hits per value of each requested facet.
+	Facets map[string]map[string]int64
+}
+
+type SearchHit struct {
+	Document map[string]interface{}
+	// Highlights contains snippets of the requested fields, where the matches
+	// are surrounded by SearchHighlightPreTag and SearchHighlightPostTag.
+	Highlights map[string][]string
+}
+
+// SearchVectorOptions describes a nearest neighbor search, the hits are sorted
+// by decreasing similarity with the vector, and at most Limit are returned.
+type SearchVectorOptions struct {
+	Filter   interface{}
+	Limit    int64
+	Retrieve []string
+}
+
+type SearchVectorHit struct {
+	Document map[string]interface{}
+	// Score is between 0 and 1, where 1 means the same direction.
+	Score float64
+}
+
 const (
 	FileSearchIndex         = "file"
 	GroupSearchIndex        = "group"
@@ -43,4 +114,7 @@ const (
 	OrganizationSearchIndex = "organization"
 	TaskSearchIndex         = "task"
 	UserSearchIndex         = "user"
+	// SnapshotChunkSearchIndex holds the vectors of the chunks of the text of
+	// the snapshots, which are used by the semantic search.
+	SnapshotChunkSearchIndex = "snapshot_chunk"
 )
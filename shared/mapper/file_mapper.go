@@ -46,7 +46,11 @@ func (mp *FileMapper) Map(m model.File, userID string) (*dto.File, error) {
 	if err != nil {
 		return nil, err
 	}
-	return mp.mapWithWorkspace(m, workspace, userID)
+	snapshots, groups, err := mp.prefetch([]model.File{m})
+	if err != nil {
+		return nil, err
+	}
+	return mp.mapWithWorkspace(m, workspace, snapshots, groups, userID)
 }
 
 func (mp *FileMapper) MapMany(data []model.File, workspaceID string, userID string) ([]*dto.File, error) {
@@ -55,8 +59,12 @@ func (mp *FileMapper) MapMany(data []model.File, workspaceID string, userID stri
 	if err != nil {
 		return nil, err
 	}
+	snapshots, groups, err := mp.prefetch(data)
+	if err != nil {
+		return nil, err
+	}
 	for _, file := range data {
-		f, err := mp.mapWithWorkspace(file, workspace, userID)
+		f, err := mp.mapWithWorkspace(file, workspace, snapshots, groups, userID)
 		if err != nil {
 			var e *errorpkg.ErrorResponse
 			if errors.As(err, &e) && e.Code == errorpkg.NewFileNotFoundError(nil).Code {
@@ -70,7 +78,13 @@ func (mp *FileMapper) MapMany(data []model.File, workspaceID string, userID stri
 	return res, nil
 }
 
-func (mp *FileMapper) mapWithWorkspace(m model.File, workspace *dto.Workspace, userID string) (*dto.File, error) {
+func (mp *FileMapper) mapWithWorkspace(
+	m model.File,
+	workspace *dto.Workspace,
+	snapshots map[string]model.Snapshot,
+	groups map[string]model.Group,
+	userID string,
+) (*dto.File, error) {
 	res := &dto.File{
 		ID:         m.GetID(),
 		Workspace:  *workspace,
@@ -81,9 +95,9 @@ func (mp *FileMapper) mapWithWorkspace(m model.File, workspace *dto.Workspace, u
 		UpdateTime: m.GetUpdateTime(),
 	}
 	if m.GetSnapshotID() != nil {
-		snapshot, err := mp.snapshotCache.Get(*m.GetSnapshotID())
-		if err != nil {
-			return nil, err
+		snapshot, ok := snapshots[*m.GetSnapshotID()]
+		if !ok {
+			return nil, errorpkg.NewSnapshotNotFoundError(nil)
 		}
 		res.Snapshot = mp.snapshotMapper.Map(snapshot)
 		res.Snapshot.IsActive = true
@@ -95,9 +109,9 @@ func (mp *FileMapper) mapWithWorkspace(m model.File, workspace *dto.Workspace, u
 		}
 	}
 	for _, p := range m.GetGroupPermissions() {
-		g, err := mp.groupCache.Get(p.GetGroupID())
-		if err != nil {
-			return nil, err
+		g, ok := groups[p.GetGroupID()]
+		if !ok {
+			continue
 		}
 		for _, u := range g.GetMembers() {
 			if u == userID && model.GetPermissionWeight(p.GetValue()) > model.GetPermissionWeight(res.Permission) {
@@ -123,6 +137,34 @@ func (mp *FileMapper) mapWithWorkspace(m model.File, workspace *dto.Workspace, u
 	return res, nil
 }
 
+// prefetch fetches the active snapshots and the groups of the files, keyed by
+// ID, with a single batch each.
+func (mp *FileMapper) prefetch(files []model.File) (map[string]model.Snapshot, map[string]model.Group, error) {
+	var snapshotIDs []string
+	var permissions []model.CoreGroupPermission
+	for _, f := range files {
+		if f.GetSnapshotID() != nil {
+			snapshotIDs = append(snapshotIDs, *f.GetSnapshotID())
+		}
+		permissions = append(permissions, f.GetGroupPermissions()...)
+	}
+	snapshots := make(map[string]model.Snapshot, len(snapshotIDs))
+	if len(snapshotIDs) > 0 {
+		data, err := mp.snapshotCache.GetMany(snapshotIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range data {
+			snapshots[s.GetID()] = s
+		}
+	}
+	groups, err := findGroups(mp.groupCache, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshots, groups, nil
+}
+
 func (mp *FileMapper) findWorkspace(workspaceID string, userID string) (*dto.Workspace, error) {
 	workspace, err := mp.workspaceCache.Get(workspaceID)
 	if err != nil {
@@ -0,0 +1,40 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package mapper
+
+import (
+	"slices"
+
+	"github.com/kouprlabs/voltaserve/shared/cache"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+// findGroups returns the groups of the permissions keyed by ID, fetched from
+// the cache in a single batch, groups which no longer exist are left out.
+func findGroups(groupCache *cache.GroupCache, permissions []model.CoreGroupPermission) (map[string]model.Group, error) {
+	res := make(map[string]model.Group)
+	if len(permissions) == 0 {
+		return res, nil
+	}
+	ids := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		ids = append(ids, p.GetGroupID())
+	}
+	slices.Sort(ids)
+	groups, err := groupCache.GetMany(slices.Compact(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		res[g.GetID()] = g
+	}
+	return res, nil
+}
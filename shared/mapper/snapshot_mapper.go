@@ -42,6 +42,10 @@ func (mp *SnapshotMapper) Map(m model.Snapshot) *dto.Snapshot {
 		Language:   m.GetLanguage(),
 		Summary:    m.GetSummary(),
 		Intent:     m.GetIntent(),
+		Label:      m.GetLabel(),
+		Note:       m.GetNote(),
+		UserID:     m.GetUserID(),
+		IsPinned:   m.GetIsPinned(),
 		CreateTime: m.GetCreateTime(),
 		UpdateTime: m.GetUpdateTime(),
 	}
@@ -68,12 +72,17 @@ func (mp *SnapshotMapper) Map(m model.Snapshot) *dto.Snapshot {
 	if m.GetSummary() != nil {
 		s.Capabilities.Summary = true
 	}
+	s.Metadata = m.GetMetadata()
 	if m.HasEntities() {
 		s.Capabilities.Entities = true
 	}
 	if m.HasMosaic() {
 		s.Capabilities.Mosaic = true
 	}
+	if m.HasHLS() {
+		s.HLS = mp.MapS3Object(m.GetHLS())
+		s.Capabilities.HLS = true
+	}
 	if m.GetTaskID() != nil {
 		task, err := mp.taskCache.Get(*m.GetTaskID())
 		if err == nil {
@@ -117,6 +126,9 @@ func (mp *SnapshotMapper) MapS3Object(o *model.S3Object) *dto.SnapshotDownloadab
 	if o.Document != nil {
 		download.Document = o.Document
 	}
+	if o.Media != nil {
+		download.Media = o.Media
+	}
 	return download
 }
 
@@ -129,11 +141,17 @@ func (mp *SnapshotMapper) MapWithS3Objects(m model.Snapshot) *dto.SnapshotWithS3
 		OCR:        m.GetOCR(),
 		Text:       m.GetText(),
 		Thumbnail:  m.GetThumbnail(),
+		Metadata:   m.GetMetadata(),
 		Entities:   m.GetEntities(),
 		Mosaic:     m.GetMosaic(),
+		HLS:        m.GetHLS(),
 		Language:   m.GetLanguage(),
 		Summary:    m.GetSummary(),
 		Intent:     m.GetIntent(),
+		Label:      m.GetLabel(),
+		Note:       m.GetNote(),
+		UserID:     m.GetUserID(),
+		IsPinned:   m.GetIsPinned(),
 		CreateTime: m.GetCreateTime(),
 		UpdateTime: m.GetUpdateTime(),
 	}
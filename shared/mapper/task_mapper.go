@@ -39,7 +39,7 @@ func (mp *TaskMapper) Map(m model.Task) (*dto.Task, error) {
 		IsIndeterminate: m.GetIsIndeterminate(),
 		UserID:          m.GetUserID(),
 		Status:          m.GetStatus(),
-		IsDismissible:   m.GetStatus() == model.TaskStatusSuccess || m.GetStatus() == model.TaskStatusError,
+		IsDismissible:   m.GetStatus() == model.TaskStatusSuccess || m.GetStatus() == model.TaskStatusError || m.GetStatus() == model.TaskStatusCancelled,
 		Payload:         m.GetPayload(),
 		CreateTime:      m.GetCreateTime(),
 		UpdateTime:      m.GetUpdateTime(),
@@ -60,10 +60,14 @@ func (mp *WorkspaceMapper) Map(m model.Workspace, userID string) (*dto.Workspace
 			res.Permission = p.GetValue()
 		}
 	}
+	groups, err := findGroups(mp.groupCache, m.GetGroupPermissions())
+	if err != nil {
+		return nil, err
+	}
 	for _, p := range m.GetGroupPermissions() {
-		g, err := mp.groupCache.Get(p.GetGroupID())
-		if err != nil {
-			return nil, err
+		g, ok := groups[p.GetGroupID()]
+		if !ok {
+			continue
 		}
 		for _, u := range g.GetMembers() {
 			if u == userID && model.GetPermissionWeight(p.GetValue()) > model.GetPermissionWeight(res.Permission) {
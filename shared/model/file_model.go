@@ -25,6 +25,9 @@ type File interface {
 	GetGroupPermissions() []CoreGroupPermission
 	GetText() *string
 	GetSummary() *string
+	GetMetadata() *SnapshotMetadata
+	GetIntent() *string
+	GetLanguage() *string
 	GetSnapshotID() *string
 	GetCreateTime() string
 	GetUpdateTime() *string
@@ -35,6 +38,9 @@ type File interface {
 	SetName(string)
 	SetText(*string)
 	SetSummary(*string)
+	SetMetadata(*SnapshotMetadata)
+	SetIntent(*string)
+	SetLanguage(*string)
 	SetSnapshotID(*string)
 	SetUserPermissions([]CoreUserPermission)
 	SetGroupPermissions([]CoreGroupPermission)
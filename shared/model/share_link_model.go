@@ -0,0 +1,56 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package model
+
+const (
+	ShareLinkModeViewer = "viewer"
+	ShareLinkModeUpload = "upload"
+)
+
+const (
+	ShareLinkActionFind              = "find"
+	ShareLinkActionUnlock            = "unlock"
+	ShareLinkActionList              = "list"
+	ShareLinkActionDownloadOriginal  = "download_original"
+	ShareLinkActionDownloadPreview   = "download_preview"
+	ShareLinkActionDownloadThumbnail = "download_thumbnail"
+	ShareLinkActionUpload            = "upload"
+)
+
+type ShareLink interface {
+	GetID() string
+	GetFileID() string
+	GetWorkspaceID() string
+	GetUserID() string
+	GetToken() string
+	GetMode() string
+	GetPasswordHash() *string
+	GetExpiryTime() *string
+	GetMaxDownloads() *int
+	GetDownloadCount() int
+	GetRevokeTime() *string
+	GetCreateTime() string
+	GetUpdateTime() *string
+	HasPassword() bool
+	SetRevokeTime(*string)
+}
+
+type ShareLinkAccess interface {
+	GetID() string
+	GetShareLinkID() string
+	GetFileID() *string
+	GetAction() string
+	GetGranted() bool
+	GetErrorCode() *string
+	GetIP() *string
+	GetUserAgent() *string
+	GetCreateTime() string
+}
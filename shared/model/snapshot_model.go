@@ -17,6 +17,8 @@ const (
 	SnapshotFieldOCR       = "ocr"
 	SnapshotFieldEntities  = "entities"
 	SnapshotFieldMosaic    = "mosaic"
+	SnapshotFieldHLS       = "hls"
+	SnapshotFieldMetadata  = "metadata"
 	SnapshotFieldThumbnail = "thumbnail"
 	SnapshotFieldLanguage  = "language"
 	SnapshotFieldSummary   = "summary"
@@ -41,16 +43,23 @@ type Snapshot interface {
 	GetOCR() *S3Object
 	GetEntities() *S3Object
 	GetMosaic() *S3Object
+	GetHLS() *S3Object
 	GetThumbnail() *S3Object
+	GetMetadata() *SnapshotMetadata
 	GetSummary() *string
 	GetIntent() *string
 	GetTaskID() *string
+	GetIsPinned() bool
+	GetLabel() *string
+	GetNote() *string
+	GetUserID() *string
 	HasOriginal() bool
 	HasPreview() bool
 	HasText() bool
 	HasOCR() bool
 	HasEntities() bool
 	HasMosaic() bool
+	HasHLS() bool
 	HasThumbnail() bool
 	GetLanguage() *string
 	GetCreateTime() string
@@ -63,11 +72,17 @@ type Snapshot interface {
 	SetOCR(*S3Object)
 	SetEntities(*S3Object)
 	SetMosaic(*S3Object)
+	SetHLS(*S3Object)
 	SetThumbnail(*S3Object)
+	SetMetadata(*SnapshotMetadata)
 	SetSummary(*string)
 	SetIntent(*string)
 	SetLanguage(*string)
 	SetTaskID(*string)
+	SetIsPinned(bool)
+	SetLabel(*string)
+	SetNote(*string)
+	SetUserID(*string)
 	SetCreateTime(string)
 	SetUpdateTime(*string)
 }
@@ -78,6 +93,7 @@ type S3Object struct {
 	Size     int64          `json:"size"`
 	Image    *ImageProps    `json:"image,omitempty"`
 	Document *DocumentProps `json:"document,omitempty"`
+	Media    *MediaProps    `json:"media,omitempty"`
 }
 
 type ImageProps struct {
@@ -85,6 +101,20 @@ type ImageProps struct {
 	Height int `json:"height"`
 }
 
+type MediaProps struct {
+	Duration   float64          `json:"duration"`
+	Width      int              `json:"width,omitempty"`
+	Height     int              `json:"height,omitempty"`
+	Renditions []RenditionProps `json:"renditions,omitempty"`
+}
+
+type RenditionProps struct {
+	Name      string `json:"name"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Bandwidth int    `json:"bandwidth"`
+}
+
 type DocumentProps struct {
 	Page      *PageProps      `json:"page,omitempty"`
 	Thumbnail *ThumbnailProps `json:"thumbnail,omitempty"`
@@ -99,6 +129,36 @@ type ThumbnailProps struct {
 	Extension string `json:"extension"`
 }
 
+type SnapshotMetadata struct {
+	Media    *MediaMetadata    `json:"media,omitempty"`
+	Image    *ImageMetadata    `json:"image,omitempty"`
+	Document *DocumentMetadata `json:"document,omitempty"`
+}
+
+type MediaMetadata struct {
+	Duration      float64 `json:"duration"`
+	Bitrate       int64   `json:"bitrate,omitempty"`
+	VideoCodec    *string `json:"videoCodec,omitempty"`
+	AudioCodec    *string `json:"audioCodec,omitempty"`
+	FrameRate     float64 `json:"frameRate,omitempty"`
+	AudioChannels int     `json:"audioChannels,omitempty"`
+}
+
+type ImageMetadata struct {
+	CameraMake  *string  `json:"cameraMake,omitempty"`
+	CameraModel *string  `json:"cameraModel,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	CaptureTime *string  `json:"captureTime,omitempty"`
+	Orientation *int     `json:"orientation,omitempty"`
+}
+
+type DocumentMetadata struct {
+	Title        *string `json:"title,omitempty"`
+	Author       *string `json:"author,omitempty"`
+	CreationDate *string `json:"creationDate,omitempty"`
+}
+
 type S3Reference struct {
 	Bucket      string `json:"bucket"`
 	Key         string `json:"key"`
@@ -0,0 +1,32 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package model
+
+type SnapshotRetentionPolicy interface {
+	GetID() string
+	GetWorkspaceID() string
+	GetKeepLast() *int
+	GetKeepAllDays() *int
+	GetKeepDailyDays() *int
+	GetKeepWeeklyWeeks() *int
+	GetLastRunTime() *string
+	GetLastDeletedCount() *int
+	GetLastFreedBytes() *int64
+	GetCreateTime() string
+	GetUpdateTime() *string
+	SetKeepLast(*int)
+	SetKeepAllDays(*int)
+	SetKeepDailyDays(*int)
+	SetKeepWeeklyWeeks(*int)
+	SetLastRunTime(*string)
+	SetLastDeletedCount(*int)
+	SetLastFreedBytes(*int64)
+}
@@ -11,10 +11,11 @@
 package model
 
 const (
-	TaskStatusWaiting = "waiting"
-	TaskStatusRunning = "running"
-	TaskStatusSuccess = "success"
-	TaskStatusError   = "error"
+	TaskStatusWaiting   = "waiting"
+	TaskStatusRunning   = "running"
+	TaskStatusSuccess   = "success"
+	TaskStatusError     = "error"
+	TaskStatusCancelled = "cancelled"
 )
 
 const (
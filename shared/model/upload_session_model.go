@@ -0,0 +1,59 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package model
+
+const (
+	UploadSessionStatusUploading  = "uploading"
+	UploadSessionStatusFinalizing = "finalizing"
+)
+
+type UploadSession interface {
+	GetID() string
+	GetUserID() string
+	GetWorkspaceID() string
+	GetParentID() *string
+	GetFileID() *string
+	GetName() string
+	GetSize() int64
+	GetContentType() string
+	GetBucket() string
+	GetKey() string
+	GetSnapshotID() string
+	GetS3UploadID() string
+	GetStatus() string
+	GetExpiryTime() string
+	GetCreateTime() string
+	GetUpdateTime() *string
+	SetID(string)
+	SetUserID(string)
+	SetWorkspaceID(string)
+	SetParentID(*string)
+	SetFileID(*string)
+	SetName(string)
+	SetSize(int64)
+	SetContentType(string)
+	SetBucket(string)
+	SetKey(string)
+	SetSnapshotID(string)
+	SetS3UploadID(string)
+	SetStatus(string)
+	SetExpiryTime(string)
+	SetCreateTime(string)
+	SetUpdateTime(*string)
+}
+
+type UploadSessionPart interface {
+	GetSessionID() string
+	GetNumber() int
+	GetSize() int64
+	GetETag() string
+	GetCreateTime() string
+}
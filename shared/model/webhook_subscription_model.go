@@ -0,0 +1,65 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package model
+
+const (
+	WebhookEventFileCreated       = "file.created"
+	WebhookEventFileUpdated       = "file.updated"
+	WebhookEventFileMoved         = "file.moved"
+	WebhookEventFileDeleted       = "file.deleted"
+	WebhookEventSnapshotActivated = "snapshot.activated"
+	WebhookEventPermissionChanged = "permission.changed"
+	WebhookEventTaskFinished      = "task.finished"
+)
+
+const (
+	WebhookDeliveryStatusPending = "pending"
+	WebhookDeliveryStatusSuccess = "success"
+	WebhookDeliveryStatusFailed  = "failed"
+)
+
+type WebhookSubscription interface {
+	GetID() string
+	GetWorkspaceID() string
+	GetUserID() string
+	GetURL() string
+	GetSecret() string
+	GetEvents() []string
+	GetIsActive() bool
+	GetCreateTime() string
+	GetUpdateTime() *string
+	SetURL(string)
+	SetEvents([]string)
+	SetIsActive(bool)
+}
+
+type WebhookDelivery interface {
+	GetID() string
+	GetSubscriptionID() string
+	GetEventType() string
+	GetPayload() string
+	GetStatus() string
+	GetAttempts() int
+	GetNextAttemptTime() string
+	GetLastAttemptTime() *string
+	GetResponseStatus() *int
+	GetResponseBody() *string
+	GetError() *string
+	GetCreateTime() string
+	GetUpdateTime() *string
+	SetStatus(string)
+	SetAttempts(int)
+	SetNextAttemptTime(string)
+	SetLastAttemptTime(*string)
+	SetResponseStatus(*int)
+	SetResponseBody(*string)
+	SetError(*string)
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type filePropertyEntity struct {
+	ID         string  `gorm:"column:id"          json:"id"`
+	FileID     string  `gorm:"column:file_id"     json:"fileId"`
+	Namespace  string  `gorm:"column:namespace"   json:"namespace"`
+	Name       string  `gorm:"column:name"        json:"name"`
+	Value      *string `gorm:"column:value"       json:"value,omitempty"`
+	CreateTime string  `gorm:"column:create_time" json:"createTime"`
+	UpdateTime *string `gorm:"column:update_time" json:"updateTime,omitempty"`
+}
+
+func (*filePropertyEntity) TableName() string {
+	return "file_property"
+}
+
+func (e *filePropertyEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *filePropertyEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *filePropertyEntity) GetID() string {
+	return e.ID
+}
+
+func (e *filePropertyEntity) GetFileID() string {
+	return e.FileID
+}
+
+func (e *filePropertyEntity) GetNamespace() string {
+	return e.Namespace
+}
+
+func (e *filePropertyEntity) GetName() string {
+	return e.Name
+}
+
+func (e *filePropertyEntity) GetValue() *string {
+	return e.Value
+}
+
+func (e *filePropertyEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *filePropertyEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *filePropertyEntity) SetID(id string) {
+	e.ID = id
+}
+
+func (e *filePropertyEntity) SetFileID(fileID string) {
+	e.FileID = fileID
+}
+
+func (e *filePropertyEntity) SetNamespace(namespace string) {
+	e.Namespace = namespace
+}
+
+func (e *filePropertyEntity) SetName(name string) {
+	e.Name = name
+}
+
+func (e *filePropertyEntity) SetValue(value *string) {
+	e.Value = value
+}
+
+func (e *filePropertyEntity) SetCreateTime(createTime string) {
+	e.CreateTime = createTime
+}
+
+func (e *filePropertyEntity) SetUpdateTime(updateTime *string) {
+	e.UpdateTime = updateTime
+}
+
+func NewFilePropertyModel() model.FileProperty {
+	return &filePropertyEntity{}
+}
+
+type FilePropertyRepo struct {
+	db *gorm.DB
+}
+
+func NewFilePropertyRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *FilePropertyRepo {
+	return &FilePropertyRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+// Transaction runs fn with a repo bound to a transaction, which is rolled back
+// when fn returns an error.
+func (repo *FilePropertyRepo) Transaction(fn func(tx *FilePropertyRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&FilePropertyRepo{db: tx})
+	})
+}
+
+type FilePropertyUpsertOptions struct {
+	FileID    string
+	Namespace string
+	Name      string
+	Value     *string
+}
+
+// Upsert inserts the property, or replaces the value of the property with the
+// same namespace and name if the file already has one.
+func (repo *FilePropertyRepo) Upsert(opts FilePropertyUpsertOptions) error {
+	property := filePropertyEntity{
+		ID:        helper.NewID(),
+		FileID:    opts.FileID,
+		Namespace: opts.Namespace,
+		Name:      opts.Name,
+		Value:     opts.Value,
+	}
+	db := repo.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}, {Name: "namespace"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "update_time"}),
+		}).
+		Create(&property)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *FilePropertyRepo) FindByFileID(fileID string) ([]model.FileProperty, error) {
+	var entities []*filePropertyEntity
+	db := repo.db.
+		Raw(`SELECT * FROM file_property WHERE file_id = ? ORDER BY namespace, name`, fileID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.FileProperty
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// FindByFileIDs returns the properties of many files with a single query.
+func (repo *FilePropertyRepo) FindByFileIDs(fileIDs []string) ([]model.FileProperty, error) {
+	var entities []*filePropertyEntity
+	db := repo.db.
+		Raw(`SELECT * FROM file_property WHERE file_id IN (?) ORDER BY file_id, namespace, name`, fileIDs).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.FileProperty
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *FilePropertyRepo) Delete(fileID string, namespace string, name string) error {
+	db := repo.db.Exec(
+		`DELETE FROM file_property WHERE file_id = ? AND namespace = ? AND name = ?`,
+		fileID, namespace, name,
+	)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
@@ -12,6 +12,9 @@ package repo
 
 import (
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 
 	"gorm.io/gorm"
 
@@ -32,6 +35,9 @@ type fileEntity struct {
 	GroupPermissions []*GroupPermissionValue `gorm:"-"                   json:"groupPermissions"`
 	Text             *string                 `gorm:"-"                   json:"text,omitempty"`
 	Summary          *string                 `gorm:"-"                   json:"summary,omitempty"`
+	Metadata         *model.SnapshotMetadata `gorm:"-"                   json:"metadata,omitempty"`
+	Intent           *string                 `gorm:"-"                   json:"intent,omitempty"`
+	Language         *string                 `gorm:"-"                   json:"language,omitempty"`
 	SnapshotID       *string                 `gorm:"column:snapshot_id"  json:"snapshotId,omitempty"`
 	CreateTime       string                  `gorm:"column:create_time"  json:"createTime"`
 	UpdateTime       *string                 `gorm:"column:update_time"  json:"updateTime,omitempty"`
@@ -95,6 +101,18 @@ func (f *fileEntity) GetSummary() *string {
 	return f.Summary
 }
 
+func (f *fileEntity) GetMetadata() *model.SnapshotMetadata {
+	return f.Metadata
+}
+
+func (f *fileEntity) GetIntent() *string {
+	return f.Intent
+}
+
+func (f *fileEntity) GetLanguage() *string {
+	return f.Language
+}
+
 func (f *fileEntity) GetSnapshotID() *string {
 	return f.SnapshotID
 }
@@ -135,6 +153,18 @@ func (f *fileEntity) SetSummary(summary *string) {
 	f.Summary = summary
 }
 
+func (f *fileEntity) SetMetadata(metadata *model.SnapshotMetadata) {
+	f.Metadata = metadata
+}
+
+func (f *fileEntity) SetIntent(intent *string) {
+	f.Intent = intent
+}
+
+func (f *fileEntity) SetLanguage(language *string) {
+	f.Language = language
+}
+
 func (f *fileEntity) SetSnapshotID(snapshotID *string) {
 	f.SnapshotID = snapshotID
 }
@@ -172,6 +202,8 @@ type FileNewModelOptions struct {
 	Type             string
 	Name             string
 	Text             *string
+	Intent           *string
+	Language         *string
 	SnapshotID       *string
 	UserPermissions  []model.CoreUserPermission
 	GroupPermissions []model.CoreGroupPermission
@@ -187,6 +219,8 @@ func NewFileModelWithOptions(opts FileNewModelOptions) model.File {
 		Type:        opts.Type,
 		Name:        opts.Name,
 		Text:        opts.Text,
+		Intent:      opts.Intent,
+		Language:    opts.Language,
 		SnapshotID:  opts.SnapshotID,
 		CreateTime:  opts.CreateTime,
 		UpdateTime:  opts.UpdateTime,
@@ -260,6 +294,26 @@ func (repo *FileRepo) FindOrNil(id string) model.File {
 	return res
 }
 
+// FindMany returns the files of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *FileRepo) FindMany(ids []string) ([]model.File, error) {
+	res := make([]model.File, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*fileEntity
+	if db := repo.db.Raw("SELECT * FROM file WHERE id IN ?", ids).Scan(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	if err := repo.populateModelFields(entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *FileRepo) FindChildren(id string) ([]model.File, error) {
 	var entities []*fileEntity
 	db := repo.db.
@@ -343,13 +397,49 @@ func (repo *FileRepo) FindTreeIDs(id string) ([]string, error) {
 	return res, nil
 }
 
-func (repo *FileRepo) FindIDsByWorkspace(workspaceID string) ([]string, error) {
+// FindWithMoreSnapshotsThan returns, with a single query, the files of the
+// workspace that are associated with more than count snapshots.
+func (repo *FileRepo) FindWithMoreSnapshotsThan(workspaceID string, count int) ([]model.File, error) {
+	var entities []*fileEntity
+	db := repo.db.
+		Raw(`SELECT f.* FROM file f
+             WHERE f.workspace_id = ? AND f.type = ?
+             AND (SELECT COUNT(*) FROM snapshot_file sf WHERE sf.file_id = f.id) > ?
+             ORDER BY f.create_time`,
+			workspaceID, model.FileTypeFile, count).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	if err := repo.populateModelFields(entities); err != nil {
+		return nil, err
+	}
+	res := make([]model.File, 0, len(entities))
+	for _, f := range entities {
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the files can be walked through in batches.
+// The files in the trash, including the descendants of the trashed folders,
+// are skipped since they are not part of the search index.
+func (repo *FileRepo) FindIDsAfter(id string, limit int) ([]string, error) {
 	type IDResult struct {
 		Result string
 	}
 	var ids []IDResult
 	db := repo.db.
-		Raw("SELECT id result FROM file WHERE workspace_id = ? ORDER BY create_time", workspaceID).
+		Raw(`WITH RECURSIVE trashed (id) AS (
+				SELECT t.file_id FROM trash_item t
+				UNION
+				SELECT f.id FROM file f JOIN trashed ON f.parent_id = trashed.id
+			)
+			SELECT f.id result FROM file f
+			WHERE f.id > ? AND NOT EXISTS (SELECT 1 FROM trashed WHERE trashed.id = f.id)
+			ORDER BY f.id LIMIT ?`,
+			id, limit).
 		Scan(&ids)
 	if db.Error != nil {
 		return nil, db.Error
@@ -397,6 +487,183 @@ func (repo *FileRepo) FindChildrenIDs(id string) ([]string, error) {
 	return res, nil
 }
 
+const (
+	FileOrderByName         = "name"
+	FileOrderByKind         = "kind"
+	FileOrderBySize         = "size"
+	FileOrderByDateCreated  = "date_created"
+	FileOrderByDateModified = "date_modified"
+)
+
+type FileListChildrenOptions struct {
+	UserID           string
+	Type             *string
+	CreateTimeAfter  *int64
+	CreateTimeBefore *int64
+	UpdateTimeAfter  *int64
+	UpdateTimeBefore *int64
+	OrderBy          string
+	Descending       bool
+	// KindRanks ranks the extensions of the files when ordering by kind, folders
+	// always come first and the extensions without a rank last.
+	KindRanks map[string]int
+	// After and Before are mutually exclusive, when neither is set the listing
+	// starts at Offset.
+	After  *FileKeyset
+	Before *FileKeyset
+	Offset uint64
+	Limit  uint64
+}
+
+// FileKeyset is the position of a file in a listing, Value is the text form of
+// the key the listing is ordered by, the name and the ID break the ties.
+type FileKeyset struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ListChildren returns the keysets of the children the user can view, in the
+// order of the options, the permissions and the ordering are resolved by the
+// database, so only a page of rows leaves it.
+func (repo *FileRepo) ListChildren(id string, opts FileListChildrenOptions) ([]FileKeyset, error) {
+	key, keyType, args := repo.childrenOrderKey(opts)
+	where, whereArgs := repo.childrenWhere(id, opts)
+	args = append(args, whereArgs...)
+	descending := opts.Descending
+	if opts.Before != nil {
+		descending = !descending
+	}
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+	keyset := opts.After
+	if opts.Before != nil {
+		keyset = opts.Before
+	}
+	query := fmt.Sprintf(
+		`SELECT c.id, c.name, CAST(c.key AS text) AS value FROM
+         (SELECT f.id, f.name, %s AS key FROM file f LEFT JOIN snapshot s ON s.id = f.snapshot_id WHERE %s) c`,
+		key, where,
+	)
+	if keyset != nil {
+		query += fmt.Sprintf(` WHERE (c.key, c.name COLLATE "C", c.id) %s (CAST(? AS %s), ?, ?)`, comparison, keyType)
+		args = append(args, keyset.Value, keyset.Name, keyset.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY c.key %s, c.name COLLATE "C" %s, c.id %s LIMIT ?`, direction, direction, direction)
+	args = append(args, opts.Limit)
+	if keyset == nil {
+		query += " OFFSET ?"
+		args = append(args, opts.Offset)
+	}
+	var res []FileKeyset
+	if db := repo.db.Raw(query, args...).Scan(&res); db.Error != nil {
+		return nil, db.Error
+	}
+	if opts.Before != nil {
+		slices.Reverse(res)
+	}
+	return res, nil
+}
+
+// CountListedChildren returns the number of children the user can view,
+// ignoring the keyset and the pagination of the options.
+func (repo *FileRepo) CountListedChildren(id string, opts FileListChildrenOptions) (uint64, error) {
+	where, args := repo.childrenWhere(id, opts)
+	var res int64
+	if db := repo.db.
+		Raw(`SELECT count(*) FROM file f WHERE `+where, args...).
+		Scan(&res); db.Error != nil {
+		return 0, db.Error
+	}
+	return uint64(res), nil
+}
+
+// FindChildrenExtensions returns the distinct extensions of the active
+// snapshots of the children, lower-cased, with an empty one for the files
+// without any.
+func (repo *FileRepo) FindChildrenExtensions(id string) ([]string, error) {
+	var res []string
+	if db := repo.db.
+		Raw(`SELECT DISTINCT `+fileExtensionExpr+` FROM file f LEFT JOIN snapshot s ON s.id = f.snapshot_id
+             WHERE f.parent_id = ? AND f.type = ?`, id, model.FileTypeFile).
+		Scan(&res); db.Error != nil {
+		return nil, db.Error
+	}
+	return res, nil
+}
+
+// fileExtensionExpr is the extension of the original of the active snapshot,
+// the same way filepath.Ext would find it in the S3 key.
+const fileExtensionExpr = `COALESCE(lower(substring(s.original->>'key' from '\.[^./]*$')), '')`
+
+// childrenWhere returns the conditions on the children, including the viewer
+// permission of the user, granted either directly or through a group, where
+// the members of a group are the users with a permission on it.
+func (repo *FileRepo) childrenWhere(id string, opts FileListChildrenOptions) (string, []interface{}) {
+	permissions := []string{model.PermissionViewer, model.PermissionEditor, model.PermissionOwner}
+	where := `f.parent_id = ? AND (
+             EXISTS (SELECT 1 FROM userpermission up
+                     WHERE up.resource_id = f.id AND up.user_id = ? AND up.permission IN ?)
+             OR EXISTS (SELECT 1 FROM grouppermission gp
+                        JOIN userpermission m ON m.resource_id = gp.group_id AND m.user_id = ?
+                        WHERE gp.resource_id = f.id AND gp.permission IN ?))`
+	args := []interface{}{id, opts.UserID, permissions, opts.UserID, permissions}
+	if opts.Type != nil {
+		where += " AND f.type = ?"
+		args = append(args, *opts.Type)
+	}
+	if opts.CreateTimeAfter != nil {
+		where += " AND CAST(f.create_time AS timestamptz) >= to_timestamp(? / 1000.0)"
+		args = append(args, *opts.CreateTimeAfter)
+	}
+	if opts.CreateTimeBefore != nil {
+		where += " AND CAST(f.create_time AS timestamptz) <= to_timestamp(? / 1000.0)"
+		args = append(args, *opts.CreateTimeBefore)
+	}
+	if opts.UpdateTimeAfter != nil {
+		where += " AND CAST(f.update_time AS timestamptz) >= to_timestamp(? / 1000.0)"
+		args = append(args, *opts.UpdateTimeAfter)
+	}
+	if opts.UpdateTimeBefore != nil {
+		where += " AND CAST(f.update_time AS timestamptz) <= to_timestamp(? / 1000.0)"
+		args = append(args, *opts.UpdateTimeBefore)
+	}
+	return where, args
+}
+
+// childrenOrderKey returns the expression the children are ordered by, its SQL
+// type and its arguments.
+func (repo *FileRepo) childrenOrderKey(opts FileListChildrenOptions) (string, string, []interface{}) {
+	switch opts.OrderBy {
+	case FileOrderByName:
+		return `f.name COLLATE "C"`, "text", nil
+	case FileOrderBySize:
+		return `COALESCE(CAST(s.original->>'size' AS bigint), 0)`, "bigint", nil
+	case FileOrderByDateModified:
+		return "COALESCE(f.update_time, f.create_time)", "text", nil
+	case FileOrderByKind:
+		byRank := make(map[int][]string)
+		other := 1
+		for ext, rank := range opts.KindRanks {
+			byRank[rank] = append(byRank[rank], ext)
+			other = max(other, rank+1)
+		}
+		key := "CASE WHEN f.type = ? THEN 0"
+		args := []interface{}{model.FileTypeFolder}
+		for _, rank := range slices.Sorted(maps.Keys(byRank)) {
+			key += " WHEN " + fileExtensionExpr + " IN ? THEN ?"
+			args = append(args, byRank[rank], rank)
+		}
+		key += " ELSE ? END"
+		args = append(args, other)
+		return key, "integer", args
+	default:
+		return "f.create_time", "text", nil
+	}
+}
+
 func (repo *FileRepo) DeleteChunk(ids []string) error {
 	if db := repo.db.Delete(&fileEntity{}, ids); db.Error != nil {
 		return db.Error
@@ -690,29 +957,17 @@ func (repo *FileRepo) find(id string) (*fileEntity, error) {
 }
 
 func (repo *FileRepo) populateModelFields(entities []*fileEntity) error {
+	ids := make([]string, 0, len(entities))
 	for _, f := range entities {
-		f.UserPermissions = make([]*UserPermissionValue, 0)
-		userPermissions, err := repo.permissionRepo.FindUserPermissions(f.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range userPermissions {
-			f.UserPermissions = append(f.UserPermissions, &UserPermissionValue{
-				UserID: p.GetUserID(),
-				Value:  p.GetPermission(),
-			})
-		}
-		f.GroupPermissions = make([]*GroupPermissionValue, 0)
-		groupPermissions, err := repo.permissionRepo.FindGroupPermissions(f.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range groupPermissions {
-			f.GroupPermissions = append(f.GroupPermissions, &GroupPermissionValue{
-				GroupID: p.GetGroupID(),
-				Value:   p.GetPermission(),
-			})
-		}
+		ids = append(ids, f.ID)
+	}
+	userPermissions, groupPermissions, err := repo.permissionRepo.findPermissionValues(ids)
+	if err != nil {
+		return err
+	}
+	for _, f := range entities {
+		f.UserPermissions = userPermissions[f.ID]
+		f.GroupPermissions = groupPermissions[f.ID]
 	}
 	return nil
 }
@@ -217,6 +217,26 @@ func (repo *GroupRepo) FindOrNil(id string) model.Group {
 	return res
 }
 
+// FindMany returns the groups of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *GroupRepo) FindMany(ids []string) ([]model.Group, error) {
+	res := make([]model.Group, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*groupEntity
+	if db := repo.db.Where("id IN ?", ids).Find(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	if err := repo.populateModelFields(entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *GroupRepo) FindIDsByFile(fileID string) ([]string, error) {
 	type Value struct {
 		Result string
@@ -270,6 +290,26 @@ func (repo *GroupRepo) FindIDs() ([]string, error) {
 	return res, nil
 }
 
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the groups can be walked through in batches.
+func (repo *GroupRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM "group" WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *GroupRepo) FindIDsByOwner(userID string) ([]string, error) {
 	type IDResult struct {
 		Result string
@@ -289,6 +329,25 @@ func (repo *GroupRepo) FindIDsByOwner(userID string) ([]string, error) {
 	return res, nil
 }
 
+func (repo *GroupRepo) FindIDsByMember(userID string) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT g.id result FROM "group" g
+			 INNER JOIN userpermission up ON up.resource_id = g.id AND up.user_id = ?`,
+			userID).Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *GroupRepo) FindMembers(id string) ([]model.User, error) {
 	var entities []*userEntity
 	db := repo.db.
@@ -383,29 +442,17 @@ func (repo *GroupRepo) find(id string) (*groupEntity, error) {
 }
 
 func (repo *GroupRepo) populateModelFields(groups []*groupEntity) error {
+	ids := make([]string, 0, len(groups))
 	for _, g := range groups {
-		g.UserPermissions = make([]*UserPermissionValue, 0)
-		userPermissions, err := repo.permissionRepo.FindUserPermissions(g.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range userPermissions {
-			g.UserPermissions = append(g.UserPermissions, &UserPermissionValue{
-				UserID: p.GetUserID(),
-				Value:  p.GetPermission(),
-			})
-		}
-		g.GroupPermissions = make([]*GroupPermissionValue, 0)
-		groupPermissions, err := repo.permissionRepo.FindGroupPermissions(g.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range groupPermissions {
-			g.GroupPermissions = append(g.GroupPermissions, &GroupPermissionValue{
-				GroupID: p.GetGroupID(),
-				Value:   p.GetPermission(),
-			})
-		}
+		ids = append(ids, g.ID)
+	}
+	userPermissions, groupPermissions, err := repo.permissionRepo.findPermissionValues(ids)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		g.UserPermissions = userPermissions[g.ID]
+		g.GroupPermissions = groupPermissions[g.ID]
 		members, err := repo.FindMembers(g.ID)
 		if err != nil {
 			return nil
@@ -201,6 +201,26 @@ func (repo *OrganizationRepo) FindOrNil(id string) model.Organization {
 	return res
 }
 
+// FindMany returns the organizations of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *OrganizationRepo) FindMany(ids []string) ([]model.Organization, error) {
+	res := make([]model.Organization, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*organizationEntity
+	if db := repo.db.Where("id IN ?", ids).Find(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	if err := repo.populateModelFields(entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *OrganizationRepo) FindIDs() ([]string, error) {
 	type Value struct {
 		Result string
@@ -217,6 +237,26 @@ func (repo *OrganizationRepo) FindIDs() ([]string, error) {
 	return res, nil
 }
 
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the organizations can be walked through in batches.
+func (repo *OrganizationRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM organization WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *OrganizationRepo) FindIDsByOwner(userID string) ([]string, error) {
 	type IDResult struct {
 		Result string
@@ -349,29 +389,17 @@ func (repo *OrganizationRepo) find(id string) (*organizationEntity, error) {
 }
 
 func (repo *OrganizationRepo) populateModelFields(organizations []*organizationEntity) error {
+	ids := make([]string, 0, len(organizations))
 	for _, o := range organizations {
-		o.UserPermissions = make([]*UserPermissionValue, 0)
-		userPermissions, err := repo.permissionRepo.FindUserPermissions(o.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range userPermissions {
-			o.UserPermissions = append(o.UserPermissions, &UserPermissionValue{
-				UserID: p.GetUserID(),
-				Value:  p.GetPermission(),
-			})
-		}
-		o.GroupPermissions = make([]*GroupPermissionValue, 0)
-		groupPermissions, err := repo.permissionRepo.FindGroupPermissions(o.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range groupPermissions {
-			o.GroupPermissions = append(o.GroupPermissions, &GroupPermissionValue{
-				GroupID: p.GetGroupID(),
-				Value:   p.GetPermission(),
-			})
-		}
+		ids = append(ids, o.ID)
+	}
+	userPermissions, groupPermissions, err := repo.permissionRepo.findPermissionValues(ids)
+	if err != nil {
+		return err
+	}
+	for _, o := range organizations {
+		o.UserPermissions = userPermissions[o.ID]
+		o.GroupPermissions = groupPermissions[o.ID]
 		members, err := repo.FindMembers(o.ID)
 		if err != nil {
 			return nil
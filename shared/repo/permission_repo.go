@@ -250,6 +250,77 @@ func (repo *PermissionRepo) FindGroupPermissions(id string) ([]model.GroupPermis
 	}
 }
 
+// findPermissionsChunkSize is the number of resource IDs per bulk query.
+const findPermissionsChunkSize = 1000
+
+// FindUserPermissionsForResources returns the user permissions of the
+// resources with a single query per chunk, grouped by resource ID.
+func (repo *PermissionRepo) FindUserPermissionsForResources(ids []string) (map[string][]model.UserPermission, error) {
+	res := make(map[string][]model.UserPermission)
+	for start := 0; start < len(ids); start += findPermissionsChunkSize {
+		var entities []*userPermissionEntity
+		if db := repo.db.
+			Raw("SELECT * FROM userpermission WHERE resource_id IN ?", ids[start:min(start+findPermissionsChunkSize, len(ids))]).
+			Scan(&entities); db.Error != nil {
+			return nil, db.Error
+		}
+		for _, entity := range entities {
+			res[entity.ResourceID] = append(res[entity.ResourceID], entity)
+		}
+	}
+	return res, nil
+}
+
+// FindGroupPermissionsForResources returns the group permissions of the
+// resources with a single query per chunk, grouped by resource ID.
+func (repo *PermissionRepo) FindGroupPermissionsForResources(ids []string) (map[string][]model.GroupPermission, error) {
+	res := make(map[string][]model.GroupPermission)
+	for start := 0; start < len(ids); start += findPermissionsChunkSize {
+		var entities []*groupPermissionEntity
+		if db := repo.db.
+			Raw("SELECT * FROM grouppermission WHERE resource_id IN ?", ids[start:min(start+findPermissionsChunkSize, len(ids))]).
+			Scan(&entities); db.Error != nil {
+			return nil, db.Error
+		}
+		for _, entity := range entities {
+			res[entity.ResourceID] = append(res[entity.ResourceID], entity)
+		}
+	}
+	return res, nil
+}
+
+// findPermissionValues returns the permission values of the resources, every
+// resource gets an entry, empty when it has no permissions.
+func (repo *PermissionRepo) findPermissionValues(ids []string) (map[string][]*UserPermissionValue, map[string][]*GroupPermissionValue, error) {
+	userPermissions, err := repo.FindUserPermissionsForResources(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupPermissions, err := repo.FindGroupPermissionsForResources(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	userValues := make(map[string][]*UserPermissionValue, len(ids))
+	groupValues := make(map[string][]*GroupPermissionValue, len(ids))
+	for _, id := range ids {
+		userValues[id] = make([]*UserPermissionValue, 0)
+		for _, p := range userPermissions[id] {
+			userValues[id] = append(userValues[id], &UserPermissionValue{
+				UserID: p.GetUserID(),
+				Value:  p.GetPermission(),
+			})
+		}
+		groupValues[id] = make([]*GroupPermissionValue, 0)
+		for _, p := range groupPermissions[id] {
+			groupValues[id] = append(groupValues[id], &GroupPermissionValue{
+				GroupID: p.GetGroupID(),
+				Value:   p.GetPermission(),
+			})
+		}
+	}
+	return userValues, groupValues, nil
+}
+
 func (repo *PermissionRepo) FindFirstOwnerOfResource(id string) (string, error) {
 	var entities []userPermissionEntity
 	if db := repo.db.
@@ -0,0 +1,345 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type shareLinkEntity struct {
+	ID            string  `gorm:"column:id"             json:"id"`
+	FileID        string  `gorm:"column:file_id"        json:"fileId"`
+	WorkspaceID   string  `gorm:"column:workspace_id"   json:"workspaceId"`
+	UserID        string  `gorm:"column:user_id"        json:"userId"`
+	Token         string  `gorm:"column:token"          json:"token"`
+	Mode          string  `gorm:"column:mode"           json:"mode"`
+	PasswordHash  *string `gorm:"column:password_hash"  json:"passwordHash,omitempty"`
+	ExpiryTime    *string `gorm:"column:expiry_time"    json:"expiryTime,omitempty"`
+	MaxDownloads  *int    `gorm:"column:max_downloads"  json:"maxDownloads,omitempty"`
+	DownloadCount int     `gorm:"column:download_count" json:"downloadCount"`
+	RevokeTime    *string `gorm:"column:revoke_time"    json:"revokeTime,omitempty"`
+	CreateTime    string  `gorm:"column:create_time"    json:"createTime"`
+	UpdateTime    *string `gorm:"column:update_time"    json:"updateTime,omitempty"`
+}
+
+func (*shareLinkEntity) TableName() string {
+	return "share_link"
+}
+
+func (e *shareLinkEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *shareLinkEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *shareLinkEntity) GetID() string {
+	return e.ID
+}
+
+func (e *shareLinkEntity) GetFileID() string {
+	return e.FileID
+}
+
+func (e *shareLinkEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *shareLinkEntity) GetUserID() string {
+	return e.UserID
+}
+
+func (e *shareLinkEntity) GetToken() string {
+	return e.Token
+}
+
+func (e *shareLinkEntity) GetMode() string {
+	return e.Mode
+}
+
+func (e *shareLinkEntity) GetPasswordHash() *string {
+	return e.PasswordHash
+}
+
+func (e *shareLinkEntity) GetExpiryTime() *string {
+	return e.ExpiryTime
+}
+
+func (e *shareLinkEntity) GetMaxDownloads() *int {
+	return e.MaxDownloads
+}
+
+func (e *shareLinkEntity) GetDownloadCount() int {
+	return e.DownloadCount
+}
+
+func (e *shareLinkEntity) GetRevokeTime() *string {
+	return e.RevokeTime
+}
+
+func (e *shareLinkEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *shareLinkEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *shareLinkEntity) HasPassword() bool {
+	return e.PasswordHash != nil
+}
+
+func (e *shareLinkEntity) SetRevokeTime(revokeTime *string) {
+	e.RevokeTime = revokeTime
+}
+
+type shareLinkAccessEntity struct {
+	ID          string  `gorm:"column:id"            json:"id"`
+	ShareLinkID string  `gorm:"column:share_link_id" json:"shareLinkId"`
+	FileID      *string `gorm:"column:file_id"       json:"fileId,omitempty"`
+	Action      string  `gorm:"column:action"        json:"action"`
+	Granted     bool    `gorm:"column:granted"       json:"granted"`
+	ErrorCode   *string `gorm:"column:error_code"    json:"errorCode,omitempty"`
+	IP          *string `gorm:"column:ip"            json:"ip,omitempty"`
+	UserAgent   *string `gorm:"column:user_agent"    json:"userAgent,omitempty"`
+	CreateTime  string  `gorm:"column:create_time"   json:"createTime"`
+}
+
+func (*shareLinkAccessEntity) TableName() string {
+	return "share_link_access"
+}
+
+func (e *shareLinkAccessEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *shareLinkAccessEntity) GetID() string {
+	return e.ID
+}
+
+func (e *shareLinkAccessEntity) GetShareLinkID() string {
+	return e.ShareLinkID
+}
+
+func (e *shareLinkAccessEntity) GetFileID() *string {
+	return e.FileID
+}
+
+func (e *shareLinkAccessEntity) GetAction() string {
+	return e.Action
+}
+
+func (e *shareLinkAccessEntity) GetGranted() bool {
+	return e.Granted
+}
+
+func (e *shareLinkAccessEntity) GetErrorCode() *string {
+	return e.ErrorCode
+}
+
+func (e *shareLinkAccessEntity) GetIP() *string {
+	return e.IP
+}
+
+func (e *shareLinkAccessEntity) GetUserAgent() *string {
+	return e.UserAgent
+}
+
+func (e *shareLinkAccessEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+type ShareLinkRepo struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *ShareLinkRepo {
+	return &ShareLinkRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type ShareLinkInsertOptions struct {
+	ID           string
+	FileID       string
+	WorkspaceID  string
+	UserID       string
+	Token        string
+	Mode         string
+	PasswordHash *string
+	ExpiryTime   *string
+	MaxDownloads *int
+}
+
+func (repo *ShareLinkRepo) Insert(opts ShareLinkInsertOptions) (model.ShareLink, error) {
+	link := shareLinkEntity{
+		ID:           opts.ID,
+		FileID:       opts.FileID,
+		WorkspaceID:  opts.WorkspaceID,
+		UserID:       opts.UserID,
+		Token:        opts.Token,
+		Mode:         opts.Mode,
+		PasswordHash: opts.PasswordHash,
+		ExpiryTime:   opts.ExpiryTime,
+		MaxDownloads: opts.MaxDownloads,
+	}
+	if db := repo.db.Create(&link); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *ShareLinkRepo) Find(id string) (model.ShareLink, error) {
+	res := shareLinkEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewShareLinkNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *ShareLinkRepo) FindByToken(token string) (model.ShareLink, error) {
+	res := shareLinkEntity{}
+	db := repo.db.Where("token = ?", token).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewShareLinkNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *ShareLinkRepo) FindByFileID(fileID string) ([]model.ShareLink, error) {
+	var entities []*shareLinkEntity
+	db := repo.db.
+		Raw("SELECT * FROM share_link WHERE file_id = ? ORDER BY create_time DESC", fileID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.ShareLink
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *ShareLinkRepo) Save(link model.ShareLink) error {
+	db := repo.db.Save(link)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+// IncrementDownloadCount counts a download, it returns false without counting
+// if the link has reached its maximum number of downloads. The check and the
+// increment are a single statement, so concurrent downloads can't exceed it.
+func (repo *ShareLinkRepo) IncrementDownloadCount(id string) (bool, error) {
+	db := repo.db.Exec(
+		`UPDATE share_link SET download_count = download_count + 1
+         WHERE id = ? AND (max_downloads IS NULL OR download_count < max_downloads)`,
+		id,
+	)
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return db.RowsAffected == 1, nil
+}
+
+// DecrementDownloadCount gives back a download counted by IncrementDownloadCount
+// when serving it failed.
+func (repo *ShareLinkRepo) DecrementDownloadCount(id string) error {
+	db := repo.db.Exec("UPDATE share_link SET download_count = download_count - 1 WHERE id = ? AND download_count > 0", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+type ShareLinkAccessInsertOptions struct {
+	ShareLinkID string
+	FileID      *string
+	Action      string
+	Granted     bool
+	ErrorCode   *string
+	IP          *string
+	UserAgent   *string
+}
+
+func (repo *ShareLinkRepo) InsertAccess(opts ShareLinkAccessInsertOptions) error {
+	access := shareLinkAccessEntity{
+		ID:          helper.NewID(),
+		ShareLinkID: opts.ShareLinkID,
+		FileID:      opts.FileID,
+		Action:      opts.Action,
+		Granted:     opts.Granted,
+		ErrorCode:   opts.ErrorCode,
+		IP:          opts.IP,
+		UserAgent:   opts.UserAgent,
+	}
+	if db := repo.db.Create(&access); db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *ShareLinkRepo) FindAccesses(shareLinkID string, page uint64, size uint64) ([]model.ShareLinkAccess, error) {
+	var entities []*shareLinkAccessEntity
+	db := repo.db.
+		Raw(
+			"SELECT * FROM share_link_access WHERE share_link_id = ? ORDER BY create_time DESC LIMIT ? OFFSET ?",
+			shareLinkID, size, (page-1)*size,
+		).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.ShareLinkAccess
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *ShareLinkRepo) CountAccesses(shareLinkID string) (int64, error) {
+	var count int64
+	db := repo.db.
+		Model(&shareLinkAccessEntity{}).
+		Where("share_link_id = ?", shareLinkID).
+		Count(&count)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return count, nil
+}
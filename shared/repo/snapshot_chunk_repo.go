@@ -0,0 +1,308 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"encoding/binary"
+	"math"
+
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type snapshotChunkEntity struct {
+	ID          string `gorm:"column:id"           json:"id"`
+	SnapshotID  string `gorm:"column:snapshot_id"  json:"snapshotId"`
+	Ordinal     int    `gorm:"column:ordinal"      json:"ordinal"`
+	StartOffset int    `gorm:"column:start_offset" json:"startOffset"`
+	EndOffset   int    `gorm:"column:end_offset"   json:"endOffset"`
+	Text        string `gorm:"column:text"         json:"text"`
+	Embedding   []byte `gorm:"column:embedding"    json:"-"`
+	Model       string `gorm:"column:model"        json:"model"`
+	CreateTime  string `gorm:"column:create_time"  json:"createTime"`
+	// WorkspaceID is not a column, it is joined from the files of the snapshot
+	WorkspaceID string `gorm:"column:workspace_id;->" json:"workspaceId"`
+}
+
+func (*snapshotChunkEntity) TableName() string {
+	return "snapshot_chunk"
+}
+
+func (e *snapshotChunkEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *snapshotChunkEntity) GetID() string {
+	return e.ID
+}
+
+func (e *snapshotChunkEntity) GetSnapshotID() string {
+	return e.SnapshotID
+}
+
+func (e *snapshotChunkEntity) GetOrdinal() int {
+	return e.Ordinal
+}
+
+func (e *snapshotChunkEntity) GetStartOffset() int {
+	return e.StartOffset
+}
+
+func (e *snapshotChunkEntity) GetEndOffset() int {
+	return e.EndOffset
+}
+
+func (e *snapshotChunkEntity) GetText() string {
+	return e.Text
+}
+
+func (e *snapshotChunkEntity) GetEmbedding() []float32 {
+	res := make([]float32, len(e.Embedding)/4)
+	for i := range res {
+		res[i] = math.Float32frombits(binary.LittleEndian.Uint32(e.Embedding[i*4:]))
+	}
+	return res
+}
+
+func (e *snapshotChunkEntity) GetModel() string {
+	return e.Model
+}
+
+func (e *snapshotChunkEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *snapshotChunkEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *snapshotChunkEntity) SetEmbedding(embedding []float32) {
+	e.Embedding = make([]byte, len(embedding)*4)
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(e.Embedding[i*4:], math.Float32bits(v))
+	}
+}
+
+type SnapshotChunkRepo struct {
+	db *gorm.DB
+}
+
+func NewSnapshotChunkRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *SnapshotChunkRepo {
+	return &SnapshotChunkRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type SnapshotChunkInsertOptions struct {
+	Ordinal     int
+	StartOffset int
+	EndOffset   int
+	Text        string
+	Embedding   []float32
+}
+
+// ReplaceForSnapshot swaps the chunks of the snapshot with the given ones in
+// a single transaction, so that searches never see a partially chunked text.
+func (repo *SnapshotChunkRepo) ReplaceForSnapshot(snapshotID string, embeddingModel string, chunks []SnapshotChunkInsertOptions) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if db := tx.Exec("DELETE FROM snapshot_chunk WHERE snapshot_id = ?", snapshotID); db.Error != nil {
+			return db.Error
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		entities := make([]*snapshotChunkEntity, 0, len(chunks))
+		for _, c := range chunks {
+			entity := &snapshotChunkEntity{
+				ID:          helper.NewID(),
+				SnapshotID:  snapshotID,
+				Ordinal:     c.Ordinal,
+				StartOffset: c.StartOffset,
+				EndOffset:   c.EndOffset,
+				Text:        c.Text,
+				Model:       embeddingModel,
+			}
+			entity.SetEmbedding(c.Embedding)
+			entities = append(entities, entity)
+		}
+		if db := tx.CreateInBatches(entities, 100); db.Error != nil {
+			return db.Error
+		}
+		return nil
+	})
+}
+
+// snapshotChunkSelect selects the chunks along with the workspace of their
+// snapshot, the files sharing a snapshot are always in the same workspace.
+const snapshotChunkSelect = `SELECT c.*, (
+		SELECT f.workspace_id FROM snapshot_file sf
+		INNER JOIN file f ON f.id = sf.file_id
+		WHERE sf.snapshot_id = c.snapshot_id LIMIT 1
+	) workspace_id FROM snapshot_chunk c`
+
+func (repo *SnapshotChunkRepo) FindAllForSnapshot(snapshotID string) ([]model.SnapshotChunk, error) {
+	var entities []*snapshotChunkEntity
+	db := repo.db.
+		Raw(snapshotChunkSelect+" WHERE c.snapshot_id = ? ORDER BY c.ordinal", snapshotID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.SnapshotChunk
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *SnapshotChunkRepo) FindMany(ids []string) ([]model.SnapshotChunk, error) {
+	if len(ids) == 0 {
+		return []model.SnapshotChunk{}, nil
+	}
+	var entities []*snapshotChunkEntity
+	db := repo.db.
+		Raw(snapshotChunkSelect+" WHERE c.id IN (?)", ids).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]model.SnapshotChunk, 0, len(entities))
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *SnapshotChunkRepo) FindIDsForSnapshot(snapshotID string) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM snapshot_chunk WHERE snapshot_id = ?`, snapshotID).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the chunks can be walked through in batches.
+func (repo *SnapshotChunkRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM snapshot_chunk WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
+// FindSnapshotIDsToEmbedAfter returns at most limit IDs of the snapshots that
+// have a text but no chunks embedded with the given model, in ascending order
+// and greater than the given one.
+func (repo *SnapshotChunkRepo) FindSnapshotIDsToEmbedAfter(id string, embeddingModel string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT s.id result FROM snapshot s
+			WHERE s.id > ? AND s.text IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM snapshot_chunk c WHERE c.snapshot_id = s.id AND c.model = ?)
+			ORDER BY s.id LIMIT ?`,
+			id, embeddingModel, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
+func (repo *SnapshotChunkRepo) Count() (int64, error) {
+	var count int64
+	db := repo.db.Model(&snapshotChunkEntity{}).Count(&count)
+	if db.Error != nil {
+		return -1, db.Error
+	}
+	return count, nil
+}
+
+type SnapshotChunkCandidate struct {
+	FileID string
+	Chunk  model.SnapshotChunk
+}
+
+type snapshotChunkCandidateEntity struct {
+	snapshotChunkEntity
+	FileID string `gorm:"column:file_id"`
+}
+
+// FindCandidates returns, among the given chunks, the ones of the active
+// snapshots of the files the user can access, either directly or through one
+// of their groups. The files in the trash, including the descendants of the
+// trashed folders, are skipped.
+func (repo *SnapshotChunkRepo) FindCandidates(ids []string, userID string) ([]SnapshotChunkCandidate, error) {
+	if len(ids) == 0 {
+		return []SnapshotChunkCandidate{}, nil
+	}
+	var entities []*snapshotChunkCandidateEntity
+	db := repo.db.
+		Raw(`WITH RECURSIVE trashed (id) AS (
+				SELECT t.file_id FROM trash_item t
+				UNION
+				SELECT f.id FROM file f JOIN trashed ON f.parent_id = trashed.id
+			)
+			SELECT c.*, f.id file_id, f.workspace_id FROM snapshot_chunk c
+			INNER JOIN file f ON f.snapshot_id = c.snapshot_id
+			WHERE c.id IN (?)
+			AND (
+				EXISTS (SELECT 1 FROM userpermission up WHERE up.resource_id = f.id AND up.user_id = ?)
+				OR EXISTS (
+					SELECT 1 FROM grouppermission gp
+					INNER JOIN userpermission up ON up.resource_id = gp.group_id AND up.user_id = ?
+					WHERE gp.resource_id = f.id
+				)
+			)
+			AND NOT EXISTS (SELECT 1 FROM trashed WHERE trashed.id = f.id)`,
+			ids, userID, userID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]SnapshotChunkCandidate, 0, len(entities))
+	for _, e := range entities {
+		res = append(res, SnapshotChunkCandidate{FileID: e.FileID, Chunk: &e.snapshotChunkEntity})
+	}
+	return res, nil
+}
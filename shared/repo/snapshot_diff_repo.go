@@ -0,0 +1,228 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type snapshotDiffEntity struct {
+	ID               string         `gorm:"column:id"                 json:"id"`
+	BaseSnapshotID   string         `gorm:"column:base_snapshot_id"   json:"baseSnapshotId"`
+	TargetSnapshotID string         `gorm:"column:target_snapshot_id" json:"targetSnapshotId"`
+	Type             string         `gorm:"column:type"               json:"type"`
+	Artifact         datatypes.JSON `gorm:"column:artifact"           json:"artifact,omitempty"`
+	Score            *float64       `gorm:"column:score"              json:"score,omitempty"`
+	TaskID           *string        `gorm:"column:task_id"            json:"taskId,omitempty"`
+	CreateTime       string         `gorm:"column:create_time"        json:"createTime"`
+	UpdateTime       *string        `gorm:"column:update_time"        json:"updateTime,omitempty"`
+}
+
+func (*snapshotDiffEntity) TableName() string {
+	return "snapshot_diff"
+}
+
+func (e *snapshotDiffEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *snapshotDiffEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *snapshotDiffEntity) GetID() string {
+	return e.ID
+}
+
+func (e *snapshotDiffEntity) GetBaseSnapshotID() string {
+	return e.BaseSnapshotID
+}
+
+func (e *snapshotDiffEntity) GetTargetSnapshotID() string {
+	return e.TargetSnapshotID
+}
+
+func (e *snapshotDiffEntity) GetType() string {
+	return e.Type
+}
+
+func (e *snapshotDiffEntity) GetArtifact() *model.S3Object {
+	if e.Artifact.String() == "" {
+		return nil
+	}
+	res := model.S3Object{}
+	if err := json.Unmarshal([]byte(e.Artifact.String()), &res); err != nil {
+		logger.GetLogger().Fatal(err)
+		return nil
+	}
+	return &res
+}
+
+func (e *snapshotDiffEntity) GetScore() *float64 {
+	return e.Score
+}
+
+func (e *snapshotDiffEntity) GetTaskID() *string {
+	return e.TaskID
+}
+
+func (e *snapshotDiffEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *snapshotDiffEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *snapshotDiffEntity) HasArtifact() bool {
+	return e.Artifact != nil
+}
+
+func (e *snapshotDiffEntity) SetArtifact(m *model.S3Object) {
+	if m == nil {
+		e.Artifact = nil
+	} else {
+		b, err := json.Marshal(m)
+		if err != nil {
+			logger.GetLogger().Fatal(err)
+			return
+		}
+		if err := e.Artifact.UnmarshalJSON(b); err != nil {
+			logger.GetLogger().Fatal(err)
+		}
+	}
+}
+
+func (e *snapshotDiffEntity) SetScore(score *float64) {
+	e.Score = score
+}
+
+func (e *snapshotDiffEntity) SetTaskID(taskID *string) {
+	e.TaskID = taskID
+}
+
+type SnapshotDiffRepo struct {
+	db *gorm.DB
+}
+
+func NewSnapshotDiffRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *SnapshotDiffRepo {
+	return &SnapshotDiffRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type SnapshotDiffInsertOptions struct {
+	ID               string
+	BaseSnapshotID   string
+	TargetSnapshotID string
+	Type             string
+	Artifact         *model.S3Object
+	TaskID           *string
+}
+
+func (repo *SnapshotDiffRepo) Insert(opts SnapshotDiffInsertOptions) (model.SnapshotDiff, error) {
+	diff := snapshotDiffEntity{
+		ID:               opts.ID,
+		BaseSnapshotID:   opts.BaseSnapshotID,
+		TargetSnapshotID: opts.TargetSnapshotID,
+		Type:             opts.Type,
+		TaskID:           opts.TaskID,
+	}
+	diff.SetArtifact(opts.Artifact)
+	if db := repo.db.Create(&diff); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *SnapshotDiffRepo) Find(id string) (model.SnapshotDiff, error) {
+	res := snapshotDiffEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewSnapshotDiffNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *SnapshotDiffRepo) FindByPair(baseSnapshotID string, targetSnapshotID string) (model.SnapshotDiff, error) {
+	res := snapshotDiffEntity{}
+	db := repo.db.
+		Where("base_snapshot_id = ? AND target_snapshot_id = ?", baseSnapshotID, targetSnapshotID).
+		First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewSnapshotDiffNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *SnapshotDiffRepo) FindByPairOrNil(baseSnapshotID string, targetSnapshotID string) model.SnapshotDiff {
+	res, err := repo.FindByPair(baseSnapshotID, targetSnapshotID)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+func (repo *SnapshotDiffRepo) FindAllForSnapshot(snapshotID string) ([]model.SnapshotDiff, error) {
+	var entities []*snapshotDiffEntity
+	db := repo.db.
+		Raw("SELECT * FROM snapshot_diff WHERE base_snapshot_id = ? OR target_snapshot_id = ?", snapshotID, snapshotID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.SnapshotDiff
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *SnapshotDiffRepo) Save(diff model.SnapshotDiff) error {
+	db := repo.db.Save(diff)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *SnapshotDiffRepo) Delete(id string) error {
+	db := repo.db.Exec("DELETE FROM snapshot_diff WHERE id = ?", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
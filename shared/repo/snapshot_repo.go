@@ -35,11 +35,17 @@ type snapshotEntity struct {
 	OCR        datatypes.JSON `gorm:"column:ocr"         json:"ocr,omitempty"`
 	Entities   datatypes.JSON `gorm:"column:entities"    json:"entities,omitempty"`
 	Mosaic     datatypes.JSON `gorm:"column:mosaic"      json:"mosaic,omitempty"`
+	HLS        datatypes.JSON `gorm:"column:hls"         json:"hls,omitempty"`
 	Thumbnail  datatypes.JSON `gorm:"column:thumbnail"   json:"thumbnail,omitempty"`
+	Metadata   datatypes.JSON `gorm:"column:metadata"    json:"metadata,omitempty"`
 	Summary    *string        `gorm:"column:summary"     json:"summary,omitempty"`
 	Intent     *string        `gorm:"column:intent"      json:"intent,omitempty"`
 	Language   *string        `gorm:"column:language"    json:"language,omitempty"`
 	TaskID     *string        `gorm:"column:task_id"     json:"taskId,omitempty"`
+	IsPinned   bool           `gorm:"column:is_pinned"   json:"isPinned"`
+	Label      *string        `gorm:"column:label"       json:"label,omitempty"`
+	Note       *string        `gorm:"column:note"        json:"note,omitempty"`
+	UserID     *string        `gorm:"column:user_id"     json:"userId,omitempty"`
 	CreateTime string         `gorm:"column:create_time" json:"createTime"`
 	UpdateTime *string        `gorm:"column:update_time" json:"updateTime,omitempty"`
 }
@@ -138,6 +144,18 @@ func (s *snapshotEntity) GetMosaic() *model.S3Object {
 	return &res
 }
 
+func (s *snapshotEntity) GetHLS() *model.S3Object {
+	if s.HLS.String() == "" {
+		return nil
+	}
+	res := model.S3Object{}
+	if err := json.Unmarshal([]byte(s.HLS.String()), &res); err != nil {
+		logger.GetLogger().Fatal(err)
+		return nil
+	}
+	return &res
+}
+
 func (s *snapshotEntity) GetThumbnail() *model.S3Object {
 	if s.Thumbnail.String() == "" {
 		return nil
@@ -150,6 +168,18 @@ func (s *snapshotEntity) GetThumbnail() *model.S3Object {
 	return &res
 }
 
+func (s *snapshotEntity) GetMetadata() *model.SnapshotMetadata {
+	if s.Metadata.String() == "" {
+		return nil
+	}
+	res := model.SnapshotMetadata{}
+	if err := json.Unmarshal([]byte(s.Metadata.String()), &res); err != nil {
+		logger.GetLogger().Fatal(err)
+		return nil
+	}
+	return &res
+}
+
 func (s *snapshotEntity) GetSummary() *string {
 	return s.Summary
 }
@@ -166,6 +196,22 @@ func (s *snapshotEntity) GetTaskID() *string {
 	return s.TaskID
 }
 
+func (s *snapshotEntity) GetIsPinned() bool {
+	return s.IsPinned
+}
+
+func (s *snapshotEntity) GetLabel() *string {
+	return s.Label
+}
+
+func (s *snapshotEntity) GetNote() *string {
+	return s.Note
+}
+
+func (s *snapshotEntity) GetUserID() *string {
+	return s.UserID
+}
+
 func (s *snapshotEntity) HasOriginal() bool {
 	return s.Original != nil
 }
@@ -190,6 +236,10 @@ func (s *snapshotEntity) HasMosaic() bool {
 	return s.Mosaic != nil
 }
 
+func (s *snapshotEntity) HasHLS() bool {
+	return s.HLS != nil
+}
+
 func (s *snapshotEntity) HasThumbnail() bool {
 	return s.Thumbnail != nil
 }
@@ -300,6 +350,21 @@ func (s *snapshotEntity) SetMosaic(m *model.S3Object) {
 	}
 }
 
+func (s *snapshotEntity) SetHLS(m *model.S3Object) {
+	if m == nil {
+		s.HLS = nil
+	} else {
+		b, err := json.Marshal(m)
+		if err != nil {
+			logger.GetLogger().Fatal(err)
+			return
+		}
+		if err := s.HLS.UnmarshalJSON(b); err != nil {
+			logger.GetLogger().Fatal(err)
+		}
+	}
+}
+
 func (s *snapshotEntity) SetThumbnail(m *model.S3Object) {
 	if m == nil {
 		s.Thumbnail = nil
@@ -315,6 +380,21 @@ func (s *snapshotEntity) SetThumbnail(m *model.S3Object) {
 	}
 }
 
+func (s *snapshotEntity) SetMetadata(m *model.SnapshotMetadata) {
+	if m == nil {
+		s.Metadata = nil
+	} else {
+		b, err := json.Marshal(m)
+		if err != nil {
+			logger.GetLogger().Fatal(err)
+			return
+		}
+		if err := s.Metadata.UnmarshalJSON(b); err != nil {
+			logger.GetLogger().Fatal(err)
+		}
+	}
+}
+
 func (s *snapshotEntity) SetSummary(summary *string) {
 	s.Summary = summary
 }
@@ -331,6 +411,22 @@ func (s *snapshotEntity) SetTaskID(taskID *string) {
 	s.TaskID = taskID
 }
 
+func (s *snapshotEntity) SetIsPinned(isPinned bool) {
+	s.IsPinned = isPinned
+}
+
+func (s *snapshotEntity) SetLabel(label *string) {
+	s.Label = label
+}
+
+func (s *snapshotEntity) SetNote(note *string) {
+	s.Note = note
+}
+
+func (s *snapshotEntity) SetUserID(userID *string) {
+	s.UserID = userID
+}
+
 func (s *snapshotEntity) SetCreateTime(createTime string) {
 	s.CreateTime = createTime
 }
@@ -352,12 +448,18 @@ type SnapshotNewModelOptions struct {
 	OCR        *model.S3Object
 	Entities   *model.S3Object
 	Mosaic     *model.S3Object
+	HLS        *model.S3Object
 	Thumbnail  *model.S3Object
+	Metadata   *model.SnapshotMetadata
 	Summary    *string
 	Intent     *string
 	Status     string
 	Language   *string
 	TaskID     *string
+	IsPinned   bool
+	Label      *string
+	Note       *string
+	UserID     *string
 	CreateTime string
 	UpdateTime *string
 }
@@ -370,6 +472,10 @@ func NewSnapshotModelWithOptions(opts SnapshotNewModelOptions) model.Snapshot {
 		Language:   opts.Language,
 		Summary:    opts.Summary,
 		Intent:     opts.Intent,
+		IsPinned:   opts.IsPinned,
+		Label:      opts.Label,
+		Note:       opts.Note,
+		UserID:     opts.UserID,
 		CreateTime: opts.CreateTime,
 		UpdateTime: opts.UpdateTime,
 	}
@@ -377,7 +483,9 @@ func NewSnapshotModelWithOptions(opts SnapshotNewModelOptions) model.Snapshot {
 	res.SetPreview(opts.Preview)
 	res.SetText(opts.Text)
 	res.SetOCR(opts.OCR)
+	res.SetHLS(opts.HLS)
 	res.SetThumbnail(opts.Thumbnail)
+	res.SetMetadata(opts.Metadata)
 	return res
 }
 
@@ -422,6 +530,23 @@ func (repo *SnapshotRepo) FindOrNil(id string) model.Snapshot {
 	return res
 }
 
+// FindMany returns the snapshots of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *SnapshotRepo) FindMany(ids []string) ([]model.Snapshot, error) {
+	res := make([]model.Snapshot, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*snapshotEntity
+	if db := repo.db.Where("id IN ?", ids).Find(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *SnapshotRepo) FindByVersion(version int64) (model.Snapshot, error) {
 	res := snapshotEntity{}
 	db := repo.db.Where("version = ?", version).First(&res)
@@ -604,7 +729,9 @@ type SnapshotUpdateOptions struct {
 	OCR       *model.S3Object
 	Entities  *model.S3Object
 	Mosaic    *model.S3Object
+	HLS       *model.S3Object
 	Thumbnail *model.S3Object
+	Metadata  *model.SnapshotMetadata
 	Language  *string
 	Summary   *string
 	Intent    *string
@@ -634,9 +761,15 @@ func (repo *SnapshotRepo) Update(id string, opts SnapshotUpdateOptions) error {
 	if slices.Contains(opts.Fields, model.SnapshotFieldMosaic) {
 		snapshot.SetMosaic(opts.Mosaic)
 	}
+	if slices.Contains(opts.Fields, model.SnapshotFieldHLS) {
+		snapshot.SetHLS(opts.HLS)
+	}
 	if slices.Contains(opts.Fields, model.SnapshotFieldThumbnail) {
 		snapshot.SetThumbnail(opts.Thumbnail)
 	}
+	if slices.Contains(opts.Fields, model.SnapshotFieldMetadata) {
+		snapshot.SetMetadata(opts.Metadata)
+	}
 	if slices.Contains(opts.Fields, model.SnapshotFieldLanguage) {
 		snapshot.SetLanguage(opts.Language)
 	}
@@ -0,0 +1,226 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type snapshotRetentionPolicyEntity struct {
+	ID               string  `gorm:"column:id"                 json:"id"`
+	WorkspaceID      string  `gorm:"column:workspace_id"       json:"workspaceId"`
+	KeepLast         *int    `gorm:"column:keep_last"          json:"keepLast,omitempty"`
+	KeepAllDays      *int    `gorm:"column:keep_all_days"      json:"keepAllDays,omitempty"`
+	KeepDailyDays    *int    `gorm:"column:keep_daily_days"    json:"keepDailyDays,omitempty"`
+	KeepWeeklyWeeks  *int    `gorm:"column:keep_weekly_weeks"  json:"keepWeeklyWeeks,omitempty"`
+	LastRunTime      *string `gorm:"column:last_run_time"      json:"lastRunTime,omitempty"`
+	LastDeletedCount *int    `gorm:"column:last_deleted_count" json:"lastDeletedCount,omitempty"`
+	LastFreedBytes   *int64  `gorm:"column:last_freed_bytes"   json:"lastFreedBytes,omitempty"`
+	CreateTime       string  `gorm:"column:create_time"        json:"createTime"`
+	UpdateTime       *string `gorm:"column:update_time"        json:"updateTime,omitempty"`
+}
+
+func (*snapshotRetentionPolicyEntity) TableName() string {
+	return "snapshot_retention_policy"
+}
+
+func (e *snapshotRetentionPolicyEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *snapshotRetentionPolicyEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *snapshotRetentionPolicyEntity) GetID() string {
+	return e.ID
+}
+
+func (e *snapshotRetentionPolicyEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *snapshotRetentionPolicyEntity) GetKeepLast() *int {
+	return e.KeepLast
+}
+
+func (e *snapshotRetentionPolicyEntity) GetKeepAllDays() *int {
+	return e.KeepAllDays
+}
+
+func (e *snapshotRetentionPolicyEntity) GetKeepDailyDays() *int {
+	return e.KeepDailyDays
+}
+
+func (e *snapshotRetentionPolicyEntity) GetKeepWeeklyWeeks() *int {
+	return e.KeepWeeklyWeeks
+}
+
+func (e *snapshotRetentionPolicyEntity) GetLastRunTime() *string {
+	return e.LastRunTime
+}
+
+func (e *snapshotRetentionPolicyEntity) GetLastDeletedCount() *int {
+	return e.LastDeletedCount
+}
+
+func (e *snapshotRetentionPolicyEntity) GetLastFreedBytes() *int64 {
+	return e.LastFreedBytes
+}
+
+func (e *snapshotRetentionPolicyEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *snapshotRetentionPolicyEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *snapshotRetentionPolicyEntity) SetKeepLast(keepLast *int) {
+	e.KeepLast = keepLast
+}
+
+func (e *snapshotRetentionPolicyEntity) SetKeepAllDays(keepAllDays *int) {
+	e.KeepAllDays = keepAllDays
+}
+
+func (e *snapshotRetentionPolicyEntity) SetKeepDailyDays(keepDailyDays *int) {
+	e.KeepDailyDays = keepDailyDays
+}
+
+func (e *snapshotRetentionPolicyEntity) SetKeepWeeklyWeeks(keepWeeklyWeeks *int) {
+	e.KeepWeeklyWeeks = keepWeeklyWeeks
+}
+
+func (e *snapshotRetentionPolicyEntity) SetLastRunTime(lastRunTime *string) {
+	e.LastRunTime = lastRunTime
+}
+
+func (e *snapshotRetentionPolicyEntity) SetLastDeletedCount(lastDeletedCount *int) {
+	e.LastDeletedCount = lastDeletedCount
+}
+
+func (e *snapshotRetentionPolicyEntity) SetLastFreedBytes(lastFreedBytes *int64) {
+	e.LastFreedBytes = lastFreedBytes
+}
+
+type SnapshotRetentionPolicyRepo struct {
+	db *gorm.DB
+}
+
+func NewSnapshotRetentionPolicyRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *SnapshotRetentionPolicyRepo {
+	return &SnapshotRetentionPolicyRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type SnapshotRetentionPolicyInsertOptions struct {
+	ID              string
+	WorkspaceID     string
+	KeepLast        *int
+	KeepAllDays     *int
+	KeepDailyDays   *int
+	KeepWeeklyWeeks *int
+}
+
+func (repo *SnapshotRetentionPolicyRepo) Insert(opts SnapshotRetentionPolicyInsertOptions) (model.SnapshotRetentionPolicy, error) {
+	policy := snapshotRetentionPolicyEntity{
+		ID:              opts.ID,
+		WorkspaceID:     opts.WorkspaceID,
+		KeepLast:        opts.KeepLast,
+		KeepAllDays:     opts.KeepAllDays,
+		KeepDailyDays:   opts.KeepDailyDays,
+		KeepWeeklyWeeks: opts.KeepWeeklyWeeks,
+	}
+	if db := repo.db.Create(&policy); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *SnapshotRetentionPolicyRepo) Find(id string) (model.SnapshotRetentionPolicy, error) {
+	res := snapshotRetentionPolicyEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewSnapshotRetentionPolicyNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *SnapshotRetentionPolicyRepo) FindByWorkspace(workspaceID string) (model.SnapshotRetentionPolicy, error) {
+	res := snapshotRetentionPolicyEntity{}
+	db := repo.db.Where("workspace_id = ?", workspaceID).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewSnapshotRetentionPolicyNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *SnapshotRetentionPolicyRepo) FindByWorkspaceOrNil(workspaceID string) model.SnapshotRetentionPolicy {
+	res, err := repo.FindByWorkspace(workspaceID)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+func (repo *SnapshotRetentionPolicyRepo) FindAll() ([]model.SnapshotRetentionPolicy, error) {
+	var entities []*snapshotRetentionPolicyEntity
+	db := repo.db.
+		Raw("SELECT * FROM snapshot_retention_policy ORDER BY create_time").
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.SnapshotRetentionPolicy
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *SnapshotRetentionPolicyRepo) Save(policy model.SnapshotRetentionPolicy) error {
+	db := repo.db.Save(policy)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *SnapshotRetentionPolicyRepo) Delete(id string) error {
+	db := repo.db.Exec("DELETE FROM snapshot_retention_policy WHERE id = ?", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
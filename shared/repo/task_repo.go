@@ -250,6 +250,23 @@ func (repo *TaskRepo) FindOrNil(id string) model.Task {
 	return res
 }
 
+// FindMany returns the tasks of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *TaskRepo) FindMany(ids []string) ([]model.Task, error) {
+	res := make([]model.Task, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*taskEntity
+	if db := repo.db.Where("id IN ?", ids).Find(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *TaskRepo) FindIDs(userID string) ([]string, error) {
 	type Value struct {
 		Result string
@@ -268,6 +285,26 @@ func (repo *TaskRepo) FindIDs(userID string) ([]string, error) {
 	return res, nil
 }
 
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the tasks can be walked through in batches.
+func (repo *TaskRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM task WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *TaskRepo) FindIDsByOwner(userID string) ([]string, error) {
 	type IDResult struct {
 		Result string
@@ -0,0 +1,207 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type trashItemEntity struct {
+	ID               string  `gorm:"column:id"                 json:"id"`
+	FileID           string  `gorm:"column:file_id"            json:"fileId"`
+	WorkspaceID      string  `gorm:"column:workspace_id"       json:"workspaceId"`
+	UserID           string  `gorm:"column:user_id"            json:"userId"`
+	OriginalParentID *string `gorm:"column:original_parent_id" json:"originalParentId,omitempty"`
+	OriginalPath     string  `gorm:"column:original_path"      json:"originalPath"`
+	CreateTime       string  `gorm:"column:create_time"        json:"createTime"`
+}
+
+func (*trashItemEntity) TableName() string {
+	return "trash_item"
+}
+
+func (e *trashItemEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *trashItemEntity) GetID() string {
+	return e.ID
+}
+
+func (e *trashItemEntity) GetFileID() string {
+	return e.FileID
+}
+
+func (e *trashItemEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *trashItemEntity) GetUserID() string {
+	return e.UserID
+}
+
+func (e *trashItemEntity) GetOriginalParentID() *string {
+	return e.OriginalParentID
+}
+
+func (e *trashItemEntity) GetOriginalPath() string {
+	return e.OriginalPath
+}
+
+func (e *trashItemEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+type TrashItemRepo struct {
+	db *gorm.DB
+}
+
+func NewTrashItemRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *TrashItemRepo {
+	return &TrashItemRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type TrashItemInsertOptions struct {
+	ID               string
+	FileID           string
+	WorkspaceID      string
+	UserID           string
+	OriginalParentID *string
+	OriginalPath     string
+}
+
+func (repo *TrashItemRepo) Insert(opts TrashItemInsertOptions) (model.TrashItem, error) {
+	item := trashItemEntity{
+		ID:               opts.ID,
+		FileID:           opts.FileID,
+		WorkspaceID:      opts.WorkspaceID,
+		UserID:           opts.UserID,
+		OriginalParentID: opts.OriginalParentID,
+		OriginalPath:     opts.OriginalPath,
+	}
+	if db := repo.db.Create(&item); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *TrashItemRepo) Find(id string) (model.TrashItem, error) {
+	res := trashItemEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewTrashItemNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *TrashItemRepo) FindByWorkspace(workspaceID string) ([]model.TrashItem, error) {
+	var entities []*trashItemEntity
+	db := repo.db.
+		Raw("SELECT * FROM trash_item WHERE workspace_id = ? ORDER BY create_time DESC", workspaceID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.TrashItem
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// FindCreatedBefore returns the oldest items that were trashed before the given
+// time, across all workspaces.
+func (repo *TrashItemRepo) FindCreatedBefore(createTime string, limit int) ([]model.TrashItem, error) {
+	var entities []*trashItemEntity
+	db := repo.db.
+		Raw("SELECT * FROM trash_item WHERE create_time < ? ORDER BY create_time LIMIT ?", createTime, limit).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.TrashItem
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// IsInTrash tells whether the file, or any of its ancestors, is in the trash.
+func (repo *TrashItemRepo) IsInTrash(fileID string) (bool, error) {
+	type Result struct {
+		Result bool
+	}
+	var res Result
+	db := repo.db.
+		Raw(`WITH RECURSIVE rec (id, parent_id) AS (
+				SELECT f.id, f.parent_id FROM file f WHERE f.id = ?
+				UNION
+				SELECT f.id, f.parent_id FROM rec JOIN file f ON f.id = rec.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM trash_item t JOIN rec ON rec.id = t.file_id) AS result`,
+			fileID).
+		Scan(&res)
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return res.Result, nil
+}
+
+// ComputeSize returns the size of the snapshots of the items in the trash of
+// the workspace, including their descendants.
+func (repo *TrashItemRepo) ComputeSize(workspaceID string) (int64, error) {
+	type Result struct {
+		Result int64
+	}
+	var res Result
+	db := repo.db.
+		Raw(`WITH RECURSIVE rec (id, parent_id) AS (
+				SELECT f.id, f.parent_id FROM trash_item t JOIN file f ON f.id = t.file_id WHERE t.workspace_id = ?
+				UNION
+				SELECT f.id, f.parent_id FROM rec JOIN file f ON f.parent_id = rec.id
+			)
+			SELECT COALESCE(SUM((s.original->>'size')::bigint), 0) AS result
+			FROM snapshot_file map
+			JOIN snapshot s ON map.snapshot_id = s.id
+			JOIN rec ON rec.id = map.file_id`,
+			workspaceID).
+		Scan(&res)
+	if db.Error != nil {
+		return res.Result, db.Error
+	}
+	return res.Result, nil
+}
+
+func (repo *TrashItemRepo) Delete(id string) error {
+	db := repo.db.Exec("DELETE FROM trash_item WHERE id = ?", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type uploadSessionEntity struct {
+	ID          string  `gorm:"column:id"           json:"id"`
+	UserID      string  `gorm:"column:user_id"      json:"userId"`
+	WorkspaceID string  `gorm:"column:workspace_id" json:"workspaceId"`
+	ParentID    *string `gorm:"column:parent_id"    json:"parentId,omitempty"`
+	FileID      *string `gorm:"column:file_id"      json:"fileId,omitempty"`
+	Name        string  `gorm:"column:name"         json:"name"`
+	Size        int64   `gorm:"column:size"         json:"size"`
+	ContentType string  `gorm:"column:content_type" json:"contentType"`
+	Bucket      string  `gorm:"column:bucket"       json:"bucket"`
+	Key         string  `gorm:"column:key"          json:"key"`
+	SnapshotID  string  `gorm:"column:snapshot_id"  json:"snapshotId"`
+	S3UploadID  string  `gorm:"column:s3_upload_id" json:"s3UploadId"`
+	Status      string  `gorm:"column:status"       json:"status"`
+	ExpiryTime  string  `gorm:"column:expiry_time"  json:"expiryTime"`
+	CreateTime  string  `gorm:"column:create_time"  json:"createTime"`
+	UpdateTime  *string `gorm:"column:update_time"  json:"updateTime,omitempty"`
+}
+
+func (*uploadSessionEntity) TableName() string {
+	return "upload_session"
+}
+
+func (e *uploadSessionEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *uploadSessionEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *uploadSessionEntity) GetID() string {
+	return e.ID
+}
+
+func (e *uploadSessionEntity) GetUserID() string {
+	return e.UserID
+}
+
+func (e *uploadSessionEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *uploadSessionEntity) GetParentID() *string {
+	return e.ParentID
+}
+
+func (e *uploadSessionEntity) GetFileID() *string {
+	return e.FileID
+}
+
+func (e *uploadSessionEntity) GetName() string {
+	return e.Name
+}
+
+func (e *uploadSessionEntity) GetSize() int64 {
+	return e.Size
+}
+
+func (e *uploadSessionEntity) GetContentType() string {
+	return e.ContentType
+}
+
+func (e *uploadSessionEntity) GetBucket() string {
+	return e.Bucket
+}
+
+func (e *uploadSessionEntity) GetKey() string {
+	return e.Key
+}
+
+func (e *uploadSessionEntity) GetSnapshotID() string {
+	return e.SnapshotID
+}
+
+func (e *uploadSessionEntity) GetS3UploadID() string {
+	return e.S3UploadID
+}
+
+func (e *uploadSessionEntity) GetStatus() string {
+	return e.Status
+}
+
+func (e *uploadSessionEntity) GetExpiryTime() string {
+	return e.ExpiryTime
+}
+
+func (e *uploadSessionEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *uploadSessionEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *uploadSessionEntity) SetID(id string) {
+	e.ID = id
+}
+
+func (e *uploadSessionEntity) SetUserID(userID string) {
+	e.UserID = userID
+}
+
+func (e *uploadSessionEntity) SetWorkspaceID(workspaceID string) {
+	e.WorkspaceID = workspaceID
+}
+
+func (e *uploadSessionEntity) SetParentID(parentID *string) {
+	e.ParentID = parentID
+}
+
+func (e *uploadSessionEntity) SetFileID(fileID *string) {
+	e.FileID = fileID
+}
+
+func (e *uploadSessionEntity) SetName(name string) {
+	e.Name = name
+}
+
+func (e *uploadSessionEntity) SetSize(size int64) {
+	e.Size = size
+}
+
+func (e *uploadSessionEntity) SetContentType(contentType string) {
+	e.ContentType = contentType
+}
+
+func (e *uploadSessionEntity) SetBucket(bucket string) {
+	e.Bucket = bucket
+}
+
+func (e *uploadSessionEntity) SetKey(key string) {
+	e.Key = key
+}
+
+func (e *uploadSessionEntity) SetSnapshotID(snapshotID string) {
+	e.SnapshotID = snapshotID
+}
+
+func (e *uploadSessionEntity) SetS3UploadID(s3UploadID string) {
+	e.S3UploadID = s3UploadID
+}
+
+func (e *uploadSessionEntity) SetStatus(status string) {
+	e.Status = status
+}
+
+func (e *uploadSessionEntity) SetExpiryTime(expiryTime string) {
+	e.ExpiryTime = expiryTime
+}
+
+func (e *uploadSessionEntity) SetCreateTime(createTime string) {
+	e.CreateTime = createTime
+}
+
+func (e *uploadSessionEntity) SetUpdateTime(updateTime *string) {
+	e.UpdateTime = updateTime
+}
+
+func NewUploadSessionModel() model.UploadSession {
+	return &uploadSessionEntity{}
+}
+
+type uploadSessionPartEntity struct {
+	SessionID  string `gorm:"column:session_id"  json:"sessionId"`
+	Number     int    `gorm:"column:number"      json:"number"`
+	Size       int64  `gorm:"column:size"        json:"size"`
+	ETag       string `gorm:"column:etag"        json:"etag"`
+	CreateTime string `gorm:"column:create_time" json:"createTime"`
+}
+
+func (*uploadSessionPartEntity) TableName() string {
+	return "upload_session_part"
+}
+
+func (e *uploadSessionPartEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *uploadSessionPartEntity) GetSessionID() string {
+	return e.SessionID
+}
+
+func (e *uploadSessionPartEntity) GetNumber() int {
+	return e.Number
+}
+
+func (e *uploadSessionPartEntity) GetSize() int64 {
+	return e.Size
+}
+
+func (e *uploadSessionPartEntity) GetETag() string {
+	return e.ETag
+}
+
+func (e *uploadSessionPartEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+type UploadSessionRepo struct {
+	db *gorm.DB
+}
+
+func NewUploadSessionRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *UploadSessionRepo {
+	return &UploadSessionRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+// Transaction runs fn with a repo bound to a transaction, which is rolled back
+// when fn returns an error.
+func (repo *UploadSessionRepo) Transaction(fn func(tx *UploadSessionRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&UploadSessionRepo{db: tx})
+	})
+}
+
+type UploadSessionInsertOptions struct {
+	ID          string
+	UserID      string
+	WorkspaceID string
+	ParentID    *string
+	FileID      *string
+	Name        string
+	Size        int64
+	ContentType string
+	Bucket      string
+	Key         string
+	SnapshotID  string
+	S3UploadID  string
+	ExpiryTime  string
+}
+
+func (repo *UploadSessionRepo) Insert(opts UploadSessionInsertOptions) (model.UploadSession, error) {
+	session := uploadSessionEntity{
+		ID:          opts.ID,
+		UserID:      opts.UserID,
+		WorkspaceID: opts.WorkspaceID,
+		ParentID:    opts.ParentID,
+		FileID:      opts.FileID,
+		Name:        opts.Name,
+		Size:        opts.Size,
+		ContentType: opts.ContentType,
+		Bucket:      opts.Bucket,
+		Key:         opts.Key,
+		SnapshotID:  opts.SnapshotID,
+		S3UploadID:  opts.S3UploadID,
+		Status:      model.UploadSessionStatusUploading,
+		ExpiryTime:  opts.ExpiryTime,
+	}
+	if db := repo.db.Create(&session); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *UploadSessionRepo) Find(id string) (model.UploadSession, error) {
+	res := uploadSessionEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewUploadSessionNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *UploadSessionRepo) Save(session model.UploadSession) error {
+	db := repo.db.Save(session)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+// Touch extends the expiry time of a session that is still uploading. It
+// updates only that column, so that it never overwrites the status set by a
+// concurrent Finalize.
+func (repo *UploadSessionRepo) Touch(id string, expiryTime string) error {
+	db := repo.db.Exec(
+		"UPDATE upload_session SET expiry_time = ?, update_time = ? WHERE id = ? AND status = ?",
+		expiryTime, helper.NewTimeString(), id, model.UploadSessionStatusUploading,
+	)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+// LockUploading takes a share lock on the session until the end of the
+// transaction, and returns false if the session is no longer uploading. Any
+// number of chunks can hold the lock at once, while UpdateStatus waits for all
+// of them to be released.
+func (repo *UploadSessionRepo) LockUploading(id string) (bool, error) {
+	var ids []string
+	db := repo.db.
+		Raw("SELECT id FROM upload_session WHERE id = ? AND status = ? FOR SHARE", id, model.UploadSessionStatusUploading).
+		Scan(&ids)
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return len(ids) == 1, nil
+}
+
+// UpdateStatus moves a session from one status to another, and returns false if
+// the session was not in the expected status, e.g. because a concurrent request
+// moved it first.
+func (repo *UploadSessionRepo) UpdateStatus(id string, from string, to string) (bool, error) {
+	db := repo.db.Exec(
+		"UPDATE upload_session SET status = ?, update_time = ? WHERE id = ? AND status = ?",
+		to, helper.NewTimeString(), id, from,
+	)
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return db.RowsAffected == 1, nil
+}
+
+func (repo *UploadSessionRepo) Delete(id string) error {
+	db := repo.db.Exec("DELETE FROM upload_session WHERE id = ?", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+// DeleteExpired removes the sessions whose expiry time is before the given time
+// and returns them, so that the caller can release the underlying multipart
+// uploads. Since rows are returned by the DELETE itself, concurrent replicas
+// never get the same session twice.
+func (repo *UploadSessionRepo) DeleteExpired(before string) ([]model.UploadSession, error) {
+	var entities []*uploadSessionEntity
+	db := repo.db.
+		Raw("DELETE FROM upload_session WHERE expiry_time < ? RETURNING *", before).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.UploadSession
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+type UploadSessionPartUpsertOptions struct {
+	SessionID string
+	Number    int
+	Size      int64
+	ETag      string
+}
+
+// UpsertPart records a part, or replaces it if the client uploaded the same
+// part number again, e.g. after a timeout.
+func (repo *UploadSessionRepo) UpsertPart(opts UploadSessionPartUpsertOptions) error {
+	part := uploadSessionPartEntity{
+		SessionID: opts.SessionID,
+		Number:    opts.Number,
+		Size:      opts.Size,
+		ETag:      opts.ETag,
+	}
+	db := repo.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "session_id"}, {Name: "number"}},
+			DoUpdates: clause.AssignmentColumns([]string{"size", "etag", "create_time"}),
+		}).
+		Create(&part)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *UploadSessionRepo) FindParts(sessionID string) ([]model.UploadSessionPart, error) {
+	var entities []*uploadSessionPartEntity
+	db := repo.db.
+		Raw("SELECT * FROM upload_session_part WHERE session_id = ? ORDER BY number", sessionID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.UploadSessionPart
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
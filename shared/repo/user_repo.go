@@ -134,6 +134,26 @@ func (repo *UserRepo) FindAll() ([]model.User, error) {
 	return res, nil
 }
 
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the users can be walked through in batches.
+func (repo *UserRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM "user" WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *UserRepo) Count() (int64, error) {
 	var count int64
 	db := repo.db.Model(&userEntity{}).Count(&count)
@@ -0,0 +1,439 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/logger"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type webhookSubscriptionEntity struct {
+	ID          string         `gorm:"column:id"           json:"id"`
+	WorkspaceID string         `gorm:"column:workspace_id" json:"workspaceId"`
+	UserID      string         `gorm:"column:user_id"      json:"userId"`
+	URL         string         `gorm:"column:url"          json:"url"`
+	Secret      string         `gorm:"column:secret"       json:"secret"`
+	Events      datatypes.JSON `gorm:"column:events"       json:"events"`
+	IsActive    bool           `gorm:"column:is_active"    json:"isActive"`
+	CreateTime  string         `gorm:"column:create_time"  json:"createTime"`
+	UpdateTime  *string        `gorm:"column:update_time"  json:"updateTime,omitempty"`
+}
+
+func (*webhookSubscriptionEntity) TableName() string {
+	return "webhook_subscription"
+}
+
+func (e *webhookSubscriptionEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *webhookSubscriptionEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *webhookSubscriptionEntity) GetID() string {
+	return e.ID
+}
+
+func (e *webhookSubscriptionEntity) GetWorkspaceID() string {
+	return e.WorkspaceID
+}
+
+func (e *webhookSubscriptionEntity) GetUserID() string {
+	return e.UserID
+}
+
+func (e *webhookSubscriptionEntity) GetURL() string {
+	return e.URL
+}
+
+func (e *webhookSubscriptionEntity) GetSecret() string {
+	return e.Secret
+}
+
+func (e *webhookSubscriptionEntity) GetEvents() []string {
+	res := make([]string, 0)
+	if e.Events.String() == "" {
+		return res
+	}
+	if err := json.Unmarshal([]byte(e.Events.String()), &res); err != nil {
+		logger.GetLogger().Fatal(err)
+		return nil
+	}
+	return res
+}
+
+func (e *webhookSubscriptionEntity) GetIsActive() bool {
+	return e.IsActive
+}
+
+func (e *webhookSubscriptionEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *webhookSubscriptionEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *webhookSubscriptionEntity) SetURL(url string) {
+	e.URL = url
+}
+
+func (e *webhookSubscriptionEntity) SetEvents(events []string) {
+	b, err := json.Marshal(events)
+	if err != nil {
+		logger.GetLogger().Fatal(err)
+		return
+	}
+	if err := e.Events.UnmarshalJSON(b); err != nil {
+		logger.GetLogger().Fatal(err)
+	}
+}
+
+func (e *webhookSubscriptionEntity) SetIsActive(isActive bool) {
+	e.IsActive = isActive
+}
+
+type webhookDeliveryEntity struct {
+	ID              string  `gorm:"column:id"                json:"id"`
+	SubscriptionID  string  `gorm:"column:subscription_id"   json:"subscriptionId"`
+	EventType       string  `gorm:"column:event_type"        json:"eventType"`
+	Payload         string  `gorm:"column:payload"           json:"payload"`
+	Status          string  `gorm:"column:status"            json:"status"`
+	Attempts        int     `gorm:"column:attempts"          json:"attempts"`
+	NextAttemptTime string  `gorm:"column:next_attempt_time" json:"nextAttemptTime"`
+	LastAttemptTime *string `gorm:"column:last_attempt_time" json:"lastAttemptTime,omitempty"`
+	ResponseStatus  *int    `gorm:"column:response_status"   json:"responseStatus,omitempty"`
+	ResponseBody    *string `gorm:"column:response_body"     json:"responseBody,omitempty"`
+	Error           *string `gorm:"column:error"             json:"error,omitempty"`
+	CreateTime      string  `gorm:"column:create_time"       json:"createTime"`
+	UpdateTime      *string `gorm:"column:update_time"       json:"updateTime,omitempty"`
+}
+
+func (*webhookDeliveryEntity) TableName() string {
+	return "webhook_delivery"
+}
+
+func (e *webhookDeliveryEntity) BeforeCreate(*gorm.DB) (err error) {
+	e.CreateTime = helper.NewTimeString()
+	return nil
+}
+
+func (e *webhookDeliveryEntity) BeforeSave(*gorm.DB) (err error) {
+	e.UpdateTime = helper.ToPtr(helper.NewTimeString())
+	return nil
+}
+
+func (e *webhookDeliveryEntity) GetID() string {
+	return e.ID
+}
+
+func (e *webhookDeliveryEntity) GetSubscriptionID() string {
+	return e.SubscriptionID
+}
+
+func (e *webhookDeliveryEntity) GetEventType() string {
+	return e.EventType
+}
+
+func (e *webhookDeliveryEntity) GetPayload() string {
+	return e.Payload
+}
+
+func (e *webhookDeliveryEntity) GetStatus() string {
+	return e.Status
+}
+
+func (e *webhookDeliveryEntity) GetAttempts() int {
+	return e.Attempts
+}
+
+func (e *webhookDeliveryEntity) GetNextAttemptTime() string {
+	return e.NextAttemptTime
+}
+
+func (e *webhookDeliveryEntity) GetLastAttemptTime() *string {
+	return e.LastAttemptTime
+}
+
+func (e *webhookDeliveryEntity) GetResponseStatus() *int {
+	return e.ResponseStatus
+}
+
+func (e *webhookDeliveryEntity) GetResponseBody() *string {
+	return e.ResponseBody
+}
+
+func (e *webhookDeliveryEntity) GetError() *string {
+	return e.Error
+}
+
+func (e *webhookDeliveryEntity) GetCreateTime() string {
+	return e.CreateTime
+}
+
+func (e *webhookDeliveryEntity) GetUpdateTime() *string {
+	return e.UpdateTime
+}
+
+func (e *webhookDeliveryEntity) SetStatus(status string) {
+	e.Status = status
+}
+
+func (e *webhookDeliveryEntity) SetAttempts(attempts int) {
+	e.Attempts = attempts
+}
+
+func (e *webhookDeliveryEntity) SetNextAttemptTime(nextAttemptTime string) {
+	e.NextAttemptTime = nextAttemptTime
+}
+
+func (e *webhookDeliveryEntity) SetLastAttemptTime(lastAttemptTime *string) {
+	e.LastAttemptTime = lastAttemptTime
+}
+
+func (e *webhookDeliveryEntity) SetResponseStatus(responseStatus *int) {
+	e.ResponseStatus = responseStatus
+}
+
+func (e *webhookDeliveryEntity) SetResponseBody(responseBody *string) {
+	e.ResponseBody = responseBody
+}
+
+func (e *webhookDeliveryEntity) SetError(err *string) {
+	e.Error = err
+}
+
+type WebhookSubscriptionRepo struct {
+	db *gorm.DB
+}
+
+func NewWebhookSubscriptionRepo(postgres config.PostgresConfig, environment config.EnvironmentConfig) *WebhookSubscriptionRepo {
+	return &WebhookSubscriptionRepo{
+		db: infra.NewPostgresManager(postgres, environment).GetDBOrPanic(),
+	}
+}
+
+type WebhookSubscriptionInsertOptions struct {
+	ID          string
+	WorkspaceID string
+	UserID      string
+	URL         string
+	Secret      string
+	Events      []string
+}
+
+func (repo *WebhookSubscriptionRepo) Insert(opts WebhookSubscriptionInsertOptions) (model.WebhookSubscription, error) {
+	subscription := webhookSubscriptionEntity{
+		ID:          opts.ID,
+		WorkspaceID: opts.WorkspaceID,
+		UserID:      opts.UserID,
+		URL:         opts.URL,
+		Secret:      opts.Secret,
+		IsActive:    true,
+	}
+	subscription.SetEvents(opts.Events)
+	if db := repo.db.Create(&subscription); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.Find(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) Find(id string) (model.WebhookSubscription, error) {
+	res := webhookSubscriptionEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewWebhookSubscriptionNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) FindByWorkspace(workspaceID string) ([]model.WebhookSubscription, error) {
+	var entities []*webhookSubscriptionEntity
+	db := repo.db.
+		Raw("SELECT * FROM webhook_subscription WHERE workspace_id = ? ORDER BY create_time", workspaceID).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.WebhookSubscription
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// FindActiveForEvent returns the active subscriptions of the workspace that
+// subscribe to the given event type.
+func (repo *WebhookSubscriptionRepo) FindActiveForEvent(workspaceID string, eventType string) ([]model.WebhookSubscription, error) {
+	events, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+	var entities []*webhookSubscriptionEntity
+	db := repo.db.
+		Raw(
+			"SELECT * FROM webhook_subscription WHERE workspace_id = ? AND is_active = true AND events @> ?::jsonb",
+			workspaceID, string(events),
+		).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.WebhookSubscription
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) Save(subscription model.WebhookSubscription) error {
+	db := repo.db.Save(subscription)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+func (repo *WebhookSubscriptionRepo) Delete(id string) error {
+	db := repo.db.Exec("DELETE FROM webhook_subscription WHERE id = ?", id)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
+
+type WebhookDeliveryInsertOptions struct {
+	ID             string
+	SubscriptionID string
+	EventType      string
+	Payload        string
+}
+
+func (repo *WebhookSubscriptionRepo) InsertDelivery(opts WebhookDeliveryInsertOptions) (model.WebhookDelivery, error) {
+	delivery := webhookDeliveryEntity{
+		ID:              opts.ID,
+		SubscriptionID:  opts.SubscriptionID,
+		EventType:       opts.EventType,
+		Payload:         opts.Payload,
+		Status:          model.WebhookDeliveryStatusPending,
+		NextAttemptTime: helper.NewTimeString(),
+	}
+	if db := repo.db.Create(&delivery); db.Error != nil {
+		return nil, db.Error
+	}
+	res, err := repo.FindDelivery(opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) FindDelivery(id string) (model.WebhookDelivery, error) {
+	res := webhookDeliveryEntity{}
+	db := repo.db.Where("id = ?", id).First(&res)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, errorpkg.NewWebhookDeliveryNotFoundError(db.Error)
+		} else {
+			return nil, errorpkg.NewInternalServerError(db.Error)
+		}
+	}
+	return &res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) FindDeliveries(subscriptionID string, page uint64, size uint64) ([]model.WebhookDelivery, error) {
+	var entities []*webhookDeliveryEntity
+	db := repo.db.
+		Raw(
+			"SELECT * FROM webhook_delivery WHERE subscription_id = ? ORDER BY create_time DESC LIMIT ? OFFSET ?",
+			subscriptionID, size, (page-1)*size,
+		).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.WebhookDelivery
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) CountDeliveries(subscriptionID string) (int64, error) {
+	var count int64
+	db := repo.db.
+		Model(&webhookDeliveryEntity{}).
+		Where("subscription_id = ?", subscriptionID).
+		Count(&count)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return count, nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries whose next attempt
+// is due, and postpones their next attempt to leaseUntil. This way concurrent
+// replicas never claim the same delivery, and a delivery claimed by a replica
+// that crashed is attempted again once the lease is over.
+func (repo *WebhookSubscriptionRepo) ClaimDueDeliveries(now string, leaseUntil string, limit int) ([]model.WebhookDelivery, error) {
+	var entities []*webhookDeliveryEntity
+	db := repo.db.
+		Raw(
+			`UPDATE webhook_delivery SET next_attempt_time = ?
+             WHERE id IN (
+                 SELECT id FROM webhook_delivery
+                 WHERE status = ? AND next_attempt_time <= ?
+                 ORDER BY next_attempt_time
+                 LIMIT ?
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING *`,
+			leaseUntil, model.WebhookDeliveryStatusPending, now, limit,
+		).
+		Scan(&entities)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var res []model.WebhookDelivery
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *WebhookSubscriptionRepo) SaveDelivery(delivery model.WebhookDelivery) error {
+	db := repo.db.Save(delivery)
+	if db.Error != nil {
+		return db.Error
+	}
+	return nil
+}
@@ -259,6 +259,26 @@ func (repo *WorkspaceRepo) FindOrNil(id string) model.Workspace {
 	return res
 }
 
+// FindMany returns the workspaces of the IDs with a single query, IDs which don't
+// exist are skipped.
+func (repo *WorkspaceRepo) FindMany(ids []string) ([]model.Workspace, error) {
+	res := make([]model.Workspace, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	var entities []*workspaceEntity
+	if db := repo.db.Where("id IN ?", ids).Find(&entities); db.Error != nil {
+		return nil, db.Error
+	}
+	if err := repo.populateModelFields(entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
 func (repo *WorkspaceRepo) FindIDs() ([]string, error) {
 	type IDResult struct {
 		Result string
@@ -275,6 +295,26 @@ func (repo *WorkspaceRepo) FindIDs() ([]string, error) {
 	return res, nil
 }
 
+// FindIDsAfter returns at most limit IDs greater than the given one, in
+// ascending order, so that all the workspaces can be walked through in batches.
+func (repo *WorkspaceRepo) FindIDsAfter(id string, limit int) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT id result FROM workspace WHERE id > ? ORDER BY id LIMIT ?`, id, limit).
+		Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *WorkspaceRepo) FindIDsByOrganization(orgID string) ([]string, error) {
 	type IDResult struct {
 		Result string
@@ -312,6 +352,32 @@ func (repo *WorkspaceRepo) FindIDsByOwner(userID string) ([]string, error) {
 	return res, nil
 }
 
+// FindIDsForUser returns the IDs of the workspaces the user can access, either
+// directly or through one of their groups.
+func (repo *WorkspaceRepo) FindIDsForUser(userID string) ([]string, error) {
+	type IDResult struct {
+		Result string
+	}
+	var ids []IDResult
+	db := repo.db.
+		Raw(`SELECT w.id result FROM workspace w
+			 WHERE EXISTS (SELECT 1 FROM userpermission up WHERE up.resource_id = w.id AND up.user_id = ?)
+			 OR EXISTS (
+				 SELECT 1 FROM grouppermission gp
+				 INNER JOIN userpermission up ON up.resource_id = gp.group_id AND up.user_id = ?
+				 WHERE gp.resource_id = w.id
+			 )`,
+			userID, userID).Scan(&ids)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	res := make([]string, 0)
+	for _, id := range ids {
+		res = append(res, id.Result)
+	}
+	return res, nil
+}
+
 func (repo *WorkspaceRepo) Count() (int64, error) {
 	var count int64
 	db := repo.db.Model(&workspaceEntity{}).Count(&count)
@@ -395,29 +461,17 @@ func (repo *WorkspaceRepo) find(id string) (*workspaceEntity, error) {
 }
 
 func (repo *WorkspaceRepo) populateModelFields(workspaces []*workspaceEntity) error {
+	ids := make([]string, 0, len(workspaces))
 	for _, w := range workspaces {
-		w.UserPermissions = make([]*UserPermissionValue, 0)
-		userPermissions, err := repo.permissionRepo.FindUserPermissions(w.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range userPermissions {
-			w.UserPermissions = append(w.UserPermissions, &UserPermissionValue{
-				UserID: p.GetUserID(),
-				Value:  p.GetPermission(),
-			})
-		}
-		w.GroupPermissions = make([]*GroupPermissionValue, 0)
-		groupPermissions, err := repo.permissionRepo.FindGroupPermissions(w.ID)
-		if err != nil {
-			return err
-		}
-		for _, p := range groupPermissions {
-			w.GroupPermissions = append(w.GroupPermissions, &GroupPermissionValue{
-				GroupID: p.GetGroupID(),
-				Value:   p.GetPermission(),
-			})
-		}
+		ids = append(ids, w.ID)
+	}
+	userPermissions, groupPermissions, err := repo.permissionRepo.findPermissionValues(ids)
+	if err != nil {
+		return err
+	}
+	for _, w := range workspaces {
+		w.UserPermissions = userPermissions[w.ID]
+		w.GroupPermissions = groupPermissions[w.ID]
 	}
 	return nil
 }
@@ -12,10 +12,14 @@ package search
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 
 	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
 	"github.com/kouprlabs/voltaserve/shared/infra"
 	"github.com/kouprlabs/voltaserve/shared/model"
 	"github.com/kouprlabs/voltaserve/shared/repo"
@@ -28,6 +32,39 @@ type FileSearch struct {
 	snapshotRepo *repo.SnapshotRepo
 }
 
+// Facets of the file index, the date facet counts files per month of creation,
+// formatted as YYYY-MM.
+const (
+	FileFacetType      = "type"
+	FileFacetIntent    = "intent"
+	FileFacetLanguage  = "language"
+	FileFacetWorkspace = "workspaceId"
+	FileFacetDate      = "createMonth"
+)
+
+// FileFieldText is the field highlighted in the search results.
+const FileFieldText = "text"
+
+const (
+	FileFieldUsers  = "users"
+	FileFieldGroups = "groups"
+)
+
+// FilePrincipalFilter matches the files that the user can view, either directly
+// or through one of the groups the user is a member of.
+func FilePrincipalFilter(userID string, groupIDs []string) string {
+	if len(groupIDs) == 0 {
+		return fmt.Sprintf("%s IN [%s]", FileFieldUsers, strconv.Quote(userID))
+	}
+	quoted := make([]string, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	return fmt.Sprintf("(%s IN [%s] OR %s IN [%s])",
+		FileFieldUsers, strconv.Quote(userID),
+		FileFieldGroups, strings.Join(quoted, ","))
+}
+
 type fileEntity struct {
 	ID          string  `json:"id"`
 	WorkspaceID string  `json:"workspaceId"`
@@ -37,8 +74,25 @@ type fileEntity struct {
 	Text        *string `json:"text,omitempty"`
 	Summary     *string `json:"summary,omitempty"`
 	SnapshotID  *string `json:"snapshotId,omitempty"`
+	Intent      *string `json:"intent,omitempty"`
+	Language    *string `json:"language,omitempty"`
+	CreateMonth string  `json:"createMonth"`
 	CreateTime  string  `json:"createTime"`
 	UpdateTime  *string `json:"updateTime,omitempty"`
+	// The principals with at least viewer access, so that the hits can be
+	// filtered by the search engine
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+	// Flattened from the snapshot metadata, so that the fields can be filtered
+	Duration       *float64 `json:"duration,omitempty"`
+	VideoCodec     *string  `json:"videoCodec,omitempty"`
+	AudioCodec     *string  `json:"audioCodec,omitempty"`
+	CameraMake     *string  `json:"cameraMake,omitempty"`
+	CameraModel    *string  `json:"cameraModel,omitempty"`
+	CaptureTime    *int64   `json:"captureTime,omitempty"`
+	HasLocation    *bool    `json:"hasLocation,omitempty"`
+	DocumentTitle  *string  `json:"documentTitle,omitempty"`
+	DocumentAuthor *string  `json:"documentAuthor,omitempty"`
 }
 
 func (f fileEntity) GetID() string {
@@ -58,7 +112,7 @@ func (s *FileSearch) Index(files []model.File) (err error) {
 	if len(files) == 0 {
 		return nil
 	}
-	if err = s.populateTextAndSummaryFields(files); err != nil {
+	if err = s.populateSnapshotFields(files); err != nil {
 		return err
 	}
 	var models []infra.SearchModel
@@ -75,7 +129,7 @@ func (s *FileSearch) Update(files []model.File) (err error) {
 	if len(files) == 0 {
 		return nil
 	}
-	if err = s.populateTextAndSummaryFields(files); err != nil {
+	if err = s.populateSnapshotFields(files); err != nil {
 		return err
 	}
 	var models []infra.SearchModel
@@ -98,6 +152,10 @@ func (s *FileSearch) Delete(ids []string) error {
 	return nil
 }
 
+func (s *FileSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *FileSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.File, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -119,7 +177,46 @@ func (s *FileSearch) Query(query string, opts infra.SearchQueryOptions) ([]model
 	return res, nil
 }
 
-func (s *FileSearch) populateTextAndSummaryFields(files []model.File) error {
+type FileSearchResult struct {
+	Hits      []*FileSearchHit
+	TotalHits int64
+	Facets    map[string]map[string]int64
+}
+
+type FileSearchHit struct {
+	ID         string
+	Highlights []string
+}
+
+// Search returns a page of hits sorted by the search engine, along with the
+// facets of all the hits and the highlighted snippets of their text.
+func (s *FileSearch) Search(query string, opts infra.SearchOptions) (*FileSearchResult, error) {
+	opts.Facets = []string{FileFacetType, FileFacetIntent, FileFacetLanguage, FileFacetWorkspace, FileFacetDate}
+	opts.Highlight = []string{FileFieldText}
+	opts.Retrieve = []string{"id"}
+	res, err := s.search.Search(s.index, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*FileSearchHit, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		id, ok := hit.Document["id"].(string)
+		if !ok {
+			continue
+		}
+		hits = append(hits, &FileSearchHit{
+			ID:         id,
+			Highlights: hit.Highlights[FileFieldText],
+		})
+	}
+	return &FileSearchResult{
+		Hits:      hits,
+		TotalHits: res.TotalHits,
+		Facets:    res.Facets,
+	}, nil
+}
+
+func (s *FileSearch) populateSnapshotFields(files []model.File) error {
 	for _, f := range files {
 		if f.GetType() == model.FileTypeFile && f.GetSnapshotID() != nil {
 			snapshot, err := s.snapshotRepo.Find(*f.GetSnapshotID())
@@ -134,13 +231,16 @@ func (s *FileSearch) populateTextAndSummaryFields(files []model.File) error {
 				f.SetText(&text)
 			}
 			f.SetSummary(snapshot.GetSummary())
+			f.SetMetadata(snapshot.GetMetadata())
+			f.SetIntent(snapshot.GetIntent())
+			f.SetLanguage(snapshot.GetLanguage())
 		}
 	}
 	return nil
 }
 
 func (s *FileSearch) mapEntity(file model.File) *fileEntity {
-	return &fileEntity{
+	res := &fileEntity{
 		ID:          file.GetID(),
 		WorkspaceID: file.GetWorkspaceID(),
 		Name:        file.GetName(),
@@ -149,7 +249,44 @@ func (s *FileSearch) mapEntity(file model.File) *fileEntity {
 		Text:        file.GetText(),
 		Summary:     file.GetSummary(),
 		SnapshotID:  file.GetSnapshotID(),
+		Intent:      file.GetIntent(),
+		Language:    file.GetLanguage(),
 		CreateTime:  file.GetCreateTime(),
 		UpdateTime:  file.GetUpdateTime(),
 	}
+	res.Users = make([]string, 0)
+	for _, p := range file.GetUserPermissions() {
+		if model.IsEquivalentPermission(p.GetValue(), model.PermissionViewer) {
+			res.Users = append(res.Users, p.GetUserID())
+		}
+	}
+	res.Groups = make([]string, 0)
+	for _, p := range file.GetGroupPermissions() {
+		if model.IsEquivalentPermission(p.GetValue(), model.PermissionViewer) {
+			res.Groups = append(res.Groups, p.GetGroupID())
+		}
+	}
+	if file.GetCreateTime() != "" {
+		res.CreateMonth = helper.StringToTime(file.GetCreateTime()).UTC().Format("2006-01")
+	}
+	if metadata := file.GetMetadata(); metadata != nil {
+		if metadata.Media != nil {
+			res.Duration = &metadata.Media.Duration
+			res.VideoCodec = metadata.Media.VideoCodec
+			res.AudioCodec = metadata.Media.AudioCodec
+		}
+		if metadata.Image != nil {
+			res.CameraMake = metadata.Image.CameraMake
+			res.CameraModel = metadata.Image.CameraModel
+			if metadata.Image.CaptureTime != nil {
+				res.CaptureTime = helper.ToPtr(helper.StringToTimestamp(*metadata.Image.CaptureTime))
+			}
+			res.HasLocation = helper.ToPtr(metadata.Image.Latitude != nil && metadata.Image.Longitude != nil)
+		}
+		if metadata.Document != nil {
+			res.DocumentTitle = metadata.Document.Title
+			res.DocumentAuthor = metadata.Document.Author
+		}
+	}
+	return res
 }
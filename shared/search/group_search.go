@@ -82,6 +82,10 @@ func (s *GroupSearch) Delete(ids []string) error {
 	return nil
 }
 
+func (s *GroupSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *GroupSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.Group, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -81,6 +81,10 @@ func (s *OrganizationSearch) Delete(ids []string) error {
 	return nil
 }
 
+func (s *OrganizationSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *OrganizationSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.Organization, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -0,0 +1,139 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+	"github.com/kouprlabs/voltaserve/shared/model"
+)
+
+type SnapshotChunkSearch struct {
+	index  string
+	search infra.SearchManager
+}
+
+type snapshotChunkEntity struct {
+	ID          string               `json:"id"`
+	SnapshotID  string               `json:"snapshotId"`
+	WorkspaceID string               `json:"workspaceId"`
+	Model       string               `json:"model"`
+	CreateTime  string               `json:"createTime"`
+	Vectors     map[string][]float32 `json:"_vectors"`
+}
+
+func (c snapshotChunkEntity) GetID() string {
+	return c.ID
+}
+
+func (c snapshotChunkEntity) GetVector() []float32 {
+	return c.Vectors[infra.SearchVectorEmbedder]
+}
+
+func NewSnapshotChunkSearch(
+	search config.SearchConfig,
+	embedding config.EmbeddingConfig,
+	environment config.EnvironmentConfig,
+) *SnapshotChunkSearch {
+	res := &SnapshotChunkSearch{
+		index:  infra.SnapshotChunkSearchIndex,
+		search: infra.NewSearchManager(search, environment),
+	}
+	if embedding.Enabled {
+		if err := res.search.EnableVectors(res.index, embedding.Dimensions); err != nil {
+			panic(err)
+		}
+	}
+	return res
+}
+
+func (s *SnapshotChunkSearch) Index(chunks []model.SnapshotChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	var models []infra.SearchModel
+	for _, c := range chunks {
+		models = append(models, s.mapEntity(c))
+	}
+	if err := s.search.Index(s.index, models); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SnapshotChunkSearch) Delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := s.search.Delete(s.index, ids); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SnapshotChunkSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
+type SnapshotChunkQueryOptions struct {
+	Model        string
+	WorkspaceIDs []string
+	Limit        int64
+}
+
+type SnapshotChunkHit struct {
+	ID    string
+	Score float64
+}
+
+// Query returns the chunks closest to the vector, among the chunks of the given
+// workspaces that were embedded with the given model.
+func (s *SnapshotChunkSearch) Query(vector []float32, opts SnapshotChunkQueryOptions) ([]*SnapshotChunkHit, error) {
+	if len(opts.WorkspaceIDs) == 0 {
+		return []*SnapshotChunkHit{}, nil
+	}
+	quoted := make([]string, 0, len(opts.WorkspaceIDs))
+	for _, id := range opts.WorkspaceIDs {
+		quoted = append(quoted, strconv.Quote(id))
+	}
+	hits, err := s.search.SearchVector(s.index, vector, infra.SearchVectorOptions{
+		Filter:   fmt.Sprintf("model = %s AND workspaceId IN [%s]", strconv.Quote(opts.Model), strings.Join(quoted, ",")),
+		Limit:    opts.Limit,
+		Retrieve: []string{"id"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*SnapshotChunkHit, 0, len(hits))
+	for _, hit := range hits {
+		id, ok := hit.Document["id"].(string)
+		if !ok {
+			continue
+		}
+		res = append(res, &SnapshotChunkHit{ID: id, Score: hit.Score})
+	}
+	return res, nil
+}
+
+func (s *SnapshotChunkSearch) mapEntity(chunk model.SnapshotChunk) *snapshotChunkEntity {
+	return &snapshotChunkEntity{
+		ID:          chunk.GetID(),
+		SnapshotID:  chunk.GetSnapshotID(),
+		WorkspaceID: chunk.GetWorkspaceID(),
+		Model:       chunk.GetModel(),
+		CreateTime:  chunk.GetCreateTime(),
+		Vectors:     map[string][]float32{infra.SearchVectorEmbedder: chunk.GetEmbedding()},
+	}
+}
@@ -85,6 +85,10 @@ func (s *TaskSearch) Delete(ids []string) error {
 	return nil
 }
 
+func (s *TaskSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *TaskSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.Task, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -24,6 +24,22 @@ type UserSearch struct {
 	search infra.SearchManager
 }
 
+// userEntity mirrors the documents written by the identity provider, which owns
+// the user index.
+type userEntity struct {
+	ID               string  `json:"id"`
+	Username         string  `json:"username"`
+	Email            string  `json:"email"`
+	FullName         string  `json:"fullName"`
+	IsEmailConfirmed bool    `json:"isEmailConfirmed"`
+	CreateTime       string  `json:"createTime"`
+	UpdateTime       *string `json:"updateTime,omitempty"`
+}
+
+func (u userEntity) GetID() string {
+	return u.ID
+}
+
 func NewUserSearch(search config.SearchConfig, environment config.EnvironmentConfig) *UserSearch {
 	return &UserSearch{
 		index:  infra.UserSearchIndex,
@@ -31,6 +47,34 @@ func NewUserSearch(search config.SearchConfig, environment config.EnvironmentCon
 	}
 }
 
+func (s *UserSearch) Index(users []model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	var models []infra.SearchModel
+	for _, u := range users {
+		models = append(models, s.mapEntity(u))
+	}
+	if err := s.search.Index(s.index, models); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserSearch) Delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := s.search.Delete(s.index, ids); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *UserSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.User, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -50,3 +94,15 @@ func (s *UserSearch) Query(query string, opts infra.SearchQueryOptions) ([]model
 	}
 	return res, nil
 }
+
+func (s *UserSearch) mapEntity(user model.User) *userEntity {
+	return &userEntity{
+		ID:               user.GetID(),
+		Username:         user.GetUsername(),
+		Email:            user.GetEmail(),
+		FullName:         user.GetFullName(),
+		IsEmailConfirmed: user.GetIsEmailConfirmed(),
+		CreateTime:       user.GetCreateTime(),
+		UpdateTime:       user.GetUpdateTime(),
+	}
+}
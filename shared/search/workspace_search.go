@@ -84,6 +84,10 @@ func (s *WorkspaceSearch) Delete(ids []string) error {
 	return nil
 }
 
+func (s *WorkspaceSearch) FindIDs() ([]string, error) {
+	return s.search.FindIDs(s.index)
+}
+
 func (s *WorkspaceSearch) Query(query string, opts infra.SearchQueryOptions) ([]model.Workspace, error) {
 	hits, err := s.search.Query(s.index, query, opts)
 	if err != nil {
@@ -56,6 +56,8 @@ export type FileList = {
   page: number
   size: number
   query?: FileQuery
+  nextCursor?: string
+  previousCursor?: string
 }
 
 export type FileUserPermission = {
@@ -77,6 +79,16 @@ export type FileQuery = {
   createTimeBefore?: number
   updateTimeAfter?: number
   updateTimeBefore?: number
+  durationMin?: number
+  durationMax?: number
+  videoCodec?: string
+  audioCodec?: string
+  cameraMake?: string
+  cameraModel?: string
+  captureTimeAfter?: number
+  captureTimeBefore?: number
+  hasLocation?: boolean
+  author?: string
 }
 
 export type FileListOptions = {
@@ -85,6 +97,7 @@ export type FileListOptions = {
   sortBy?: FileSortBy
   sortOrder?: FileSortOrder
   query?: FileQuery
+  cursor?: string
 }
 
 export type FileMoveManyOptions = {
@@ -167,6 +180,7 @@ type FileListQueryParams = {
   sort_order?: string
   type?: string
   query?: string
+  cursor?: string
 }
 
 export class FileAPI {
@@ -292,6 +306,9 @@ export class FileAPI {
     if (options?.query) {
       params.query = encodeQuery(JSON.stringify(options.query))
     }
+    if (options?.cursor) {
+      params.cursor = options.cursor
+    }
     return new URLSearchParams(params)
   }
 
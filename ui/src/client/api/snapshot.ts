@@ -20,6 +20,8 @@ export type Snapshot = {
   ocr?: SnapshotDownloadable
   text?: SnapshotDownloadable
   thumbnail?: SnapshotDownloadable
+  hls?: SnapshotDownloadable
+  metadata?: SnapshotMetadata
   summary?: string
   intent?: SnapshotIntent
   language?: string
@@ -75,14 +77,46 @@ export type SnapshotCapabilities = {
   summary: boolean
   entities: boolean
   mosaic: boolean
+  hls: boolean
   thumbnail: boolean
 }
 
+export type SnapshotMetadata = {
+  media?: MediaMetadata
+  image?: ImageMetadata
+  document?: DocumentMetadata
+}
+
+export type MediaMetadata = {
+  duration: number
+  bitrate?: number
+  videoCodec?: string
+  audioCodec?: string
+  frameRate?: number
+  audioChannels?: number
+}
+
+export type ImageMetadata = {
+  cameraMake?: string
+  cameraModel?: string
+  latitude?: number
+  longitude?: number
+  captureTime?: string
+  orientation?: number
+}
+
+export type DocumentMetadata = {
+  title?: string
+  author?: string
+  creationDate?: string
+}
+
 export type SnapshotDownloadable = {
   extension?: string
   size?: number
   image?: ImageProps
   document?: DocumentProps
+  media?: MediaProps
 }
 
 export type ImageProps = {
@@ -95,6 +129,20 @@ export type DocumentProps = {
   thumbnail?: ThumbnailProps
 }
 
+export type MediaProps = {
+  duration: number
+  width?: number
+  height?: number
+  renditions?: RenditionProps[]
+}
+
+export type RenditionProps = {
+  name: string
+  width?: number
+  height?: number
+  bandwidth: number
+}
+
 export type PageProps = {
   count: number
   extension: string
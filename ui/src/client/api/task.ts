@@ -41,6 +41,7 @@ export enum TaskStatus {
   Running = 'running',
   Success = 'success',
   Error = 'error',
+  Cancelled = 'cancelled',
 }
 
 export type TaskPayload = {
@@ -140,6 +141,13 @@ export class TaskAPI {
     })
   }
 
+  static async cancel(id: string) {
+    return apiFetcher({
+      url: `/tasks/${id}/cancel`,
+      method: 'POST',
+    }) as Promise<Task>
+  }
+
   static async dismissAll() {
     return apiFetcher({
       url: `/tasks/dismiss`,
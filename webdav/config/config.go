@@ -23,6 +23,7 @@ type Config struct {
 	APIURL      string
 	IdPURL      string
 	S3          config.S3Config
+	Redis       config.RedisConfig
 	Security    config.SecurityConfig
 	Environment config.EnvironmentConfig
 }
@@ -32,6 +33,7 @@ func GetConfig() *Config {
 	readPort(cfg)
 	readURLs(cfg)
 	config.ReadS3(&cfg.S3)
+	config.ReadRedis(&cfg.Redis)
 	config.ReadSecurity(&cfg.Security)
 	config.ReadEnvironment(&cfg.Environment)
 	return cfg
@@ -22,6 +22,18 @@ import (
 func handleError(err error, w http.ResponseWriter) {
 	var errorResponse *errorpkg.ErrorResponse
 	switch {
+	case errors.Is(err, errLockConflict):
+		w.WriteHeader(http.StatusLocked)
+		if _, err := w.Write([]byte("Resource is locked.")); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		return
+	case errors.Is(err, errLockNotFound):
+		w.WriteHeader(http.StatusConflict)
+		if _, err := w.Write([]byte("Lock not found.")); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		return
 	case errors.As(err, &errorResponse):
 		w.WriteHeader(errorResponse.Status)
 		if _, err := w.Write([]byte(errorResponse.UserMessage)); err != nil {
@@ -21,12 +21,14 @@ import (
 )
 
 type Handler struct {
-	s3 infra.S3Manager
+	s3    infra.S3Manager
+	locks *lockManager
 }
 
 func NewHandler() *Handler {
 	return &Handler{
-		s3: infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		s3:    infra.NewS3Manager(config.GetConfig().S3, config.GetConfig().Environment),
+		locks: newLockManager(config.GetConfig().Redis),
 	}
 }
 
@@ -52,6 +54,10 @@ func (h *Handler) Dispatch(w http.ResponseWriter, r *http.Request) {
 		h.methodPropfind(w, r)
 	case "PROPPATCH":
 		h.methodProppatch(w, r)
+	case "LOCK":
+		h.methodLock(w, r)
+	case "UNLOCK":
+		h.methodUnlock(w, r)
 	default:
 		http.Error(w, "Method not implemented", http.StatusNotImplemented)
 	}
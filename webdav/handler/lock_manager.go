@@ -0,0 +1,367 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/infra"
+
+	"github.com/kouprlabs/voltaserve/webdav/logger"
+)
+
+const (
+	LockScopeExclusive = "exclusive"
+	LockScopeShared    = "shared"
+)
+
+const (
+	LockDepthZero     = "0"
+	LockDepthInfinity = "infinity"
+)
+
+const (
+	lockTokenPrefix      = "opaquelocktoken:"
+	lockDefaultTimeout   = 1 * time.Hour
+	lockMaxTimeout       = 24 * time.Hour
+	lockMutexTimeout     = 10 * time.Second
+	lockMutexRetryPeriod = 50 * time.Millisecond
+)
+
+var (
+	errLockConflict = errors.New("resource is locked")
+	errLockNotFound = errors.New("lock not found")
+	errLockMutex    = errors.New("cannot acquire lock mutex")
+)
+
+type Lock struct {
+	Token      string `json:"token"`
+	Path       string `json:"path"`
+	Username   string `json:"username"`
+	Scope      string `json:"scope"`
+	Depth      string `json:"depth"`
+	Owner      string `json:"owner,omitempty"`
+	Timeout    int64  `json:"timeout"`
+	ExpiryTime string `json:"expiryTime"`
+}
+
+func (l *Lock) IsExpired() bool {
+	return time.Now().After(helper.StringToTime(l.ExpiryTime))
+}
+
+// Covers tells whether the lock protects the resource at path, either because
+// the lock is rooted there or because it is a depth-infinity lock on an ancestor.
+func (l *Lock) Covers(path string) bool {
+	if l.Path == path {
+		return true
+	}
+	return l.Depth == LockDepthInfinity && isDescendantPath(path, l.Path)
+}
+
+type lockManager struct {
+	redis     *infra.RedisManager
+	keyPrefix string
+}
+
+func newLockManager(redis config.RedisConfig) *lockManager {
+	return &lockManager{
+		redis:     infra.NewRedisManager(redis),
+		keyPrefix: "webdav:locks:",
+	}
+}
+
+type LockCreateOptions struct {
+	Path     string
+	Username string
+	Scope    string
+	Depth    string
+	Owner    string
+	Timeout  time.Duration
+}
+
+func (mgr *lockManager) Create(opts LockCreateOptions) (*Lock, error) {
+	path := normalizeLockPath(opts.Path)
+	unlock, err := mgr.acquireMutex(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	locks, err := mgr.list(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locks {
+		if mgr.overlaps(l, path, opts.Depth) && (l.Scope == LockScopeExclusive || opts.Scope == LockScopeExclusive) {
+			return nil, errLockConflict
+		}
+	}
+	timeout := normalizeLockTimeout(opts.Timeout)
+	res := &Lock{
+		Token:      lockTokenPrefix + uuid.New().String(),
+		Path:       path,
+		Username:   opts.Username,
+		Scope:      opts.Scope,
+		Depth:      opts.Depth,
+		Owner:      opts.Owner,
+		Timeout:    int64(timeout.Seconds()),
+		ExpiryTime: helper.TimeToString(time.Now().Add(timeout)),
+	}
+	if err := mgr.save(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (mgr *lockManager) Refresh(path string, token string, username string, timeout time.Duration) (*Lock, error) {
+	path = normalizeLockPath(path)
+	unlock, err := mgr.acquireMutex(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	locks, err := mgr.list(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locks {
+		if l.Token == token && l.Covers(path) {
+			if l.Username != username {
+				return nil, errLockConflict
+			}
+			timeout = normalizeLockTimeout(timeout)
+			l.Timeout = int64(timeout.Seconds())
+			l.ExpiryTime = helper.TimeToString(time.Now().Add(timeout))
+			if err := mgr.save(l); err != nil {
+				return nil, err
+			}
+			return l, nil
+		}
+	}
+	return nil, errLockNotFound
+}
+
+func (mgr *lockManager) Delete(path string, token string, username string) error {
+	path = normalizeLockPath(path)
+	unlock, err := mgr.acquireMutex(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	locks, err := mgr.list(path)
+	if err != nil {
+		return err
+	}
+	for _, l := range locks {
+		if l.Token == token && l.Covers(path) {
+			if l.Username != username {
+				return errLockConflict
+			}
+			return mgr.redis.HDel(mgr.key(path), l.Token)
+		}
+	}
+	return errLockNotFound
+}
+
+// DeleteTree removes every lock rooted at path or below it, this is used once
+// the resource has been deleted or moved away.
+func (mgr *lockManager) DeleteTree(path string) error {
+	path = normalizeLockPath(path)
+	unlock, err := mgr.acquireMutex(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	locks, err := mgr.list(path)
+	if err != nil {
+		return err
+	}
+	var tokens []string
+	for _, l := range locks {
+		if l.Path == path || isDescendantPath(l.Path, path) {
+			tokens = append(tokens, l.Token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return mgr.redis.HDel(mgr.key(path), tokens...)
+}
+
+// Discover returns the active locks that apply to the resource at path and to
+// its direct members, keyed by path, so that PROPFIND can render them all with
+// a single round trip to Redis.
+func (mgr *lockManager) Discover(path string, members []string) (map[string][]*Lock, error) {
+	path = normalizeLockPath(path)
+	locks, err := mgr.list(path)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]*Lock)
+	for _, p := range append([]string{path}, members...) {
+		p = normalizeLockPath(p)
+		for _, l := range locks {
+			if l.Covers(p) {
+				res[p] = append(res[p], l)
+			}
+		}
+	}
+	return res, nil
+}
+
+type LockCheckOptions struct {
+	// Recursive also checks the locks held on descendants, e.g. for DELETE or MOVE of a collection.
+	Recursive bool
+	// ChangesMembership also checks the locks held on the parent collection, e.g. when adding or removing a member.
+	ChangesMembership bool
+}
+
+// Check makes sure that the caller may modify the resource at path, otherwise
+// the request must fail with 423 Locked. Every exclusive lock protecting the
+// resource must have been submitted by its owner, while shared locks only
+// require one of them to be, since other users can hold shared locks too.
+func (mgr *lockManager) Check(path string, username string, tokens []string, opts LockCheckOptions) error {
+	path = normalizeLockPath(path)
+	locks, err := mgr.list(path)
+	if err != nil {
+		return err
+	}
+	parent := normalizeLockPath(helper.Dirname(path))
+	var shared, submitted bool
+	for _, l := range locks {
+		affected := l.Covers(path) ||
+			(opts.Recursive && isDescendantPath(l.Path, path)) ||
+			(opts.ChangesMembership && l.Covers(parent))
+		if !affected {
+			continue
+		}
+		owned := l.Username == username && containsLockToken(tokens, l.Token)
+		if l.Scope == LockScopeExclusive {
+			if !owned {
+				return errLockConflict
+			}
+			continue
+		}
+		shared = true
+		submitted = submitted || owned
+	}
+	if shared && !submitted {
+		return errLockConflict
+	}
+	return nil
+}
+
+func (mgr *lockManager) overlaps(l *Lock, path string, depth string) bool {
+	return l.Covers(path) || (depth == LockDepthInfinity && isDescendantPath(l.Path, path))
+}
+
+func (mgr *lockManager) list(path string) ([]*Lock, error) {
+	values, err := mgr.redis.HGetAll(mgr.key(path))
+	if err != nil {
+		return nil, err
+	}
+	var res []*Lock
+	var expired []string
+	for token, value := range values {
+		l := &Lock{}
+		if err := json.Unmarshal([]byte(value), l); err != nil {
+			logger.GetLogger().Error(err)
+			expired = append(expired, token)
+			continue
+		}
+		if l.IsExpired() {
+			expired = append(expired, token)
+			continue
+		}
+		res = append(res, l)
+	}
+	if len(expired) > 0 {
+		if err := mgr.redis.HDel(mgr.key(path), expired...); err != nil {
+			logger.GetLogger().Error(err)
+		}
+	}
+	return res, nil
+}
+
+func (mgr *lockManager) save(l *Lock) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return mgr.redis.HSet(mgr.key(l.Path), l.Token, string(b))
+}
+
+// Locks are partitioned by workspace, so that conflict detection only has to
+// look at the locks that could possibly overlap.
+func (mgr *lockManager) key(path string) string {
+	return mgr.keyPrefix + helper.ExtractWorkspaceIDFromPath(path)
+}
+
+// acquireMutex serializes the changes to the locks of a workspace, the mutex
+// holds a random value, so that releasing it after it has expired and has been
+// acquired by someone else leaves the new holder's mutex in place.
+func (mgr *lockManager) acquireMutex(path string) (func(), error) {
+	key := mgr.key(path) + ":mutex"
+	value := uuid.New().String()
+	deadline := time.Now().Add(lockMutexTimeout)
+	for time.Now().Before(deadline) {
+		ok, err := mgr.redis.SetNX(key, value, lockMutexTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() {
+				if _, err := mgr.redis.DeleteIfEqual(key, value); err != nil {
+					logger.GetLogger().Error(err)
+				}
+			}, nil
+		}
+		time.Sleep(lockMutexRetryPeriod)
+	}
+	return nil, errLockMutex
+}
+
+func normalizeLockPath(path string) string {
+	path = "/" + strings.Trim(path, "/")
+	return path
+}
+
+func normalizeLockTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return lockDefaultTimeout
+	}
+	if timeout > lockMaxTimeout {
+		return lockMaxTimeout
+	}
+	return timeout
+}
+
+func isDescendantPath(path string, ancestor string) bool {
+	if ancestor == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, ancestor+"/")
+}
+
+func containsLockToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
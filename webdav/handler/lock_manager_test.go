@@ -0,0 +1,278 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/kouprlabs/voltaserve/shared/config"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+)
+
+type LockManagerTestSuite struct {
+	suite.Suite
+	miniredis *miniredis.Miniredis
+	mgr       *lockManager
+}
+
+func TestLockManagerSuite(t *testing.T) {
+	suite.Run(t, new(LockManagerTestSuite))
+}
+
+func (s *LockManagerTestSuite) SetupTest() {
+	var err error
+	s.miniredis, err = miniredis.Run()
+	s.Require().NoError(err)
+	s.mgr = newLockManager(config.RedisConfig{Address: s.miniredis.Addr()})
+}
+
+func (s *LockManagerTestSuite) TearDownTest() {
+	s.Require().NoError(s.mgr.redis.Close())
+	s.miniredis.Close()
+}
+
+func (s *LockManagerTestSuite) TestCreate_ExclusiveConflict() {
+	_, err := s.create("/Workspace-a/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-a/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.ErrorIs(err, errLockConflict)
+	_, err = s.create("/Workspace-a/file.txt", "bob", LockScopeShared, LockDepthZero)
+	s.ErrorIs(err, errLockConflict)
+}
+
+func (s *LockManagerTestSuite) TestCreate_SharedLocksCoexist() {
+	_, err := s.create("/Workspace-a/file.txt", "alice", LockScopeShared, LockDepthZero)
+	s.Require().NoError(err)
+	_, err = s.create("/Workspace-a/file.txt", "bob", LockScopeShared, LockDepthZero)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-a/file.txt", "carol", LockScopeExclusive, LockDepthZero)
+	s.ErrorIs(err, errLockConflict)
+}
+
+func (s *LockManagerTestSuite) TestCreate_DepthInfinityCoversDescendants() {
+	_, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthInfinity)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-a/folder/sub/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.ErrorIs(err, errLockConflict)
+	_, err = s.create("/Workspace-a/folder-other/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.NoError(err)
+}
+
+func (s *LockManagerTestSuite) TestCreate_DepthZeroDoesNotCoverDescendants() {
+	_, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-a/folder/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.NoError(err)
+}
+
+func (s *LockManagerTestSuite) TestCreate_DepthInfinityConflictsWithDescendant() {
+	_, err := s.create("/Workspace-a/folder/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-a/folder", "bob", LockScopeExclusive, LockDepthInfinity)
+	s.ErrorIs(err, errLockConflict)
+	_, err = s.create("/Workspace-a/folder", "bob", LockScopeExclusive, LockDepthZero)
+	s.NoError(err)
+}
+
+func (s *LockManagerTestSuite) TestCreate_WorkspacesAreIsolated() {
+	_, err := s.create("/Workspace-a/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	_, err = s.create("/Workspace-b/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.NoError(err)
+}
+
+func (s *LockManagerTestSuite) TestCreate_Timeout() {
+	lock, err := s.mgr.Create(LockCreateOptions{
+		Path:     "/Workspace-a/default.txt",
+		Username: "alice",
+		Scope:    LockScopeExclusive,
+		Depth:    LockDepthZero,
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(lockDefaultTimeout.Seconds()), lock.Timeout)
+
+	lock, err = s.mgr.Create(LockCreateOptions{
+		Path:     "/Workspace-a/max.txt",
+		Username: "alice",
+		Scope:    LockScopeExclusive,
+		Depth:    LockDepthZero,
+		Timeout:  48 * time.Hour,
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(lockMaxTimeout.Seconds()), lock.Timeout)
+
+	lock, err = s.mgr.Create(LockCreateOptions{
+		Path:     "/Workspace-a/custom.txt",
+		Username: "alice",
+		Scope:    LockScopeExclusive,
+		Depth:    LockDepthZero,
+		Timeout:  10 * time.Minute,
+	})
+	s.Require().NoError(err)
+	s.Equal(int64(600), lock.Timeout)
+}
+
+func (s *LockManagerTestSuite) TestExpiredLockIsIgnored() {
+	lock, err := s.create("/Workspace-a/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+	lock.ExpiryTime = helper.TimeToString(time.Now().Add(-time.Second))
+	s.Require().NoError(s.mgr.save(lock))
+
+	_, err = s.create("/Workspace-a/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+	values, err := s.mgr.redis.HGetAll(s.mgr.key("/Workspace-a"))
+	s.Require().NoError(err)
+	s.NotContains(values, lock.Token)
+}
+
+func (s *LockManagerTestSuite) TestRefresh() {
+	lock, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthInfinity)
+	s.Require().NoError(err)
+
+	_, err = s.mgr.Refresh("/Workspace-a/folder/file.txt", lock.Token, "bob", time.Minute)
+	s.ErrorIs(err, errLockConflict)
+	_, err = s.mgr.Refresh("/Workspace-a/folder", "opaquelocktoken:unknown", "alice", time.Minute)
+	s.ErrorIs(err, errLockNotFound)
+
+	lock, err = s.mgr.Refresh("/Workspace-a/folder/file.txt", lock.Token, "alice", time.Minute)
+	s.Require().NoError(err)
+	s.Equal(int64(60), lock.Timeout)
+}
+
+func (s *LockManagerTestSuite) TestDelete() {
+	lock, err := s.create("/Workspace-a/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.ErrorIs(s.mgr.Delete("/Workspace-a/file.txt", lock.Token, "bob"), errLockConflict)
+	s.ErrorIs(s.mgr.Delete("/Workspace-a/file.txt", "opaquelocktoken:unknown", "alice"), errLockNotFound)
+	s.Require().NoError(s.mgr.Delete("/Workspace-a/file.txt", lock.Token, "alice"))
+
+	_, err = s.create("/Workspace-a/file.txt", "bob", LockScopeExclusive, LockDepthZero)
+	s.NoError(err)
+}
+
+func (s *LockManagerTestSuite) TestDeleteTree() {
+	_, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+	_, err = s.create("/Workspace-a/folder/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+	other, err := s.create("/Workspace-a/folder-other", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.mgr.DeleteTree("/Workspace-a/folder"))
+
+	locks, err := s.mgr.list("/Workspace-a")
+	s.Require().NoError(err)
+	s.Require().Len(locks, 1)
+	s.Equal(other.Token, locks[0].Token)
+}
+
+func (s *LockManagerTestSuite) TestCheck() {
+	lock, err := s.create("/Workspace-a/folder/file.txt", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.ErrorIs(s.mgr.Check("/Workspace-a/folder/file.txt", "alice", nil, LockCheckOptions{}), errLockConflict)
+	s.ErrorIs(s.mgr.Check("/Workspace-a/folder/file.txt", "bob", []string{lock.Token}, LockCheckOptions{}), errLockConflict)
+	s.NoError(s.mgr.Check("/Workspace-a/folder/file.txt", "alice", []string{lock.Token}, LockCheckOptions{}))
+
+	s.NoError(s.mgr.Check("/Workspace-a/folder", "bob", nil, LockCheckOptions{}))
+	s.ErrorIs(s.mgr.Check("/Workspace-a/folder", "bob", nil, LockCheckOptions{Recursive: true}), errLockConflict)
+}
+
+func (s *LockManagerTestSuite) TestCheck_SharedLocks() {
+	alice, err := s.create("/Workspace-a/file.txt", "alice", LockScopeShared, LockDepthZero)
+	s.Require().NoError(err)
+	bob, err := s.create("/Workspace-a/file.txt", "bob", LockScopeShared, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.NoError(s.mgr.Check("/Workspace-a/file.txt", "alice", []string{alice.Token}, LockCheckOptions{}))
+	s.NoError(s.mgr.Check("/Workspace-a/file.txt", "bob", []string{bob.Token}, LockCheckOptions{}))
+	s.ErrorIs(s.mgr.Check("/Workspace-a/file.txt", "alice", []string{bob.Token}, LockCheckOptions{}), errLockConflict)
+	s.ErrorIs(s.mgr.Check("/Workspace-a/file.txt", "carol", nil, LockCheckOptions{}), errLockConflict)
+}
+
+func (s *LockManagerTestSuite) TestCheck_SharedUnderExclusive() {
+	folder, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+	file, err := s.create("/Workspace-a/folder/file.txt", "bob", LockScopeShared, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.NoError(s.mgr.Check("/Workspace-a/folder/file.txt", "bob", []string{file.Token}, LockCheckOptions{}))
+	s.ErrorIs(
+		s.mgr.Check("/Workspace-a/folder/file.txt", "bob", []string{file.Token}, LockCheckOptions{ChangesMembership: true}),
+		errLockConflict,
+	)
+	s.ErrorIs(
+		s.mgr.Check("/Workspace-a/folder/file.txt", "alice", []string{folder.Token}, LockCheckOptions{ChangesMembership: true}),
+		errLockConflict,
+	)
+}
+
+func (s *LockManagerTestSuite) TestCheck_ChangesMembership() {
+	_, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthZero)
+	s.Require().NoError(err)
+
+	s.NoError(s.mgr.Check("/Workspace-a/folder/new.txt", "bob", nil, LockCheckOptions{}))
+	s.ErrorIs(s.mgr.Check("/Workspace-a/folder/new.txt", "bob", nil, LockCheckOptions{ChangesMembership: true}), errLockConflict)
+}
+
+func (s *LockManagerTestSuite) TestDiscover() {
+	folder, err := s.create("/Workspace-a/folder", "alice", LockScopeExclusive, LockDepthInfinity)
+	s.Require().NoError(err)
+
+	locks, err := s.mgr.Discover("/Workspace-a/folder", []string{"/Workspace-a/folder/file.txt"})
+	s.Require().NoError(err)
+	s.Require().Len(locks["/Workspace-a/folder"], 1)
+	s.Require().Len(locks["/Workspace-a/folder/file.txt"], 1)
+	s.Equal(folder.Token, locks["/Workspace-a/folder/file.txt"][0].Token)
+}
+
+func (s *LockManagerTestSuite) TestMutex_ReleaseKeepsOtherHolder() {
+	unlock, err := s.mgr.acquireMutex("/Workspace-a")
+	s.Require().NoError(err)
+	key := s.mgr.key("/Workspace-a") + ":mutex"
+
+	/* Simulate the mutex expiring and being acquired by someone else */
+	s.miniredis.Del(key)
+	s.Require().NoError(s.miniredis.Set(key, "other"))
+
+	unlock()
+	value, err := s.miniredis.Get(key)
+	s.Require().NoError(err)
+	s.Equal("other", value)
+}
+
+func (s *LockManagerTestSuite) TestMutex_Release() {
+	unlock, err := s.mgr.acquireMutex("/Workspace-a")
+	s.Require().NoError(err)
+	unlock()
+
+	s.False(s.miniredis.Exists(s.mgr.key("/Workspace-a") + ":mutex"))
+}
+
+func (s *LockManagerTestSuite) create(path string, username string, scope string, depth string) (*Lock, error) {
+	return s.mgr.Create(LockCreateOptions{
+		Path:     path,
+		Username: username,
+		Scope:    scope,
+		Depth:    depth,
+	})
+}
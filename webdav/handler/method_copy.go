@@ -28,6 +28,7 @@ This method copies a resource from a source URL to a destination URL.
 Example implementation:
 
 - Extract the source and destination paths from the headers or request body.
+- Check that the destination is not locked, or that the lock token was submitted in the If header.
 - Use fs.copyFile() to copy the file from the source to the destination.
 - Set the response status code to 204 if successful or an appropriate error code if the source file is not found or encountered an error.
 - Return the response.
@@ -41,6 +42,9 @@ func (h *Handler) methodCopy(w http.ResponseWriter, r *http.Request) {
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
 	sourcePath := helper.DecodeURIComponent(r.URL.Path)
 	targetPath := helper.DecodeURIComponent(helper.GetTargetPath(r))
+	if !h.checkLocks(w, r, targetPath, LockCheckOptions{Recursive: true, ChangesMembership: true}) {
+		return
+	}
 	sourceFile, err := cl.GetByPath(sourcePath)
 	if err != nil {
 		handleError(err, w)
@@ -19,6 +19,7 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/helper"
 
 	"github.com/kouprlabs/voltaserve/webdav/config"
+	"github.com/kouprlabs/voltaserve/webdav/logger"
 )
 
 /*
@@ -27,6 +28,7 @@ This method deletes a resource identified by the URL.
 Example implementation:
 
 - Extract the file path from the URL.
+- Check that the resource and its members are not locked, or that the lock tokens were submitted in the If header.
 - Delete the file.
 - Set the response status code to 204 if successful or an appropriate error code if the file is not found.
 - Return the response.
@@ -37,8 +39,12 @@ func (h *Handler) methodDelete(w http.ResponseWriter, r *http.Request) {
 		handleError(fmt.Errorf("missing token"), w)
 		return
 	}
+	filePath := helper.DecodeURIComponent(r.URL.Path)
+	if !h.checkLocks(w, r, filePath, LockCheckOptions{Recursive: true, ChangesMembership: true}) {
+		return
+	}
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
-	file, err := cl.GetByPath(helper.DecodeURIComponent(r.URL.Path))
+	file, err := cl.GetByPath(filePath)
 	if err != nil {
 		handleError(err, w)
 		return
@@ -47,5 +53,8 @@ func (h *Handler) methodDelete(w http.ResponseWriter, r *http.Request) {
 		handleError(err, w)
 		return
 	}
+	if err = h.locks.DeleteTree(filePath); err != nil {
+		logger.GetLogger().Error(err)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
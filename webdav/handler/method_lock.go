@@ -0,0 +1,293 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+	"github.com/kouprlabs/voltaserve/shared/model"
+
+	"github.com/kouprlabs/voltaserve/webdav/config"
+)
+
+type lockInfo struct {
+	XMLName   xml.Name `xml:"DAV: lockinfo"`
+	LockScope struct {
+		Exclusive *struct{} `xml:"DAV: exclusive"`
+		Shared    *struct{} `xml:"DAV: shared"`
+	} `xml:"DAV: lockscope"`
+	LockType struct {
+		Write *struct{} `xml:"DAV: write"`
+	} `xml:"DAV: locktype"`
+	Owner struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"DAV: owner"`
+}
+
+/*
+This method locks a resource to prevent other clients from modifying it.
+
+Example implementation:
+
+- Extract the file path from the URL.
+- Parse the request body to extract the lock scope, lock type and owner, or refresh an existing lock if the body is empty.
+- Check that the lock does not conflict with an existing lock.
+- Create an empty file if the URL is not mapped to a resource.
+- Set the Lock-Token header and format the response body with the lock discovery.
+- Set the response status code to 200 if locked, 201 if a file was created, or 423 if the resource is already locked.
+- Return the response.
+*/
+func (h *Handler) methodLock(w http.ResponseWriter, r *http.Request) {
+	token, ok := r.Context().Value("token").(*dto.Token)
+	if !ok {
+		handleError(fmt.Errorf("missing token"), w)
+		return
+	}
+	username, _, _ := r.BasicAuth()
+	filePath := helper.DecodeURIComponent(r.URL.Path)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	timeout := parseTimeoutHeader(r.Header.Get("Timeout"))
+	if len(strings.TrimSpace(string(body))) == 0 {
+		tokens := parseIfHeader(r.Header.Get("If"))
+		if len(tokens) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock, err := h.locks.Refresh(filePath, tokens[0], username, timeout)
+		if err != nil {
+			if errors.Is(err, errLockNotFound) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			handleError(err, w)
+			return
+		}
+		writeLockResponse(w, http.StatusOK, lock)
+		return
+	}
+	info := lockInfo{}
+	if err := xml.Unmarshal(body, &info); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if info.LockType.Write == nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	scope := LockScopeExclusive
+	if info.LockScope.Shared != nil {
+		scope = LockScopeShared
+	}
+	depth := LockDepthInfinity
+	if r.Header.Get("Depth") == LockDepthZero {
+		depth = LockDepthZero
+	}
+	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
+	status := http.StatusOK
+	if _, err := cl.GetByPath(filePath); err != nil {
+		/* Lock-null resources are not supported, so we create an empty file instead, as per RFC 4918 */
+		if err := h.createEmptyFile(cl, r.URL.Path); err != nil {
+			handleError(err, w)
+			return
+		}
+		status = http.StatusCreated
+	}
+	lock, err := h.locks.Create(LockCreateOptions{
+		Path:     filePath,
+		Username: username,
+		Scope:    scope,
+		Depth:    depth,
+		Owner:    strings.TrimSpace(info.Owner.InnerXML),
+		Timeout:  timeout,
+	})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	writeLockResponse(w, status, lock)
+}
+
+func (h *Handler) createEmptyFile(cl *client.FileClient, urlPath string) error {
+	name := helper.DecodeURIComponent(path.Base(urlPath))
+	directory, err := cl.GetByPath(helper.DecodeURIComponent(helper.Dirname(urlPath)))
+	if err != nil {
+		return err
+	}
+	workspaceClient := client.NewWorkspaceClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
+	bucket, err := workspaceClient.GetBucket(helper.ExtractWorkspaceIDFromPath(urlPath))
+	if err != nil {
+		return err
+	}
+	snapshotID := helper.NewID()
+	key := snapshotID + "/original" + strings.ToLower(path.Ext(name))
+	contentType := helper.DetectMIMEFromPath(name)
+	if err := h.s3.PutText(key, "", contentType, bucket, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+	if _, err := cl.CreateFromS3(client.FileCreateFromS3Options{
+		Type:        model.FileTypeFile,
+		WorkspaceID: directory.Workspace.ID,
+		ParentID:    directory.ID,
+		Name:        name,
+		S3Reference: model.S3Reference{
+			Bucket:      bucket,
+			Key:         key,
+			SnapshotID:  snapshotID,
+			Size:        0,
+			ContentType: contentType,
+		},
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeLockResponse(w http.ResponseWriter, status int, lock *Lock) {
+	responseXml := fmt.Sprintf(
+		`<?xml version="1.0" encoding="utf-8"?>
+		<D:prop xmlns:D="DAV:">
+			<D:lockdiscovery>%s</D:lockdiscovery>
+		</D:prop>`,
+		formatActiveLock(lock),
+	)
+	w.Header().Set("Lock-Token", "<"+lock.Token+">")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(responseXml)); err != nil {
+		handleError(err, w)
+		return
+	}
+}
+
+func formatActiveLock(lock *Lock) string {
+	return fmt.Sprintf(
+		`<D:activelock>
+			<D:locktype><D:write/></D:locktype>
+			<D:lockscope><D:%s/></D:lockscope>
+			<D:depth>%s</D:depth>
+			<D:owner>%s</D:owner>
+			<D:timeout>Second-%d</D:timeout>
+			<D:locktoken><D:href>%s</D:href></D:locktoken>
+			<D:lockroot><D:href>%s</D:href></D:lockroot>
+		</D:activelock>`,
+		lock.Scope,
+		lock.Depth,
+		lock.Owner,
+		lock.Timeout,
+		lock.Token,
+		helper.EncodeURIComponent(lock.Path),
+	)
+}
+
+func formatLockDiscovery(locks []*Lock) string {
+	var res string
+	for _, l := range locks {
+		res += formatActiveLock(l)
+	}
+	return fmt.Sprintf(
+		`<D:supportedlock>
+			<D:lockentry>
+				<D:lockscope><D:exclusive/></D:lockscope>
+				<D:locktype><D:write/></D:locktype>
+			</D:lockentry>
+			<D:lockentry>
+				<D:lockscope><D:shared/></D:lockscope>
+				<D:locktype><D:write/></D:locktype>
+			</D:lockentry>
+		</D:supportedlock>
+		<D:lockdiscovery>%s</D:lockdiscovery>`,
+		res,
+	)
+}
+
+func parseTimeoutHeader(value string) time.Duration {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if strings.EqualFold(part, "Infinite") {
+			return lockMaxTimeout
+		}
+		if strings.HasPrefix(part, "Second-") {
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(part, "Second-"), 10, 64)
+			if err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return lockDefaultTimeout
+}
+
+// parseIfHeader extracts the state tokens submitted in the If header, e.g.
+// (<opaquelocktoken:...>) or <http://host/path> (<opaquelocktoken:...>).
+// Resource tags and entity tags are ignored, so are negated conditions.
+func parseIfHeader(value string) []string {
+	var res []string
+	inList := false
+	negated := false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '(':
+			inList = true
+			negated = false
+		case ')':
+			inList = false
+		case '[':
+			end := strings.IndexByte(value[i:], ']')
+			if end == -1 {
+				return res
+			}
+			i += end
+		case '<':
+			end := strings.IndexByte(value[i:], '>')
+			if end == -1 {
+				return res
+			}
+			if inList && !negated {
+				res = append(res, value[i+1:i+end])
+			}
+			negated = false
+			i += end
+		case 'N', 'n':
+			if inList && i+3 <= len(value) && strings.EqualFold(value[i:i+3], "Not") {
+				negated = true
+				i += 2
+			}
+		}
+	}
+	return res
+}
+
+// checkLocks makes sure the request is allowed to modify the resource at
+// filePath, it writes a 423 Locked response and returns false otherwise.
+func (h *Handler) checkLocks(w http.ResponseWriter, r *http.Request, filePath string, opts LockCheckOptions) bool {
+	username, _, _ := r.BasicAuth()
+	if err := h.locks.Check(filePath, username, parseIfHeader(r.Header.Get("If")), opts); err != nil {
+		handleError(err, w)
+		return false
+	}
+	return true
+}
@@ -31,6 +31,7 @@ This method creates a new collection (directory) at the specified URL.
 Example implementation:
 
 - Extract the directory path from the URL.
+- Check that the parent collection is not locked, or that the lock token was submitted in the If header.
 - Create the directory.
 - Set the response status code to 201 if created or an appropriate error code if the directory already exists or encountered an error.
 - Return the response.
@@ -41,6 +42,9 @@ func (h *Handler) methodMkcol(w http.ResponseWriter, r *http.Request) {
 		handleError(fmt.Errorf("missing token"), w)
 		return
 	}
+	if !h.checkLocks(w, r, helper.DecodeURIComponent(r.URL.Path), LockCheckOptions{ChangesMembership: true}) {
+		return
+	}
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
 	rootPath := helper.DecodeURIComponent(getRootPath(r.URL.Path))
 	rootDir, err := cl.GetByPath(rootPath)
@@ -21,6 +21,7 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/helper"
 
 	"github.com/kouprlabs/voltaserve/webdav/config"
+	"github.com/kouprlabs/voltaserve/webdav/logger"
 )
 
 /*
@@ -29,6 +30,7 @@ This method moves or renames a resource from a source URL to a destination URL.
 Example implementation:
 
 - Extract the source and destination paths from the headers or request body.
+- Check that neither the source nor the destination is locked, or that the lock tokens were submitted in the If header.
 - Move or rename the file from the source to the destination.
 - Set the response status code to 204 if successful or an appropriate error code if the source file is not found or encountered an error.
 - Return the response.
@@ -42,6 +44,10 @@ func (h *Handler) methodMove(w http.ResponseWriter, r *http.Request) {
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
 	sourcePath := helper.DecodeURIComponent(r.URL.Path)
 	targetPath := helper.DecodeURIComponent(helper.GetTargetPath(r))
+	if !h.checkLocks(w, r, sourcePath, LockCheckOptions{Recursive: true, ChangesMembership: true}) ||
+		!h.checkLocks(w, r, targetPath, LockCheckOptions{Recursive: true, ChangesMembership: true}) {
+		return
+	}
 	sourceFile, err := cl.GetByPath(sourcePath)
 	if err != nil {
 		handleError(err, w)
@@ -75,6 +81,9 @@ func (h *Handler) methodMove(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+		if err := h.locks.DeleteTree(sourcePath); err != nil {
+			logger.GetLogger().Error(err)
+		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -21,9 +21,11 @@ Example implementation:
 
 - Set the response status code to 200.
 - Set the Allow header to specify the supported methods, such as OPTIONS, GET, PUT, DELETE, etc.
+- Set the DAV header to advertise the compliance classes, class 2 meaning that locking is supported.
 - Return the response.
 */
 func (h *Handler) methodOptions(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH")
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK")
+	w.Header().Set("DAV", "1, 2")
 	w.WriteHeader(http.StatusOK)
 }
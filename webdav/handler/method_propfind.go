@@ -13,6 +13,7 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"path"
 
 	"github.com/kouprlabs/voltaserve/shared/client"
 	"github.com/kouprlabs/voltaserve/shared/dto"
@@ -20,15 +21,22 @@ import (
 	"github.com/kouprlabs/voltaserve/shared/model"
 
 	"github.com/kouprlabs/voltaserve/webdav/config"
+	"github.com/kouprlabs/voltaserve/webdav/logger"
 )
 
+// DeadPropertiesBatchSize is the maximum number of files whose dead properties
+// are fetched with a single request.
+const DeadPropertiesBatchSize = 1000
+
 /*
 This method retrieves properties and metadata of a resource.
 
 Example implementation:
 
 - Extract the file path from the URL.
+- Parse the request body to determine whether all properties, property names or specific properties are requested.
 - Retrieve the file metadata.
+- Retrieve the dead properties and the active locks of the file and its children.
 - Format the response body in the desired XML format with the properties and metadata.
 - Set the response status code to 207 if successful or an appropriate error code if the file is not found or encountered an error.
 - Set the Content-Type header to indicate the XML format.
@@ -40,38 +48,30 @@ func (h *Handler) methodPropfind(w http.ResponseWriter, r *http.Request) {
 		handleError(fmt.Errorf("missing token"), w)
 		return
 	}
+	req, err := parsePropfindRequest(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
-	file, err := cl.GetByPath(helper.DecodeURIComponent(r.URL.Path))
+	filePath := helper.DecodeURIComponent(r.URL.Path)
+	file, err := cl.GetByPath(filePath)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
 	if file.Type == model.FileTypeFile {
+		locks := h.discoverLocks(filePath, nil)
+		properties := h.getDeadProperties(cl, []*dto.File{file}, req)
 		responseXml := fmt.Sprintf(
-			`<D:multistatus xmlns:D="DAV:">
-				<D:response>
-					<D:href>%s</D:href>
-					<D:propstat>
-						<D:prop>
-							<D:resourcetype></D:resourcetype>
-							<D:getcontentlength>%d</D:getcontentlength>
-							<D:creationdate>%s</D:creationdate>
-							<D:getlastmodified>%s</D:getlastmodified>
-						</D:prop>
-						<D:status>HTTP/1.1 200 OK</D:status>
-					</D:propstat>
-				</D:response>
-			</D:multistatus>`,
-			helper.EncodeURIComponent(file.Name),
-			func() int64 {
-				if file.Type == model.FileTypeFile && file.Snapshot != nil && file.Snapshot.Original != nil {
-					return file.Snapshot.Original.Size
-				} else {
-					return 0
-				}
-			}(),
-			helper.ToUTCString(&file.CreateTime),
-			helper.ToUTCString(file.UpdateTime),
+			`<D:multistatus xmlns:D="DAV:">%s</D:multistatus>`,
+			formatPropfindResponse(
+				helper.EncodeURIComponent(file.Name),
+				file,
+				properties[file.ID],
+				locks[normalizeLockPath(filePath)],
+				req,
+			),
 		)
 		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
 		w.WriteHeader(http.StatusMultiStatus)
@@ -80,61 +80,37 @@ func (h *Handler) methodPropfind(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else if file.Type == model.FileTypeFolder {
-		responseXml := fmt.Sprintf(
-			`<D:multistatus xmlns:D="DAV:">
-				<D:response>
-					<D:href>%s</D:href>
-					<D:propstat>
-						<D:prop>
-							<D:resourcetype><D:collection/></D:resourcetype>
-							<D:getcontentlength>0</D:getcontentlength>
-							<D:getlastmodified>%s</D:getlastmodified>
-							<D:creationdate>%s</D:creationdate>
-						</D:prop>
-						<D:status>HTTP/1.1 200 OK</D:status>
-					</D:propstat>
-				</D:response>`,
-			helper.EncodeURIComponent(r.URL.Path),
-			helper.ToUTCString(file.UpdateTime),
-			helper.ToUTCString(&file.CreateTime),
-		)
-		list, err := cl.ListByPath(helper.DecodeURIComponent(r.URL.Path))
+		list, err := cl.ListByPath(filePath)
 		if err != nil {
 			handleError(err, w)
 			return
 		}
+		var members []string
 		for _, item := range list {
-			itemXml := fmt.Sprintf(
-				`<D:response>
-					<D:href>%s</D:href>
-					<D:propstat>
-						<D:prop>
-							<D:resourcetype>%s</D:resourcetype>
-							<D:getcontentlength>%d</D:getcontentlength>
-							<D:getlastmodified>%s</D:getlastmodified>
-							<D:creationdate>%s</D:creationdate>
-						</D:prop>
-						<D:status>HTTP/1.1 200 OK</D:status>
-					</D:propstat>
-				</D:response>`,
+			members = append(members, path.Join(filePath, item.Name))
+		}
+		locks := h.discoverLocks(filePath, members)
+		files := []*dto.File{file}
+		for i := range list {
+			files = append(files, &list[i])
+		}
+		properties := h.getDeadProperties(cl, files, req)
+		responseXml := `<D:multistatus xmlns:D="DAV:">`
+		responseXml += formatPropfindResponse(
+			helper.EncodeURIComponent(r.URL.Path),
+			file,
+			properties[file.ID],
+			locks[normalizeLockPath(filePath)],
+			req,
+		)
+		for _, item := range list {
+			responseXml += formatPropfindResponse(
 				helper.EncodeURIComponent(r.URL.Path+item.Name),
-				func() string {
-					if item.Type == model.FileTypeFolder {
-						return "<D:collection/>"
-					}
-					return ""
-				}(),
-				func() int64 {
-					if item.Type == model.FileTypeFile && item.Snapshot != nil && item.Snapshot.Original != nil {
-						return item.Snapshot.Original.Size
-					} else {
-						return 0
-					}
-				}(),
-				helper.ToUTCString(item.UpdateTime),
-				helper.ToUTCString(&item.CreateTime),
+				&item,
+				properties[item.ID],
+				locks[normalizeLockPath(path.Join(filePath, item.Name))],
+				req,
 			)
-			responseXml += itemXml
 		}
 		responseXml += `</D:multistatus>`
 		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
@@ -145,3 +121,101 @@ func (h *Handler) methodPropfind(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+func formatPropfindResponse(href string, file *dto.File, properties []dto.FileProperty, locks []*Lock, req propfindRequest) string {
+	if req.Mode == propfindModePropName {
+		return fmt.Sprintf(
+			`<D:response>
+				<D:href>%s</D:href>
+				<D:propstat>
+					<D:prop>
+						<D:resourcetype/>
+						<D:getcontentlength/>
+						<D:creationdate/>
+						<D:getlastmodified/>
+						<D:supportedlock/>
+						<D:lockdiscovery/>
+						%s
+					</D:prop>
+					<D:status>HTTP/1.1 200 OK</D:status>
+				</D:propstat>
+			</D:response>`,
+			href,
+			formatDeadProperties(properties, false),
+		)
+	}
+	found, missing := filterDeadProperties(properties, req)
+	return fmt.Sprintf(
+		`<D:response>
+			<D:href>%s</D:href>
+			<D:propstat>
+				<D:prop>
+					<D:resourcetype>%s</D:resourcetype>
+					<D:getcontentlength>%d</D:getcontentlength>
+					<D:creationdate>%s</D:creationdate>
+					<D:getlastmodified>%s</D:getlastmodified>
+					%s
+					%s
+				</D:prop>
+				<D:status>HTTP/1.1 200 OK</D:status>
+			</D:propstat>
+			%s
+		</D:response>`,
+		href,
+		func() string {
+			if file.Type == model.FileTypeFolder {
+				return "<D:collection/>"
+			}
+			return ""
+		}(),
+		func() int64 {
+			if file.Type == model.FileTypeFile && file.Snapshot != nil && file.Snapshot.Original != nil {
+				return file.Snapshot.Original.Size
+			} else {
+				return 0
+			}
+		}(),
+		helper.ToUTCString(&file.CreateTime),
+		helper.ToUTCString(file.UpdateTime),
+		formatLockDiscovery(locks),
+		formatDeadProperties(found, true),
+		formatPropstat(formatPropertyNames(missing), "404 Not Found"),
+	)
+}
+
+func (h *Handler) getDeadProperties(cl *client.FileClient, files []*dto.File, req propfindRequest) map[string][]dto.FileProperty {
+	if !req.WantsDeadProperties() {
+		return nil
+	}
+	var ids []string
+	for _, file := range files {
+		/* The root folder listing the workspaces is virtual, so it cannot have dead properties */
+		if file.ID != file.Workspace.ID {
+			ids = append(ids, file.ID)
+		}
+	}
+	res := make(map[string][]dto.FileProperty)
+	for start := 0; start < len(ids); start += DeadPropertiesBatchSize {
+		end := min(start+DeadPropertiesBatchSize, len(ids))
+		batch, err := cl.ListPropertiesMany(dto.FileListPropertiesManyOptions{IDs: ids[start:end]})
+		if err != nil {
+			/* Dead properties are best effort, live properties are still returned without them */
+			logger.GetLogger().Error(err)
+			return nil
+		}
+		for id, properties := range batch {
+			res[id] = properties
+		}
+	}
+	return res
+}
+
+func (h *Handler) discoverLocks(filePath string, members []string) map[string][]*Lock {
+	res, err := h.locks.Discover(filePath, members)
+	if err != nil {
+		/* Lock discovery is best effort, properties are still returned without it */
+		logger.GetLogger().Error(err)
+		return make(map[string][]*Lock)
+	}
+	return res
+}
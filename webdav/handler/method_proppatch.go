@@ -11,7 +11,14 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+
+	"github.com/kouprlabs/voltaserve/shared/client"
+	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/helper"
+
+	"github.com/kouprlabs/voltaserve/webdav/config"
 )
 
 /*
@@ -19,30 +26,56 @@ This method updates the properties of a resource.
 
 Example implementation:
 
-- Parse the request body to extract the properties to be updated.
-- Read the existing data from the file.
-- Parse the existing properties.
-- Merge the updated properties with the existing ones.
-- Format the updated properties and store them back in the file.
-- Set the response status code to 204 if successful or an appropriate error code if the file is not found or encountered an error.
+- Extract the file path from the URL.
+- Check that the resource is not locked, or that the lock token was submitted in the If header.
+- Parse the request body to extract the properties to be set and removed, in document order.
+- Store the dead properties, keyed by the ID of the file, live properties in the DAV: namespace are protected.
+- Set the response status code to 207 with a propstat for each status, or an appropriate error code if the file is not found or encountered an error.
 - Return the response.
 
-In this example implementation, the handleProppatch() method first parses the XML
-payload containing the properties to be updated. Then, it reads the existing data from the file,
-parses the existing properties (assuming an XML format),
-merges the updated properties with the existing ones, and formats
-the properties back into the desired format (e.g., XML).
-
-Finally, the updated properties are written back to the file.
-You can customize the parseProperties() and formatProperties()
-functions to match the specific property format you are using in your WebDAV server.
-
-Note that this implementation assumes a simplified example and may require further
-customization based on your specific property format and requirements.
+Operations are applied in order and atomically, so when one of them fails, it
+is reported with its own status and all the others with 424 Failed Dependency.
 */
-func (h *Handler) methodProppatch(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
-	if _, err := w.Write([]byte(http.StatusText(http.StatusNotImplemented))); err != nil {
+func (h *Handler) methodProppatch(w http.ResponseWriter, r *http.Request) {
+	token, ok := r.Context().Value("token").(*dto.Token)
+	if !ok {
+		handleError(fmt.Errorf("missing token"), w)
+		return
+	}
+	filePath := helper.DecodeURIComponent(r.URL.Path)
+	if !h.checkLocks(w, r, filePath, LockCheckOptions{}) {
+		return
+	}
+	operations, err := parsePropertyUpdate(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
+	file, err := cl.GetByPath(filePath)
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	res, err := cl.PatchProperties(file.ID, dto.FilePatchPropertiesOptions{Operations: operations})
+	if err != nil {
+		handleError(err, w)
+		return
+	}
+	responseXml := fmt.Sprintf(
+		`<D:multistatus xmlns:D="DAV:">
+			<D:response>
+				<D:href>%s</D:href>
+				%s
+			</D:response>
+		</D:multistatus>`,
+		helper.EncodeURIComponent(r.URL.Path),
+		formatPropertyStatuses(res.Statuses),
+	)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err := w.Write([]byte(responseXml)); err != nil {
+		handleError(err, w)
 		return
 	}
 }
@@ -11,20 +11,20 @@
 package handler
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 
 	"github.com/kouprlabs/voltaserve/shared/client"
 	"github.com/kouprlabs/voltaserve/shared/dto"
+	"github.com/kouprlabs/voltaserve/shared/errorpkg"
 	"github.com/kouprlabs/voltaserve/shared/helper"
 	"github.com/kouprlabs/voltaserve/shared/model"
 
@@ -32,15 +32,30 @@ import (
 	"github.com/kouprlabs/voltaserve/webdav/logger"
 )
 
+const (
+	// putPartSize is the size of the parts of the multipart upload, it bounds the
+	// memory used per upload, and allows objects of up to 10,000 times this size.
+	putPartSize = 64 * 1024 * 1024
+	// putSniffSize is the number of bytes needed to detect the MIME type of the content.
+	putSniffSize = 3072
+	// putLabelHeader and putNoteHeader carry the percent-encoded label and note
+	// of the snapshot created by the upload, WebDAV has no other way to pass them.
+	putLabelHeader = "X-Snapshot-Label"
+	putNoteHeader  = "X-Snapshot-Note"
+)
+
+var errPutQuotaExceeded = errors.New("storage limit exceeded while receiving the request body")
+
 /*
 This method creates or updates a resource with the provided content.
 
 Example implementation:
 
 - Extract the file path from the URL.
-- Create a write stream to the file.
-- Listen for the data event to write the incoming data to the file.
-- Listen for the end event to indicate the completion of the write stream.
+- Check that the resource is not locked, or that the lock token was submitted in the If header.
+- Check that the workspace has enough space for the Content-Length, or for whatever is received when the body is chunked.
+- Stream the request body straight to S3 as a multipart upload, aborting the upload if the client disconnects.
+- Pass the snapshot label and note from the X-Snapshot-Label and X-Snapshot-Note headers, if present.
 - Set the response status code to 201 if created or 204 if updated.
 - Return the response.
 */
@@ -55,31 +70,29 @@ func (h *Handler) methodPut(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if !h.checkLocks(w, r, helper.DecodeURIComponent(r.URL.Path), LockCheckOptions{}) {
+		return
+	}
 	cl := client.NewFileClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
 	directory, err := cl.GetByPath(helper.DecodeURIComponent(helper.Dirname(r.URL.Path)))
 	if err != nil {
 		handleError(err, w)
 		return
 	}
-	outputPath := filepath.Join(os.TempDir(), uuid.New().String())
-	//nolint:gosec // Known safe path
-	file, err := os.Create(outputPath)
+	/* ContentLength is -1 when the body is sent with chunked transfer encoding */
+	size := r.ContentLength
+	usage, err := client.NewStorageClient(token, config.GetConfig().APIURL, config.GetConfig().Security.APIKey).
+		GetWorkspaceUsage(directory.Workspace.ID)
 	if err != nil {
 		handleError(err, w)
 		return
 	}
-	defer func(path string, file *os.File) {
-		if err := file.Close(); err != nil {
-			handleError(err, w)
-		}
-		if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
-			return
-		} else if err != nil {
-			logger.GetLogger().Error(err)
-		}
-	}(outputPath, file)
-	if _, err = io.Copy(file, r.Body); err != nil {
-		handleError(err, w)
+	remaining := usage.MaxBytes - usage.Bytes
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining <= 0 || size > remaining {
+		handleError(errorpkg.NewStorageLimitExceededError(), w)
 		return
 	}
 	workspaceClient := client.NewWorkspaceClient(config.GetConfig().APIURL, config.GetConfig().Security.APIKey)
@@ -90,21 +103,29 @@ func (h *Handler) methodPut(w http.ResponseWriter, r *http.Request) {
 	}
 	snapshotID := helper.NewID()
 	key := snapshotID + "/original" + strings.ToLower(filepath.Ext(name))
-	if err = h.s3.PutFile(key, outputPath, helper.DetectMIMEFromPath(outputPath), bucket, minio.PutObjectOptions{}); err != nil {
-		handleError(err, w)
+	body := bufio.NewReaderSize(&putBodyReader{reader: r.Body, remaining: remaining}, putSniffSize)
+	/* Peek returns what it could read when the body is shorter than the sniff size */
+	head, err := body.Peek(putSniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		h.handlePutError(err, w)
 		return
 	}
-	stat, err := os.Stat(outputPath)
-	if err != nil {
-		handleError(err, w)
+	contentType := helper.DetectMIMEFromBytes(head)
+	counter := &putBodyReader{reader: body, remaining: -1}
+	if err = h.s3.PutObject(key, counter, size, contentType, bucket, minio.PutObjectOptions{PartSize: putPartSize}); err != nil {
+		/* The multipart upload is aborted by the S3 client when the body fails to read, this makes sure nothing is left behind */
+		if err := h.s3.RemoveIncompleteUpload(key, bucket); err != nil {
+			logger.GetLogger().Error(err)
+		}
+		h.handlePutError(err, w)
 		return
 	}
 	s3Reference := model.S3Reference{
 		Bucket:      bucket,
 		Key:         key,
 		SnapshotID:  snapshotID,
-		Size:        stat.Size(),
-		ContentType: helper.DetectMIMEFromPath(outputPath),
+		Size:        counter.read,
+		ContentType: contentType,
 	}
 	existingFile, err := cl.GetByPath(r.URL.Path)
 	if err == nil {
@@ -112,23 +133,74 @@ func (h *Handler) methodPut(w http.ResponseWriter, r *http.Request) {
 			ID:          existingFile.ID,
 			Name:        name,
 			S3Reference: s3Reference,
+			Label:       h.getPutHeader(r, putLabelHeader),
+			Note:        h.getPutHeader(r, putNoteHeader),
 		}); err != nil {
+			h.removeOrphanObject(key, bucket)
 			handleError(err, w)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		return
 	} else {
+		if !h.checkLocks(w, r, helper.DecodeURIComponent(r.URL.Path), LockCheckOptions{ChangesMembership: true}) {
+			h.removeOrphanObject(key, bucket)
+			return
+		}
 		if _, err = cl.CreateFromS3(client.FileCreateFromS3Options{
 			Type:        model.FileTypeFile,
 			WorkspaceID: directory.Workspace.ID,
 			ParentID:    directory.ID,
 			Name:        name,
 			S3Reference: s3Reference,
+			Label:       h.getPutHeader(r, putLabelHeader),
+			Note:        h.getPutHeader(r, putNoteHeader),
 		}); err != nil {
+			h.removeOrphanObject(key, bucket)
 			handleError(err, w)
 			return
 		}
 	}
 	w.WriteHeader(http.StatusCreated)
 }
+
+func (h *Handler) handlePutError(err error, w http.ResponseWriter) {
+	if errors.Is(err, errPutQuotaExceeded) {
+		handleError(errorpkg.NewStorageLimitExceededError(), w)
+		return
+	}
+	handleError(err, w)
+}
+
+func (h *Handler) getPutHeader(r *http.Request, name string) *string {
+	value := helper.DecodeURIComponent(r.Header.Get(name))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (h *Handler) removeOrphanObject(key string, bucket string) {
+	if err := h.s3.RemoveObject(key, bucket, minio.RemoveObjectOptions{}); err != nil {
+		logger.GetLogger().Error(err)
+	}
+}
+
+// putBodyReader counts the bytes read from the request body, and fails once
+// more than the remaining space of the workspace has been read, which is what
+// enforces the quota when the size is not known in advance. A remaining of -1
+// disables the limit.
+type putBodyReader struct {
+	reader    io.Reader
+	remaining int64
+	read      int64
+}
+
+func (r *putBodyReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.remaining != -1 && r.read > r.remaining {
+		return n, errPutQuotaExceeded
+	}
+	return n, err
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kouprlabs/voltaserve/shared/helper"
+)
+
+/*
+This method removes a lock previously acquired with the LOCK method.
+
+Example implementation:
+
+- Extract the file path from the URL and the lock token from the Lock-Token header.
+- Remove the lock if it is held by the current user.
+- Set the response status code to 204 if successful, 409 if the lock is not found, or 423 if the lock is held by another user.
+- Return the response.
+*/
+func (h *Handler) methodUnlock(w http.ResponseWriter, r *http.Request) {
+	lockToken := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(r.Header.Get("Lock-Token")), "<"), ">")
+	if lockToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	username, _, _ := r.BasicAuth()
+	if err := h.locks.Delete(helper.DecodeURIComponent(r.URL.Path), lockToken, username); err != nil {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
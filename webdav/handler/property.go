@@ -0,0 +1,239 @@
+// Copyright (c) 2023 Anass Bouassaba.
+//
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE in the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the GNU Affero General Public License v3.0 only, included in the file
+// AGPL-3.0-only in the root of this repository.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/kouprlabs/voltaserve/shared/dto"
+)
+
+const davNamespace = "DAV:"
+
+const (
+	propfindModeAllProp  = "allprop"
+	propfindModePropName = "propname"
+	propfindModeProp     = "prop"
+)
+
+type propfindRequest struct {
+	Mode  string
+	Names []xml.Name
+}
+
+// WantsDeadProperties tells whether the dead properties of the resources
+// have to be fetched to answer the request.
+func (p propfindRequest) WantsDeadProperties() bool {
+	if p.Mode != propfindModeProp {
+		return true
+	}
+	for _, n := range p.Names {
+		if n.Space != davNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePropfindRequest parses the body of a PROPFIND request, an empty body
+// must be treated as an allprop request, as per RFC 4918.
+func parsePropfindRequest(body io.Reader) (propfindRequest, error) {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return propfindRequest{}, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return propfindRequest{Mode: propfindModeAllProp}, nil
+	}
+	var value struct {
+		XMLName  xml.Name  `xml:"DAV: propfind"`
+		AllProp  *struct{} `xml:"DAV: allprop"`
+		PropName *struct{} `xml:"DAV: propname"`
+		Prop     *struct {
+			Items []struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"DAV: prop"`
+	}
+	if err := xml.Unmarshal(b, &value); err != nil {
+		return propfindRequest{}, err
+	}
+	switch {
+	case value.PropName != nil:
+		return propfindRequest{Mode: propfindModePropName}, nil
+	case value.Prop != nil:
+		res := propfindRequest{Mode: propfindModeProp}
+		for _, item := range value.Prop.Items {
+			res.Names = append(res.Names, item.XMLName)
+		}
+		return res, nil
+	default:
+		return propfindRequest{Mode: propfindModeAllProp}, nil
+	}
+}
+
+// parsePropertyUpdate parses the body of a PROPPATCH request into operations
+// in document order, the inner XML of each property is kept as is, so that it
+// can be returned verbatim by PROPFIND.
+func parsePropertyUpdate(body io.Reader) ([]dto.FilePropertyOperation, error) {
+	var res []dto.FilePropertyOperation
+	decoder := xml.NewDecoder(body)
+	var action string
+	var inProp bool
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if inProp {
+				var value struct {
+					InnerXML string `xml:",innerxml"`
+				}
+				if err := decoder.DecodeElement(&value, &t); err != nil {
+					return nil, err
+				}
+				operation := dto.FilePropertyOperation{Namespace: t.Name.Space, Name: t.Name.Local}
+				if action == "set" {
+					operation.Action = dto.FilePropertyActionSet
+					operation.Value = &value.InnerXML
+				} else {
+					operation.Action = dto.FilePropertyActionRemove
+				}
+				res = append(res, operation)
+			} else if t.Name.Space == davNamespace {
+				switch t.Name.Local {
+				case "set", "remove":
+					action = t.Name.Local
+				case "prop":
+					inProp = action != ""
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space == davNamespace && t.Name.Local == "prop" {
+				inProp = false
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, errors.New("empty propertyupdate")
+	}
+	return res, nil
+}
+
+func formatDeadProperties(properties []dto.FileProperty, withValues bool) string {
+	var res string
+	for i, p := range properties {
+		value := ""
+		if withValues && p.Value != nil {
+			value = *p.Value
+		}
+		res += formatProperty(i, xml.Name{Space: p.Namespace, Local: p.Name}, value)
+	}
+	return res
+}
+
+func formatPropertyNames(names []xml.Name) string {
+	var res string
+	for i, n := range names {
+		res += formatProperty(i, n, "")
+	}
+	return res
+}
+
+func formatProperty(index int, name xml.Name, value string) string {
+	if name.Space == "" {
+		return fmt.Sprintf(`<%s xmlns="">%s</%s>`, name.Local, value, name.Local)
+	}
+	return fmt.Sprintf(
+		`<P%d:%s xmlns:P%d="%s">%s</P%d:%s>`,
+		index, name.Local, index, escapeXML(name.Space), value, index, name.Local,
+	)
+}
+
+// formatPropstat wraps the given properties in a propstat element with the
+// given status, or returns an empty string if there are no properties.
+func formatPropstat(properties string, status string) string {
+	if properties == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		`<D:propstat>
+			<D:prop>%s</D:prop>
+			<D:status>HTTP/1.1 %s</D:status>
+		</D:propstat>`,
+		properties,
+		status,
+	)
+}
+
+// formatPropertyStatuses groups the properties by status in propstat elements,
+// keeping the order in which the statuses first appear.
+func formatPropertyStatuses(statuses []dto.FilePropertyStatus) string {
+	var order []int
+	names := make(map[int][]xml.Name)
+	for _, s := range statuses {
+		if _, ok := names[s.Status]; !ok {
+			order = append(order, s.Status)
+		}
+		names[s.Status] = append(names[s.Status], xml.Name{Space: s.Namespace, Local: s.Name})
+	}
+	var res string
+	for _, status := range order {
+		res += formatPropstat(formatPropertyNames(names[status]), fmt.Sprintf("%d %s", status, http.StatusText(status)))
+	}
+	return res
+}
+
+// filterDeadProperties returns the requested dead properties that exist, and
+// the names of the requested dead properties that don't.
+func filterDeadProperties(properties []dto.FileProperty, req propfindRequest) ([]dto.FileProperty, []xml.Name) {
+	if req.Mode != propfindModeProp {
+		return properties, nil
+	}
+	var found []dto.FileProperty
+	var missing []xml.Name
+	for _, n := range req.Names {
+		if n.Space == davNamespace {
+			continue
+		}
+		exists := false
+		for _, p := range properties {
+			if p.Namespace == n.Space && p.Name == n.Local {
+				found = append(found, p)
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			missing = append(missing, n)
+		}
+	}
+	return found, missing
+}
+
+func escapeXML(value string) string {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(value)); err != nil {
+		return ""
+	}
+	return b.String()
+}